@@ -1,21 +1,31 @@
 package main
 
 import (
+  "context"
+  "errors"
   "fmt"
+  "net/http"
   "os"
+  "os/signal"
+  "syscall"
   "time"
   
   "github.com/slotter-org/slotter-backend/internal/logger"
   "github.com/slotter-org/slotter-backend/internal/utils"
   "github.com/slotter-org/slotter-backend/internal/db"
+  "github.com/slotter-org/slotter-backend/internal/events"
+  "github.com/slotter-org/slotter-backend/internal/normalization"
+  "github.com/slotter-org/slotter-backend/internal/presence"
   "github.com/slotter-org/slotter-backend/internal/seed"
   "github.com/slotter-org/slotter-backend/internal/repos"
   "github.com/slotter-org/slotter-backend/internal/services"
+  "github.com/slotter-org/slotter-backend/internal/pubsub"
   "github.com/slotter-org/slotter-backend/internal/socket"
   "github.com/slotter-org/slotter-backend/internal/handlers"
   "github.com/slotter-org/slotter-backend/internal/middleware"
   "github.com/slotter-org/slotter-backend/internal/server"
   "github.com/slotter-org/slotter-backend/internal/sse"
+  "github.com/slotter-org/slotter-backend/internal/types"
 )
 
 func main() {
@@ -70,6 +80,21 @@ func main() {
   roleRepo := repos.NewRoleRepo(thePG, log)
   userTokenRepo := repos.NewUserTokenRepo(thePG, log)
   invitationRepo := repos.NewInvitationRepo(thePG, log)
+  outboxMessageRepo := repos.NewOutboxMessageRepo(thePG, log)
+  smsOptOutRepo := repos.NewSmsOptOutRepo(thePG, log)
+  warehouseLocationRepo := repos.NewWarehouseLocationRepo(thePG, log)
+  itemRepo := repos.NewItemRepo(thePG, log)
+  inventoryRepo := repos.NewInventoryRepo(thePG, log)
+  orderLineRepo := repos.NewOrderLineRepo(thePG, log)
+  velocityRepo := repos.NewVelocityRepo(thePG, log)
+  slottingRepo := repos.NewSlottingRepo(thePG, log)
+  slottingScenarioRepo := repos.NewSlottingScenarioRepo(thePG, log)
+  moveTaskRepo := repos.NewMoveTaskRepo(thePG, log)
+  warehouseNavigationRepo := repos.NewWarehouseNavigationRepo(thePG, log)
+  floorMapRepo := repos.NewFloorMapRepo(thePG, log)
+  chatRepo := repos.NewChatRepo(thePG, log)
+  webhookEndpointRepo := repos.NewWebhookEndpointRepo(thePG, log)
+  auditLogRepo := repos.NewAuditLogRepo(thePG, log)
   log.Info("Repositories Set Up From Main Successful :)")
 
   // Seed Setup
@@ -84,41 +109,98 @@ func main() {
   wsHub := socket.NewHub(log)
   log.Info("Websocket Hub Set Up From Main Successful :)")
 
-  // SSE Hub
-  log.Info("Setting Up SSE Hub From Main Now :)")
-  sseHub := sse.NewSSEHub(log)
-  log.Info("SSE Hub Set Up From Main Successful :)")
-
   // Redis PubSub
   log.Info("Setting Up Redis PubSub From Main Now :)")
-  redisChanName := "slotter_hub_broadcast"
-  redisPubSub, err := socket.NewRedisPubSub(log, redisAddress, redisPassword, redisChanName)
+  var hubPubSub pubsub.PubSub
+  redisPubSub, err := pubsub.NewRedis(log, redisAddress, redisPassword)
   if err != nil {
-    log.Warn("Failed to init redis pubsub", "error", err)
+    log.Warn("Failed to init redis pubsub; hubs only reach clients of this instance", "error", err)
+    hubPubSub = pubsub.NewMemory()
   } else {
-    if err := redisPubSub.StartSubscriber(wsHub); err != nil {
-      log.Warn("Failed to subscribe to Redis pub/sub", "error", err)
+    hubPubSub = redisPubSub
+    log.Info("Redis pubsub is active!")
+  }
+
+  // SSE Hub
+  // Instances sharing the pub/sub must number events in one shared store, so
+  // the memory store is only allowed while the pub/sub is in-process too.
+  log.Info("Setting Up SSE Hub From Main Now :)")
+  sseHub := sse.NewSSEHub(log)
+  sseReplayBackend := utils.GetEnv("SSE_REPLAY_BACKEND", "redis", log)
+  sseReplayBuffer := utils.GetEnvAsInt("SSE_REPLAY_BUFFER", sse.DefaultReplaySize, log)
+  var eventStore sse.EventStore = sse.NewMemoryEventStore(sseReplayBuffer)
+  if sseReplayBackend == "redis" {
+    redisStore, err := sse.NewRedisEventStore(log, redisAddress, redisPassword, "slotter_sse", sseReplayBuffer)
+    if err != nil {
+      if hubPubSub.Shared() {
+        log.Error("Fatal error: Cannot init redis SSE replay store while the pub/sub is shared", "error", err)
+        os.Exit(1)
+      }
+      log.Warn("Failed to init redis SSE replay store; keeping events in memory", "error", err)
     } else {
-      wsHub.SetRedisPubSub(redisPubSub)
-      log.Info("Redis pubsub is active!")
+      eventStore = redisStore
     }
+  } else if hubPubSub.Shared() {
+    log.Error("Fatal error: SSE_REPLAY_BACKEND must be redis while the pub/sub is shared", "backend", sseReplayBackend)
+    os.Exit(1)
+  }
+  if err := sseHub.SetEventStore(eventStore); err != nil {
+    log.Error("Fatal error: Cannot set SSE replay store", "error", err)
+    os.Exit(1)
+  }
+  log.Info("SSE Hub Set Up From Main Successful :)")
+
+  if err := wsHub.SetPubSub(context.Background(), hubPubSub, "slotter_hub_broadcast"); err != nil {
+    log.Warn("Failed to subscribe websocket hub to pub/sub", "error", err)
+  }
+  if err := sseHub.SetPubSub(context.Background(), hubPubSub, "slotter_sse_broadcast"); err != nil {
+    log.Error("Fatal error: Cannot subscribe SSE hub to pub/sub", "error", err)
+    os.Exit(1)
   }
   log.Info("Successfully Set up Redis Pub Sub From Main :)")
 
+  // Presence
+  log.Info("Setting Up Presence Tracker From Main Now...")
+  presenceBackend := utils.GetEnv("PRESENCE_BACKEND", "redis", log)
+  presenceTTL := time.Duration(utils.GetEnvAsInt("PRESENCE_TTL_SECONDS", int(presence.DefaultTTL/time.Second), log)) * time.Second
+  var presenceStore presence.Store = presence.NewMemoryStore()
+  if presenceBackend == "redis" {
+    redisPresenceStore, err := presence.NewRedisStore(log, redisAddress, redisPassword, "slotter_presence")
+    if err != nil {
+      log.Warn("Failed to init redis presence store; only this instance's connections are visible", "error", err)
+    } else {
+      presenceStore = redisPresenceStore
+    }
+  }
+  presenceTracker := presence.NewTracker(log, presenceStore, presenceTTL)
+  log.Info("Presence Tracker Set Up From Main Successful :)")
+
   // Services Setup
   log.Info("Setting up Services from Main now...")
   emailService, err := services.NewEmailService(log)
   if err != nil {
-    log.Warn("Could not init EmailService", "error", err)
+    log.Error("Fatal error: Cannot init EmailService", "error", err)
+    os.Exit(1)
   }
-  textService, err := services.NewTextService(log)
+  normalization.DefaultPhoneRegion = utils.GetEnv("PHONE_DEFAULT_REGION", "US", log)
+  if err := postgresService.BackfillPhoneNumbers(); err != nil {
+    log.Warn("Phone number backfill failed", "error", err)
+  }
+  textService, err := services.NewTextService(thePG, log, smsOptOutRepo)
   if err != nil {
-    log.Warn("Could not init TextService", "error", err)
+    log.Error("Fatal error: Cannot init TextService", "error", err)
+    os.Exit(1)
   }
   bucketService, err := services.NewBucketService(log)
   if err != nil {
-    log.Warn("Could not init BucketService", "error", err)
+    log.Error("Fatal error: Cannot init BucketService", "error", err)
+    os.Exit(1)
+  }
+  var localFilesHandler *handlers.LocalFilesHandler
+  if localStorage, ok := bucketService.(services.LocalStorage); ok {
+    localFilesHandler = handlers.NewLocalFilesHandler(localStorage)
   }
+  fileAccessService := services.NewFileAccessService(thePG, log, companyRepo, bucketService)
   avatarService, err := services.NewAvatarService(thePG, log, wmsRepo, companyRepo, warehouseRepo, userRepo, roleRepo, permissionRepo, bucketService)
   if err != nil {
     log.Error("Fatal error: Cannot init AvatarService", "error", err)
@@ -129,22 +211,108 @@ func main() {
   meService := services.NewMeService(thePG, log, userRepo, wmsRepo, companyRepo, roleRepo)
   myCompanyService := services.NewMyCompanyService(thePG, log, warehouseRepo, companyRepo, userRepo, roleRepo, invitationRepo, permissionRepo)
   myWmsService := services.NewMyWmsService(thePG, log, companyRepo, wmsRepo, userRepo, roleRepo, invitationRepo, permissionRepo)
-  invitationService := services.NewInvitationService(thePG, log, invitationRepo, userRepo, wmsRepo, companyRepo, roleRepo, permissionRepo, textService, emailService, avatarService)
+  templateService, err := services.NewTemplateService(thePG, log, wmsRepo, companyRepo, userRepo)
+  if err != nil {
+    log.Error("Fatal error: Cannot init TemplateService", "error", err)
+    os.Exit(1)
+  }
+  outboxService := services.NewOutboxService(thePG, log, outboxMessageRepo, utils.GetEnvAsInt("OUTBOX_MAX_ATTEMPTS", 8, log))
+  invitationService := services.NewInvitationService(thePG, log, invitationRepo, userRepo, wmsRepo, companyRepo, roleRepo, permissionRepo, avatarService, templateService, outboxService)
   warehouseService := services.NewWarehouseService(thePG, log, userRepo, wmsRepo, companyRepo, roleRepo, permissionRepo, warehouseRepo)
+  warehouseLocationService := services.NewWarehouseLocationService(thePG, log, warehouseService, warehouseLocationRepo)
+  warehouseNavigationService := services.NewWarehouseNavigationService(thePG, log, warehouseService, warehouseLocationRepo, warehouseNavigationRepo)
+  itemService := services.NewItemService(thePG, log, companyRepo, itemRepo)
+  inventoryService := services.NewInventoryService(thePG, log, warehouseService, warehouseLocationRepo, itemRepo, inventoryRepo)
+  velocityService := services.NewVelocityService(thePG, log, warehouseService, itemRepo, orderLineRepo, velocityRepo)
+  if err := velocityService.FailInterruptedRuns(context.Background()); err != nil {
+    log.Warn("Failed to clean up interrupted velocity runs", "error", err)
+  }
+  slottingService := services.NewSlottingService(thePG, log, warehouseService, warehouseLocationRepo, inventoryRepo, itemRepo, velocityRepo, slottingRepo, slottingScenarioRepo, warehouseNavigationRepo)
+  if err := slottingService.FailInterruptedJobs(context.Background()); err != nil {
+    log.Warn("Failed to clean up interrupted slotting jobs", "error", err)
+  }
+  slottingScenarioService := services.NewSlottingScenarioService(thePG, log, warehouseService, slottingService, warehouseLocationRepo, inventoryRepo, itemRepo, velocityRepo, slottingRepo, slottingScenarioRepo, warehouseNavigationRepo)
+  floorMapService := services.NewFloorMapService(thePG, log, warehouseService, bucketService, warehouseLocationRepo, inventoryRepo, itemRepo, velocityRepo, slottingRepo, slottingScenarioRepo, warehouseNavigationRepo, floorMapRepo)
+  llmProvider, err := services.NewLLMProvider(log)
+  if err != nil {
+    log.Error("Fatal error: Cannot init LLM provider", "error", err)
+    os.Exit(1)
+  }
+  chatService := services.NewChatService(thePG, log, llmProvider, chatRepo, userRepo, companyRepo, warehouseRepo, velocityService, inventoryService, slottingScenarioService)
+  moveTaskService := services.NewMoveTaskService(thePG, log, warehouseService, inventoryService, companyRepo, userRepo, warehouseLocationRepo, itemRepo, slottingRepo, slottingScenarioRepo, moveTaskRepo)
+  channelService := services.NewChannelService(log, companyRepo, roleRepo, slottingRepo, warehouseService)
+  wsHub.SetAuthorizer(channelService)
+  webhookService := services.NewWebhookService(thePG, log, webhookEndpointRepo)
+  auditService := services.NewAuditService(thePG, log, auditLogRepo)
+  presenceService := services.NewPresenceService(log, presenceTracker, channelService, userRepo, companyRepo, warehouseRepo, slottingScenarioRepo)
   log.Info("Services Set Up From Main Successful :)")
 
+  // Event Bus
+  log.Info("Setting Up Event Bus From Main Now...")
+  eventBus := events.NewBus(log)
+  eventBus.Subscribe(services.NewSSEEventHandler(sseHub))
+  eventBus.Subscribe(services.NewSocketEventHandler(wsHub))
+  eventJournal := services.NewEventJournal(outboxService)
+  eventBus.Subscribe(eventJournal)
+  log.Info("Event Bus Set Up From Main Successful :)")
+
+  // Outbox Dispatcher
+  log.Info("Starting Outbox Dispatcher From Main Now...")
+  outboxDispatcher := services.NewOutboxDispatcher(log, outboxMessageRepo, map[types.OutboxChannel]services.OutboxTarget{
+    types.OutboxChannelEmail:   services.NewEmailOutboxTarget(emailService),
+    types.OutboxChannelSMS:     services.NewTextOutboxTarget(textService),
+    types.OutboxChannelWebhook: services.NewWebhookOutboxTarget(webhookEndpointRepo),
+    types.OutboxChannelEvent:   services.NewEventOutboxTarget(thePG, log, auditLogRepo, webhookEndpointRepo, outboxService),
+  }, services.OutboxDispatcherConfig{
+    PollInterval: time.Duration(utils.GetEnvAsInt("OUTBOX_POLL_INTERVAL_SECONDS", 5, log)) * time.Second,
+    BatchSize:    utils.GetEnvAsInt("OUTBOX_BATCH_SIZE", 20, log),
+    BackoffBase:  time.Duration(utils.GetEnvAsInt("OUTBOX_BACKOFF_BASE_SECONDS", 30, log)) * time.Second,
+    BackoffMax:   time.Duration(utils.GetEnvAsInt("OUTBOX_BACKOFF_MAX_SECONDS", 3600, log)) * time.Second,
+  })
+  outboxService.SetNotifier(outboxDispatcher.Notify)
+  outboxDispatcher.Start(context.Background())
+  log.Info("Outbox Dispatcher Started From Main Successful :)")
+
+  // Chat Retention Worker
+  log.Info("Starting Chat Retention Worker From Main Now...")
+  chatRetentionWorker := services.NewChatRetentionWorker(log, chatService, time.Duration(utils.GetEnvAsInt("CHAT_RETENTION_INTERVAL_MINUTES", 60, log))*time.Minute)
+  chatRetentionWorker.Start(context.Background())
+  log.Info("Chat Retention Worker Started From Main Successful :)")
+
 
   //  Handler Setup
   log.Info("Setting Up Handlers from Main now...")
-  authHandler := handlers.NewAuthHandler(authService, sseHub)
+  authHandler := handlers.NewAuthHandler(authService)
   meHandler := handlers.NewMeHandler(meService)
   myCompanyHandler := handlers.NewMyCompanyHandler(myCompanyService)
   myWmsHandler := handlers.NewMyWmsHandler(myWmsService)
-  invitationHandler := handlers.NewInvitationHandler(invitationService, sseHub)
-  warehouseHandler := handlers.NewWarehouseHandler(warehouseService, wsHub)
-  roleHandler := handlers.NewRoleHandler(roleService, sseHub)
-  wsHandler := handlers.WsHandler(wsHub, log)
-  sseHandler := handlers.NewSSEHandler(log, sseHub)
+  invitationHandler := handlers.NewInvitationHandler(invitationService)
+  warehouseHandler := handlers.NewWarehouseHandler(warehouseService)
+  roleHandler := handlers.NewRoleHandler(roleService)
+  wsHandler := handlers.WsHandler(wsHub, log, presenceTracker)
+  sseHandler := handlers.NewSSEHandler(log, sseHub, channelService, presenceTracker)
+  templateHandler := handlers.NewTemplateHandler(templateService)
+  outboxHandler := handlers.NewOutboxHandler(outboxService)
+  smsHandler := handlers.NewSMSHandler(textService)
+  fileHandler := handlers.NewFileHandler(fileAccessService)
+  avatarHandler := handlers.NewAvatarHandler(avatarService)
+  locationHandler := handlers.NewWarehouseLocationHandler(warehouseLocationService)
+  navigationHandler := handlers.NewWarehouseNavigationHandler(warehouseNavigationService)
+  floorMapHandler := handlers.NewFloorMapHandler(floorMapService)
+  itemHandler := handlers.NewItemHandler(itemService)
+  inventoryHandler := handlers.NewInventoryHandler(inventoryService)
+  velocityHandler := handlers.NewVelocityHandler(velocityService, eventBus)
+  velocityService.SetNotifier(velocityHandler.RunFinished)
+  slottingHandler := handlers.NewSlottingHandler(slottingService, eventBus)
+  slottingService.SetNotifier(slottingHandler.JobUpdated)
+  scenarioHandler := handlers.NewSlottingScenarioHandler(slottingScenarioService)
+  moveTaskHandler := handlers.NewMoveTaskHandler(moveTaskService)
+  chatHandler := handlers.NewChatHandler(chatService)
+  webhookHandler := handlers.NewWebhookHandler(webhookService)
+  auditHandler := handlers.NewAuditHandler(auditService)
+  presenceHandler := handlers.NewPresenceHandler(presenceService, eventBus)
+  presenceTracker.SetNotifier(presenceHandler.PresenceChanged)
+  presenceTracker.Start(context.Background())
   log.Info("Handlers Set Up From Main Successful :)")
 
   // MiddleWare Setup
@@ -165,17 +333,62 @@ func main() {
     WsHandler:              wsHandler,
     SSEHandler:             sseHandler,
     RoleHandler:            roleHandler,
+    TemplateHandler:        templateHandler,
+    OutboxHandler:          outboxHandler,
+    SMSHandler:             smsHandler,
+    AvatarHandler:          avatarHandler,
+    LocalStorageRoute:      services.LocalStorageRoute,
+    FileHandler:            fileHandler,
+    LocationHandler:        locationHandler,
+    ItemHandler:            itemHandler,
+    InventoryHandler:       inventoryHandler,
+    VelocityHandler:        velocityHandler,
+    SlottingHandler:        slottingHandler,
+    ScenarioHandler:        scenarioHandler,
+    MoveTaskHandler:        moveTaskHandler,
+    NavigationHandler:      navigationHandler,
+    FloorMapHandler:        floorMapHandler,
+    ChatHandler:            chatHandler,
+    WebhookHandler:         webhookHandler,
+    AuditHandler:           auditHandler,
+    PresenceHandler:        presenceHandler,
+    EventBus:               eventBus,
+    EventJournal:           eventJournal,
+    LocalFilesHandler:      localFilesHandler,
   })
   log.Info("Router Set Up From Main Successful :)")
 
   port := utils.GetEnv("PORT", "8080", log)
-  fmt.Printf("Server listening on :%s\n", port)
-  if err := router.Run(":" + port); err != nil {
-    log.Warn("Server failed: %v", err)
+  ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+  defer stop()
+  srv := &http.Server{Addr: ":" + port, Handler: router}
+  // SSE streams only end when the client goes away, so close them rather
+  // than let Shutdown wait on them.
+  srv.RegisterOnShutdown(sseHandler.CloseAll)
+  serverErr := make(chan error, 1)
+  go func() {
+    fmt.Printf("Server listening on :%s\n", port)
+    if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+      serverErr <- err
+    }
+  }()
+  select {
+  case err := <-serverErr:
+    log.Warn("Server failed", "error", err)
+  case <-ctx.Done():
+    log.Info("Shutdown signal received; draining requests now...")
   }
+  stop()
 
   // On Shutdown
-  if redisPubSub != nil {
-    redisPubSub.Stop()
+  shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(utils.GetEnvAsInt("SHUTDOWN_TIMEOUT_SECONDS", 20, log))*time.Second)
+  defer cancel()
+  if err := srv.Shutdown(shutdownCtx); err != nil {
+    log.Warn("Server did not drain before the shutdown timeout", "error", err)
   }
+  presenceTracker.Stop()
+  chatRetentionWorker.Stop()
+  outboxDispatcher.Stop()
+  _ = hubPubSub.Close()
+  log.Info("Shutdown complete")
 }
@@ -0,0 +1,112 @@
+// Package channels names the realtime channels that SSE and websocket
+// clients subscribe to and decides who may subscribe to each. A channel is
+// "<kind>:<uuid>", e.g. "company:6f1c...".
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/slotter-org/slotter-backend/internal/requestdata"
+)
+
+type Kind string
+
+const (
+	KindUser        Kind = "user"
+	KindCompany     Kind = "company"
+	KindWms         Kind = "wms"
+	KindWarehouse   Kind = "warehouse"
+	KindRole        Kind = "role"
+	KindSlottingJob Kind = "slotting_job"
+)
+
+var (
+	// ErrUnknownChannel is returned for names that are malformed or of a
+	// kind with no registered rule.
+	ErrUnknownChannel = errors.New("unknown channel")
+	// ErrForbidden is returned when the caller may not subscribe.
+	ErrForbidden = errors.New("not allowed to subscribe to channel")
+)
+
+func Name(kind Kind, id uuid.UUID) string {
+	return string(kind) + ":" + id.String()
+}
+
+func User(id uuid.UUID) string        { return Name(KindUser, id) }
+func Company(id uuid.UUID) string     { return Name(KindCompany, id) }
+func Wms(id uuid.UUID) string         { return Name(KindWms, id) }
+func Warehouse(id uuid.UUID) string   { return Name(KindWarehouse, id) }
+func Role(id uuid.UUID) string        { return Name(KindRole, id) }
+func SlottingJob(id uuid.UUID) string { return Name(KindSlottingJob, id) }
+
+// Parse splits a channel name into its kind and ID.
+func Parse(channel string) (Kind, uuid.UUID, error) {
+	kind, rawID, ok := strings.Cut(strings.TrimSpace(channel), ":")
+	if !ok || kind == "" {
+		return "", uuid.Nil, fmt.Errorf("%w %q", ErrUnknownChannel, channel)
+	}
+	id, err := uuid.Parse(rawID)
+	if err != nil || id == uuid.Nil {
+		return "", uuid.Nil, fmt.Errorf("%w %q: invalid id", ErrUnknownChannel, channel)
+	}
+	return Kind(kind), id, nil
+}
+
+// Rule decides whether the requester rd may subscribe to the channel of its
+// kind with the given ID. It returns nil to allow.
+type Rule func(ctx context.Context, rd *requestdata.RequestData, id uuid.UUID) error
+
+// Registry holds the rule of every channel kind. Kinds without a rule
+// cannot be subscribed to.
+type Registry struct {
+	rules map[Kind]Rule
+}
+
+func NewRegistry() *Registry {
+	return &Registry{rules: make(map[Kind]Rule)}
+}
+
+func (r *Registry) Register(kind Kind, rule Rule) {
+	r.rules[kind] = rule
+}
+
+// Authorize checks the requester in ctx against the rule of channel's kind.
+func (r *Registry) Authorize(ctx context.Context, channel string) error {
+	kind, id, err := Parse(channel)
+	if err != nil {
+		return err
+	}
+	rule, ok := r.rules[kind]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownChannel, channel)
+	}
+	rd := requestdata.GetRequestData(ctx)
+	if rd == nil || rd.UserID == uuid.Nil {
+		return ErrForbidden
+	}
+	if err := rule(ctx, rd, id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// OwnUser allows a user to subscribe to their own user channel.
+func OwnUser(_ context.Context, rd *requestdata.RequestData, id uuid.UUID) error {
+	if rd.UserID != id {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// OwnWms allows wms users to subscribe to their own wms channel.
+func OwnWms(_ context.Context, rd *requestdata.RequestData, id uuid.UUID) error {
+	if rd.UserType != "wms" || rd.WmsID != id {
+		return ErrForbidden
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/slotter-org/slotter-backend/internal/requestdata"
+)
+
+func TestParse(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		name     string
+		channel  string
+		wantKind Kind
+		wantErr  bool
+	}{
+		{name: "company", channel: Company(id), wantKind: KindCompany},
+		{name: "slotting job", channel: SlottingJob(id), wantKind: KindSlottingJob},
+		{name: "surrounding space", channel: "  " + Wms(id) + " ", wantKind: KindWms},
+		{name: "no separator", channel: "company" + id.String(), wantErr: true},
+		{name: "no kind", channel: ":" + id.String(), wantErr: true},
+		{name: "bad id", channel: "company:acme", wantErr: true},
+		{name: "nil id", channel: Company(uuid.Nil), wantErr: true},
+		{name: "empty", channel: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, gotID, err := Parse(tt.channel)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnknownChannel) {
+					t.Fatalf("err = %v, want %v", err, ErrUnknownChannel)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if kind != tt.wantKind || gotID != id {
+				t.Fatalf("Parse = %s, %s; want %s, %s", kind, gotID, tt.wantKind, id)
+			}
+		})
+	}
+}
+
+func TestRegistryAuthorize(t *testing.T) {
+	wmsID, otherWmsID, companyID := uuid.New(), uuid.New(), uuid.New()
+	wmsViewer := &requestdata.RequestData{UserType: "wms", UserID: uuid.New(), WmsID: wmsID}
+	companyViewer := &requestdata.RequestData{UserType: "company", UserID: uuid.New(), CompanyID: companyID}
+	otherWmsViewer := &requestdata.RequestData{UserType: "wms", UserID: uuid.New(), WmsID: otherWmsID}
+
+	r := NewRegistry()
+	r.Register(KindUser, OwnUser)
+	r.Register(KindWms, OwnWms)
+
+	tests := []struct {
+		name    string
+		rd      *requestdata.RequestData
+		channel string
+		wantErr error
+	}{
+		{name: "own user channel", rd: companyViewer, channel: User(companyViewer.UserID)},
+		{name: "another user's channel", rd: companyViewer, channel: User(wmsViewer.UserID), wantErr: ErrForbidden},
+		{name: "wms viewer on own wms", rd: wmsViewer, channel: Wms(wmsID)},
+		{name: "cross-tenant wms viewer", rd: otherWmsViewer, channel: Wms(wmsID), wantErr: ErrForbidden},
+		{name: "company viewer on a wms", rd: companyViewer, channel: Wms(wmsID), wantErr: ErrForbidden},
+		{name: "no requester", rd: nil, channel: Wms(wmsID), wantErr: ErrForbidden},
+		{name: "requester without user", rd: &requestdata.RequestData{UserType: "wms", WmsID: wmsID}, channel: Wms(wmsID), wantErr: ErrForbidden},
+		{name: "malformed name", rd: wmsViewer, channel: "wms:" + wmsID.String()[:8], wantErr: ErrUnknownChannel},
+		{name: "unknown prefix", rd: wmsViewer, channel: "tenant:" + wmsID.String(), wantErr: ErrUnknownChannel},
+		{name: "kind without a rule", rd: companyViewer, channel: Company(companyID), wantErr: ErrUnknownChannel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.rd != nil {
+				ctx = requestdata.WithRequestData(ctx, tt.rd)
+			}
+			if err := r.Authorize(ctx, tt.channel); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authorize(%s): err = %v, want %v", tt.channel, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+  "fmt"
+
+  "github.com/google/uuid"
+
+  "github.com/slotter-org/slotter-backend/internal/normalization"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// BackfillPhoneNumbers rewrites the phone numbers stored before numbers were
+// normalized on write into E.164, so lookups, opt outs and invitation
+// matching compare like with like. Numbers that do not parse, or that would
+// collide with a user already holding the normalized form, are left as they
+// are and logged. It is safe to run on every start.
+func (s *PostgresService) BackfillPhoneNumbers() error {
+  s.log.Info("Backfilling E.164 phone numbers now...")
+  if err := s.backfillPhoneColumn("user", &types.User{}, true); err != nil {
+    return err
+  }
+  if err := s.backfillPhoneColumn("invitation", &types.Invitation{}, false); err != nil {
+    return err
+  }
+  s.log.Info("Successfully Backfilled E.164 phone numbers :)")
+  return nil
+}
+
+// backfillPhoneColumn normalizes model's phone_number column, soft deleted
+// rows included. With unique set, numbers whose normalized form another row
+// already holds are skipped rather than duplicated.
+func (s *PostgresService) backfillPhoneColumn(table string, model interface{}, unique bool) error {
+  var rows []struct {
+    ID            uuid.UUID
+    PhoneNumber   string
+  }
+  if err := s.db.Unscoped().Model(model).
+    Select("id", "phone_number").
+    Where("phone_number IS NOT NULL AND phone_number NOT SIMILAR TO ?", `\+[0-9]+`).
+    Find(&rows).Error; err != nil {
+    return fmt.Errorf("failed to load %s phone numbers: %w", table, err)
+  }
+  updated := 0
+  for _, row := range rows {
+    normalized, err := normalization.ParsePhoneNumberPtr(&row.PhoneNumber)
+    if err != nil {
+      s.log.Warn("Leaving unparseable phone number as is", "table", table, "id", row.ID, "error", err)
+      continue
+    }
+    if normalized != nil && *normalized == row.PhoneNumber {
+      continue
+    }
+    if unique && normalized != nil {
+      var taken int64
+      if err := s.db.Unscoped().Model(model).Where("phone_number = ? AND id <> ?", *normalized, row.ID).Count(&taken).Error; err != nil {
+        return fmt.Errorf("failed to check %s phone number: %w", table, err)
+      }
+      if taken > 0 {
+        s.log.Warn("Leaving phone number already held by another row in E.164 form", "table", table, "id", row.ID)
+        continue
+      }
+    }
+    if err := s.db.Unscoped().Model(model).Where("id = ?", row.ID).Update("phone_number", normalized).Error; err != nil {
+      return fmt.Errorf("failed to update %s phone number: %w", table, err)
+    }
+    updated++
+  }
+  s.log.Info("Backfilled phone numbers", "table", table, "candidates", len(rows), "updated", updated)
+  return nil
+}
@@ -78,6 +78,30 @@ func (s *PostgresService) AutoMigrateAll() error {
     &types.Invitation{},
     &types.ChatSession{},
     &types.ChatMessage{},
+    &types.OutboxMessage{},
+    &types.SmsOptOut{},
+    &types.WarehouseLocation{},
+    &types.Item{},
+    &types.ItemUOM{},
+    &types.InventoryLedgerEntry{},
+    &types.InventoryOnHand{},
+    &types.OrderLine{},
+    &types.VelocityRun{},
+    &types.SKUVelocity{},
+    &types.SKUAffinity{},
+    &types.SlottingJob{},
+    &types.SlottingAssignment{},
+    &types.SlottingMove{},
+    &types.SlottingScenario{},
+    &types.SlottingPlan{},
+    &types.MoveTask{},
+    &types.WarehouseNavigation{},
+    &types.FloorMapRender{},
+    &types.ChatSessionShare{},
+    &types.ChatSessionPin{},
+    &types.ChatRetentionPolicy{},
+    &types.WebhookEndpoint{},
+    &types.AuditLogEntry{},
   )
   if err != nil {
     s.log.Error("AutoMigrateAll failed for Base Tables :(", "error", err)
@@ -266,8 +290,707 @@ func (s *PostgresService) AutoMigrateAll() error {
   `).Error; err != nil {
       return fmt.Errorf("failed to add fk_warehouse_company_id: %w", err)
   }
+  // -- OutboxMessage.wms_id => wms.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "outbox_message"
+    ADD CONSTRAINT "fk_outbox_message_wms_id"
+    FOREIGN KEY ("wms_id")
+    REFERENCES "wms"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_outbox_message_wms_id: %w", err)
+  }
+  // -- OutboxMessage.company_id => company.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "outbox_message"
+    ADD CONSTRAINT "fk_outbox_message_company_id"
+    FOREIGN KEY ("company_id")
+    REFERENCES "company"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_outbox_message_company_id: %w", err)
+  }
+  // -- WarehouseLocation.warehouse_id => warehouse.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "warehouse_location"
+    ADD CONSTRAINT "fk_warehouse_location_warehouse_id"
+    FOREIGN KEY ("warehouse_id")
+    REFERENCES "warehouse"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_warehouse_location_warehouse_id: %w", err)
+  }
+  // -- WarehouseLocation.company_id => company.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "warehouse_location"
+    ADD CONSTRAINT "fk_warehouse_location_company_id"
+    FOREIGN KEY ("company_id")
+    REFERENCES "company"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_warehouse_location_company_id: %w", err)
+  }
+  // -- WarehouseLocation.parent_id => warehouse_location.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "warehouse_location"
+    ADD CONSTRAINT "fk_warehouse_location_parent_id"
+    FOREIGN KEY ("parent_id")
+    REFERENCES "warehouse_location"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_warehouse_location_parent_id: %w", err)
+  }
+  // -- Item.company_id => company.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "item"
+    ADD CONSTRAINT "fk_item_company_id"
+    FOREIGN KEY ("company_id")
+    REFERENCES "company"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_item_company_id: %w", err)
+  }
+  // -- ItemUOM.item_id => item.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "item_uom"
+    ADD CONSTRAINT "fk_item_uom_item_id"
+    FOREIGN KEY ("item_id")
+    REFERENCES "item"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_item_uom_item_id: %w", err)
+  }
+  // -- ItemUOM.company_id => company.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "item_uom"
+    ADD CONSTRAINT "fk_item_uom_company_id"
+    FOREIGN KEY ("company_id")
+    REFERENCES "company"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_item_uom_company_id: %w", err)
+  }
+  // -- InventoryLedgerEntry.warehouse_id => warehouse.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "inventory_ledger"
+    ADD CONSTRAINT "fk_inventory_ledger_warehouse_id"
+    FOREIGN KEY ("warehouse_id")
+    REFERENCES "warehouse"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_inventory_ledger_warehouse_id: %w", err)
+  }
+  // -- InventoryLedgerEntry.company_id => company.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "inventory_ledger"
+    ADD CONSTRAINT "fk_inventory_ledger_company_id"
+    FOREIGN KEY ("company_id")
+    REFERENCES "company"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_inventory_ledger_company_id: %w", err)
+  }
+  // -- InventoryLedgerEntry.location_id => warehouse_location.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "inventory_ledger"
+    ADD CONSTRAINT "fk_inventory_ledger_location_id"
+    FOREIGN KEY ("location_id")
+    REFERENCES "warehouse_location"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_inventory_ledger_location_id: %w", err)
+  }
+  // -- InventoryLedgerEntry.item_id => item.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "inventory_ledger"
+    ADD CONSTRAINT "fk_inventory_ledger_item_id"
+    FOREIGN KEY ("item_id")
+    REFERENCES "item"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_inventory_ledger_item_id: %w", err)
+  }
+  // -- InventoryOnHand.warehouse_id => warehouse.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "inventory_on_hand"
+    ADD CONSTRAINT "fk_inventory_on_hand_warehouse_id"
+    FOREIGN KEY ("warehouse_id")
+    REFERENCES "warehouse"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_inventory_on_hand_warehouse_id: %w", err)
+  }
+  // -- InventoryOnHand.company_id => company.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "inventory_on_hand"
+    ADD CONSTRAINT "fk_inventory_on_hand_company_id"
+    FOREIGN KEY ("company_id")
+    REFERENCES "company"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_inventory_on_hand_company_id: %w", err)
+  }
+  // -- InventoryOnHand.location_id => warehouse_location.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "inventory_on_hand"
+    ADD CONSTRAINT "fk_inventory_on_hand_location_id"
+    FOREIGN KEY ("location_id")
+    REFERENCES "warehouse_location"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_inventory_on_hand_location_id: %w", err)
+  }
+  // -- InventoryOnHand.item_id => item.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "inventory_on_hand"
+    ADD CONSTRAINT "fk_inventory_on_hand_item_id"
+    FOREIGN KEY ("item_id")
+    REFERENCES "item"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_inventory_on_hand_item_id: %w", err)
+  }
+  // -- OrderLine.warehouse_id => warehouse.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "order_line"
+    ADD CONSTRAINT "fk_order_line_warehouse_id"
+    FOREIGN KEY ("warehouse_id")
+    REFERENCES "warehouse"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_order_line_warehouse_id: %w", err)
+  }
+  // -- OrderLine.company_id => company.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "order_line"
+    ADD CONSTRAINT "fk_order_line_company_id"
+    FOREIGN KEY ("company_id")
+    REFERENCES "company"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_order_line_company_id: %w", err)
+  }
+  // -- OrderLine.item_id => item.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "order_line"
+    ADD CONSTRAINT "fk_order_line_item_id"
+    FOREIGN KEY ("item_id")
+    REFERENCES "item"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_order_line_item_id: %w", err)
+  }
+  // -- VelocityRun.warehouse_id => warehouse.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "velocity_run"
+    ADD CONSTRAINT "fk_velocity_run_warehouse_id"
+    FOREIGN KEY ("warehouse_id")
+    REFERENCES "warehouse"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_velocity_run_warehouse_id: %w", err)
+  }
+  // -- VelocityRun.company_id => company.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "velocity_run"
+    ADD CONSTRAINT "fk_velocity_run_company_id"
+    FOREIGN KEY ("company_id")
+    REFERENCES "company"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_velocity_run_company_id: %w", err)
+  }
+  // -- SKUVelocity.run_id => velocity_run.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "sku_velocity"
+    ADD CONSTRAINT "fk_sku_velocity_run_id"
+    FOREIGN KEY ("run_id")
+    REFERENCES "velocity_run"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_sku_velocity_run_id: %w", err)
+  }
+  // -- SKUVelocity.item_id => item.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "sku_velocity"
+    ADD CONSTRAINT "fk_sku_velocity_item_id"
+    FOREIGN KEY ("item_id")
+    REFERENCES "item"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_sku_velocity_item_id: %w", err)
+  }
+  // -- SKUAffinity.run_id => velocity_run.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "sku_affinity"
+    ADD CONSTRAINT "fk_sku_affinity_run_id"
+    FOREIGN KEY ("run_id")
+    REFERENCES "velocity_run"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_sku_affinity_run_id: %w", err)
+  }
+  // -- SKUAffinity.item_id => item.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "sku_affinity"
+    ADD CONSTRAINT "fk_sku_affinity_item_id"
+    FOREIGN KEY ("item_id")
+    REFERENCES "item"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_sku_affinity_item_id: %w", err)
+  }
+  // -- SKUAffinity.other_item_id => item.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "sku_affinity"
+    ADD CONSTRAINT "fk_sku_affinity_other_item_id"
+    FOREIGN KEY ("other_item_id")
+    REFERENCES "item"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_sku_affinity_other_item_id: %w", err)
+  }
+  // -- SlottingJob.warehouse_id => warehouse.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "slotting_job"
+    ADD CONSTRAINT "fk_slotting_job_warehouse_id"
+    FOREIGN KEY ("warehouse_id")
+    REFERENCES "warehouse"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_slotting_job_warehouse_id: %w", err)
+  }
+  // -- SlottingJob.company_id => company.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "slotting_job"
+    ADD CONSTRAINT "fk_slotting_job_company_id"
+    FOREIGN KEY ("company_id")
+    REFERENCES "company"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_slotting_job_company_id: %w", err)
+  }
+  // -- SlottingAssignment.job_id => slotting_job.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "slotting_assignment"
+    ADD CONSTRAINT "fk_slotting_assignment_job_id"
+    FOREIGN KEY ("job_id")
+    REFERENCES "slotting_job"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_slotting_assignment_job_id: %w", err)
+  }
+  // -- SlottingAssignment.item_id => item.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "slotting_assignment"
+    ADD CONSTRAINT "fk_slotting_assignment_item_id"
+    FOREIGN KEY ("item_id")
+    REFERENCES "item"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_slotting_assignment_item_id: %w", err)
+  }
+  // -- SlottingAssignment.location_id => warehouse_location.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "slotting_assignment"
+    ADD CONSTRAINT "fk_slotting_assignment_location_id"
+    FOREIGN KEY ("location_id")
+    REFERENCES "warehouse_location"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_slotting_assignment_location_id: %w", err)
+  }
+  // -- SlottingMove.job_id => slotting_job.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "slotting_move"
+    ADD CONSTRAINT "fk_slotting_move_job_id"
+    FOREIGN KEY ("job_id")
+    REFERENCES "slotting_job"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_slotting_move_job_id: %w", err)
+  }
+  // -- SlottingMove.item_id => item.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "slotting_move"
+    ADD CONSTRAINT "fk_slotting_move_item_id"
+    FOREIGN KEY ("item_id")
+    REFERENCES "item"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_slotting_move_item_id: %w", err)
+  }
+  // -- SlottingMove.from_location_id => warehouse_location.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "slotting_move"
+    ADD CONSTRAINT "fk_slotting_move_from_location_id"
+    FOREIGN KEY ("from_location_id")
+    REFERENCES "warehouse_location"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_slotting_move_from_location_id: %w", err)
+  }
+  // -- SlottingMove.to_location_id => warehouse_location.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "slotting_move"
+    ADD CONSTRAINT "fk_slotting_move_to_location_id"
+    FOREIGN KEY ("to_location_id")
+    REFERENCES "warehouse_location"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_slotting_move_to_location_id: %w", err)
+  }
+  // -- SlottingJob.scenario_id => slotting_scenario.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "slotting_job"
+    ADD CONSTRAINT "fk_slotting_job_scenario_id"
+    FOREIGN KEY ("scenario_id")
+    REFERENCES "slotting_scenario"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_slotting_job_scenario_id: %w", err)
+  }
+  // -- SlottingScenario.warehouse_id => warehouse.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "slotting_scenario"
+    ADD CONSTRAINT "fk_slotting_scenario_warehouse_id"
+    FOREIGN KEY ("warehouse_id")
+    REFERENCES "warehouse"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_slotting_scenario_warehouse_id: %w", err)
+  }
+  // -- SlottingScenario.company_id => company.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "slotting_scenario"
+    ADD CONSTRAINT "fk_slotting_scenario_company_id"
+    FOREIGN KEY ("company_id")
+    REFERENCES "company"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_slotting_scenario_company_id: %w", err)
+  }
+  // -- SlottingScenario.job_id => slotting_job.id (ON DELETE SET NULL)
+  if err := s.db.Exec(`
+    ALTER TABLE "slotting_scenario"
+    ADD CONSTRAINT "fk_slotting_scenario_job_id"
+    FOREIGN KEY ("job_id")
+    REFERENCES "slotting_job"("id")
+    ON DELETE SET NULL
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_slotting_scenario_job_id: %w", err)
+  }
+  // -- SlottingPlan.warehouse_id => warehouse.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "slotting_plan"
+    ADD CONSTRAINT "fk_slotting_plan_warehouse_id"
+    FOREIGN KEY ("warehouse_id")
+    REFERENCES "warehouse"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_slotting_plan_warehouse_id: %w", err)
+  }
+  // -- SlottingPlan.company_id => company.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "slotting_plan"
+    ADD CONSTRAINT "fk_slotting_plan_company_id"
+    FOREIGN KEY ("company_id")
+    REFERENCES "company"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_slotting_plan_company_id: %w", err)
+  }
+  // -- SlottingPlan.scenario_id => slotting_scenario.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "slotting_plan"
+    ADD CONSTRAINT "fk_slotting_plan_scenario_id"
+    FOREIGN KEY ("scenario_id")
+    REFERENCES "slotting_scenario"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_slotting_plan_scenario_id: %w", err)
+  }
+  // -- SlottingPlan.job_id => slotting_job.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "slotting_plan"
+    ADD CONSTRAINT "fk_slotting_plan_job_id"
+    FOREIGN KEY ("job_id")
+    REFERENCES "slotting_job"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_slotting_plan_job_id: %w", err)
+  }
+  // -- MoveTask.plan_id => slotting_plan.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "move_task"
+    ADD CONSTRAINT "fk_move_task_plan_id"
+    FOREIGN KEY ("plan_id")
+    REFERENCES "slotting_plan"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_move_task_plan_id: %w", err)
+  }
+  // -- MoveTask.warehouse_id => warehouse.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "move_task"
+    ADD CONSTRAINT "fk_move_task_warehouse_id"
+    FOREIGN KEY ("warehouse_id")
+    REFERENCES "warehouse"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_move_task_warehouse_id: %w", err)
+  }
+  // -- MoveTask.company_id => company.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "move_task"
+    ADD CONSTRAINT "fk_move_task_company_id"
+    FOREIGN KEY ("company_id")
+    REFERENCES "company"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_move_task_company_id: %w", err)
+  }
+  // -- MoveTask.move_id => slotting_move.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "move_task"
+    ADD CONSTRAINT "fk_move_task_move_id"
+    FOREIGN KEY ("move_id")
+    REFERENCES "slotting_move"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_move_task_move_id: %w", err)
+  }
+  // -- MoveTask.item_id => item.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "move_task"
+    ADD CONSTRAINT "fk_move_task_item_id"
+    FOREIGN KEY ("item_id")
+    REFERENCES "item"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_move_task_item_id: %w", err)
+  }
+  // -- MoveTask.from_location_id => warehouse_location.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "move_task"
+    ADD CONSTRAINT "fk_move_task_from_location_id"
+    FOREIGN KEY ("from_location_id")
+    REFERENCES "warehouse_location"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_move_task_from_location_id: %w", err)
+  }
+  // -- MoveTask.to_location_id => warehouse_location.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "move_task"
+    ADD CONSTRAINT "fk_move_task_to_location_id"
+    FOREIGN KEY ("to_location_id")
+    REFERENCES "warehouse_location"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_move_task_to_location_id: %w", err)
+  }
+  // -- MoveTask.assigned_to_id => user.id (ON DELETE SET NULL)
+  if err := s.db.Exec(`
+    ALTER TABLE "move_task"
+    ADD CONSTRAINT "fk_move_task_assigned_to_id"
+    FOREIGN KEY ("assigned_to_id")
+    REFERENCES "user"("id")
+    ON DELETE SET NULL
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_move_task_assigned_to_id: %w", err)
+  }
+  // -- WarehouseNavigation.warehouse_id => warehouse.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "warehouse_navigation"
+    ADD CONSTRAINT "fk_warehouse_navigation_warehouse_id"
+    FOREIGN KEY ("warehouse_id")
+    REFERENCES "warehouse"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_warehouse_navigation_warehouse_id: %w", err)
+  }
+  // -- WarehouseNavigation.company_id => company.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "warehouse_navigation"
+    ADD CONSTRAINT "fk_warehouse_navigation_company_id"
+    FOREIGN KEY ("company_id")
+    REFERENCES "company"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_warehouse_navigation_company_id: %w", err)
+  }
+  // -- FloorMapRender.warehouse_id => warehouse.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "floor_map_render"
+    ADD CONSTRAINT "fk_floor_map_render_warehouse_id"
+    FOREIGN KEY ("warehouse_id")
+    REFERENCES "warehouse"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_floor_map_render_warehouse_id: %w", err)
+  }
+  // -- FloorMapRender.company_id => company.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "floor_map_render"
+    ADD CONSTRAINT "fk_floor_map_render_company_id"
+    FOREIGN KEY ("company_id")
+    REFERENCES "company"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_floor_map_render_company_id: %w", err)
+  }
+  // -- FloorMapRender.scenario_id => slotting_scenario.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "floor_map_render"
+    ADD CONSTRAINT "fk_floor_map_render_scenario_id"
+    FOREIGN KEY ("scenario_id")
+    REFERENCES "slotting_scenario"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_floor_map_render_scenario_id: %w", err)
+  }
+  // -- ChatSessionShare.session_id => chat_session.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "chat_session_share"
+    ADD CONSTRAINT "fk_chat_session_share_session_id"
+    FOREIGN KEY ("session_id")
+    REFERENCES "chat_session"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_chat_session_share_session_id: %w", err)
+  }
+  // -- ChatSessionShare.user_id => user.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "chat_session_share"
+    ADD CONSTRAINT "fk_chat_session_share_user_id"
+    FOREIGN KEY ("user_id")
+    REFERENCES "user"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_chat_session_share_user_id: %w", err)
+  }
+  // -- ChatSessionPin.session_id => chat_session.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "chat_session_pin"
+    ADD CONSTRAINT "fk_chat_session_pin_session_id"
+    FOREIGN KEY ("session_id")
+    REFERENCES "chat_session"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_chat_session_pin_session_id: %w", err)
+  }
+  // -- ChatSessionPin.user_id => user.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "chat_session_pin"
+    ADD CONSTRAINT "fk_chat_session_pin_user_id"
+    FOREIGN KEY ("user_id")
+    REFERENCES "user"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_chat_session_pin_user_id: %w", err)
+  }
+  // -- ChatRetentionPolicy.company_id => company.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "chat_retention_policy"
+    ADD CONSTRAINT "fk_chat_retention_policy_company_id"
+    FOREIGN KEY ("company_id")
+    REFERENCES "company"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_chat_retention_policy_company_id: %w", err)
+  }
+  // -- ChatRetentionPolicy.wms_id => wms.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "chat_retention_policy"
+    ADD CONSTRAINT "fk_chat_retention_policy_wms_id"
+    FOREIGN KEY ("wms_id")
+    REFERENCES "wms"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_chat_retention_policy_wms_id: %w", err)
+  }
+  // -- ChatRetentionPolicy.updated_by_id => user.id (ON DELETE SET NULL)
+  if err := s.db.Exec(`
+    ALTER TABLE "chat_retention_policy"
+    ADD CONSTRAINT "fk_chat_retention_policy_updated_by_id"
+    FOREIGN KEY ("updated_by_id")
+    REFERENCES "user"("id")
+    ON DELETE SET NULL
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_chat_retention_policy_updated_by_id: %w", err)
+  }
+  // -- WebhookEndpoint.wms_id => wms.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "webhook_endpoint"
+    ADD CONSTRAINT "fk_webhook_endpoint_wms_id"
+    FOREIGN KEY ("wms_id")
+    REFERENCES "wms"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_webhook_endpoint_wms_id: %w", err)
+  }
+  // -- WebhookEndpoint.company_id => company.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "webhook_endpoint"
+    ADD CONSTRAINT "fk_webhook_endpoint_company_id"
+    FOREIGN KEY ("company_id")
+    REFERENCES "company"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_webhook_endpoint_company_id: %w", err)
+  }
+  // -- WebhookEndpoint.created_by_id => user.id (ON DELETE SET NULL)
+  if err := s.db.Exec(`
+    ALTER TABLE "webhook_endpoint"
+    ADD CONSTRAINT "fk_webhook_endpoint_created_by_id"
+    FOREIGN KEY ("created_by_id")
+    REFERENCES "user"("id")
+    ON DELETE SET NULL
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_webhook_endpoint_created_by_id: %w", err)
+  }
+  // -- AuditLogEntry.wms_id => wms.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "audit_log_entry"
+    ADD CONSTRAINT "fk_audit_log_entry_wms_id"
+    FOREIGN KEY ("wms_id")
+    REFERENCES "wms"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_audit_log_entry_wms_id: %w", err)
+  }
+  // -- AuditLogEntry.company_id => company.id (ON DELETE CASCADE)
+  if err := s.db.Exec(`
+    ALTER TABLE "audit_log_entry"
+    ADD CONSTRAINT "fk_audit_log_entry_company_id"
+    FOREIGN KEY ("company_id")
+    REFERENCES "company"("id")
+    ON DELETE CASCADE
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_audit_log_entry_company_id: %w", err)
+  }
+  // -- AuditLogEntry.actor_id => user.id (ON DELETE SET NULL)
+  if err := s.db.Exec(`
+    ALTER TABLE "audit_log_entry"
+    ADD CONSTRAINT "fk_audit_log_entry_actor_id"
+    FOREIGN KEY ("actor_id")
+    REFERENCES "user"("id")
+    ON DELETE SET NULL
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add fk_audit_log_entry_actor_id: %w", err)
+  }
   s.log.Info("Successfully Added Foreign Key Relationships to Base Tables :)")
 
+  // -- ChatMessage.content full-text search
+  if err := s.db.Exec(`
+    CREATE INDEX IF NOT EXISTS "idx_chat_message_content_fts"
+    ON "chat_message"
+    USING GIN (to_tsvector('simple', "content"))
+  `).Error; err != nil {
+      return fmt.Errorf("failed to add idx_chat_message_content_fts: %w", err)
+  }
+
   return nil
 }
 
@@ -0,0 +1,48 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/slotter-org/slotter-backend/internal/logger"
+)
+
+// Handler is a delivery adapter. Handle should not fail the others: a
+// returned error is logged and the bus moves on.
+type Handler interface {
+	Name() string
+	Handle(ctx context.Context, ev Event) error
+}
+
+// Bus hands published events to every registered handler in order.
+type Bus struct {
+	log      *logger.Logger
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewBus(log *logger.Logger) *Bus {
+	return &Bus{log: log.With("component", "EventBus")}
+}
+
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+// Publish delivers events now. Code running inside a request records them
+// with RecordTx instead, so nothing goes out if the request fails.
+func (b *Bus) Publish(ctx context.Context, evs ...Event) {
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+	for _, ev := range evs {
+		ev = ev.withActor(ctx)
+		for _, h := range handlers {
+			if err := h.Handle(ctx, ev); err != nil {
+				b.log.Warn("Event handler failed", "handler", h.Name(), "type", ev.Type, "eventID", ev.ID, "error", err)
+			}
+		}
+	}
+}
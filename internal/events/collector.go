@@ -0,0 +1,83 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type key struct{}
+
+var collectorKey key
+
+// Journal keeps the events webhooks and the audit log must get. Write runs
+// in the transaction of the change the events describe, so they are kept
+// exactly when that change commits, and delivered from there. Notify is
+// called once the request that wrote them has succeeded.
+type Journal interface {
+	Write(ctx context.Context, tx *gorm.DB, evs ...Event) error
+	Notify()
+}
+
+// Collector holds the events recorded while a request runs.
+type Collector struct {
+	mu      sync.Mutex
+	journal Journal
+	events  []Event
+}
+
+// WithCollector starts collecting the events of a request. Without a
+// journal, RecordTx records like Record.
+func WithCollector(ctx context.Context, journal Journal) context.Context {
+	return context.WithValue(ctx, collectorKey, &Collector{journal: journal})
+}
+
+func GetCollector(ctx context.Context) *Collector {
+	c, _ := ctx.Value(collectorKey).(*Collector)
+	return c
+}
+
+// Record keeps ev for publishing after the request succeeds. It reports
+// false when ctx has no collector, e.g. outside a request.
+func Record(ctx context.Context, ev Event) bool {
+	c := GetCollector(ctx)
+	if c == nil {
+		return false
+	}
+	ev = ev.withActor(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, ev)
+	return true
+}
+
+// RecordTx records ev from inside the transaction tx that made the change.
+// Unless ev is transient it is written to the request's journal in tx
+// first, and the copy kept for publishing is then live only, so the bus
+// does not hand it to webhooks and the audit log a second time. A failed
+// write should fail tx.
+func RecordTx(ctx context.Context, tx *gorm.DB, ev Event) error {
+	c := GetCollector(ctx)
+	if c == nil {
+		return nil
+	}
+	ev = ev.withActor(ctx)
+	if c.journal != nil && !ev.Transient() {
+		if err := c.journal.Write(ctx, tx, ev); err != nil {
+			return err
+		}
+		ev = ev.Live()
+	}
+	Record(ctx, ev)
+	return nil
+}
+
+// Drain returns the recorded events and forgets them.
+func (c *Collector) Drain() []Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	evs := c.events
+	c.events = nil
+	return evs
+}
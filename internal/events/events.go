@@ -0,0 +1,200 @@
+// Package events carries domain events from the services that cause them to
+// every delivery adapter (SSE, websockets, webhooks, audit log) in one
+// envelope. Services record events on the request context inside their
+// transaction: the journal keeps them for webhooks and the audit log in
+// that same transaction, and live clients get them once the request has
+// succeeded, i.e. after the transaction committed.
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/slotter-org/slotter-backend/internal/channels"
+	"github.com/slotter-org/slotter-backend/internal/requestdata"
+)
+
+// Type names a domain event. The names match the SSE event names clients
+// already listen for.
+type Type string
+
+const (
+	UserJoined                 Type = "UserJoined"
+	UserLeft                   Type = "UserLeft"
+	UserAvatarUpdated          Type = "UserAvatarUpdated"
+	AvatarUpdated              Type = "AvatarUpdated"
+	WarehouseCreated           Type = "WarehouseCreated"
+	WarehouseNavigationUpdated Type = "WarehouseNavigationUpdated"
+	LocationsCreated           Type = "LocationsCreated"
+	LocationUpdated            Type = "LocationUpdated"
+	LocationDeleted            Type = "LocationDeleted"
+	LayoutImported             Type = "LayoutImported"
+	ItemCreated                Type = "ItemCreated"
+	ItemUpdated                Type = "ItemUpdated"
+	ItemDeleted                Type = "ItemDeleted"
+	ItemsImported              Type = "ItemsImported"
+	InventoryChanged           Type = "InventoryChanged"
+	OrderLinesImported         Type = "OrderLinesImported"
+	VelocityRunFinished        Type = "VelocityRunFinished"
+	RoleCreated                Type = "RoleCreated"
+	RoleUpdated                Type = "RoleUpdated"
+	RoleDeleted                Type = "RoleDeleted"
+	InvitationCreated          Type = "InvitationCreated"
+	InvitationAccepted         Type = "InvitationAccepted"
+	InvitationCanceled         Type = "InvitationCanceled"
+	InvitationResent           Type = "InvitationResent"
+	InvitationDeleted          Type = "InvitationDeleted"
+	InvitationUpdated          Type = "InvitationUpdated"
+	SlottingJobProgress        Type = "SlottingJobProgress"
+	SlottingJobCompleted       Type = "SlottingJobCompleted"
+	SlottingJobFailed          Type = "SlottingJobFailed"
+	SlottingJobCanceled        Type = "SlottingJobCanceled"
+	SlottingScenarioCreated    Type = "SlottingScenarioCreated"
+	SlottingScenarioUpdated    Type = "SlottingScenarioUpdated"
+	SlottingScenarioDeleted    Type = "SlottingScenarioDeleted"
+	SlottingPlanApproved       Type = "SlottingPlanApproved"
+	MoveTasksGenerated         Type = "MoveTasksGenerated"
+	MoveTaskUpdated            Type = "MoveTaskUpdated"
+)
+
+// Clients have listened for these names since before the event bus, but
+// nothing emits them yet, so Types leaves them out.
+const (
+	UserNameChanged   Type = "UserNameChanged"
+	WarehouseDeleted  Type = "WarehouseDeleted"
+	CompanyCreated    Type = "CompanyCreated"
+	CompanyDeleted    Type = "CompanyDeleted"
+	InvitationExpired Type = "InvitationExpired"
+)
+
+// versions holds the schema version of each event's data. Bump a type's
+// version whenever the shape of its data changes, so webhook consumers
+// can tell payloads apart. Types not listed are at version 1.
+var versions = map[Type]int{}
+
+func Version(t Type) int {
+	if v, ok := versions[t]; ok {
+		return v
+	}
+	return 1
+}
+
+// Transient reports whether t only matters to clients watching live, like
+// job progress ticks. Webhooks and the audit log skip these.
+func Transient(t Type) bool {
+	return t == SlottingJobProgress
+}
+
+// Types lists every event type, e.g. to validate webhook subscriptions.
+func Types() []Type {
+	return []Type{
+		UserJoined, UserLeft, UserAvatarUpdated, AvatarUpdated,
+		WarehouseCreated, WarehouseNavigationUpdated, LocationsCreated, LocationUpdated, LocationDeleted, LayoutImported,
+		ItemCreated, ItemUpdated, ItemDeleted, ItemsImported, InventoryChanged, OrderLinesImported, VelocityRunFinished,
+		RoleCreated, RoleUpdated, RoleDeleted,
+		InvitationCreated, InvitationAccepted, InvitationCanceled, InvitationResent, InvitationDeleted, InvitationUpdated,
+		SlottingJobProgress, SlottingJobCompleted, SlottingJobFailed, SlottingJobCanceled,
+		SlottingScenarioCreated, SlottingScenarioUpdated, SlottingScenarioDeleted, SlottingPlanApproved,
+		MoveTasksGenerated, MoveTaskUpdated,
+	}
+}
+
+// Event is the envelope every adapter delivers. WmsID and CompanyID say
+// which tenant the event belongs to and route it to that tenant's
+// channels; Channels adds narrower ones such as a slotting job's.
+type Event struct {
+	ID         uuid.UUID  `json:"id"`
+	Type       Type       `json:"type"`
+	Version    int        `json:"version"`
+	OccurredAt time.Time  `json:"occurredAt"`
+	ActorID    *uuid.UUID `json:"actorID,omitempty"`
+	WmsID      *uuid.UUID `json:"wmsID,omitempty"`
+	CompanyID  *uuid.UUID `json:"companyID,omitempty"`
+	Channels   []string   `json:"-"`
+	Data       any        `json:"data,omitempty"`
+
+	live bool
+}
+
+// New starts an event of type t. Route it with ForWms, ForCompany or
+// ForTenant before recording it.
+func New(t Type, data any) Event {
+	return Event{
+		ID:         uuid.New(),
+		Type:       t,
+		Version:    Version(t),
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+}
+
+func (e Event) ForWms(wmsID uuid.UUID) Event {
+	if wmsID != uuid.Nil {
+		e.WmsID = &wmsID
+	}
+	return e
+}
+
+func (e Event) ForCompany(companyID uuid.UUID) Event {
+	if companyID != uuid.Nil {
+		e.CompanyID = &companyID
+	}
+	return e
+}
+
+// ForTenant routes to whichever of the wms and company is set.
+func (e Event) ForTenant(wmsID, companyID *uuid.UUID) Event {
+	if wmsID != nil {
+		e = e.ForWms(*wmsID)
+	}
+	if companyID != nil {
+		e = e.ForCompany(*companyID)
+	}
+	return e
+}
+
+// Live marks an event that only matters to clients watching right now,
+// like presence changes, whatever its type.
+func (e Event) Live() Event {
+	e.live = true
+	return e
+}
+
+// Transient reports whether webhooks and the audit log should skip e.
+func (e Event) Transient() bool {
+	return e.live || Transient(e.Type)
+}
+
+// On adds a channel beyond the tenant's own.
+func (e Event) On(channel string) Event {
+	if channel != "" {
+		e.Channels = append(e.Channels, channel)
+	}
+	return e
+}
+
+// Routes returns every channel the event is delivered on.
+func (e Event) Routes() []string {
+	var routes []string
+	if e.WmsID != nil {
+		routes = append(routes, channels.Wms(*e.WmsID))
+	}
+	if e.CompanyID != nil {
+		routes = append(routes, channels.Company(*e.CompanyID))
+	}
+	return append(routes, e.Channels...)
+}
+
+// withActor fills ActorID from the requester in ctx, if any.
+func (e Event) withActor(ctx context.Context) Event {
+	if e.ActorID != nil {
+		return e
+	}
+	if rd := requestdata.GetRequestData(ctx); rd != nil && rd.UserID != uuid.Nil {
+		actor := rd.UserID
+		e.ActorID = &actor
+	}
+	return e
+}
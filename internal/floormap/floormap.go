@@ -0,0 +1,207 @@
+// Package floormap draws a warehouse floor as SVG or PNG: zones, aisles with
+// their bays, cross aisles, the depot and docks, and optionally a heatmap
+// over the bays.
+//
+// Coordinates are in metres, as in package routing: X runs across the
+// aisles and Y along them from the front (Y = 0). The front is drawn at the
+// bottom of the image.
+package floormap
+
+import (
+	"fmt"
+	"math"
+)
+
+// Rect is an axis-aligned rectangle on the floor.
+type Rect struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+}
+
+// Zone is a labelled area drawn behind the aisles.
+type Zone struct {
+	Name string `json:"name"`
+	Rect Rect   `json:"rect"`
+}
+
+// Aisle is drawn as a walkway from Y0 to Y1 at X. Direction is "", "up" or
+// "down", as in routing.Direction.
+type Aisle struct {
+	Name      string  `json:"name"`
+	X         float64 `json:"x"`
+	Y0        float64 `json:"y0"`
+	Y1        float64 `json:"y1"`
+	Direction string  `json:"direction,omitempty"`
+}
+
+// Cell is a bay or any other block coloured by the heatmap. A nil Value is
+// drawn as having no data.
+type Cell struct {
+	Label string   `json:"label"`
+	Rect  Rect     `json:"rect"`
+	Value *float64 `json:"value,omitempty"`
+}
+
+// Marker is a named point such as a dock.
+type Marker struct {
+	Name string  `json:"name"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+}
+
+// Scale maps cell values onto the colour ramp. Values at or below Min are
+// cold, at or above Max hot.
+type Scale struct {
+	Label string  `json:"label"`
+	Unit  string  `json:"unit,omitempty"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+}
+
+// Map is everything drawn on one floor map. Width and Length bound the
+// floor; anything outside is clipped by the image edge.
+type Map struct {
+	Title       string    `json:"title"`
+	Width       float64   `json:"width"`
+	Length      float64   `json:"length"`
+	Zones       []Zone    `json:"zones"`
+	Aisles      []Aisle   `json:"aisles"`
+	Cells       []Cell    `json:"cells"`
+	CrossAisles []float64 `json:"crossAisles"`
+	Depot       *Marker   `json:"depot,omitempty"`
+	Docks       []Marker  `json:"docks"`
+	Heat        *Scale    `json:"heat,omitempty"`
+}
+
+const (
+	// PixelsPerMetre is the drawing scale unless the floor would not fit
+	// in MaxPixels.
+	PixelsPerMetre = 24.0
+	// MaxPixels caps the longer side of the floor in the image.
+	MaxPixels = 4000.0
+
+	margin       = 24.0
+	headerHeight = 28.0
+	legendHeight = 44.0
+	minLabelPx   = 14.0
+)
+
+// RGB is a colour.
+type RGB struct {
+	R, G, B uint8
+}
+
+// Hex is the colour as #rrggbb.
+func (c RGB) Hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+var (
+	background = RGB{0xff, 0xff, 0xff}
+	zoneFill   = RGB{0xf3, 0xf4, 0xf6}
+	zoneStroke = RGB{0x9c, 0xa3, 0xaf}
+	walkway    = RGB{0xe5, 0xe7, 0xeb}
+	cellFill   = RGB{0xcb, 0xd5, 0xe1}
+	noData     = RGB{0xf9, 0xfa, 0xfb}
+	cellStroke = RGB{0x47, 0x55, 0x69}
+	ink        = RGB{0x11, 0x18, 0x27}
+	depotFill  = RGB{0x25, 0x63, 0xeb}
+	dockFill   = RGB{0x05, 0x96, 0x69}
+
+	// ramp runs from cold to hot.
+	ramp = []RGB{
+		{0x2c, 0x7b, 0xb6},
+		{0xab, 0xd9, 0xe9},
+		{0xff, 0xff, 0xbf},
+		{0xfd, 0xae, 0x61},
+		{0xd7, 0x19, 0x1c},
+	}
+)
+
+// HeatColor is the ramp colour of t between 0 and 1.
+func HeatColor(t float64) RGB {
+	if math.IsNaN(t) || t < 0 {
+		t = 0
+	}
+	if t > 1 {
+		t = 1
+	}
+	pos := t * float64(len(ramp)-1)
+	i := int(pos)
+	if i >= len(ramp)-1 {
+		return ramp[len(ramp)-1]
+	}
+	f := pos - float64(i)
+	a, b := ramp[i], ramp[i+1]
+	mix := func(x, y uint8) uint8 { return uint8(math.Round(float64(x) + f*(float64(y)-float64(x)))) }
+	return RGB{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B)}
+}
+
+// fill is the colour a cell is drawn in.
+func (m Map) fill(c Cell) RGB {
+	if m.Heat == nil {
+		return cellFill
+	}
+	if c.Value == nil {
+		return noData
+	}
+	span := m.Heat.Max - m.Heat.Min
+	if span <= 0 {
+		return HeatColor(1)
+	}
+	return HeatColor((*c.Value - m.Heat.Min) / span)
+}
+
+// canvas converts floor metres into image pixels.
+type canvas struct {
+	scale  float64
+	width  float64
+	height float64
+	floorH float64
+}
+
+func (m Map) canvas() (canvas, error) {
+	if !(m.Width > 0) || !(m.Length > 0) || math.IsInf(m.Width, 0) || math.IsInf(m.Length, 0) {
+		return canvas{}, fmt.Errorf("floor map needs a positive width and length")
+	}
+	scale := PixelsPerMetre
+	if longest := math.Max(m.Width, m.Length) * scale; longest > MaxPixels {
+		scale = MaxPixels / math.Max(m.Width, m.Length)
+	}
+	c := canvas{scale: scale, floorH: m.Length * scale}
+	c.width = math.Ceil(m.Width*scale + 2*margin)
+	c.height = math.Ceil(headerHeight + c.floorH + 2*margin)
+	if m.Heat != nil {
+		c.height += legendHeight
+	}
+	return c, nil
+}
+
+func (c canvas) x(x float64) float64 {
+	return margin + x*c.scale
+}
+
+func (c canvas) y(y float64) float64 {
+	return headerHeight + margin + c.floorH - y*c.scale
+}
+
+// rect is r in pixels, as left, top, width and height.
+func (c canvas) rect(r Rect) (float64, float64, float64, float64) {
+	return c.x(r.X), c.y(r.Y + r.H), r.W * c.scale, r.H * c.scale
+}
+
+// legendTop is where the heat legend starts.
+func (c canvas) legendTop() float64 {
+	return headerHeight + c.floorH + 2*margin
+}
+
+// legendText is the label under each end of the heat legend.
+func legendText(v float64, unit string) string {
+	s := fmt.Sprintf("%.3g", v)
+	if unit != "" {
+		s += " " + unit
+	}
+	return s
+}
@@ -0,0 +1,108 @@
+package floormap
+
+import (
+	"bytes"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// PNG draws m as a PNG image with gg's built-in font, so no font files are
+// needed.
+func PNG(m Map) ([]byte, error) {
+	c, err := m.canvas()
+	if err != nil {
+		return nil, err
+	}
+	dc := gg.NewContext(int(c.width), int(c.height))
+	setColor(dc, background)
+	dc.Clear()
+	setColor(dc, ink)
+	dc.DrawStringAnchored(m.Title, margin, margin, 0, 0)
+
+	for _, z := range m.Zones {
+		x, y, w, h := c.rect(z.Rect)
+		dc.DrawRoundedRectangle(x, y, w, h, 4)
+		setColor(dc, zoneFill)
+		dc.FillPreserve()
+		setColor(dc, zoneStroke)
+		dc.SetDash(6, 4)
+		dc.SetLineWidth(1)
+		dc.Stroke()
+		dc.SetDash()
+		dc.DrawStringAnchored(z.Name, x+4, y+4, 0, 1)
+	}
+	setColor(dc, walkway)
+	dc.SetLineWidth(math.Max(2, c.scale/2))
+	for _, y := range m.CrossAisles {
+		dc.DrawLine(c.x(0), c.y(y), c.x(m.Width), c.y(y))
+		dc.Stroke()
+	}
+	for _, a := range m.Aisles {
+		dc.DrawLine(c.x(a.X), c.y(a.Y0), c.x(a.X), c.y(a.Y1))
+		dc.Stroke()
+	}
+	dc.SetLineWidth(0.5)
+	for _, cell := range m.Cells {
+		x, y, w, h := c.rect(cell.Rect)
+		dc.DrawRectangle(x, y, w, h)
+		setColor(dc, m.fill(cell))
+		dc.FillPreserve()
+		setColor(dc, cellStroke)
+		dc.Stroke()
+		if h >= minLabelPx && w >= minLabelPx {
+			if tw, _ := dc.MeasureString(cell.Label); tw <= w {
+				setColor(dc, ink)
+				dc.DrawStringAnchored(cell.Label, x+w/2, y+h/2, 0.5, 0.5)
+			}
+		}
+	}
+	setColor(dc, ink)
+	for _, a := range m.Aisles {
+		label := a.Name
+		switch a.Direction {
+		case "up":
+			label += " ^"
+		case "down":
+			label += " v"
+		}
+		dc.DrawStringAnchored(label, c.x(a.X), c.y(math.Max(a.Y0, a.Y1))-6, 0.5, 0)
+	}
+	if m.Depot != nil {
+		dc.DrawCircle(c.x(m.Depot.X), c.y(m.Depot.Y), 6)
+		setColor(dc, depotFill)
+		dc.Fill()
+	}
+	for _, d := range m.Docks {
+		dc.DrawRectangle(c.x(d.X)-6, c.y(d.Y)-6, 12, 12)
+		setColor(dc, dockFill)
+		dc.Fill()
+		setColor(dc, ink)
+		dc.DrawStringAnchored(d.Name, c.x(d.X), c.y(d.Y)+10, 0.5, 1)
+	}
+
+	if m.Heat != nil {
+		top := c.legendTop()
+		width := math.Min(240, c.width-2*margin)
+		setColor(dc, ink)
+		dc.DrawStringAnchored(m.Heat.Label, margin, top, 0, 0)
+		for i := 0; i < int(width); i++ {
+			setColor(dc, HeatColor(float64(i)/width))
+			dc.DrawRectangle(margin+float64(i), top+6, 1, 10)
+			dc.Fill()
+		}
+		setColor(dc, ink)
+		dc.DrawStringAnchored(legendText(m.Heat.Min, m.Heat.Unit), margin, top+30, 0, 0)
+		dc.DrawStringAnchored(legendText(m.Heat.Max, m.Heat.Unit), margin+width, top+30, 1, 0)
+	}
+
+	var buf bytes.Buffer
+	if err := dc.EncodePNG(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func setColor(dc *gg.Context, c RGB) {
+	dc.SetRGB255(int(c.R), int(c.G), int(c.B))
+}
@@ -0,0 +1,88 @@
+package floormap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+)
+
+// SVG draws m as a standalone SVG document.
+func SVG(m Map) ([]byte, error) {
+	c, err := m.canvas()
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g" viewBox="0 0 %g %g" font-family="sans-serif">`, c.width, c.height, c.width, c.height)
+	b.WriteString("\n")
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", background.Hex())
+	fmt.Fprintf(&b, `<text x="%g" y="%g" font-size="16" font-weight="bold" fill="%s">%s</text>`+"\n", margin, margin, ink.Hex(), escape(m.Title))
+
+	for _, z := range m.Zones {
+		x, y, w, h := c.rect(z.Rect)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" rx="4" fill="%s" stroke="%s" stroke-dasharray="6 4"/>`+"\n", x, y, w, h, zoneFill.Hex(), zoneStroke.Hex())
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" font-size="12" fill="%s">%s</text>`+"\n", x+4, y+14, zoneStroke.Hex(), escape(z.Name))
+	}
+	for _, y := range m.CrossAisles {
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="%.1f"/>`+"\n", c.x(0), c.y(y), c.x(m.Width), c.y(y), walkway.Hex(), math.Max(2, c.scale/2))
+	}
+	for _, a := range m.Aisles {
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="%.1f"/>`+"\n", c.x(a.X), c.y(a.Y0), c.x(a.X), c.y(a.Y1), walkway.Hex(), math.Max(2, c.scale/2))
+	}
+	for _, cell := range m.Cells {
+		x, y, w, h := c.rect(cell.Rect)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s" stroke="%s" stroke-width="0.5">`, x, y, w, h, m.fill(cell).Hex(), cellStroke.Hex())
+		title := cell.Label
+		if m.Heat != nil && cell.Value != nil {
+			title += ": " + legendText(*cell.Value, m.Heat.Unit)
+		}
+		fmt.Fprintf(&b, `<title>%s</title></rect>`+"\n", escape(title))
+		if h >= minLabelPx && w >= minLabelPx {
+			fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" font-size="9" text-anchor="middle" dominant-baseline="middle" fill="%s">%s</text>`+"\n", x+w/2, y+h/2, ink.Hex(), escape(cell.Label))
+		}
+	}
+	for _, a := range m.Aisles {
+		top := c.y(math.Max(a.Y0, a.Y1))
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" font-size="11" text-anchor="middle" fill="%s">%s%s</text>`+"\n", c.x(a.X), top-6, ink.Hex(), escape(a.Name), arrow(a.Direction))
+	}
+	if m.Depot != nil {
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="6" fill="%s"><title>%s</title></circle>`+"\n", c.x(m.Depot.X), c.y(m.Depot.Y), depotFill.Hex(), escape(m.Depot.Name))
+	}
+	for _, d := range m.Docks {
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="12" height="12" fill="%s"><title>%s</title></rect>`+"\n", c.x(d.X)-6, c.y(d.Y)-6, dockFill.Hex(), escape(d.Name))
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" font-size="10" text-anchor="middle" fill="%s">%s</text>`+"\n", c.x(d.X), c.y(d.Y)+18, ink.Hex(), escape(d.Name))
+	}
+
+	if m.Heat != nil {
+		top := c.legendTop()
+		width := math.Min(240, c.width-2*margin)
+		b.WriteString(`<defs><linearGradient id="heat">`)
+		for i, col := range ramp {
+			fmt.Fprintf(&b, `<stop offset="%g" stop-color="%s"/>`, float64(i)/float64(len(ramp)-1), col.Hex())
+		}
+		b.WriteString("</linearGradient></defs>\n")
+		fmt.Fprintf(&b, `<text x="%g" y="%.1f" font-size="11" fill="%s">%s</text>`+"\n", margin, top, ink.Hex(), escape(m.Heat.Label))
+		fmt.Fprintf(&b, `<rect x="%g" y="%.1f" width="%.1f" height="10" fill="url(#heat)" stroke="%s" stroke-width="0.5"/>`+"\n", margin, top+6, width, cellStroke.Hex())
+		fmt.Fprintf(&b, `<text x="%g" y="%.1f" font-size="10" fill="%s">%s</text>`+"\n", margin, top+30, ink.Hex(), escape(legendText(m.Heat.Min, m.Heat.Unit)))
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" font-size="10" text-anchor="end" fill="%s">%s</text>`+"\n", margin+width, top+30, ink.Hex(), escape(legendText(m.Heat.Max, m.Heat.Unit)))
+	}
+	b.WriteString("</svg>\n")
+	return b.Bytes(), nil
+}
+
+func arrow(direction string) string {
+	switch direction {
+	case "up":
+		return " ↑"
+	case "down":
+		return " ↓"
+	}
+	return ""
+}
+
+func escape(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
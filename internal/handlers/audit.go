@@ -0,0 +1,48 @@
+package handlers
+
+import (
+  "net/http"
+  "strconv"
+  "strings"
+
+  "github.com/gin-gonic/gin"
+
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/services"
+)
+
+type AuditHandler struct {
+  auditService    services.AuditService
+}
+
+func NewAuditHandler(auditService services.AuditService) *AuditHandler {
+  return &AuditHandler{auditService: auditService}
+}
+
+// ListEntries handles GET /api/audit, newest first. Query parameters: type
+// (comma separated), actorId, since, until and limit.
+func (ah *AuditHandler) ListEntries(c *gin.Context) {
+  var filter repos.AuditLogFilter
+  if raw := strings.TrimSpace(c.Query("type")); raw != "" {
+    for _, t := range strings.Split(raw, ",") {
+      filter.EventTypes = append(filter.EventTypes, strings.TrimSpace(t))
+    }
+  }
+  var ok bool
+  if filter.ActorID, ok = parseUUIDQuery(c, "actorId"); !ok {
+    return
+  }
+  if filter.Since, ok = parseTimeQuery(c, "since"); !ok {
+    return
+  }
+  if filter.Until, ok = parseTimeQuery(c, "until"); !ok {
+    return
+  }
+  filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+  entries, err := ah.auditService.List(c.Request.Context(), nil, filter)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
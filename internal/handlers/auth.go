@@ -9,17 +9,14 @@ import (
 
   "github.com/slotter-org/slotter-backend/internal/types"
   "github.com/slotter-org/slotter-backend/internal/services"
-  "github.com/slotter-org/slotter-backend/internal/sse"
-  "github.com/slotter-org/slotter-backend/internal/ssedata"
 )
 
 type AuthHandler struct {
   authService     services.AuthService
-  sseHub          *sse.SSEHub
 }
 
-func NewAuthHandler(authService services.AuthService, hub *sse.SSEHub) *AuthHandler {
-  return &AuthHandler{authService: authService, sseHub: hub}
+func NewAuthHandler(authService services.AuthService) *AuthHandler {
+  return &AuthHandler{authService: authService}
 }
 
 func (ah *AuthHandler) Register(c *gin.Context) {
@@ -102,13 +99,6 @@ func (ah *AuthHandler) RegisterWithInvitation(c *gin.Context) {
     c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
     return
   }
-  ssd := ssedata.GetSSEData(ctx)
-  if ssd != nil && len(ssd.Messages) > 0 {
-    for _, msg := range ssd.Messages {
-      ah.sseHub.Broadcast(msg)
-    }
-    ssd.Messages = nil
-  }
   c.JSON(http.StatusOK, gin.H{"message": "User successfully registered via invitation"})
 }
 
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+  "errors"
+  "net/http"
+  "strings"
+
+  "github.com/gin-gonic/gin"
+  "github.com/google/uuid"
+
+  "github.com/slotter-org/slotter-backend/internal/services"
+)
+
+// multipartOverhead is allowed on top of the avatar size limit for the
+// multipart boundaries and headers around the file part.
+const multipartOverhead = 64 << 10
+
+type AvatarHandler struct {
+  avatarService   services.AvatarService
+}
+
+func NewAvatarHandler(avatarService services.AvatarService) *AvatarHandler {
+  return &AvatarHandler{avatarService: avatarService}
+}
+
+// UploadAvatar handles PUT /api/{user|company|wms|warehouse|role}/:id/avatar
+// with the image in the multipart "file" field.
+func (ah *AvatarHandler) UploadAvatar(c *gin.Context) {
+  ctx := c.Request.Context()
+  entityType, entityID, ok := avatarRouteParams(c)
+  if !ok {
+    return
+  }
+  c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, ah.avatarService.MaxUploadBytes()+multipartOverhead)
+  file, _, err := c.Request.FormFile("file")
+  if err != nil {
+    var maxErr *http.MaxBytesError
+    if errors.As(err, &maxErr) {
+      c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": services.ErrAvatarTooLarge.Error()})
+      return
+    }
+    c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+    return
+  }
+  defer file.Close()
+
+  result, err := ah.avatarService.UploadAvatar(ctx, nil, entityType, entityID, file)
+  if err != nil {
+    switch {
+    case errors.Is(err, services.ErrAvatarTooLarge):
+      c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+    case errors.Is(err, services.ErrAvatarUnsupportedType):
+      c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+    default:
+      c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    }
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"avatar": result})
+}
+
+// ResetAvatar handles DELETE /api/{user|company|wms|warehouse|role}/:id/avatar
+// and puts the generated avatar back.
+func (ah *AvatarHandler) ResetAvatar(c *gin.Context) {
+  ctx := c.Request.Context()
+  entityType, entityID, ok := avatarRouteParams(c)
+  if !ok {
+    return
+  }
+  result, err := ah.avatarService.ResetAvatar(ctx, nil, entityType, entityID)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"avatar": result})
+}
+
+// GetAvatarSVG handles GET /api/{user|company|wms|warehouse|role}/:id/avatar.svg
+// and renders the generated avatar; ?theme=dark uses the dark palette.
+func (ah *AvatarHandler) GetAvatarSVG(c *gin.Context) {
+  entityType, entityID, ok := avatarRouteParams(c)
+  if !ok {
+    return
+  }
+  theme, err := services.ParseAvatarTheme(c.Query("theme"))
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  svg, err := ah.avatarService.GenerateAvatarSVG(c.Request.Context(), nil, entityType, entityID, theme)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.Header("Cache-Control", "private, max-age=3600")
+  c.Data(http.StatusOK, "image/svg+xml", svg)
+}
+
+type WmsPaletteRequest struct {
+  Colors          []string        `json:"colors"`
+}
+
+// SetWmsPalette handles PUT /api/mywms/avatar-palette. An empty colors list
+// restores the default palette.
+func (ah *AvatarHandler) SetWmsPalette(c *gin.Context) {
+  var req WmsPaletteRequest
+  if err := c.ShouldBindJSON(&req); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+    return
+  }
+  wms, err := ah.avatarService.SetWmsPalette(c.Request.Context(), nil, req.Colors)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"avatarPalette": wms.AvatarPalette})
+}
+
+// avatarRouteParams reads the entity type from the route itself
+// (/api/<entity>/:id/avatar) so one handler serves every entity.
+func avatarRouteParams(c *gin.Context) (services.AvatarEntityType, uuid.UUID, bool) {
+  parts := strings.Split(strings.Trim(c.FullPath(), "/"), "/")
+  if len(parts) < 3 {
+    c.JSON(http.StatusNotFound, gin.H{"error": "unknown avatar route"})
+    return "", uuid.Nil, false
+  }
+  entityType, err := services.ParseAvatarEntityType(parts[len(parts)-3])
+  if err != nil {
+    c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+    return "", uuid.Nil, false
+  }
+  entityID, err := uuid.Parse(c.Param("id"))
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+    return "", uuid.Nil, false
+  }
+  return entityType, entityID, true
+}
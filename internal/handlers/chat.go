@@ -0,0 +1,268 @@
+package handlers
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "strconv"
+  "strings"
+
+  "github.com/gin-gonic/gin"
+
+  "github.com/slotter-org/slotter-backend/internal/services"
+)
+
+type ChatHandler struct {
+  chatService     services.ChatService
+}
+
+func NewChatHandler(chatService services.ChatService) *ChatHandler {
+  return &ChatHandler{chatService: chatService}
+}
+
+// CreateSession handles POST /api/chat/sessions with an optional title.
+func (ch *ChatHandler) CreateSession(c *gin.Context) {
+  var req struct {
+    Title   string  `json:"title"`
+  }
+  if c.Request.ContentLength != 0 {
+    if err := c.ShouldBindJSON(&req); err != nil {
+      c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+      return
+    }
+  }
+  session, err := ch.chatService.CreateSession(c.Request.Context(), nil, req.Title)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusCreated, session)
+}
+
+// ListSessions handles GET /api/chat/sessions. Query parameters: scope
+// (mine|shared|all), pinned=true, title, limit and offset. Pinned sessions
+// come first, then the most recently active.
+func (ch *ChatHandler) ListSessions(c *gin.Context) {
+  query := services.ChatSessionQuery{
+    Scope:      c.Query("scope"),
+    PinnedOnly: c.Query("pinned") == "true",
+    Title:      c.Query("title"),
+  }
+  query.Limit, _ = strconv.Atoi(c.Query("limit"))
+  query.Offset, _ = strconv.Atoi(c.Query("offset"))
+  page, err := ch.chatService.ListSessions(c.Request.Context(), nil, query)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, page)
+}
+
+// GetSession handles GET /api/chat/sessions/:id.
+func (ch *ChatHandler) GetSession(c *gin.Context) {
+  sessionID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  detail, err := ch.chatService.GetSession(c.Request.Context(), nil, sessionID)
+  if err != nil {
+    c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, detail)
+}
+
+// UpdateSession handles PATCH /api/chat/sessions/:id with any of title,
+// visibility (private|company|users) and sharedWith (user IDs).
+func (ch *ChatHandler) UpdateSession(c *gin.Context) {
+  sessionID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  var req services.ChatSessionUpdate
+  if err := c.ShouldBindJSON(&req); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  detail, err := ch.chatService.UpdateSession(c.Request.Context(), nil, sessionID, req)
+  if err != nil {
+    c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, detail)
+}
+
+// PinSession handles PUT /api/chat/sessions/:id/pin.
+func (ch *ChatHandler) PinSession(c *gin.Context) {
+  ch.setPinned(c, true)
+}
+
+// UnpinSession handles DELETE /api/chat/sessions/:id/pin.
+func (ch *ChatHandler) UnpinSession(c *gin.Context) {
+  ch.setPinned(c, false)
+}
+
+func (ch *ChatHandler) setPinned(c *gin.Context, pinned bool) {
+  sessionID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  if err := ch.chatService.PinSession(c.Request.Context(), nil, sessionID, pinned); err != nil {
+    c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"sessionID": sessionID, "pinned": pinned})
+}
+
+// ExportSession handles GET /api/chat/sessions/:id/export?format=markdown|json
+// and answers with the session as a file download.
+func (ch *ChatHandler) ExportSession(c *gin.Context) {
+  sessionID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  export, err := ch.chatService.ExportSession(c.Request.Context(), nil, sessionID, c.Query("format"))
+  if err != nil {
+    c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+    return
+  }
+  c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.Filename))
+  c.Data(http.StatusOK, export.ContentType, export.Data)
+}
+
+// SearchMessages handles GET /api/chat/search?q=&limit=&offset= over every
+// session the caller may read.
+func (ch *ChatHandler) SearchMessages(c *gin.Context) {
+  limit, _ := strconv.Atoi(c.Query("limit"))
+  offset, _ := strconv.Atoi(c.Query("offset"))
+  page, err := ch.chatService.SearchMessages(c.Request.Context(), nil, c.Query("q"), limit, offset)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, page)
+}
+
+// GetRetention handles GET /api/chat/retention for the caller's company or
+// WMS.
+func (ch *ChatHandler) GetRetention(c *gin.Context) {
+  policy, err := ch.chatService.GetRetentionPolicy(c.Request.Context(), nil)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, policy)
+}
+
+// SetRetention handles PUT /api/chat/retention with {"retentionDays": n};
+// 0 keeps sessions forever.
+func (ch *ChatHandler) SetRetention(c *gin.Context) {
+  var req struct {
+    RetentionDays   *int  `json:"retentionDays" binding:"required"`
+  }
+  if err := c.ShouldBindJSON(&req); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  policy, err := ch.chatService.SetRetentionPolicy(c.Request.Context(), nil, *req.RetentionDays)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, policy)
+}
+
+// DeleteSession handles DELETE /api/chat/sessions/:id.
+func (ch *ChatHandler) DeleteSession(c *gin.Context) {
+  sessionID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  if err := ch.chatService.DeleteSession(c.Request.Context(), nil, sessionID); err != nil {
+    c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"message": "Chat session deleted"})
+}
+
+// PostMessage handles POST /api/chat/sessions/:id/messages with
+// {"content": "..."}. With ?stream=true or Accept: text/event-stream the
+// reply streams as server-sent events named after services.ChatEvent*
+// (message, token, done, error); otherwise it answers once the reply is
+// complete with the new messages.
+func (ch *ChatHandler) PostMessage(c *gin.Context) {
+  sessionID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  var req struct {
+    Content   string  `json:"content" binding:"required"`
+  }
+  if err := c.ShouldBindJSON(&req); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  ctx := c.Request.Context()
+
+  stream := c.Query("stream") == "true" || strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+  if !stream {
+    messages, err := ch.chatService.PostMessage(ctx, sessionID, req.Content, nil)
+    if err != nil {
+      c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+      return
+    }
+    c.JSON(http.StatusOK, gin.H{"messages": messages})
+    return
+  }
+
+  flusher, ok := c.Writer.(http.Flusher)
+  if !ok {
+    c.JSON(http.StatusInternalServerError, gin.H{"error": "Streaming unsupported!"})
+    return
+  }
+  // Headers go out with the first event, so errors found before the reply
+  // starts (unknown session, busy, empty content) still get a status code.
+  started := false
+  write := func(ev services.ChatStreamEvent) error {
+    if !started {
+      c.Header("Content-Type", "text/event-stream")
+      c.Header("Cache-Control", "no-cache")
+      c.Header("Connection", "keep-alive")
+      c.Header("X-Accel-Buffering", "no")
+      c.Status(http.StatusOK)
+      started = true
+    }
+    data, err := json.Marshal(ev.Data)
+    if err != nil {
+      return err
+    }
+    if _, err := fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+      return err
+    }
+    flusher.Flush()
+    return ctx.Err()
+  }
+  if _, err := ch.chatService.PostMessage(ctx, sessionID, req.Content, write); err != nil {
+    if !started {
+      c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+      return
+    }
+    if ctx.Err() == nil {
+      _ = write(services.ChatStreamEvent{Type: services.ChatEventError, Data: gin.H{"error": err.Error()}})
+    }
+  }
+}
+
+func chatErrorStatus(err error) int {
+  switch {
+  case errors.Is(err, services.ErrChatSessionNotFound):
+    return http.StatusNotFound
+  case errors.Is(err, services.ErrChatSessionReadOnly):
+    return http.StatusForbidden
+  case errors.Is(err, services.ErrChatSessionBusy):
+    return http.StatusConflict
+  default:
+    return http.StatusBadRequest
+  }
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+  "context"
+  "errors"
+  "io"
+  "io/fs"
+  "net/http"
+  "os"
+  "strings"
+  "time"
+
+  "github.com/gin-gonic/gin"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/services"
+)
+
+// maxLocalSignedUpload caps PUTs through a signed local-storage url.
+const maxLocalSignedUpload = 100 << 20
+
+type FileHandler struct {
+  fileAccessService   services.FileAccessService
+}
+
+func NewFileHandler(fileAccessService services.FileAccessService) *FileHandler {
+  return &FileHandler{fileAccessService: fileAccessService}
+}
+
+type SignURLRequest struct {
+  Key             string          `json:"key"`
+  Method          string          `json:"method,omitempty"`
+  TTLSeconds      int             `json:"ttl_seconds,omitempty"`
+}
+
+// SignURL mints a short-lived GET or HEAD url for a private object owned by
+// the caller's tenant.
+func (fh *FileHandler) SignURL(c *gin.Context) {
+  fh.sign(c, fh.fileAccessService.SignURL)
+}
+
+// SignWriteURL mints a PUT or DELETE url. It is routed behind manage_files.
+func (fh *FileHandler) SignWriteURL(c *gin.Context) {
+  fh.sign(c, fh.fileAccessService.SignWriteURL)
+}
+
+func (fh *FileHandler) sign(c *gin.Context, signer func(ctx context.Context, tx *gorm.DB, key string, method string, ttl time.Duration) (*services.SignedURL, error)) {
+  var req SignURLRequest
+  if err := c.ShouldBindJSON(&req); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+    return
+  }
+  if strings.TrimSpace(req.Key) == "" {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "key is required"})
+    return
+  }
+  signed, err := signer(c.Request.Context(), nil, req.Key, req.Method, time.Duration(req.TTLSeconds)*time.Second)
+  if err != nil {
+    c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"signedURL": signed})
+}
+
+//-------------------------------------------------------
+// Local storage (STORAGE_BACKEND=local only)
+//-------------------------------------------------------
+
+type LocalFilesHandler struct {
+  storage         services.LocalStorage
+}
+
+func NewLocalFilesHandler(storage services.LocalStorage) *LocalFilesHandler {
+  return &LocalFilesHandler{storage: storage}
+}
+
+// ServeFile serves GET/HEAD. Public objects need no signature; private ones
+// and any request carrying a signature must verify.
+func (lh *LocalFilesHandler) ServeFile(c *gin.Context) {
+  key := strings.TrimPrefix(c.Param("key"), "/")
+  private := services.IsPrivateKey(key)
+  if private || c.Query("signature") != "" {
+    if err := lh.storage.VerifySignedURL(key, c.Request.Method, c.Request.URL.Query()); err != nil {
+      c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+      return
+    }
+  }
+  full, err := lh.storage.LocalPath(key)
+  if err != nil {
+    c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+    return
+  }
+  info, err := os.Stat(full)
+  if err != nil || info.IsDir() {
+    c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+    return
+  }
+  if private {
+    c.Header("Cache-Control", "private, no-store")
+  }
+  c.File(full)
+}
+
+// PutFile accepts an upload through a url signed for PUT.
+func (lh *LocalFilesHandler) PutFile(c *gin.Context) {
+  key := strings.TrimPrefix(c.Param("key"), "/")
+  if err := lh.storage.VerifySignedURL(key, http.MethodPut, c.Request.URL.Query()); err != nil {
+    c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+    return
+  }
+  body := io.Reader(http.MaxBytesReader(c.Writer, c.Request.Body, maxLocalSignedUpload))
+  if err := lh.storage.UploadFile(c.Request.Context(), nil, key, body); err != nil {
+    var maxErr *http.MaxBytesError
+    if errors.As(err, &maxErr) {
+      c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file is too large"})
+      return
+    }
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.Status(http.StatusOK)
+}
+
+// DeleteFile removes an object through a url signed for DELETE.
+func (lh *LocalFilesHandler) DeleteFile(c *gin.Context) {
+  key := strings.TrimPrefix(c.Param("key"), "/")
+  if err := lh.storage.VerifySignedURL(key, http.MethodDelete, c.Request.URL.Query()); err != nil {
+    c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+    return
+  }
+  if err := lh.storage.DeleteFile(c.Request.Context(), nil, key); err != nil {
+    if errors.Is(err, fs.ErrNotExist) {
+      c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+      return
+    }
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.Status(http.StatusNoContent)
+}
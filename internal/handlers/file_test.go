@@ -0,0 +1,90 @@
+package handlers
+
+import (
+  "context"
+  "io"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/gin-gonic/gin"
+  "github.com/google/uuid"
+  "go.uber.org/zap"
+
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/services"
+)
+
+// TestLocalFilesHandler drives the local storage backend over HTTP the way a
+// browser holding signed urls would.
+func TestLocalFilesHandler(t *testing.T) {
+  gin.SetMode(gin.TestMode)
+  log := &logger.Logger{SugaredLogger: zap.NewNop().Sugar()}
+  bs, err := services.NewLocalBucketService(log, t.TempDir(), services.LocalStorageRoute, []byte("0123456789abcdef0123456789abcdef"))
+  if err != nil {
+    t.Fatalf("NewLocalBucketService: %v", err)
+  }
+  storage := bs.(services.LocalStorage)
+  lh := NewLocalFilesHandler(storage)
+  router := gin.New()
+  router.GET(services.LocalStorageRoute+"/*key", lh.ServeFile)
+  router.HEAD(services.LocalStorageRoute+"/*key", lh.ServeFile)
+  router.PUT(services.LocalStorageRoute+"/*key", lh.PutFile)
+  router.DELETE(services.LocalStorageRoute+"/*key", lh.DeleteFile)
+  srv := httptest.NewServer(router)
+  defer srv.Close()
+
+  privateKey := services.PrivateObjectKey("company", uuid.New(), "exports/items.csv")
+  publicKey := "avatars/company/logo.png"
+  if err := storage.UploadFile(context.Background(), nil, publicKey, strings.NewReader("png")); err != nil {
+    t.Fatalf("UploadFile: %v", err)
+  }
+  sign := func(key, method string) string {
+    signed, err := storage.GetSignedURL(key, time.Minute, method)
+    if err != nil {
+      t.Fatalf("GetSignedURL(%s): %v", method, err)
+    }
+    return srv.URL + signed
+  }
+
+  steps := []struct {
+    name          string
+    method        string
+    url           string
+    body          string
+    wantStatus    int
+    wantBody      string
+  }{
+    {name: "put without signature", method: http.MethodPut, url: srv.URL + services.LocalStorageRoute + "/" + privateKey, body: "sku\n", wantStatus: http.StatusForbidden},
+    {name: "put with get url", method: http.MethodPut, url: sign(privateKey, http.MethodGet), body: "sku\n", wantStatus: http.StatusForbidden},
+    {name: "signed put", method: http.MethodPut, url: sign(privateKey, http.MethodPut), body: "sku\n", wantStatus: http.StatusOK},
+    {name: "private get without signature", method: http.MethodGet, url: srv.URL + services.LocalStorageRoute + "/" + privateKey, wantStatus: http.StatusForbidden},
+    {name: "signed get", method: http.MethodGet, url: sign(privateKey, http.MethodGet), wantStatus: http.StatusOK, wantBody: "sku\n"},
+    {name: "signed head", method: http.MethodHead, url: sign(privateKey, http.MethodGet), wantStatus: http.StatusOK},
+    {name: "public get", method: http.MethodGet, url: srv.URL + services.LocalStorageRoute + "/" + publicKey, wantStatus: http.StatusOK, wantBody: "png"},
+    {name: "delete with put url", method: http.MethodDelete, url: sign(privateKey, http.MethodPut), wantStatus: http.StatusForbidden},
+    {name: "signed delete", method: http.MethodDelete, url: sign(privateKey, http.MethodDelete), wantStatus: http.StatusNoContent},
+    {name: "get after delete", method: http.MethodGet, url: sign(privateKey, http.MethodGet), wantStatus: http.StatusNotFound},
+    {name: "delete again", method: http.MethodDelete, url: sign(privateKey, http.MethodDelete), wantStatus: http.StatusNotFound},
+  }
+  for _, step := range steps {
+    req, err := http.NewRequest(step.method, step.url, strings.NewReader(step.body))
+    if err != nil {
+      t.Fatalf("%s: %v", step.name, err)
+    }
+    resp, err := srv.Client().Do(req)
+    if err != nil {
+      t.Fatalf("%s: %v", step.name, err)
+    }
+    body, _ := io.ReadAll(resp.Body)
+    resp.Body.Close()
+    if resp.StatusCode != step.wantStatus {
+      t.Fatalf("%s: status = %d, want %d (%s)", step.name, resp.StatusCode, step.wantStatus, body)
+    }
+    if step.wantBody != "" && string(body) != step.wantBody {
+      t.Fatalf("%s: body = %q, want %q", step.name, body, step.wantBody)
+    }
+  }
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+  "net/http"
+
+  "github.com/gin-gonic/gin"
+
+  "github.com/slotter-org/slotter-backend/internal/services"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type FloorMapHandler struct {
+  floorMapService     services.FloorMapService
+}
+
+func NewFloorMapHandler(floorMapService services.FloorMapService) *FloorMapHandler {
+  return &FloorMapHandler{floorMapService: floorMapService}
+}
+
+// GetFloorMap handles GET /api/warehouses/:id/floor-map. Query parameters:
+// format (svg|png), heatmap (none|velocity|cube|scenario), scenarioID and
+// side (before|after) for scenario heatmaps, and velocityRunID. It answers
+// with a signed URL to embed, or with ?redirect=true redirects to it.
+func (fh *FloorMapHandler) GetFloorMap(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  req := services.FloorMapRequest{
+    Format:  types.FloorMapFormat(c.Query("format")),
+    Heatmap: types.FloorMapHeatmap(c.Query("heatmap")),
+    Side:    c.Query("side"),
+  }
+  if req.ScenarioID, ok = parseUUIDQuery(c, "scenarioID"); !ok {
+    return
+  }
+  if req.VelocityRunID, ok = parseUUIDQuery(c, "velocityRunID"); !ok {
+    return
+  }
+  floorMap, err := fh.floorMapService.RenderFloorMap(c.Request.Context(), nil, warehouseID, req)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  if c.Query("redirect") == "true" {
+    c.Header("Cache-Control", "private, no-store")
+    c.Redirect(http.StatusFound, floorMap.URL)
+    return
+  }
+  c.JSON(http.StatusOK, floorMap)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+  "errors"
+  "net/http"
+  "strconv"
+  "time"
+
+  "github.com/gin-gonic/gin"
+  "github.com/google/uuid"
+
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/services"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type InventoryHandler struct {
+  inventoryService    services.InventoryService
+}
+
+func NewInventoryHandler(inventoryService services.InventoryService) *InventoryHandler {
+  return &InventoryHandler{inventoryService: inventoryService}
+}
+
+// ListInventory handles GET /api/warehouses/:id/inventory. Optional filters:
+// itemID, sku, locationID, prefix (a location full code such as "A-03"), lot
+// and expiringBefore (a date or RFC 3339 time), plus limit and offset.
+func (ih *InventoryHandler) ListInventory(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  filter := services.InventoryFilter{SKU: c.Query("sku")}
+  filter.LocationPrefix = c.Query("prefix")
+  filter.Lot = c.Query("lot")
+  filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+  filter.Offset, _ = strconv.Atoi(c.Query("offset"))
+  if filter.ItemID, ok = parseUUIDQuery(c, "itemID"); !ok {
+    return
+  }
+  if filter.LocationID, ok = parseUUIDQuery(c, "locationID"); !ok {
+    return
+  }
+  if filter.ExpiringBefore, ok = parseTimeQuery(c, "expiringBefore"); !ok {
+    return
+  }
+  page, err := ih.inventoryService.ListOnHand(c.Request.Context(), nil, warehouseID, filter)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, page)
+}
+
+// ListLedger handles GET /api/warehouses/:id/inventory/ledger, newest first.
+// Optional filters: itemID, locationID, type, since and until.
+func (ih *InventoryHandler) ListLedger(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  filter := repos.LedgerFilter{Type: types.InventoryTransactionType(c.Query("type"))}
+  filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+  filter.Offset, _ = strconv.Atoi(c.Query("offset"))
+  if filter.ItemID, ok = parseUUIDQuery(c, "itemID"); !ok {
+    return
+  }
+  if filter.LocationID, ok = parseUUIDQuery(c, "locationID"); !ok {
+    return
+  }
+  if filter.Since, ok = parseTimeQuery(c, "since"); !ok {
+    return
+  }
+  if filter.Until, ok = parseTimeQuery(c, "until"); !ok {
+    return
+  }
+  page, err := ih.inventoryService.ListLedger(c.Request.Context(), nil, warehouseID, filter)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, page)
+}
+
+type RecordTransactionsRequest struct {
+  Transactions    []services.InventoryTransactionInput  `json:"transactions"`
+}
+
+// RecordTransactions handles POST /api/warehouses/:id/inventory/transactions.
+// The batch is all or nothing; a bin that would go negative answers 409.
+func (ih *InventoryHandler) RecordTransactions(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  var req RecordTransactionsRequest
+  if err := c.ShouldBindJSON(&req); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+    return
+  }
+  entries, err := ih.inventoryService.RecordTransactions(c.Request.Context(), nil, warehouseID, req.Transactions)
+  if err != nil {
+    if errors.Is(err, services.ErrInsufficientStock) {
+      c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+      return
+    }
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusCreated, gin.H{"entries": entries})
+}
+
+// parseUUIDQuery reads an optional UUID query parameter; an empty value is nil.
+func parseUUIDQuery(c *gin.Context, name string) (*uuid.UUID, bool) {
+  raw := c.Query(name)
+  if raw == "" {
+    return nil, true
+  }
+  id, err := uuid.Parse(raw)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name + " format"})
+    return nil, false
+  }
+  return &id, true
+}
+
+// parseTimeQuery reads an optional time query parameter given either as a
+// date (2006-01-02, midnight UTC) or as an RFC 3339 timestamp.
+func parseTimeQuery(c *gin.Context, name string) (*time.Time, bool) {
+  raw := c.Query(name)
+  if raw == "" {
+    return nil, true
+  }
+  if t, err := time.Parse("2006-01-02", raw); err == nil {
+    return &t, true
+  }
+  t, err := time.Parse(time.RFC3339, raw)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name + " format, expected YYYY-MM-DD or RFC 3339"})
+    return nil, false
+  }
+  return &t, true
+}
@@ -6,19 +6,16 @@ import (
   "github.com/gin-gonic/gin"
   "github.com/google/uuid"
   
-  "github.com/slotter-org/slotter-backend/internal/sse"
-  "github.com/slotter-org/slotter-backend/internal/ssedata"
   "github.com/slotter-org/slotter-backend/internal/types"
   "github.com/slotter-org/slotter-backend/internal/services"
 )
 
 type InvitationHandler struct {
   invitationService       services.InvitationService
-  sseHub                  *sse.SSEHub
 }
 
-func NewInvitationHandler(invitationService services.InvitationService, hub *sse.SSEHub) *InvitationHandler {
-  return &InvitationHandler{invitationService: invitationService, sseHub: hub}
+func NewInvitationHandler(invitationService services.InvitationService) *InvitationHandler {
+  return &InvitationHandler{invitationService: invitationService}
 }
 
 type InvitationSendRequest struct {
@@ -46,13 +43,6 @@ func (ih *InvitationHandler) SendInvitation(c *gin.Context) {
     c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
     return
   }
-  ssd := ssedata.GetSSEData(c.Request.Context())
-  if ssd != nil && len(ssd.Messages) > 0 {
-    for _, msg := range ssd.Messages {
-      ih.sseHub.Broadcast(msg)
-    }
-    ssd.Messages = nil
-  }
   c.JSON(http.StatusOK, gin.H{"message": "Invitation sent successfully"})
 }
 
@@ -88,13 +78,6 @@ func (ih *InvitationHandler) UpdateInvitationMsgName(c *gin.Context) {
     c.JSON(http.StatusBadRequest, gin.H{"error": updateErr.Error()})
     return
   }
-  ssd := ssedata.GetSSEData(c.Request.Context())
-  if ssd != nil && len(ssd.Messages) > 0 {
-    for _, msg := range ssd.Messages {
-      ih.sseHub.Broadcast(msg)
-    }
-    ssd.Messages = nil
-  }
   c.JSON(http.StatusOK, gin.H{"message": "Invitation updated successfully"})
 }
 
@@ -131,13 +114,6 @@ func (ih *InvitationHandler) UpdateInvitationRole(c *gin.Context) {
     c.JSON(http.StatusBadRequest, gin.H{"error": updateErr.Error()})
     return
   }
-  ssd := ssedata.GetSSEData(c.Request.Context())
-  if ssd != nil && len(ssd.Messages) > 0 {
-    for _, msg := range ssd.Messages {
-      ih.sseHub.Broadcast(msg)
-    }
-    ssd.Messages = nil
-  }
   c.JSON(http.StatusOK, gin.H{"message": "Invitation role updated successfully"})
 }
 
@@ -169,13 +145,6 @@ func (ih *InvitationHandler) CancelInvitation(c *gin.Context) {
     c.JSON(http.StatusBadRequest, gin.H{"error": cancelErr.Error()})
     return
   }
-  ssd := ssedata.GetSSEData(c.Request.Context())
-  if ssd != nil && len(ssd.Messages) > 0 {
-    for _, msg := range ssd.Messages {
-      ih.sseHub.Broadcast(msg)
-    }
-    ssd.Messages = nil
-  }
   c.JSON(http.StatusOK, gin.H{"message": "Invitation canceled successfully"})
 }
 
@@ -207,13 +176,6 @@ func (ih *InvitationHandler) ResendInvitation(c *gin.Context) {
     c.JSON(http.StatusBadRequest, gin.H{"error": reErr.Error()})
     return
   }
-  ssd := ssedata.GetSSEData(c.Request.Context())
-  if ssd != nil && len(ssd.Messages) > 0 {
-    for _, msg := range ssd.Messages {
-      ih.sseHub.Broadcast(msg)
-    }
-    ssd.Messages = nil
-  }
   c.JSON(http.StatusOK, gin.H{"message": "Invitation resent successfully"})
 }
 
@@ -245,13 +207,6 @@ func (ih *InvitationHandler) DeleteInvitation(c *gin.Context) {
     c.JSON(http.StatusBadRequest, gin.H{"error": delErr.Error()})
     return
   }
-  ssd := ssedata.GetSSEData(c.Request.Context())
-  if ssd != nil && len(ssd.Messages) > 0 {
-    for _, msg := range ssd.Messages {
-      ih.sseHub.Broadcast(msg)
-    }
-    ssd.Messages = nil
-  }
   c.JSON(http.StatusOK, gin.H{"message": "Invitation deleted successfully"})
 }
 
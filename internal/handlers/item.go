@@ -0,0 +1,178 @@
+package handlers
+
+import (
+  "bytes"
+  "errors"
+  "net/http"
+  "strconv"
+  "time"
+
+  "github.com/gin-gonic/gin"
+
+  "github.com/slotter-org/slotter-backend/internal/services"
+)
+
+type ItemHandler struct {
+  itemService     services.ItemService
+}
+
+func NewItemHandler(itemService services.ItemService) *ItemHandler {
+  return &ItemHandler{itemService: itemService}
+}
+
+// ListItems handles GET /api/companies/:id/items?q=&limit=&offset=. q matches
+// SKU, description or barcode.
+func (ih *ItemHandler) ListItems(c *gin.Context) {
+  companyID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  limit, _ := strconv.Atoi(c.Query("limit"))
+  offset, _ := strconv.Atoi(c.Query("offset"))
+  page, err := ih.itemService.ListItems(c.Request.Context(), nil, companyID, c.Query("q"), limit, offset)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, page)
+}
+
+func (ih *ItemHandler) GetItem(c *gin.Context) {
+  companyID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  itemID, ok := parseUUIDParam(c, "itemId")
+  if !ok {
+    return
+  }
+  item, err := ih.itemService.GetItem(c.Request.Context(), nil, companyID, itemID)
+  if err != nil {
+    c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"item": item})
+}
+
+// LookupGTIN handles GET /api/companies/:id/items/lookup?gtin= for scanners.
+func (ih *ItemHandler) LookupGTIN(c *gin.Context) {
+  companyID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  match, err := ih.itemService.LookupGTIN(c.Request.Context(), nil, companyID, c.Query("gtin"))
+  if err != nil {
+    c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, match)
+}
+
+func (ih *ItemHandler) CreateItem(c *gin.Context) {
+  companyID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  var input services.ItemInput
+  if err := c.ShouldBindJSON(&input); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+    return
+  }
+  item, err := ih.itemService.CreateItem(c.Request.Context(), nil, companyID, input)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusCreated, gin.H{"item": item})
+}
+
+// UpdateItem handles PUT /api/companies/:id/items/:itemId. The body replaces
+// the whole item, UOMs included.
+func (ih *ItemHandler) UpdateItem(c *gin.Context) {
+  companyID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  itemID, ok := parseUUIDParam(c, "itemId")
+  if !ok {
+    return
+  }
+  var input services.ItemInput
+  if err := c.ShouldBindJSON(&input); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+    return
+  }
+  item, err := ih.itemService.UpdateItem(c.Request.Context(), nil, companyID, itemID, input)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"item": item})
+}
+
+func (ih *ItemHandler) DeleteItem(c *gin.Context) {
+  companyID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  itemID, ok := parseUUIDParam(c, "itemId")
+  if !ok {
+    return
+  }
+  if _, err := ih.itemService.DeleteItem(c.Request.Context(), nil, companyID, itemID); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ImportItems handles POST /api/companies/:id/items/import with a CSV in the
+// multipart "file" field; ?dryRun=true only validates. A rejected import
+// answers 422 with the row report.
+func (ih *ItemHandler) ImportItems(c *gin.Context) {
+  companyID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+  c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxItemImportBytes+multipartOverhead)
+  file, _, err := c.Request.FormFile("file")
+  if err != nil {
+    var maxErr *http.MaxBytesError
+    if errors.As(err, &maxErr) {
+      c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "item file is too large"})
+      return
+    }
+    c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+    return
+  }
+  defer file.Close()
+
+  report, err := ih.itemService.ImportItemsCSV(c.Request.Context(), nil, companyID, file, dryRun)
+  if err != nil {
+    if errors.Is(err, services.ErrItemImportInvalid) && report != nil {
+      c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "report": report})
+      return
+    }
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+// ExportItems handles GET /api/companies/:id/items/export and streams the
+// item master as CSV in the import layout.
+func (ih *ItemHandler) ExportItems(c *gin.Context) {
+  companyID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  var buf bytes.Buffer
+  if err := ih.itemService.ExportItemsCSV(c.Request.Context(), nil, companyID, &buf); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  filename := "items-" + time.Now().UTC().Format("20060102") + ".csv"
+  c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+  c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
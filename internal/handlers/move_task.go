@@ -0,0 +1,190 @@
+package handlers
+
+import (
+  "errors"
+  "net/http"
+  "strconv"
+  "strings"
+
+  "github.com/gin-gonic/gin"
+  "github.com/google/uuid"
+
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/services"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// MoveTaskHandler serves the floor side of a slotting plan. Every change is
+// pushed to the company's channel, so supervisors can follow a plan live.
+type MoveTaskHandler struct {
+  moveTaskService     services.MoveTaskService
+}
+
+func NewMoveTaskHandler(moveTaskService services.MoveTaskService) *MoveTaskHandler {
+  return &MoveTaskHandler{moveTaskService: moveTaskService}
+}
+
+// GenerateTasks handles POST /api/warehouses/:id/slotting/plans/:planId/tasks.
+func (mh *MoveTaskHandler) GenerateTasks(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  planID, ok := parseUUIDParam(c, "planId")
+  if !ok {
+    return
+  }
+  batch, err := mh.moveTaskService.GenerateTasks(c.Request.Context(), nil, warehouseID, planID)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusCreated, batch)
+}
+
+// ListTasks handles GET /api/warehouses/:id/move-tasks. Optional filters:
+// planID, assignedTo (a user ID, or "me") and status (comma separated), plus
+// limit and offset.
+func (mh *MoveTaskHandler) ListTasks(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  var filter repos.MoveTaskFilter
+  filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+  filter.Offset, _ = strconv.Atoi(c.Query("offset"))
+  if filter.PlanID, ok = parseUUIDQuery(c, "planID"); !ok {
+    return
+  }
+  if c.Query("assignedTo") == "me" {
+    if rd := requestdata.GetRequestData(c.Request.Context()); rd != nil && rd.UserID != uuid.Nil {
+      userID := rd.UserID
+      filter.AssignedToID = &userID
+    }
+  } else if filter.AssignedToID, ok = parseUUIDQuery(c, "assignedTo"); !ok {
+    return
+  }
+  for _, st := range strings.Split(c.Query("status"), ",") {
+    if st = strings.TrimSpace(st); st != "" {
+      filter.Statuses = append(filter.Statuses, types.MoveTaskStatus(st))
+    }
+  }
+  page, err := mh.moveTaskService.ListTasks(c.Request.Context(), nil, warehouseID, filter)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, page)
+}
+
+func (mh *MoveTaskHandler) GetTask(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  taskID, ok := parseUUIDParam(c, "taskId")
+  if !ok {
+    return
+  }
+  task, err := mh.moveTaskService.GetTask(c.Request.Context(), nil, warehouseID, taskID)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"task": task})
+}
+
+// AssignTask handles POST /api/warehouses/:id/move-tasks/:taskId/assign with
+// {"userID": "..."}; an empty userID unassigns the task.
+func (mh *MoveTaskHandler) AssignTask(c *gin.Context) {
+  var req struct {
+    UserID  string  `json:"userID"`
+  }
+  if err := c.ShouldBindJSON(&req); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+    return
+  }
+  userID := uuid.Nil
+  if req.UserID != "" {
+    id, err := uuid.Parse(req.UserID)
+    if err != nil {
+      c.JSON(http.StatusBadRequest, gin.H{"error": "invalid userID format"})
+      return
+    }
+    userID = id
+  }
+  mh.updateTask(c, func(warehouseID, taskID uuid.UUID) (*services.MoveTaskUpdate, error) {
+    return mh.moveTaskService.AssignTask(c.Request.Context(), nil, warehouseID, taskID, userID)
+  })
+}
+
+func (mh *MoveTaskHandler) ClaimTask(c *gin.Context) {
+  mh.updateTask(c, func(warehouseID, taskID uuid.UUID) (*services.MoveTaskUpdate, error) {
+    return mh.moveTaskService.ClaimTask(c.Request.Context(), nil, warehouseID, taskID)
+  })
+}
+
+func (mh *MoveTaskHandler) StartTask(c *gin.Context) {
+  mh.updateTask(c, func(warehouseID, taskID uuid.UUID) (*services.MoveTaskUpdate, error) {
+    return mh.moveTaskService.StartTask(c.Request.Context(), nil, warehouseID, taskID)
+  })
+}
+
+// ConfirmTask handles POST /api/warehouses/:id/move-tasks/:taskId/confirm. It
+// answers 409 when the source bin no longer holds the stock.
+func (mh *MoveTaskHandler) ConfirmTask(c *gin.Context) {
+  var input services.MoveTaskConfirmation
+  if err := c.ShouldBindJSON(&input); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+    return
+  }
+  mh.updateTask(c, func(warehouseID, taskID uuid.UUID) (*services.MoveTaskUpdate, error) {
+    return mh.moveTaskService.ConfirmTask(c.Request.Context(), nil, warehouseID, taskID, input)
+  })
+}
+
+func (mh *MoveTaskHandler) ReportException(c *gin.Context) {
+  var input services.MoveTaskExceptionInput
+  if err := c.ShouldBindJSON(&input); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+    return
+  }
+  mh.updateTask(c, func(warehouseID, taskID uuid.UUID) (*services.MoveTaskUpdate, error) {
+    return mh.moveTaskService.ReportException(c.Request.Context(), nil, warehouseID, taskID, input)
+  })
+}
+
+func (mh *MoveTaskHandler) ReopenTask(c *gin.Context) {
+  mh.updateTask(c, func(warehouseID, taskID uuid.UUID) (*services.MoveTaskUpdate, error) {
+    return mh.moveTaskService.ReopenTask(c.Request.Context(), nil, warehouseID, taskID)
+  })
+}
+
+func (mh *MoveTaskHandler) CancelTask(c *gin.Context) {
+  mh.updateTask(c, func(warehouseID, taskID uuid.UUID) (*services.MoveTaskUpdate, error) {
+    return mh.moveTaskService.CancelTask(c.Request.Context(), nil, warehouseID, taskID)
+  })
+}
+
+// updateTask runs one task transition and broadcasts the result.
+func (mh *MoveTaskHandler) updateTask(c *gin.Context, run func(warehouseID, taskID uuid.UUID) (*services.MoveTaskUpdate, error)) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  taskID, ok := parseUUIDParam(c, "taskId")
+  if !ok {
+    return
+  }
+  update, err := run(warehouseID, taskID)
+  if err != nil {
+    if errors.Is(err, services.ErrInsufficientStock) {
+      c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+      return
+    }
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, update)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+  "net/http"
+  "strings"
+
+  "github.com/gin-gonic/gin"
+  "github.com/google/uuid"
+
+  "github.com/slotter-org/slotter-backend/internal/services"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type OutboxHandler struct {
+  outboxService services.OutboxService
+}
+
+func NewOutboxHandler(outboxService services.OutboxService) *OutboxHandler {
+  return &OutboxHandler{outboxService: outboxService}
+}
+
+// ListFailedMessages returns failed and dead-lettered messages for the caller's
+// tenant. ?status=failed,dead,pending narrows or widens the set.
+func (oh *OutboxHandler) ListFailedMessages(c *gin.Context) {
+  var statuses []types.OutboxStatus
+  if raw := strings.TrimSpace(c.Query("status")); raw != "" {
+    for _, s := range strings.Split(raw, ",") {
+      statuses = append(statuses, types.OutboxStatus(strings.TrimSpace(s)))
+    }
+  }
+  msgs, err := oh.outboxService.ListFailed(c.Request.Context(), nil, statuses)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"messages": msgs})
+}
+
+type OutboxRetryRequest struct {
+  MessageIDs        []string            `json:"message_ids"`
+}
+
+func (oh *OutboxHandler) RetryMessages(c *gin.Context) {
+  var req OutboxRetryRequest
+  if err := c.ShouldBindJSON(&req); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+    return
+  }
+  if len(req.MessageIDs) == 0 {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "message_ids is required"})
+    return
+  }
+  ids := make([]uuid.UUID, 0, len(req.MessageIDs))
+  for _, raw := range req.MessageIDs {
+    id, err := uuid.Parse(raw)
+    if err != nil {
+      c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message_ids format"})
+      return
+    }
+    ids = append(ids, id)
+  }
+  msgs, err := oh.outboxService.RetryMessages(c.Request.Context(), nil, ids)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"messages": msgs})
+}
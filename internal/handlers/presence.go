@@ -0,0 +1,92 @@
+package handlers
+
+import (
+  "context"
+  "errors"
+  "net/http"
+
+  "github.com/gin-gonic/gin"
+  "github.com/google/uuid"
+
+  "github.com/slotter-org/slotter-backend/internal/channels"
+  "github.com/slotter-org/slotter-backend/internal/events"
+  "github.com/slotter-org/slotter-backend/internal/presence"
+  "github.com/slotter-org/slotter-backend/internal/services"
+)
+
+type PresenceHandler struct {
+  presenceService   services.PresenceService
+  bus               *events.Bus
+}
+
+func NewPresenceHandler(presenceService services.PresenceService, bus *events.Bus) *PresenceHandler {
+  return &PresenceHandler{presenceService: presenceService, bus: bus}
+}
+
+// ListPresence handles GET /api/presence?scope=company|wms. WMS users add
+// companyId to see one of their companies.
+func (ph *PresenceHandler) ListPresence(c *gin.Context) {
+  companyID, ok := parseUUIDQuery(c, "companyId")
+  if !ok {
+    return
+  }
+  members, err := ph.presenceService.List(c.Request.Context(), nil, c.Query("scope"), companyID)
+  if err != nil {
+    status := http.StatusBadRequest
+    if errors.Is(err, channels.ErrForbidden) {
+      status = http.StatusForbidden
+    }
+    c.JSON(status, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"members": members})
+}
+
+// SetViewing handles PUT /api/presence/viewing with the connectionId sent
+// in the stream's Connected message, plus the warehouseId and optional
+// scenarioId open in that tab; omitting both clears it.
+func (ph *PresenceHandler) SetViewing(c *gin.Context) {
+  var req struct {
+    ConnectionID  uuid.UUID   `json:"connectionId" binding:"required"`
+    WarehouseID   *uuid.UUID  `json:"warehouseId"`
+    ScenarioID    *uuid.UUID  `json:"scenarioId"`
+  }
+  if err := c.ShouldBindJSON(&req); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  if err := ph.presenceService.SetViewing(c.Request.Context(), nil, req.ConnectionID, req.WarehouseID, req.ScenarioID); err != nil {
+    status := http.StatusBadRequest
+    switch {
+    case errors.Is(err, channels.ErrForbidden):
+      status = http.StatusForbidden
+    case errors.Is(err, presence.ErrConnectionNotFound):
+      status = http.StatusNotFound
+    }
+    c.JSON(status, gin.H{"error": err.Error()})
+    return
+  }
+  c.Status(http.StatusNoContent)
+}
+
+// PresenceChanged is the presence.Tracker notifier; it announces users
+// coming online and going offline on their company or WMS channel.
+func (ph *PresenceHandler) PresenceChanged(change presence.Change, conn presence.Connection) {
+  t := events.UserJoined
+  if change == presence.Left {
+    t = events.UserLeft
+  }
+  ev := events.New(t, conn).Live()
+  ev.ActorID = &conn.UserID
+  kind, id, err := channels.Parse(conn.Tenant)
+  if err != nil {
+    return
+  }
+  switch kind {
+  case channels.KindCompany:
+    ev = ev.ForCompany(id)
+  case channels.KindWms:
+    ev = ev.ForWms(id)
+  }
+  ph.bus.Publish(context.Background(), ev)
+}
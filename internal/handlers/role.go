@@ -8,18 +8,15 @@ import (
   
   "github.com/slotter-org/slotter-backend/internal/types"
   "github.com/slotter-org/slotter-backend/internal/services"
-  "github.com/slotter-org/slotter-backend/internal/ssedata"
-  "github.com/slotter-org/slotter-backend/internal/sse"
   "github.com/slotter-org/slotter-backend/internal/errordata"
 )
 
 type RoleHandler struct {
   roleService     services.RoleService
-  sseHub          *sse.SSEHub
 }
 
-func NewRoleHandler(roleService services.RoleService, hub *sse.SSEHub) *RoleHandler {
-  return &RoleHandler{roleService: roleService, sseHub: hub}
+func NewRoleHandler(roleService services.RoleService) *RoleHandler {
+  return &RoleHandler{roleService: roleService}
 }
 
 //-------------------------------------------------------
@@ -48,13 +45,6 @@ func (rh *RoleHandler) CreateRole(c *gin.Context) {
     c.JSON(http.StatusBadRequest, gin.H{"error": errData.Message})
     return
   }
-  ssd := ssedata.GetSSEData(ctx)
-  if ssd != nil && len(ssd.Messages) > 0 {
-    for _, msg := range ssd.Messages {
-      rh.sseHub.Broadcast(msg)
-    }
-    ssd.Messages = nil
-  }
   c.JSON(http.StatusOK, gin.H{"message": "Role created successfully"})
 }
 
@@ -94,13 +84,6 @@ func (rh *RoleHandler) UpdateRoleNameDesc(c *gin.Context) {
     c.JSON(http.StatusBadRequest, gin.H{"error": errData.Message})
     return
   }
-  ssd := ssedata.GetSSEData(ctx)
-  if ssd != nil && len(ssd.Messages) > 0 {
-    for _, msg := range ssd.Messages {
-      rh.sseHub.Broadcast(msg)
-    }
-    ssd.Messages = nil
-  }
   c.JSON(http.StatusOK, gin.H{"message": "Role name/description updated successfully"})
 }
 
@@ -139,13 +122,6 @@ func (rh *RoleHandler) UpdateRolePermissions(c *gin.Context) {
     c.JSON(http.StatusBadRequest, gin.H{"error": errData.Message})
     return
   }
-  ssd := ssedata.GetSSEData(ctx)
-  if ssd != nil && len(ssd.Messages) > 0 {
-    for _, msg := range ssd.Messages {
-      rh.sseHub.Broadcast(msg)
-    }
-    ssd.Messages = nil
-  }
   c.JSON(http.StatusOK, gin.H{"message": "Role permissions updated successfully"})
 }
 
@@ -183,12 +159,5 @@ func (rh *RoleHandler) DeleteRole(c *gin.Context) {
     c.JSON(http.StatusBadRequest, gin.H{"error": errData.Message})
     return
   }
-  ssd := ssedata.GetSSEData(ctx)
-  if ssd != nil && len(ssd.Messages) > 0 {
-    for _, msg := range ssd.Messages {
-      rh.sseHub.Broadcast(msg)
-    }
-    ssd.Messages = nil
-  }
   c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
 }
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+  "context"
+  "net/http"
+
+  "github.com/gin-gonic/gin"
+
+  "github.com/slotter-org/slotter-backend/internal/channels"
+  "github.com/slotter-org/slotter-backend/internal/events"
+  "github.com/slotter-org/slotter-backend/internal/services"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type SlottingHandler struct {
+  slottingService   services.SlottingService
+  bus               *events.Bus
+}
+
+func NewSlottingHandler(slottingService services.SlottingService, bus *events.Bus) *SlottingHandler {
+  return &SlottingHandler{slottingService: slottingService, bus: bus}
+}
+
+// StartJob handles POST /api/warehouses/:id/slotting/jobs. The job runs in
+// the background; its progress is streamed over SSE on the job's channel
+// ("slotting_job:<jobID>") and the company channel.
+func (sh *SlottingHandler) StartJob(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  var params types.SlottingParams
+  if c.Request.ContentLength != 0 {
+    if err := c.ShouldBindJSON(&params); err != nil {
+      c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+      return
+    }
+  }
+  job, err := sh.slottingService.StartJob(c.Request.Context(), nil, warehouseID, params)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusAccepted, gin.H{"job": job})
+}
+
+func (sh *SlottingHandler) ListJobs(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  jobs, err := sh.slottingService.ListJobs(c.Request.Context(), nil, warehouseID)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+func (sh *SlottingHandler) GetJob(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  jobID, ok := parseUUIDParam(c, "jobId")
+  if !ok {
+    return
+  }
+  job, err := sh.slottingService.GetJob(c.Request.Context(), nil, warehouseID, jobID)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"job": job})
+}
+
+// CancelJob handles POST /api/warehouses/:id/slotting/jobs/:jobId/cancel. A
+// running job stops at the solver's next check; SlottingJobCanceled follows.
+func (sh *SlottingHandler) CancelJob(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  jobID, ok := parseUUIDParam(c, "jobId")
+  if !ok {
+    return
+  }
+  job, err := sh.slottingService.CancelJob(c.Request.Context(), nil, warehouseID, jobID)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusAccepted, gin.H{"job": job})
+}
+
+func (sh *SlottingHandler) ListAssignments(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  jobID, ok := parseUUIDParam(c, "jobId")
+  if !ok {
+    return
+  }
+  assignments, err := sh.slottingService.ListAssignments(c.Request.Context(), nil, warehouseID, jobID)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"assignments": assignments})
+}
+
+func (sh *SlottingHandler) ListMoves(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  jobID, ok := parseUUIDParam(c, "jobId")
+  if !ok {
+    return
+  }
+  moves, err := sh.slottingService.ListMoves(c.Request.Context(), nil, warehouseID, jobID)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"moves": moves})
+}
+
+// JobUpdated is the SlottingService notifier. Progress goes to the job's
+// own channel and the company channel alike, so both a job page and a
+// dashboard can follow it.
+func (sh *SlottingHandler) JobUpdated(job *types.SlottingJob) {
+  event := events.SlottingJobProgress
+  switch job.Status {
+  case types.SlottingJobCompleted:
+    event = events.SlottingJobCompleted
+  case types.SlottingJobFailed:
+    event = events.SlottingJobFailed
+  case types.SlottingJobCanceled:
+    event = events.SlottingJobCanceled
+  }
+  data := gin.H{
+    "jobID":       job.ID,
+    "warehouseID": job.WarehouseID,
+    "status":      job.Status,
+    "progress":    job.Progress,
+    "message":     job.Message,
+  }
+  if job.Status == types.SlottingJobCompleted || job.Status == types.SlottingJobFailed {
+    data["job"] = job
+  }
+  sh.bus.Publish(context.Background(), events.New(event, data).ForCompany(job.CompanyID).On(channels.SlottingJob(job.ID)))
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+  "errors"
+  "net/http"
+
+  "github.com/gin-gonic/gin"
+  "github.com/google/uuid"
+
+  "github.com/slotter-org/slotter-backend/internal/services"
+)
+
+type SlottingScenarioHandler struct {
+  scenarioService   services.SlottingScenarioService
+}
+
+func NewSlottingScenarioHandler(scenarioService services.SlottingScenarioService) *SlottingScenarioHandler {
+  return &SlottingScenarioHandler{scenarioService: scenarioService}
+}
+
+func (sh *SlottingScenarioHandler) ListScenarios(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  scenarios, err := sh.scenarioService.ListScenarios(c.Request.Context(), nil, warehouseID)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"scenarios": scenarios})
+}
+
+func (sh *SlottingScenarioHandler) GetScenario(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  scenarioID, ok := parseUUIDParam(c, "scenarioId")
+  if !ok {
+    return
+  }
+  scenario, err := sh.scenarioService.GetScenario(c.Request.Context(), nil, warehouseID, scenarioID)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"scenario": scenario})
+}
+
+// CreateScenario handles POST /api/warehouses/:id/slotting/scenarios. The
+// warehouse's layout, inventory and velocity are snapshotted right away.
+func (sh *SlottingScenarioHandler) CreateScenario(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  var input services.SlottingScenarioInput
+  if err := c.ShouldBindJSON(&input); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+    return
+  }
+  scenario, err := sh.scenarioService.CreateScenario(c.Request.Context(), nil, warehouseID, input)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusCreated, gin.H{"scenario": scenario})
+}
+
+func (sh *SlottingScenarioHandler) UpdateScenario(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  scenarioID, ok := parseUUIDParam(c, "scenarioId")
+  if !ok {
+    return
+  }
+  var patch services.SlottingScenarioPatch
+  if err := c.ShouldBindJSON(&patch); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+    return
+  }
+  scenario, err := sh.scenarioService.UpdateScenario(c.Request.Context(), nil, warehouseID, scenarioID, patch)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"scenario": scenario})
+}
+
+func (sh *SlottingScenarioHandler) DeleteScenario(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  scenarioID, ok := parseUUIDParam(c, "scenarioId")
+  if !ok {
+    return
+  }
+  if _, err := sh.scenarioService.DeleteScenario(c.Request.Context(), nil, warehouseID, scenarioID); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// RunScenario handles POST /api/warehouses/:id/slotting/scenarios/:scenarioId/run.
+// The answer is the job; it reports over SSE like any slotting job.
+func (sh *SlottingScenarioHandler) RunScenario(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  scenarioID, ok := parseUUIDParam(c, "scenarioId")
+  if !ok {
+    return
+  }
+  job, err := sh.scenarioService.RunScenario(c.Request.Context(), nil, warehouseID, scenarioID)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusAccepted, gin.H{"job": job})
+}
+
+// CompareScenarios handles GET /api/warehouses/:id/slotting/scenarios/compare?a=&b=.
+func (sh *SlottingScenarioHandler) CompareScenarios(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  a, err := uuid.Parse(c.Query("a"))
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "invalid a format"})
+    return
+  }
+  b, err := uuid.Parse(c.Query("b"))
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "invalid b format"})
+    return
+  }
+  comparison, err := sh.scenarioService.CompareScenarios(c.Request.Context(), nil, warehouseID, a, b)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, comparison)
+}
+
+// PromoteScenario handles POST /api/warehouses/:id/slotting/scenarios/:scenarioId/promote.
+// It answers 409 when the stock has moved since the scenario's snapshot.
+func (sh *SlottingScenarioHandler) PromoteScenario(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  scenarioID, ok := parseUUIDParam(c, "scenarioId")
+  if !ok {
+    return
+  }
+  plan, err := sh.scenarioService.PromoteScenario(c.Request.Context(), nil, warehouseID, scenarioID)
+  if err != nil {
+    if errors.Is(err, services.ErrSlottingPlanStale) {
+      c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+      return
+    }
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusCreated, gin.H{"plan": plan})
+}
+
+func (sh *SlottingScenarioHandler) ListPlans(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  plans, err := sh.scenarioService.ListPlans(c.Request.Context(), nil, warehouseID)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"plans": plans})
+}
+
+func (sh *SlottingScenarioHandler) GetPlan(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  planID, ok := parseUUIDParam(c, "planId")
+  if !ok {
+    return
+  }
+  detail, err := sh.scenarioService.GetPlan(c.Request.Context(), nil, warehouseID, planID)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, detail)
+}
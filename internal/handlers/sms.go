@@ -0,0 +1,48 @@
+package handlers
+
+import (
+  "encoding/xml"
+  "errors"
+  "net/http"
+
+  "github.com/gin-gonic/gin"
+
+  "github.com/slotter-org/slotter-backend/internal/services"
+)
+
+type SMSHandler struct {
+  textService services.TextService
+}
+
+func NewSMSHandler(textService services.TextService) *SMSHandler {
+  return &SMSHandler{textService: textService}
+}
+
+type twimlResponse struct {
+  XMLName   xml.Name  `xml:"Response"`
+  Message   string    `xml:"Message,omitempty"`
+}
+
+// InboundSMS receives carrier callbacks for replies to our numbers and applies
+// STOP/START/HELP. Twilio expects TwiML back; other providers get JSON.
+func (sh *SMSHandler) InboundSMS(c *gin.Context) {
+  msg, err := sh.textService.ParseInbound(c.Request)
+  if errors.Is(err, services.ErrUnverifiedInboundSMS) {
+    c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+    return
+  }
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  result, err := sh.textService.HandleInbound(c.Request.Context(), nil, msg)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  if sh.textService.ProviderName() == services.SMSBackendTwilio {
+    c.XML(http.StatusOK, twimlResponse{Message: result.Reply})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"result": result})
+}
@@ -1,28 +1,38 @@
 package handlers
 
 import (
+  "context"
+  "errors"
   "net/http"
+  "strconv"
   "sync"
   
   "github.com/gin-gonic/gin"
   "github.com/google/uuid"
   
+  "github.com/slotter-org/slotter-backend/internal/channels"
   "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/presence"
   "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/services"
   "github.com/slotter-org/slotter-backend/internal/sse"
 )
 
 type SSEHandler struct {
   Log           *logger.Logger
   Hub           *sse.SSEHub
+  Channels      services.ChannelService
+  Presence      *presence.Tracker
   mu            sync.RWMutex
   userMap       map[uuid.UUID]*sse.SSEClient
 }
 
-func NewSSEHandler(log *logger.Logger, hub *sse.SSEHub) *SSEHandler {
+func NewSSEHandler(log *logger.Logger, hub *sse.SSEHub, channelService services.ChannelService, tracker *presence.Tracker) *SSEHandler {
   return &SSEHandler{
     Log:      log,
     Hub:      hub,
+    Channels: channelService,
+    Presence: tracker,
     userMap:  make(map[uuid.UUID]*sse.SSEClient),
   }
 }
@@ -44,15 +54,46 @@ func (h *SSEHandler) SSEStream(c *gin.Context) {
   client = h.Hub.NewSSEClient(userID)
   client.ID = uuid.New()
   client.Logger = h.Log.With("SSEClientID", client.ID)
+  client.LastEventID = lastEventID(c)
   h.userMap[userID] = client
   h.mu.Unlock()
 
+  if conn, ok := services.PresenceConnection(rd, presence.TransportSSE); ok {
+    conn.ID = client.ID
+    conn = h.Presence.Connect(c.Request.Context(), conn)
+    defer h.Presence.Disconnect(context.Background(), conn)
+  }
   h.Hub.ServeHTTP(c.Writer, c.Request, client)
 
+  // A reconnect of the same user has already closed this client and put
+  // its own in the map; only clean up while the entry is still ours.
   h.mu.Lock()
-  delete(h.userMap, userID)
-  h.mu.Unlock()
-  h.Hub.CloseClient(client)
+  defer h.mu.Unlock()
+  if h.userMap[userID] == client {
+    delete(h.userMap, userID)
+    h.Hub.CloseClient(client)
+  }
+}
+
+// CloseAll ends every open stream; the server calls it on shutdown.
+func (h *SSEHandler) CloseAll() {
+  h.mu.Lock()
+  defer h.mu.Unlock()
+  for userID, client := range h.userMap {
+    h.Hub.CloseClient(client)
+    delete(h.userMap, userID)
+  }
+}
+
+// lastEventID reads the Last-Event-ID header browsers send on reconnect,
+// or the lastEventId query parameter for clients that cannot set headers.
+func lastEventID(c *gin.Context) uint64 {
+  raw := c.GetHeader("Last-Event-ID")
+  if raw == "" {
+    raw = c.Query("lastEventId")
+  }
+  id, _ := strconv.ParseUint(raw, 10, 64)
+  return id
 }
 
 func (h *SSEHandler) SSESubscribe(c *gin.Context) {
@@ -77,6 +118,10 @@ func (h *SSEHandler) SSESubscribe(c *gin.Context) {
     c.JSON(http.StatusConflict, gin.H{"error": "no active SSE connection for this user"})
     return
   }
+  if err := h.Channels.Authorize(c.Request.Context(), req.Channel); err != nil {
+    c.JSON(channelErrorStatus(err), gin.H{"error": err.Error()})
+    return
+  }
   h.Hub.AddChannel(client, req.Channel)
   c.JSON(http.StatusOK, gin.H{"message": "subscribed", "channel": req.Channel})
 }
@@ -106,3 +151,14 @@ func (h *SSEHandler) SSEUnsubscribe(c *gin.Context) {
   h.Hub.RemoveChannel(client, req.Channel)
   c.JSON(http.StatusOK, gin.H{"message": "unsubscribed", "channel": req.Channel})
 }
+
+func channelErrorStatus(err error) int {
+  switch {
+  case errors.Is(err, channels.ErrUnknownChannel):
+    return http.StatusBadRequest
+  case errors.Is(err, channels.ErrForbidden):
+    return http.StatusForbidden
+  default:
+    return http.StatusInternalServerError
+  }
+}
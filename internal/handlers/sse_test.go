@@ -0,0 +1,57 @@
+package handlers
+
+import (
+  "bufio"
+  "context"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/gin-gonic/gin"
+  "github.com/google/uuid"
+  "go.uber.org/zap"
+
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/sse"
+)
+
+// TestSSEStreamShutdown opens a stream, waits for its Connected message
+// and checks the server then shuts down without waiting on it.
+func TestSSEStreamShutdown(t *testing.T) {
+  gin.SetMode(gin.TestMode)
+  log := &logger.Logger{SugaredLogger: zap.NewNop().Sugar()}
+  h := NewSSEHandler(log, sse.NewSSEHub(log), nil, nil)
+  router := gin.New()
+  router.GET("/events", func(c *gin.Context) {
+    rd := &requestdata.RequestData{UserID: uuid.New()}
+    c.Request = c.Request.WithContext(requestdata.WithRequestData(c.Request.Context(), rd))
+    h.SSEStream(c)
+  })
+  srv := httptest.NewUnstartedServer(router)
+  srv.Config.RegisterOnShutdown(h.CloseAll)
+  srv.Start()
+  defer srv.Close()
+
+  resp, err := http.Get(srv.URL + "/events")
+  if err != nil {
+    t.Fatalf("GET /events: %v", err)
+  }
+  defer resp.Body.Close()
+  scanner := bufio.NewScanner(resp.Body)
+  connected := false
+  for !connected && scanner.Scan() {
+    connected = strings.HasPrefix(scanner.Text(), "data: ") && strings.Contains(scanner.Text(), `"event":"Connected"`)
+  }
+  if !connected {
+    t.Fatalf("stream ended before its Connected message: %v", scanner.Err())
+  }
+
+  ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+  defer cancel()
+  if err := srv.Config.Shutdown(ctx); err != nil {
+    t.Fatalf("Shutdown() error = %v, want the open stream closed", err)
+  }
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+  "net/http"
+
+  "github.com/gin-gonic/gin"
+
+  "github.com/slotter-org/slotter-backend/internal/services"
+  "github.com/slotter-org/slotter-backend/internal/templates"
+)
+
+type TemplateHandler struct {
+  templateService services.TemplateService
+}
+
+func NewTemplateHandler(templateService services.TemplateService) *TemplateHandler {
+  return &TemplateHandler{templateService: templateService}
+}
+
+func (th *TemplateHandler) ListTemplates(c *gin.Context) {
+  c.JSON(http.StatusOK, gin.H{"templates": th.templateService.ListTemplates(c.Request.Context())})
+}
+
+// PreviewTemplate renders a message type with sample data and the caller's
+// tenant branding. ?format=html returns the raw email body for a browser tab;
+// anything else returns every rendered part as JSON.
+func (th *TemplateHandler) PreviewTemplate(c *gin.Context) {
+  msgType := templates.MessageType(c.Param("type"))
+  locale := c.Query("locale")
+  rendered, err := th.templateService.Preview(c.Request.Context(), nil, msgType, locale)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  if c.Query("format") == "html" {
+    c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(rendered.HTML))
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"preview": rendered})
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+  "context"
+  "errors"
+  "net/http"
+  "strconv"
+
+  "github.com/gin-gonic/gin"
+
+  "github.com/slotter-org/slotter-backend/internal/events"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/services"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type VelocityHandler struct {
+  velocityService   services.VelocityService
+  bus               *events.Bus
+}
+
+func NewVelocityHandler(velocityService services.VelocityService, bus *events.Bus) *VelocityHandler {
+  return &VelocityHandler{velocityService: velocityService, bus: bus}
+}
+
+type IngestOrderLinesRequest struct {
+  Lines           []services.OrderLineInput   `json:"lines"`
+}
+
+// IngestOrderLines handles POST /api/warehouses/:id/order-lines. A rejected
+// batch answers 422 with the report and stores nothing.
+func (vh *VelocityHandler) IngestOrderLines(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  var req IngestOrderLinesRequest
+  if err := c.ShouldBindJSON(&req); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+    return
+  }
+  report, err := vh.velocityService.IngestOrderLines(c.Request.Context(), nil, warehouseID, req.Lines)
+  vh.respondImport(c, report, err)
+}
+
+// ImportOrderLines handles POST /api/warehouses/:id/order-lines/import with a
+// .csv or .xlsx in the multipart "file" field. ?dryRun=true validates and
+// reports without writing anything.
+func (vh *VelocityHandler) ImportOrderLines(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+  c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxOrderLineImportBytes+multipartOverhead)
+  file, header, err := c.Request.FormFile("file")
+  if err != nil {
+    var maxErr *http.MaxBytesError
+    if errors.As(err, &maxErr) {
+      c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "order history file is too large"})
+      return
+    }
+    c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+    return
+  }
+  defer file.Close()
+
+  report, err := vh.velocityService.ImportOrderLines(c.Request.Context(), nil, warehouseID, header.Filename, file, dryRun)
+  vh.respondImport(c, report, err)
+}
+
+func (vh *VelocityHandler) respondImport(c *gin.Context, report *services.OrderLineImportReport, err error) {
+  if err != nil {
+    if errors.Is(err, services.ErrOrderLineImportInvalid) && report != nil {
+      c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "report": report})
+      return
+    }
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+// StartRun handles POST /api/warehouses/:id/velocity/runs. The run is
+// computed in the background; its result is announced on the websocket as
+// velocity_run_finished.
+func (vh *VelocityHandler) StartRun(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  var input services.VelocityRunInput
+  if c.Request.ContentLength != 0 {
+    if err := c.ShouldBindJSON(&input); err != nil {
+      c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+      return
+    }
+  }
+  run, err := vh.velocityService.StartRun(c.Request.Context(), nil, warehouseID, input)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusAccepted, gin.H{"run": run})
+}
+
+func (vh *VelocityHandler) ListRuns(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  runs, err := vh.velocityService.ListRuns(c.Request.Context(), nil, warehouseID)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+func (vh *VelocityHandler) GetRun(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  runID, ok := parseUUIDParam(c, "runId")
+  if !ok {
+    return
+  }
+  run, err := vh.velocityService.GetRun(c.Request.Context(), nil, warehouseID, runID)
+  if err != nil {
+    c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"run": run})
+}
+
+// ListVelocity handles GET /api/warehouses/:id/velocity: per-SKU stats of
+// runID (default the latest completed run), filtered by itemID, abc and xyz.
+func (vh *VelocityHandler) ListVelocity(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  runID, ok := parseUUIDQuery(c, "runID")
+  if !ok {
+    return
+  }
+  filter := repos.VelocityFilter{ABCClass: c.Query("abc"), XYZClass: c.Query("xyz")}
+  filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+  filter.Offset, _ = strconv.Atoi(c.Query("offset"))
+  if filter.ItemID, ok = parseUUIDQuery(c, "itemID"); !ok {
+    return
+  }
+  page, err := vh.velocityService.ListVelocity(c.Request.Context(), nil, warehouseID, runID, filter)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, page)
+}
+
+// ListAffinities handles GET /api/warehouses/:id/velocity/affinity with the
+// same runID default as ListVelocity and an optional itemID.
+func (vh *VelocityHandler) ListAffinities(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  runID, ok := parseUUIDQuery(c, "runID")
+  if !ok {
+    return
+  }
+  itemID, ok := parseUUIDQuery(c, "itemID")
+  if !ok {
+    return
+  }
+  run, affinities, err := vh.velocityService.ListAffinities(c.Request.Context(), nil, warehouseID, runID, itemID)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"run": run, "affinities": affinities})
+}
+
+// RunFinished is the VelocityService notifier; it tells the company's
+// clients that a background run completed or failed.
+func (vh *VelocityHandler) RunFinished(run *types.VelocityRun) {
+  vh.bus.Publish(context.Background(), events.New(events.VelocityRunFinished, run).ForCompany(run.CompanyID))
+}
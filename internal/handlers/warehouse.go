@@ -7,16 +7,14 @@ import (
     "github.com/google/uuid"
 
     "github.com/slotter-org/slotter-backend/internal/services"
-    "github.com/slotter-org/slotter-backend/internal/socket"
 )
 
 type WarehouseHandler struct {
     warehouseService services.WarehouseService
-    hub              *socket.Hub
 }
 
-func NewWarehouseHandler(warehouseService services.WarehouseService, hub *socket.Hub) *WarehouseHandler {
-    return &WarehouseHandler{warehouseService: warehouseService, hub: hub}
+func NewWarehouseHandler(warehouseService services.WarehouseService) *WarehouseHandler {
+    return &WarehouseHandler{warehouseService: warehouseService}
 }
 
 func (wh *WarehouseHandler) CreateWarehouse(c *gin.Context) {
@@ -49,15 +47,6 @@ func (wh *WarehouseHandler) CreateWarehouse(c *gin.Context) {
         return
     }
 
-    if warehouse.CompanyID != uuid.Nil {
-        wh.hub.BroadcastGlobal(c.Request.Context(), socket.Message{
-            Channel: "company:" + warehouse.CompanyID.String(),
-            Data: map[string]interface{}{
-                "action": "warehouse_created",
-                "payload": warehouse,
-            },
-        })
-    }
     c.JSON(http.StatusOK, gin.H{
         "success": true,
         "warehouse": warehouse,
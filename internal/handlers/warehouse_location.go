@@ -0,0 +1,179 @@
+package handlers
+
+import (
+  "errors"
+  "net/http"
+  "strconv"
+
+  "github.com/gin-gonic/gin"
+  "github.com/google/uuid"
+
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/services"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type WarehouseLocationHandler struct {
+  locationService   services.WarehouseLocationService
+}
+
+func NewWarehouseLocationHandler(locationService services.WarehouseLocationService) *WarehouseLocationHandler {
+  return &WarehouseLocationHandler{locationService: locationService}
+}
+
+// ListLocations handles GET /api/warehouses/:id/locations. Optional filters:
+// kind, locationType, parentID (or parentID=root for zones) and prefix, a
+// full code prefix such as "A-03".
+func (lh *WarehouseLocationHandler) ListLocations(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  filter := repos.LocationFilter{
+    Kind:         types.LocationKind(c.Query("kind")),
+    LocationType: types.LocationType(c.Query("locationType")),
+    CodePrefix:   c.Query("prefix"),
+  }
+  if parent := c.Query("parentID"); parent != "" {
+    if parent == "root" {
+      filter.RootsOnly = true
+    } else {
+      parentID, err := uuid.Parse(parent)
+      if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid parentID format"})
+        return
+      }
+      filter.ParentID = &parentID
+    }
+  }
+  locations, err := lh.locationService.ListLocations(c.Request.Context(), nil, warehouseID, filter)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"locations": locations})
+}
+
+func (lh *WarehouseLocationHandler) GetLocation(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  locationID, ok := parseUUIDParam(c, "locationId")
+  if !ok {
+    return
+  }
+  location, err := lh.locationService.GetLocation(c.Request.Context(), nil, warehouseID, locationID)
+  if err != nil {
+    c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"location": location})
+}
+
+type CreateLocationsRequest struct {
+  Locations       []services.LocationInput    `json:"locations"`
+}
+
+// CreateLocations handles POST /api/warehouses/:id/locations.
+func (lh *WarehouseLocationHandler) CreateLocations(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  var req CreateLocationsRequest
+  if err := c.ShouldBindJSON(&req); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+    return
+  }
+  locations, err := lh.locationService.CreateLocations(c.Request.Context(), nil, warehouseID, req.Locations)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusCreated, gin.H{"locations": locations})
+}
+
+// UpdateLocation handles PATCH /api/warehouses/:id/locations/:locationId.
+func (lh *WarehouseLocationHandler) UpdateLocation(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  locationID, ok := parseUUIDParam(c, "locationId")
+  if !ok {
+    return
+  }
+  var patch services.LocationPatch
+  if err := c.ShouldBindJSON(&patch); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+    return
+  }
+  location, err := lh.locationService.UpdateLocation(c.Request.Context(), nil, warehouseID, locationID, patch)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"location": location})
+}
+
+// DeleteLocation handles DELETE /api/warehouses/:id/locations/:locationId and
+// removes everything underneath the location as well.
+func (lh *WarehouseLocationHandler) DeleteLocation(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  locationID, ok := parseUUIDParam(c, "locationId")
+  if !ok {
+    return
+  }
+  if _, err := lh.locationService.DeleteLocation(c.Request.Context(), nil, warehouseID, locationID); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ImportLayout handles POST /api/warehouses/:id/layout/import with a .csv or
+// .xlsx in the multipart "file" field. ?dryRun=true validates and reports
+// without writing anything. A rejected import answers 422 with the report.
+func (lh *WarehouseLocationHandler) ImportLayout(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+  c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxLayoutImportBytes+multipartOverhead)
+  file, header, err := c.Request.FormFile("file")
+  if err != nil {
+    var maxErr *http.MaxBytesError
+    if errors.As(err, &maxErr) {
+      c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "layout file is too large"})
+      return
+    }
+    c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+    return
+  }
+  defer file.Close()
+
+  report, err := lh.locationService.ImportLayout(c.Request.Context(), nil, warehouseID, header.Filename, file, dryRun)
+  if err != nil {
+    if errors.Is(err, services.ErrLayoutImportInvalid) && report != nil {
+      c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "report": report})
+      return
+    }
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+func parseUUIDParam(c *gin.Context, name string) (uuid.UUID, bool) {
+  id, err := uuid.Parse(c.Param(name))
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name + " format"})
+    return uuid.Nil, false
+  }
+  return id, true
+}
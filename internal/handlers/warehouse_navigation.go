@@ -0,0 +1,76 @@
+package handlers
+
+import (
+  "errors"
+  "net/http"
+
+  "github.com/gin-gonic/gin"
+
+  "github.com/slotter-org/slotter-backend/internal/services"
+)
+
+type WarehouseNavigationHandler struct {
+  navigationService   services.WarehouseNavigationService
+}
+
+func NewWarehouseNavigationHandler(navigationService services.WarehouseNavigationService) *WarehouseNavigationHandler {
+  return &WarehouseNavigationHandler{navigationService: navigationService}
+}
+
+// GetNavigation handles GET /api/warehouses/:id/navigation.
+func (nh *WarehouseNavigationHandler) GetNavigation(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  detail, err := nh.navigationService.GetNavigation(c.Request.Context(), nil, warehouseID)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, detail)
+}
+
+// UpdateNavigation handles PUT /api/warehouses/:id/navigation. The body
+// replaces the whole navigation.
+func (nh *WarehouseNavigationHandler) UpdateNavigation(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  var input services.NavigationInput
+  if err := c.ShouldBindJSON(&input); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+    return
+  }
+  detail, err := nh.navigationService.UpdateNavigation(c.Request.Context(), nil, warehouseID, input)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, detail)
+}
+
+// EstimateRoute handles POST /api/warehouses/:id/route-estimate. It answers
+// 422 when one-way aisles make the route impossible to walk.
+func (nh *WarehouseNavigationHandler) EstimateRoute(c *gin.Context) {
+  warehouseID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  var req services.RouteEstimateRequest
+  if err := c.ShouldBindJSON(&req); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+    return
+  }
+  estimate, err := nh.navigationService.EstimateRoute(c.Request.Context(), nil, warehouseID, req)
+  if err != nil {
+    if errors.Is(err, services.ErrRouteUnreachable) {
+      c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+      return
+    }
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, estimate)
+}
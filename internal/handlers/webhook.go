@@ -0,0 +1,64 @@
+package handlers
+
+import (
+  "errors"
+  "net/http"
+
+  "github.com/gin-gonic/gin"
+
+  "github.com/slotter-org/slotter-backend/internal/services"
+)
+
+type WebhookHandler struct {
+  webhookService  services.WebhookService
+}
+
+func NewWebhookHandler(webhookService services.WebhookService) *WebhookHandler {
+  return &WebhookHandler{webhookService: webhookService}
+}
+
+// ListEndpoints handles GET /api/webhooks.
+func (wh *WebhookHandler) ListEndpoints(c *gin.Context) {
+  endpoints, err := wh.webhookService.List(c.Request.Context(), nil)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusOK, gin.H{"endpoints": endpoints})
+}
+
+// CreateEndpoint handles POST /api/webhooks. The signing secret is only
+// ever returned here.
+func (wh *WebhookHandler) CreateEndpoint(c *gin.Context) {
+  var req struct {
+    URL         string    `json:"url" binding:"required"`
+    EventTypes  []string  `json:"eventTypes"`
+  }
+  if err := c.ShouldBindJSON(&req); err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  endpoint, err := wh.webhookService.Create(c.Request.Context(), nil, req.URL, req.EventTypes)
+  if err != nil {
+    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    return
+  }
+  c.JSON(http.StatusCreated, gin.H{"endpoint": endpoint, "secret": endpoint.Secret})
+}
+
+// DeleteEndpoint handles DELETE /api/webhooks/:id.
+func (wh *WebhookHandler) DeleteEndpoint(c *gin.Context) {
+  endpointID, ok := parseUUIDParam(c, "id")
+  if !ok {
+    return
+  }
+  if err := wh.webhookService.Delete(c.Request.Context(), nil, endpointID); err != nil {
+    status := http.StatusBadRequest
+    if errors.Is(err, services.ErrWebhookEndpointNotFound) {
+      status = http.StatusNotFound
+    }
+    c.JSON(status, gin.H{"error": err.Error()})
+    return
+  }
+  c.Status(http.StatusNoContent)
+}
@@ -8,7 +8,9 @@ import (
   "github.com/gorilla/websocket"
   
   "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/presence"
   "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/services"
   "github.com/slotter-org/slotter-backend/internal/socket"
 )
 
@@ -36,7 +38,7 @@ func WsHandlerr(hub *socket.Hub, log *logger.Logger) gin.HandlerFunc {
   }
 }
 
-func WsHandler(hub *socket.Hub, log *logger.Logger) gin.HandlerFunc {
+func WsHandler(hub *socket.Hub, log *logger.Logger, tracker *presence.Tracker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		rd := requestdata.GetRequestData(c.Request.Context())
 		if rd == nil || rd.UserID == [16]byte{} {
@@ -50,7 +52,20 @@ func WsHandler(hub *socket.Hub, log *logger.Logger) gin.HandlerFunc {
 		}
 		client := socket.NewClient(conn, hub, log)
 
-		go client.Run(context.Background())
+		// The connection outlives the request, so keep only who it belongs to
+		// for the subscription checks.
+		ctx := requestdata.WithRequestData(context.Background(), rd)
+		presenceConn, present := services.PresenceConnection(rd, presence.TransportWebsocket)
+		if present {
+			presenceConn.ID = client.ID
+			presenceConn = tracker.Connect(ctx, presenceConn)
+		}
+		go func() {
+			client.Run(ctx)
+			if present {
+				tracker.Disconnect(context.Background(), presenceConn)
+			}
+		}()
 	}
 }
 
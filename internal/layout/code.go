@@ -0,0 +1,138 @@
+package layout
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// CodeSeparator joins the per-level segments of a location code: A-03-02-B.
+const CodeSeparator = "-"
+
+const maxSegmentLength = 10
+
+// letterKinds are numbered A, B, ... Z, AA, AB ...; every other kind uses
+// zero-padded numbers (01, 02 ... 99, 100).
+var letterKinds = map[types.LocationKind]bool{
+	types.LocationKindZone:  true,
+	types.LocationKindLevel: true,
+}
+
+func IsValidKind(kind types.LocationKind) bool {
+	return depth(kind) >= 0
+}
+
+func IsValidLocationType(t types.LocationType) bool {
+	switch t {
+	case types.LocationTypePickFace, types.LocationTypeReserve, types.LocationTypeFloor, types.LocationTypeBulk:
+		return true
+	}
+	return false
+}
+
+// ParentKind is the kind directly above kind, or false for zones.
+func ParentKind(kind types.LocationKind) (types.LocationKind, bool) {
+	d := depth(kind)
+	if d <= 0 {
+		return "", false
+	}
+	return types.LocationKinds[d-1], true
+}
+
+// ChildKind is the kind directly below kind, or false for positions.
+func ChildKind(kind types.LocationKind) (types.LocationKind, bool) {
+	d := depth(kind)
+	if d < 0 || d == len(types.LocationKinds)-1 {
+		return "", false
+	}
+	return types.LocationKinds[d+1], true
+}
+
+func depth(kind types.LocationKind) int {
+	for i, k := range types.LocationKinds {
+		if k == kind {
+			return i
+		}
+	}
+	return -1
+}
+
+// FormatSegment renders the n-th (1-based) code of kind.
+func FormatSegment(kind types.LocationKind, n int) (string, error) {
+	if n < 1 {
+		return "", fmt.Errorf("location sequence must be at least 1, got %d", n)
+	}
+	if letterKinds[kind] {
+		var b []byte
+		for n > 0 {
+			n--
+			b = append([]byte{byte('A' + n%26)}, b...)
+			n /= 26
+		}
+		return string(b), nil
+	}
+	return fmt.Sprintf("%02d", n), nil
+}
+
+// ParseSegment is the inverse of FormatSegment. It reports false for codes
+// the generator would not have produced, such as hand-entered "DOCK".
+func ParseSegment(kind types.LocationKind, code string) (int, bool) {
+	if code == "" {
+		return 0, false
+	}
+	if letterKinds[kind] {
+		n := 0
+		for _, r := range code {
+			if r < 'A' || r > 'Z' {
+				return 0, false
+			}
+			n = n*26 + int(r-'A') + 1
+		}
+		return n, true
+	}
+	n, err := strconv.Atoi(code)
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return n, true
+}
+
+// NormalizeSegment upper-cases a user supplied code and checks it only holds
+// letters and digits, so it cannot be confused with the separator.
+func NormalizeSegment(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return "", fmt.Errorf("location code cannot be empty")
+	}
+	if len(code) > maxSegmentLength {
+		return "", fmt.Errorf("location code %q is longer than %d characters", code, maxSegmentLength)
+	}
+	for _, r := range code {
+		if !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') {
+			return "", fmt.Errorf("location code %q may only contain letters and digits", code)
+		}
+	}
+	return code, nil
+}
+
+// JoinCode appends code to its parent's full code.
+func JoinCode(parentFullCode, code string) string {
+	if parentFullCode == "" {
+		return code
+	}
+	return parentFullCode + CodeSeparator + code
+}
+
+// NextSequence returns the sequence after the highest generated code among
+// siblings, so new codes never collide with existing ones.
+func NextSequence(kind types.LocationKind, siblingCodes []string) int {
+	max := 0
+	for _, c := range siblingCodes {
+		if n, ok := ParseSegment(kind, c); ok && n > max {
+			max = n
+		}
+	}
+	return max + 1
+}
@@ -0,0 +1,128 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/slotter-org/slotter-backend/internal/types"
+)
+
+func TestFormatAndParseSegment(t *testing.T) {
+	tests := []struct {
+		kind types.LocationKind
+		n    int
+		code string
+	}{
+		{kind: types.LocationKindZone, n: 1, code: "A"},
+		{kind: types.LocationKindZone, n: 26, code: "Z"},
+		{kind: types.LocationKindZone, n: 27, code: "AA"},
+		{kind: types.LocationKindZone, n: 52, code: "AZ"},
+		{kind: types.LocationKindLevel, n: 703, code: "AAA"},
+		{kind: types.LocationKindAisle, n: 1, code: "01"},
+		{kind: types.LocationKindBay, n: 99, code: "99"},
+		{kind: types.LocationKindPosition, n: 100, code: "100"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.kind)+"/"+tt.code, func(t *testing.T) {
+			code, err := FormatSegment(tt.kind, tt.n)
+			if err != nil {
+				t.Fatalf("FormatSegment(%s, %d) error = %v", tt.kind, tt.n, err)
+			}
+			if code != tt.code {
+				t.Errorf("FormatSegment(%s, %d) = %q, want %q", tt.kind, tt.n, code, tt.code)
+			}
+			n, ok := ParseSegment(tt.kind, tt.code)
+			if !ok || n != tt.n {
+				t.Errorf("ParseSegment(%s, %q) = %d, %v, want %d, true", tt.kind, tt.code, n, ok, tt.n)
+			}
+		})
+	}
+
+	if _, err := FormatSegment(types.LocationKindAisle, 0); err == nil {
+		t.Error("FormatSegment(aisle, 0) error = nil, want an error")
+	}
+}
+
+func TestParseSegmentRejectsHandEnteredCodes(t *testing.T) {
+	tests := []struct {
+		kind types.LocationKind
+		code string
+	}{
+		{kind: types.LocationKindZone, code: ""},
+		{kind: types.LocationKindZone, code: "A1"},
+		{kind: types.LocationKindLevel, code: "b"},
+		{kind: types.LocationKindAisle, code: "DOCK"},
+		{kind: types.LocationKindBay, code: "00"},
+		{kind: types.LocationKindPosition, code: "-3"},
+	}
+	for _, tt := range tests {
+		if n, ok := ParseSegment(tt.kind, tt.code); ok {
+			t.Errorf("ParseSegment(%s, %q) = %d, true, want false", tt.kind, tt.code, n)
+		}
+	}
+}
+
+func TestNextSequence(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     types.LocationKind
+		siblings []string
+		want     int
+	}{
+		{name: "no siblings", kind: types.LocationKindAisle, want: 1},
+		{name: "after the highest", kind: types.LocationKindAisle, siblings: []string{"01", "07", "03"}, want: 8},
+		{name: "hand-entered codes ignored", kind: types.LocationKindAisle, siblings: []string{"02", "DOCK"}, want: 3},
+		{name: "letters", kind: types.LocationKindZone, siblings: []string{"A", "Z"}, want: 27},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NextSequence(tt.kind, tt.siblings); got != tt.want {
+				t.Errorf("NextSequence() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeSegment(t *testing.T) {
+	tests := []struct {
+		code    string
+		want    string
+		wantErr bool
+	}{
+		{code: " a1 ", want: "A1"},
+		{code: "dock", want: "DOCK"},
+		{code: "", wantErr: true},
+		{code: "A-1", wantErr: true},
+		{code: "ÄB", wantErr: true},
+		{code: "ABCDEFGHIJK", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeSegment(tt.code)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NormalizeSegment(%q) = %q, %v, want %q, error %v", tt.code, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestKindHierarchy(t *testing.T) {
+	if _, ok := ParentKind(types.LocationKindZone); ok {
+		t.Error("ParentKind(zone) reported a parent")
+	}
+	if _, ok := ChildKind(types.LocationKindPosition); ok {
+		t.Error("ChildKind(position) reported a child")
+	}
+	if k, ok := ParentKind(types.LocationKindBay); !ok || k != types.LocationKindAisle {
+		t.Errorf("ParentKind(bay) = %q, %v, want aisle", k, ok)
+	}
+	if k, ok := ChildKind(types.LocationKindBay); !ok || k != types.LocationKindLevel {
+		t.Errorf("ChildKind(bay) = %q, %v, want level", k, ok)
+	}
+	if IsValidKind("shelf") {
+		t.Error("IsValidKind(shelf) = true")
+	}
+	if got := JoinCode("A-01", "03"); got != "A-01-03" {
+		t.Errorf("JoinCode() = %q, want A-01-03", got)
+	}
+	if got := JoinCode("", "A"); got != "A" {
+		t.Errorf("JoinCode() = %q, want A", got)
+	}
+}
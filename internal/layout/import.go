@@ -0,0 +1,340 @@
+package layout
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+
+	"github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// MaxImportRows caps the data rows accepted by a single layout import.
+const MaxImportRows = 50000
+
+// ImportRow is one data row of a layout import, already converted to
+// centimetres and kilograms.
+type ImportRow struct {
+	Row          int
+	FullCode     string
+	Segments     []string
+	Kind         types.LocationKind
+	Name         string
+	LocationType types.LocationType
+	WidthCm      float64
+	DepthCm      float64
+	HeightCm     float64
+	MaxWeightKg  float64
+	PickSequence int
+}
+
+// RowError points at a problem in the uploaded sheet. Row is the 1-based line
+// as a spreadsheet shows it, so the header is row 1.
+type RowError struct {
+	Row     int    `json:"row"`
+	Code    string `json:"code,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+var lengthUnitsToCm = map[string]float64{
+	"mm": 0.1,
+	"cm": 1,
+	"m":  100,
+	"in": 2.54,
+	"ft": 30.48,
+}
+
+var weightUnitsToKg = map[string]float64{
+	"g":  0.001,
+	"kg": 1,
+	"t":  1000,
+	"lb": 0.45359237,
+}
+
+var columnAliases = map[string]string{
+	"code":            "code",
+	"location":        "code",
+	"location_code":   "code",
+	"full_code":       "code",
+	"kind":            "kind",
+	"name":            "name",
+	"type":            "location_type",
+	"location_type":   "location_type",
+	"width":           "width",
+	"depth":           "depth",
+	"height":          "height",
+	"max_weight":      "max_weight",
+	"weight_capacity": "max_weight",
+	"dim_unit":        "dim_unit",
+	"dimension_unit":  "dim_unit",
+	"weight_unit":     "weight_unit",
+	"pick_sequence":   "pick_sequence",
+	"sequence":        "pick_sequence",
+}
+
+const (
+	// maxUnzipBytes bounds how much an .xlsx may decompress to in total, so
+	// a small upload cannot expand into gigabytes.
+	maxUnzipBytes = 256 << 20
+	// maxUnzipXMLBytes is how much of a worksheet is unzipped in memory;
+	// larger ones are spooled to a temporary file.
+	maxUnzipXMLBytes = 16 << 20
+)
+
+// ReadSheet returns the cells of a .csv file or of the first worksheet of an
+// .xlsx workbook. It stops after the header and maxRows+1 data rows, so the
+// caller can tell the sheet is over its limit without reading all of it.
+func ReadSheet(filename string, r io.Reader, maxRows int) ([][]string, error) {
+	limit := maxRows + 2
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		var records [][]string
+		for len(records) < limit {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read csv: %w", err)
+			}
+			records = append(records, record)
+		}
+		if len(records) > 0 && len(records[0]) > 0 {
+			records[0][0] = strings.TrimPrefix(records[0][0], "\ufeff")
+		}
+		return records, nil
+	case ".xlsx":
+		f, err := excelize.OpenReader(r, excelize.Options{UnzipSizeLimit: maxUnzipBytes, UnzipXMLSizeLimit: maxUnzipXMLBytes})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open xlsx: %w", err)
+		}
+		defer f.Close()
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, fmt.Errorf("workbook has no sheets")
+		}
+		rows, err := f.Rows(sheets[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sheet %q: %w", sheets[0], err)
+		}
+		defer rows.Close()
+		var records [][]string
+		for len(records) < limit && rows.Next() {
+			record, err := rows.Columns()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read sheet %q: %w", sheets[0], err)
+			}
+			records = append(records, record)
+		}
+		if err := rows.Error(); err != nil {
+			return nil, fmt.Errorf("failed to read sheet %q: %w", sheets[0], err)
+		}
+		return records, nil
+	}
+	return nil, fmt.Errorf("unsupported file type %q, expected .csv or .xlsx", filepath.Ext(filename))
+}
+
+// importColumn is a recognised header. unit is set when the header carries
+// one, as in "width_mm" or "max_weight_lb", and overrides the row's unit
+// columns.
+type importColumn struct {
+	index int
+	unit  string
+}
+
+// ParseImport validates the sheet row by row. Hard problems with the sheet
+// itself (no code column, too many rows) are returned as error; everything
+// else is collected so the caller can report every bad row at once.
+func ParseImport(records [][]string) ([]ImportRow, []RowError, error) {
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("file is empty")
+	}
+	columns, err := parseImportHeader(records[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(records)-1 > MaxImportRows {
+		return nil, nil, fmt.Errorf("file has %d rows, the limit is %d", len(records)-1, MaxImportRows)
+	}
+
+	var rows []ImportRow
+	var rowErrs []RowError
+	seen := map[string]int{}
+	for i, record := range records[1:] {
+		line := i + 2
+		if isBlankRecord(record) {
+			continue
+		}
+		row, errs := parseImportRow(line, record, columns)
+		if len(errs) > 0 {
+			rowErrs = append(rowErrs, errs...)
+			continue
+		}
+		if first, ok := seen[row.FullCode]; ok {
+			rowErrs = append(rowErrs, RowError{Row: line, Code: row.FullCode, Field: "code", Message: fmt.Sprintf("duplicate of row %d", first)})
+			continue
+		}
+		seen[row.FullCode] = line
+		rows = append(rows, row)
+	}
+	return rows, rowErrs, nil
+}
+
+func parseImportHeader(header []string) (map[string]importColumn, error) {
+	columns := map[string]importColumn{}
+	for i, raw := range header {
+		name := strings.ToLower(strings.TrimSpace(raw))
+		name = strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+		if name == "" {
+			continue
+		}
+		key, unit := name, ""
+		if _, ok := columnAliases[key]; !ok {
+			if idx := strings.LastIndex(name, "_"); idx > 0 {
+				base, suffix := name[:idx], name[idx+1:]
+				if _, known := columnAliases[base]; known && isUnitFor(columnAliases[base], suffix) {
+					key, unit = base, suffix
+				}
+			}
+		}
+		canonical, ok := columnAliases[key]
+		if !ok {
+			continue
+		}
+		if _, dup := columns[canonical]; dup {
+			return nil, fmt.Errorf("column %q appears more than once", canonical)
+		}
+		columns[canonical] = importColumn{index: i, unit: unit}
+	}
+	if _, ok := columns["code"]; !ok {
+		return nil, fmt.Errorf("missing required column \"code\"")
+	}
+	return columns, nil
+}
+
+func isUnitFor(column, unit string) bool {
+	switch column {
+	case "width", "depth", "height":
+		_, ok := lengthUnitsToCm[unit]
+		return ok
+	case "max_weight":
+		_, ok := weightUnitsToKg[unit]
+		return ok
+	}
+	return false
+}
+
+func parseImportRow(line int, record []string, columns map[string]importColumn) (ImportRow, []RowError) {
+	cell := func(name string) string {
+		col, ok := columns[name]
+		if !ok || col.index >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[col.index])
+	}
+	row := ImportRow{Row: line}
+	var errs []RowError
+	fail := func(field, format string, args ...interface{}) {
+		errs = append(errs, RowError{Row: line, Code: row.FullCode, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	rawCode := cell("code")
+	if rawCode == "" {
+		fail("code", "code is required")
+		return row, errs
+	}
+	parts := strings.Split(rawCode, CodeSeparator)
+	if len(parts) > len(types.LocationKinds) {
+		row.FullCode = strings.ToUpper(rawCode)
+		fail("code", "code has %d segments, at most %d are allowed (zone-aisle-bay-level-position)", len(parts), len(types.LocationKinds))
+		return row, errs
+	}
+	for _, p := range parts {
+		segment, err := NormalizeSegment(p)
+		if err != nil {
+			row.FullCode = strings.ToUpper(rawCode)
+			fail("code", "%s", err.Error())
+			return row, errs
+		}
+		row.Segments = append(row.Segments, segment)
+	}
+	row.FullCode = strings.Join(row.Segments, CodeSeparator)
+	row.Kind = types.LocationKinds[len(row.Segments)-1]
+
+	if kind := types.LocationKind(strings.ToLower(cell("kind"))); kind != "" && kind != row.Kind {
+		fail("kind", "kind %q does not match code %s, which is a %s", kind, row.FullCode, row.Kind)
+	}
+	row.Name = cell("name")
+	if lt := types.LocationType(strings.ToLower(cell("location_type"))); lt != "" {
+		if !IsValidLocationType(lt) {
+			fail("location_type", "unknown location type %q", lt)
+		} else {
+			row.LocationType = lt
+		}
+	}
+
+	dimUnit := strings.ToLower(cell("dim_unit"))
+	if dimUnit == "" {
+		dimUnit = "cm"
+	} else if _, ok := lengthUnitsToCm[dimUnit]; !ok {
+		fail("dim_unit", "unknown dimension unit %q, expected one of mm, cm, m, in, ft", dimUnit)
+	}
+	weightUnit := strings.ToLower(cell("weight_unit"))
+	if weightUnit == "" {
+		weightUnit = "kg"
+	} else if _, ok := weightUnitsToKg[weightUnit]; !ok {
+		fail("weight_unit", "unknown weight unit %q, expected one of g, kg, t, lb", weightUnit)
+	}
+
+	measure := func(field string, units map[string]float64, rowUnit string) float64 {
+		raw := cell(field)
+		if raw == "" {
+			return 0
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			fail(field, "%q is not a number", raw)
+			return 0
+		}
+		if v < 0 {
+			fail(field, "%s cannot be negative", field)
+			return 0
+		}
+		unit := rowUnit
+		if columns[field].unit != "" {
+			unit = columns[field].unit
+		}
+		return v * units[unit]
+	}
+	row.WidthCm = measure("width", lengthUnitsToCm, dimUnit)
+	row.DepthCm = measure("depth", lengthUnitsToCm, dimUnit)
+	row.HeightCm = measure("height", lengthUnitsToCm, dimUnit)
+	row.MaxWeightKg = measure("max_weight", weightUnitsToKg, weightUnit)
+
+	if raw := cell("pick_sequence"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			fail("pick_sequence", "%q is not a non-negative whole number", raw)
+		} else {
+			row.PickSequence = n
+		}
+	}
+	return row, errs
+}
+
+func isBlankRecord(record []string) bool {
+	for _, c := range record {
+		if strings.TrimSpace(c) != "" {
+			return false
+		}
+	}
+	return true
+}
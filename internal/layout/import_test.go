@@ -0,0 +1,213 @@
+package layout
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"github.com/slotter-org/slotter-backend/internal/types"
+)
+
+func TestParseImportUnits(t *testing.T) {
+	tests := []struct {
+		name       string
+		records    [][]string
+		wantWidth  float64
+		wantWeight float64
+	}{
+		{
+			name:       "defaults to cm and kg",
+			records:    [][]string{{"code", "width", "max_weight"}, {"A", "120", "500"}},
+			wantWidth:  120,
+			wantWeight: 500,
+		},
+		{
+			name:       "unit columns",
+			records:    [][]string{{"code", "width", "max_weight", "dim_unit", "weight_unit"}, {"A", "1.2", "1000", "m", "lb"}},
+			wantWidth:  120,
+			wantWeight: 453.59237,
+		},
+		{
+			name:       "unit suffixes",
+			records:    [][]string{{"Location Code", "Width mm", "max-weight-t"}, {"A", "1200", "0.5"}},
+			wantWidth:  120,
+			wantWeight: 500,
+		},
+		{
+			name:       "suffix beats the unit column",
+			records:    [][]string{{"code", "width_in", "dim_unit", "weight_capacity_g"}, {"A", "10", "m", "2500"}},
+			wantWidth:  25.4,
+			wantWeight: 2.5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, rowErrs, err := ParseImport(tt.records)
+			if err != nil || len(rowErrs) > 0 {
+				t.Fatalf("ParseImport() errors = %v, %v", err, rowErrs)
+			}
+			if len(rows) != 1 {
+				t.Fatalf("got %d rows, want 1", len(rows))
+			}
+			if !approx(rows[0].WidthCm, tt.wantWidth) {
+				t.Errorf("width = %v cm, want %v", rows[0].WidthCm, tt.wantWidth)
+			}
+			if !approx(rows[0].MaxWeightKg, tt.wantWeight) {
+				t.Errorf("max weight = %v kg, want %v", rows[0].MaxWeightKg, tt.wantWeight)
+			}
+		})
+	}
+}
+
+func TestParseImportRows(t *testing.T) {
+	records := [][]string{
+		{"code", "kind", "name", "type", "pick_sequence"},
+		{"a", "zone", "Ambient", "", ""},
+		{"A-01-02", "bay", "", "", ""},
+		{"", "", "", "", ""},
+		{"A-01-02-b-03", "", "", "pick_face", "12"},
+	}
+	rows, rowErrs, err := ParseImport(records)
+	if err != nil || len(rowErrs) > 0 {
+		t.Fatalf("ParseImport() errors = %v, %v", err, rowErrs)
+	}
+	want := []ImportRow{
+		{Row: 2, FullCode: "A", Segments: []string{"A"}, Kind: types.LocationKindZone, Name: "Ambient"},
+		{Row: 3, FullCode: "A-01-02", Segments: []string{"A", "01", "02"}, Kind: types.LocationKindBay},
+		{Row: 5, FullCode: "A-01-02-B-03", Segments: []string{"A", "01", "02", "B", "03"}, Kind: types.LocationKindPosition, LocationType: types.LocationTypePickFace, PickSequence: 12},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(rows), len(want))
+	}
+	for i := range want {
+		if fmt.Sprint(rows[i]) != fmt.Sprint(want[i]) {
+			t.Errorf("row %d = %+v, want %+v", i, rows[i], want[i])
+		}
+	}
+}
+
+func TestParseImportRowErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		record    []string
+		wantField string
+	}{
+		{name: "missing code", record: []string{"", "", "1", "", ""}, wantField: "code"},
+		{name: "too many segments", record: []string{"A-01-01-A-01-01", "", "", "", ""}, wantField: "code"},
+		{name: "bad segment", record: []string{"A-0_1", "", "", "", ""}, wantField: "code"},
+		{name: "kind mismatch", record: []string{"A-01", "zone", "", "", ""}, wantField: "kind"},
+		{name: "unknown dim unit", record: []string{"A", "", "1", "yd", ""}, wantField: "dim_unit"},
+		{name: "not a number", record: []string{"A", "", "wide", "", ""}, wantField: "width"},
+		{name: "negative", record: []string{"A", "", "-1", "", ""}, wantField: "width"},
+		{name: "bad pick sequence", record: []string{"A", "", "", "", "1.5"}, wantField: "pick_sequence"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := [][]string{{"code", "kind", "width", "dim_unit", "pick_sequence"}, tt.record}
+			rows, rowErrs, err := ParseImport(records)
+			if err != nil {
+				t.Fatalf("ParseImport() error = %v", err)
+			}
+			if len(rows) != 0 || len(rowErrs) != 1 {
+				t.Fatalf("got %d rows and errors %v, want one error", len(rows), rowErrs)
+			}
+			if rowErrs[0].Row != 2 || rowErrs[0].Field != tt.wantField {
+				t.Errorf("error = %+v, want row 2 field %q", rowErrs[0], tt.wantField)
+			}
+		})
+	}
+}
+
+func TestParseImportDuplicate(t *testing.T) {
+	_, rowErrs, err := ParseImport([][]string{{"code"}, {"A-01"}, {"a-01"}})
+	if err != nil {
+		t.Fatalf("ParseImport() error = %v", err)
+	}
+	if len(rowErrs) != 1 || rowErrs[0].Row != 3 || rowErrs[0].Message != "duplicate of row 2" {
+		t.Errorf("errors = %+v, want row 3 as a duplicate of row 2", rowErrs)
+	}
+}
+
+func TestParseImportSheetErrors(t *testing.T) {
+	tooMany := [][]string{{"code"}}
+	for i := 0; i <= MaxImportRows; i++ {
+		tooMany = append(tooMany, []string{fmt.Sprint("A", i)})
+	}
+	tests := []struct {
+		name    string
+		records [][]string
+	}{
+		{name: "empty", records: nil},
+		{name: "no code column", records: [][]string{{"name", "width"}, {"A", "1"}}},
+		{name: "duplicate column", records: [][]string{{"code", "location"}, {"A", "B"}}},
+		{name: "too many rows", records: tooMany},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ParseImport(tt.records); err == nil {
+				t.Error("ParseImport() error = nil, want an error")
+			}
+		})
+	}
+}
+
+// TestReadSheetStopsAfterLimit reads the header and one row past the limit,
+// which is enough for ParseImport to reject the sheet.
+func TestReadSheetStopsAfterLimit(t *testing.T) {
+	const maxRows, total = 3, 10
+
+	var csv strings.Builder
+	csv.WriteString("\ufeffcode,width\n")
+	for i := 1; i <= total; i++ {
+		fmt.Fprintf(&csv, "A%d,%d\n", i, i)
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+	sheet := f.GetSheetName(0)
+	if err := f.SetSheetRow(sheet, "A1", &[]string{"code", "width"}); err != nil {
+		t.Fatalf("SetSheetRow: %v", err)
+	}
+	for i := 1; i <= total; i++ {
+		if err := f.SetSheetRow(sheet, fmt.Sprintf("A%d", i+1), &[]interface{}{fmt.Sprint("A", i), i}); err != nil {
+			t.Fatalf("SetSheetRow: %v", err)
+		}
+	}
+	xlsx, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatalf("WriteToBuffer: %v", err)
+	}
+
+	tests := []struct {
+		filename string
+		content  string
+	}{
+		{filename: "layout.csv", content: csv.String()},
+		{filename: "Layout.XLSX", content: xlsx.String()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			records, err := ReadSheet(tt.filename, strings.NewReader(tt.content), maxRows)
+			if err != nil {
+				t.Fatalf("ReadSheet() error = %v", err)
+			}
+			if len(records) != maxRows+2 {
+				t.Fatalf("read %d records, want %d", len(records), maxRows+2)
+			}
+			if records[0][0] != "code" || records[1][0] != "A1" || records[1][1] != "1" {
+				t.Errorf("records start %q, want the header then A1", records[:2])
+			}
+		})
+	}
+
+	if _, err := ReadSheet("layout.txt", strings.NewReader(""), maxRows); err == nil {
+		t.Error("ReadSheet(.txt) error = nil, want an error")
+	}
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
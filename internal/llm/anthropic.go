@@ -0,0 +1,242 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AnthropicName is the name of the Anthropic provider.
+const AnthropicName = "anthropic"
+
+const (
+	anthropicURL     = "https://api.anthropic.com/v1/messages"
+	anthropicVersion = "2023-06-01"
+)
+
+// Anthropic streams turns from the Anthropic Messages API.
+type Anthropic struct {
+	apiKey string
+	model  string
+	url    string
+	client *http.Client
+}
+
+// NewAnthropic needs an API key and a model name. baseURL may be empty for
+// the public endpoint.
+func NewAnthropic(apiKey string, model string, baseURL string) (*Anthropic, error) {
+	if strings.TrimSpace(apiKey) == "" {
+		return nil, fmt.Errorf("anthropic api key is required")
+	}
+	if strings.TrimSpace(model) == "" {
+		return nil, fmt.Errorf("anthropic model is required")
+	}
+	if baseURL == "" {
+		baseURL = anthropicURL
+	}
+	return &Anthropic{
+		apiKey: apiKey,
+		model:  model,
+		url:    baseURL,
+		client: &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (a *Anthropic) Name() string {
+	return AnthropicName
+}
+
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+	Tools     []anthropicTool    `json:"tools,omitempty"`
+	Stream    bool               `json:"stream"`
+}
+
+type anthropicEvent struct {
+	Type         string          `json:"type"`
+	Index        int             `json:"index"`
+	ContentBlock *anthropicBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (a *Anthropic) Stream(ctx context.Context, req Request, onToken func(text string) error) (*Response, error) {
+	if len(req.Messages) == 0 {
+		return nil, ErrNoMessages
+	}
+	body := anthropicRequest{
+		Model:     a.model,
+		MaxTokens: req.MaxTokens,
+		System:    req.System,
+		Messages:  anthropicMessages(req.Messages),
+		Stream:    true,
+	}
+	if body.MaxTokens <= 0 {
+		body.MaxTokens = DefaultMaxTokens
+	}
+	for _, t := range req.Tools {
+		body.Tools = append(body.Tools, anthropicTool{Name: t.Name, Description: t.Description, InputSchema: t.Parameters})
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode anthropic request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("content-type", "application/json")
+	httpReq.Header.Set("accept", "text/event-stream")
+	httpReq.Header.Set("x-api-key", a.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+
+	httpResp, err := a.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(httpResp.Body, 4096))
+		return nil, fmt.Errorf("anthropic returned %s: %s", httpResp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	resp := &Response{StopReason: StopEnd}
+	var text strings.Builder
+	calls := map[int]*ToolCall{}
+	inputs := map[int]*strings.Builder{}
+	var order []int
+
+	scanner := bufio.NewScanner(httpResp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var ev anthropicEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &ev); err != nil {
+			return nil, fmt.Errorf("failed to decode anthropic event: %w", err)
+		}
+		switch ev.Type {
+		case "content_block_start":
+			if ev.ContentBlock != nil && ev.ContentBlock.Type == "tool_use" {
+				calls[ev.Index] = &ToolCall{ID: ev.ContentBlock.ID, Name: ev.ContentBlock.Name}
+				inputs[ev.Index] = &strings.Builder{}
+				order = append(order, ev.Index)
+			}
+		case "content_block_delta":
+			switch ev.Delta.Type {
+			case "text_delta":
+				text.WriteString(ev.Delta.Text)
+				if err := onToken(ev.Delta.Text); err != nil {
+					return nil, err
+				}
+			case "input_json_delta":
+				if b := inputs[ev.Index]; b != nil {
+					b.WriteString(ev.Delta.PartialJSON)
+				}
+			}
+		case "message_delta":
+			switch ev.Delta.StopReason {
+			case "tool_use":
+				resp.StopReason = StopToolUse
+			case "max_tokens":
+				resp.StopReason = StopMaxTokens
+			}
+		case "error":
+			if ev.Error != nil {
+				return nil, fmt.Errorf("anthropic stream error: %s", ev.Error.Message)
+			}
+			return nil, fmt.Errorf("anthropic stream error")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed reading anthropic stream: %w", err)
+	}
+
+	resp.Content = text.String()
+	for _, i := range order {
+		call := calls[i]
+		args := strings.TrimSpace(inputs[i].String())
+		if args == "" {
+			args = "{}"
+		}
+		call.Arguments = json.RawMessage(args)
+		resp.ToolCalls = append(resp.ToolCalls, *call)
+	}
+	return resp, nil
+}
+
+// anthropicMessages maps a conversation onto the Messages API, where tool
+// results are user content and consecutive ones share a message.
+func anthropicMessages(messages []Message) []anthropicMessage {
+	var out []anthropicMessage
+	for _, m := range messages {
+		switch m.Role {
+		case RoleTool:
+			block := anthropicBlock{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content, IsError: m.IsError}
+			if n := len(out); n > 0 && out[n-1].Role == string(RoleUser) && out[n-1].Content[0].Type == "tool_result" {
+				out[n-1].Content = append(out[n-1].Content, block)
+				continue
+			}
+			out = append(out, anthropicMessage{Role: string(RoleUser), Content: []anthropicBlock{block}})
+		case RoleAssistant:
+			msg := anthropicMessage{Role: string(RoleAssistant)}
+			if m.Content != "" {
+				msg.Content = append(msg.Content, anthropicBlock{Type: "text", Text: m.Content})
+			}
+			for _, c := range m.ToolCalls {
+				input := c.Arguments
+				if len(input) == 0 {
+					input = json.RawMessage("{}")
+				}
+				msg.Content = append(msg.Content, anthropicBlock{Type: "tool_use", ID: c.ID, Name: c.Name, Input: input})
+			}
+			if len(msg.Content) > 0 {
+				out = append(out, msg)
+			}
+		default:
+			out = append(out, anthropicMessage{Role: string(RoleUser), Content: []anthropicBlock{{Type: "text", Text: m.Content}}})
+		}
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+// Package llm is the chat model behind the slotting assistant. A Provider
+// streams one assistant turn at a time: text arrives as token events, and a
+// turn may end by asking for tools, whose results the caller sends back in
+// the next request.
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool"
+)
+
+// ToolCall is a request from the model to run a tool. Arguments is a JSON
+// object matching the tool's Parameters.
+type ToolCall struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// Message is one turn of a conversation. Assistant messages may carry
+// ToolCalls; a tool message answers the call named by ToolCallID.
+type Message struct {
+	Role       Role
+	Content    string
+	ToolCalls  []ToolCall
+	ToolCallID string
+	IsError    bool
+}
+
+// Tool describes a tool the model may call. Parameters is a JSON schema of
+// an object.
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+type Request struct {
+	System    string
+	Messages  []Message
+	Tools     []Tool
+	MaxTokens int
+}
+
+type StopReason string
+
+const (
+	StopEnd       StopReason = "end"
+	StopToolUse   StopReason = "tool_use"
+	StopMaxTokens StopReason = "max_tokens"
+)
+
+// Response is a finished assistant turn.
+type Response struct {
+	Content    string
+	ToolCalls  []ToolCall
+	StopReason StopReason
+}
+
+// Provider is a chat model. Stream calls onToken with each piece of text
+// as it arrives and returns the whole turn; an error from onToken stops the
+// stream and is returned.
+type Provider interface {
+	Name() string
+	Stream(ctx context.Context, req Request, onToken func(text string) error) (*Response, error)
+}
+
+// DefaultMaxTokens bounds a turn when the request does not.
+const DefaultMaxTokens = 1024
+
+// ErrNoMessages is returned for a request without messages.
+var ErrNoMessages = errors.New("llm request has no messages")
@@ -0,0 +1,123 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// StubName is the name of the Stub provider.
+const StubName = "stub"
+
+// Stub is a deterministic local provider for development and tests. It
+// answers a user message by echoing it, except that "/tool <name> <json>"
+// calls that tool with the JSON object (or {}) as arguments. After tool
+// results it reports what each tool returned. A title request is answered
+// with the first words of the question. Text streams a word at a time.
+type Stub struct{}
+
+func NewStub() *Stub {
+	return &Stub{}
+}
+
+func (s *Stub) Name() string {
+	return StubName
+}
+
+func (s *Stub) Stream(ctx context.Context, req Request, onToken func(text string) error) (*Response, error) {
+	if len(req.Messages) == 0 {
+		return nil, ErrNoMessages
+	}
+	resp := &Response{StopReason: StopEnd}
+	last := req.Messages[len(req.Messages)-1]
+	switch {
+	case req.System == TitleSystem:
+		question, _, _ := strings.Cut(strings.TrimPrefix(last.Content, "User: "), "\n")
+		words := strings.Fields(strings.TrimPrefix(question, "/tool "))
+		if len(words) > 6 {
+			words = words[:6]
+		}
+		resp.Content = strings.Join(words, " ")
+	case last.Role == RoleTool:
+		var results []string
+		for i := len(req.Messages) - 1; i >= 0 && req.Messages[i].Role == RoleTool; i-- {
+			m := req.Messages[i]
+			status := "returned"
+			if m.IsError {
+				status = "failed with"
+			}
+			results = append([]string{fmt.Sprintf("%s %s: %s", toolName(req.Messages[:i], m.ToolCallID), status, truncate(m.Content, 500))}, results...)
+		}
+		resp.Content = "Here is what I found.\n" + strings.Join(results, "\n")
+	default:
+		text := strings.TrimSpace(last.Content)
+		if call, ok := stubToolCall(text, req.Tools, len(req.Messages)); ok {
+			resp.ToolCalls = []ToolCall{call}
+			resp.StopReason = StopToolUse
+			return resp, nil
+		}
+		resp.Content = "You said: " + text
+	}
+
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+	words := strings.SplitAfter(resp.Content, " ")
+	if len(words) > maxTokens {
+		words = words[:maxTokens]
+		resp.Content = strings.Join(words, "")
+		resp.StopReason = StopMaxTokens
+	}
+	for _, w := range words {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := onToken(w); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// stubToolCall parses "/tool <name> <json>" for a tool in tools. Call IDs
+// depend only on the conversation length, so replays are identical.
+func stubToolCall(text string, tools []Tool, turn int) (ToolCall, bool) {
+	if !strings.HasPrefix(text, "/tool ") {
+		return ToolCall{}, false
+	}
+	fields := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(text, "/tool ")), " ", 2)
+	args := "{}"
+	if len(fields) == 2 && strings.TrimSpace(fields[1]) != "" {
+		args = strings.TrimSpace(fields[1])
+	}
+	if !json.Valid([]byte(args)) {
+		return ToolCall{}, false
+	}
+	for _, t := range tools {
+		if t.Name == fields[0] {
+			return ToolCall{ID: fmt.Sprintf("stub_call_%d", turn), Name: t.Name, Arguments: json.RawMessage(args)}, true
+		}
+	}
+	return ToolCall{}, false
+}
+
+func toolName(history []Message, callID string) string {
+	for i := len(history) - 1; i >= 0; i-- {
+		for _, c := range history[i].ToolCalls {
+			if c.ID == callID {
+				return c.Name
+			}
+		}
+	}
+	return "tool"
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
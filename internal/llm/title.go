@@ -0,0 +1,39 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// TitleSystem is the system prompt of a title request.
+const TitleSystem = "You name conversations. Reply with a title of at most six words for the conversation below, without quotes or punctuation at the end."
+
+const (
+	titleMaxTokens = 24
+	titleMaxRunes  = 60
+)
+
+// Title asks p for a short title of a conversation that started with
+// question and answer.
+func Title(ctx context.Context, p Provider, question string, answer string) (string, error) {
+	req := Request{
+		System:    TitleSystem,
+		Messages:  []Message{{Role: RoleUser, Content: fmt.Sprintf("User: %s\nAssistant: %s", truncate(question, 2000), truncate(answer, 2000))}},
+		MaxTokens: titleMaxTokens,
+	}
+	resp, err := p.Stream(ctx, req, func(string) error { return nil })
+	if err != nil {
+		return "", err
+	}
+	title := strings.TrimSpace(resp.Content)
+	if i := strings.IndexByte(title, '\n'); i >= 0 {
+		title = title[:i]
+	}
+	title = strings.Trim(strings.TrimSpace(title), "\"'`*#.:")
+	title = strings.TrimSpace(strings.TrimPrefix(title, "Title:"))
+	if title == "" {
+		return "", fmt.Errorf("%s returned an empty title", p.Name())
+	}
+	return truncate(title, titleMaxRunes), nil
+}
@@ -10,7 +10,6 @@ import (
 
   "github.com/slotter-org/slotter-backend/internal/logger"
   "github.com/slotter-org/slotter-backend/internal/requestdata"
-  "github.com/slotter-org/slotter-backend/internal/ssedata"
   "github.com/slotter-org/slotter-backend/internal/errordata"
   "github.com/slotter-org/slotter-backend/internal/repos"
   "github.com/slotter-org/slotter-backend/internal/services"
@@ -29,33 +28,42 @@ func NewAuthMiddleware(log *logger.Logger, authService services.AuthService, rol
 
 func (am *AuthMiddleware) RequireAuth() gin.HandlerFunc {
   return func(c *gin.Context) {
-    tokenString := extractTokenFromAll(c)
-    am.log.Debug("TokenString:", "tokenstring", tokenString)
-    if tokenString == "" {
-      c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing or invalid token"})
+    if !am.authenticate(c) {
       return
     }
-    ctx, err := am.authService.SetContextFromToken(c.Request.Context(), tokenString)
-    if err != nil {
-      c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-      return
-    }
-    ctx = ssedata.WithSSEData(ctx)
-    ctx = errordata.WithErrorData(ctx)
-    c.Request = c.Request.WithContext(ctx)
-    rd := requestdata.GetRequestData(ctx)
-    if rd == nil || rd.UserID == uuid.Nil {
-      c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden - invalid user id"})
-      return
-    } 
     c.Next()
   }
 }
 
+// authenticate resolves the request's token into request data on the
+// request context. It aborts and reports false when the token is missing
+// or invalid, and never calls Next, so callers can run their own checks
+// before the handler.
+func (am *AuthMiddleware) authenticate(c *gin.Context) bool {
+  tokenString := extractTokenFromAll(c)
+  am.log.Debug("TokenString:", "tokenstring", tokenString)
+  if tokenString == "" {
+    c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing or invalid token"})
+    return false
+  }
+  ctx, err := am.authService.SetContextFromToken(c.Request.Context(), tokenString)
+  if err != nil {
+    c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+    return false
+  }
+  ctx = errordata.WithErrorData(ctx)
+  c.Request = c.Request.WithContext(ctx)
+  rd := requestdata.GetRequestData(ctx)
+  if rd == nil || rd.UserID == uuid.Nil {
+    c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden - invalid user id"})
+    return false
+  }
+  return true
+}
+
 func (am *AuthMiddleware) RequirePermission(permission string) gin.HandlerFunc {
   return func(c *gin.Context) {
-    am.RequireAuth()(c)
-    if c.IsAborted() {
+    if !am.authenticate(c) {
       return
     }
     ctx := c.Request.Context()
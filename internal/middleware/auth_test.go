@@ -0,0 +1,89 @@
+package middleware
+
+import (
+  "context"
+  "errors"
+  "net/http"
+  "net/http/httptest"
+  "testing"
+
+  "github.com/gin-gonic/gin"
+  "github.com/google/uuid"
+  "go.uber.org/zap"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/services"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type fakeAuthService struct {
+  services.AuthService
+  rd                *requestdata.RequestData
+}
+
+func (f *fakeAuthService) SetContextFromToken(ctx context.Context, tokenString string) (context.Context, error) {
+  if tokenString != "good" {
+    return ctx, errors.New("invalid token")
+  }
+  return requestdata.WithRequestData(ctx, f.rd), nil
+}
+
+type fakeRoleRepo struct {
+  repos.RoleRepo
+  role              *types.Role
+}
+
+func (f *fakeRoleRepo) GetByIDs(ctx context.Context, tx *gorm.DB, roleIDs []uuid.UUID) ([]*types.Role, error) {
+  if f.role == nil {
+    return nil, nil
+  }
+  return []*types.Role{f.role}, nil
+}
+
+func TestRequirePermission(t *testing.T) {
+  gin.SetMode(gin.TestMode)
+  log := &logger.Logger{SugaredLogger: zap.NewNop().Sugar()}
+  rd := &requestdata.RequestData{UserID: uuid.New(), RoleID: uuid.New()}
+  allowed := &types.Role{Permissions: []*types.Permission{{PermissionType: "manage_webhooks"}}}
+  denied := &types.Role{Permissions: []*types.Permission{{PermissionType: "view_audit_log"}}}
+
+  tests := []struct {
+    name          string
+    token         string
+    role          *types.Role
+    wantStatus    int
+    wantHandler   bool
+  }{
+    {name: "missing token", token: "", role: allowed, wantStatus: http.StatusUnauthorized},
+    {name: "invalid token", token: "bad", role: allowed, wantStatus: http.StatusUnauthorized},
+    {name: "role not found", token: "good", role: nil, wantStatus: http.StatusForbidden},
+    {name: "missing permission", token: "good", role: denied, wantStatus: http.StatusForbidden},
+    {name: "has permission", token: "good", role: allowed, wantStatus: http.StatusNoContent, wantHandler: true},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      am := NewAuthMiddleware(log, &fakeAuthService{rd: rd}, &fakeRoleRepo{role: tt.role})
+      ran := false
+      router := gin.New()
+      router.POST("/", am.RequirePermission("manage_webhooks"), func(c *gin.Context) {
+        ran = true
+        c.Status(http.StatusNoContent)
+      })
+      req := httptest.NewRequest(http.MethodPost, "/", nil)
+      if tt.token != "" {
+        req.Header.Set("Authorization", "Bearer "+tt.token)
+      }
+      w := httptest.NewRecorder()
+      router.ServeHTTP(w, req)
+      if w.Code != tt.wantStatus {
+        t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+      }
+      if ran != tt.wantHandler {
+        t.Errorf("handler ran = %v, want %v", ran, tt.wantHandler)
+      }
+    })
+  }
+}
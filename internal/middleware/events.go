@@ -0,0 +1,30 @@
+package middleware
+
+import (
+  "net/http"
+
+  "github.com/gin-gonic/gin"
+
+  "github.com/slotter-org/slotter-backend/internal/events"
+)
+
+// PublishEvents collects the domain events services record while a request
+// runs and publishes them once it has succeeded. Services commit before
+// their handler answers, so nothing is delivered for a change that was
+// rolled back or rejected. journal keeps the events for webhooks and the
+// audit log in the services' own transactions.
+func PublishEvents(bus *events.Bus, journal events.Journal) gin.HandlerFunc {
+  return func(c *gin.Context) {
+    ctx := events.WithCollector(c.Request.Context(), journal)
+    c.Request = c.Request.WithContext(ctx)
+    c.Next()
+    collected := events.GetCollector(ctx).Drain()
+    if len(collected) == 0 || c.Writer.Status() >= http.StatusBadRequest {
+      return
+    }
+    if journal != nil {
+      journal.Notify()
+    }
+    bus.Publish(c.Request.Context(), collected...)
+  }
+}
@@ -0,0 +1,45 @@
+package normalization
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseGTIN accepts a UPC-A (12), EAN-8, EAN-13 or GTIN-14 barcode, checks
+// its check digit and returns it left-padded to 14 digits, so the same
+// product matches however it was scanned. Spaces and dashes are ignored.
+func ParseGTIN(input string) (string, error) {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(input))
+	if digits == "" {
+		return "", fmt.Errorf("GTIN is empty")
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return "", fmt.Errorf("invalid GTIN '%s': only digits are allowed", input)
+		}
+	}
+	switch len(digits) {
+	case 8, 12, 13, 14:
+	default:
+		return "", fmt.Errorf("invalid GTIN '%s': expected 8, 12, 13 or 14 digits", input)
+	}
+	gtin := strings.Repeat("0", 14-len(digits)) + digits
+	if gtinCheckDigit(gtin[:13]) != gtin[13] {
+		return "", fmt.Errorf("invalid GTIN '%s': check digit does not match", input)
+	}
+	return gtin, nil
+}
+
+// gtinCheckDigit is the GS1 mod-10 check digit for the first 13 digits of a
+// GTIN-14: weights alternate 3,1 starting from the left.
+func gtinCheckDigit(body string) byte {
+	sum := 0
+	for i := 0; i < len(body); i++ {
+		d := int(body[i] - '0')
+		if i%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
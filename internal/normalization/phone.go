@@ -0,0 +1,44 @@
+package normalization
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+// DefaultPhoneRegion is the region assumed for numbers entered without a
+// country code. main overrides it from PHONE_DEFAULT_REGION.
+var DefaultPhoneRegion = "US"
+
+// ParsePhoneNumber returns input in E.164 form (e.g. "+15555550100").
+func ParsePhoneNumber(input string) (string, error) {
+	trimmed := strings.TrimSpace(input)
+	if trimmed == "" {
+		return "", fmt.Errorf("phone number is empty")
+	}
+	if strings.HasPrefix(trimmed, "00") {
+		trimmed = "+" + strings.TrimPrefix(trimmed, "00")
+	}
+	num, err := phonenumbers.Parse(trimmed, strings.ToUpper(DefaultPhoneRegion))
+	if err != nil {
+		return "", fmt.Errorf("invalid phone number '%s': %w", input, err)
+	}
+	if !phonenumbers.IsPossibleNumber(num) {
+		return "", fmt.Errorf("invalid phone number '%s'", input)
+	}
+	return phonenumbers.Format(num, phonenumbers.E164), nil
+}
+
+// ParsePhoneNumberPtr normalizes a nullable phone number. nil and blank
+// values come back as nil so they are stored as NULL.
+func ParsePhoneNumberPtr(input *string) (*string, error) {
+	if input == nil || strings.TrimSpace(*input) == "" {
+		return nil, nil
+	}
+	normalized, err := ParsePhoneNumber(*input)
+	if err != nil {
+		return nil, err
+	}
+	return &normalized, nil
+}
@@ -0,0 +1,48 @@
+package normalization
+
+import "testing"
+
+func TestParsePhoneNumber(t *testing.T) {
+	tests := []struct {
+		name    string
+		region  string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "e164", region: "US", input: "+15555550100", want: "+15555550100"},
+		{name: "e164 with formatting", region: "US", input: " +1 (555) 555-0100 ", want: "+15555550100"},
+		{name: "international prefix", region: "US", input: "0044 20 7946 0958", want: "+442079460958"},
+		{name: "national in the default region", region: "US", input: "555.555.0100", want: "+15555550100"},
+		{name: "national in another default region", region: "gb", input: "020 7946 0958", want: "+442079460958"},
+		{name: "e164 ignores the default region", region: "GB", input: "+15555550100", want: "+15555550100"},
+		{name: "empty", region: "US", input: "  ", wantErr: true},
+		{name: "letters", region: "US", input: "call me", wantErr: true},
+		{name: "too short", region: "US", input: "555-01", wantErr: true},
+		{name: "too long", region: "US", input: "+1555555010012345", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(region string) { DefaultPhoneRegion = region }(DefaultPhoneRegion)
+			DefaultPhoneRegion = tt.region
+			got, err := ParsePhoneNumber(tt.input)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ParsePhoneNumber(%q) = %q, %v, want %q, error %v", tt.input, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParsePhoneNumberPtr(t *testing.T) {
+	blank := " "
+	if got, err := ParsePhoneNumberPtr(&blank); got != nil || err != nil {
+		t.Errorf("ParsePhoneNumberPtr(blank) = %v, %v, want nil", got, err)
+	}
+	if got, err := ParsePhoneNumberPtr(nil); got != nil || err != nil {
+		t.Errorf("ParsePhoneNumberPtr(nil) = %v, %v, want nil", got, err)
+	}
+	in := "+1 555 555 0100"
+	if got, err := ParsePhoneNumberPtr(&in); err != nil || got == nil || *got != "+15555550100" {
+		t.Errorf("ParsePhoneNumberPtr(%q) = %v, %v, want +15555550100", in, got, err)
+	}
+}
@@ -0,0 +1,117 @@
+package presence
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryEntry struct {
+	conn      Connection
+	expiresAt time.Time
+}
+
+// MemoryStore keeps connections in process, for single-instance setups.
+type MemoryStore struct {
+	mu      sync.Mutex
+	tenants map[string]map[uuid.UUID]*memoryEntry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tenants: make(map[string]map[uuid.UUID]*memoryEntry)}
+}
+
+func (s *MemoryStore) Add(ctx context.Context, c Connection, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	live := s.live(c.Tenant, time.Now())
+	first := !hasOther(live, c)
+	entries, ok := s.tenants[c.Tenant]
+	if !ok {
+		entries = make(map[uuid.UUID]*memoryEntry)
+		s.tenants[c.Tenant] = entries
+	}
+	entries[c.ID] = &memoryEntry{conn: c, expiresAt: expiresAt}
+	return first, nil
+}
+
+func (s *MemoryStore) Touch(ctx context.Context, conns []Connection, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range conns {
+		if e, ok := s.tenants[c.Tenant][c.ID]; ok {
+			e.expiresAt = expiresAt
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) SetViewing(ctx context.Context, c Connection) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.tenants[c.Tenant][c.ID]
+	if !ok || e.conn.UserID != c.UserID || e.expiresAt.Before(time.Now()) {
+		return ErrConnectionNotFound
+	}
+	e.conn.CompanyID = c.CompanyID
+	e.conn.WarehouseID = c.WarehouseID
+	e.conn.ScenarioID = c.ScenarioID
+	return nil
+}
+
+func (s *MemoryStore) Remove(ctx context.Context, c Connection) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := s.tenants[c.Tenant]
+	if _, ok := entries[c.ID]; !ok {
+		return false, nil
+	}
+	delete(entries, c.ID)
+	if len(entries) == 0 {
+		delete(s.tenants, c.Tenant)
+	}
+	return !hasOther(s.live(c.Tenant, time.Now()), c), nil
+}
+
+func (s *MemoryStore) List(ctx context.Context, tenant string) ([]Connection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.live(tenant, time.Now()), nil
+}
+
+func (s *MemoryStore) Expire(ctx context.Context, now time.Time) ([]Connection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var expired []Connection
+	for tenant, entries := range s.tenants {
+		for id, e := range entries {
+			if e.expiresAt.Before(now) {
+				expired = append(expired, e.conn)
+				delete(entries, id)
+			}
+		}
+		if len(entries) == 0 {
+			delete(s.tenants, tenant)
+		}
+	}
+	var left []Connection
+	for _, c := range expired {
+		if !hasOther(s.live(c.Tenant, now), c) && !hasOther(left, c) {
+			left = append(left, c)
+		}
+	}
+	return left, nil
+}
+
+// live must be called with s.mu held.
+func (s *MemoryStore) live(tenant string, now time.Time) []Connection {
+	var out []Connection
+	for _, e := range s.tenants[tenant] {
+		if !e.expiresAt.Before(now) {
+			out = append(out, e.conn)
+		}
+	}
+	return out
+}
@@ -0,0 +1,124 @@
+// Package presence tracks which users are connected over SSE or websockets
+// and what they are looking at. Every connection is stored with an expiry
+// that the instance holding it keeps pushing out; connections of an
+// instance that dies simply lapse and are swept by the others.
+package presence
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultTTL is how long a connection stays present without a heartbeat.
+const DefaultTTL = 45 * time.Second
+
+const (
+	TransportSSE       = "sse"
+	TransportWebsocket = "websocket"
+)
+
+// ErrConnectionNotFound is returned when a connection is not live, or not
+// the given user's, in the given tenant.
+var ErrConnectionNotFound = errors.New("presence connection not found")
+
+// Connection is one live SSE or websocket connection. Tenant is the
+// channel name of the company or WMS the user belongs to, which is also
+// where joins and leaves are announced. CompanyID is the company of the
+// open warehouse, so a WMS user working in a company can be listed there.
+type Connection struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"userID"`
+	Tenant      string     `json:"tenant"`
+	Transport   string     `json:"transport"`
+	CompanyID   *uuid.UUID `json:"companyID,omitempty"`
+	WarehouseID *uuid.UUID `json:"warehouseID,omitempty"`
+	ScenarioID  *uuid.UUID `json:"scenarioID,omitempty"`
+	ConnectedAt time.Time  `json:"connectedAt"`
+}
+
+// Store keeps connections across instances.
+type Store interface {
+	// Add stores c until expiresAt and reports whether it is its user's
+	// first live connection in the tenant.
+	Add(ctx context.Context, c Connection, expiresAt time.Time) (bool, error)
+	// Touch pushes the expiry of conns out to expiresAt.
+	Touch(ctx context.Context, conns []Connection, expiresAt time.Time) error
+	// SetViewing copies what c is looking at onto the live connection
+	// with c's ID, which must be c's user's in c's tenant, or returns
+	// ErrConnectionNotFound.
+	SetViewing(ctx context.Context, c Connection) error
+	// Remove deletes c and reports whether that left its user without a
+	// live connection in the tenant. Removing a connection that is already
+	// gone reports false, so a leave is only ever reported once.
+	Remove(ctx context.Context, c Connection) (bool, error)
+	// List returns the live connections of tenant.
+	List(ctx context.Context, tenant string) ([]Connection, error)
+	// Expire removes the connections that lapsed before now and returns
+	// those that left their user without a live connection.
+	Expire(ctx context.Context, now time.Time) ([]Connection, error)
+}
+
+// Member is one present user, folded from their connections.
+type Member struct {
+	UserID      uuid.UUID  `json:"userID"`
+	Transports  []string   `json:"transports"`
+	Connections int        `json:"connections"`
+	WarehouseID *uuid.UUID `json:"warehouseID,omitempty"`
+	ScenarioID  *uuid.UUID `json:"scenarioID,omitempty"`
+	ConnectedAt time.Time  `json:"connectedAt"`
+}
+
+// Members folds conns into one Member per user, longest connected first.
+func Members(conns []Connection) []Member {
+	byUser := make(map[uuid.UUID]*Member)
+	var order []uuid.UUID
+	for _, c := range conns {
+		m, ok := byUser[c.UserID]
+		if !ok {
+			m = &Member{UserID: c.UserID, ConnectedAt: c.ConnectedAt}
+			byUser[c.UserID] = m
+			order = append(order, c.UserID)
+		}
+		m.Connections++
+		if !containsString(m.Transports, c.Transport) {
+			m.Transports = append(m.Transports, c.Transport)
+		}
+		if c.ConnectedAt.Before(m.ConnectedAt) {
+			m.ConnectedAt = c.ConnectedAt
+		}
+		if c.WarehouseID != nil {
+			m.WarehouseID = c.WarehouseID
+			m.ScenarioID = c.ScenarioID
+		}
+	}
+	out := make([]Member, 0, len(order))
+	for _, id := range order {
+		out = append(out, *byUser[id])
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].ConnectedAt.Before(out[j].ConnectedAt) })
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOther reports whether conns holds another connection of c's user in
+// c's tenant.
+func hasOther(conns []Connection, c Connection) bool {
+	for _, other := range conns {
+		if other.UserID == c.UserID && other.Tenant == c.Tenant && other.ID != c.ID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,235 @@
+package presence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/slotter-org/slotter-backend/internal/logger"
+)
+
+// connGrace keeps a connection's record a while past its expiry so the
+// sweep can still report who left.
+const connGrace = time.Minute
+
+// RedisStore shares connections between instances. Each tenant has a
+// sorted set of connection IDs scored by expiry; each connection's record
+// sits in its own key.
+type RedisStore struct {
+	log    *logger.Logger
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisStore(log *logger.Logger, address, password, prefix string) (*RedisStore, error) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     address,
+		Password: password,
+		DB:       0,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("redis ping failed: %w", err)
+	}
+	return &RedisStore{
+		log:    log.With("component", "RedisPresenceStore"),
+		client: rdb,
+		prefix: prefix,
+	}, nil
+}
+
+func (s *RedisStore) Add(ctx context.Context, c Connection, expiresAt time.Time) (bool, error) {
+	live, err := s.List(ctx, c.Tenant)
+	if err != nil {
+		return false, err
+	}
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode presence connection: %w", err)
+	}
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, s.connKey(c.ID.String()), payload, time.Until(expiresAt)+connGrace)
+	pipe.ZAdd(ctx, s.tenantKey(c.Tenant), redis.Z{Score: score(expiresAt), Member: c.ID.String()})
+	pipe.SAdd(ctx, s.tenantsKey(), c.Tenant)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("failed to store presence connection: %w", err)
+	}
+	return !hasOther(live, c), nil
+}
+
+func (s *RedisStore) Touch(ctx context.Context, conns []Connection, expiresAt time.Time) error {
+	if len(conns) == 0 {
+		return nil
+	}
+	pipe := s.client.Pipeline()
+	for _, c := range conns {
+		pipe.ZAddXX(ctx, s.tenantKey(c.Tenant), redis.Z{Score: score(expiresAt), Member: c.ID.String()})
+		pipe.PExpireAt(ctx, s.connKey(c.ID.String()), expiresAt.Add(connGrace))
+		pipe.SAdd(ctx, s.tenantsKey(), c.Tenant)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to refresh presence connections: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) SetViewing(ctx context.Context, c Connection) error {
+	live, err := s.client.ZScore(ctx, s.tenantKey(c.Tenant), c.ID.String()).Result()
+	if err == redis.Nil || (err == nil && live < score(time.Now())) {
+		return ErrConnectionNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load presence connection: %w", err)
+	}
+	stored, err := s.load(ctx, []string{c.ID.String()})
+	if err != nil {
+		return err
+	}
+	if len(stored) == 0 || stored[0].UserID != c.UserID || stored[0].Tenant != c.Tenant {
+		return ErrConnectionNotFound
+	}
+	conn := stored[0]
+	conn.CompanyID = c.CompanyID
+	conn.WarehouseID = c.WarehouseID
+	conn.ScenarioID = c.ScenarioID
+	payload, err := json.Marshal(conn)
+	if err != nil {
+		return fmt.Errorf("failed to encode presence connection: %w", err)
+	}
+	err = s.client.SetArgs(ctx, s.connKey(c.ID.String()), payload, redis.SetArgs{KeepTTL: true, Mode: "XX"}).Err()
+	if err == redis.Nil {
+		return ErrConnectionNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update presence connection: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) Remove(ctx context.Context, c Connection) (bool, error) {
+	removed, err := s.client.ZRem(ctx, s.tenantKey(c.Tenant), c.ID.String()).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to remove presence connection: %w", err)
+	}
+	if err := s.client.Del(ctx, s.connKey(c.ID.String())).Err(); err != nil {
+		s.log.Warn("Failed to delete presence connection record", "connectionID", c.ID, "error", err)
+	}
+	if removed == 0 {
+		return false, nil
+	}
+	live, err := s.List(ctx, c.Tenant)
+	if err != nil {
+		return false, err
+	}
+	return !hasOther(live, c), nil
+}
+
+func (s *RedisStore) List(ctx context.Context, tenant string) ([]Connection, error) {
+	ids, err := s.client.ZRangeByScore(ctx, s.tenantKey(tenant), &redis.ZRangeBy{
+		Min: strconv.FormatFloat(score(time.Now()), 'f', 0, 64),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list presence connections: %w", err)
+	}
+	return s.load(ctx, ids)
+}
+
+// Expire claims every lapsed connection with ZREM, so when several
+// instances sweep at once each connection is reported by exactly one.
+func (s *RedisStore) Expire(ctx context.Context, now time.Time) ([]Connection, error) {
+	tenants, err := s.client.SMembers(ctx, s.tenantsKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list presence tenants: %w", err)
+	}
+	var left []Connection
+	for _, tenant := range tenants {
+		key := s.tenantKey(tenant)
+		ids, err := s.client.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+			Min: "-inf",
+			Max: "(" + strconv.FormatFloat(score(now), 'f', 0, 64),
+		}).Result()
+		if err != nil {
+			return left, fmt.Errorf("failed to list lapsed presence connections: %w", err)
+		}
+		var claimed []string
+		for _, id := range ids {
+			if n, err := s.client.ZRem(ctx, key, id).Result(); err == nil && n > 0 {
+				claimed = append(claimed, id)
+			}
+		}
+		if len(claimed) > 0 {
+			expired, err := s.load(ctx, claimed)
+			if err != nil {
+				return left, err
+			}
+			live, err := s.List(ctx, tenant)
+			if err != nil {
+				return left, err
+			}
+			for _, c := range expired {
+				if !hasOther(live, c) && !hasOther(left, c) {
+					left = append(left, c)
+				}
+			}
+			keys := make([]string, 0, len(claimed))
+			for _, id := range claimed {
+				keys = append(keys, s.connKey(id))
+			}
+			s.client.Del(ctx, keys...)
+		}
+		if n, err := s.client.ZCard(ctx, key).Result(); err == nil && n == 0 {
+			s.client.SRem(ctx, s.tenantsKey(), tenant)
+		}
+	}
+	return left, nil
+}
+
+func (s *RedisStore) load(ctx context.Context, ids []string) ([]Connection, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, s.connKey(id))
+	}
+	raw, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load presence connections: %w", err)
+	}
+	conns := make([]Connection, 0, len(raw))
+	for _, v := range raw {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var c Connection
+		if err := json.Unmarshal([]byte(str), &c); err != nil {
+			s.log.Warn("Skipping undecodable presence connection", "error", err)
+			continue
+		}
+		conns = append(conns, c)
+	}
+	return conns, nil
+}
+
+func (s *RedisStore) tenantsKey() string {
+	return s.prefix + ":tenants"
+}
+
+func (s *RedisStore) tenantKey(tenant string) string {
+	return s.prefix + ":tenant:" + tenant
+}
+
+func (s *RedisStore) connKey(id string) string {
+	return s.prefix + ":conn:" + id
+}
+
+func score(t time.Time) float64 {
+	return float64(t.UnixMilli())
+}
@@ -0,0 +1,172 @@
+package presence
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/slotter-org/slotter-backend/internal/logger"
+)
+
+// Change is what a Notifier is told about a user.
+type Change string
+
+const (
+	Joined Change = "joined"
+	Left   Change = "left"
+)
+
+// Notifier hears when a user's first connection in their tenant opens and
+// when their last one closes or lapses.
+type Notifier func(change Change, c Connection)
+
+// Tracker records this instance's connections in a Store and heartbeats
+// them while they stay open. It also sweeps connections that lapsed,
+// including those of instances that went away.
+type Tracker struct {
+	log    *logger.Logger
+	store  Store
+	ttl    time.Duration
+	notify Notifier
+
+	mu    sync.Mutex
+	local map[uuid.UUID]Connection
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+func NewTracker(log *logger.Logger, store Store, ttl time.Duration) *Tracker {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Tracker{
+		log:   log.With("component", "PresenceTracker"),
+		store: store,
+		ttl:   ttl,
+		local: make(map[uuid.UUID]Connection),
+		stop:  make(chan struct{}),
+	}
+}
+
+func (t *Tracker) SetNotifier(notify Notifier) {
+	t.notify = notify
+}
+
+// Connect records a new connection and returns it with its ID set.
+// Presence is best effort: a store failure is logged, never returned.
+func (t *Tracker) Connect(ctx context.Context, c Connection) Connection {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	c.ConnectedAt = time.Now().UTC()
+	t.mu.Lock()
+	t.local[c.ID] = c
+	t.mu.Unlock()
+	first, err := t.store.Add(ctx, c, time.Now().Add(t.ttl))
+	if err != nil {
+		t.log.Warn("Failed to record presence connection", "connectionID", c.ID, "error", err)
+		return c
+	}
+	if first {
+		t.emit(Joined, c)
+	}
+	return c
+}
+
+func (t *Tracker) Disconnect(ctx context.Context, c Connection) {
+	t.mu.Lock()
+	delete(t.local, c.ID)
+	t.mu.Unlock()
+	last, err := t.store.Remove(ctx, c)
+	if err != nil {
+		t.log.Warn("Failed to remove presence connection", "connectionID", c.ID, "error", err)
+		return
+	}
+	if last {
+		t.emit(Left, c)
+	}
+}
+
+// SetViewing records the warehouse and scenario connection c has open,
+// and the warehouse's company. Nil clears them. Each connection keeps its
+// own, so a user's tabs can be on different warehouses.
+func (t *Tracker) SetViewing(ctx context.Context, c Connection) error {
+	return t.store.SetViewing(ctx, c)
+}
+
+func (t *Tracker) List(ctx context.Context, tenant string) ([]Connection, error) {
+	return t.store.List(ctx, tenant)
+}
+
+// Start heartbeats and sweeps three times per TTL until Stop.
+func (t *Tracker) Start(ctx context.Context) {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(t.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				t.heartbeat(ctx)
+				t.sweep(ctx)
+			}
+		}
+	}()
+}
+
+// Stop ends the loop and removes this instance's connections, so other
+// instances see their users leave right away instead of after the TTL.
+func (t *Tracker) Stop() {
+	t.once.Do(func() {
+		close(t.stop)
+		t.wg.Wait()
+		t.mu.Lock()
+		conns := make([]Connection, 0, len(t.local))
+		for _, c := range t.local {
+			conns = append(conns, c)
+		}
+		t.mu.Unlock()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		for _, c := range conns {
+			t.Disconnect(ctx, c)
+		}
+	})
+}
+
+func (t *Tracker) heartbeat(ctx context.Context) {
+	t.mu.Lock()
+	conns := make([]Connection, 0, len(t.local))
+	for _, c := range t.local {
+		conns = append(conns, c)
+	}
+	t.mu.Unlock()
+	if err := t.store.Touch(ctx, conns, time.Now().Add(t.ttl)); err != nil {
+		t.log.Warn("Failed to heartbeat presence connections", "count", len(conns), "error", err)
+	}
+}
+
+func (t *Tracker) sweep(ctx context.Context) {
+	left, err := t.store.Expire(ctx, time.Now())
+	if err != nil {
+		t.log.Warn("Failed to sweep lapsed presence connections", "error", err)
+	}
+	for _, c := range left {
+		t.emit(Left, c)
+	}
+}
+
+func (t *Tracker) emit(change Change, c Connection) {
+	if t.notify != nil {
+		t.notify(change, c)
+	}
+}
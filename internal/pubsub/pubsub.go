@@ -0,0 +1,144 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Handler receives the payload of a message published on a topic.
+type Handler func(payload []byte)
+
+// PubSub fans messages out to every subscriber of a topic, across
+// instances when the backend is shared. Publishers receive their own
+// messages too; hubs tell them apart with the Envelope origin.
+type PubSub interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+	// Subscribe calls h for each message on topic, in publish order, until
+	// the returned function is called.
+	Subscribe(ctx context.Context, topic string, h Handler) (unsubscribe func(), err error)
+	// Shared reports whether messages reach other instances.
+	Shared() bool
+	Close() error
+}
+
+// Envelope wraps a hub message with the ID of the hub that published it, so
+// a hub can skip messages it already delivered locally.
+type Envelope struct {
+	Origin string          `json:"origin"`
+	Data   json.RawMessage `json:"data"`
+}
+
+func Encode(origin string, v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Envelope{Origin: origin, Data: data})
+}
+
+func Decode(payload []byte) (Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(payload, &env); err != nil {
+		return env, fmt.Errorf("json unmarshal failed: %w", err)
+	}
+	return env, nil
+}
+
+// subscriptionBuffer is how many messages a subscriber may fall behind
+// before it misses messages.
+const subscriptionBuffer = 256
+
+// ErrSubscriberBehind is returned by Memory.Publish when a subscriber's
+// buffer was full and it missed the message. The others still got it.
+var ErrSubscriberBehind = errors.New("pubsub subscriber is behind; message dropped")
+
+// Memory is an in-process PubSub for a single instance and for tests. Each
+// subscriber gets messages on its own goroutine, like the Redis backend.
+// Publish never waits for a subscriber: one that is a full buffer behind
+// misses the message, as a slow Redis subscriber would.
+type Memory struct {
+	mu     sync.RWMutex
+	topics map[string]map[*memorySub]bool
+	closed bool
+}
+
+type memorySub struct {
+	ch   chan []byte
+	once sync.Once
+}
+
+func NewMemory() *Memory {
+	return &Memory{topics: make(map[string]map[*memorySub]bool)}
+}
+
+func (m *Memory) Publish(_ context.Context, topic string, payload []byte) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return fmt.Errorf("pubsub closed")
+	}
+	// Sending under the read lock keeps unsubscribe from closing a channel
+	// mid-send; it must not block, or unsubscribe and Close would wait on
+	// the slowest subscriber.
+	dropped := 0
+	for sub := range m.topics[topic] {
+		select {
+		case sub.ch <- payload:
+		default:
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		return fmt.Errorf("%w (%d of %d subscribers of %s)", ErrSubscriberBehind, dropped, len(m.topics[topic]), topic)
+	}
+	return nil
+}
+
+func (m *Memory) Shared() bool {
+	return false
+}
+
+func (m *Memory) Subscribe(_ context.Context, topic string, h Handler) (func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, fmt.Errorf("pubsub closed")
+	}
+	sub := &memorySub{ch: make(chan []byte, subscriptionBuffer)}
+	if m.topics[topic] == nil {
+		m.topics[topic] = make(map[*memorySub]bool)
+	}
+	m.topics[topic][sub] = true
+	go func() {
+		for payload := range sub.ch {
+			h(payload)
+		}
+	}()
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if subs, ok := m.topics[topic]; ok && subs[sub] {
+			delete(subs, sub)
+			if len(subs) == 0 {
+				delete(m.topics, topic)
+			}
+			sub.once.Do(func() { close(sub.ch) })
+		}
+	}, nil
+}
+
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	for _, subs := range m.topics {
+		for sub := range subs {
+			sub.once.Do(func() { close(sub.ch) })
+		}
+	}
+	m.topics = make(map[string]map[*memorySub]bool)
+	return nil
+}
@@ -0,0 +1,118 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryDeliversInOrder(t *testing.T) {
+	m := NewMemory()
+	defer m.Close()
+	got := make(chan string, 3)
+	if _, err := m.Subscribe(context.Background(), "t", func(p []byte) { got <- string(p) }); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	for _, p := range []string{"a", "b", "c"} {
+		if err := m.Publish(context.Background(), "t", []byte(p)); err != nil {
+			t.Fatalf("Publish(%s): %v", p, err)
+		}
+	}
+	for _, want := range []string{"a", "b", "c"} {
+		select {
+		case p := <-got:
+			if p != want {
+				t.Fatalf("got %q, want %q", p, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+}
+
+// TestMemorySlowSubscriber fills a stuck subscriber's buffer and checks
+// that Publish neither blocks on it nor starves the other subscriber, and
+// that the stuck one can still be unsubscribed.
+func TestMemorySlowSubscriber(t *testing.T) {
+	m := NewMemory()
+	defer m.Close()
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	unsubscribeStuck, err := m.Subscribe(context.Background(), "t", func([]byte) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+	})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer close(release)
+	received := make(chan struct{}, 1)
+	if _, err := m.Subscribe(context.Background(), "t", func([]byte) { received <- struct{}{} }); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	// The first message is taken by the stuck handler, the next
+	// subscriptionBuffer fill its buffer. Waiting for the other subscriber
+	// after each one keeps it from falling behind too.
+	for i := 0; i <= subscriptionBuffer; i++ {
+		if err := m.Publish(context.Background(), "t", []byte("x")); err != nil {
+			t.Fatalf("Publish %d: %v", i, err)
+		}
+		if i == 0 {
+			<-started
+		}
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			t.Fatalf("other subscriber did not get message %d", i)
+		}
+	}
+
+	published := make(chan error, 1)
+	go func() { published <- m.Publish(context.Background(), "t", []byte("over")) }()
+	select {
+	case err := <-published:
+		if !errors.Is(err, ErrSubscriberBehind) {
+			t.Fatalf("Publish to a full subscriber: err = %v, want %v", err, ErrSubscriberBehind)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a full subscriber")
+	}
+
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("other subscriber missed the message the stuck one dropped")
+	}
+
+	unsubscribed := make(chan struct{})
+	go func() {
+		unsubscribeStuck()
+		close(unsubscribed)
+	}()
+	select {
+	case <-unsubscribed:
+	case <-time.After(time.Second):
+		t.Fatal("unsubscribe blocked behind a full subscriber")
+	}
+}
+
+func TestMemoryClosed(t *testing.T) {
+	m := NewMemory()
+	if _, err := m.Subscribe(context.Background(), "t", func([]byte) {}); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := m.Publish(context.Background(), "t", []byte("x")); err == nil {
+		t.Fatal("Publish after Close succeeded")
+	}
+	if _, err := m.Subscribe(context.Background(), "t", func([]byte) {}); err == nil {
+		t.Fatal("Subscribe after Close succeeded")
+	}
+}
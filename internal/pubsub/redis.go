@@ -0,0 +1,89 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slotter-org/slotter-backend/internal/logger"
+)
+
+// Redis is a PubSub over Redis channels, shared by every instance that
+// connects to the same server.
+type Redis struct {
+	log     *logger.Logger
+	client  *redis.Client
+	mu      sync.Mutex
+	cancels []context.CancelFunc
+}
+
+func NewRedis(log *logger.Logger, address, password string) (*Redis, error) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     address,
+		Password: password,
+		DB:       0,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("redis ping failed: %w", err)
+	}
+	return &Redis{
+		log:    log.With("component", "RedisPubSub"),
+		client: rdb,
+	}, nil
+}
+
+func (r *Redis) Publish(ctx context.Context, topic string, payload []byte) error {
+	return r.client.Publish(ctx, topic, payload).Err()
+}
+
+func (r *Redis) Shared() bool {
+	return true
+}
+
+func (r *Redis) Subscribe(ctx context.Context, topic string, h Handler) (func(), error) {
+	subCtx, cancel := context.WithCancel(context.Background())
+	sub := r.client.Subscribe(subCtx, topic)
+	if _, err := sub.Receive(ctx); err != nil {
+		cancel()
+		_ = sub.Close()
+		return nil, fmt.Errorf("failed to subscribe to redis channel: %w", err)
+	}
+	r.log.Info("RedisPubSub subscribed successfully", "channel", topic)
+
+	r.mu.Lock()
+	r.cancels = append(r.cancels, cancel)
+	r.mu.Unlock()
+
+	go func() {
+		defer sub.Close()
+		ch := sub.Channel()
+		for {
+			select {
+			case <-subCtx.Done():
+				r.log.Debug("Redis pubsub context done, stopping subscription goroutine", "channel", topic)
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					r.log.Debug("PubSub channel closed, stopping subscription goroutine", "channel", topic)
+					return
+				}
+				h([]byte(msg.Payload))
+			}
+		}
+	}()
+	return cancel, nil
+}
+
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	for _, cancel := range r.cancels {
+		cancel()
+	}
+	r.cancels = nil
+	r.mu.Unlock()
+	return r.client.Close()
+}
@@ -0,0 +1,99 @@
+package repos
+
+import (
+    "context"
+    "time"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "github.com/slotter-org/slotter-backend/internal/logger"
+    "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// AuditLogFilter narrows a tenant's audit log. Zero values don't filter.
+type AuditLogFilter struct {
+    EventTypes  []string
+    ActorID     *uuid.UUID
+    Since       *time.Time
+    Until       *time.Time
+    Limit       int
+}
+
+type AuditLogRepo interface {
+    Create(ctx context.Context, tx *gorm.DB, entry *types.AuditLogEntry) (bool, error)
+    GetByTenant(ctx context.Context, tx *gorm.DB, wmsID, companyID *uuid.UUID, filter AuditLogFilter) ([]*types.AuditLogEntry, error)
+}
+
+type auditLogRepo struct {
+    db          *gorm.DB
+    log         *logger.Logger
+}
+
+func NewAuditLogRepo(db *gorm.DB, baseLog *logger.Logger) AuditLogRepo {
+    repoLog := baseLog.With("repo", "AuditLogRepo")
+    return &auditLogRepo{db: db, log: repoLog}
+}
+
+// Create ignores an entry whose event was already recorded, and reports
+// whether the entry was new.
+func (ar *auditLogRepo) Create(ctx context.Context, tx *gorm.DB, entry *types.AuditLogEntry) (bool, error) {
+    ar.log.Info("Starting Create AuditLogEntry now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = ar.db
+        ar.log.Debug("Transaction is nil, using ar.db", "db", transaction)
+    }
+    result := transaction.WithContext(ctx).
+        Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "event_id"}}, DoNothing: true}).
+        Create(entry)
+    if result.Error != nil {
+        ar.log.Error("Failed to create audit log entry", "error", result.Error)
+        return false, result.Error
+    }
+    return result.RowsAffected > 0, nil
+}
+
+func (ar *auditLogRepo) GetByTenant(ctx context.Context, tx *gorm.DB, wmsID, companyID *uuid.UUID, filter AuditLogFilter) ([]*types.AuditLogEntry, error) {
+    ar.log.Info("Starting GetByTenant for audit log entries...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = ar.db
+        ar.log.Debug("Transaction is nil, using ar.db", "db", transaction)
+    }
+    var results []*types.AuditLogEntry
+    query := transaction.WithContext(ctx).Model(&types.AuditLogEntry{})
+    switch {
+    case wmsID != nil && *wmsID != uuid.Nil:
+        query = query.Where("wms_id = ?", *wmsID)
+    case companyID != nil && *companyID != uuid.Nil:
+        query = query.Where("company_id = ?", *companyID)
+    default:
+        ar.log.Debug("No tenant provided, returning empty slice")
+        return results, nil
+    }
+    if len(filter.EventTypes) > 0 {
+        query = query.Where("event_type IN ?", filter.EventTypes)
+    }
+    if filter.ActorID != nil {
+        query = query.Where("actor_id = ?", *filter.ActorID)
+    }
+    if filter.Since != nil {
+        query = query.Where("occurred_at >= ?", *filter.Since)
+    }
+    if filter.Until != nil {
+        query = query.Where("occurred_at < ?", *filter.Until)
+    }
+    if filter.Limit > 0 {
+        query = query.Limit(filter.Limit)
+    }
+    if err := query.Order("occurred_at DESC").Find(&results).Error; err != nil {
+        ar.log.Error("Failed to fetch audit log entries by tenant", "error", err)
+        return nil, err
+    }
+    ar.log.Info("Successfully fetched audit log entries by tenant", "count", len(results))
+    return results, nil
+}
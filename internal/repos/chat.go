@@ -0,0 +1,538 @@
+package repos
+
+import (
+    "context"
+    "time"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "github.com/slotter-org/slotter-backend/internal/logger"
+    "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type ChatRepo interface {
+    CreateSession(ctx context.Context, tx *gorm.DB, session *types.ChatSession) (*types.ChatSession, error)
+    GetSessionsByIDs(ctx context.Context, tx *gorm.DB, sessionIDs []uuid.UUID) ([]*types.ChatSession, error)
+    SearchSessions(ctx context.Context, tx *gorm.DB, filter ChatSessionFilter) ([]*types.ChatSession, int64, error)
+    CanView(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID, viewer ChatViewer) (bool, error)
+    UpdateSession(ctx context.Context, tx *gorm.DB, session *types.ChatSession) (*types.ChatSession, error)
+    FullDeleteSessionsByIDs(ctx context.Context, tx *gorm.DB, sessionIDs []uuid.UUID) error
+    CreateMessages(ctx context.Context, tx *gorm.DB, messages []*types.ChatMessage) ([]*types.ChatMessage, error)
+    GetMessagesBySessionID(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID) ([]*types.ChatMessage, error)
+    SearchMessages(ctx context.Context, tx *gorm.DB, viewer ChatViewer, query string, limit int, offset int) ([]*ChatSearchHit, int64, error)
+
+    CreatePin(ctx context.Context, tx *gorm.DB, pin *types.ChatSessionPin) error
+    DeletePin(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID, userID uuid.UUID) error
+    GetPinnedSessionIDs(ctx context.Context, tx *gorm.DB, userID uuid.UUID, sessionIDs []uuid.UUID) ([]uuid.UUID, error)
+    GetSharesBySessionID(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID) ([]*types.ChatSessionShare, error)
+    ReplaceShares(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID, userIDs []uuid.UUID) ([]*types.ChatSessionShare, error)
+
+    GetRetentionPolicy(ctx context.Context, tx *gorm.DB, companyID *uuid.UUID, wmsID *uuid.UUID) (*types.ChatRetentionPolicy, error)
+    GetActiveRetentionPolicies(ctx context.Context, tx *gorm.DB) ([]*types.ChatRetentionPolicy, error)
+    SaveRetentionPolicy(ctx context.Context, tx *gorm.DB, policy *types.ChatRetentionPolicy) (*types.ChatRetentionPolicy, error)
+    PurgeSessions(ctx context.Context, tx *gorm.DB, policy *types.ChatRetentionPolicy, cutoff time.Time) (int64, error)
+}
+
+const (
+    ChatScopeMine       = "mine"
+    ChatScopeShared     = "shared"
+    ChatScopeAll        = "all"
+)
+
+// ChatViewer is who is reading chat sessions. CompanyID or WmsID is the
+// viewer's tenant, used for sessions shared company-wide.
+type ChatViewer struct {
+    UserID          uuid.UUID
+    CompanyID       *uuid.UUID
+    WmsID           *uuid.UUID
+}
+
+type ChatSessionFilter struct {
+    Viewer          ChatViewer
+    Scope           string
+    PinnedOnly      bool
+    Title           string
+    Limit           int
+    Offset          int
+}
+
+// ChatSearchHit is a message matching a full-text search, with Headline
+// marking the matched words.
+type ChatSearchHit struct {
+    MessageID       uuid.UUID       `json:"messageID"`
+    SessionID       uuid.UUID       `json:"sessionID"`
+    SessionTitle    string          `json:"sessionTitle"`
+    Sequence        int             `json:"sequence"`
+    Role            string          `json:"role"`
+    Headline        string          `json:"headline"`
+    Rank            float64         `json:"rank"`
+    CreatedAt       time.Time       `json:"createdAt"`
+}
+
+type chatRepo struct {
+    db          *gorm.DB
+    log         *logger.Logger
+}
+
+func NewChatRepo(db *gorm.DB, baseLog *logger.Logger) ChatRepo {
+    repoLog := baseLog.With("repo", "ChatRepo")
+    return &chatRepo{db: db, log: repoLog}
+}
+
+func (cr *chatRepo) CreateSession(ctx context.Context, tx *gorm.DB, session *types.ChatSession) (*types.ChatSession, error) {
+    cr.log.Info("Starting CreateSession now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = cr.db
+        cr.log.Debug("Transaction is nil, using cr.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).Omit(clause.Associations).Create(session).Error; err != nil {
+        cr.log.Error("Failed to create chat session", "error", err)
+        return nil, err
+    }
+    cr.log.Info("Successfully created chat session", "sessionID", session.ID)
+    return session, nil
+}
+
+func (cr *chatRepo) GetSessionsByIDs(ctx context.Context, tx *gorm.DB, sessionIDs []uuid.UUID) ([]*types.ChatSession, error) {
+    cr.log.Info("Starting GetSessionsByIDs now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = cr.db
+        cr.log.Debug("Transaction is nil, using cr.db", "db", transaction)
+    }
+    var sessions []*types.ChatSession
+    if len(sessionIDs) == 0 {
+        return sessions, nil
+    }
+    if err := transaction.WithContext(ctx).
+        Where("id IN ?", sessionIDs).
+        Find(&sessions).Error; err != nil {
+        cr.log.Error("Failed to fetch chat sessions by IDs", "error", err)
+        return nil, err
+    }
+    return sessions, nil
+}
+
+// SearchSessions pages through the sessions filter.Viewer may read, pinned
+// ones first and then the most recently active.
+func (cr *chatRepo) SearchSessions(ctx context.Context, tx *gorm.DB, filter ChatSessionFilter) ([]*types.ChatSession, int64, error) {
+    cr.log.Info("Starting SearchSessions now...", "scope", filter.Scope)
+
+    transaction := tx
+    if transaction == nil {
+        transaction = cr.db
+        cr.log.Debug("Transaction is nil, using cr.db", "db", transaction)
+    }
+    pinned := `EXISTS (SELECT 1 FROM "chat_session_pin" p WHERE p.session_id = "chat_session"."id" AND p.user_id = ? AND p.deleted_at IS NULL)`
+    matches := func(db *gorm.DB) *gorm.DB {
+        db = db.Model(&types.ChatSession{})
+        switch filter.Scope {
+        case ChatScopeShared:
+            sql, args := chatSharedCondition(filter.Viewer)
+            db = db.Where(`"chat_session"."user_id" <> ?`, filter.Viewer.UserID).Where(sql, args...)
+        case ChatScopeAll:
+            sql, args := chatVisibleCondition(filter.Viewer)
+            db = db.Where(sql, args...)
+        default:
+            db = db.Where(`"chat_session"."user_id" = ?`, filter.Viewer.UserID)
+        }
+        if filter.PinnedOnly {
+            db = db.Where(pinned, filter.Viewer.UserID)
+        }
+        if filter.Title != "" {
+            db = db.Where(`"chat_session"."title" ILIKE ?`, "%"+escapeLike(filter.Title)+"%")
+        }
+        return db
+    }
+    var total int64
+    if err := transaction.WithContext(ctx).Scopes(matches).Count(&total).Error; err != nil {
+        cr.log.Error("Failed to count chat sessions", "error", err)
+        return nil, 0, err
+    }
+    var sessions []*types.ChatSession
+    if err := transaction.WithContext(ctx).
+        Scopes(matches).
+        Order(clause.OrderBy{Expression: clause.Expr{SQL: pinned + " DESC, updated_at DESC, id", Vars: []interface{}{filter.Viewer.UserID}, WithoutParentheses: true}}).
+        Limit(filter.Limit).
+        Offset(filter.Offset).
+        Find(&sessions).Error; err != nil {
+        cr.log.Error("Failed to search chat sessions", "error", err)
+        return nil, 0, err
+    }
+    return sessions, total, nil
+}
+
+// CanView reports whether viewer may read the session: it is theirs, shared
+// with their company or WMS, or shared with them.
+func (cr *chatRepo) CanView(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID, viewer ChatViewer) (bool, error) {
+    cr.log.Info("Starting CanView now...", "sessionID", sessionID)
+
+    transaction := tx
+    if transaction == nil {
+        transaction = cr.db
+        cr.log.Debug("Transaction is nil, using cr.db", "db", transaction)
+    }
+    sql, args := chatVisibleCondition(viewer)
+    var count int64
+    if err := transaction.WithContext(ctx).
+        Model(&types.ChatSession{}).
+        Where(`"chat_session"."id" = ?`, sessionID).
+        Where(sql, args...).
+        Count(&count).Error; err != nil {
+        cr.log.Error("Failed to check chat session access", "error", err)
+        return false, err
+    }
+    return count > 0, nil
+}
+
+func (cr *chatRepo) UpdateSession(ctx context.Context, tx *gorm.DB, session *types.ChatSession) (*types.ChatSession, error) {
+    cr.log.Info("Starting UpdateSession now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = cr.db
+        cr.log.Debug("Transaction is nil, using cr.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).Omit(clause.Associations).Save(session).Error; err != nil {
+        cr.log.Error("Failed to update chat session", "error", err, "sessionID", session.ID)
+        return nil, err
+    }
+    return session, nil
+}
+
+func (cr *chatRepo) FullDeleteSessionsByIDs(ctx context.Context, tx *gorm.DB, sessionIDs []uuid.UUID) error {
+    cr.log.Info("Starting FullDeleteSessionsByIDs now...")
+    transaction := tx
+    if transaction == nil {
+        transaction = cr.db
+    }
+    if len(sessionIDs) == 0 {
+        cr.log.Debug("No sessionIDs provided, skipping full delete")
+        return nil
+    }
+    if err := transaction.WithContext(ctx).
+        Unscoped().
+        Where("id IN (?)", sessionIDs).
+        Delete(&types.ChatSession{}).Error; err != nil {
+        cr.log.Error("Failed to FULL delete chat sessions", "error", err)
+        return err
+    }
+    cr.log.Info("Successfully FULL deleted chat sessions", "count", len(sessionIDs))
+    return nil
+}
+
+func (cr *chatRepo) CreateMessages(ctx context.Context, tx *gorm.DB, messages []*types.ChatMessage) ([]*types.ChatMessage, error) {
+    cr.log.Info("Starting CreateMessages now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = cr.db
+        cr.log.Debug("Transaction is nil, using cr.db", "db", transaction)
+    }
+    if len(messages) == 0 {
+        return messages, nil
+    }
+    if err := transaction.WithContext(ctx).Omit(clause.Associations).Create(&messages).Error; err != nil {
+        cr.log.Error("Failed to create chat messages", "error", err)
+        return nil, err
+    }
+    cr.log.Info("Successfully created chat messages", "count", len(messages))
+    return messages, nil
+}
+
+func (cr *chatRepo) GetMessagesBySessionID(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID) ([]*types.ChatMessage, error) {
+    cr.log.Info("Starting GetMessagesBySessionID now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = cr.db
+        cr.log.Debug("Transaction is nil, using cr.db", "db", transaction)
+    }
+    var messages []*types.ChatMessage
+    if err := transaction.WithContext(ctx).
+        Where("session_id = ?", sessionID).
+        Order("sequence").
+        Order("created_at").
+        Find(&messages).Error; err != nil {
+        cr.log.Error("Failed to fetch chat messages", "error", err)
+        return nil, err
+    }
+    return messages, nil
+}
+
+// SearchMessages runs a full-text search over the user and assistant
+// messages of the sessions viewer may read, best matches first. query uses
+// web search syntax: words, "quoted phrases", or and -excluded words.
+func (cr *chatRepo) SearchMessages(ctx context.Context, tx *gorm.DB, viewer ChatViewer, query string, limit int, offset int) ([]*ChatSearchHit, int64, error) {
+    cr.log.Info("Starting SearchMessages now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = cr.db
+        cr.log.Debug("Transaction is nil, using cr.db", "db", transaction)
+    }
+    visible, visibleArgs := chatVisibleCondition(viewer)
+    from := `
+        FROM "chat_message" m
+        JOIN "chat_session" ON "chat_session"."id" = m.session_id AND "chat_session"."deleted_at" IS NULL,
+        websearch_to_tsquery('simple', ?) q
+        WHERE m.deleted_at IS NULL
+        AND m.role IN ('user', 'assistant')
+        AND to_tsvector('simple', m.content) @@ q
+        AND ` + visible
+    args := append([]interface{}{query}, visibleArgs...)
+
+    var total int64
+    if err := transaction.WithContext(ctx).Raw(`SELECT COUNT(*) `+from, args...).Scan(&total).Error; err != nil {
+        cr.log.Error("Failed to count chat search hits", "error", err)
+        return nil, 0, err
+    }
+    hits := []*ChatSearchHit{}
+    if err := transaction.WithContext(ctx).Raw(`
+        SELECT m.id AS message_id, m.session_id, "chat_session"."title" AS session_title, m.sequence, m.role, m.created_at,
+            ts_headline('simple', m.content, q, 'StartSel=**, StopSel=**, MaxWords=35, MinWords=15') AS headline,
+            ts_rank(to_tsvector('simple', m.content), q) AS rank
+        `+from+`
+        ORDER BY rank DESC, m.created_at DESC, m.id
+        LIMIT ? OFFSET ?`, append(args, limit, offset)...).
+        Scan(&hits).Error; err != nil {
+        cr.log.Error("Failed to search chat messages", "error", err)
+        return nil, 0, err
+    }
+    return hits, total, nil
+}
+
+// CreatePin does nothing when the session is already pinned.
+func (cr *chatRepo) CreatePin(ctx context.Context, tx *gorm.DB, pin *types.ChatSessionPin) error {
+    cr.log.Info("Starting CreatePin now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = cr.db
+        cr.log.Debug("Transaction is nil, using cr.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).
+        Omit(clause.Associations).
+        Clauses(clause.OnConflict{
+            Columns:   []clause.Column{{Name: "session_id"}, {Name: "user_id"}},
+            DoNothing: true,
+        }).
+        Create(pin).Error; err != nil {
+        cr.log.Error("Failed to pin chat session", "error", err)
+        return err
+    }
+    return nil
+}
+
+func (cr *chatRepo) DeletePin(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID, userID uuid.UUID) error {
+    cr.log.Info("Starting DeletePin now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = cr.db
+        cr.log.Debug("Transaction is nil, using cr.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).
+        Unscoped().
+        Where("session_id = ? AND user_id = ?", sessionID, userID).
+        Delete(&types.ChatSessionPin{}).Error; err != nil {
+        cr.log.Error("Failed to unpin chat session", "error", err)
+        return err
+    }
+    return nil
+}
+
+func (cr *chatRepo) GetPinnedSessionIDs(ctx context.Context, tx *gorm.DB, userID uuid.UUID, sessionIDs []uuid.UUID) ([]uuid.UUID, error) {
+    cr.log.Info("Starting GetPinnedSessionIDs now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = cr.db
+        cr.log.Debug("Transaction is nil, using cr.db", "db", transaction)
+    }
+    var ids []uuid.UUID
+    if len(sessionIDs) == 0 {
+        return ids, nil
+    }
+    if err := transaction.WithContext(ctx).
+        Model(&types.ChatSessionPin{}).
+        Where("user_id = ? AND session_id IN ?", userID, sessionIDs).
+        Pluck("session_id", &ids).Error; err != nil {
+        cr.log.Error("Failed to fetch pinned chat sessions", "error", err)
+        return nil, err
+    }
+    return ids, nil
+}
+
+func (cr *chatRepo) GetSharesBySessionID(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID) ([]*types.ChatSessionShare, error) {
+    cr.log.Info("Starting GetSharesBySessionID now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = cr.db
+        cr.log.Debug("Transaction is nil, using cr.db", "db", transaction)
+    }
+    var shares []*types.ChatSessionShare
+    if err := transaction.WithContext(ctx).
+        Where("session_id = ?", sessionID).
+        Order("created_at").
+        Find(&shares).Error; err != nil {
+        cr.log.Error("Failed to fetch chat session shares", "error", err)
+        return nil, err
+    }
+    return shares, nil
+}
+
+// ReplaceShares makes userIDs the exact set of users the session is shared
+// with.
+func (cr *chatRepo) ReplaceShares(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID, userIDs []uuid.UUID) ([]*types.ChatSessionShare, error) {
+    cr.log.Info("Starting ReplaceShares now...", "sessionID", sessionID, "count", len(userIDs))
+
+    transaction := tx
+    if transaction == nil {
+        transaction = cr.db
+        cr.log.Debug("Transaction is nil, using cr.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).
+        Unscoped().
+        Where("session_id = ?", sessionID).
+        Delete(&types.ChatSessionShare{}).Error; err != nil {
+        cr.log.Error("Failed to clear chat session shares", "error", err)
+        return nil, err
+    }
+    shares := make([]*types.ChatSessionShare, 0, len(userIDs))
+    for _, userID := range userIDs {
+        shares = append(shares, &types.ChatSessionShare{SessionID: sessionID, UserID: userID})
+    }
+    if len(shares) == 0 {
+        return shares, nil
+    }
+    if err := transaction.WithContext(ctx).Omit(clause.Associations).Create(&shares).Error; err != nil {
+        cr.log.Error("Failed to create chat session shares", "error", err)
+        return nil, err
+    }
+    return shares, nil
+}
+
+// GetRetentionPolicy returns nil without an error when the tenant has no
+// policy.
+func (cr *chatRepo) GetRetentionPolicy(ctx context.Context, tx *gorm.DB, companyID *uuid.UUID, wmsID *uuid.UUID) (*types.ChatRetentionPolicy, error) {
+    cr.log.Info("Starting GetRetentionPolicy now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = cr.db
+        cr.log.Debug("Transaction is nil, using cr.db", "db", transaction)
+    }
+    query := transaction.WithContext(ctx)
+    if companyID != nil {
+        query = query.Where("company_id = ?", *companyID)
+    } else {
+        query = query.Where("wms_id = ?", wmsID)
+    }
+    var results []*types.ChatRetentionPolicy
+    if err := query.Limit(1).Find(&results).Error; err != nil {
+        cr.log.Error("Failed to fetch chat retention policy", "error", err)
+        return nil, err
+    }
+    if len(results) == 0 {
+        return nil, nil
+    }
+    return results[0], nil
+}
+
+func (cr *chatRepo) GetActiveRetentionPolicies(ctx context.Context, tx *gorm.DB) ([]*types.ChatRetentionPolicy, error) {
+    cr.log.Info("Starting GetActiveRetentionPolicies now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = cr.db
+        cr.log.Debug("Transaction is nil, using cr.db", "db", transaction)
+    }
+    var policies []*types.ChatRetentionPolicy
+    if err := transaction.WithContext(ctx).
+        Where("retention_days > 0").
+        Order("id").
+        Find(&policies).Error; err != nil {
+        cr.log.Error("Failed to fetch chat retention policies", "error", err)
+        return nil, err
+    }
+    return policies, nil
+}
+
+func (cr *chatRepo) SaveRetentionPolicy(ctx context.Context, tx *gorm.DB, policy *types.ChatRetentionPolicy) (*types.ChatRetentionPolicy, error) {
+    cr.log.Info("Starting SaveRetentionPolicy now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = cr.db
+        cr.log.Debug("Transaction is nil, using cr.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).Omit(clause.Associations).Save(policy).Error; err != nil {
+        cr.log.Error("Failed to save chat retention policy", "error", err)
+        return nil, err
+    }
+    return policy, nil
+}
+
+// PurgeSessions hard-deletes the sessions of the policy's tenant that have
+// been idle since before cutoff; their messages, shares and pins go with
+// them. Sessions belong to the tenant of the user who owns them.
+func (cr *chatRepo) PurgeSessions(ctx context.Context, tx *gorm.DB, policy *types.ChatRetentionPolicy, cutoff time.Time) (int64, error) {
+    cr.log.Info("Starting PurgeSessions now...", "policyID", policy.ID, "cutoff", cutoff)
+
+    transaction := tx
+    if transaction == nil {
+        transaction = cr.db
+        cr.log.Debug("Transaction is nil, using cr.db", "db", transaction)
+    }
+    owners := `user_id IN (SELECT id FROM "user" WHERE wms_id = ?)`
+    tenant := policy.WmsID
+    if policy.CompanyID != nil {
+        owners = `user_id IN (SELECT id FROM "user" WHERE company_id = ?)`
+        tenant = policy.CompanyID
+    }
+    if tenant == nil {
+        return 0, nil
+    }
+    result := transaction.WithContext(ctx).
+        Unscoped().
+        Where("updated_at < ?", cutoff).
+        Where(owners, *tenant).
+        Delete(&types.ChatSession{})
+    if result.Error != nil {
+        cr.log.Error("Failed to purge chat sessions", "error", result.Error)
+        return 0, result.Error
+    }
+    if result.RowsAffected > 0 {
+        cr.log.Info("Successfully purged chat sessions", "count", result.RowsAffected)
+    }
+    return result.RowsAffected, nil
+}
+
+// chatVisibleCondition matches the sessions viewer may read.
+func chatVisibleCondition(viewer ChatViewer) (string, []interface{}) {
+    shared, args := chatSharedCondition(viewer)
+    return `("chat_session"."user_id" = ? OR ` + shared + `)`, append([]interface{}{viewer.UserID}, args...)
+}
+
+// chatSharedCondition matches sessions shared with viewer's tenant or with
+// viewer in person.
+func chatSharedCondition(viewer ChatViewer) (string, []interface{}) {
+    sql := `(("chat_session"."visibility" = 'users' AND EXISTS (SELECT 1 FROM "chat_session_share" s WHERE s.session_id = "chat_session"."id" AND s.user_id = ? AND s.deleted_at IS NULL))`
+    args := []interface{}{viewer.UserID}
+    switch {
+    case viewer.CompanyID != nil:
+        sql += ` OR ("chat_session"."visibility" = 'company' AND "chat_session"."user_id" IN (SELECT u.id FROM "user" u WHERE u.company_id = ? AND u.deleted_at IS NULL))`
+        args = append(args, *viewer.CompanyID)
+    case viewer.WmsID != nil:
+        sql += ` OR ("chat_session"."visibility" = 'company' AND "chat_session"."user_id" IN (SELECT u.id FROM "user" u WHERE u.wms_id = ? AND u.deleted_at IS NULL))`
+        args = append(args, *viewer.WmsID)
+    }
+    return sql + `)`, args
+}
@@ -0,0 +1,117 @@
+package repos
+
+import (
+    "context"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "github.com/slotter-org/slotter-backend/internal/logger"
+    "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type FloorMapRepo interface {
+    CreateRender(ctx context.Context, tx *gorm.DB, render *types.FloorMapRender) (*types.FloorMapRender, error)
+    GetRenderByFingerprint(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, fingerprint string) (*types.FloorMapRender, error)
+    GetRendersByVariant(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, variant string) ([]*types.FloorMapRender, error)
+    FullDeleteRendersByIDs(ctx context.Context, tx *gorm.DB, renderIDs []uuid.UUID) error
+}
+
+type floorMapRepo struct {
+    db          *gorm.DB
+    log         *logger.Logger
+}
+
+func NewFloorMapRepo(db *gorm.DB, baseLog *logger.Logger) FloorMapRepo {
+    repoLog := baseLog.With("repo", "FloorMapRepo")
+    return &floorMapRepo{db: db, log: repoLog}
+}
+
+// CreateRender leaves render.ID unset when a render with the same
+// fingerprint already exists.
+func (fr *floorMapRepo) CreateRender(ctx context.Context, tx *gorm.DB, render *types.FloorMapRender) (*types.FloorMapRender, error) {
+    fr.log.Info("Starting CreateRender now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = fr.db
+        fr.log.Debug("Transaction is nil, using fr.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).
+        Omit(clause.Associations).
+        Clauses(clause.OnConflict{
+            Columns:   []clause.Column{{Name: "warehouse_id"}, {Name: "fingerprint"}},
+            DoNothing: true,
+        }).
+        Create(render).Error; err != nil {
+        fr.log.Error("Failed to create floor map render", "error", err)
+        return nil, err
+    }
+    fr.log.Info("Successfully created floor map render", "renderID", render.ID)
+    return render, nil
+}
+
+// GetRenderByFingerprint returns nil without an error when nothing has been
+// rendered with that fingerprint.
+func (fr *floorMapRepo) GetRenderByFingerprint(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, fingerprint string) (*types.FloorMapRender, error) {
+    fr.log.Info("Starting GetRenderByFingerprint now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = fr.db
+        fr.log.Debug("Transaction is nil, using fr.db", "db", transaction)
+    }
+    var results []*types.FloorMapRender
+    if err := transaction.WithContext(ctx).
+        Where("warehouse_id = ? AND fingerprint = ?", warehouseID, fingerprint).
+        Limit(1).
+        Find(&results).Error; err != nil {
+        fr.log.Error("Failed to fetch floor map render by fingerprint", "error", err)
+        return nil, err
+    }
+    if len(results) == 0 {
+        return nil, nil
+    }
+    return results[0], nil
+}
+
+func (fr *floorMapRepo) GetRendersByVariant(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, variant string) ([]*types.FloorMapRender, error) {
+    fr.log.Info("Starting GetRendersByVariant now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = fr.db
+        fr.log.Debug("Transaction is nil, using fr.db", "db", transaction)
+    }
+    var renders []*types.FloorMapRender
+    if err := transaction.WithContext(ctx).
+        Where("warehouse_id = ? AND variant = ?", warehouseID, variant).
+        Order("created_at DESC").
+        Find(&renders).Error; err != nil {
+        fr.log.Error("Failed to fetch floor map renders by variant", "error", err)
+        return nil, err
+    }
+    return renders, nil
+}
+
+func (fr *floorMapRepo) FullDeleteRendersByIDs(ctx context.Context, tx *gorm.DB, renderIDs []uuid.UUID) error {
+    fr.log.Info("Starting FullDeleteRendersByIDs now...")
+    transaction := tx
+    if transaction == nil {
+        transaction = fr.db
+    }
+    if len(renderIDs) == 0 {
+        fr.log.Debug("No renderIDs provided, skipping full delete")
+        return nil
+    }
+    if err := transaction.WithContext(ctx).
+        Unscoped().
+        Where("id IN (?)", renderIDs).
+        Delete(&types.FloorMapRender{}).Error; err != nil {
+        fr.log.Error("Failed to FULL delete floor map renders", "error", err)
+        return err
+    }
+    fr.log.Info("Successfully FULL deleted floor map renders", "count", len(renderIDs))
+    return nil
+}
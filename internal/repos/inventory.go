@@ -0,0 +1,278 @@
+package repos
+
+import (
+    "context"
+    "time"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "github.com/slotter-org/slotter-backend/internal/logger"
+    "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// OnHandFilter narrows SearchOnHand. LocationPrefix matches a location full
+// code and everything below it ("A-03" matches "A-03-02-B-01").
+type OnHandFilter struct {
+    ItemID          *uuid.UUID
+    LocationID      *uuid.UUID
+    LocationPrefix  string
+    Lot             string
+    ExpiringBefore  *time.Time
+    Limit           int
+    Offset          int
+}
+
+// LedgerFilter narrows SearchLedger.
+type LedgerFilter struct {
+    ItemID          *uuid.UUID
+    LocationID      *uuid.UUID
+    Type            types.InventoryTransactionType
+    Since           *time.Time
+    Until           *time.Time
+    Limit           int
+    Offset          int
+}
+
+type InventoryRepo interface {
+    CreateLedgerEntries(ctx context.Context, tx *gorm.DB, entries []*types.InventoryLedgerEntry) ([]*types.InventoryLedgerEntry, error)
+    GetOrCreateOnHandForUpdate(ctx context.Context, tx *gorm.DB, row *types.InventoryOnHand) (*types.InventoryOnHand, error)
+    GetOnHandByWarehouseID(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) ([]*types.InventoryOnHand, error)
+    SearchOnHand(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filter OnHandFilter) ([]*types.InventoryOnHand, int64, error)
+    SearchLedger(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filter LedgerFilter) ([]*types.InventoryLedgerEntry, int64, error)
+    UpdateOnHand(ctx context.Context, tx *gorm.DB, rows []*types.InventoryOnHand) ([]*types.InventoryOnHand, error)
+    FullDeleteOnHandByIDs(ctx context.Context, tx *gorm.DB, ids []uuid.UUID) error
+}
+
+type inventoryRepo struct {
+    db          *gorm.DB
+    log         *logger.Logger
+}
+
+func NewInventoryRepo(db *gorm.DB, baseLog *logger.Logger) InventoryRepo {
+    repoLog := baseLog.With("repo", "InventoryRepo")
+    return &inventoryRepo{db: db, log: repoLog}
+}
+
+func (ir *inventoryRepo) CreateLedgerEntries(ctx context.Context, tx *gorm.DB, entries []*types.InventoryLedgerEntry) ([]*types.InventoryLedgerEntry, error) {
+    ir.log.Info("Starting CreateLedgerEntries now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = ir.db
+        ir.log.Debug("Transaction is nil, using ir.db", "db", transaction)
+    } else {
+        ir.log.Debug("Transaction is not nil", "transaction", transaction)
+    }
+    if len(entries) == 0 {
+        ir.log.Debug("No ledger entries provided, returning empty slice")
+        return []*types.InventoryLedgerEntry{}, nil
+    }
+    ir.log.Info("Creating ledger entries now...", "count", len(entries))
+    if err := transaction.WithContext(ctx).Create(&entries).Error; err != nil {
+        ir.log.Error("Failed to create ledger entries", "error", err)
+        return nil, err
+    }
+    ir.log.Info("Successfully created ledger entries", "count", len(entries))
+    return entries, nil
+}
+
+// GetOrCreateOnHandForUpdate returns the on-hand row for row's location, item
+// and lot, locked for the rest of the transaction. A missing row is inserted
+// with quantity zero first; ON CONFLICT DO NOTHING keeps two concurrent
+// receipts into an empty bin from failing on the unique index.
+func (ir *inventoryRepo) GetOrCreateOnHandForUpdate(ctx context.Context, tx *gorm.DB, row *types.InventoryOnHand) (*types.InventoryOnHand, error) {
+    ir.log.Info("Starting GetOrCreateOnHandForUpdate now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = ir.db
+        ir.log.Debug("Transaction is nil, using ir.db", "db", transaction)
+    }
+    seed := *row
+    seed.Quantity = 0
+    if err := transaction.WithContext(ctx).
+        Clauses(clause.OnConflict{
+            Columns:   []clause.Column{{Name: "location_id"}, {Name: "item_id"}, {Name: "lot"}},
+            DoNothing: true,
+        }).
+        Create(&seed).Error; err != nil {
+        ir.log.Error("Failed to insert on-hand row", "error", err)
+        return nil, err
+    }
+    var locked types.InventoryOnHand
+    if err := transaction.WithContext(ctx).
+        Clauses(clause.Locking{Strength: "UPDATE"}).
+        Where("location_id = ? AND item_id = ? AND lot = ?", row.LocationID, row.ItemID, row.Lot).
+        First(&locked).Error; err != nil {
+        ir.log.Error("Failed to lock on-hand row", "error", err)
+        return nil, err
+    }
+    ir.log.Debug("Locked on-hand row", "locationID", row.LocationID, "itemID", row.ItemID, "lot", row.Lot, "quantity", locked.Quantity)
+    return &locked, nil
+}
+
+func (ir *inventoryRepo) GetOnHandByWarehouseID(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) ([]*types.InventoryOnHand, error) {
+    ir.log.Info("Starting GetOnHandByWarehouseID now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = ir.db
+        ir.log.Debug("Transaction is nil, using ir.db", "db", transaction)
+    }
+    var results []*types.InventoryOnHand
+    if warehouseID == uuid.Nil {
+        ir.log.Debug("warehouseID is nil, returning empty slice")
+        return results, nil
+    }
+    if err := transaction.WithContext(ctx).
+        Where("warehouse_id = ?", warehouseID).
+        Find(&results).Error; err != nil {
+        ir.log.Error("Failed to fetch on-hand rows by warehouseID", "error", err)
+        return nil, err
+    }
+    ir.log.Info("Successfully fetched on-hand rows by warehouseID", "count", len(results))
+    return results, nil
+}
+
+func (ir *inventoryRepo) SearchOnHand(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filter OnHandFilter) ([]*types.InventoryOnHand, int64, error) {
+    ir.log.Info("Starting SearchOnHand now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = ir.db
+        ir.log.Debug("Transaction is nil, using ir.db", "db", transaction)
+    }
+    var results []*types.InventoryOnHand
+    var total int64
+    matches := func(db *gorm.DB) *gorm.DB {
+        db = db.Where("warehouse_id = ?", warehouseID)
+        if filter.ItemID != nil {
+            db = db.Where("item_id = ?", *filter.ItemID)
+        }
+        if filter.LocationID != nil {
+            db = db.Where("location_id = ?", *filter.LocationID)
+        }
+        if filter.LocationPrefix != "" {
+            db = db.Where("location_id IN (?)", transaction.Model(&types.WarehouseLocation{}).
+                Select("id").
+                Where("warehouse_id = ? AND (full_code = ? OR full_code LIKE ?)", warehouseID, filter.LocationPrefix, escapeLike(filter.LocationPrefix)+"-%"))
+        }
+        if filter.Lot != "" {
+            db = db.Where("lot = ?", filter.Lot)
+        }
+        if filter.ExpiringBefore != nil {
+            db = db.Where("expires_at IS NOT NULL AND expires_at < ?", *filter.ExpiringBefore)
+        }
+        return db
+    }
+    if err := transaction.WithContext(ctx).Model(&types.InventoryOnHand{}).Scopes(matches).Count(&total).Error; err != nil {
+        ir.log.Error("Failed to count on-hand rows", "error", err)
+        return nil, 0, err
+    }
+    if err := transaction.WithContext(ctx).
+        Scopes(matches).
+        Preload("Location").
+        Preload("Item").
+        Order("location_id ASC, item_id ASC, lot ASC").
+        Limit(filter.Limit).
+        Offset(filter.Offset).
+        Find(&results).Error; err != nil {
+        ir.log.Error("Failed to search on-hand rows", "error", err)
+        return nil, 0, err
+    }
+    ir.log.Info("Successfully searched on-hand rows", "total", total, "returned", len(results))
+    return results, total, nil
+}
+
+func (ir *inventoryRepo) SearchLedger(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filter LedgerFilter) ([]*types.InventoryLedgerEntry, int64, error) {
+    ir.log.Info("Starting SearchLedger now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = ir.db
+        ir.log.Debug("Transaction is nil, using ir.db", "db", transaction)
+    }
+    var results []*types.InventoryLedgerEntry
+    var total int64
+    matches := func(db *gorm.DB) *gorm.DB {
+        db = db.Where("warehouse_id = ?", warehouseID)
+        if filter.ItemID != nil {
+            db = db.Where("item_id = ?", *filter.ItemID)
+        }
+        if filter.LocationID != nil {
+            db = db.Where("location_id = ?", *filter.LocationID)
+        }
+        if filter.Type != "" {
+            db = db.Where("type = ?", filter.Type)
+        }
+        if filter.Since != nil {
+            db = db.Where("created_at >= ?", *filter.Since)
+        }
+        if filter.Until != nil {
+            db = db.Where("created_at < ?", *filter.Until)
+        }
+        return db
+    }
+    if err := transaction.WithContext(ctx).Model(&types.InventoryLedgerEntry{}).Scopes(matches).Count(&total).Error; err != nil {
+        ir.log.Error("Failed to count ledger entries", "error", err)
+        return nil, 0, err
+    }
+    if err := transaction.WithContext(ctx).
+        Scopes(matches).
+        Order("created_at DESC").
+        Limit(filter.Limit).
+        Offset(filter.Offset).
+        Find(&results).Error; err != nil {
+        ir.log.Error("Failed to search ledger entries", "error", err)
+        return nil, 0, err
+    }
+    ir.log.Info("Successfully searched ledger entries", "total", total, "returned", len(results))
+    return results, total, nil
+}
+
+func (ir *inventoryRepo) UpdateOnHand(ctx context.Context, tx *gorm.DB, rows []*types.InventoryOnHand) ([]*types.InventoryOnHand, error) {
+    ir.log.Info("Starting UpdateOnHand now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = ir.db
+        ir.log.Debug("Transaction is nil, using ir.db", "db", transaction)
+    } else {
+        ir.log.Debug("Transaction is not nil", "transaction", transaction)
+    }
+    if len(rows) == 0 {
+        ir.log.Debug("No on-hand rows provided, returning empty slice")
+        return rows, nil
+    }
+    for i := range rows {
+        if err := transaction.WithContext(ctx).Omit(clause.Associations).Save(&rows[i]).Error; err != nil {
+            ir.log.Error("Failed to update on-hand row", "error", err, "row", rows[i])
+            return nil, err
+        }
+    }
+    ir.log.Info("Successfully updated on-hand rows", "count", len(rows))
+    return rows, nil
+}
+
+func (ir *inventoryRepo) FullDeleteOnHandByIDs(ctx context.Context, tx *gorm.DB, ids []uuid.UUID) error {
+    ir.log.Info("Starting FullDeleteOnHandByIDs now...")
+    transaction := tx
+    if transaction == nil {
+        transaction = ir.db
+    }
+    if len(ids) == 0 {
+        ir.log.Debug("No on-hand IDs provided, skipping full delete")
+        return nil
+    }
+    if err := transaction.WithContext(ctx).
+        Unscoped().
+        Where("id IN (?)", ids).
+        Delete(&types.InventoryOnHand{}).Error; err != nil {
+        ir.log.Error("Failed to FULL delete on-hand rows", "error", err)
+        return err
+    }
+    ir.log.Info("Successfully FULL deleted on-hand rows", "count", len(ids))
+    return nil
+}
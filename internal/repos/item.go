@@ -0,0 +1,293 @@
+package repos
+
+import (
+    "context"
+    "strings"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "github.com/slotter-org/slotter-backend/internal/logger"
+    "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type ItemRepo interface {
+    Create(ctx context.Context, tx *gorm.DB, items []*types.Item) ([]*types.Item, error)
+    GetByIDs(ctx context.Context, tx *gorm.DB, itemIDs []uuid.UUID) ([]*types.Item, error)
+    GetByCompanyID(ctx context.Context, tx *gorm.DB, companyID uuid.UUID) ([]*types.Item, error)
+    GetBySKUs(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, skus []string) ([]*types.Item, error)
+    Search(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, query string, limit int, offset int) ([]*types.Item, int64, error)
+    GetUOMsByGTINs(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, gtins []string) ([]*types.ItemUOM, error)
+    Update(ctx context.Context, tx *gorm.DB, items []*types.Item) ([]*types.Item, error)
+    ReplaceUOMs(ctx context.Context, tx *gorm.DB, item *types.Item, uoms []*types.ItemUOM) ([]*types.ItemUOM, error)
+    FullDeleteByIDs(ctx context.Context, tx *gorm.DB, itemIDs []uuid.UUID) error
+}
+
+type itemRepo struct {
+    db          *gorm.DB
+    log         *logger.Logger
+}
+
+func NewItemRepo(db *gorm.DB, baseLog *logger.Logger) ItemRepo {
+    repoLog := baseLog.With("repo", "ItemRepo")
+    return &itemRepo{db: db, log: repoLog}
+}
+
+func (ir *itemRepo) Create(ctx context.Context, tx *gorm.DB, items []*types.Item) ([]*types.Item, error) {
+    ir.log.Info("Starting Create Items now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = ir.db
+        ir.log.Debug("Transaction is nil, using ir.db", "db", transaction)
+    } else {
+        ir.log.Debug("Transaction is not nil", "transaction", transaction)
+    }
+    if len(items) == 0 {
+        ir.log.Debug("No items provided, returning empty slice")
+        return []*types.Item{}, nil
+    }
+    ir.log.Debug("Items provided", "count", len(items))
+
+    ir.log.Info("Creating items and their UOMs now...")
+    if err := transaction.WithContext(ctx).CreateInBatches(&items, 200).Error; err != nil {
+        ir.log.Error("Failed to create items", "error", err)
+        return nil, err
+    }
+    ir.log.Info("Successfully created items", "count", len(items))
+    return items, nil
+}
+
+func (ir *itemRepo) GetByIDs(ctx context.Context, tx *gorm.DB, itemIDs []uuid.UUID) ([]*types.Item, error) {
+    ir.log.Info("Starting GetByIDs for items...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = ir.db
+        ir.log.Debug("Transaction is nil, using ir.db", "db", transaction)
+    } else {
+        ir.log.Debug("Transaction is not nil", "transaction", transaction)
+    }
+    var results []*types.Item
+    if len(itemIDs) == 0 {
+        ir.log.Debug("No itemIDs provided, returning empty slice")
+        return results, nil
+    }
+    ir.log.Debug("ItemIDs provided", "count", len(itemIDs), "itemIDs", itemIDs)
+    ir.log.Info("Fetching items by IDs now...")
+    if err := transaction.WithContext(ctx).
+        Clauses(clause.Locking{Strength: "UPDATE"}).
+        Preload("UOMs", orderUOMs).
+        Where("id IN ?", itemIDs).
+        Find(&results).Error; err != nil {
+        ir.log.Error("Failed to fetch items by IDs", "error", err)
+        return nil, err
+    }
+    ir.log.Info("Successfully fetched items by IDs", "count", len(results))
+    return results, nil
+}
+
+func (ir *itemRepo) GetByCompanyID(ctx context.Context, tx *gorm.DB, companyID uuid.UUID) ([]*types.Item, error) {
+    ir.log.Info("Starting GetByCompanyID for items...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = ir.db
+        ir.log.Debug("Transaction is nil, using ir.db", "db", transaction)
+    }
+    var results []*types.Item
+    if companyID == uuid.Nil {
+        ir.log.Debug("companyID is nil, returning empty slice")
+        return results, nil
+    }
+    if err := transaction.WithContext(ctx).
+        Preload("UOMs", orderUOMs).
+        Where("company_id = ?", companyID).
+        Order("sku ASC").
+        Find(&results).Error; err != nil {
+        ir.log.Error("Failed to fetch items by companyID", "error", err)
+        return nil, err
+    }
+    ir.log.Info("Successfully fetched items by companyID", "count", len(results))
+    return results, nil
+}
+
+func (ir *itemRepo) GetBySKUs(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, skus []string) ([]*types.Item, error) {
+    ir.log.Info("Starting GetBySKUs for items...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = ir.db
+        ir.log.Debug("Transaction is nil, using ir.db", "db", transaction)
+    }
+    var results []*types.Item
+    if companyID == uuid.Nil || len(skus) == 0 {
+        ir.log.Debug("Nothing to look up, returning empty slice")
+        return results, nil
+    }
+    if err := transaction.WithContext(ctx).
+        Clauses(clause.Locking{Strength: "UPDATE"}).
+        Preload("UOMs", orderUOMs).
+        Where("company_id = ? AND sku IN ?", companyID, skus).
+        Find(&results).Error; err != nil {
+        ir.log.Error("Failed to fetch items by SKUs", "error", err)
+        return nil, err
+    }
+    ir.log.Debug("GetBySKUs completed", "requested", len(skus), "found", len(results))
+    return results, nil
+}
+
+// Search matches query against SKU and description (case-insensitive) and
+// against barcodes, and returns one page plus the total number of matches.
+func (ir *itemRepo) Search(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, query string, limit int, offset int) ([]*types.Item, int64, error) {
+    ir.log.Info("Starting Search for items...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = ir.db
+        ir.log.Debug("Transaction is nil, using ir.db", "db", transaction)
+    }
+    var results []*types.Item
+    var total int64
+    matches := func(db *gorm.DB) *gorm.DB {
+        db = db.Where("company_id = ?", companyID)
+        if q := strings.TrimSpace(query); q != "" {
+            pattern := "%" + escapeLike(q) + "%"
+            db = db.Where(
+                "(sku ILIKE ? OR description ILIKE ? OR id IN (?))",
+                pattern, pattern,
+                transaction.Model(&types.ItemUOM{}).Select("item_id").Where("company_id = ? AND gtin LIKE ?", companyID, pattern),
+            )
+        }
+        return db
+    }
+    if err := transaction.WithContext(ctx).Model(&types.Item{}).Scopes(matches).Count(&total).Error; err != nil {
+        ir.log.Error("Failed to count items for search", "error", err)
+        return nil, 0, err
+    }
+    if err := transaction.WithContext(ctx).
+        Scopes(matches).
+        Preload("UOMs", orderUOMs).
+        Order("sku ASC").
+        Limit(limit).
+        Offset(offset).
+        Find(&results).Error; err != nil {
+        ir.log.Error("Failed to search items", "error", err)
+        return nil, 0, err
+    }
+    ir.log.Info("Successfully searched items", "query", query, "total", total, "returned", len(results))
+    return results, total, nil
+}
+
+func (ir *itemRepo) GetUOMsByGTINs(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, gtins []string) ([]*types.ItemUOM, error) {
+    ir.log.Info("Starting GetUOMsByGTINs for items...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = ir.db
+        ir.log.Debug("Transaction is nil, using ir.db", "db", transaction)
+    }
+    var results []*types.ItemUOM
+    if companyID == uuid.Nil || len(gtins) == 0 {
+        ir.log.Debug("Nothing to look up, returning empty slice")
+        return results, nil
+    }
+    if err := transaction.WithContext(ctx).
+        Where("company_id = ? AND gtin IN ?", companyID, gtins).
+        Find(&results).Error; err != nil {
+        ir.log.Error("Failed to fetch item UOMs by GTINs", "error", err)
+        return nil, err
+    }
+    ir.log.Debug("GetUOMsByGTINs completed", "requested", len(gtins), "found", len(results))
+    return results, nil
+}
+
+func (ir *itemRepo) Update(ctx context.Context, tx *gorm.DB, items []*types.Item) ([]*types.Item, error) {
+    ir.log.Info("Starting Update Items now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = ir.db
+        ir.log.Debug("Transaction is nil, using ir.db", "db", transaction)
+    } else {
+        ir.log.Debug("Transaction is not nil", "transaction", transaction)
+    }
+    if len(items) == 0 {
+        ir.log.Debug("No items provided, returning empty slice")
+        return items, nil
+    }
+    ir.log.Debug("Updating items", "count", len(items))
+    ir.log.Info("Saving items now...")
+    for i := range items {
+        if err := transaction.WithContext(ctx).Omit(clause.Associations).Save(&items[i]).Error; err != nil {
+            ir.log.Error("Failed to update item", "error", err, "item", items[i])
+            return nil, err
+        }
+    }
+    ir.log.Info("Successfully updated items", "count", len(items))
+    return items, nil
+}
+
+// ReplaceUOMs hard deletes the item's current UOM rows and inserts uoms in
+// their place, so a UOM that was dropped from the item really goes away.
+func (ir *itemRepo) ReplaceUOMs(ctx context.Context, tx *gorm.DB, item *types.Item, uoms []*types.ItemUOM) ([]*types.ItemUOM, error) {
+    ir.log.Info("Starting ReplaceUOMs for item...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = ir.db
+        ir.log.Debug("Transaction is nil, using ir.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).
+        Unscoped().
+        Where("item_id = ?", item.ID).
+        Delete(&types.ItemUOM{}).Error; err != nil {
+        ir.log.Error("Failed to delete old item UOMs", "error", err)
+        return nil, err
+    }
+    for _, u := range uoms {
+        u.ItemID = item.ID
+        u.CompanyID = item.CompanyID
+    }
+    if len(uoms) > 0 {
+        if err := transaction.WithContext(ctx).Create(&uoms).Error; err != nil {
+            ir.log.Error("Failed to create item UOMs", "error", err)
+            return nil, err
+        }
+    }
+    ir.log.Info("Successfully replaced item UOMs", "itemID", item.ID, "count", len(uoms))
+    return uoms, nil
+}
+
+func (ir *itemRepo) FullDeleteByIDs(ctx context.Context, tx *gorm.DB, itemIDs []uuid.UUID) error {
+    ir.log.Info("Starting FullDeleteByIDs for items now...")
+    transaction := tx
+    if transaction == nil {
+        transaction = ir.db
+    }
+    if len(itemIDs) == 0 {
+        ir.log.Debug("No itemIDs provided, skipping full delete")
+        return nil
+    }
+    ir.log.Debug("Full deleting items by IDs", "count", len(itemIDs), "itemIDs", itemIDs)
+    ir.log.Info("Performing FULL (hard) delete by itemIDs now...")
+    if err := transaction.WithContext(ctx).
+        Unscoped().
+        Where("id IN (?)", itemIDs).
+        Delete(&types.Item{}).Error; err != nil {
+        ir.log.Error("Failed to FULL delete items by IDs", "error", err)
+        return err
+    }
+    ir.log.Info("Successfully FULL deleted items by IDs", "count", len(itemIDs))
+    return nil
+}
+
+// orderUOMs keeps preloaded UOMs smallest first: each, inner, case, pallet.
+func orderUOMs(db *gorm.DB) *gorm.DB {
+    return db.Order("quantity ASC")
+}
+
+func escapeLike(s string) string {
+    return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,165 @@
+package repos
+
+import (
+    "context"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "github.com/slotter-org/slotter-backend/internal/logger"
+    "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// MoveTaskFilter narrows SearchTasks.
+type MoveTaskFilter struct {
+    PlanID          *uuid.UUID
+    AssignedToID    *uuid.UUID
+    Statuses        []types.MoveTaskStatus
+    Limit           int
+    Offset          int
+}
+
+type MoveTaskRepo interface {
+    CreateTasks(ctx context.Context, tx *gorm.DB, tasks []*types.MoveTask) ([]*types.MoveTask, error)
+    GetTasksByIDs(ctx context.Context, tx *gorm.DB, taskIDs []uuid.UUID) ([]*types.MoveTask, error)
+    GetTasksByPlanID(ctx context.Context, tx *gorm.DB, planID uuid.UUID) ([]*types.MoveTask, error)
+    SearchTasks(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filter MoveTaskFilter) ([]*types.MoveTask, int64, error)
+    UpdateTask(ctx context.Context, tx *gorm.DB, task *types.MoveTask) (*types.MoveTask, error)
+}
+
+type moveTaskRepo struct {
+    db          *gorm.DB
+    log         *logger.Logger
+}
+
+func NewMoveTaskRepo(db *gorm.DB, baseLog *logger.Logger) MoveTaskRepo {
+    repoLog := baseLog.With("repo", "MoveTaskRepo")
+    return &moveTaskRepo{db: db, log: repoLog}
+}
+
+func (mr *moveTaskRepo) CreateTasks(ctx context.Context, tx *gorm.DB, tasks []*types.MoveTask) ([]*types.MoveTask, error) {
+    mr.log.Info("Starting CreateTasks now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = mr.db
+        mr.log.Debug("Transaction is nil, using mr.db", "db", transaction)
+    }
+    if len(tasks) == 0 {
+        mr.log.Debug("No tasks provided, returning empty slice")
+        return []*types.MoveTask{}, nil
+    }
+    if err := transaction.WithContext(ctx).Omit(clause.Associations).CreateInBatches(&tasks, 1000).Error; err != nil {
+        mr.log.Error("Failed to create move tasks", "error", err)
+        return nil, err
+    }
+    mr.log.Info("Successfully created move tasks", "count", len(tasks))
+    return tasks, nil
+}
+
+// GetTasksByIDs locks the tasks, so two workers cannot act on one at once.
+func (mr *moveTaskRepo) GetTasksByIDs(ctx context.Context, tx *gorm.DB, taskIDs []uuid.UUID) ([]*types.MoveTask, error) {
+    mr.log.Info("Starting GetTasksByIDs now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = mr.db
+        mr.log.Debug("Transaction is nil, using mr.db", "db", transaction)
+    }
+    var results []*types.MoveTask
+    if len(taskIDs) == 0 {
+        mr.log.Debug("No taskIDs provided, returning empty slice")
+        return results, nil
+    }
+    if err := transaction.WithContext(ctx).
+        Clauses(clause.Locking{Strength: "UPDATE"}).
+        Where("id IN ?", taskIDs).
+        Find(&results).Error; err != nil {
+        mr.log.Error("Failed to fetch move tasks by IDs", "error", err)
+        return nil, err
+    }
+    mr.log.Info("Successfully fetched move tasks by IDs", "count", len(results))
+    return results, nil
+}
+
+func (mr *moveTaskRepo) GetTasksByPlanID(ctx context.Context, tx *gorm.DB, planID uuid.UUID) ([]*types.MoveTask, error) {
+    mr.log.Info("Starting GetTasksByPlanID now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = mr.db
+        mr.log.Debug("Transaction is nil, using mr.db", "db", transaction)
+    }
+    var results []*types.MoveTask
+    if err := transaction.WithContext(ctx).
+        Where("plan_id = ?", planID).
+        Order("priority DESC, sequence ASC, lot ASC").
+        Find(&results).Error; err != nil {
+        mr.log.Error("Failed to fetch move tasks by planID", "error", err)
+        return nil, err
+    }
+    mr.log.Info("Successfully fetched move tasks by planID", "count", len(results))
+    return results, nil
+}
+
+// SearchTasks pages through a warehouse's tasks in work order: highest
+// priority first, then plan sequence.
+func (mr *moveTaskRepo) SearchTasks(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filter MoveTaskFilter) ([]*types.MoveTask, int64, error) {
+    mr.log.Info("Starting SearchTasks now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = mr.db
+        mr.log.Debug("Transaction is nil, using mr.db", "db", transaction)
+    }
+    var results []*types.MoveTask
+    var total int64
+    matches := func(db *gorm.DB) *gorm.DB {
+        db = db.Where("warehouse_id = ?", warehouseID)
+        if filter.PlanID != nil {
+            db = db.Where("plan_id = ?", *filter.PlanID)
+        }
+        if filter.AssignedToID != nil {
+            db = db.Where("assigned_to_id = ?", *filter.AssignedToID)
+        }
+        if len(filter.Statuses) > 0 {
+            db = db.Where("status IN ?", filter.Statuses)
+        }
+        return db
+    }
+    if err := transaction.WithContext(ctx).Model(&types.MoveTask{}).Scopes(matches).Count(&total).Error; err != nil {
+        mr.log.Error("Failed to count move tasks", "error", err)
+        return nil, 0, err
+    }
+    if err := transaction.WithContext(ctx).
+        Scopes(matches).
+        Preload("Item").
+        Preload("FromLocation").
+        Preload("ToLocation").
+        Order("priority DESC, sequence ASC, lot ASC").
+        Limit(filter.Limit).
+        Offset(filter.Offset).
+        Find(&results).Error; err != nil {
+        mr.log.Error("Failed to search move tasks", "error", err)
+        return nil, 0, err
+    }
+    mr.log.Info("Successfully searched move tasks", "total", total, "returned", len(results))
+    return results, total, nil
+}
+
+func (mr *moveTaskRepo) UpdateTask(ctx context.Context, tx *gorm.DB, task *types.MoveTask) (*types.MoveTask, error) {
+    mr.log.Info("Starting UpdateTask now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = mr.db
+        mr.log.Debug("Transaction is nil, using mr.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).Omit(clause.Associations).Save(task).Error; err != nil {
+        mr.log.Error("Failed to update move task", "error", err)
+        return nil, err
+    }
+    mr.log.Info("Successfully updated move task", "taskID", task.ID)
+    return task, nil
+}
@@ -0,0 +1,100 @@
+package repos
+
+import (
+    "context"
+    "time"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "github.com/slotter-org/slotter-backend/internal/logger"
+    "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type OrderLineRepo interface {
+    Upsert(ctx context.Context, tx *gorm.DB, lines []*types.OrderLine) ([]*types.OrderLine, error)
+    GetInWindow(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, start time.Time, end time.Time) ([]*types.OrderLine, error)
+    CountByWarehouseID(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) (int64, error)
+}
+
+type orderLineRepo struct {
+    db          *gorm.DB
+    log         *logger.Logger
+}
+
+func NewOrderLineRepo(db *gorm.DB, baseLog *logger.Logger) OrderLineRepo {
+    repoLog := baseLog.With("repo", "OrderLineRepo")
+    return &orderLineRepo{db: db, log: repoLog}
+}
+
+// Upsert inserts lines, replacing quantity, timestamp and SKU of a line that
+// already exists for the same warehouse, order and item.
+func (olr *orderLineRepo) Upsert(ctx context.Context, tx *gorm.DB, lines []*types.OrderLine) ([]*types.OrderLine, error) {
+    olr.log.Info("Starting Upsert OrderLines now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = olr.db
+        olr.log.Debug("Transaction is nil, using olr.db", "db", transaction)
+    } else {
+        olr.log.Debug("Transaction is not nil", "transaction", transaction)
+    }
+    if len(lines) == 0 {
+        olr.log.Debug("No order lines provided, returning empty slice")
+        return []*types.OrderLine{}, nil
+    }
+    olr.log.Info("Upserting order lines now...", "count", len(lines))
+    if err := transaction.WithContext(ctx).
+        Clauses(clause.OnConflict{
+            Columns:   []clause.Column{{Name: "warehouse_id"}, {Name: "order_id"}, {Name: "item_id"}},
+            DoUpdates: clause.AssignmentColumns([]string{"sku", "quantity", "ordered_at", "updated_at"}),
+        }).
+        CreateInBatches(&lines, 1000).Error; err != nil {
+        olr.log.Error("Failed to upsert order lines", "error", err)
+        return nil, err
+    }
+    olr.log.Info("Successfully upserted order lines", "count", len(lines))
+    return lines, nil
+}
+
+// GetInWindow returns the lines ordered in [start, end). Only the columns the
+// velocity computation needs are loaded.
+func (olr *orderLineRepo) GetInWindow(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, start time.Time, end time.Time) ([]*types.OrderLine, error) {
+    olr.log.Info("Starting GetInWindow for order lines...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = olr.db
+        olr.log.Debug("Transaction is nil, using olr.db", "db", transaction)
+    }
+    var results []*types.OrderLine
+    if err := transaction.WithContext(ctx).
+        Select("order_id", "item_id", "quantity", "ordered_at").
+        Where("warehouse_id = ? AND ordered_at >= ? AND ordered_at < ?", warehouseID, start, end).
+        Find(&results).Error; err != nil {
+        olr.log.Error("Failed to fetch order lines in window", "error", err)
+        return nil, err
+    }
+    olr.log.Info("Successfully fetched order lines in window", "count", len(results))
+    return results, nil
+}
+
+func (olr *orderLineRepo) CountByWarehouseID(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) (int64, error) {
+    olr.log.Info("Starting CountByWarehouseID for order lines...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = olr.db
+        olr.log.Debug("Transaction is nil, using olr.db", "db", transaction)
+    }
+    var total int64
+    if err := transaction.WithContext(ctx).
+        Model(&types.OrderLine{}).
+        Where("warehouse_id = ?", warehouseID).
+        Count(&total).Error; err != nil {
+        olr.log.Error("Failed to count order lines", "error", err)
+        return 0, err
+    }
+    return total, nil
+}
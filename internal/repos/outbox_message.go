@@ -0,0 +1,236 @@
+package repos
+
+import (
+    "context"
+    "time"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "github.com/slotter-org/slotter-backend/internal/logger"
+    "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type OutboxMessageRepo interface {
+    Create(ctx context.Context, tx *gorm.DB, messages []*types.OutboxMessage) ([]*types.OutboxMessage, error)
+    GetByIDs(ctx context.Context, tx *gorm.DB, messageIDs []uuid.UUID) ([]*types.OutboxMessage, error)
+    GetByTenantAndStatuses(ctx context.Context, tx *gorm.DB, wmsID, companyID *uuid.UUID, statuses []types.OutboxStatus, limit int) ([]*types.OutboxMessage, error)
+    ClaimDue(ctx context.Context, tx *gorm.DB, now time.Time, lease time.Duration, limit int) ([]*types.OutboxMessage, error)
+    Update(ctx context.Context, tx *gorm.DB, messages []*types.OutboxMessage) ([]*types.OutboxMessage, error)
+    UpdateClaimed(ctx context.Context, tx *gorm.DB, message *types.OutboxMessage, lockedUntil time.Time) (bool, error)
+}
+
+type outboxMessageRepo struct {
+    db          *gorm.DB
+    log         *logger.Logger
+}
+
+func NewOutboxMessageRepo(db *gorm.DB, baseLog *logger.Logger) OutboxMessageRepo {
+    repoLog := baseLog.With("repo", "OutboxMessageRepo")
+    return &outboxMessageRepo{db: db, log: repoLog}
+}
+
+func (omr *outboxMessageRepo) Create(ctx context.Context, tx *gorm.DB, messages []*types.OutboxMessage) ([]*types.OutboxMessage, error) {
+    omr.log.Info("Starting Create OutboxMessages now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = omr.db
+        omr.log.Debug("Transaction is nil, using omr.db", "db", transaction)
+    } else {
+        omr.log.Debug("Transaction is not nil", "transaction", transaction)
+    }
+    if len(messages) == 0 {
+        omr.log.Debug("No outbox messages provided, returning empty slice")
+        return []*types.OutboxMessage{}, nil
+    }
+    omr.log.Debug("Outbox messages provided", "count", len(messages))
+
+    omr.log.Info("Creating outbox messages now...")
+    if err := transaction.WithContext(ctx).Create(&messages).Error; err != nil {
+        omr.log.Error("Failed to create outbox messages", "error", err)
+        return nil, err
+    }
+    omr.log.Info("Successfully created outbox messages", "count", len(messages))
+    return messages, nil
+}
+
+func (omr *outboxMessageRepo) GetByIDs(ctx context.Context, tx *gorm.DB, messageIDs []uuid.UUID) ([]*types.OutboxMessage, error) {
+    omr.log.Info("Starting GetByIDs for outbox messages...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = omr.db
+        omr.log.Debug("Transaction is nil, using omr.db", "db", transaction)
+    } else {
+        omr.log.Debug("Transaction is not nil", "transaction", transaction)
+    }
+    var results []*types.OutboxMessage
+    if len(messageIDs) == 0 {
+        omr.log.Debug("No messageIDs provided, returning empty slice")
+        return results, nil
+    }
+    omr.log.Debug("MessageIDs provided", "count", len(messageIDs), "messageIDs", messageIDs)
+    omr.log.Info("Fetching outbox messages by IDs now...")
+    if err := transaction.WithContext(ctx).
+        Clauses(clause.Locking{Strength: "UPDATE"}).
+        Where("id IN ?", messageIDs).
+        Find(&results).Error; err != nil {
+        omr.log.Error("Failed to fetch outbox messages by IDs", "error", err)
+        return nil, err
+    }
+    omr.log.Info("Successfully fetched outbox messages by IDs", "count", len(results))
+    return results, nil
+}
+
+// GetByTenantAndStatuses lists messages owned by exactly one of wmsID or
+// companyID, newest first.
+func (omr *outboxMessageRepo) GetByTenantAndStatuses(ctx context.Context, tx *gorm.DB, wmsID, companyID *uuid.UUID, statuses []types.OutboxStatus, limit int) ([]*types.OutboxMessage, error) {
+    omr.log.Info("Starting GetByTenantAndStatuses for outbox messages...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = omr.db
+        omr.log.Debug("Transaction is nil, using omr.db", "db", transaction)
+    } else {
+        omr.log.Debug("Transaction is not nil", "transaction", transaction)
+    }
+    var results []*types.OutboxMessage
+    query := transaction.WithContext(ctx).Model(&types.OutboxMessage{})
+    switch {
+    case wmsID != nil && *wmsID != uuid.Nil:
+        query = query.Where("wms_id = ?", *wmsID)
+    case companyID != nil && *companyID != uuid.Nil:
+        query = query.Where("company_id = ?", *companyID)
+    default:
+        omr.log.Debug("No tenant provided, returning empty slice")
+        return results, nil
+    }
+    if len(statuses) > 0 {
+        query = query.Where("status IN ?", statuses)
+    }
+    if limit > 0 {
+        query = query.Limit(limit)
+    }
+    omr.log.Debug("Fetching outbox messages by tenant", "wmsID", wmsID, "companyID", companyID, "statuses", statuses, "limit", limit)
+    if err := query.Order("updated_at DESC").Find(&results).Error; err != nil {
+        omr.log.Error("Failed to fetch outbox messages by tenant and statuses", "error", err)
+        return nil, err
+    }
+    omr.log.Info("Successfully fetched outbox messages by tenant and statuses", "count", len(results))
+    return results, nil
+}
+
+// ClaimDue locks up to limit deliverable messages with SKIP LOCKED, so several
+// dispatchers can poll the same table, and marks them processing until
+// now+lease. Messages stuck in processing past their lease are reclaimed.
+func (omr *outboxMessageRepo) ClaimDue(ctx context.Context, tx *gorm.DB, now time.Time, lease time.Duration, limit int) ([]*types.OutboxMessage, error) {
+    omr.log.Debug("Starting ClaimDue for outbox messages...", "limit", limit)
+
+    transaction := tx
+    if transaction == nil {
+        transaction = omr.db
+    }
+    var results []*types.OutboxMessage
+    if limit <= 0 {
+        return results, nil
+    }
+    err := transaction.WithContext(ctx).Transaction(func(inner *gorm.DB) error {
+        if err := inner.
+            Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+            Where(
+                "(status IN ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)",
+                []types.OutboxStatus{types.OutboxStatusPending, types.OutboxStatusFailed}, now,
+                types.OutboxStatusProcessing, now,
+            ).
+            Order("next_attempt_at ASC").
+            Limit(limit).
+            Find(&results).Error; err != nil {
+            return err
+        }
+        if len(results) == 0 {
+            return nil
+        }
+        // Postgres keeps microseconds, so the lease is stored exactly as
+        // UpdateClaimed will later compare it.
+        lockedUntil := now.Add(lease).Truncate(time.Microsecond)
+        ids := make([]uuid.UUID, 0, len(results))
+        for _, m := range results {
+            ids = append(ids, m.ID)
+            m.Status = types.OutboxStatusProcessing
+            m.LockedUntil = &lockedUntil
+        }
+        return inner.Model(&types.OutboxMessage{}).
+            Where("id IN ?", ids).
+            Updates(map[string]interface{}{
+                "status":       types.OutboxStatusProcessing,
+                "locked_until": lockedUntil,
+                "updated_at":   now,
+            }).Error
+    })
+    if err != nil {
+        omr.log.Error("Failed to claim due outbox messages", "error", err)
+        return nil, err
+    }
+    if len(results) > 0 {
+        omr.log.Info("Claimed due outbox messages", "count", len(results))
+    }
+    return results, nil
+}
+
+// UpdateClaimed records a delivery outcome only while message is still
+// processing under the lease it was claimed with, lockedUntil. It reports
+// false when the lease expired and another dispatcher reclaimed the message,
+// so a slow delivery cannot overwrite the newer attempt.
+func (omr *outboxMessageRepo) UpdateClaimed(ctx context.Context, tx *gorm.DB, message *types.OutboxMessage, lockedUntil time.Time) (bool, error) {
+    omr.log.Debug("Starting UpdateClaimed for outbox message...", "id", message.ID)
+
+    transaction := tx
+    if transaction == nil {
+        transaction = omr.db
+    }
+    result := transaction.WithContext(ctx).
+        Model(&types.OutboxMessage{}).
+        Where("id = ? AND status = ? AND locked_until = ?", message.ID, types.OutboxStatusProcessing, lockedUntil).
+        Updates(map[string]interface{}{
+            "status":          message.Status,
+            "attempts":        message.Attempts,
+            "next_attempt_at": message.NextAttemptAt,
+            "locked_until":    message.LockedUntil,
+            "last_error":      message.LastError,
+            "sent_at":         message.SentAt,
+            "dead_at":         message.DeadAt,
+            "updated_at":      time.Now().UTC(),
+        })
+    if result.Error != nil {
+        omr.log.Error("Failed to update claimed outbox message", "error", result.Error, "id", message.ID)
+        return false, result.Error
+    }
+    return result.RowsAffected > 0, nil
+}
+
+func (omr *outboxMessageRepo) Update(ctx context.Context, tx *gorm.DB, messages []*types.OutboxMessage) ([]*types.OutboxMessage, error) {
+    omr.log.Info("Starting Update OutboxMessages now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = omr.db
+        omr.log.Debug("Transaction is nil, using omr.db", "db", transaction)
+    } else {
+        omr.log.Debug("Transaction is not nil", "transaction", transaction)
+    }
+    if len(messages) == 0 {
+        omr.log.Debug("No outbox messages provided, returning empty slice")
+        return messages, nil
+    }
+    omr.log.Info("Saving outbox messages now...", "count", len(messages))
+    for i := range messages {
+        if err := transaction.WithContext(ctx).Save(&messages[i]).Error; err != nil {
+            omr.log.Error("Failed to update outbox message", "error", err, "id", messages[i].ID)
+            return nil, err
+        }
+    }
+    omr.log.Info("Successfully updated outbox messages", "count", len(messages))
+    return messages, nil
+}
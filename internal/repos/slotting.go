@@ -0,0 +1,286 @@
+package repos
+
+import (
+    "context"
+    "time"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "github.com/slotter-org/slotter-backend/internal/logger"
+    "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type SlottingRepo interface {
+    CreateJob(ctx context.Context, tx *gorm.DB, job *types.SlottingJob) (*types.SlottingJob, error)
+    GetJobsByIDs(ctx context.Context, tx *gorm.DB, jobIDs []uuid.UUID) ([]*types.SlottingJob, error)
+    GetJobsByWarehouseID(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) ([]*types.SlottingJob, error)
+    GetStaleJobs(ctx context.Context, tx *gorm.DB, statuses []types.SlottingJobStatus, updatedBefore time.Time) ([]*types.SlottingJob, error)
+    UpdateJob(ctx context.Context, tx *gorm.DB, job *types.SlottingJob) (*types.SlottingJob, error)
+    UpdateJobProgress(ctx context.Context, tx *gorm.DB, jobID uuid.UUID, progress float64, message string) (bool, error)
+    HeartbeatJob(ctx context.Context, tx *gorm.DB, jobID uuid.UUID) (bool, error)
+    FullDeleteJobsByIDs(ctx context.Context, tx *gorm.DB, jobIDs []uuid.UUID) error
+    CreateAssignments(ctx context.Context, tx *gorm.DB, assignments []*types.SlottingAssignment) ([]*types.SlottingAssignment, error)
+    GetAssignmentsByJobID(ctx context.Context, tx *gorm.DB, jobID uuid.UUID) ([]*types.SlottingAssignment, error)
+    CreateMoves(ctx context.Context, tx *gorm.DB, moves []*types.SlottingMove) ([]*types.SlottingMove, error)
+    GetMovesByJobID(ctx context.Context, tx *gorm.DB, jobID uuid.UUID) ([]*types.SlottingMove, error)
+}
+
+type slottingRepo struct {
+    db          *gorm.DB
+    log         *logger.Logger
+}
+
+func NewSlottingRepo(db *gorm.DB, baseLog *logger.Logger) SlottingRepo {
+    repoLog := baseLog.With("repo", "SlottingRepo")
+    return &slottingRepo{db: db, log: repoLog}
+}
+
+func (sr *slottingRepo) CreateJob(ctx context.Context, tx *gorm.DB, job *types.SlottingJob) (*types.SlottingJob, error) {
+    sr.log.Info("Starting CreateJob now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).Create(job).Error; err != nil {
+        sr.log.Error("Failed to create slotting job", "error", err)
+        return nil, err
+    }
+    sr.log.Info("Successfully created slotting job", "jobID", job.ID)
+    return job, nil
+}
+
+func (sr *slottingRepo) GetJobsByIDs(ctx context.Context, tx *gorm.DB, jobIDs []uuid.UUID) ([]*types.SlottingJob, error) {
+    sr.log.Info("Starting GetJobsByIDs now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    }
+    var results []*types.SlottingJob
+    if len(jobIDs) == 0 {
+        sr.log.Debug("No jobIDs provided, returning empty slice")
+        return results, nil
+    }
+    if err := transaction.WithContext(ctx).
+        Clauses(clause.Locking{Strength: "UPDATE"}).
+        Where("id IN ?", jobIDs).
+        Find(&results).Error; err != nil {
+        sr.log.Error("Failed to fetch slotting jobs by IDs", "error", err)
+        return nil, err
+    }
+    sr.log.Info("Successfully fetched slotting jobs by IDs", "count", len(results))
+    return results, nil
+}
+
+func (sr *slottingRepo) GetJobsByWarehouseID(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) ([]*types.SlottingJob, error) {
+    sr.log.Info("Starting GetJobsByWarehouseID now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    }
+    var results []*types.SlottingJob
+    if err := transaction.WithContext(ctx).
+        Where("warehouse_id = ?", warehouseID).
+        Order("created_at DESC").
+        Find(&results).Error; err != nil {
+        sr.log.Error("Failed to fetch slotting jobs by warehouseID", "error", err)
+        return nil, err
+    }
+    sr.log.Info("Successfully fetched slotting jobs by warehouseID", "count", len(results))
+    return results, nil
+}
+
+// GetStaleJobs returns the jobs in statuses whose last heartbeat is older
+// than updatedBefore, i.e. whose process has gone away.
+func (sr *slottingRepo) GetStaleJobs(ctx context.Context, tx *gorm.DB, statuses []types.SlottingJobStatus, updatedBefore time.Time) ([]*types.SlottingJob, error) {
+    sr.log.Info("Starting GetStaleJobs now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    }
+    var results []*types.SlottingJob
+    if len(statuses) == 0 {
+        return results, nil
+    }
+    if err := transaction.WithContext(ctx).
+        Where("status IN ? AND updated_at < ?", statuses, updatedBefore).
+        Find(&results).Error; err != nil {
+        sr.log.Error("Failed to fetch stale slotting jobs", "error", err)
+        return nil, err
+    }
+    sr.log.Info("Successfully fetched stale slotting jobs", "count", len(results))
+    return results, nil
+}
+
+func (sr *slottingRepo) UpdateJob(ctx context.Context, tx *gorm.DB, job *types.SlottingJob) (*types.SlottingJob, error) {
+    sr.log.Info("Starting UpdateJob now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).Save(job).Error; err != nil {
+        sr.log.Error("Failed to update slotting job", "error", err, "jobID", job.ID)
+        return nil, err
+    }
+    sr.log.Info("Successfully updated slotting job", "jobID", job.ID, "status", job.Status)
+    return job, nil
+}
+
+// UpdateJobProgress only touches the progress columns of a running job, so
+// a progress report racing a cancel or the final save never overwrites the
+// status. It reports false once the job is no longer running, e.g. because
+// another instance canceled it.
+func (sr *slottingRepo) UpdateJobProgress(ctx context.Context, tx *gorm.DB, jobID uuid.UUID, progress float64, message string) (bool, error) {
+    sr.log.Debug("Starting UpdateJobProgress now...", "jobID", jobID)
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+    }
+    result := transaction.WithContext(ctx).
+        Model(&types.SlottingJob{}).
+        Where("id = ? AND status = ?", jobID, types.SlottingJobRunning).
+        Updates(map[string]interface{}{"progress": progress, "message": message, "updated_at": time.Now().UTC()})
+    if result.Error != nil {
+        sr.log.Error("Failed to update slotting job progress", "error", result.Error, "jobID", jobID)
+        return false, result.Error
+    }
+    return result.RowsAffected > 0, nil
+}
+
+// HeartbeatJob marks a running job as still alive. It reports false once
+// the job is no longer running.
+func (sr *slottingRepo) HeartbeatJob(ctx context.Context, tx *gorm.DB, jobID uuid.UUID) (bool, error) {
+    sr.log.Debug("Starting HeartbeatJob now...", "jobID", jobID)
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+    }
+    result := transaction.WithContext(ctx).
+        Model(&types.SlottingJob{}).
+        Where("id = ? AND status = ?", jobID, types.SlottingJobRunning).
+        Update("updated_at", time.Now().UTC())
+    if result.Error != nil {
+        sr.log.Error("Failed to heartbeat slotting job", "error", result.Error, "jobID", jobID)
+        return false, result.Error
+    }
+    return result.RowsAffected > 0, nil
+}
+
+func (sr *slottingRepo) FullDeleteJobsByIDs(ctx context.Context, tx *gorm.DB, jobIDs []uuid.UUID) error {
+    sr.log.Info("Starting FullDeleteJobsByIDs now...")
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+    }
+    if len(jobIDs) == 0 {
+        sr.log.Debug("No jobIDs provided, skipping full delete")
+        return nil
+    }
+    if err := transaction.WithContext(ctx).
+        Unscoped().
+        Where("id IN (?)", jobIDs).
+        Delete(&types.SlottingJob{}).Error; err != nil {
+        sr.log.Error("Failed to FULL delete slotting jobs", "error", err)
+        return err
+    }
+    sr.log.Info("Successfully FULL deleted slotting jobs", "count", len(jobIDs))
+    return nil
+}
+
+func (sr *slottingRepo) CreateAssignments(ctx context.Context, tx *gorm.DB, assignments []*types.SlottingAssignment) ([]*types.SlottingAssignment, error) {
+    sr.log.Info("Starting CreateAssignments now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    }
+    if len(assignments) == 0 {
+        sr.log.Debug("No assignments provided, returning empty slice")
+        return []*types.SlottingAssignment{}, nil
+    }
+    if err := transaction.WithContext(ctx).CreateInBatches(&assignments, 1000).Error; err != nil {
+        sr.log.Error("Failed to create slotting assignments", "error", err)
+        return nil, err
+    }
+    sr.log.Info("Successfully created slotting assignments", "count", len(assignments))
+    return assignments, nil
+}
+
+func (sr *slottingRepo) GetAssignmentsByJobID(ctx context.Context, tx *gorm.DB, jobID uuid.UUID) ([]*types.SlottingAssignment, error) {
+    sr.log.Info("Starting GetAssignmentsByJobID now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    }
+    var results []*types.SlottingAssignment
+    if err := transaction.WithContext(ctx).
+        Preload("Item").
+        Preload("Location").
+        Where("job_id = ?", jobID).
+        Order("picks_per_day DESC, item_id ASC").
+        Find(&results).Error; err != nil {
+        sr.log.Error("Failed to fetch slotting assignments by jobID", "error", err)
+        return nil, err
+    }
+    sr.log.Info("Successfully fetched slotting assignments by jobID", "count", len(results))
+    return results, nil
+}
+
+func (sr *slottingRepo) CreateMoves(ctx context.Context, tx *gorm.DB, moves []*types.SlottingMove) ([]*types.SlottingMove, error) {
+    sr.log.Info("Starting CreateMoves now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    }
+    if len(moves) == 0 {
+        sr.log.Debug("No moves provided, returning empty slice")
+        return []*types.SlottingMove{}, nil
+    }
+    if err := transaction.WithContext(ctx).CreateInBatches(&moves, 1000).Error; err != nil {
+        sr.log.Error("Failed to create slotting moves", "error", err)
+        return nil, err
+    }
+    sr.log.Info("Successfully created slotting moves", "count", len(moves))
+    return moves, nil
+}
+
+func (sr *slottingRepo) GetMovesByJobID(ctx context.Context, tx *gorm.DB, jobID uuid.UUID) ([]*types.SlottingMove, error) {
+    sr.log.Info("Starting GetMovesByJobID now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    }
+    var results []*types.SlottingMove
+    if err := transaction.WithContext(ctx).
+        Preload("Item").
+        Preload("FromLocation").
+        Preload("ToLocation").
+        Where("job_id = ?", jobID).
+        Order("sequence ASC, lot ASC").
+        Find(&results).Error; err != nil {
+        sr.log.Error("Failed to fetch slotting moves by jobID", "error", err)
+        return nil, err
+    }
+    sr.log.Info("Successfully fetched slotting moves by jobID", "count", len(results))
+    return results, nil
+}
@@ -0,0 +1,238 @@
+package repos
+
+import (
+    "context"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "github.com/slotter-org/slotter-backend/internal/logger"
+    "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type SlottingScenarioRepo interface {
+    CreateScenario(ctx context.Context, tx *gorm.DB, scenario *types.SlottingScenario) (*types.SlottingScenario, error)
+    GetScenariosByIDs(ctx context.Context, tx *gorm.DB, scenarioIDs []uuid.UUID) ([]*types.SlottingScenario, error)
+    GetScenariosByWarehouseID(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) ([]*types.SlottingScenario, error)
+    GetScenarioByName(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, name string) (*types.SlottingScenario, error)
+    UpdateScenario(ctx context.Context, tx *gorm.DB, scenario *types.SlottingScenario) (*types.SlottingScenario, error)
+    FullDeleteScenariosByIDs(ctx context.Context, tx *gorm.DB, scenarioIDs []uuid.UUID) error
+    CreatePlan(ctx context.Context, tx *gorm.DB, plan *types.SlottingPlan) (*types.SlottingPlan, error)
+    GetPlansByIDs(ctx context.Context, tx *gorm.DB, planIDs []uuid.UUID) ([]*types.SlottingPlan, error)
+    GetPlansByWarehouseID(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) ([]*types.SlottingPlan, error)
+    UpdatePlan(ctx context.Context, tx *gorm.DB, plan *types.SlottingPlan) (*types.SlottingPlan, error)
+}
+
+type slottingScenarioRepo struct {
+    db          *gorm.DB
+    log         *logger.Logger
+}
+
+func NewSlottingScenarioRepo(db *gorm.DB, baseLog *logger.Logger) SlottingScenarioRepo {
+    repoLog := baseLog.With("repo", "SlottingScenarioRepo")
+    return &slottingScenarioRepo{db: db, log: repoLog}
+}
+
+func (sr *slottingScenarioRepo) CreateScenario(ctx context.Context, tx *gorm.DB, scenario *types.SlottingScenario) (*types.SlottingScenario, error) {
+    sr.log.Info("Starting CreateScenario now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).Omit(clause.Associations).Create(scenario).Error; err != nil {
+        sr.log.Error("Failed to create slotting scenario", "error", err)
+        return nil, err
+    }
+    sr.log.Info("Successfully created slotting scenario", "scenarioID", scenario.ID)
+    return scenario, nil
+}
+
+// GetScenariosByIDs loads full scenarios, snapshot included.
+func (sr *slottingScenarioRepo) GetScenariosByIDs(ctx context.Context, tx *gorm.DB, scenarioIDs []uuid.UUID) ([]*types.SlottingScenario, error) {
+    sr.log.Info("Starting GetScenariosByIDs now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    }
+    var results []*types.SlottingScenario
+    if len(scenarioIDs) == 0 {
+        sr.log.Debug("No scenarioIDs provided, returning empty slice")
+        return results, nil
+    }
+    if err := transaction.WithContext(ctx).
+        Clauses(clause.Locking{Strength: "UPDATE"}).
+        Where("id IN ?", scenarioIDs).
+        Find(&results).Error; err != nil {
+        sr.log.Error("Failed to fetch slotting scenarios by IDs", "error", err)
+        return nil, err
+    }
+    sr.log.Info("Successfully fetched slotting scenarios by IDs", "count", len(results))
+    return results, nil
+}
+
+// GetScenariosByWarehouseID lists scenarios with their latest job but
+// without their snapshots, which can be large.
+func (sr *slottingScenarioRepo) GetScenariosByWarehouseID(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) ([]*types.SlottingScenario, error) {
+    sr.log.Info("Starting GetScenariosByWarehouseID now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    }
+    var results []*types.SlottingScenario
+    if err := transaction.WithContext(ctx).
+        Omit("snapshot").
+        Preload("Job").
+        Where("warehouse_id = ?", warehouseID).
+        Order("created_at DESC").
+        Find(&results).Error; err != nil {
+        sr.log.Error("Failed to fetch slotting scenarios by warehouseID", "error", err)
+        return nil, err
+    }
+    sr.log.Info("Successfully fetched slotting scenarios by warehouseID", "count", len(results))
+    return results, nil
+}
+
+// GetScenarioByName returns nil without an error when no scenario of the
+// warehouse has that name.
+func (sr *slottingScenarioRepo) GetScenarioByName(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, name string) (*types.SlottingScenario, error) {
+    sr.log.Info("Starting GetScenarioByName now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    }
+    var results []*types.SlottingScenario
+    if err := transaction.WithContext(ctx).
+        Omit("snapshot").
+        Where("warehouse_id = ? AND name = ?", warehouseID, name).
+        Limit(1).
+        Find(&results).Error; err != nil {
+        sr.log.Error("Failed to fetch slotting scenario by name", "error", err)
+        return nil, err
+    }
+    if len(results) == 0 {
+        return nil, nil
+    }
+    return results[0], nil
+}
+
+func (sr *slottingScenarioRepo) UpdateScenario(ctx context.Context, tx *gorm.DB, scenario *types.SlottingScenario) (*types.SlottingScenario, error) {
+    sr.log.Info("Starting UpdateScenario now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).Omit(clause.Associations).Save(scenario).Error; err != nil {
+        sr.log.Error("Failed to update slotting scenario", "error", err, "scenarioID", scenario.ID)
+        return nil, err
+    }
+    sr.log.Info("Successfully updated slotting scenario", "scenarioID", scenario.ID)
+    return scenario, nil
+}
+
+func (sr *slottingScenarioRepo) FullDeleteScenariosByIDs(ctx context.Context, tx *gorm.DB, scenarioIDs []uuid.UUID) error {
+    sr.log.Info("Starting FullDeleteScenariosByIDs now...")
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+    }
+    if len(scenarioIDs) == 0 {
+        sr.log.Debug("No scenarioIDs provided, skipping full delete")
+        return nil
+    }
+    if err := transaction.WithContext(ctx).
+        Unscoped().
+        Where("id IN (?)", scenarioIDs).
+        Delete(&types.SlottingScenario{}).Error; err != nil {
+        sr.log.Error("Failed to FULL delete slotting scenarios", "error", err)
+        return err
+    }
+    sr.log.Info("Successfully FULL deleted slotting scenarios", "count", len(scenarioIDs))
+    return nil
+}
+
+func (sr *slottingScenarioRepo) CreatePlan(ctx context.Context, tx *gorm.DB, plan *types.SlottingPlan) (*types.SlottingPlan, error) {
+    sr.log.Info("Starting CreatePlan now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).Create(plan).Error; err != nil {
+        sr.log.Error("Failed to create slotting plan", "error", err)
+        return nil, err
+    }
+    sr.log.Info("Successfully created slotting plan", "planID", plan.ID)
+    return plan, nil
+}
+
+func (sr *slottingScenarioRepo) GetPlansByIDs(ctx context.Context, tx *gorm.DB, planIDs []uuid.UUID) ([]*types.SlottingPlan, error) {
+    sr.log.Info("Starting GetPlansByIDs now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    }
+    var results []*types.SlottingPlan
+    if len(planIDs) == 0 {
+        sr.log.Debug("No planIDs provided, returning empty slice")
+        return results, nil
+    }
+    if err := transaction.WithContext(ctx).
+        Clauses(clause.Locking{Strength: "UPDATE"}).
+        Where("id IN ?", planIDs).
+        Find(&results).Error; err != nil {
+        sr.log.Error("Failed to fetch slotting plans by IDs", "error", err)
+        return nil, err
+    }
+    sr.log.Info("Successfully fetched slotting plans by IDs", "count", len(results))
+    return results, nil
+}
+
+func (sr *slottingScenarioRepo) GetPlansByWarehouseID(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) ([]*types.SlottingPlan, error) {
+    sr.log.Info("Starting GetPlansByWarehouseID now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    }
+    var results []*types.SlottingPlan
+    if err := transaction.WithContext(ctx).
+        Where("warehouse_id = ?", warehouseID).
+        Order("approved_at DESC").
+        Find(&results).Error; err != nil {
+        sr.log.Error("Failed to fetch slotting plans by warehouseID", "error", err)
+        return nil, err
+    }
+    sr.log.Info("Successfully fetched slotting plans by warehouseID", "count", len(results))
+    return results, nil
+}
+
+func (sr *slottingScenarioRepo) UpdatePlan(ctx context.Context, tx *gorm.DB, plan *types.SlottingPlan) (*types.SlottingPlan, error) {
+    sr.log.Info("Starting UpdatePlan now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).Save(plan).Error; err != nil {
+        sr.log.Error("Failed to update slotting plan", "error", err, "planID", plan.ID)
+        return nil, err
+    }
+    sr.log.Info("Successfully updated slotting plan", "planID", plan.ID, "status", plan.Status)
+    return plan, nil
+}
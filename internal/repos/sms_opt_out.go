@@ -0,0 +1,101 @@
+package repos
+
+import (
+    "context"
+
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "github.com/slotter-org/slotter-backend/internal/logger"
+    "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type SmsOptOutRepo interface {
+    GetByPhoneNumbers(ctx context.Context, tx *gorm.DB, phoneNumbers []string) ([]*types.SmsOptOut, error)
+    IsOptedOut(ctx context.Context, tx *gorm.DB, phoneNumber string) (bool, error)
+    Upsert(ctx context.Context, tx *gorm.DB, optOut *types.SmsOptOut) (*types.SmsOptOut, error)
+}
+
+type smsOptOutRepo struct {
+    db          *gorm.DB
+    log         *logger.Logger
+}
+
+func NewSmsOptOutRepo(db *gorm.DB, baseLog *logger.Logger) SmsOptOutRepo {
+    repoLog := baseLog.With("repo", "SmsOptOutRepo")
+    return &smsOptOutRepo{db: db, log: repoLog}
+}
+
+func (sr *smsOptOutRepo) GetByPhoneNumbers(ctx context.Context, tx *gorm.DB, phoneNumbers []string) ([]*types.SmsOptOut, error) {
+    sr.log.Info("Starting GetByPhoneNumbers for sms opt outs...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    } else {
+        sr.log.Debug("Transaction is not nil", "transaction", transaction)
+    }
+    var results []*types.SmsOptOut
+    if len(phoneNumbers) == 0 {
+        sr.log.Debug("No phoneNumbers provided, returning empty slice")
+        return results, nil
+    }
+    sr.log.Debug("PhoneNumbers provided", "count", len(phoneNumbers))
+    if err := transaction.WithContext(ctx).
+        Clauses(clause.Locking{Strength: "UPDATE"}).
+        Where("phone_number IN ?", phoneNumbers).
+        Find(&results).Error; err != nil {
+        sr.log.Error("Failed to fetch sms opt outs by phone numbers", "error", err)
+        return nil, err
+    }
+    sr.log.Info("Successfully fetched sms opt outs by phone numbers", "count", len(results))
+    return results, nil
+}
+
+func (sr *smsOptOutRepo) IsOptedOut(ctx context.Context, tx *gorm.DB, phoneNumber string) (bool, error) {
+    sr.log.Info("Checking if phone number has opted out of sms...")
+    if phoneNumber == "" {
+        sr.log.Warn("Phone number is empty, returning false early")
+        return false, nil
+    }
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Using sr.db because transaction is nil")
+    }
+    var count int64
+    if err := transaction.WithContext(ctx).
+        Model(&types.SmsOptOut{}).
+        Where("phone_number = ? AND opted_out = ?", phoneNumber, true).
+        Count(&count).Error; err != nil {
+        sr.log.Error("Failed to count sms opt outs", "error", err)
+        return false, err
+    }
+    sr.log.Debug("IsOptedOut completed", "phoneNumber", phoneNumber, "count", count)
+    return count > 0, nil
+}
+
+// Upsert creates or replaces the opt-out row for optOut.PhoneNumber.
+func (sr *smsOptOutRepo) Upsert(ctx context.Context, tx *gorm.DB, optOut *types.SmsOptOut) (*types.SmsOptOut, error) {
+    sr.log.Info("Starting Upsert SmsOptOut now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = sr.db
+        sr.log.Debug("Transaction is nil, using sr.db", "db", transaction)
+    } else {
+        sr.log.Debug("Transaction is not nil", "transaction", transaction)
+    }
+    if err := transaction.WithContext(ctx).
+        Clauses(clause.OnConflict{
+            Columns:   []clause.Column{{Name: "phone_number"}},
+            DoUpdates: clause.AssignmentColumns([]string{"opted_out", "keyword", "provider", "opted_out_at", "opted_in_at", "updated_at"}),
+        }).
+        Create(optOut).Error; err != nil {
+        sr.log.Error("Failed to upsert sms opt out", "error", err)
+        return nil, err
+    }
+    sr.log.Info("Successfully upserted sms opt out", "phoneNumber", optOut.PhoneNumber, "optedOut", optOut.OptedOut)
+    return optOut, nil
+}
@@ -29,6 +29,9 @@ type UserRepo interface {
     GetByRoleIDs(ctx context.Context, tx *gorm.DB, roleIDs []uuid.UUID) ([]*types.User, error)
     GetByRoles(ctx context.Context, tx *gorm.DB, roles []*types.Role) ([]*types.User, error)
 
+    // UPDATE
+    Update(ctx context.Context, tx *gorm.DB, users []*types.User) ([]*types.User, error)
+
     // SOFT DELETE
     SoftDeleteByUsers(ctx context.Context, tx *gorm.DB, users []*types.User) error
     SoftDeleteByIDs(ctx context.Context, tx *gorm.DB, userIDs []uuid.UUID) error
@@ -417,6 +420,38 @@ func (ur *userRepo) GetByRoles(ctx context.Context, tx *gorm.DB, roles []*types.
     return results, nil
 }
 
+// ----------------------------------------------------------------
+// UPDATE
+// ----------------------------------------------------------------
+
+func (ur *userRepo) Update(ctx context.Context, tx *gorm.DB, users []*types.User) ([]*types.User, error) {
+    ur.log.Info("Starting Update Users now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = ur.db
+        ur.log.Debug("Transaction is nil, using ur.db", "db", transaction)
+    } else {
+        ur.log.Debug("Transaction is not nil", "transaction", transaction)
+    }
+
+    if len(users) == 0 {
+        ur.log.Debug("No users provided, returning empty slice")
+        return users, nil
+    }
+    ur.log.Debug("Updating users", "count", len(users))
+
+    ur.log.Info("Saving users now...")
+    for i := range users {
+        if err := transaction.WithContext(ctx).Save(&users[i]).Error; err != nil {
+            ur.log.Error("Failed to update user", "error", err, "userID", users[i].ID)
+            return nil, err
+        }
+    }
+    ur.log.Info("Successfully updated users", "count", len(users))
+    return users, nil
+}
+
 // ----------------------------------------------------------------
 // SOFT DELETE
 // ----------------------------------------------------------------
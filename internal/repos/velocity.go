@@ -0,0 +1,339 @@
+package repos
+
+import (
+    "context"
+    "time"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "github.com/slotter-org/slotter-backend/internal/logger"
+    "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// VelocityFilter narrows SearchStats.
+type VelocityFilter struct {
+    ItemID          *uuid.UUID
+    ABCClass        string
+    XYZClass        string
+    Limit           int
+    Offset          int
+}
+
+type VelocityRepo interface {
+    CreateRun(ctx context.Context, tx *gorm.DB, run *types.VelocityRun) (*types.VelocityRun, error)
+    GetRunsByIDs(ctx context.Context, tx *gorm.DB, runIDs []uuid.UUID) ([]*types.VelocityRun, error)
+    GetRunsByWarehouseID(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) ([]*types.VelocityRun, error)
+    GetStaleRuns(ctx context.Context, tx *gorm.DB, statuses []types.VelocityRunStatus, updatedBefore time.Time) ([]*types.VelocityRun, error)
+    GetLatestCompletedRun(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) (*types.VelocityRun, error)
+    UpdateRun(ctx context.Context, tx *gorm.DB, run *types.VelocityRun) (*types.VelocityRun, error)
+    HeartbeatRun(ctx context.Context, tx *gorm.DB, runID uuid.UUID) error
+    FullDeleteRunsByIDs(ctx context.Context, tx *gorm.DB, runIDs []uuid.UUID) error
+    CreateStats(ctx context.Context, tx *gorm.DB, stats []*types.SKUVelocity) ([]*types.SKUVelocity, error)
+    CreateAffinities(ctx context.Context, tx *gorm.DB, affinities []*types.SKUAffinity) ([]*types.SKUAffinity, error)
+    GetStatsByRunID(ctx context.Context, tx *gorm.DB, runID uuid.UUID) ([]*types.SKUVelocity, error)
+    SearchStats(ctx context.Context, tx *gorm.DB, runID uuid.UUID, filter VelocityFilter) ([]*types.SKUVelocity, int64, error)
+    GetAffinities(ctx context.Context, tx *gorm.DB, runID uuid.UUID, itemID *uuid.UUID) ([]*types.SKUAffinity, error)
+}
+
+type velocityRepo struct {
+    db          *gorm.DB
+    log         *logger.Logger
+}
+
+func NewVelocityRepo(db *gorm.DB, baseLog *logger.Logger) VelocityRepo {
+    repoLog := baseLog.With("repo", "VelocityRepo")
+    return &velocityRepo{db: db, log: repoLog}
+}
+
+func (vr *velocityRepo) CreateRun(ctx context.Context, tx *gorm.DB, run *types.VelocityRun) (*types.VelocityRun, error) {
+    vr.log.Info("Starting CreateRun now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = vr.db
+        vr.log.Debug("Transaction is nil, using vr.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).Create(run).Error; err != nil {
+        vr.log.Error("Failed to create velocity run", "error", err)
+        return nil, err
+    }
+    vr.log.Info("Successfully created velocity run", "runID", run.ID)
+    return run, nil
+}
+
+func (vr *velocityRepo) GetRunsByIDs(ctx context.Context, tx *gorm.DB, runIDs []uuid.UUID) ([]*types.VelocityRun, error) {
+    vr.log.Info("Starting GetRunsByIDs now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = vr.db
+        vr.log.Debug("Transaction is nil, using vr.db", "db", transaction)
+    }
+    var results []*types.VelocityRun
+    if len(runIDs) == 0 {
+        vr.log.Debug("No runIDs provided, returning empty slice")
+        return results, nil
+    }
+    if err := transaction.WithContext(ctx).
+        Clauses(clause.Locking{Strength: "UPDATE"}).
+        Where("id IN ?", runIDs).
+        Find(&results).Error; err != nil {
+        vr.log.Error("Failed to fetch velocity runs by IDs", "error", err)
+        return nil, err
+    }
+    vr.log.Info("Successfully fetched velocity runs by IDs", "count", len(results))
+    return results, nil
+}
+
+func (vr *velocityRepo) GetRunsByWarehouseID(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) ([]*types.VelocityRun, error) {
+    vr.log.Info("Starting GetRunsByWarehouseID now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = vr.db
+        vr.log.Debug("Transaction is nil, using vr.db", "db", transaction)
+    }
+    var results []*types.VelocityRun
+    if err := transaction.WithContext(ctx).
+        Where("warehouse_id = ?", warehouseID).
+        Order("created_at DESC").
+        Find(&results).Error; err != nil {
+        vr.log.Error("Failed to fetch velocity runs by warehouseID", "error", err)
+        return nil, err
+    }
+    vr.log.Info("Successfully fetched velocity runs by warehouseID", "count", len(results))
+    return results, nil
+}
+
+// GetStaleRuns returns the runs in statuses whose last heartbeat is older
+// than updatedBefore, i.e. whose process has gone away.
+func (vr *velocityRepo) GetStaleRuns(ctx context.Context, tx *gorm.DB, statuses []types.VelocityRunStatus, updatedBefore time.Time) ([]*types.VelocityRun, error) {
+    vr.log.Info("Starting GetStaleRuns now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = vr.db
+        vr.log.Debug("Transaction is nil, using vr.db", "db", transaction)
+    }
+    var results []*types.VelocityRun
+    if len(statuses) == 0 {
+        return results, nil
+    }
+    if err := transaction.WithContext(ctx).
+        Where("status IN ? AND updated_at < ?", statuses, updatedBefore).
+        Find(&results).Error; err != nil {
+        vr.log.Error("Failed to fetch stale velocity runs", "error", err)
+        return nil, err
+    }
+    vr.log.Info("Successfully fetched stale velocity runs", "count", len(results))
+    return results, nil
+}
+
+// GetLatestCompletedRun returns nil without an error when the warehouse has
+// no completed run yet.
+func (vr *velocityRepo) GetLatestCompletedRun(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) (*types.VelocityRun, error) {
+    vr.log.Info("Starting GetLatestCompletedRun now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = vr.db
+        vr.log.Debug("Transaction is nil, using vr.db", "db", transaction)
+    }
+    var results []*types.VelocityRun
+    if err := transaction.WithContext(ctx).
+        Where("warehouse_id = ? AND status = ?", warehouseID, types.VelocityRunCompleted).
+        Order("completed_at DESC").
+        Limit(1).
+        Find(&results).Error; err != nil {
+        vr.log.Error("Failed to fetch latest completed velocity run", "error", err)
+        return nil, err
+    }
+    if len(results) == 0 {
+        vr.log.Debug("No completed velocity run found", "warehouseID", warehouseID)
+        return nil, nil
+    }
+    return results[0], nil
+}
+
+func (vr *velocityRepo) UpdateRun(ctx context.Context, tx *gorm.DB, run *types.VelocityRun) (*types.VelocityRun, error) {
+    vr.log.Info("Starting UpdateRun now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = vr.db
+        vr.log.Debug("Transaction is nil, using vr.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).Save(run).Error; err != nil {
+        vr.log.Error("Failed to update velocity run", "error", err, "runID", run.ID)
+        return nil, err
+    }
+    vr.log.Info("Successfully updated velocity run", "runID", run.ID, "status", run.Status)
+    return run, nil
+}
+
+// HeartbeatRun marks a running run as still alive.
+func (vr *velocityRepo) HeartbeatRun(ctx context.Context, tx *gorm.DB, runID uuid.UUID) error {
+    vr.log.Debug("Starting HeartbeatRun now...", "runID", runID)
+
+    transaction := tx
+    if transaction == nil {
+        transaction = vr.db
+    }
+    if err := transaction.WithContext(ctx).
+        Model(&types.VelocityRun{}).
+        Where("id = ? AND status = ?", runID, types.VelocityRunRunning).
+        Update("updated_at", time.Now().UTC()).Error; err != nil {
+        vr.log.Error("Failed to heartbeat velocity run", "error", err, "runID", runID)
+        return err
+    }
+    return nil
+}
+
+func (vr *velocityRepo) FullDeleteRunsByIDs(ctx context.Context, tx *gorm.DB, runIDs []uuid.UUID) error {
+    vr.log.Info("Starting FullDeleteRunsByIDs now...")
+    transaction := tx
+    if transaction == nil {
+        transaction = vr.db
+    }
+    if len(runIDs) == 0 {
+        vr.log.Debug("No runIDs provided, skipping full delete")
+        return nil
+    }
+    if err := transaction.WithContext(ctx).
+        Unscoped().
+        Where("id IN (?)", runIDs).
+        Delete(&types.VelocityRun{}).Error; err != nil {
+        vr.log.Error("Failed to FULL delete velocity runs", "error", err)
+        return err
+    }
+    vr.log.Info("Successfully FULL deleted velocity runs", "count", len(runIDs))
+    return nil
+}
+
+func (vr *velocityRepo) CreateStats(ctx context.Context, tx *gorm.DB, stats []*types.SKUVelocity) ([]*types.SKUVelocity, error) {
+    vr.log.Info("Starting CreateStats now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = vr.db
+        vr.log.Debug("Transaction is nil, using vr.db", "db", transaction)
+    }
+    if len(stats) == 0 {
+        vr.log.Debug("No stats provided, returning empty slice")
+        return []*types.SKUVelocity{}, nil
+    }
+    if err := transaction.WithContext(ctx).CreateInBatches(&stats, 1000).Error; err != nil {
+        vr.log.Error("Failed to create SKU velocity stats", "error", err)
+        return nil, err
+    }
+    vr.log.Info("Successfully created SKU velocity stats", "count", len(stats))
+    return stats, nil
+}
+
+func (vr *velocityRepo) CreateAffinities(ctx context.Context, tx *gorm.DB, affinities []*types.SKUAffinity) ([]*types.SKUAffinity, error) {
+    vr.log.Info("Starting CreateAffinities now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = vr.db
+        vr.log.Debug("Transaction is nil, using vr.db", "db", transaction)
+    }
+    if len(affinities) == 0 {
+        vr.log.Debug("No affinities provided, returning empty slice")
+        return []*types.SKUAffinity{}, nil
+    }
+    if err := transaction.WithContext(ctx).CreateInBatches(&affinities, 1000).Error; err != nil {
+        vr.log.Error("Failed to create SKU affinities", "error", err)
+        return nil, err
+    }
+    vr.log.Info("Successfully created SKU affinities", "count", len(affinities))
+    return affinities, nil
+}
+
+func (vr *velocityRepo) GetStatsByRunID(ctx context.Context, tx *gorm.DB, runID uuid.UUID) ([]*types.SKUVelocity, error) {
+    vr.log.Info("Starting GetStatsByRunID now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = vr.db
+        vr.log.Debug("Transaction is nil, using vr.db", "db", transaction)
+    }
+    var results []*types.SKUVelocity
+    if err := transaction.WithContext(ctx).
+        Where("run_id = ?", runID).
+        Order("rank ASC").
+        Find(&results).Error; err != nil {
+        vr.log.Error("Failed to fetch SKU velocity stats by runID", "error", err)
+        return nil, err
+    }
+    vr.log.Info("Successfully fetched SKU velocity stats by runID", "count", len(results))
+    return results, nil
+}
+
+func (vr *velocityRepo) SearchStats(ctx context.Context, tx *gorm.DB, runID uuid.UUID, filter VelocityFilter) ([]*types.SKUVelocity, int64, error) {
+    vr.log.Info("Starting SearchStats now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = vr.db
+        vr.log.Debug("Transaction is nil, using vr.db", "db", transaction)
+    }
+    var results []*types.SKUVelocity
+    var total int64
+    matches := func(db *gorm.DB) *gorm.DB {
+        db = db.Where("run_id = ?", runID)
+        if filter.ItemID != nil {
+            db = db.Where("item_id = ?", *filter.ItemID)
+        }
+        if filter.ABCClass != "" {
+            db = db.Where("abc_class = ?", filter.ABCClass)
+        }
+        if filter.XYZClass != "" {
+            db = db.Where("xyz_class = ?", filter.XYZClass)
+        }
+        return db
+    }
+    if err := transaction.WithContext(ctx).Model(&types.SKUVelocity{}).Scopes(matches).Count(&total).Error; err != nil {
+        vr.log.Error("Failed to count SKU velocity stats", "error", err)
+        return nil, 0, err
+    }
+    if err := transaction.WithContext(ctx).
+        Scopes(matches).
+        Preload("Item").
+        Order("rank ASC").
+        Limit(filter.Limit).
+        Offset(filter.Offset).
+        Find(&results).Error; err != nil {
+        vr.log.Error("Failed to search SKU velocity stats", "error", err)
+        return nil, 0, err
+    }
+    vr.log.Info("Successfully searched SKU velocity stats", "total", total, "returned", len(results))
+    return results, total, nil
+}
+
+// GetAffinities returns the affinities of a run, strongest first per item.
+// With itemID set only that item's partners are returned.
+func (vr *velocityRepo) GetAffinities(ctx context.Context, tx *gorm.DB, runID uuid.UUID, itemID *uuid.UUID) ([]*types.SKUAffinity, error) {
+    vr.log.Info("Starting GetAffinities now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = vr.db
+        vr.log.Debug("Transaction is nil, using vr.db", "db", transaction)
+    }
+    var results []*types.SKUAffinity
+    query := transaction.WithContext(ctx).Where("run_id = ?", runID)
+    if itemID != nil {
+        query = query.Where("item_id = ?", *itemID)
+    }
+    if err := query.
+        Order("item_id ASC, co_occurrences DESC, lift DESC").
+        Find(&results).Error; err != nil {
+        vr.log.Error("Failed to fetch SKU affinities", "error", err)
+        return nil, err
+    }
+    vr.log.Info("Successfully fetched SKU affinities", "count", len(results))
+    return results, nil
+}
@@ -0,0 +1,279 @@
+package repos
+
+import (
+    "context"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "github.com/slotter-org/slotter-backend/internal/logger"
+    "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// LocationFilter narrows GetByWarehouseID. Zero values are ignored; RootsOnly
+// selects locations without a parent (zones).
+type LocationFilter struct {
+    Kind            types.LocationKind
+    LocationType    types.LocationType
+    ParentID        *uuid.UUID
+    RootsOnly       bool
+    CodePrefix      string
+}
+
+type WarehouseLocationRepo interface {
+    Create(ctx context.Context, tx *gorm.DB, locations []*types.WarehouseLocation) ([]*types.WarehouseLocation, error)
+    GetByIDs(ctx context.Context, tx *gorm.DB, locationIDs []uuid.UUID) ([]*types.WarehouseLocation, error)
+    GetByWarehouseID(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filter LocationFilter) ([]*types.WarehouseLocation, error)
+    GetSiblingCodes(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, parentID *uuid.UUID) ([]string, error)
+    GetExistingFullCodes(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, fullCodes []string) ([]string, error)
+    GetMaxPickSequence(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) (int, error)
+    Update(ctx context.Context, tx *gorm.DB, locations []*types.WarehouseLocation) ([]*types.WarehouseLocation, error)
+    ReplaceFullCodePrefix(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, oldPrefix string, newPrefix string) (int64, error)
+    FullDeleteByIDs(ctx context.Context, tx *gorm.DB, locationIDs []uuid.UUID) error
+}
+
+type warehouseLocationRepo struct {
+    db          *gorm.DB
+    log         *logger.Logger
+}
+
+func NewWarehouseLocationRepo(db *gorm.DB, baseLog *logger.Logger) WarehouseLocationRepo {
+    repoLog := baseLog.With("repo", "WarehouseLocationRepo")
+    return &warehouseLocationRepo{db: db, log: repoLog}
+}
+
+func (lr *warehouseLocationRepo) Create(ctx context.Context, tx *gorm.DB, locations []*types.WarehouseLocation) ([]*types.WarehouseLocation, error) {
+    lr.log.Info("Starting Create WarehouseLocations now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = lr.db
+        lr.log.Debug("Transaction is nil, using lr.db", "db", transaction)
+    } else {
+        lr.log.Debug("Transaction is not nil", "transaction", transaction)
+    }
+    if len(locations) == 0 {
+        lr.log.Debug("No locations provided, returning empty slice")
+        return []*types.WarehouseLocation{}, nil
+    }
+    lr.log.Debug("Locations provided", "count", len(locations))
+
+    lr.log.Info("Creating locations now...")
+    if err := transaction.WithContext(ctx).CreateInBatches(&locations, 500).Error; err != nil {
+        lr.log.Error("Failed to create locations", "error", err)
+        return nil, err
+    }
+    lr.log.Info("Successfully created locations", "count", len(locations))
+    return locations, nil
+}
+
+func (lr *warehouseLocationRepo) GetByIDs(ctx context.Context, tx *gorm.DB, locationIDs []uuid.UUID) ([]*types.WarehouseLocation, error) {
+    lr.log.Info("Starting GetByIDs for locations...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = lr.db
+        lr.log.Debug("Transaction is nil, using lr.db", "db", transaction)
+    } else {
+        lr.log.Debug("Transaction is not nil", "transaction", transaction)
+    }
+    var results []*types.WarehouseLocation
+    if len(locationIDs) == 0 {
+        lr.log.Debug("No locationIDs provided, returning empty slice")
+        return results, nil
+    }
+    lr.log.Debug("LocationIDs provided", "count", len(locationIDs), "locationIDs", locationIDs)
+    lr.log.Info("Fetching locations by IDs now...")
+    if err := transaction.WithContext(ctx).
+        Clauses(clause.Locking{Strength: "UPDATE"}).
+        Where("id IN ?", locationIDs).
+        Find(&results).Error; err != nil {
+        lr.log.Error("Failed to fetch locations by IDs", "error", err)
+        return nil, err
+    }
+    lr.log.Info("Successfully fetched locations by IDs", "count", len(results))
+    return results, nil
+}
+
+func (lr *warehouseLocationRepo) GetByWarehouseID(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filter LocationFilter) ([]*types.WarehouseLocation, error) {
+    lr.log.Info("Starting GetByWarehouseID for locations...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = lr.db
+        lr.log.Debug("Transaction is nil, using lr.db", "db", transaction)
+    } else {
+        lr.log.Debug("Transaction is not nil", "transaction", transaction)
+    }
+    var results []*types.WarehouseLocation
+    if warehouseID == uuid.Nil {
+        lr.log.Debug("warehouseID is nil, returning empty slice")
+        return results, nil
+    }
+    lr.log.Debug("Fetching locations for warehouse", "warehouseID", warehouseID, "filter", filter)
+    query := transaction.WithContext(ctx).Where("warehouse_id = ?", warehouseID)
+    if filter.Kind != "" {
+        query = query.Where("kind = ?", filter.Kind)
+    }
+    if filter.LocationType != "" {
+        query = query.Where("location_type = ?", filter.LocationType)
+    }
+    if filter.ParentID != nil {
+        query = query.Where("parent_id = ?", *filter.ParentID)
+    } else if filter.RootsOnly {
+        query = query.Where("parent_id IS NULL")
+    }
+    if filter.CodePrefix != "" {
+        query = query.Where("full_code LIKE ?", filter.CodePrefix+"%")
+    }
+    if err := query.Order("full_code ASC").Find(&results).Error; err != nil {
+        lr.log.Error("Failed to fetch locations by warehouseID", "error", err)
+        return nil, err
+    }
+    lr.log.Info("Successfully fetched locations by warehouseID", "count", len(results))
+    return results, nil
+}
+
+func (lr *warehouseLocationRepo) GetSiblingCodes(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, parentID *uuid.UUID) ([]string, error) {
+    lr.log.Info("Starting GetSiblingCodes for locations...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = lr.db
+        lr.log.Debug("Transaction is nil, using lr.db", "db", transaction)
+    }
+    var codes []string
+    query := transaction.WithContext(ctx).
+        Model(&types.WarehouseLocation{}).
+        Where("warehouse_id = ?", warehouseID)
+    if parentID == nil {
+        query = query.Where("parent_id IS NULL")
+    } else {
+        query = query.Where("parent_id = ?", *parentID)
+    }
+    if err := query.Pluck("code", &codes).Error; err != nil {
+        lr.log.Error("Failed to fetch sibling codes", "error", err)
+        return nil, err
+    }
+    lr.log.Debug("GetSiblingCodes completed", "warehouseID", warehouseID, "parentID", parentID, "count", len(codes))
+    return codes, nil
+}
+
+func (lr *warehouseLocationRepo) GetExistingFullCodes(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, fullCodes []string) ([]string, error) {
+    lr.log.Info("Checking which location full codes already exist...")
+    var existing []string
+    if warehouseID == uuid.Nil || len(fullCodes) == 0 {
+        lr.log.Debug("Nothing to check, returning empty slice")
+        return existing, nil
+    }
+    transaction := tx
+    if transaction == nil {
+        transaction = lr.db
+        lr.log.Debug("Using lr.db because transaction is nil")
+    }
+    if err := transaction.WithContext(ctx).
+        Model(&types.WarehouseLocation{}).
+        Where("warehouse_id = ? AND full_code IN ?", warehouseID, fullCodes).
+        Pluck("full_code", &existing).Error; err != nil {
+        lr.log.Error("Failed to check existing full codes", "error", err)
+        return nil, err
+    }
+    lr.log.Debug("GetExistingFullCodes completed", "checked", len(fullCodes), "existing", len(existing))
+    return existing, nil
+}
+
+func (lr *warehouseLocationRepo) GetMaxPickSequence(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) (int, error) {
+    lr.log.Info("Fetching max pick sequence for warehouse...")
+    transaction := tx
+    if transaction == nil {
+        transaction = lr.db
+        lr.log.Debug("Using lr.db because transaction is nil")
+    }
+    var max int
+    if err := transaction.WithContext(ctx).
+        Model(&types.WarehouseLocation{}).
+        Where("warehouse_id = ?", warehouseID).
+        Select("COALESCE(MAX(pick_sequence), 0)").
+        Scan(&max).Error; err != nil {
+        lr.log.Error("Failed to fetch max pick sequence", "error", err)
+        return 0, err
+    }
+    lr.log.Debug("GetMaxPickSequence completed", "warehouseID", warehouseID, "max", max)
+    return max, nil
+}
+
+func (lr *warehouseLocationRepo) Update(ctx context.Context, tx *gorm.DB, locations []*types.WarehouseLocation) ([]*types.WarehouseLocation, error) {
+    lr.log.Info("Starting Update WarehouseLocations now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = lr.db
+        lr.log.Debug("Transaction is nil, using lr.db", "db", transaction)
+    } else {
+        lr.log.Debug("Transaction is not nil", "transaction", transaction)
+    }
+    if len(locations) == 0 {
+        lr.log.Debug("No locations provided, returning empty slice")
+        return locations, nil
+    }
+    lr.log.Debug("Updating locations", "count", len(locations))
+    lr.log.Info("Saving locations now...")
+    for i := range locations {
+        if err := transaction.WithContext(ctx).Save(&locations[i]).Error; err != nil {
+            lr.log.Error("Failed to update location", "error", err, "location", locations[i])
+            return nil, err
+        }
+    }
+    lr.log.Info("Successfully updated locations", "count", len(locations))
+    return locations, nil
+}
+
+// ReplaceFullCodePrefix rewrites the full code of every descendant of a
+// renamed location, e.g. "A-03-..." to "A-04-...". Codes only hold letters,
+// digits and the separator, so the LIKE pattern needs no escaping.
+func (lr *warehouseLocationRepo) ReplaceFullCodePrefix(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, oldPrefix string, newPrefix string) (int64, error) {
+    lr.log.Info("Starting ReplaceFullCodePrefix for locations...")
+    transaction := tx
+    if transaction == nil {
+        transaction = lr.db
+        lr.log.Debug("Using lr.db because transaction is nil")
+    }
+    if oldPrefix == "" || oldPrefix == newPrefix {
+        lr.log.Debug("Nothing to rewrite", "oldPrefix", oldPrefix, "newPrefix", newPrefix)
+        return 0, nil
+    }
+    result := transaction.WithContext(ctx).
+        Model(&types.WarehouseLocation{}).
+        Where("warehouse_id = ? AND full_code LIKE ?", warehouseID, oldPrefix+"%").
+        Update("full_code", gorm.Expr("? || SUBSTRING(full_code FROM ?)", newPrefix, len(oldPrefix)+1))
+    if result.Error != nil {
+        lr.log.Error("Failed to rewrite location full codes", "error", result.Error)
+        return 0, result.Error
+    }
+    lr.log.Info("Successfully rewrote location full codes", "count", result.RowsAffected)
+    return result.RowsAffected, nil
+}
+
+func (lr *warehouseLocationRepo) FullDeleteByIDs(ctx context.Context, tx *gorm.DB, locationIDs []uuid.UUID) error {
+    lr.log.Info("Starting FullDeleteByIDs for locations now...")
+    transaction := tx
+    if transaction == nil {
+        transaction = lr.db
+    }
+    if len(locationIDs) == 0 {
+        lr.log.Debug("No locationIDs provided, skipping full delete")
+        return nil
+    }
+    lr.log.Debug("Full deleting locations by IDs", "count", len(locationIDs), "locationIDs", locationIDs)
+    lr.log.Info("Performing FULL (hard) delete by locationIDs now...")
+    if err := transaction.WithContext(ctx).
+        Unscoped().
+        Where("id IN (?)", locationIDs).
+        Delete(&types.WarehouseLocation{}).Error; err != nil {
+        lr.log.Error("Failed to FULL delete locations by IDs", "error", err)
+        return err
+    }
+    lr.log.Info("Successfully FULL deleted locations by IDs", "count", len(locationIDs))
+    return nil
+}
@@ -0,0 +1,68 @@
+package repos
+
+import (
+    "context"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "github.com/slotter-org/slotter-backend/internal/logger"
+    "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type WarehouseNavigationRepo interface {
+    GetByWarehouseID(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) (*types.WarehouseNavigation, error)
+    Save(ctx context.Context, tx *gorm.DB, navigation *types.WarehouseNavigation) (*types.WarehouseNavigation, error)
+}
+
+type warehouseNavigationRepo struct {
+    db          *gorm.DB
+    log         *logger.Logger
+}
+
+func NewWarehouseNavigationRepo(db *gorm.DB, baseLog *logger.Logger) WarehouseNavigationRepo {
+    repoLog := baseLog.With("repo", "WarehouseNavigationRepo")
+    return &warehouseNavigationRepo{db: db, log: repoLog}
+}
+
+// GetByWarehouseID returns nil without an error when the warehouse has no
+// navigation saved yet.
+func (nr *warehouseNavigationRepo) GetByWarehouseID(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) (*types.WarehouseNavigation, error) {
+    nr.log.Info("Starting GetByWarehouseID now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = nr.db
+        nr.log.Debug("Transaction is nil, using nr.db", "db", transaction)
+    }
+    var results []*types.WarehouseNavigation
+    if err := transaction.WithContext(ctx).
+        Where("warehouse_id = ?", warehouseID).
+        Limit(1).
+        Find(&results).Error; err != nil {
+        nr.log.Error("Failed to fetch warehouse navigation by warehouseID", "error", err)
+        return nil, err
+    }
+    if len(results) == 0 {
+        return nil, nil
+    }
+    return results[0], nil
+}
+
+// Save creates the navigation if it has no ID yet and updates it otherwise.
+func (nr *warehouseNavigationRepo) Save(ctx context.Context, tx *gorm.DB, navigation *types.WarehouseNavigation) (*types.WarehouseNavigation, error) {
+    nr.log.Info("Starting Save now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = nr.db
+        nr.log.Debug("Transaction is nil, using nr.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).Omit(clause.Associations).Save(navigation).Error; err != nil {
+        nr.log.Error("Failed to save warehouse navigation", "error", err, "warehouseID", navigation.WarehouseID)
+        return nil, err
+    }
+    nr.log.Info("Successfully saved warehouse navigation", "navigationID", navigation.ID)
+    return navigation, nil
+}
@@ -0,0 +1,124 @@
+package repos
+
+import (
+    "context"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+
+    "github.com/slotter-org/slotter-backend/internal/logger"
+    "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type WebhookEndpointRepo interface {
+    Create(ctx context.Context, tx *gorm.DB, endpoint *types.WebhookEndpoint) (*types.WebhookEndpoint, error)
+    GetByID(ctx context.Context, tx *gorm.DB, endpointID uuid.UUID) (*types.WebhookEndpoint, error)
+    GetByTenant(ctx context.Context, tx *gorm.DB, wmsID, companyID *uuid.UUID) ([]*types.WebhookEndpoint, error)
+    GetActiveByTenant(ctx context.Context, tx *gorm.DB, wmsID, companyID *uuid.UUID) ([]*types.WebhookEndpoint, error)
+    SoftDeleteByID(ctx context.Context, tx *gorm.DB, endpointID uuid.UUID) error
+}
+
+type webhookEndpointRepo struct {
+    db          *gorm.DB
+    log         *logger.Logger
+}
+
+func NewWebhookEndpointRepo(db *gorm.DB, baseLog *logger.Logger) WebhookEndpointRepo {
+    repoLog := baseLog.With("repo", "WebhookEndpointRepo")
+    return &webhookEndpointRepo{db: db, log: repoLog}
+}
+
+func (wr *webhookEndpointRepo) Create(ctx context.Context, tx *gorm.DB, endpoint *types.WebhookEndpoint) (*types.WebhookEndpoint, error) {
+    wr.log.Info("Starting Create WebhookEndpoint now...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = wr.db
+        wr.log.Debug("Transaction is nil, using wr.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).Create(endpoint).Error; err != nil {
+        wr.log.Error("Failed to create webhook endpoint", "error", err)
+        return nil, err
+    }
+    wr.log.Info("Successfully created webhook endpoint", "endpointID", endpoint.ID)
+    return endpoint, nil
+}
+
+// GetByID returns nil without an error when the endpoint does not exist.
+func (wr *webhookEndpointRepo) GetByID(ctx context.Context, tx *gorm.DB, endpointID uuid.UUID) (*types.WebhookEndpoint, error) {
+    wr.log.Info("Starting GetByID for webhook endpoint...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = wr.db
+        wr.log.Debug("Transaction is nil, using wr.db", "db", transaction)
+    }
+    var results []*types.WebhookEndpoint
+    if err := transaction.WithContext(ctx).
+        Where("id = ?", endpointID).
+        Limit(1).
+        Find(&results).Error; err != nil {
+        wr.log.Error("Failed to fetch webhook endpoint by id", "error", err)
+        return nil, err
+    }
+    if len(results) == 0 {
+        return nil, nil
+    }
+    return results[0], nil
+}
+
+func (wr *webhookEndpointRepo) GetByTenant(ctx context.Context, tx *gorm.DB, wmsID, companyID *uuid.UUID) ([]*types.WebhookEndpoint, error) {
+    return wr.getByTenant(ctx, tx, wmsID, companyID, false)
+}
+
+func (wr *webhookEndpointRepo) GetActiveByTenant(ctx context.Context, tx *gorm.DB, wmsID, companyID *uuid.UUID) ([]*types.WebhookEndpoint, error) {
+    return wr.getByTenant(ctx, tx, wmsID, companyID, true)
+}
+
+func (wr *webhookEndpointRepo) getByTenant(ctx context.Context, tx *gorm.DB, wmsID, companyID *uuid.UUID, activeOnly bool) ([]*types.WebhookEndpoint, error) {
+    wr.log.Info("Starting getByTenant for webhook endpoints...", "activeOnly", activeOnly)
+
+    transaction := tx
+    if transaction == nil {
+        transaction = wr.db
+        wr.log.Debug("Transaction is nil, using wr.db", "db", transaction)
+    }
+    var results []*types.WebhookEndpoint
+    query := transaction.WithContext(ctx).Model(&types.WebhookEndpoint{})
+    switch {
+    case wmsID != nil && *wmsID != uuid.Nil:
+        query = query.Where("wms_id = ?", *wmsID)
+    case companyID != nil && *companyID != uuid.Nil:
+        query = query.Where("company_id = ?", *companyID)
+    default:
+        wr.log.Debug("No tenant provided, returning empty slice")
+        return results, nil
+    }
+    if activeOnly {
+        query = query.Where("active = ?", true)
+    }
+    if err := query.Order("created_at ASC").Find(&results).Error; err != nil {
+        wr.log.Error("Failed to fetch webhook endpoints by tenant", "error", err)
+        return nil, err
+    }
+    wr.log.Info("Successfully fetched webhook endpoints by tenant", "count", len(results))
+    return results, nil
+}
+
+func (wr *webhookEndpointRepo) SoftDeleteByID(ctx context.Context, tx *gorm.DB, endpointID uuid.UUID) error {
+    wr.log.Info("Starting SoftDeleteByID for webhook endpoint...")
+
+    transaction := tx
+    if transaction == nil {
+        transaction = wr.db
+        wr.log.Debug("Transaction is nil, using wr.db", "db", transaction)
+    }
+    if err := transaction.WithContext(ctx).
+        Where("id = ?", endpointID).
+        Delete(&types.WebhookEndpoint{}).Error; err != nil {
+        wr.log.Error("Failed to delete webhook endpoint", "error", err)
+        return err
+    }
+    wr.log.Info("Successfully deleted webhook endpoint", "endpointID", endpointID)
+    return nil
+}
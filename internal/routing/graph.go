@@ -0,0 +1,276 @@
+package routing
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// NodeID is a node of a Graph.
+type NodeID int
+
+type edge struct {
+	to     NodeID
+	length float64
+}
+
+// Graph is the walkable network of a Layout: every aisle split at each cross
+// aisle and stop, and every cross aisle split at each aisle, depot and dock.
+// Cross aisles and spurs are two-way; aisles follow their Direction.
+type Graph struct {
+	points []Point
+	adj    [][]edge
+	depot  NodeID
+	docks  map[string]NodeID
+	stops  []NodeID
+	stopAt []Stop
+	aisles []Aisle
+	length float64
+	dist   map[NodeID][]float64
+}
+
+// Path is a shortest path and the points it walks through.
+type Path struct {
+	Distance float64
+	Points   []Point
+}
+
+type aislePos struct {
+	aisle int
+	y     float64
+}
+
+type crossNode struct {
+	x  float64
+	id NodeID
+}
+
+// Build turns l into a graph with a node for each of stops, in the same
+// order, so routes can be asked for them.
+func Build(l Layout, stops []Stop) (*Graph, error) {
+	if err := l.Validate(stops); err != nil {
+		return nil, err
+	}
+	g := &Graph{
+		docks:  map[string]NodeID{},
+		stopAt: append([]Stop{}, stops...),
+		aisles: l.Aisles,
+		length: l.Length,
+		dist:   map[NodeID][]float64{},
+	}
+	crossYs := l.crossYs()
+
+	onAisle := map[aislePos]NodeID{}
+	stopYs := make([][]float64, len(l.Aisles))
+	for _, s := range stops {
+		stopYs[s.Aisle] = append(stopYs[s.Aisle], s.Y)
+	}
+	for i, a := range l.Aisles {
+		ys := append(append([]float64{}, crossYs...), stopYs[i]...)
+		sort.Float64s(ys)
+		var prev NodeID = -1
+		var prevY float64
+		for _, y := range ys {
+			if _, ok := onAisle[aislePos{i, y}]; ok {
+				continue
+			}
+			id := g.addNode(Point{X: a.X, Y: y})
+			onAisle[aislePos{i, y}] = id
+			if prev >= 0 {
+				if a.Direction != Down {
+					g.addEdge(prev, id, y-prevY)
+				}
+				if a.Direction != Up {
+					g.addEdge(id, prev, y-prevY)
+				}
+			}
+			prev, prevY = id, y
+		}
+	}
+
+	cross := make([][]crossNode, len(crossYs))
+	for c, y := range crossYs {
+		for i, a := range l.Aisles {
+			cross[c] = append(cross[c], crossNode{x: a.X, id: onAisle[aislePos{i, y}]})
+		}
+	}
+	attach := func(p Point) NodeID {
+		c := nearestCross(crossYs, p.Y)
+		var at NodeID = -1
+		for _, n := range cross[c] {
+			if n.x == p.X {
+				at = n.id
+			}
+		}
+		if at < 0 {
+			at = g.addNode(Point{X: p.X, Y: crossYs[c]})
+			cross[c] = append(cross[c], crossNode{x: p.X, id: at})
+		}
+		if p.Y == crossYs[c] {
+			return at
+		}
+		id := g.addNode(p)
+		g.addEdge(id, at, math.Abs(p.Y-crossYs[c]))
+		g.addEdge(at, id, math.Abs(p.Y-crossYs[c]))
+		return id
+	}
+	g.depot = attach(l.Depot)
+	for _, d := range l.Docks {
+		g.docks[d.Name] = attach(d.At)
+	}
+	for c := range cross {
+		nodes := cross[c]
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].x < nodes[j].x })
+		for i := 1; i < len(nodes); i++ {
+			g.addEdge(nodes[i-1].id, nodes[i].id, nodes[i].x-nodes[i-1].x)
+			g.addEdge(nodes[i].id, nodes[i-1].id, nodes[i].x-nodes[i-1].x)
+		}
+	}
+
+	g.stops = make([]NodeID, len(stops))
+	for i, s := range stops {
+		g.stops[i] = onAisle[aislePos{s.Aisle, s.Y}]
+	}
+	return g, nil
+}
+
+// Depot is the node of the layout's depot.
+func (g *Graph) Depot() NodeID {
+	return g.depot
+}
+
+// Dock is the node of the named dock.
+func (g *Graph) Dock(name string) (NodeID, bool) {
+	id, ok := g.docks[name]
+	return id, ok
+}
+
+// Stop is the node of the i-th stop passed to Build.
+func (g *Graph) Stop(i int) NodeID {
+	return g.stops[i]
+}
+
+// Point is where a node is.
+func (g *Graph) Point(id NodeID) Point {
+	return g.points[id]
+}
+
+// Distance is the length of the shortest path from one node to another.
+func (g *Graph) Distance(from, to NodeID) (float64, error) {
+	d := g.distancesFrom(from)[to]
+	if math.IsInf(d, 1) {
+		return 0, ErrUnreachable
+	}
+	return d, nil
+}
+
+// ShortestPath walks from one node to another the shortest way.
+func (g *Graph) ShortestPath(from, to NodeID) (Path, error) {
+	dist, prev := g.dijkstra(from)
+	if math.IsInf(dist[to], 1) {
+		return Path{}, ErrUnreachable
+	}
+	var ids []NodeID
+	for at := to; at >= 0; at = prev[at] {
+		ids = append(ids, at)
+	}
+	path := Path{Distance: dist[to], Points: make([]Point, 0, len(ids))}
+	for i := len(ids) - 1; i >= 0; i-- {
+		path.Points = append(path.Points, g.points[ids[i]])
+	}
+	return path, nil
+}
+
+// RoundTrips is, for every stop, the walk from a node to the stop and back,
+// found with one search each way rather than one per stop.
+func (g *Graph) RoundTrips(from NodeID) []float64 {
+	out := g.distancesFrom(from)
+	reverse := &Graph{points: g.points, adj: make([][]edge, len(g.adj))}
+	for a, edges := range g.adj {
+		for _, e := range edges {
+			reverse.adj[e.to] = append(reverse.adj[e.to], edge{to: NodeID(a), length: e.length})
+		}
+	}
+	back, _ := reverse.dijkstra(from)
+	trips := make([]float64, len(g.stops))
+	for i, id := range g.stops {
+		trips[i] = out[id] + back[id]
+	}
+	return trips
+}
+
+func (g *Graph) addNode(p Point) NodeID {
+	g.points = append(g.points, p)
+	g.adj = append(g.adj, nil)
+	return NodeID(len(g.points) - 1)
+}
+
+func (g *Graph) addEdge(from, to NodeID, length float64) {
+	g.adj[from] = append(g.adj[from], edge{to: to, length: length})
+}
+
+// distancesFrom caches the shortest distances from a node to every other.
+func (g *Graph) distancesFrom(from NodeID) []float64 {
+	if d, ok := g.dist[from]; ok {
+		return d
+	}
+	d, _ := g.dijkstra(from)
+	g.dist[from] = d
+	return d
+}
+
+func (g *Graph) dijkstra(from NodeID) ([]float64, []NodeID) {
+	dist := make([]float64, len(g.points))
+	prev := make([]NodeID, len(g.points))
+	for i := range dist {
+		dist[i] = math.Inf(1)
+		prev[i] = -1
+	}
+	dist[from] = 0
+	q := &nodeQueue{{id: from}}
+	for q.Len() > 0 {
+		cur := heap.Pop(q).(queued)
+		if cur.dist > dist[cur.id] {
+			continue
+		}
+		for _, e := range g.adj[cur.id] {
+			if d := cur.dist + e.length; d < dist[e.to] {
+				dist[e.to] = d
+				prev[e.to] = cur.id
+				heap.Push(q, queued{id: e.to, dist: d})
+			}
+		}
+	}
+	return dist, prev
+}
+
+// nearestCross is the index of the cross aisle closest to y, the front one
+// on a tie.
+func nearestCross(crossYs []float64, y float64) int {
+	best := 0
+	for c, cy := range crossYs {
+		if math.Abs(cy-y) < math.Abs(crossYs[best]-y) {
+			best = c
+		}
+	}
+	return best
+}
+
+type queued struct {
+	id   NodeID
+	dist float64
+}
+
+type nodeQueue []queued
+
+func (q nodeQueue) Len() int            { return len(q) }
+func (q nodeQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q nodeQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *nodeQueue) Push(x interface{}) { *q = append(*q, x.(queued)) }
+func (q *nodeQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	it := old[n-1]
+	*q = old[:n-1]
+	return it
+}
@@ -0,0 +1,214 @@
+package routing
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// threeAisles is three 20 m aisles, 5 m apart, with the depot 2 m in front
+// of the leftmost one.
+func threeAisles(dirs ...Direction) Layout {
+	l := Layout{Length: 20, Depot: Point{X: 0, Y: -2}}
+	for i := 0; i < 3; i++ {
+		a := Aisle{X: float64(5 * i)}
+		if i < len(dirs) {
+			a.Direction = dirs[i]
+		}
+		l.Aisles = append(l.Aisles, a)
+	}
+	return l
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  Layout
+		stops   []Stop
+		from    func(g *Graph) NodeID
+		to      func(g *Graph) NodeID
+		want    float64
+		wantErr error
+	}{
+		{
+			name:   "two-way aisle from the front",
+			layout: threeAisles(),
+			stops:  []Stop{{Aisle: 1, Y: 8}},
+			from:   (*Graph).Depot,
+			to:     stopNode(0),
+			want:   2 + 5 + 8,
+		},
+		{
+			name:   "up aisle entered from the front",
+			layout: threeAisles(TwoWay, Up),
+			stops:  []Stop{{Aisle: 1, Y: 8}},
+			from:   (*Graph).Depot,
+			to:     stopNode(0),
+			want:   2 + 5 + 8,
+		},
+		{
+			name:   "up aisle left by the back",
+			layout: threeAisles(TwoWay, Up),
+			stops:  []Stop{{Aisle: 1, Y: 8}},
+			from:   stopNode(0),
+			to:     (*Graph).Depot,
+			want:   12 + 5 + 20 + 2,
+		},
+		{
+			name:   "down aisle entered from the back",
+			layout: threeAisles(TwoWay, Down),
+			stops:  []Stop{{Aisle: 1, Y: 8}},
+			from:   (*Graph).Depot,
+			to:     stopNode(0),
+			want:   2 + 20 + 5 + 12,
+		},
+		{
+			name:   "around the back without a cross aisle",
+			layout: threeAisles(),
+			stops:  []Stop{{Aisle: 0, Y: 12}, {Aisle: 1, Y: 12}},
+			from:   stopNode(0),
+			to:     stopNode(1),
+			want:   8 + 5 + 8,
+		},
+		{
+			name:   "through a cross aisle",
+			layout: func() Layout { l := threeAisles(); l.CrossAisles = []float64{10}; return l }(),
+			stops:  []Stop{{Aisle: 0, Y: 12}, {Aisle: 1, Y: 12}},
+			from:   stopNode(0),
+			to:     stopNode(1),
+			want:   2 + 5 + 2,
+		},
+		{
+			name:   "dock joined to the back cross aisle",
+			layout: func() Layout { l := threeAisles(); l.Docks = []Dock{{Name: "out", At: Point{X: 10, Y: 23}}}; return l }(),
+			from:   (*Graph).Depot,
+			to:     dockNode("out"),
+			want:   2 + 20 + 10 + 3,
+		},
+		{
+			name:   "dock between aisles",
+			layout: func() Layout { l := threeAisles(); l.Docks = []Dock{{Name: "in", At: Point{X: 7, Y: -4}}}; return l }(),
+			from:   dockNode("in"),
+			to:     (*Graph).Depot,
+			want:   4 + 7 + 2,
+		},
+		{
+			name:    "one-way aisle with no way back",
+			layout:  Layout{Aisles: []Aisle{{X: 0, Direction: Up}}, Length: 20, Depot: Point{X: 0, Y: -2}},
+			stops:   []Stop{{Aisle: 0, Y: 10}},
+			from:    stopNode(0),
+			to:      (*Graph).Depot,
+			wantErr: ErrUnreachable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := Build(tt.layout, tt.stops)
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			from, to := tt.from(g), tt.to(g)
+			got, err := g.Distance(from, to)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Distance() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Distance() = %g, want %g", got, tt.want)
+			}
+			path, err := g.ShortestPath(from, to)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ShortestPath() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (path.Distance != tt.want || walked(path.Points) != tt.want) {
+				t.Errorf("ShortestPath() = %g walking %g over %v, want %g", path.Distance, walked(path.Points), path.Points, tt.want)
+			}
+		})
+	}
+}
+
+func TestShortestPathPoints(t *testing.T) {
+	g, err := Build(threeAisles(TwoWay, Up), []Stop{{Aisle: 1, Y: 8}})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	path, err := g.ShortestPath(g.Stop(0), g.Depot())
+	if err != nil {
+		t.Fatalf("ShortestPath() error = %v", err)
+	}
+	want := []Point{{5, 8}, {5, 20}, {0, 20}, {0, 0}, {0, -2}}
+	if len(path.Points) != len(want) {
+		t.Fatalf("points = %v, want %v", path.Points, want)
+	}
+	for i := range want {
+		if path.Points[i] != want[i] {
+			t.Fatalf("points = %v, want %v", path.Points, want)
+		}
+	}
+}
+
+func TestRoundTrips(t *testing.T) {
+	stops := []Stop{{Aisle: 0, Y: 3}, {Aisle: 1, Y: 8}, {Aisle: 2, Y: 15}}
+	g, err := Build(threeAisles(Down, Up, TwoWay), stops)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	trips := g.RoundTrips(g.Depot())
+	for i := range stops {
+		out, err := g.Distance(g.Depot(), g.Stop(i))
+		if err != nil {
+			t.Fatalf("Distance() error = %v", err)
+		}
+		back, err := g.Distance(g.Stop(i), g.Depot())
+		if err != nil {
+			t.Fatalf("Distance() error = %v", err)
+		}
+		if trips[i] != out+back {
+			t.Errorf("round trip to stop %d = %g, want %g", i, trips[i], out+back)
+		}
+	}
+}
+
+func TestBuildRejectsInvalidLayouts(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout Layout
+		stops  []Stop
+	}{
+		{name: "no aisles", layout: Layout{Length: 20}},
+		{name: "no length", layout: Layout{Aisles: []Aisle{{X: 0}}}},
+		{name: "shared position", layout: Layout{Aisles: []Aisle{{X: 0}, {X: 0}}, Length: 20}},
+		{name: "bad direction", layout: Layout{Aisles: []Aisle{{X: 0, Direction: "sideways"}}, Length: 20}},
+		{name: "cross aisle outside", layout: Layout{Aisles: []Aisle{{X: 0}}, Length: 20, CrossAisles: []float64{20}}},
+		{name: "unnamed dock", layout: Layout{Aisles: []Aisle{{X: 0}}, Length: 20, Docks: []Dock{{}}}},
+		{name: "depot not finite", layout: Layout{Aisles: []Aisle{{X: 0}}, Length: 20, Depot: Point{X: math.NaN()}}},
+		{name: "stop in unknown aisle", layout: threeAisles(), stops: []Stop{{Aisle: 3}}},
+		{name: "stop past the back", layout: threeAisles(), stops: []Stop{{Aisle: 0, Y: 21}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Build(tt.layout, tt.stops); err == nil {
+				t.Error("Build() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func stopNode(i int) func(g *Graph) NodeID {
+	return func(g *Graph) NodeID { return g.Stop(i) }
+}
+
+func dockNode(name string) func(g *Graph) NodeID {
+	return func(g *Graph) NodeID {
+		id, _ := g.Dock(name)
+		return id
+	}
+}
+
+// walked is the length of a path that only moves along X or Y.
+func walked(points []Point) float64 {
+	total := 0.0
+	for i := 1; i < len(points); i++ {
+		total += math.Abs(points[i].X-points[i-1].X) + math.Abs(points[i].Y-points[i-1].Y)
+	}
+	return total
+}
@@ -0,0 +1,143 @@
+// Package routing estimates walking distances in a warehouse. A Layout of
+// parallel aisles joined by cross aisles is turned into a Graph, which
+// answers shortest paths and estimates pick routes over a batch of stops.
+//
+// Coordinates are in metres. X runs across the aisles and Y along them,
+// from the front cross aisle (Y = 0) to the back one (Y = Layout.Length).
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Direction restricts travel along an aisle. Up is towards the back (growing
+// Y), Down towards the front.
+type Direction string
+
+const (
+	TwoWay Direction = ""
+	Up     Direction = "up"
+	Down   Direction = "down"
+)
+
+// Point is a position on the floor.
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Aisle is a pick aisle running the full length of the layout at X.
+type Aisle struct {
+	Name      string
+	X         float64
+	Direction Direction
+}
+
+// Dock is a named door routes can start or end at.
+type Dock struct {
+	Name string
+	At   Point
+}
+
+// Layout is a block of parallel aisles. Cross aisles always run along the
+// front and the back; CrossAisles adds the ones in between, by their Y.
+// The depot and docks are joined to the nearest cross aisle by a straight
+// walk, so they can sit anywhere on the floor.
+type Layout struct {
+	Aisles      []Aisle
+	Length      float64
+	CrossAisles []float64
+	Depot       Point
+	Docks       []Dock
+}
+
+// Stop is a position in an aisle: the index of the aisle in Layout.Aisles
+// and how far along it.
+type Stop struct {
+	Aisle int
+	Y     float64
+}
+
+// ErrUnreachable is returned when one-way aisles leave no way between two
+// points.
+var ErrUnreachable = errors.New("no route between the points")
+
+// Validate checks l and the stops to be placed on it.
+func (l Layout) Validate(stops []Stop) error {
+	if len(l.Aisles) == 0 {
+		return fmt.Errorf("layout has no aisles")
+	}
+	if !(l.Length > 0) || math.IsInf(l.Length, 0) {
+		return fmt.Errorf("aisle length must be positive")
+	}
+	xs := make([]float64, 0, len(l.Aisles))
+	for i, a := range l.Aisles {
+		if math.IsNaN(a.X) || math.IsInf(a.X, 0) {
+			return fmt.Errorf("aisle %d has an invalid position", i+1)
+		}
+		if a.Direction != TwoWay && a.Direction != Up && a.Direction != Down {
+			return fmt.Errorf("aisle %s has invalid direction %q", aisleLabel(a, i), a.Direction)
+		}
+		xs = append(xs, a.X)
+	}
+	sort.Float64s(xs)
+	for i := 1; i < len(xs); i++ {
+		if xs[i] == xs[i-1] {
+			return fmt.Errorf("two aisles share position %g", xs[i])
+		}
+	}
+	for _, y := range l.CrossAisles {
+		if !(y > 0 && y < l.Length) {
+			return fmt.Errorf("cross aisle at %g is outside the aisles (0 to %g)", y, l.Length)
+		}
+	}
+	points := []Point{l.Depot}
+	names := map[string]bool{}
+	for _, d := range l.Docks {
+		if d.Name == "" {
+			return fmt.Errorf("every dock needs a name")
+		}
+		if names[d.Name] {
+			return fmt.Errorf("dock %s is listed twice", d.Name)
+		}
+		names[d.Name] = true
+		points = append(points, d.At)
+	}
+	for _, p := range points {
+		if math.IsNaN(p.X) || math.IsNaN(p.Y) || math.IsInf(p.X, 0) || math.IsInf(p.Y, 0) {
+			return fmt.Errorf("depot and docks need finite positions")
+		}
+	}
+	for i, s := range stops {
+		if s.Aisle < 0 || s.Aisle >= len(l.Aisles) {
+			return fmt.Errorf("stop %d is in an unknown aisle", i+1)
+		}
+		if !(s.Y >= 0 && s.Y <= l.Length) {
+			return fmt.Errorf("stop %d is outside its aisle", i+1)
+		}
+	}
+	return nil
+}
+
+// crossYs is every cross aisle, front to back.
+func (l Layout) crossYs() []float64 {
+	ys := append([]float64{0, l.Length}, l.CrossAisles...)
+	sort.Float64s(ys)
+	out := ys[:1]
+	for _, y := range ys[1:] {
+		if y != out[len(out)-1] {
+			out = append(out, y)
+		}
+	}
+	return out
+}
+
+func aisleLabel(a Aisle, i int) string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return fmt.Sprintf("%d", i+1)
+}
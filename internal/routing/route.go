@@ -0,0 +1,355 @@
+package routing
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Heuristic is a way of ordering the stops of a pick route.
+type Heuristic string
+
+const (
+	// SShape walks every aisle with a stop end to end, alternating
+	// direction except where an aisle is one-way.
+	SShape Heuristic = "s_shape"
+	// LargestGap walks the first and last aisles end to end and dips into
+	// the others from the front and from the back, leaving out each aisle's
+	// largest gap between stops.
+	LargestGap Heuristic = "largest_gap"
+	// Optimal finds the shortest order: exactly up to MaxExactStops distinct
+	// stops, by local search from the better heuristic above that.
+	Optimal Heuristic = "optimal"
+)
+
+// Heuristics lists every heuristic.
+var Heuristics = []Heuristic{SShape, LargestGap, Optimal}
+
+// MaxExactStops is the most distinct stops Optimal solves exactly.
+const MaxExactStops = 15
+
+// maxImprovePasses bounds Optimal's local search above MaxExactStops.
+const maxImprovePasses = 50
+
+// Route is an estimated pick route. Order lists the stops, as indices into
+// the stops passed to Build, in the order they are visited. Distance is
+// walked on the graph from the start through every stop to the end, so the
+// heuristics only choose the order and one-way aisles always hold. Exact is
+// set when the order is known to be the shortest.
+type Route struct {
+	Heuristic Heuristic
+	Order     []int
+	Distance  float64
+	Exact     bool
+}
+
+// visit is a distinct node among the stops.
+type visit struct {
+	node  NodeID
+	aisle int
+	y     float64
+	stops []int
+}
+
+// EstimateRoute orders the stops g was built with by h, for a route from
+// start to end. A Graph caches distances and is not safe for concurrent use.
+func (g *Graph) EstimateRoute(h Heuristic, start, end NodeID) (*Route, error) {
+	visits := g.visits()
+	var order []int
+	exact := false
+	switch h {
+	case SShape:
+		order = g.sShape(visits, start)
+	case LargestGap:
+		order = g.largestGap(visits, start)
+	case Optimal:
+		if len(visits) <= MaxExactStops {
+			var err error
+			if order, err = g.heldKarp(visits, start, end); err != nil {
+				return nil, err
+			}
+			exact = true
+		} else {
+			order = g.improve(visits, start, end, g.bestOf(visits, start, end, g.sShape(visits, start), g.largestGap(visits, start)))
+		}
+	default:
+		return nil, fmt.Errorf("unknown heuristic %q", h)
+	}
+	dist := g.orderLength(visits, start, end, order)
+	if math.IsInf(dist, 1) {
+		return nil, ErrUnreachable
+	}
+	r := &Route{Heuristic: h, Distance: dist, Exact: exact || len(visits) <= 1}
+	for _, v := range order {
+		r.Order = append(r.Order, visits[v].stops...)
+	}
+	return r, nil
+}
+
+// visits groups stops that share a node, in order of first appearance.
+func (g *Graph) visits() []visit {
+	var out []visit
+	at := map[NodeID]int{}
+	for i, s := range g.stopAt {
+		node := g.stops[i]
+		if v, ok := at[node]; ok {
+			out[v].stops = append(out[v].stops, i)
+			continue
+		}
+		at[node] = len(out)
+		out = append(out, visit{node: node, aisle: s.Aisle, y: s.Y, stops: []int{i}})
+	}
+	return out
+}
+
+// aisleOrder groups visits by aisle, with the aisles in walking order: left
+// to right, or right to left when start is nearer the rightmost one. Each
+// aisle's visits are front to back.
+func (g *Graph) aisleOrder(visits []visit, start NodeID) [][]int {
+	byAisle := map[int][]int{}
+	var aisles []int
+	for i, v := range visits {
+		if byAisle[v.aisle] == nil {
+			aisles = append(aisles, v.aisle)
+		}
+		byAisle[v.aisle] = append(byAisle[v.aisle], i)
+	}
+	sort.Slice(aisles, func(i, j int) bool { return g.aisles[aisles[i]].X < g.aisles[aisles[j]].X })
+	if len(aisles) > 1 {
+		x := g.points[start].X
+		if math.Abs(g.aisles[aisles[len(aisles)-1]].X-x) < math.Abs(g.aisles[aisles[0]].X-x) {
+			for i, j := 0, len(aisles)-1; i < j; i, j = i+1, j-1 {
+				aisles[i], aisles[j] = aisles[j], aisles[i]
+			}
+		}
+	}
+	out := make([][]int, 0, len(aisles))
+	for _, a := range aisles {
+		vs := byAisle[a]
+		sort.Slice(vs, func(i, j int) bool { return visits[vs[i]].y < visits[vs[j]].y })
+		out = append(out, vs)
+	}
+	return out
+}
+
+// sShape enters each aisle from the end the walker is at, unless the aisle
+// is one-way, and leaves it at the other end.
+func (g *Graph) sShape(visits []visit, start NodeID) []int {
+	var order []int
+	atFront := g.points[start].Y <= g.length/2
+	for _, vs := range g.aisleOrder(visits, start) {
+		switch g.aisles[visits[vs[0]].aisle].Direction {
+		case Up:
+			atFront = true
+		case Down:
+			atFront = false
+		}
+		if atFront {
+			order = append(order, vs...)
+		} else {
+			order = append(order, reversed(vs)...)
+		}
+		atFront = !atFront
+	}
+	return order
+}
+
+func (g *Graph) largestGap(visits []visit, start NodeID) []int {
+	aisles := g.aisleOrder(visits, start)
+	if len(aisles) == 1 {
+		return aisles[0]
+	}
+	fronts := make([][]int, len(aisles))
+	backs := make([][]int, len(aisles))
+	for k := 1; k < len(aisles)-1; k++ {
+		vs := aisles[k]
+		// The gap cut is the largest one between the front, the stops and
+		// the back; stops before it are reached from the front.
+		cut, widest, prev := 0, -1.0, 0.0
+		for i := 0; i <= len(vs); i++ {
+			next := g.length
+			if i < len(vs) {
+				next = visits[vs[i]].y
+			}
+			if next-prev > widest {
+				cut, widest = i, next-prev
+			}
+			prev = next
+		}
+		fronts[k], backs[k] = vs[:cut], vs[cut:]
+	}
+	order := append([]int{}, aisles[0]...)
+	for k := 1; k < len(aisles)-1; k++ {
+		order = append(order, reversed(backs[k])...)
+	}
+	order = append(order, reversed(aisles[len(aisles)-1])...)
+	for k := len(aisles) - 2; k >= 1; k-- {
+		order = append(order, fronts[k]...)
+	}
+	return order
+}
+
+// heldKarp is the exact shortest order, by dynamic programming over subsets.
+func (g *Graph) heldKarp(visits []visit, start, end NodeID) ([]int, error) {
+	n := len(visits)
+	if n == 0 {
+		return nil, nil
+	}
+	d := g.matrix(visits)
+	fromStart := g.distancesFrom(start)
+	full := 1<<n - 1
+	cost := make([]float64, (full+1)*n)
+	parent := make([]int8, (full+1)*n)
+	for i := range cost {
+		cost[i] = math.Inf(1)
+		parent[i] = -1
+	}
+	for j := 0; j < n; j++ {
+		cost[(1<<j)*n+j] = fromStart[visits[j].node]
+	}
+	for mask := 1; mask <= full; mask++ {
+		for j := 0; j < n; j++ {
+			c := cost[mask*n+j]
+			if mask&(1<<j) == 0 || math.IsInf(c, 1) {
+				continue
+			}
+			for k := 0; k < n; k++ {
+				if mask&(1<<k) != 0 {
+					continue
+				}
+				next := mask | 1<<k
+				if nc := c + d[j][k]; nc < cost[next*n+k] {
+					cost[next*n+k] = nc
+					parent[next*n+k] = int8(j)
+				}
+			}
+		}
+	}
+	last, best := -1, math.Inf(1)
+	for j := 0; j < n; j++ {
+		if c := cost[full*n+j] + g.distancesFrom(visits[j].node)[end]; c < best {
+			last, best = j, c
+		}
+	}
+	if last < 0 {
+		return nil, ErrUnreachable
+	}
+	order := make([]int, n)
+	for mask, j, i := full, last, n-1; i >= 0; i-- {
+		order[i] = j
+		prev := int(parent[mask*n+j])
+		mask &^= 1 << j
+		j = prev
+	}
+	return order, nil
+}
+
+// improve shortens order by reversing segments and relocating single
+// visits until neither helps. Distances may be asymmetric, so a reversed
+// segment is priced walking it backwards.
+func (g *Graph) improve(visits []visit, start, end NodeID, order []int) []int {
+	const eps = 1e-9
+	n := len(order)
+	seq := make([]NodeID, n+2)
+	seq[0], seq[n+1] = start, end
+	pos := append([]int{}, order...)
+	d := func(a, b NodeID) float64 { return g.distancesFrom(a)[b] }
+	load := func() {
+		for i, v := range pos {
+			seq[i+1] = visits[v].node
+		}
+	}
+	load()
+	fwd := make([]float64, n+2)
+	rev := make([]float64, n+2)
+	prefix := func() {
+		for k := 1; k < n+2; k++ {
+			fwd[k] = fwd[k-1] + d(seq[k-1], seq[k])
+			rev[k] = rev[k-1] + d(seq[k], seq[k-1])
+		}
+	}
+	prefix()
+	for pass := 0; pass < maxImprovePasses; pass++ {
+		improved := false
+		for i := 1; i <= n; i++ {
+			for j := i + 1; j <= n; j++ {
+				before := d(seq[i-1], seq[i]) + (fwd[j] - fwd[i]) + d(seq[j], seq[j+1])
+				after := d(seq[i-1], seq[j]) + (rev[j] - rev[i]) + d(seq[i], seq[j+1])
+				if after < before-eps {
+					for a, b := i-1, j-1; a < b; a, b = a+1, b-1 {
+						pos[a], pos[b] = pos[b], pos[a]
+					}
+					load()
+					prefix()
+					improved = true
+				}
+			}
+		}
+		for i := 1; i <= n; i++ {
+			removed := d(seq[i-1], seq[i+1]) - d(seq[i-1], seq[i]) - d(seq[i], seq[i+1])
+			for k := 0; k <= n; k++ {
+				if k == i-1 || k == i {
+					continue
+				}
+				inserted := d(seq[k], seq[i]) + d(seq[i], seq[k+1]) - d(seq[k], seq[k+1])
+				if removed+inserted < -eps {
+					v := pos[i-1]
+					pos = append(pos[:i-1], pos[i:]...)
+					at := k
+					if k > i {
+						at = k - 1
+					}
+					pos = append(pos[:at], append([]int{v}, pos[at:]...)...)
+					load()
+					prefix()
+					improved = true
+					break
+				}
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+	return pos
+}
+
+func (g *Graph) bestOf(visits []visit, start, end NodeID, orders ...[]int) []int {
+	best, bestLen := orders[0], math.Inf(1)
+	for _, o := range orders {
+		if l := g.orderLength(visits, start, end, o); l < bestLen {
+			best, bestLen = o, l
+		}
+	}
+	return best
+}
+
+func (g *Graph) orderLength(visits []visit, start, end NodeID, order []int) float64 {
+	total := 0.0
+	at := start
+	for _, v := range order {
+		total += g.distancesFrom(at)[visits[v].node]
+		at = visits[v].node
+	}
+	return total + g.distancesFrom(at)[end]
+}
+
+func (g *Graph) matrix(visits []visit) [][]float64 {
+	d := make([][]float64, len(visits))
+	for i, a := range visits {
+		from := g.distancesFrom(a.node)
+		d[i] = make([]float64, len(visits))
+		for j, b := range visits {
+			d[i][j] = from[b.node]
+		}
+	}
+	return d
+}
+
+func reversed(vs []int) []int {
+	out := make([]int, len(vs))
+	for i, v := range vs {
+		out[len(vs)-1-i] = v
+	}
+	return out
+}
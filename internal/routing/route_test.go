@@ -0,0 +1,294 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// sixStops has two stops in each of threeAisles: the middle aisle's widest
+// gap is between its stops.
+var sixStops = []Stop{{Aisle: 0, Y: 15}, {Aisle: 0, Y: 5}, {Aisle: 1, Y: 4}, {Aisle: 1, Y: 14}, {Aisle: 2, Y: 17}, {Aisle: 2, Y: 3}}
+
+func TestHeuristicOrders(t *testing.T) {
+	rightDepot := threeAisles()
+	rightDepot.Depot = Point{X: 10, Y: -2}
+	tests := []struct {
+		name      string
+		layout    Layout
+		heuristic Heuristic
+		want      []int
+	}{
+		{name: "s-shape alternates", layout: threeAisles(), heuristic: SShape, want: []int{1, 0, 3, 2, 5, 4}},
+		{name: "s-shape from the right", layout: rightDepot, heuristic: SShape, want: []int{5, 4, 3, 2, 1, 0}},
+		{name: "s-shape follows an up aisle", layout: threeAisles(TwoWay, Up), heuristic: SShape, want: []int{1, 0, 2, 3, 4, 5}},
+		{name: "s-shape follows a down aisle", layout: threeAisles(Down), heuristic: SShape, want: []int{0, 1, 2, 3, 4, 5}},
+		{name: "largest gap", layout: threeAisles(), heuristic: LargestGap, want: []int{1, 0, 3, 4, 5, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := Build(tt.layout, sixStops)
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			r, err := g.EstimateRoute(tt.heuristic, g.Depot(), g.Depot())
+			if err != nil {
+				t.Fatalf("EstimateRoute() error = %v", err)
+			}
+			if fmt.Sprint(r.Order) != fmt.Sprint(tt.want) {
+				t.Errorf("order = %v, want %v", r.Order, tt.want)
+			}
+			if want := orderDistance(t, g, g.Depot(), g.Depot(), r.Order); math.Abs(r.Distance-want) > 1e-9 {
+				t.Errorf("distance = %g, want %g walked on the graph", r.Distance, want)
+			}
+		})
+	}
+}
+
+func TestLargestGapSkipsEachAislesWidestGap(t *testing.T) {
+	// Four aisles, so the two middle ones are dipped into: aisle 1 from
+	// both ends and aisle 2 only from the back.
+	l := Layout{Aisles: []Aisle{{X: 0}, {X: 5}, {X: 10}, {X: 15}}, Length: 20, Depot: Point{X: 0, Y: -2}}
+	stops := []Stop{{Aisle: 0, Y: 10}, {Aisle: 1, Y: 2}, {Aisle: 1, Y: 18}, {Aisle: 2, Y: 16}, {Aisle: 3, Y: 10}}
+	g, err := Build(l, stops)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	r, err := g.EstimateRoute(LargestGap, g.Depot(), g.Depot())
+	if err != nil {
+		t.Fatalf("EstimateRoute() error = %v", err)
+	}
+	if want := []int{0, 2, 3, 4, 1}; fmt.Sprint(r.Order) != fmt.Sprint(want) {
+		t.Errorf("order = %v, want %v", r.Order, want)
+	}
+}
+
+func TestEstimateRouteStartAndEnd(t *testing.T) {
+	l := threeAisles()
+	l.Docks = []Dock{{Name: "out", At: Point{X: 10, Y: 22}}}
+	g, err := Build(l, []Stop{{Aisle: 1, Y: 10}, {Aisle: 1, Y: 10}})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	dock, ok := g.Dock("out")
+	if !ok {
+		t.Fatal("Dock(out) not found")
+	}
+	for _, h := range Heuristics {
+		t.Run(string(h), func(t *testing.T) {
+			r, err := g.EstimateRoute(h, g.Depot(), dock)
+			if err != nil {
+				t.Fatalf("EstimateRoute() error = %v", err)
+			}
+			// Depot to the stop by the front, then on to the dock by the back.
+			if want := (2 + 5 + 10) + (10 + 5 + 2.0); r.Distance != want {
+				t.Errorf("distance = %g, want %g", r.Distance, want)
+			}
+			if fmt.Sprint(r.Order) != "[0 1]" || !r.Exact {
+				t.Errorf("order = %v exact %v, want both stops of the one visit, exact", r.Order, r.Exact)
+			}
+		})
+	}
+}
+
+func TestEstimateRouteUnreachable(t *testing.T) {
+	l := Layout{Aisles: []Aisle{{X: 0, Direction: Up}, {X: 5, Direction: Up}}, Length: 20, Depot: Point{X: 0, Y: -2}}
+	g, err := Build(l, []Stop{{Aisle: 0, Y: 5}, {Aisle: 1, Y: 15}})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	for _, h := range Heuristics {
+		if _, err := g.EstimateRoute(h, g.Depot(), g.Depot()); !errors.Is(err, ErrUnreachable) {
+			t.Errorf("EstimateRoute(%s) error = %v, want ErrUnreachable", h, err)
+		}
+	}
+	if _, err := g.EstimateRoute("nearest", g.Depot(), g.Depot()); err == nil {
+		t.Error("EstimateRoute(nearest) error = nil, want an error")
+	}
+}
+
+// TestOptimalMatchesBruteForce checks, on random small layouts with one-way
+// aisles, cross aisles and docks, that Optimal is the shortest order found
+// by trying every one and is never longer than either heuristic.
+func TestOptimalMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		l, stops := randomLayout(rng, 1+rng.Intn(6))
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			g, err := Build(l, stops)
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			start, end := g.Depot(), g.Depot()
+			if len(l.Docks) > 0 {
+				end, _ = g.Dock(l.Docks[0].Name)
+			}
+			want := bruteForce(g, start, end)
+			opt, err := g.EstimateRoute(Optimal, start, end)
+			if math.IsInf(want, 1) {
+				if !errors.Is(err, ErrUnreachable) {
+					t.Fatalf("Optimal error = %v, want ErrUnreachable", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Optimal error = %v", err)
+			}
+			if !opt.Exact || math.Abs(opt.Distance-want) > 1e-9 {
+				t.Fatalf("Optimal = %g exact %v, want %g by brute force", opt.Distance, opt.Exact, want)
+			}
+			checkCovers(t, opt.Order, len(stops))
+			for _, h := range []Heuristic{SShape, LargestGap} {
+				r, err := g.EstimateRoute(h, start, end)
+				if err != nil {
+					t.Fatalf("%s error = %v", h, err)
+				}
+				checkCovers(t, r.Order, len(stops))
+				if opt.Distance > r.Distance+1e-9 {
+					t.Errorf("Optimal %g is longer than %s %g", opt.Distance, h, r.Distance)
+				}
+			}
+		})
+	}
+}
+
+// TestOptimalAboveExactLimit checks the local search keeps every stop and
+// does no worse than the heuristics it starts from.
+func TestOptimalAboveExactLimit(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 20; i++ {
+		l, stops := randomLayout(rng, MaxExactStops+10)
+		for a := range l.Aisles {
+			l.Aisles[a].Direction = TwoWay
+		}
+		g, err := Build(l, stops)
+		if err != nil {
+			t.Fatalf("Build() error = %v", err)
+		}
+		if len(g.visits()) <= MaxExactStops {
+			continue
+		}
+		opt, err := g.EstimateRoute(Optimal, g.Depot(), g.Depot())
+		if err != nil {
+			t.Fatalf("Optimal error = %v", err)
+		}
+		if opt.Exact {
+			t.Error("Optimal above the exact limit reported Exact")
+		}
+		checkCovers(t, opt.Order, len(stops))
+		for _, h := range []Heuristic{SShape, LargestGap} {
+			r, err := g.EstimateRoute(h, g.Depot(), g.Depot())
+			if err != nil {
+				t.Fatalf("%s error = %v", h, err)
+			}
+			if opt.Distance > r.Distance+1e-9 {
+				t.Errorf("layout %d: Optimal %g is longer than %s %g", i, opt.Distance, h, r.Distance)
+			}
+		}
+	}
+}
+
+func TestImprove(t *testing.T) {
+	g, err := Build(threeAisles(TwoWay, Up, Down), sixStops)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	visits := g.visits()
+	start := g.Depot()
+	worst := []int{4, 1, 3, 0, 5, 2}
+	improved := g.improve(visits, start, start, append([]int{}, worst...))
+	checkCovers(t, improved, len(visits))
+	before := g.orderLength(visits, start, start, worst)
+	after := g.orderLength(visits, start, start, improved)
+	if after >= before {
+		t.Errorf("improve() left %v at %g, started at %g", improved, after, before)
+	}
+	if again := g.improve(visits, start, start, append([]int{}, improved...)); g.orderLength(visits, start, start, again) != after {
+		t.Errorf("improve() of its own result changed %g to %g", after, g.orderLength(visits, start, start, again))
+	}
+}
+
+// randomLayout is two to four aisles of random direction, maybe a middle
+// cross aisle and a dock, and n stops on whole metres.
+func randomLayout(rng *rand.Rand, n int) (Layout, []Stop) {
+	dirs := []Direction{TwoWay, TwoWay, Up, Down}
+	l := Layout{Length: 20, Depot: Point{X: float64(rng.Intn(12)), Y: -2}}
+	for a := 0; a < 2+rng.Intn(3); a++ {
+		l.Aisles = append(l.Aisles, Aisle{X: float64(4 * a), Direction: dirs[rng.Intn(len(dirs))]})
+	}
+	if rng.Intn(2) == 0 {
+		l.CrossAisles = []float64{float64(5 + rng.Intn(10))}
+	}
+	if rng.Intn(2) == 0 {
+		l.Docks = []Dock{{Name: "dock", At: Point{X: float64(rng.Intn(12)), Y: 22}}}
+	}
+	stops := make([]Stop, n)
+	for i := range stops {
+		stops[i] = Stop{Aisle: rng.Intn(len(l.Aisles)), Y: float64(rng.Intn(21))}
+	}
+	return l, stops
+}
+
+// bruteForce is the shortest walk over every order of g's visits.
+func bruteForce(g *Graph, start, end NodeID) float64 {
+	visits := g.visits()
+	order := make([]int, len(visits))
+	for i := range order {
+		order[i] = i
+	}
+	best := math.Inf(1)
+	var permute func(k int)
+	permute = func(k int) {
+		if k == len(order) {
+			best = math.Min(best, g.orderLength(visits, start, end, order))
+			return
+		}
+		for i := k; i < len(order); i++ {
+			order[k], order[i] = order[i], order[k]
+			permute(k + 1)
+			order[k], order[i] = order[i], order[k]
+		}
+	}
+	permute(0)
+	return best
+}
+
+// orderDistance walks stops in order from start to end.
+func orderDistance(t *testing.T, g *Graph, start, end NodeID, order []int) float64 {
+	t.Helper()
+	total, at := 0.0, start
+	for _, s := range append(append([]NodeID{}, stopNodes(g, order)...), end) {
+		d, err := g.Distance(at, s)
+		if err != nil {
+			t.Fatalf("Distance() error = %v", err)
+		}
+		total, at = total+d, s
+	}
+	return total
+}
+
+func stopNodes(g *Graph, order []int) []NodeID {
+	out := make([]NodeID, len(order))
+	for i, s := range order {
+		out[i] = g.Stop(s)
+	}
+	return out
+}
+
+// checkCovers fails unless order visits each of n stops once.
+func checkCovers(t *testing.T, order []int, n int) {
+	t.Helper()
+	got := append([]int{}, order...)
+	sort.Ints(got)
+	for i := 0; i < n; i++ {
+		if i >= len(got) || got[i] != i {
+			t.Fatalf("order %v does not visit each of %d stops once", order, n)
+		}
+	}
+	if len(got) != n {
+		t.Fatalf("order %v does not visit each of %d stops once", order, n)
+	}
+}
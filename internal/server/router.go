@@ -4,6 +4,7 @@ import (
   "github.com/gin-gonic/gin"
   "github.com/gin-contrib/cors"
 
+  "github.com/slotter-org/slotter-backend/internal/events"
   "github.com/slotter-org/slotter-backend/internal/handlers"
   "github.com/slotter-org/slotter-backend/internal/middleware"
 )
@@ -19,6 +20,28 @@ type RouterConfig struct {
   WarehouseHandler      *handlers.WarehouseHandler
   SSEHandler            *handlers.SSEHandler
   RoleHandler           *handlers.RoleHandler
+  TemplateHandler       *handlers.TemplateHandler
+  OutboxHandler         *handlers.OutboxHandler
+  SMSHandler            *handlers.SMSHandler
+  AvatarHandler         *handlers.AvatarHandler
+  FileHandler           *handlers.FileHandler
+  LocationHandler       *handlers.WarehouseLocationHandler
+  ItemHandler           *handlers.ItemHandler
+  InventoryHandler      *handlers.InventoryHandler
+  VelocityHandler       *handlers.VelocityHandler
+  SlottingHandler       *handlers.SlottingHandler
+  ScenarioHandler       *handlers.SlottingScenarioHandler
+  MoveTaskHandler       *handlers.MoveTaskHandler
+  NavigationHandler     *handlers.WarehouseNavigationHandler
+  FloorMapHandler       *handlers.FloorMapHandler
+  ChatHandler           *handlers.ChatHandler
+  WebhookHandler        *handlers.WebhookHandler
+  AuditHandler          *handlers.AuditHandler
+  PresenceHandler       *handlers.PresenceHandler
+  EventBus              *events.Bus
+  EventJournal          events.Journal
+  LocalStorageRoute     string
+  LocalFilesHandler     *handlers.LocalFilesHandler
 }
 
 func NewRouter(cfg RouterConfig) *gin.Engine {
@@ -41,11 +64,27 @@ func NewRouter(cfg RouterConfig) *gin.Engine {
     AllowCredentials: true,
 }))
 
+  //-----------------------------------------
+  // Domain events recorded by a request are
+  // published once it has succeeded
+  //-----------------------------------------
+  router.Use(middleware.PublishEvents(cfg.EventBus, cfg.EventJournal))
+
   //-----------------------------------------
   // Health Routes
   //-----------------------------------------
   router.GET("/healthz", handlers.Healthz)
 
+  //-----------------------------------------
+  // Local Storage (only with STORAGE_BACKEND=local)
+  //-----------------------------------------
+  if cfg.LocalFilesHandler != nil {
+    router.GET(cfg.LocalStorageRoute+"/*key", cfg.LocalFilesHandler.ServeFile)
+    router.HEAD(cfg.LocalStorageRoute+"/*key", cfg.LocalFilesHandler.ServeFile)
+    router.PUT(cfg.LocalStorageRoute+"/*key", cfg.LocalFilesHandler.PutFile)
+    router.DELETE(cfg.LocalStorageRoute+"/*key", cfg.LocalFilesHandler.DeleteFile)
+  }
+
   //-----------------------------------------
   // Public Routes
   //-----------------------------------------
@@ -55,6 +94,7 @@ func NewRouter(cfg RouterConfig) *gin.Engine {
     api.Use(middleware.AttachRequestContext()).POST("/invitation/register", cfg.AuthHandler.RegisterWithInvitation)
     api.POST("/login", cfg.AuthHandler.Login)
     api.Use(middleware.AttachRequestContext()).POST("/invitation/validtoken", cfg.InvitationHandler.ValidateInvitationToken)
+    api.POST("/sms/inbound", cfg.SMSHandler.InboundSMS)
   }
 
 
@@ -72,6 +112,10 @@ func NewRouter(cfg RouterConfig) *gin.Engine {
   protected.POST("/sse/subscribe", cfg.SSEHandler.SSESubscribe)
   protected.POST("/sse/unsubscribe", cfg.SSEHandler.SSEUnsubscribe)
 
+  //Presence
+  protected.GET("/presence", cfg.PresenceHandler.ListPresence)
+  protected.PUT("/presence/viewing", cfg.PresenceHandler.SetViewing)
+
   //ME
   protected.GET("/me", cfg.MeHandler.GetMe)
   protected.GET("/mywms", cfg.MeHandler.GetMyWms)
@@ -99,6 +143,98 @@ func NewRouter(cfg RouterConfig) *gin.Engine {
   //Warehouse
   protected.POST("/warehouse", cfg.WarehouseHandler.CreateWarehouse)
 
+  //Warehouse Locations
+  locationsGroup := api.Group("/warehouses/:id/locations")
+  locationsGroup.GET("", cfg.AuthMiddleware.RequireAuth(), cfg.LocationHandler.ListLocations)
+  locationsGroup.GET("/:locationId", cfg.AuthMiddleware.RequireAuth(), cfg.LocationHandler.GetLocation)
+  locationsGroup.POST("", cfg.AuthMiddleware.RequirePermission("create_locations"), cfg.LocationHandler.CreateLocations)
+  locationsGroup.PATCH("/:locationId", cfg.AuthMiddleware.RequirePermission("update_locations"), cfg.LocationHandler.UpdateLocation)
+  locationsGroup.DELETE("/:locationId", cfg.AuthMiddleware.RequirePermission("delete_locations"), cfg.LocationHandler.DeleteLocation)
+  api.POST("/warehouses/:id/layout/import", cfg.AuthMiddleware.RequirePermission("create_locations"), cfg.LocationHandler.ImportLayout)
+
+  //Navigation & Routing
+  api.GET("/warehouses/:id/navigation", cfg.AuthMiddleware.RequireAuth(), cfg.NavigationHandler.GetNavigation)
+  api.PUT("/warehouses/:id/navigation", cfg.AuthMiddleware.RequirePermission("update_locations"), cfg.NavigationHandler.UpdateNavigation)
+  api.POST("/warehouses/:id/route-estimate", cfg.AuthMiddleware.RequireAuth(), cfg.NavigationHandler.EstimateRoute)
+
+  //Floor Maps
+  api.GET("/warehouses/:id/floor-map", cfg.AuthMiddleware.RequireAuth(), cfg.FloorMapHandler.GetFloorMap)
+
+  //Items
+  itemsGroup := api.Group("/companies/:id/items")
+  itemsGroup.GET("", cfg.AuthMiddleware.RequireAuth(), cfg.ItemHandler.ListItems)
+  itemsGroup.GET("/lookup", cfg.AuthMiddleware.RequireAuth(), cfg.ItemHandler.LookupGTIN)
+  itemsGroup.GET("/export", cfg.AuthMiddleware.RequireAuth(), cfg.ItemHandler.ExportItems)
+  itemsGroup.GET("/:itemId", cfg.AuthMiddleware.RequireAuth(), cfg.ItemHandler.GetItem)
+  itemsGroup.POST("", cfg.AuthMiddleware.RequirePermission("create_items"), cfg.ItemHandler.CreateItem)
+  itemsGroup.POST("/import", cfg.AuthMiddleware.RequirePermission("create_items"), cfg.ItemHandler.ImportItems)
+  itemsGroup.PUT("/:itemId", cfg.AuthMiddleware.RequirePermission("update_items"), cfg.ItemHandler.UpdateItem)
+  itemsGroup.DELETE("/:itemId", cfg.AuthMiddleware.RequirePermission("delete_items"), cfg.ItemHandler.DeleteItem)
+
+  //Inventory
+  inventoryGroup := api.Group("/warehouses/:id/inventory")
+  inventoryGroup.GET("", cfg.AuthMiddleware.RequireAuth(), cfg.InventoryHandler.ListInventory)
+  inventoryGroup.GET("/ledger", cfg.AuthMiddleware.RequireAuth(), cfg.InventoryHandler.ListLedger)
+  inventoryGroup.POST("/transactions", cfg.AuthMiddleware.RequirePermission("manage_inventory"), cfg.InventoryHandler.RecordTransactions)
+
+  //Order History & Velocity
+  api.POST("/warehouses/:id/order-lines", cfg.AuthMiddleware.RequirePermission("manage_slotting"), cfg.VelocityHandler.IngestOrderLines)
+  api.POST("/warehouses/:id/order-lines/import", cfg.AuthMiddleware.RequirePermission("manage_slotting"), cfg.VelocityHandler.ImportOrderLines)
+  velocityGroup := api.Group("/warehouses/:id/velocity")
+  velocityGroup.GET("", cfg.AuthMiddleware.RequireAuth(), cfg.VelocityHandler.ListVelocity)
+  velocityGroup.GET("/affinity", cfg.AuthMiddleware.RequireAuth(), cfg.VelocityHandler.ListAffinities)
+  velocityGroup.GET("/runs", cfg.AuthMiddleware.RequireAuth(), cfg.VelocityHandler.ListRuns)
+  velocityGroup.GET("/runs/:runId", cfg.AuthMiddleware.RequireAuth(), cfg.VelocityHandler.GetRun)
+  velocityGroup.POST("/runs", cfg.AuthMiddleware.RequirePermission("manage_slotting"), cfg.VelocityHandler.StartRun)
+
+  //Slotting
+  slottingGroup := api.Group("/warehouses/:id/slotting")
+  slottingGroup.GET("/jobs", cfg.AuthMiddleware.RequireAuth(), cfg.SlottingHandler.ListJobs)
+  slottingGroup.GET("/jobs/:jobId", cfg.AuthMiddleware.RequireAuth(), cfg.SlottingHandler.GetJob)
+  slottingGroup.GET("/jobs/:jobId/assignments", cfg.AuthMiddleware.RequireAuth(), cfg.SlottingHandler.ListAssignments)
+  slottingGroup.GET("/jobs/:jobId/moves", cfg.AuthMiddleware.RequireAuth(), cfg.SlottingHandler.ListMoves)
+  slottingGroup.POST("/jobs", cfg.AuthMiddleware.RequirePermission("manage_slotting"), cfg.SlottingHandler.StartJob)
+  slottingGroup.POST("/jobs/:jobId/cancel", cfg.AuthMiddleware.RequirePermission("manage_slotting"), cfg.SlottingHandler.CancelJob)
+  slottingGroup.GET("/scenarios", cfg.AuthMiddleware.RequireAuth(), cfg.ScenarioHandler.ListScenarios)
+  slottingGroup.GET("/scenarios/compare", cfg.AuthMiddleware.RequireAuth(), cfg.ScenarioHandler.CompareScenarios)
+  slottingGroup.GET("/scenarios/:scenarioId", cfg.AuthMiddleware.RequireAuth(), cfg.ScenarioHandler.GetScenario)
+  slottingGroup.POST("/scenarios", cfg.AuthMiddleware.RequirePermission("manage_slotting"), cfg.ScenarioHandler.CreateScenario)
+  slottingGroup.PATCH("/scenarios/:scenarioId", cfg.AuthMiddleware.RequirePermission("manage_slotting"), cfg.ScenarioHandler.UpdateScenario)
+  slottingGroup.DELETE("/scenarios/:scenarioId", cfg.AuthMiddleware.RequirePermission("manage_slotting"), cfg.ScenarioHandler.DeleteScenario)
+  slottingGroup.POST("/scenarios/:scenarioId/run", cfg.AuthMiddleware.RequirePermission("manage_slotting"), cfg.ScenarioHandler.RunScenario)
+  slottingGroup.POST("/scenarios/:scenarioId/promote", cfg.AuthMiddleware.RequirePermission("manage_slotting"), cfg.ScenarioHandler.PromoteScenario)
+  slottingGroup.GET("/plans", cfg.AuthMiddleware.RequireAuth(), cfg.ScenarioHandler.ListPlans)
+  slottingGroup.GET("/plans/:planId", cfg.AuthMiddleware.RequireAuth(), cfg.ScenarioHandler.GetPlan)
+  slottingGroup.POST("/plans/:planId/tasks", cfg.AuthMiddleware.RequirePermission("manage_slotting"), cfg.MoveTaskHandler.GenerateTasks)
+
+  //Move Tasks
+  moveTaskGroup := api.Group("/warehouses/:id/move-tasks")
+  moveTaskGroup.GET("", cfg.AuthMiddleware.RequireAuth(), cfg.MoveTaskHandler.ListTasks)
+  moveTaskGroup.GET("/:taskId", cfg.AuthMiddleware.RequireAuth(), cfg.MoveTaskHandler.GetTask)
+  moveTaskGroup.POST("/:taskId/assign", cfg.AuthMiddleware.RequirePermission("manage_slotting"), cfg.MoveTaskHandler.AssignTask)
+  moveTaskGroup.POST("/:taskId/reopen", cfg.AuthMiddleware.RequirePermission("manage_slotting"), cfg.MoveTaskHandler.ReopenTask)
+  moveTaskGroup.POST("/:taskId/cancel", cfg.AuthMiddleware.RequirePermission("manage_slotting"), cfg.MoveTaskHandler.CancelTask)
+  moveTaskGroup.POST("/:taskId/claim", cfg.AuthMiddleware.RequirePermission("perform_moves"), cfg.MoveTaskHandler.ClaimTask)
+  moveTaskGroup.POST("/:taskId/start", cfg.AuthMiddleware.RequirePermission("perform_moves"), cfg.MoveTaskHandler.StartTask)
+  moveTaskGroup.POST("/:taskId/confirm", cfg.AuthMiddleware.RequirePermission("perform_moves"), cfg.MoveTaskHandler.ConfirmTask)
+  moveTaskGroup.POST("/:taskId/exception", cfg.AuthMiddleware.RequirePermission("perform_moves"), cfg.MoveTaskHandler.ReportException)
+
+  //Assistant Chat
+  chatGroup := api.Group("/chat/sessions")
+  chatGroup.Use(cfg.AuthMiddleware.RequireAuth())
+  chatGroup.GET("", cfg.ChatHandler.ListSessions)
+  chatGroup.POST("", cfg.ChatHandler.CreateSession)
+  chatGroup.GET("/:id", cfg.ChatHandler.GetSession)
+  chatGroup.PATCH("/:id", cfg.ChatHandler.UpdateSession)
+  chatGroup.DELETE("/:id", cfg.ChatHandler.DeleteSession)
+  chatGroup.POST("/:id/messages", cfg.ChatHandler.PostMessage)
+  chatGroup.PUT("/:id/pin", cfg.ChatHandler.PinSession)
+  chatGroup.DELETE("/:id/pin", cfg.ChatHandler.UnpinSession)
+  chatGroup.GET("/:id/export", cfg.ChatHandler.ExportSession)
+  api.GET("/chat/search", cfg.AuthMiddleware.RequireAuth(), cfg.ChatHandler.SearchMessages)
+  api.GET("/chat/retention", cfg.AuthMiddleware.RequireAuth(), cfg.ChatHandler.GetRetention)
+  api.PUT("/chat/retention", cfg.AuthMiddleware.RequirePermission("manage_chat"), cfg.ChatHandler.SetRetention)
+
   //Invitations  
   protected.Use(cfg.AuthMiddleware.RequirePermission("create_invitations")).POST("/invitation", cfg.InvitationHandler.SendInvitation)
   protected.Use(cfg.AuthMiddleware.RequirePermission("update_invitations")).PATCH("/invitation", cfg.InvitationHandler.UpdateInvitationMsgName)
@@ -107,6 +243,46 @@ func NewRouter(cfg RouterConfig) *gin.Engine {
   protected.Use(cfg.AuthMiddleware.RequirePermission("update_invitations")).PATCH("/invitation/resend", cfg.InvitationHandler.ResendInvitation)
   protected.Use(cfg.AuthMiddleware.RequirePermission("delete_invitations")).DELETE("/invitation", cfg.InvitationHandler.DeleteInvitation)
   
+  //Templates
+  templatesGroup := api.Group("/templates")
+  templatesGroup.Use(cfg.AuthMiddleware.RequirePermission("manage_templates"))
+  templatesGroup.GET("", cfg.TemplateHandler.ListTemplates)
+  templatesGroup.GET("/:type/preview", cfg.TemplateHandler.PreviewTemplate)
+
+  //Outbox
+  outboxGroup := api.Group("/outbox")
+  outboxGroup.Use(cfg.AuthMiddleware.RequirePermission("manage_outbox"))
+  outboxGroup.GET("/failed", cfg.OutboxHandler.ListFailedMessages)
+  outboxGroup.POST("/retry", cfg.OutboxHandler.RetryMessages)
+
+  //Webhooks
+  webhooksGroup := api.Group("/webhooks")
+  webhooksGroup.Use(cfg.AuthMiddleware.RequirePermission("manage_webhooks"))
+  webhooksGroup.GET("", cfg.WebhookHandler.ListEndpoints)
+  webhooksGroup.POST("", cfg.WebhookHandler.CreateEndpoint)
+  webhooksGroup.DELETE("/:id", cfg.WebhookHandler.DeleteEndpoint)
+
+  //Audit Log
+  api.GET("/audit", cfg.AuthMiddleware.RequirePermission("view_audit_log"), cfg.AuditHandler.ListEntries)
+
+  //Files
+  filesGroup := api.Group("/files")
+  filesGroup.POST("/sign", cfg.AuthMiddleware.RequireAuth(), cfg.FileHandler.SignURL)
+  filesGroup.POST("/sign-write", cfg.AuthMiddleware.RequirePermission("manage_files"), cfg.FileHandler.SignWriteURL)
+
+  //Avatars
+  avatarGroup := api.Group("/")
+  avatarGroup.Use(cfg.AuthMiddleware.RequirePermission("update_avatar"))
+  for _, entity := range []string{"user", "company", "wms", "warehouse", "role"} {
+    avatarGroup.PUT("/"+entity+"/:id/avatar", cfg.AvatarHandler.UploadAvatar)
+    avatarGroup.DELETE("/"+entity+"/:id/avatar", cfg.AvatarHandler.ResetAvatar)
+  }
+  avatarGroup.PUT("/mywms/avatar-palette", cfg.AvatarHandler.SetWmsPalette)
+  avatarViewGroup := api.Group("/")
+  avatarViewGroup.Use(cfg.AuthMiddleware.RequireAuth())
+  for _, entity := range []string{"user", "company", "wms", "warehouse", "role"} {
+    avatarViewGroup.GET("/"+entity+"/:id/avatar.svg", cfg.AvatarHandler.GetAvatarSVG)
+  }
 
   return router
 }
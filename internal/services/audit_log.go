@@ -0,0 +1,65 @@
+package services
+
+import (
+  "context"
+  "time"
+
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/events"
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+const maxAuditLogPage = 500
+
+// AuditService reads the requester's tenant's audit log. Entries are
+// written by the event outbox target as journaled events are delivered.
+type AuditService interface {
+  List(ctx context.Context, tx *gorm.DB, filter repos.AuditLogFilter) ([]*types.AuditLogEntry, error)
+}
+
+type auditService struct {
+  db                *gorm.DB
+  log               *logger.Logger
+  auditRepo         repos.AuditLogRepo
+}
+
+func NewAuditService(db *gorm.DB, log *logger.Logger, auditRepo repos.AuditLogRepo) AuditService {
+  return &auditService{
+    db:         db,
+    log:        log.With("service", "AuditService"),
+    auditRepo:  auditRepo,
+  }
+}
+
+func (as *auditService) List(ctx context.Context, tx *gorm.DB, filter repos.AuditLogFilter) ([]*types.AuditLogEntry, error) {
+  as.log.Info("Starting List now...")
+  wmsID, companyID, err := outboxTenantFromRequest(ctx)
+  if err != nil {
+    return nil, err
+  }
+  if filter.Limit <= 0 || filter.Limit > maxAuditLogPage {
+    filter.Limit = maxAuditLogPage
+  }
+  return as.auditRepo.GetByTenant(ctx, tx, wmsID, companyID, filter)
+}
+
+// auditLogEntry is the audit log's record of ev.
+func auditLogEntry(ev events.Event) *types.AuditLogEntry {
+  occurredAt := ev.OccurredAt
+  if occurredAt.IsZero() {
+    occurredAt = time.Now()
+  }
+  return &types.AuditLogEntry{
+    EventID:      ev.ID,
+    WmsID:        ev.WmsID,
+    CompanyID:    ev.CompanyID,
+    ActorID:      ev.ActorID,
+    EventType:    string(ev.Type),
+    Version:      ev.Version,
+    Data:         ev.Data,
+    OccurredAt:   occurredAt,
+  }
+}
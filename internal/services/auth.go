@@ -12,13 +12,12 @@ import (
   "github.com/golang-jwt/jwt/v5"
   "github.com/google/uuid"
 
+  "github.com/slotter-org/slotter-backend/internal/events"
   "github.com/slotter-org/slotter-backend/internal/normalization"
   "github.com/slotter-org/slotter-backend/internal/logger"
   "github.com/slotter-org/slotter-backend/internal/types"
   "github.com/slotter-org/slotter-backend/internal/repos"
   "github.com/slotter-org/slotter-backend/internal/requestdata"
-  "github.com/slotter-org/slotter-backend/internal/ssedata"
-  "github.com/slotter-org/slotter-backend/internal/sse"
   "github.com/slotter-org/slotter-backend/internal/utils"
 )
 
@@ -317,22 +316,7 @@ func (as *authService) createFinalUser(ctx context.Context, tx *gorm.DB, user *t
     as.log.Warn("Failure to actually create user from AuthService")
     return fmt.Errorf("Failure to create user in DB")
   }
-  ssd := ssedata.GetSSEData(ctx)
-  if ssd != nil {
-    if user.UserType == "wms" && user.WmsID != nil && *user.WmsID != uuid.Nil {
-      ssd.AppendMessage(sse.SSEMessage{
-        Channel: "wms:" + user.WmsID.String(),
-        Event: sse.SSEEventUserJoined,
-      })
-    } else if user.UserType == "company" && user.CompanyID != nil && *user.CompanyID != uuid.Nil {
-      ssd.AppendMessage(sse.SSEMessage{
-        Channel: "company:" + user.CompanyID.String(),
-        Event: sse.SSEEventUserJoined,
-        Data: user,
-      })
-    }
-  }
-  return nil
+  return events.RecordTx(ctx, tx, userTenantEvent(events.UserJoined, user, user))
 }
 
 func (as *authService) RegisterUserWithInvitationToken(ctx context.Context, user *types.User, token string, newCompanyName string) error {
@@ -362,17 +346,17 @@ func (as *authService) RegisterUserWithInvitationToken(ctx context.Context, user
     }
     if inv.Email != nil && *inv.Email != "" {
       if user.Email == "" {
-        return fmt.Errorf("this invitation is for email '%s' but user provided none")
+        return fmt.Errorf("this invitation is for email '%s' but user provided none", *inv.Email)
       }
       if !strings.EqualFold(user.Email, *inv.Email) {
         return fmt.Errorf("this invitation is bound to email '%s'; provided email '%s' does not match", *inv.Email, user.Email)
       }
     } else if inv.PhoneNumber != nil && *inv.PhoneNumber != "" {
       if user.PhoneNumber == nil || *user.PhoneNumber == "" {
-        return fmt.Errorf("this invitation is for phone '%s' but user provided none")
+        return fmt.Errorf("this invitation is for phone '%s' but user provided none", *inv.PhoneNumber)
       }
       if !strings.EqualFold(*user.PhoneNumber, *inv.PhoneNumber) {
-        return fmt.Errorf("this invitation is bound to phone number '%s'; provided phone '%s' does not match", *inv.PhoneNumber, user.PhoneNumber)
+        return fmt.Errorf("this invitation is bound to phone number '%s'; provided phone '%s' does not match", *inv.PhoneNumber, *user.PhoneNumber)
       }
     }
     switch inv.InvitationType {
@@ -427,20 +411,8 @@ func (as *authService) RegisterUserWithInvitationToken(ctx context.Context, user
     if _, err := as.invitationRepo.Update(ctx, tx, []*types.Invitation{inv}); err != nil {
       return fmt.Errorf("failed to mark invitation as accepted: %w", err)
     }
-    if ssd := ssedata.GetSSEData(ctx); ssd != nil {
-      var channel string
-      if user.UserType == "wms" && user.WmsID != nil && *user.WmsID != uuid.Nil {
-        channel = "wms:" + user.WmsID.String()
-      } else if user.UserType == "company" && user.CompanyID != nil && *user.CompanyID != uuid.Nil {
-        channel = "company:" + user.CompanyID.String()
-      }
-      if channel != "" {
-        ssd.AppendMessage(sse.SSEMessage{
-          Channel: channel,
-          Event: sse.SSEEventInvitationAccepted,
-          Data: inv,
-        })
-      }
+    if err := events.RecordTx(ctx, tx, userTenantEvent(events.InvitationAccepted, user, inv)); err != nil {
+      return err
     }
     as.log.Info("Successfully registered user with invitation token", "userID", user.ID)
     return nil
@@ -817,3 +789,15 @@ func (as *authService) SetContextFromToken(ctx context.Context, tokenString stri
 func (as *authService) GetAccessTTL() time.Duration {
   return as.accessTTL
 }
+
+// userTenantEvent routes an event to the tenant of the user's type.
+func userTenantEvent(t events.Type, user *types.User, data any) events.Event {
+  ev := events.New(t, data)
+  switch {
+  case user.UserType == "wms" && user.WmsID != nil:
+    return ev.ForWms(*user.WmsID)
+  case user.UserType == "company" && user.CompanyID != nil:
+    return ev.ForCompany(*user.CompanyID)
+  }
+  return ev
+}
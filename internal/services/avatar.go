@@ -6,18 +6,17 @@ import (
   "fmt"
   "image"
   "image/color"
-  "encoding/json"
+  "io"
   "io/ioutil"
   "math"
-  "math/rand"
   "os"
   "path/filepath"
   "strings"
-  "time"
 
   "github.com/disintegration/imaging"
   "github.com/fogleman/gg"
   "github.com/golang/freetype/truetype"
+  "github.com/google/uuid"
   "golang.org/x/image/font"
   "gorm.io/gorm"
   
@@ -25,6 +24,7 @@ import (
   "github.com/slotter-org/slotter-backend/internal/logger"
   "github.com/slotter-org/slotter-backend/internal/types"
   "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/utils"
 )
 
 type AvatarService interface {
@@ -41,6 +41,15 @@ type AvatarService interface {
   GenerateWarehouseAvatar(ctx context.Context, tx *gorm.DB, warehouse *types.Warehouse) (bytes.Buffer, error)
   GenerateRoleAvatar(ctx context.Context, tx *gorm.DB, role *types.Role) (bytes.Buffer, error)
   GenerateInvitationAvatar(ctx context.Context, tx *gorm.DB, invitation *types.Invitation) (bytes.Buffer, error)
+
+  UploadAvatar(ctx context.Context, tx *gorm.DB, entityType AvatarEntityType, entityID uuid.UUID, file io.Reader) (*AvatarResult, error)
+  uploadAvatarLogic(ctx context.Context, tx *gorm.DB, entityType AvatarEntityType, entityID uuid.UUID, img image.Image) (*AvatarResult, []avatarFile, error)
+  ResetAvatar(ctx context.Context, tx *gorm.DB, entityType AvatarEntityType, entityID uuid.UUID) (*AvatarResult, error)
+  resetAvatarLogic(ctx context.Context, tx *gorm.DB, entityType AvatarEntityType, entityID uuid.UUID) (*AvatarResult, []avatarFile, error)
+  MaxUploadBytes() int64
+
+  GenerateAvatarSVG(ctx context.Context, tx *gorm.DB, entityType AvatarEntityType, entityID uuid.UUID, theme AvatarTheme) ([]byte, error)
+  SetWmsPalette(ctx context.Context, tx *gorm.DB, colors []string) (*types.Wms, error)
 }
 
 type avatarService struct {
@@ -58,8 +67,9 @@ type avatarService struct {
   warehouseIcons    []string
   roleIcons	    []string
   invitationIcons   []string
-  bgColors	    []color.NRGBA
+  palette	    AvatarPalette
   fontFace	    font.Face
+  maxUploadBytes    int64
 }
 
 func NewAvatarService(
@@ -75,9 +85,6 @@ func NewAvatarService(
 ) (AvatarService, error) {
   serviceLog := log.With("service", "AvatarService")
 
-
-  rand.Seed(time.Now().UnixNano())
-
   //1) Gather list of icons in company folder && wms folder
   companyDir := os.Getenv("COMPANY_ASSET_DIR_PATH")
   if companyDir == "" {
@@ -146,7 +153,7 @@ func NewAvatarService(
     return nil, fmt.Errorf("env var AVATAR_COLORS_JSON_PATH is empty")
   }
   serviceLog.Info("Loading avatar colors from JSON file", "path", colorsJSONPath)
-  bgColors, err := loadColorsFromFile(colorsJSONPath)
+  palette, err := loadPaletteFromFile(colorsJSONPath)
   if err != nil {
     return nil, fmt.Errorf("could not load avatar colors: %w", err)
   }
@@ -162,6 +169,12 @@ func NewAvatarService(
     return nil, fmt.Errorf("could not load avatar font: %w", err)
   }
 
+  //4) Upload limits
+  maxUploadBytes := utils.GetEnvAsInt("AVATAR_MAX_UPLOAD_BYTES", defaultAvatarMaxUploadBytes, serviceLog)
+  if maxUploadBytes <= 0 {
+    maxUploadBytes = defaultAvatarMaxUploadBytes
+  }
+
   service := &avatarService{
     db:		      db,
     log:	      serviceLog,
@@ -177,8 +190,9 @@ func NewAvatarService(
     warehouseIcons:   warehouseFiles,
     roleIcons:	      roleFiles,
     invitationIcons:  invitationFiles,
-    bgColors:	      bgColors,
+    palette:	      palette,
     fontFace:	      face,
+    maxUploadBytes:   int64(maxUploadBytes),
   }
   return service, nil
 }
@@ -192,6 +206,13 @@ func (as *avatarService) CreateAndUploadWmsAvatar(ctx context.Context, tx *gorm.
   if err := as.bucketService.UploadFile(ctx, tx, bucketKey, bytes.NewReader(buf.Bytes())); err != nil {
     return fmt.Errorf("Failed to upload wms avatar: %w", err)
   }
+  svg, err := as.wmsAvatarSVG(ctx, tx, wms, AvatarThemeLight)
+  if err != nil {
+    return err
+  }
+  if err := as.uploadAvatarSVG(ctx, tx, bucketKey, svg); err != nil {
+    return fmt.Errorf("Failed to upload wms avatar svg: %w", err)
+  }
   if wms.AvatarBucketKey != bucketKey {
     wms.AvatarBucketKey = bucketKey
   }
@@ -211,6 +232,13 @@ func (as *avatarService) CreateAndUploadCompanyAvatar(ctx context.Context, tx *g
   if err := as.bucketService.UploadFile(ctx, tx, bucketKey, bytes.NewReader(buf.Bytes())); err != nil {
     return fmt.Errorf("Failed to upload company avatar: %w", err)
   }
+  svg, err := as.companyAvatarSVG(ctx, tx, company, AvatarThemeLight)
+  if err != nil {
+    return err
+  }
+  if err := as.uploadAvatarSVG(ctx, tx, bucketKey, svg); err != nil {
+    return fmt.Errorf("Failed to upload company avatar svg: %w", err)
+  }
   if company.AvatarBucketKey != bucketKey {
     company.AvatarBucketKey = bucketKey
   }
@@ -230,6 +258,13 @@ func (as *avatarService) CreateAndUploadUserAvatar(ctx context.Context, tx *gorm
   if err := as.bucketService.UploadFile(ctx, tx, bucketKey, bytes.NewReader(buf.Bytes())); err != nil {
     return fmt.Errorf("Failed to upload user avatar: %w", err)
   }
+  svg, err := as.userAvatarSVG(ctx, tx, user, AvatarThemeLight)
+  if err != nil {
+    return err
+  }
+  if err := as.uploadAvatarSVG(ctx, tx, bucketKey, svg); err != nil {
+    return fmt.Errorf("Failed to upload user avatar svg: %w", err)
+  }
   if user.AvatarBucketKey != bucketKey {
     user.AvatarBucketKey = bucketKey
   }
@@ -249,6 +284,13 @@ func (as *avatarService) CreateAndUploadWarehouseAvatar(ctx context.Context, tx
   if err := as.bucketService.UploadFile(ctx, tx, bucketKey, bytes.NewReader(buf.Bytes())); err != nil {
     return fmt.Errorf("Failed to upload warehouse avatar: %w", err)
   }
+  svg, err := as.warehouseAvatarSVG(ctx, tx, warehouse, AvatarThemeLight)
+  if err != nil {
+    return err
+  }
+  if err := as.uploadAvatarSVG(ctx, tx, bucketKey, svg); err != nil {
+    return fmt.Errorf("Failed to upload warehouse avatar svg: %w", err)
+  }
   if warehouse.AvatarBucketKey != bucketKey {
     warehouse.AvatarBucketKey = bucketKey
   }
@@ -268,6 +310,13 @@ func (as *avatarService) CreateAndUploadRoleAvatar(ctx context.Context, tx *gorm
   if err := as.bucketService.UploadFile(ctx, tx, bucketKey, bytes.NewReader(buf.Bytes())); err != nil {
     return nil, fmt.Errorf("failed to upload role avatar: %w", err)
   }
+  svg, err := as.roleAvatarSVG(role)
+  if err != nil {
+    return nil, err
+  }
+  if err := as.uploadAvatarSVG(ctx, tx, bucketKey, svg); err != nil {
+    return nil, fmt.Errorf("failed to upload role avatar svg: %w", err)
+  }
   if role.AvatarBucketKey != bucketKey {
     role.AvatarBucketKey = bucketKey
   }
@@ -287,6 +336,13 @@ func (as *avatarService) CreateAndUploadInvitationAvatar(ctx context.Context, tx
   if err := as.bucketService.UploadFile(ctx, tx, bucketKey, bytes.NewReader(buf.Bytes())); err != nil {
     return nil, fmt.Errorf("failed to upload invitation avatar: %w", err)
   }
+  svg, err := as.invitationAvatarSVG(invitation)
+  if err != nil {
+    return nil, err
+  }
+  if err := as.uploadAvatarSVG(ctx, tx, bucketKey, svg); err != nil {
+    return nil, fmt.Errorf("failed to upload invitation avatar svg: %w", err)
+  }
   if invitation.AvatarBucketKey != bucketKey {
     invitation.AvatarBucketKey = bucketKey
   }
@@ -308,8 +364,8 @@ func (as *avatarService) GenerateUserAvatar(ctx context.Context, tx *gorm.DB, us
 	dc.DrawCircle(float64(size)/2, float64(size)/2, float64(size)/2)
 	dc.Clip()
 
-	// 3) Use a single solid background color (no gradient)
-	base := as.bgColors[rand.Intn(len(as.bgColors))]
+	// 3) Use a single solid background color (no gradient), stable per user
+	base := as.avatarColor(ctx, tx, user.ID, as.userPaletteWmsID(ctx, tx, user), AvatarThemeLight)
 	dc.SetColor(base)
 	dc.DrawRectangle(0, 0, float64(size), float64(size))
 	dc.Fill()
@@ -343,13 +399,13 @@ func (as *avatarService) GenerateCompanyAvatar(ctx context.Context, tx *gorm.DB,
 	dc.Clip()
 
 	// Solid color background
-	base := as.bgColors[rand.Intn(len(as.bgColors))]
+	base := as.avatarColor(ctx, tx, company.ID, company.WmsID, AvatarThemeLight)
 	dc.SetColor(base)
 	dc.DrawRectangle(0, 0, float64(size), float64(size))
 	dc.Fill()
 
 	// Load and colorize icon
-	iconPath := as.companyIcons[rand.Intn(len(as.companyIcons))]
+	iconPath := pickAvatarIcon(as.companyIcons, company.ID)
 	iconImg, err := imaging.Open(iconPath)
 	if err != nil {
 		return bytes.Buffer{}, fmt.Errorf("failed to open company icon: %w", err)
@@ -379,13 +435,13 @@ func (as *avatarService) GenerateWmsAvatar(ctx context.Context, tx *gorm.DB, wms
 	dc.Clip()
 
 	// Solid color background
-	base := as.bgColors[rand.Intn(len(as.bgColors))]
+	base := as.avatarColor(ctx, tx, wms.ID, &wms.ID, AvatarThemeLight)
 	dc.SetColor(base)
 	dc.DrawRectangle(0, 0, float64(size), float64(size))
 	dc.Fill()
 
 	// Load and colorize icon
-	iconPath := as.wmsIcons[rand.Intn(len(as.wmsIcons))]
+	iconPath := pickAvatarIcon(as.wmsIcons, wms.ID)
 	iconImg, err := imaging.Open(iconPath)
 	if err != nil {
 		return bytes.Buffer{}, fmt.Errorf("failed to open WMS icon: %w", err)
@@ -413,12 +469,12 @@ func (as *avatarService) GenerateWarehouseAvatar(ctx context.Context, tx *gorm.D
   dc.DrawCircle(float64(size)/2, float64(size)/2, float64(size)/2)
   dc.Clip()
 
-  base := as.bgColors[rand.Intn(len(as.bgColors))]
+  base := as.avatarColor(ctx, tx, warehouse.ID, as.companyWmsID(ctx, tx, &warehouse.CompanyID), AvatarThemeLight)
   dc.SetColor(base)
   dc.DrawRectangle(0, 0, float64(size), float64(size))
   dc.Fill()
 
-  iconPath := as.warehouseIcons[rand.Intn(len(as.warehouseIcons))]
+  iconPath := pickAvatarIcon(as.warehouseIcons, warehouse.ID)
   iconImg, err := imaging.Open(iconPath)
   if err != nil {
     return bytes.Buffer{}, fmt.Errorf("failed to open warehouse icon: %w", err)
@@ -437,7 +493,7 @@ func (as *avatarService) GenerateWarehouseAvatar(ctx context.Context, tx *gorm.D
 }
 
 func (as *avatarService) GenerateRoleAvatar(ctx context.Context, tx *gorm.DB, role *types.Role) (bytes.Buffer, error) {
-  iconPath := as.roleIcons[rand.Intn(len(as.roleIcons))]
+  iconPath := pickAvatarIcon(as.roleIcons, role.ID)
 
   img, err := imaging.Open(iconPath)
   if err != nil {
@@ -454,7 +510,7 @@ func (as *avatarService) GenerateRoleAvatar(ctx context.Context, tx *gorm.DB, ro
 }
 
 func (as *avatarService) GenerateInvitationAvatar(ctx context.Context, tx *gorm.DB, invitation *types.Invitation) (bytes.Buffer, error) {
-  iconPath := as.invitationIcons[rand.Intn(len(as.invitationIcons))]
+  iconPath := pickAvatarIcon(as.invitationIcons, invitation.ID)
   img, err := imaging.Open(iconPath)
   if err != nil {
     return bytes.Buffer{}, fmt.Errorf("failed to open invitation icon %q: %w", iconPath, err)
@@ -535,18 +591,6 @@ func findFiles(dir string) ([]string, error) {
   return paths, nil
 }
 
-func loadColorsFromFile(jsonPath string) ([]color.NRGBA, error) {
-  data, err := ioutil.ReadFile(jsonPath)
-  if err != nil {
-    return nil, fmt.Errorf("read file error: %w", err)
-  }
-  var colors []color.NRGBA
-  if err := json.Unmarshal(data, &colors); err != nil {
-    return nil, fmt.Errorf("json unmarshal error: %w", err)
-  }
-  return colors, nil
-}
-
 func loadFontFace(fontPath string, size float64) (font.Face, error) {
   fontBytes, err := ioutil.ReadFile(fontPath)
   if err != nil {
@@ -0,0 +1,347 @@
+package services
+
+import (
+  "bytes"
+  "context"
+  "encoding/base64"
+  "encoding/json"
+  "fmt"
+  "hash/fnv"
+  "html"
+  "image"
+  "image/color"
+  "io/ioutil"
+  "strconv"
+  "strings"
+
+  "github.com/disintegration/imaging"
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type AvatarTheme string
+
+const (
+  AvatarThemeLight  AvatarTheme = "light"
+  AvatarThemeDark   AvatarTheme = "dark"
+)
+
+// darkPaletteShift darkens a WMS override color for the dark theme, since
+// overrides only supply light colors.
+const darkPaletteShift = -0.25
+
+func ParseAvatarTheme(s string) (AvatarTheme, error) {
+  switch t := AvatarTheme(strings.ToLower(strings.TrimSpace(s))); t {
+  case "", AvatarThemeLight:
+    return AvatarThemeLight, nil
+  case AvatarThemeDark:
+    return AvatarThemeDark, nil
+  default:
+    return "", fmt.Errorf("invalid avatar theme: %s", s)
+  }
+}
+
+// AvatarPalette holds index-aligned light and dark colors, so an entity keeps
+// the same hue when the client switches theme.
+type AvatarPalette struct {
+  Light   []color.NRGBA   `json:"light"`
+  Dark    []color.NRGBA   `json:"dark"`
+}
+
+func (p AvatarPalette) colors(theme AvatarTheme) []color.NRGBA {
+  if theme == AvatarThemeDark && len(p.Dark) == len(p.Light) {
+    return p.Dark
+  }
+  return p.Light
+}
+
+// loadPaletteFromFile reads {"light": [...], "dark": [...]}. A bare array, the
+// old format, is accepted as the light palette with derived dark colors.
+func loadPaletteFromFile(jsonPath string) (AvatarPalette, error) {
+  data, err := ioutil.ReadFile(jsonPath)
+  if err != nil {
+    return AvatarPalette{}, fmt.Errorf("read file error: %w", err)
+  }
+  var palette AvatarPalette
+  if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+    if err := json.Unmarshal(data, &palette.Light); err != nil {
+      return AvatarPalette{}, fmt.Errorf("json unmarshal error: %w", err)
+    }
+  } else if err := json.Unmarshal(data, &palette); err != nil {
+    return AvatarPalette{}, fmt.Errorf("json unmarshal error: %w", err)
+  }
+  if len(palette.Light) == 0 {
+    return AvatarPalette{}, fmt.Errorf("palette has no colors")
+  }
+  if len(palette.Dark) != len(palette.Light) {
+    palette.Dark = darkenColors(palette.Light)
+  }
+  return palette, nil
+}
+
+// ParseAvatarPalette parses a comma separated list of #RRGGBB colors, as
+// stored in Wms.AvatarPalette.
+func ParseAvatarPalette(s string) (AvatarPalette, error) {
+  var palette AvatarPalette
+  for _, raw := range strings.Split(s, ",") {
+    raw = strings.TrimSpace(raw)
+    if raw == "" {
+      continue
+    }
+    c, err := parseHexColor(raw)
+    if err != nil {
+      return AvatarPalette{}, err
+    }
+    palette.Light = append(palette.Light, c)
+  }
+  if len(palette.Light) == 0 {
+    return AvatarPalette{}, fmt.Errorf("palette has no colors")
+  }
+  palette.Dark = darkenColors(palette.Light)
+  return palette, nil
+}
+
+// FormatAvatarPalette is the inverse of ParseAvatarPalette.
+func FormatAvatarPalette(colors []color.NRGBA) string {
+  hexes := make([]string, 0, len(colors))
+  for _, c := range colors {
+    hexes = append(hexes, hexColor(c))
+  }
+  return strings.Join(hexes, ",")
+}
+
+func darkenColors(colors []color.NRGBA) []color.NRGBA {
+  out := make([]color.NRGBA, 0, len(colors))
+  for _, c := range colors {
+    out = append(out, lightenOrDarken(c, darkPaletteShift))
+  }
+  return out
+}
+
+func parseHexColor(s string) (color.NRGBA, error) {
+  hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+  if len(hex) == 3 {
+    hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+  }
+  if len(hex) != 6 {
+    return color.NRGBA{}, fmt.Errorf("invalid hex color %q", s)
+  }
+  v, err := strconv.ParseUint(hex, 16, 32)
+  if err != nil {
+    return color.NRGBA{}, fmt.Errorf("invalid hex color %q", s)
+  }
+  return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
+
+func hexColor(c color.NRGBA) string {
+  return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// avatarHash is a stable FNV-1a hash of the entity ID. salt separates the
+// color and icon choices so they do not move in lockstep.
+func avatarHash(id uuid.UUID, salt string) uint64 {
+  h := fnv.New64a()
+  h.Write(id[:])
+  h.Write([]byte(salt))
+  return h.Sum64()
+}
+
+func pickAvatarIcon(icons []string, id uuid.UUID) string {
+  return icons[avatarHash(id, "icon")%uint64(len(icons))]
+}
+
+// avatarColor returns the background for id, from wmsID's palette override
+// when it has one and the default palette otherwise.
+func (as *avatarService) avatarColor(ctx context.Context, tx *gorm.DB, id uuid.UUID, wmsID *uuid.UUID, theme AvatarTheme) color.NRGBA {
+  colors := as.palette.colors(theme)
+  if wmsID != nil && *wmsID != uuid.Nil {
+    if palette, ok := as.wmsPalette(ctx, tx, *wmsID); ok {
+      colors = palette.colors(theme)
+    }
+  }
+  return colors[avatarHash(id, "color")%uint64(len(colors))]
+}
+
+func (as *avatarService) wmsPalette(ctx context.Context, tx *gorm.DB, wmsID uuid.UUID) (AvatarPalette, bool) {
+  wmss, err := as.wmsRepo.GetByIDs(ctx, tx, []uuid.UUID{wmsID})
+  if err != nil {
+    as.log.Warn("Failed to load wms for avatar palette, using default palette", "wmsID", wmsID, "error", err)
+    return AvatarPalette{}, false
+  }
+  if len(wmss) == 0 || strings.TrimSpace(wmss[0].AvatarPalette) == "" {
+    return AvatarPalette{}, false
+  }
+  palette, err := ParseAvatarPalette(wmss[0].AvatarPalette)
+  if err != nil {
+    as.log.Warn("Invalid wms avatar palette, using default palette", "wmsID", wmsID, "error", err)
+    return AvatarPalette{}, false
+  }
+  return palette, true
+}
+
+// SetWmsPalette overrides the requester's wms avatar palette; an empty list
+// restores the default. Existing avatars keep their colors until they are
+// reset.
+func (as *avatarService) SetWmsPalette(ctx context.Context, tx *gorm.DB, colors []string) (*types.Wms, error) {
+  as.log.Info("Starting SetWmsPalette now...", "count", len(colors))
+  rd := requestdata.GetRequestData(ctx)
+  if rd == nil {
+    return nil, fmt.Errorf("request data not set in context")
+  }
+  if rd.UserType != "wms" || rd.WmsID == uuid.Nil {
+    return nil, fmt.Errorf("only wms users can set the avatar palette")
+  }
+  stored := ""
+  if len(colors) > 0 {
+    palette, err := ParseAvatarPalette(strings.Join(colors, ","))
+    if err != nil {
+      return nil, err
+    }
+    stored = FormatAvatarPalette(palette.Light)
+  }
+  transaction := tx
+  if transaction == nil {
+    transaction = as.db.WithContext(ctx)
+  }
+  wmss, err := as.wmsRepo.GetByIDs(ctx, transaction, []uuid.UUID{rd.WmsID})
+  if err != nil {
+    return nil, fmt.Errorf("failed to load wms: %w", err)
+  }
+  if len(wmss) == 0 {
+    return nil, fmt.Errorf("wms not found")
+  }
+  wmss[0].AvatarPalette = stored
+  updated, err := as.wmsRepo.Update(ctx, transaction, wmss)
+  if err != nil {
+    return nil, fmt.Errorf("failed to save wms avatar palette: %w", err)
+  }
+  return updated[0], nil
+}
+
+// companyWmsID returns the wms managing companyID, or nil. The company may
+// not be saved yet while it is being registered.
+func (as *avatarService) companyWmsID(ctx context.Context, tx *gorm.DB, companyID *uuid.UUID) *uuid.UUID {
+  if companyID == nil || *companyID == uuid.Nil {
+    return nil
+  }
+  companies, err := as.companyRepo.GetByIDs(ctx, tx, []uuid.UUID{*companyID})
+  if err != nil || len(companies) == 0 {
+    return nil
+  }
+  return companies[0].WmsID
+}
+
+// userPaletteWmsID is the wms whose palette a user's avatar uses: their own
+// for wms users, their company's wms for company users.
+func (as *avatarService) userPaletteWmsID(ctx context.Context, tx *gorm.DB, user *types.User) *uuid.UUID {
+  if user.UserType == "wms" {
+    return user.WmsID
+  }
+  if user.Company != nil && user.Company.WmsID != nil {
+    return user.Company.WmsID
+  }
+  return as.companyWmsID(ctx, tx, user.CompanyID)
+}
+
+//----------------------------------------------------------------------------------------
+// SVG
+//----------------------------------------------------------------------------------------
+
+// GenerateAvatarSVG renders the generated avatar of an entity the requester
+// can see, in the given theme.
+func (as *avatarService) GenerateAvatarSVG(ctx context.Context, tx *gorm.DB, entityType AvatarEntityType, entityID uuid.UUID, theme AvatarTheme) ([]byte, error) {
+  if tx == nil {
+    var out []byte
+    err := as.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      svg, err := as.GenerateAvatarSVG(ctx, innerTx, entityType, entityID, theme)
+      if err != nil {
+        return err
+      }
+      out = svg
+      return nil
+    })
+    return out, err
+  }
+  target, err := as.loadAvatarTarget(ctx, tx, entityType, entityID)
+  if err != nil {
+    return nil, err
+  }
+  return target.svg(theme)
+}
+
+// AvatarSVGKey is where the SVG rendering of the avatar at bucketKey lives.
+func AvatarSVGKey(bucketKey string) string {
+  return strings.TrimSuffix(bucketKey, ".png") + ".svg"
+}
+
+func (as *avatarService) uploadAvatarSVG(ctx context.Context, tx *gorm.DB, bucketKey string, svg []byte) error {
+  return as.bucketService.UploadFile(ctx, tx, AvatarSVGKey(bucketKey), bytes.NewReader(svg))
+}
+
+func (as *avatarService) userAvatarSVG(ctx context.Context, tx *gorm.DB, user *types.User, theme AvatarTheme) ([]byte, error) {
+  bg := as.avatarColor(ctx, tx, user.ID, as.userPaletteWmsID(ctx, tx, user), theme)
+  return initialsAvatarSVG(computeInitials(user.FirstName, user.LastName), bg), nil
+}
+
+func (as *avatarService) companyAvatarSVG(ctx context.Context, tx *gorm.DB, company *types.Company, theme AvatarTheme) ([]byte, error) {
+  bg := as.avatarColor(ctx, tx, company.ID, company.WmsID, theme)
+  return iconAvatarSVG(pickAvatarIcon(as.companyIcons, company.ID), &bg)
+}
+
+func (as *avatarService) wmsAvatarSVG(ctx context.Context, tx *gorm.DB, wms *types.Wms, theme AvatarTheme) ([]byte, error) {
+  bg := as.avatarColor(ctx, tx, wms.ID, &wms.ID, theme)
+  return iconAvatarSVG(pickAvatarIcon(as.wmsIcons, wms.ID), &bg)
+}
+
+func (as *avatarService) warehouseAvatarSVG(ctx context.Context, tx *gorm.DB, warehouse *types.Warehouse, theme AvatarTheme) ([]byte, error) {
+  bg := as.avatarColor(ctx, tx, warehouse.ID, as.companyWmsID(ctx, tx, &warehouse.CompanyID), theme)
+  return iconAvatarSVG(pickAvatarIcon(as.warehouseIcons, warehouse.ID), &bg)
+}
+
+func (as *avatarService) roleAvatarSVG(role *types.Role) ([]byte, error) {
+  return iconAvatarSVG(pickAvatarIcon(as.roleIcons, role.ID), nil)
+}
+
+func (as *avatarService) invitationAvatarSVG(invitation *types.Invitation) ([]byte, error) {
+  return iconAvatarSVG(pickAvatarIcon(as.invitationIcons, invitation.ID), nil)
+}
+
+func initialsAvatarSVG(initials string, bg color.NRGBA) []byte {
+  var b bytes.Buffer
+  fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="512" height="512" viewBox="0 0 512 512">`)
+  fmt.Fprintf(&b, `<circle cx="256" cy="256" r="256" fill="%s"/>`, hexColor(bg))
+  fmt.Fprintf(&b, `<text x="50%%" y="50%%" dy=".35em" text-anchor="middle" fill="#FFFFFF" font-family="Helvetica, Arial, sans-serif" font-size="206" font-weight="600">%s</text>`, html.EscapeString(initials))
+  b.WriteString(`</svg>`)
+  return b.Bytes()
+}
+
+// iconAvatarSVG embeds the icon as a PNG; a nil bg leaves the background
+// transparent, as role and invitation avatars are.
+func iconAvatarSVG(iconPath string, bg *color.NRGBA) ([]byte, error) {
+  iconImg, err := imaging.Open(iconPath)
+  if err != nil {
+    return nil, fmt.Errorf("failed to open icon %q: %w", iconPath, err)
+  }
+  var icon image.Image = iconImg
+  size, offset := 512, 0
+  if bg != nil {
+    icon = colorizeImageWhite(iconImg)
+    size, offset = 256, 128
+  }
+  icon = imaging.Fit(icon, size, size, imaging.Lanczos)
+  var png bytes.Buffer
+  if err := imaging.Encode(&png, icon, imaging.PNG); err != nil {
+    return nil, fmt.Errorf("failed to encode icon PNG: %w", err)
+  }
+  var b bytes.Buffer
+  fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="512" height="512" viewBox="0 0 512 512">`)
+  if bg != nil {
+    fmt.Fprintf(&b, `<circle cx="256" cy="256" r="256" fill="%s"/>`, hexColor(*bg))
+  }
+  fmt.Fprintf(&b, `<image x="%d" y="%d" width="%d" height="%d" preserveAspectRatio="xMidYMid meet" href="data:image/png;base64,%s"/>`, offset, offset, size, size, base64.StdEncoding.EncodeToString(png.Bytes()))
+  b.WriteString(`</svg>`)
+  return b.Bytes(), nil
+}
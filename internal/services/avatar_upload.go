@@ -0,0 +1,470 @@
+package services
+
+import (
+  "bytes"
+  "context"
+  "errors"
+  "fmt"
+  "image"
+  "io"
+  "net/http"
+  "strings"
+  "time"
+
+  "github.com/disintegration/imaging"
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  _ "golang.org/x/image/webp"
+
+  "github.com/slotter-org/slotter-backend/internal/events"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type AvatarEntityType string
+
+const (
+  AvatarEntityUser        AvatarEntityType = "user"
+  AvatarEntityCompany     AvatarEntityType = "company"
+  AvatarEntityWms         AvatarEntityType = "wms"
+  AvatarEntityWarehouse   AvatarEntityType = "warehouse"
+  AvatarEntityRole        AvatarEntityType = "role"
+)
+
+const (
+  defaultAvatarMaxUploadBytes = 5 << 20
+  // Uploads wider or taller than this are rejected before decoding so a
+  // small, highly compressed file cannot allocate gigabytes of pixels.
+  maxAvatarDimension = 8192
+)
+
+// AvatarVariantSizes are the square sizes stored for every avatar. The
+// largest is written to the entity's existing AvatarBucketKey; the others
+// get a _<size> suffix on the same key.
+var AvatarVariantSizes = []int{64, 128, 512}
+
+var (
+  ErrAvatarTooLarge         = errors.New("avatar file is too large")
+  ErrAvatarUnsupportedType  = errors.New("unsupported avatar content type")
+)
+
+var allowedAvatarContentTypes = map[string]bool{
+  "image/png":  true,
+  "image/jpeg": true,
+  "image/gif":  true,
+  "image/webp": true,
+}
+
+type AvatarResult struct {
+  EntityType        AvatarEntityType    `json:"entityType"`
+  EntityID          uuid.UUID           `json:"entityID"`
+  AvatarBucketKey   string              `json:"avatarBucketKey"`
+  AvatarURL         string              `json:"avatarURL"`
+  Variants          map[int]string      `json:"variants"`
+}
+
+// avatarFile is one rendered file waiting to be written to the bucket.
+type avatarFile struct {
+  key         string
+  data        []byte
+}
+
+// avatarTarget is one loaded, tenant-checked entity whose avatar is being
+// replaced.
+type avatarTarget struct {
+  keyPrefix   string
+  wmsID       uuid.UUID
+  companyID   uuid.UUID
+  generate    func() (bytes.Buffer, error)
+  svg         func(theme AvatarTheme) ([]byte, error)
+  apply       func(bucketKey, url string)
+  save        func() error
+}
+
+func ParseAvatarEntityType(s string) (AvatarEntityType, error) {
+  switch t := AvatarEntityType(strings.ToLower(strings.TrimSpace(s))); t {
+  case AvatarEntityUser, AvatarEntityCompany, AvatarEntityWms, AvatarEntityWarehouse, AvatarEntityRole:
+    return t, nil
+  default:
+    return "", fmt.Errorf("invalid avatar entity type: %s", s)
+  }
+}
+
+// AvatarVariantKey returns the bucket key of the size px variant for an
+// avatar stored at bucketKey.
+func AvatarVariantKey(bucketKey string, size int) string {
+  if size == AvatarVariantSizes[len(AvatarVariantSizes)-1] {
+    return bucketKey
+  }
+  return fmt.Sprintf("%s_%d.png", strings.TrimSuffix(bucketKey, ".png"), size)
+}
+
+//----------------------------------------------------------------------------------------
+// Upload
+//----------------------------------------------------------------------------------------
+
+func (as *avatarService) MaxUploadBytes() int64 {
+  return as.maxUploadBytes
+}
+
+// UploadAvatar validates and decodes file before opening the transaction so
+// no row locks are held while a large image is being processed. The variants
+// are written to the bucket only once the transaction has committed, so a
+// rollback never replaces the avatar the entity still points at. Callers
+// passing their own tx get the files written before it commits.
+func (as *avatarService) UploadAvatar(ctx context.Context, tx *gorm.DB, entityType AvatarEntityType, entityID uuid.UUID, file io.Reader) (*AvatarResult, error) {
+  as.log.Info("Starting UploadAvatar now...", "entityType", entityType, "entityID", entityID)
+  img, err := as.decodeAvatarUpload(file)
+  if err != nil {
+    as.log.Warn("Rejected avatar upload", "entityType", entityType, "entityID", entityID, "error", err)
+    return nil, err
+  }
+  return as.storeAfterCommit(ctx, tx, entityType, func(innerTx *gorm.DB) (*AvatarResult, []avatarFile, error) {
+    return as.uploadAvatarLogic(ctx, innerTx, entityType, entityID, img)
+  })
+}
+
+func (as *avatarService) uploadAvatarLogic(ctx context.Context, tx *gorm.DB, entityType AvatarEntityType, entityID uuid.UUID, img image.Image) (*AvatarResult, []avatarFile, error) {
+  target, err := as.loadAvatarTarget(ctx, tx, entityType, entityID)
+  if err != nil {
+    return nil, nil, err
+  }
+  maxSize := AvatarVariantSizes[len(AvatarVariantSizes)-1]
+  square := imaging.Fill(img, maxSize, maxSize, imaging.Center, imaging.Lanczos)
+  return as.storeAvatar(ctx, tx, entityType, entityID, target, square)
+}
+
+//----------------------------------------------------------------------------------------
+// Reset
+//----------------------------------------------------------------------------------------
+
+// ResetAvatar writes its files after commit, like UploadAvatar.
+func (as *avatarService) ResetAvatar(ctx context.Context, tx *gorm.DB, entityType AvatarEntityType, entityID uuid.UUID) (*AvatarResult, error) {
+  as.log.Info("Starting ResetAvatar now...", "entityType", entityType, "entityID", entityID)
+  return as.storeAfterCommit(ctx, tx, entityType, func(innerTx *gorm.DB) (*AvatarResult, []avatarFile, error) {
+    return as.resetAvatarLogic(ctx, innerTx, entityType, entityID)
+  })
+}
+
+// resetAvatarLogic replaces an uploaded avatar with a freshly generated one.
+func (as *avatarService) resetAvatarLogic(ctx context.Context, tx *gorm.DB, entityType AvatarEntityType, entityID uuid.UUID) (*AvatarResult, []avatarFile, error) {
+  target, err := as.loadAvatarTarget(ctx, tx, entityType, entityID)
+  if err != nil {
+    return nil, nil, err
+  }
+  buf, err := target.generate()
+  if err != nil {
+    return nil, nil, fmt.Errorf("failed to generate %s avatar: %w", entityType, err)
+  }
+  img, err := imaging.Decode(bytes.NewReader(buf.Bytes()))
+  if err != nil {
+    return nil, nil, fmt.Errorf("failed to decode generated %s avatar: %w", entityType, err)
+  }
+  svg, err := target.svg(AvatarThemeLight)
+  if err != nil {
+    return nil, nil, fmt.Errorf("failed to generate %s avatar svg: %w", entityType, err)
+  }
+  result, files, err := as.storeAvatar(ctx, tx, entityType, entityID, target, img)
+  if err != nil {
+    return nil, nil, err
+  }
+  files = append(files, avatarFile{key: AvatarSVGKey(result.AvatarBucketKey), data: svg})
+  return result, files, nil
+}
+
+// storeAfterCommit runs logic in tx, or in a new transaction when tx is nil,
+// and then writes the files it rendered. With a new transaction the files
+// are only written once it has committed.
+func (as *avatarService) storeAfterCommit(ctx context.Context, tx *gorm.DB, entityType AvatarEntityType, logic func(tx *gorm.DB) (*AvatarResult, []avatarFile, error)) (*AvatarResult, error) {
+  var result *AvatarResult
+  var files []avatarFile
+  if tx == nil {
+    err := as.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      res, rendered, err := logic(innerTx)
+      if err != nil {
+        return err
+      }
+      result, files = res, rendered
+      return nil
+    })
+    if err != nil {
+      return nil, err
+    }
+  } else {
+    res, rendered, err := logic(tx)
+    if err != nil {
+      return nil, err
+    }
+    result, files = res, rendered
+  }
+  for _, f := range files {
+    if err := as.bucketService.UploadFile(ctx, tx, f.key, bytes.NewReader(f.data)); err != nil {
+      return nil, fmt.Errorf("failed to upload %s avatar %s: %w", entityType, f.key, err)
+    }
+  }
+  return result, nil
+}
+
+//----------------------------------------------------------------------------------------
+// Helpers
+//----------------------------------------------------------------------------------------
+
+// decodeAvatarUpload enforces the size limit, sniffs the real content type
+// rather than trusting the client's header, and decodes the image. EXIF
+// orientation is applied here; every other piece of metadata is dropped
+// when the variants are re-encoded as PNG.
+func (as *avatarService) decodeAvatarUpload(file io.Reader) (image.Image, error) {
+  if file == nil {
+    return nil, fmt.Errorf("no avatar file provided")
+  }
+  data, err := io.ReadAll(io.LimitReader(file, as.maxUploadBytes+1))
+  if err != nil {
+    return nil, fmt.Errorf("failed to read avatar file: %w", err)
+  }
+  if int64(len(data)) > as.maxUploadBytes {
+    return nil, fmt.Errorf("%w: limit is %d bytes", ErrAvatarTooLarge, as.maxUploadBytes)
+  }
+  if len(data) == 0 {
+    return nil, fmt.Errorf("avatar file is empty")
+  }
+  contentType := http.DetectContentType(data)
+  if !allowedAvatarContentTypes[contentType] {
+    return nil, fmt.Errorf("%w: %s", ErrAvatarUnsupportedType, contentType)
+  }
+  cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+  if err != nil {
+    return nil, fmt.Errorf("%w: %v", ErrAvatarUnsupportedType, err)
+  }
+  if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > maxAvatarDimension || cfg.Height > maxAvatarDimension {
+    return nil, fmt.Errorf("avatar dimensions %dx%d are outside the allowed range (max %d)", cfg.Width, cfg.Height, maxAvatarDimension)
+  }
+  img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+  if err != nil {
+    return nil, fmt.Errorf("failed to decode avatar image: %w", err)
+  }
+  return img, nil
+}
+
+// storeAvatar renders every variant of img, points the entity at the new
+// avatar and records the avatar event. It returns the variants for the caller
+// to write once the transaction has committed.
+func (as *avatarService) storeAvatar(ctx context.Context, tx *gorm.DB, entityType AvatarEntityType, entityID uuid.UUID, target *avatarTarget, img image.Image) (*AvatarResult, []avatarFile, error) {
+  bucketKey := fmt.Sprintf("%s%s.png", target.keyPrefix, entityID.String())
+  version := time.Now().Unix()
+  result := &AvatarResult{
+    EntityType:      entityType,
+    EntityID:        entityID,
+    AvatarBucketKey: bucketKey,
+    Variants:        make(map[int]string, len(AvatarVariantSizes)),
+  }
+  files := make([]avatarFile, 0, len(AvatarVariantSizes))
+  for _, size := range AvatarVariantSizes {
+    variant := imaging.Fit(img, size, size, imaging.Lanczos)
+    var buf bytes.Buffer
+    if err := imaging.Encode(&buf, variant, imaging.PNG); err != nil {
+      return nil, nil, fmt.Errorf("failed to encode %dpx %s avatar: %w", size, entityType, err)
+    }
+    key := AvatarVariantKey(bucketKey, size)
+    files = append(files, avatarFile{key: key, data: buf.Bytes()})
+    result.Variants[size] = versionedAvatarURL(as.bucketService.GetPublicURL(key), version)
+  }
+  result.AvatarURL = result.Variants[AvatarVariantSizes[len(AvatarVariantSizes)-1]]
+
+  target.apply(bucketKey, result.AvatarURL)
+  if err := target.save(); err != nil {
+    return nil, nil, fmt.Errorf("failed to save %s avatar: %w", entityType, err)
+  }
+
+  event := events.AvatarUpdated
+  if entityType == AvatarEntityUser {
+    event = events.UserAvatarUpdated
+  }
+  if err := events.RecordTx(ctx, tx, events.New(event, result).ForWms(target.wmsID).ForCompany(target.companyID)); err != nil {
+    return nil, nil, err
+  }
+  as.log.Info("Stored avatar", "entityType", entityType, "entityID", entityID, "bucketKey", bucketKey)
+  return result, files, nil
+}
+
+// versionedAvatarURL adds a version parameter so browsers and CDNs drop the
+// previous image, which lives at the same key.
+func versionedAvatarURL(url string, version int64) string {
+  sep := "?"
+  if strings.Contains(url, "?") {
+    sep = "&"
+  }
+  return fmt.Sprintf("%s%sv=%d", url, sep, version)
+}
+
+// loadAvatarTarget loads the entity under lock and checks that it belongs to
+// the requester's wms or company.
+func (as *avatarService) loadAvatarTarget(ctx context.Context, tx *gorm.DB, entityType AvatarEntityType, entityID uuid.UUID) (*avatarTarget, error) {
+  rd := requestdata.GetRequestData(ctx)
+  if rd == nil {
+    return nil, fmt.Errorf("request data not set in context")
+  }
+  if entityID == uuid.Nil {
+    return nil, fmt.Errorf("invalid %s id", entityType)
+  }
+  notYours := fmt.Errorf("%s does not belong to your organization", entityType)
+
+  switch entityType {
+  case AvatarEntityUser:
+    users, err := as.userRepo.GetByIDs(ctx, tx, []uuid.UUID{entityID})
+    if err != nil {
+      return nil, fmt.Errorf("failed to load user: %w", err)
+    }
+    if len(users) == 0 {
+      return nil, fmt.Errorf("user not found")
+    }
+    user := users[0]
+    var wmsID, companyID uuid.UUID
+    switch {
+    case rd.UserType == "wms" && user.WmsID != nil && *user.WmsID == rd.WmsID:
+      wmsID = rd.WmsID
+    case rd.UserType == "company" && user.CompanyID != nil && *user.CompanyID == rd.CompanyID:
+      companyID = rd.CompanyID
+    default:
+      return nil, notYours
+    }
+    return &avatarTarget{
+      keyPrefix: "user_avatars/",
+      wmsID:     wmsID,
+      companyID: companyID,
+      generate:  func() (bytes.Buffer, error) { return as.GenerateUserAvatar(ctx, tx, user) },
+      svg:       func(theme AvatarTheme) ([]byte, error) { return as.userAvatarSVG(ctx, tx, user, theme) },
+      apply:     func(key, url string) { user.AvatarBucketKey, user.AvatarURL = key, url },
+      save: func() error {
+        _, err := as.userRepo.Update(ctx, tx, []*types.User{user})
+        return err
+      },
+    }, nil
+
+  case AvatarEntityCompany:
+    company, err := as.loadOwnedCompany(ctx, tx, rd, entityID)
+    if err != nil {
+      return nil, err
+    }
+    var wmsID uuid.UUID
+    if company.WmsID != nil {
+      wmsID = *company.WmsID
+    }
+    return &avatarTarget{
+      keyPrefix: "company_avatars/",
+      wmsID:     wmsID,
+      companyID: company.ID,
+      generate:  func() (bytes.Buffer, error) { return as.GenerateCompanyAvatar(ctx, tx, company) },
+      svg:       func(theme AvatarTheme) ([]byte, error) { return as.companyAvatarSVG(ctx, tx, company, theme) },
+      apply:     func(key, url string) { company.AvatarBucketKey, company.AvatarURL = key, url },
+      save: func() error {
+        _, err := as.companyRepo.Update(ctx, tx, []*types.Company{company})
+        return err
+      },
+    }, nil
+
+  case AvatarEntityWms:
+    if rd.UserType != "wms" || rd.WmsID != entityID {
+      return nil, notYours
+    }
+    wmss, err := as.wmsRepo.GetByIDs(ctx, tx, []uuid.UUID{entityID})
+    if err != nil {
+      return nil, fmt.Errorf("failed to load wms: %w", err)
+    }
+    if len(wmss) == 0 {
+      return nil, fmt.Errorf("wms not found")
+    }
+    wms := wmss[0]
+    return &avatarTarget{
+      keyPrefix: "wms_avatars/",
+      wmsID:     wms.ID,
+      generate:  func() (bytes.Buffer, error) { return as.GenerateWmsAvatar(ctx, tx, wms) },
+      svg:       func(theme AvatarTheme) ([]byte, error) { return as.wmsAvatarSVG(ctx, tx, wms, theme) },
+      apply:     func(key, url string) { wms.AvatarBucketKey, wms.AvatarURL = key, url },
+      save: func() error {
+        _, err := as.wmsRepo.Update(ctx, tx, []*types.Wms{wms})
+        return err
+      },
+    }, nil
+
+  case AvatarEntityWarehouse:
+    warehouses, err := as.warehouseRepo.GetByIDs(ctx, tx, []uuid.UUID{entityID})
+    if err != nil {
+      return nil, fmt.Errorf("failed to load warehouse: %w", err)
+    }
+    if len(warehouses) == 0 {
+      return nil, fmt.Errorf("warehouse not found")
+    }
+    warehouse := warehouses[0]
+    if _, err := as.loadOwnedCompany(ctx, tx, rd, warehouse.CompanyID); err != nil {
+      return nil, notYours
+    }
+    return &avatarTarget{
+      keyPrefix: "warehouse_avatars/",
+      companyID: warehouse.CompanyID,
+      generate:  func() (bytes.Buffer, error) { return as.GenerateWarehouseAvatar(ctx, tx, warehouse) },
+      svg:       func(theme AvatarTheme) ([]byte, error) { return as.warehouseAvatarSVG(ctx, tx, warehouse, theme) },
+      apply:     func(key, url string) { warehouse.AvatarBucketKey, warehouse.AvatarURL = key, url },
+      save: func() error {
+        _, err := as.warehouseRepo.Update(ctx, tx, []*types.Warehouse{warehouse})
+        return err
+      },
+    }, nil
+
+  case AvatarEntityRole:
+    roles, err := as.roleRepo.GetByIDs(ctx, tx, []uuid.UUID{entityID})
+    if err != nil {
+      return nil, fmt.Errorf("failed to load role: %w", err)
+    }
+    if len(roles) == 0 {
+      return nil, fmt.Errorf("role not found")
+    }
+    role := roles[0]
+    var wmsID, companyID uuid.UUID
+    switch {
+    case rd.UserType == "wms" && role.WmsID != nil && *role.WmsID == rd.WmsID:
+      wmsID = rd.WmsID
+    case rd.UserType == "company" && role.CompanyID != nil && *role.CompanyID == rd.CompanyID:
+      companyID = rd.CompanyID
+    default:
+      return nil, notYours
+    }
+    return &avatarTarget{
+      keyPrefix: "role_avatar/",
+      wmsID:     wmsID,
+      companyID: companyID,
+      generate:  func() (bytes.Buffer, error) { return as.GenerateRoleAvatar(ctx, tx, role) },
+      svg:       func(theme AvatarTheme) ([]byte, error) { return as.roleAvatarSVG(role) },
+      apply:     func(key, url string) { role.AvatarBucketKey, role.AvatarURL = key, url },
+      save: func() error {
+        _, err := as.roleRepo.Update(ctx, tx, []*types.Role{role})
+        return err
+      },
+    }, nil
+  }
+  return nil, fmt.Errorf("invalid avatar entity type: %s", entityType)
+}
+
+// loadOwnedCompany returns the company if the requester is one of its users
+// or belongs to the wms that manages it.
+func (as *avatarService) loadOwnedCompany(ctx context.Context, tx *gorm.DB, rd *requestdata.RequestData, companyID uuid.UUID) (*types.Company, error) {
+  companies, err := as.companyRepo.GetByIDs(ctx, tx, []uuid.UUID{companyID})
+  if err != nil {
+    return nil, fmt.Errorf("failed to load company: %w", err)
+  }
+  if len(companies) == 0 {
+    return nil, fmt.Errorf("company not found")
+  }
+  company := companies[0]
+  switch rd.UserType {
+  case "company":
+    if company.ID == rd.CompanyID {
+      return company, nil
+    }
+  case "wms":
+    if company.WmsID != nil && *company.WmsID == rd.WmsID {
+      return company, nil
+    }
+  }
+  return nil, fmt.Errorf("company does not belong to your organization")
+}
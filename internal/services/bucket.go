@@ -4,10 +4,15 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"mime"
+	"net/http"
 	"os"
+	"path"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
 	"google.golang.org/api/option"
 	"gorm.io/gorm"
 
@@ -20,17 +25,151 @@ type BucketService interface {
 	ReplaceFile(ctx context.Context, tx *gorm.DB, key string, newFile io.Reader) error
 
 	GetPublicURL(key string) string
+	// GetSignedURL returns a URL that allows method on key until ttl elapses,
+	// without any other credentials.
+	GetSignedURL(key string, ttl time.Duration, method string) (string, error)
 }
 
-type bucketService struct {
+// PrivateKeyPrefix marks objects that are never served publicly. They are
+// laid out as private/<wms|company>/<ownerID>/<name> and can only be reached
+// through a signed URL.
+const PrivateKeyPrefix = "private/"
+
+// Read urls (GET, HEAD) and write urls (PUT, DELETE) have their own default
+// and longest expiry. Write urls are kept short since anyone holding one can
+// replace or remove the object.
+const (
+	DefaultSignedURLTTL		= 15 * time.Minute
+	MaxSignedURLTTL			= time.Hour
+	DefaultSignedWriteURLTTL	= 5 * time.Minute
+	MaxSignedWriteURLTTL		= 15 * time.Minute
+)
+
+var signedURLMethods = map[string]bool{
+	http.MethodGet:		true,
+	http.MethodHead:	true,
+	http.MethodPut:		true,
+	http.MethodDelete:	true,
+}
+
+// PrivateObjectKey builds the key of a private object owned by a wms or company.
+func PrivateObjectKey(ownerType string, ownerID uuid.UUID, name string) string {
+	return fmt.Sprintf("%s%s/%s/%s", PrivateKeyPrefix, ownerType, ownerID.String(), strings.TrimLeft(name, "/"))
+}
+
+// ParsePrivateObjectKey returns the owner of a key built by PrivateObjectKey.
+func ParsePrivateObjectKey(key string) (string, uuid.UUID, error) {
+	if !IsPrivateKey(key) {
+		return "", uuid.Nil, fmt.Errorf("storage key %q is not a private object", key)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(key, PrivateKeyPrefix), "/", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		return "", uuid.Nil, fmt.Errorf("malformed private storage key %q", key)
+	}
+	if parts[0] != "wms" && parts[0] != "company" {
+		return "", uuid.Nil, fmt.Errorf("unknown owner type %q in storage key", parts[0])
+	}
+	ownerID, err := uuid.Parse(parts[1])
+	if err != nil {
+		return "", uuid.Nil, fmt.Errorf("invalid owner id in storage key %q", key)
+	}
+	return parts[0], ownerID, nil
+}
+
+func IsPrivateKey(key string) bool {
+	return strings.HasPrefix(strings.TrimLeft(key, "/"), PrivateKeyPrefix)
+}
+
+// checkSignedURLRequest validates the arguments shared by every backend's
+// GetSignedURL and returns the upper-cased method and effective ttl.
+func checkSignedURLRequest(key string, ttl time.Duration, method string) (string, time.Duration, error) {
+	if strings.TrimSpace(key) == "" {
+		return "", 0, fmt.Errorf("storage key is required")
+	}
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if method == "" {
+		method = http.MethodGet
+	}
+	if !signedURLMethods[method] {
+		return "", 0, fmt.Errorf("unsupported signed url method %q", method)
+	}
+	defaultTTL, maxTTL := DefaultSignedURLTTL, MaxSignedURLTTL
+	if IsSignedURLWrite(method) {
+		defaultTTL, maxTTL = DefaultSignedWriteURLTTL, MaxSignedWriteURLTTL
+	}
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	if ttl > maxTTL {
+		return "", 0, fmt.Errorf("signed %s url ttl %s exceeds the maximum of %s", method, ttl, maxTTL)
+	}
+	return method, ttl, nil
+}
+
+// IsSignedURLWrite reports whether a url signed for method can change the
+// object.
+func IsSignedURLWrite(method string) bool {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	return method == http.MethodPut || method == http.MethodDelete
+}
+
+const (
+	StorageBackendGCS	= "gcs"
+	StorageBackendS3	= "s3"
+	StorageBackendLocal	= "local"
+)
+
+// MinLocalStorageSigningKeyLen is the shortest LOCAL_STORAGE_SIGNING_KEY
+// accepted, so signed urls cannot be forged by guessing the key.
+const MinLocalStorageSigningKeyLen = 32
+
+// NewBucketService picks the backend named by STORAGE_BACKEND. When it is unset
+// GCS is used if GCS_BUCKET_NAME is present; with neither set it fails, so a
+// deployment missing its bucket config never quietly writes to local disk.
+// The local backend is opt-in with STORAGE_BACKEND=local.
+func NewBucketService(log *logger.Logger) (BucketService, error) {
+	serviceLog := log.With("service", "BucketService")
+	backend := strings.ToLower(strings.TrimSpace(os.Getenv("STORAGE_BACKEND")))
+	if backend == "" {
+		if os.Getenv("GCS_BUCKET_NAME") == "" {
+			return nil, fmt.Errorf("STORAGE_BACKEND is not set and GCS_BUCKET_NAME is missing; set STORAGE_BACKEND=local to store files on disk")
+		}
+		backend = StorageBackendGCS
+	}
+	serviceLog.Info("Using storage backend", "backend", backend)
+	switch backend {
+	case StorageBackendGCS:
+		return newGCSBucketService(serviceLog)
+	case StorageBackendS3:
+		return newS3BucketService(serviceLog)
+	case StorageBackendLocal:
+		dir := os.Getenv("LOCAL_STORAGE_DIR")
+		if dir == "" {
+			dir = "./storage"
+		}
+		baseURL := os.Getenv("LOCAL_STORAGE_BASE_URL")
+		if baseURL == "" {
+			baseURL = LocalStorageRoute
+		}
+		return NewLocalBucketService(serviceLog, dir, baseURL, []byte(os.Getenv("LOCAL_STORAGE_SIGNING_KEY")))
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q (expected gcs, s3 or local)", backend)
+	}
+}
+
+//-----------------------------------------
+// Google Cloud Storage
+//-----------------------------------------
+
+type gcsBucketService struct {
 	log            *logger.Logger
 	storageClient  *storage.Client
 	bucketName     string
 	cdnDomain      string
 }
 
-func NewBucketService(log *logger.Logger) (BucketService, error) {
-	serviceLog := log.With("service", "BucketService")
+func newGCSBucketService(serviceLog *logger.Logger) (BucketService, error) {
+	serviceLog = serviceLog.With("backend", StorageBackendGCS)
 
 	bucket := os.Getenv("GCS_BUCKET_NAME")
 	if bucket == "" {
@@ -54,7 +193,7 @@ func NewBucketService(log *logger.Logger) (BucketService, error) {
 		return nil, fmt.Errorf("Failed to create storage client: %w", err)
 	}
 
-	return &bucketService{
+	return &gcsBucketService{
 		log:           serviceLog,
 		storageClient: stClient,
 		bucketName:    bucket,
@@ -62,11 +201,15 @@ func NewBucketService(log *logger.Logger) (BucketService, error) {
 	}, nil
 }
 
-func (bs *bucketService) UploadFile(ctx context.Context, tx *gorm.DB, key string, file io.Reader) error {
+func (bs *gcsBucketService) UploadFile(ctx context.Context, tx *gorm.DB, key string, file io.Reader) error {
 	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
 	defer cancel()
 
 	w := bs.storageClient.Bucket(bs.bucketName).Object(key).NewWriter(ctx)
+	// Without an explicit type GCS sniffs the content, which serves SVG as text/xml.
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		w.ContentType = contentType
+	}
 	if _, err := io.Copy(w, file); err != nil {
 		_ = w.Close()
 		return fmt.Errorf("failed to write data to GCS: %w", err)
@@ -77,7 +220,7 @@ func (bs *bucketService) UploadFile(ctx context.Context, tx *gorm.DB, key string
 	return nil
 }
 
-func (bs *bucketService) DeleteFile(ctx context.Context, tx *gorm.DB, key string) error {
+func (bs *gcsBucketService) DeleteFile(ctx context.Context, tx *gorm.DB, key string) error {
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
@@ -88,7 +231,7 @@ func (bs *bucketService) DeleteFile(ctx context.Context, tx *gorm.DB, key string
 	return nil
 }
 
-func (bs *bucketService) ReplaceFile(ctx context.Context, tx *gorm.DB, key string, newFile io.Reader) error {
+func (bs *gcsBucketService) ReplaceFile(ctx context.Context, tx *gorm.DB, key string, newFile io.Reader) error {
 	if err := bs.DeleteFile(ctx, tx, key); err != nil {
 		return fmt.Errorf("failed deleting old file: %w", err)
 	}
@@ -98,10 +241,27 @@ func (bs *bucketService) ReplaceFile(ctx context.Context, tx *gorm.DB, key strin
 	return nil
 }
 
-func (bs *bucketService) GetPublicURL(key string) string {
+func (bs *gcsBucketService) GetPublicURL(key string) string {
 	if bs.cdnDomain != "" {
 		return fmt.Sprintf("https://%s/%s", bs.cdnDomain, key)
 	}
 	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", bs.bucketName, key)
 }
 
+// GetSignedURL needs credentials that can sign: a service account key file,
+// or ADC with the iam.serviceAccounts.signBlob permission.
+func (bs *gcsBucketService) GetSignedURL(key string, ttl time.Duration, method string) (string, error) {
+	method, ttl, err := checkSignedURLRequest(key, ttl, method)
+	if err != nil {
+		return "", err
+	}
+	signed, err := bs.storageClient.Bucket(bs.bucketName).SignedURL(key, &storage.SignedURLOptions{
+		Scheme:		storage.SigningSchemeV4,
+		Method:		method,
+		Expires:	time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GCS url for %q: %w", key, err)
+	}
+	return signed, nil
+}
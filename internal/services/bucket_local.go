@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/slotter-org/slotter-backend/internal/logger"
+)
+
+// LocalStorageRoute is where the router serves local-disk files by default.
+const LocalStorageRoute = "/files"
+
+// LocalStorage is implemented by backends whose files the API serves itself.
+type LocalStorage interface {
+	BucketService
+	RootDir() string
+	// LocalPath maps key to its file on disk.
+	LocalPath(key string) (string, error)
+	// VerifySignedURL checks the signature query parameters minted by
+	// GetSignedURL for a request of method on key.
+	VerifySignedURL(key string, method string, query url.Values) error
+}
+
+type localBucketService struct {
+	log		*logger.Logger
+	rootDir		string
+	baseURL		string
+	signingKey	[]byte
+}
+
+// NewLocalBucketService stores objects under rootDir and builds public URLs as
+// baseURL + "/" + key. baseURL may be a path ("/files") or an absolute URL.
+// Signed URLs are HMAC-SHA256 signed with signingKey, which must be at least
+// MinLocalStorageSigningKeyLen bytes and the same on every instance.
+func NewLocalBucketService(log *logger.Logger, rootDir, baseURL string, signingKey []byte) (BucketService, error) {
+	if len(signingKey) < MinLocalStorageSigningKeyLen {
+		return nil, fmt.Errorf("LOCAL_STORAGE_SIGNING_KEY must be set to at least %d bytes", MinLocalStorageSigningKeyLen)
+	}
+	absRoot, err := filepath.Abs(rootDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid local storage dir %q: %w", rootDir, err)
+	}
+	if err := os.MkdirAll(absRoot, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create local storage dir %q: %w", absRoot, err)
+	}
+	log.Info("Storing files on local disk", "dir", absRoot, "baseURL", baseURL)
+	return &localBucketService{
+		log:		log.With("backend", StorageBackendLocal),
+		rootDir:	absRoot,
+		baseURL:	strings.TrimRight(baseURL, "/"),
+		signingKey:	signingKey,
+	}, nil
+}
+
+func (bs *localBucketService) RootDir() string {
+	return bs.rootDir
+}
+
+func (bs *localBucketService) LocalPath(key string) (string, error) {
+	return bs.pathFor(key)
+}
+
+// pathFor maps key to a file under rootDir, refusing keys that would escape it.
+func (bs *localBucketService) pathFor(key string) (string, error) {
+	clean := path.Clean("/" + strings.TrimSpace(key))
+	if clean == "/" || strings.Contains(key, "\\") || strings.Contains("/"+key+"/", "/../") {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	full := filepath.Join(bs.rootDir, filepath.FromSlash(clean))
+	if !strings.HasPrefix(full, bs.rootDir+string(os.PathSeparator)) {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return full, nil
+}
+
+func (bs *localBucketService) UploadFile(ctx context.Context, tx *gorm.DB, key string, file io.Reader) error {
+	full, err := bs.pathFor(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %q: %w", key, err)
+	}
+	// Write to a temp file and rename so readers never see a partial file.
+	tmp, err := os.CreateTemp(filepath.Dir(full), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for %q: %w", key, err)
+	}
+	if _, err := io.Copy(tmp, file); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write data to local storage: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to close local storage file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to set permissions on %q: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), full); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to move file into place for %q: %w", key, err)
+	}
+	return nil
+}
+
+func (bs *localBucketService) DeleteFile(ctx context.Context, tx *gorm.DB, key string) error {
+	full, err := bs.pathFor(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(full); err != nil {
+		return fmt.Errorf("failed to delete local object %q: %w", key, err)
+	}
+	return nil
+}
+
+func (bs *localBucketService) ReplaceFile(ctx context.Context, tx *gorm.DB, key string, newFile io.Reader) error {
+	if err := bs.UploadFile(ctx, tx, key, newFile); err != nil {
+		return fmt.Errorf("failed uploading new file: %w", err)
+	}
+	return nil
+}
+
+func (bs *localBucketService) GetPublicURL(key string) string {
+	return bs.baseURL + "/" + strings.TrimLeft(key, "/")
+}
+
+func (bs *localBucketService) GetSignedURL(key string, ttl time.Duration, method string) (string, error) {
+	method, ttl, err := checkSignedURLRequest(key, ttl, method)
+	if err != nil {
+		return "", err
+	}
+	if _, err := bs.pathFor(key); err != nil {
+		return "", err
+	}
+	key = strings.TrimLeft(key, "/")
+	expires := time.Now().Add(ttl).Unix()
+	q := url.Values{}
+	q.Set("method", method)
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("signature", bs.sign(key, method, expires))
+	return bs.baseURL + "/" + (&url.URL{Path: key}).EscapedPath() + "?" + q.Encode(), nil
+}
+
+func (bs *localBucketService) VerifySignedURL(key string, method string, query url.Values) error {
+	key = strings.TrimLeft(key, "/")
+	signedMethod := strings.ToUpper(query.Get("method"))
+	method = strings.ToUpper(method)
+	// A URL signed for GET may also be used for HEAD, as with GCS and S3.
+	if signedMethod != method && !(signedMethod == http.MethodGet && method == http.MethodHead) {
+		return fmt.Errorf("signed url does not allow %s", method)
+	}
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("signed url has an invalid expiry")
+	}
+	if time.Now().Unix() > expires {
+		return fmt.Errorf("signed url has expired")
+	}
+	got, err := hex.DecodeString(query.Get("signature"))
+	if err != nil {
+		return fmt.Errorf("signed url has an invalid signature")
+	}
+	want, _ := hex.DecodeString(bs.sign(key, signedMethod, expires))
+	if !hmac.Equal(got, want) {
+		return fmt.Errorf("signed url has an invalid signature")
+	}
+	return nil
+}
+
+func (bs *localBucketService) sign(key, method string, expires int64) string {
+	mac := hmac.New(sha256.New, bs.signingKey)
+	fmt.Fprintf(mac, "%s\n%s\n%d", method, key, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
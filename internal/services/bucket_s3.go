@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"gorm.io/gorm"
+
+	"github.com/slotter-org/slotter-backend/internal/logger"
+)
+
+// s3BucketService talks to any S3-compatible store (AWS S3, MinIO, R2...).
+type s3BucketService struct {
+	log		*logger.Logger
+	client		*minio.Client
+	bucketName	string
+	publicBaseURL	string
+}
+
+func newS3BucketService(serviceLog *logger.Logger) (BucketService, error) {
+	serviceLog = serviceLog.With("backend", StorageBackendS3)
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "s3.amazonaws.com"
+	}
+	bucket := os.Getenv("S3_BUCKET")
+	if bucket == "" {
+		return nil, fmt.Errorf("missing env var S3_BUCKET")
+	}
+	useSSL := strings.ToLower(os.Getenv("S3_USE_SSL")) != "false"
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:	credentials.NewStaticV4(os.Getenv("S3_ACCESS_KEY_ID"), os.Getenv("S3_SECRET_ACCESS_KEY"), ""),
+		Secure:	useSSL,
+		Region:	os.Getenv("S3_REGION"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create s3 client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	exists, err := client.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check s3 bucket %q: %w", bucket, err)
+	}
+	if !exists {
+		if strings.ToLower(os.Getenv("S3_CREATE_BUCKET")) != "true" {
+			return nil, fmt.Errorf("s3 bucket %q does not exist (set S3_CREATE_BUCKET=true to create it)", bucket)
+		}
+		if err := client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: os.Getenv("S3_REGION")}); err != nil {
+			return nil, fmt.Errorf("failed to create s3 bucket %q: %w", bucket, err)
+		}
+		serviceLog.Info("Created s3 bucket", "bucket", bucket)
+	}
+
+	publicBaseURL := strings.TrimRight(os.Getenv("S3_PUBLIC_URL"), "/")
+	if publicBaseURL == "" {
+		scheme := "https"
+		if !useSSL {
+			scheme = "http"
+		}
+		publicBaseURL = fmt.Sprintf("%s://%s/%s", scheme, endpoint, bucket)
+	}
+	return &s3BucketService{
+		log:		serviceLog,
+		client:		client,
+		bucketName:	bucket,
+		publicBaseURL:	publicBaseURL,
+	}, nil
+}
+
+func (bs *s3BucketService) UploadFile(ctx context.Context, tx *gorm.DB, key string, file io.Reader) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if _, err := bs.client.PutObject(ctx, bs.bucketName, key, file, -1, minio.PutObjectOptions{ContentType: contentType}); err != nil {
+		return fmt.Errorf("failed to write data to s3: %w", err)
+	}
+	return nil
+}
+
+func (bs *s3BucketService) DeleteFile(ctx context.Context, tx *gorm.DB, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := bs.client.RemoveObject(ctx, bs.bucketName, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete s3 object %q: %w", key, err)
+	}
+	return nil
+}
+
+// ReplaceFile overwrites in place; S3 PUTs are atomic per key.
+func (bs *s3BucketService) ReplaceFile(ctx context.Context, tx *gorm.DB, key string, newFile io.Reader) error {
+	if err := bs.UploadFile(ctx, tx, key, newFile); err != nil {
+		return fmt.Errorf("failed uploading new file: %w", err)
+	}
+	return nil
+}
+
+func (bs *s3BucketService) GetPublicURL(key string) string {
+	return bs.publicBaseURL + "/" + (&url.URL{Path: strings.TrimLeft(key, "/")}).EscapedPath()
+}
+
+func (bs *s3BucketService) GetSignedURL(key string, ttl time.Duration, method string) (string, error) {
+	method, ttl, err := checkSignedURLRequest(key, ttl, method)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	signed, err := bs.client.Presign(ctx, method, bs.bucketName, key, ttl, url.Values{})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign s3 url for %q: %w", key, err)
+	}
+	return signed.String(), nil
+}
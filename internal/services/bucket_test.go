@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/slotter-org/slotter-backend/internal/requestdata"
+)
+
+const testSigningKey = "0123456789abcdef0123456789abcdef"
+
+func newTestLocalStorage(t *testing.T) LocalStorage {
+	t.Helper()
+	bs, err := NewLocalBucketService(testLogger(), t.TempDir(), LocalStorageRoute, []byte(testSigningKey))
+	if err != nil {
+		t.Fatalf("NewLocalBucketService: %v", err)
+	}
+	return bs.(LocalStorage)
+}
+
+func TestNewBucketServiceConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		backend    string
+		gcsBucket  string
+		signingKey string
+		wantErr    bool
+	}{
+		{name: "nothing configured", wantErr: true},
+		{name: "local without signing key", backend: StorageBackendLocal, wantErr: true},
+		{name: "local with short signing key", backend: StorageBackendLocal, signingKey: "short", wantErr: true},
+		{name: "local with signing key", backend: StorageBackendLocal, signingKey: testSigningKey},
+		{name: "unknown backend", backend: "ftp", signingKey: testSigningKey, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("STORAGE_BACKEND", tt.backend)
+			t.Setenv("GCS_BUCKET_NAME", tt.gcsBucket)
+			t.Setenv("LOCAL_STORAGE_SIGNING_KEY", tt.signingKey)
+			t.Setenv("LOCAL_STORAGE_DIR", t.TempDir())
+			_, err := NewBucketService(testLogger())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLocalBucketServiceRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	bs := newTestLocalStorage(t)
+	key := PrivateObjectKey("company", uuid.New(), "reports/layout.csv")
+
+	if err := bs.UploadFile(ctx, nil, key, strings.NewReader("a,b\n1,2\n")); err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+	full, err := bs.LocalPath(key)
+	if err != nil {
+		t.Fatalf("LocalPath: %v", err)
+	}
+	if !strings.HasPrefix(full, bs.RootDir()+string(os.PathSeparator)) {
+		t.Fatalf("LocalPath %q is outside %q", full, bs.RootDir())
+	}
+	if got, _ := os.ReadFile(full); string(got) != "a,b\n1,2\n" {
+		t.Fatalf("stored content = %q", got)
+	}
+
+	if err := bs.ReplaceFile(ctx, nil, key, strings.NewReader("c,d\n")); err != nil {
+		t.Fatalf("ReplaceFile: %v", err)
+	}
+	if got, _ := os.ReadFile(full); string(got) != "c,d\n" {
+		t.Fatalf("replaced content = %q", got)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(full), ".upload-*")); len(leftovers) != 0 {
+		t.Fatalf("temp files left behind: %v", leftovers)
+	}
+
+	if err := bs.DeleteFile(ctx, nil, key); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	if err := bs.DeleteFile(ctx, nil, key); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("second DeleteFile: err = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestLocalBucketServiceRejectsEscapingKeys(t *testing.T) {
+	bs := newTestLocalStorage(t)
+	for _, key := range []string{"", "/", "../secret", "a/../../secret", `a\..\secret`} {
+		if err := bs.UploadFile(context.Background(), nil, key, strings.NewReader("x")); err == nil {
+			t.Errorf("UploadFile(%q) succeeded, want an error", key)
+		}
+		if _, err := bs.GetSignedURL(key, time.Minute, http.MethodGet); err == nil {
+			t.Errorf("GetSignedURL(%q) succeeded, want an error", key)
+		}
+	}
+}
+
+func TestLocalBucketServiceSignedURLs(t *testing.T) {
+	bs := newTestLocalStorage(t)
+	key := PrivateObjectKey("wms", uuid.New(), "label.pdf")
+	signedQuery := func(t *testing.T, method string, ttl time.Duration) url.Values {
+		t.Helper()
+		signed, err := bs.GetSignedURL(key, ttl, method)
+		if err != nil {
+			t.Fatalf("GetSignedURL: %v", err)
+		}
+		u, err := url.Parse(signed)
+		if err != nil {
+			t.Fatalf("signed url does not parse: %v", err)
+		}
+		if u.Path != LocalStorageRoute+"/"+key {
+			t.Fatalf("signed url path = %q, want %q", u.Path, LocalStorageRoute+"/"+key)
+		}
+		return u.Query()
+	}
+	other, err := NewLocalBucketService(testLogger(), t.TempDir(), LocalStorageRoute, []byte(strings.Repeat("z", 32)))
+	if err != nil {
+		t.Fatalf("NewLocalBucketService: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		verifier LocalStorage
+		query    func(t *testing.T) url.Values
+		method   string
+		wantErr  bool
+	}{
+		{name: "get", verifier: bs, query: func(t *testing.T) url.Values { return signedQuery(t, http.MethodGet, time.Minute) }, method: http.MethodGet},
+		{name: "head with get url", verifier: bs, query: func(t *testing.T) url.Values { return signedQuery(t, http.MethodGet, time.Minute) }, method: http.MethodHead},
+		{name: "put with get url", verifier: bs, query: func(t *testing.T) url.Values { return signedQuery(t, http.MethodGet, time.Minute) }, method: http.MethodPut, wantErr: true},
+		{name: "put", verifier: bs, query: func(t *testing.T) url.Values { return signedQuery(t, http.MethodPut, time.Minute) }, method: http.MethodPut},
+		{name: "expired", verifier: bs, query: func(t *testing.T) url.Values {
+			q := signedQuery(t, http.MethodGet, time.Minute)
+			q.Set("expires", "1")
+			return q
+		}, method: http.MethodGet, wantErr: true},
+		{name: "tampered method", verifier: bs, query: func(t *testing.T) url.Values {
+			q := signedQuery(t, http.MethodGet, time.Minute)
+			q.Set("method", http.MethodDelete)
+			return q
+		}, method: http.MethodDelete, wantErr: true},
+		{name: "other signing key", verifier: other.(LocalStorage), query: func(t *testing.T) url.Values { return signedQuery(t, http.MethodGet, time.Minute) }, method: http.MethodGet, wantErr: true},
+		{name: "unsigned", verifier: bs, query: func(t *testing.T) url.Values { return url.Values{} }, method: http.MethodGet, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.verifier.VerifySignedURL(key, tt.method, tt.query(t))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifySignedURL: err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckSignedURLRequestTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		ttl     time.Duration
+		wantTTL time.Duration
+		wantErr bool
+	}{
+		{name: "read default", method: http.MethodGet, wantTTL: DefaultSignedURLTTL},
+		{name: "read at cap", method: http.MethodHead, ttl: MaxSignedURLTTL, wantTTL: MaxSignedURLTTL},
+		{name: "read over cap", method: http.MethodGet, ttl: MaxSignedURLTTL + time.Second, wantErr: true},
+		{name: "write default", method: http.MethodPut, wantTTL: DefaultSignedWriteURLTTL},
+		{name: "write at cap", method: "delete", ttl: MaxSignedWriteURLTTL, wantTTL: MaxSignedWriteURLTTL},
+		{name: "write over cap", method: http.MethodPut, ttl: MaxSignedWriteURLTTL + time.Second, wantErr: true},
+		{name: "week-long write", method: http.MethodDelete, ttl: 7 * 24 * time.Hour, wantErr: true},
+		{name: "unsupported method", method: http.MethodPost, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ttl, err := checkSignedURLRequest("private/wms/x/y", tt.ttl, tt.method)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && ttl != tt.wantTTL {
+				t.Fatalf("ttl = %s, want %s", ttl, tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestFileAccessServiceSplitsReadAndWriteURLs(t *testing.T) {
+	fs := NewFileAccessService(nil, testLogger(), nil, newTestLocalStorage(t))
+	wmsID := uuid.New()
+	ctx := requestdata.WithRequestData(context.Background(), &requestdata.RequestData{UserType: "wms", UserID: uuid.New(), WmsID: wmsID})
+	key := PrivateObjectKey("wms", wmsID, "label.pdf")
+
+	tests := []struct {
+		name    string
+		sign    func(ctx context.Context, tx *gorm.DB, key string, method string, ttl time.Duration) (*SignedURL, error)
+		method  string
+		wantErr bool
+	}{
+		{name: "read url for get", sign: fs.SignURL, method: http.MethodGet},
+		{name: "read url for put", sign: fs.SignURL, method: http.MethodPut, wantErr: true},
+		{name: "read url for delete", sign: fs.SignURL, method: http.MethodDelete, wantErr: true},
+		{name: "write url for put", sign: fs.SignWriteURL, method: http.MethodPut},
+		{name: "write url for delete", sign: fs.SignWriteURL, method: http.MethodDelete},
+		{name: "write url for get", sign: fs.SignWriteURL, method: http.MethodGet, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := tt.sign(ctx, nil, key, tt.method, 0)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && signed.Method != tt.method {
+				t.Fatalf("method = %s, want %s", signed.Method, tt.method)
+			}
+		})
+	}
+	other := PrivateObjectKey("wms", uuid.New(), "label.pdf")
+	if _, err := fs.SignWriteURL(ctx, nil, other, http.MethodPut, 0); err == nil {
+		t.Fatal("signed a write url for another wms's object")
+	}
+}
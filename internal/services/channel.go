@@ -0,0 +1,121 @@
+package services
+
+import (
+  "context"
+  "fmt"
+
+  "github.com/google/uuid"
+
+  "github.com/slotter-org/slotter-backend/internal/channels"
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+)
+
+// ChannelService decides who may subscribe to which SSE and websocket
+// channel. Every channel kind in the channels package has a rule here that
+// mirrors the tenant checks of the matching REST endpoints.
+type ChannelService interface {
+  Authorize(ctx context.Context, channel string) error
+}
+
+type channelService struct {
+  log               *logger.Logger
+  companyRepo       repos.CompanyRepo
+  roleRepo          repos.RoleRepo
+  slottingRepo      repos.SlottingRepo
+  warehouseService  WarehouseService
+  registry          *channels.Registry
+}
+
+func NewChannelService(
+  log               *logger.Logger,
+  companyRepo       repos.CompanyRepo,
+  roleRepo          repos.RoleRepo,
+  slottingRepo      repos.SlottingRepo,
+  warehouseService  WarehouseService,
+) ChannelService {
+  cs := &channelService{
+    log:              log.With("service", "ChannelService"),
+    companyRepo:      companyRepo,
+    roleRepo:         roleRepo,
+    slottingRepo:     slottingRepo,
+    warehouseService: warehouseService,
+    registry:         channels.NewRegistry(),
+  }
+  cs.registry.Register(channels.KindUser, channels.OwnUser)
+  cs.registry.Register(channels.KindWms, channels.OwnWms)
+  cs.registry.Register(channels.KindCompany, cs.companyRule)
+  cs.registry.Register(channels.KindWarehouse, cs.warehouseRule)
+  cs.registry.Register(channels.KindRole, cs.roleRule)
+  cs.registry.Register(channels.KindSlottingJob, cs.slottingJobRule)
+  return cs
+}
+
+func (cs *channelService) Authorize(ctx context.Context, channel string) error {
+  if err := cs.registry.Authorize(ctx, channel); err != nil {
+    cs.log.Info("Channel subscription refused", "channel", channel, "error", err)
+    return err
+  }
+  return nil
+}
+
+// companyRule lets company users into their own company's channel and wms
+// users into the channels of companies under their wms.
+func (cs *channelService) companyRule(ctx context.Context, rd *requestdata.RequestData, companyID uuid.UUID) error {
+  switch rd.UserType {
+  case "company":
+    if rd.CompanyID == companyID {
+      return nil
+    }
+  case "wms":
+    companies, err := cs.companyRepo.GetByIDs(ctx, nil, []uuid.UUID{companyID})
+    if err != nil {
+      return fmt.Errorf("failed to load company: %w", err)
+    }
+    if len(companies) > 0 && companies[0].WmsID != nil && *companies[0].WmsID == rd.WmsID {
+      return nil
+    }
+  }
+  return channels.ErrForbidden
+}
+
+// warehouseRule follows WarehouseService.GetAuthorizedWarehouse. Unknown
+// warehouses are refused the same way, so the check reveals nothing.
+func (cs *channelService) warehouseRule(ctx context.Context, _ *requestdata.RequestData, warehouseID uuid.UUID) error {
+  if _, err := cs.warehouseService.GetAuthorizedWarehouse(ctx, nil, warehouseID); err != nil {
+    return fmt.Errorf("%w: %v", channels.ErrForbidden, err)
+  }
+  return nil
+}
+
+// roleRule allows roles of the requester's own company or wms.
+func (cs *channelService) roleRule(ctx context.Context, rd *requestdata.RequestData, roleID uuid.UUID) error {
+  roles, err := cs.roleRepo.GetByIDs(ctx, nil, []uuid.UUID{roleID})
+  if err != nil {
+    return fmt.Errorf("failed to load role: %w", err)
+  }
+  if len(roles) == 0 {
+    return channels.ErrForbidden
+  }
+  role := roles[0]
+  switch {
+  case rd.UserType == "company" && role.CompanyID != nil && *role.CompanyID == rd.CompanyID:
+    return nil
+  case rd.UserType == "wms" && role.WmsID != nil && *role.WmsID == rd.WmsID:
+    return nil
+  }
+  return channels.ErrForbidden
+}
+
+// slottingJobRule allows a job's channel to whoever may see its warehouse.
+func (cs *channelService) slottingJobRule(ctx context.Context, rd *requestdata.RequestData, jobID uuid.UUID) error {
+  jobs, err := cs.slottingRepo.GetJobsByIDs(ctx, nil, []uuid.UUID{jobID})
+  if err != nil {
+    return fmt.Errorf("failed to load slotting job: %w", err)
+  }
+  if len(jobs) == 0 {
+    return channels.ErrForbidden
+  }
+  return cs.warehouseRule(ctx, rd, jobs[0].WarehouseID)
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+  "context"
+  "errors"
+  "testing"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/channels"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type fakeRoleRepo struct {
+  repos.RoleRepo
+  roles         []*types.Role
+}
+
+func (f *fakeRoleRepo) GetByIDs(ctx context.Context, tx *gorm.DB, roleIDs []uuid.UUID) ([]*types.Role, error) {
+  var out []*types.Role
+  for _, r := range f.roles {
+    for _, id := range roleIDs {
+      if r.ID == id {
+        out = append(out, r)
+      }
+    }
+  }
+  return out, nil
+}
+
+type fakeSlottingRepo struct {
+  repos.SlottingRepo
+  jobs          []*types.SlottingJob
+}
+
+func (f *fakeSlottingRepo) GetJobsByIDs(ctx context.Context, tx *gorm.DB, jobIDs []uuid.UUID) ([]*types.SlottingJob, error) {
+  var out []*types.SlottingJob
+  for _, j := range f.jobs {
+    for _, id := range jobIDs {
+      if j.ID == id {
+        out = append(out, j)
+      }
+    }
+  }
+  return out, nil
+}
+
+// TestChannelServiceAuthorize runs every channel kind's rule for a viewer of
+// the wms, of a company under it and of an unrelated tenant.
+func TestChannelServiceAuthorize(t *testing.T) {
+  wmsID, otherWmsID := uuid.New(), uuid.New()
+  acme := &types.Company{ID: uuid.New(), Name: "Acme", WmsID: &wmsID}
+  globex := &types.Company{ID: uuid.New(), Name: "Globex", WmsID: &otherWmsID}
+  acmeDC := &types.Warehouse{ID: uuid.New(), CompanyID: acme.ID}
+  globexDC := &types.Warehouse{ID: uuid.New(), CompanyID: globex.ID}
+  wmsRole := &types.Role{ID: uuid.New(), WmsID: &wmsID}
+  acmeRole := &types.Role{ID: uuid.New(), CompanyID: &acme.ID}
+  acmeJob := &types.SlottingJob{ID: uuid.New(), WarehouseID: acmeDC.ID, CompanyID: acme.ID}
+
+  wmsViewer := &requestdata.RequestData{UserType: "wms", UserID: uuid.New(), WmsID: wmsID}
+  companyViewer := &requestdata.RequestData{UserType: "company", UserID: uuid.New(), CompanyID: acme.ID}
+  crossViewer := &requestdata.RequestData{UserType: "company", UserID: uuid.New(), CompanyID: globex.ID}
+  crossWmsViewer := &requestdata.RequestData{UserType: "wms", UserID: uuid.New(), WmsID: otherWmsID}
+
+  companyRepo := &fakeCompanyRepo{companies: []*types.Company{acme, globex}}
+  warehouseRepo := &fakeWarehouseRepo{warehouses: []*types.Warehouse{acmeDC, globexDC}}
+  cs := NewChannelService(
+    testLogger(),
+    companyRepo,
+    &fakeRoleRepo{roles: []*types.Role{wmsRole, acmeRole}},
+    &fakeSlottingRepo{jobs: []*types.SlottingJob{acmeJob}},
+    NewWarehouseService(nil, testLogger(), nil, nil, companyRepo, nil, nil, warehouseRepo),
+  )
+
+  tests := []struct {
+    name          string
+    rd            *requestdata.RequestData
+    channel       string
+    wantErr       error
+  }{
+    {name: "user: own", rd: companyViewer, channel: channels.User(companyViewer.UserID)},
+    {name: "user: someone else", rd: companyViewer, channel: channels.User(wmsViewer.UserID), wantErr: channels.ErrForbidden},
+
+    {name: "wms: wms viewer", rd: wmsViewer, channel: channels.Wms(wmsID)},
+    {name: "wms: company viewer", rd: companyViewer, channel: channels.Wms(wmsID), wantErr: channels.ErrForbidden},
+    {name: "wms: cross-tenant viewer", rd: crossWmsViewer, channel: channels.Wms(wmsID), wantErr: channels.ErrForbidden},
+
+    {name: "company: wms viewer", rd: wmsViewer, channel: channels.Company(acme.ID)},
+    {name: "company: company viewer", rd: companyViewer, channel: channels.Company(acme.ID)},
+    {name: "company: cross-tenant viewer", rd: crossViewer, channel: channels.Company(acme.ID), wantErr: channels.ErrForbidden},
+    {name: "company: wms viewer of another wms", rd: wmsViewer, channel: channels.Company(globex.ID), wantErr: channels.ErrForbidden},
+    {name: "company: unknown company", rd: wmsViewer, channel: channels.Company(uuid.New()), wantErr: channels.ErrForbidden},
+
+    {name: "warehouse: wms viewer", rd: wmsViewer, channel: channels.Warehouse(acmeDC.ID)},
+    {name: "warehouse: company viewer", rd: companyViewer, channel: channels.Warehouse(acmeDC.ID)},
+    {name: "warehouse: cross-tenant viewer", rd: crossViewer, channel: channels.Warehouse(acmeDC.ID), wantErr: channels.ErrForbidden},
+    {name: "warehouse: wms viewer of another wms", rd: wmsViewer, channel: channels.Warehouse(globexDC.ID), wantErr: channels.ErrForbidden},
+    {name: "warehouse: unknown warehouse", rd: companyViewer, channel: channels.Warehouse(uuid.New()), wantErr: channels.ErrForbidden},
+
+    {name: "role: wms viewer on wms role", rd: wmsViewer, channel: channels.Role(wmsRole.ID)},
+    {name: "role: wms viewer on company role", rd: wmsViewer, channel: channels.Role(acmeRole.ID), wantErr: channels.ErrForbidden},
+    {name: "role: company viewer", rd: companyViewer, channel: channels.Role(acmeRole.ID)},
+    {name: "role: cross-tenant viewer", rd: crossViewer, channel: channels.Role(acmeRole.ID), wantErr: channels.ErrForbidden},
+    {name: "role: unknown role", rd: companyViewer, channel: channels.Role(uuid.New()), wantErr: channels.ErrForbidden},
+
+    {name: "slotting job: wms viewer", rd: wmsViewer, channel: channels.SlottingJob(acmeJob.ID)},
+    {name: "slotting job: company viewer", rd: companyViewer, channel: channels.SlottingJob(acmeJob.ID)},
+    {name: "slotting job: cross-tenant viewer", rd: crossViewer, channel: channels.SlottingJob(acmeJob.ID), wantErr: channels.ErrForbidden},
+    {name: "slotting job: unknown job", rd: wmsViewer, channel: channels.SlottingJob(uuid.New()), wantErr: channels.ErrForbidden},
+
+    {name: "malformed channel name", rd: wmsViewer, channel: "company:" + acme.ID.String()[:8], wantErr: channels.ErrUnknownChannel},
+    {name: "unknown prefix", rd: wmsViewer, channel: "tenant:" + acme.ID.String(), wantErr: channels.ErrUnknownChannel},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      ctx := requestdata.WithRequestData(context.Background(), tt.rd)
+      if err := cs.Authorize(ctx, tt.channel); !errors.Is(err, tt.wantErr) {
+        t.Fatalf("Authorize(%s): err = %v, want %v", tt.channel, err, tt.wantErr)
+      }
+    })
+  }
+}
@@ -0,0 +1,690 @@
+package services
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "os"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/llm"
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+const (
+  DefaultChatPageSize     = 20
+  MaxChatPageSize         = 100
+  // MaxChatMessageLength bounds a user message, in characters.
+  MaxChatMessageLength    = 4000
+
+  // maxChatToolRounds bounds how often one reply may call tools; the last
+  // round is sent without tools so the model has to answer.
+  maxChatToolRounds       = 5
+  // chatHistoryWindow is how many stored messages are sent to the model.
+  chatHistoryWindow       = 40
+  chatTitleLength         = 60
+  maxChatSearchLength     = 200
+
+  chatSystemPrompt        = "You are the Slotter assistant. You help warehouse teams understand SKU velocity, inventory and slotting scenarios. " +
+                            "Use the tools to look up data instead of guessing, and call list_warehouses when you need a warehouseID. " +
+                            "The tools are read-only; you cannot change anything. Answer briefly and cite the numbers you used."
+)
+
+// Events sent while a reply streams. A message event carries every stored
+// message (the user's, each assistant turn and each tool result), token
+// events carry assistant text as it arrives, a session event carries the
+// session when its title was generated, and done ends the reply.
+const (
+  ChatEventMessage    = "message"
+  ChatEventToken      = "token"
+  ChatEventSession    = "session"
+  ChatEventDone       = "done"
+  ChatEventError      = "error"
+)
+
+var (
+  ErrChatSessionNotFound  = errors.New("chat session not found")
+  ErrChatSessionReadOnly  = errors.New("only the owner can change this chat session")
+  ErrChatSessionBusy      = errors.New("the assistant is still answering in this chat session")
+)
+
+type ChatStreamEvent struct {
+  Type        string    `json:"type"`
+  Data        any       `json:"data"`
+}
+
+// ChatSessionPage is one page of ListSessions.
+type ChatSessionPage struct {
+  Sessions        []*types.ChatSession      `json:"sessions"`
+  Total           int64                     `json:"total"`
+  Limit           int                       `json:"limit"`
+  Offset          int                       `json:"offset"`
+}
+
+// ChatSessionQuery selects sessions for ListSessions. Scope is mine (the
+// default), shared (other users' sessions the caller may read) or all.
+// Title matches part of the title.
+type ChatSessionQuery struct {
+  Scope           string
+  PinnedOnly      bool
+  Title           string
+  Limit           int
+  Offset          int
+}
+
+// ChatSessionDetail is a session with its messages in order. Owned is set
+// for the owner, who alone may post, edit and delete; SharedWith lists the
+// users a session with users visibility is shared with.
+type ChatSessionDetail struct {
+  Session         *types.ChatSession        `json:"session"`
+  Messages        []*types.ChatMessage      `json:"messages"`
+  Owned           bool                      `json:"owned"`
+  SharedWith      []uuid.UUID               `json:"sharedWith,omitempty"`
+}
+
+// ChatSessionUpdate changes the title, visibility or shares of a session;
+// nil fields are left alone. SharedWith replaces the whole share list.
+type ChatSessionUpdate struct {
+  Title           *string                   `json:"title"`
+  Visibility      *types.ChatVisibility     `json:"visibility"`
+  SharedWith      *[]uuid.UUID              `json:"sharedWith"`
+}
+
+// ChatSearchPage is one page of SearchMessages.
+type ChatSearchPage struct {
+  Hits            []*repos.ChatSearchHit    `json:"hits"`
+  Total           int64                     `json:"total"`
+  Limit           int                       `json:"limit"`
+  Offset          int                       `json:"offset"`
+}
+
+// ChatService runs users' conversations with the slotting assistant. Only
+// the owner of a session may post to, change or delete it; the session's
+// visibility decides who else in the owner's company or WMS may read it.
+type ChatService interface {
+  CreateSession(ctx context.Context, tx *gorm.DB, title string) (*types.ChatSession, error)
+  ListSessions(ctx context.Context, tx *gorm.DB, query ChatSessionQuery) (*ChatSessionPage, error)
+  GetSession(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID) (*ChatSessionDetail, error)
+  UpdateSession(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID, update ChatSessionUpdate) (*ChatSessionDetail, error)
+  PinSession(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID, pinned bool) error
+  DeleteSession(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID) error
+  PostMessage(ctx context.Context, sessionID uuid.UUID, content string, emit func(ChatStreamEvent) error) ([]*types.ChatMessage, error)
+  SearchMessages(ctx context.Context, tx *gorm.DB, query string, limit int, offset int) (*ChatSearchPage, error)
+  ExportSession(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID, format string) (*ChatExport, error)
+  GetRetentionPolicy(ctx context.Context, tx *gorm.DB) (*types.ChatRetentionPolicy, error)
+  SetRetentionPolicy(ctx context.Context, tx *gorm.DB, retentionDays int) (*types.ChatRetentionPolicy, error)
+  PurgeExpiredSessions(ctx context.Context) (int64, error)
+
+  updateSessionLogic(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID, update ChatSessionUpdate) (*ChatSessionDetail, error)
+  deleteSessionLogic(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID) error
+  setRetentionPolicyLogic(ctx context.Context, tx *gorm.DB, retentionDays int) (*types.ChatRetentionPolicy, error)
+}
+
+type chatService struct {
+  db                    *gorm.DB
+  log                   *logger.Logger
+  provider              llm.Provider
+  chatRepo              repos.ChatRepo
+  userRepo              repos.UserRepo
+  companyRepo           repos.CompanyRepo
+  warehouseRepo         repos.WarehouseRepo
+  velocityService       VelocityService
+  inventoryService      InventoryService
+  scenarioService       SlottingScenarioService
+
+  // busy holds the sessions a reply is being generated for.
+  busy                  sync.Map
+}
+
+func NewChatService(
+  db                    *gorm.DB,
+  log                   *logger.Logger,
+  provider              llm.Provider,
+  chatRepo              repos.ChatRepo,
+  userRepo              repos.UserRepo,
+  companyRepo           repos.CompanyRepo,
+  warehouseRepo         repos.WarehouseRepo,
+  velocityService       VelocityService,
+  inventoryService      InventoryService,
+  scenarioService       SlottingScenarioService,
+) ChatService {
+  serviceLog := log.With("service", "ChatService")
+  serviceLog.Info("Using LLM provider", "provider", provider.Name())
+  return &chatService{
+    db:               db,
+    log:              serviceLog,
+    provider:         provider,
+    chatRepo:         chatRepo,
+    userRepo:         userRepo,
+    companyRepo:      companyRepo,
+    warehouseRepo:    warehouseRepo,
+    velocityService:  velocityService,
+    inventoryService: inventoryService,
+    scenarioService:  scenarioService,
+  }
+}
+
+// NewLLMProvider picks the assistant's model from LLM_PROVIDER (anthropic or
+// stub). The anthropic provider needs ANTHROPIC_API_KEY and LLM_MODEL, and
+// honours ANTHROPIC_BASE_URL. Without configuration the stub is used.
+func NewLLMProvider(log *logger.Logger) (llm.Provider, error) {
+  provider := strings.ToLower(strings.TrimSpace(os.Getenv("LLM_PROVIDER")))
+  if provider == "" {
+    if os.Getenv("ANTHROPIC_API_KEY") != "" {
+      provider = llm.AnthropicName
+    } else {
+      log.Warn("LLM_PROVIDER and ANTHROPIC_API_KEY not set; the assistant will use the stub provider")
+      provider = llm.StubName
+    }
+  }
+  switch provider {
+  case llm.AnthropicName:
+    return llm.NewAnthropic(os.Getenv("ANTHROPIC_API_KEY"), os.Getenv("LLM_MODEL"), os.Getenv("ANTHROPIC_BASE_URL"))
+  case llm.StubName:
+    return llm.NewStub(), nil
+  default:
+    return nil, fmt.Errorf("unknown LLM_PROVIDER %q (expected anthropic or stub)", provider)
+  }
+}
+
+//----------------------------------------------------------------------------------------
+// Read
+//----------------------------------------------------------------------------------------
+
+func (cs *chatService) ListSessions(ctx context.Context, tx *gorm.DB, query ChatSessionQuery) (*ChatSessionPage, error) {
+  cs.log.Info("Starting ListSessions now...", "scope", query.Scope)
+  viewer, err := chatViewer(ctx)
+  if err != nil {
+    return nil, err
+  }
+  switch query.Scope {
+  case "":
+    query.Scope = repos.ChatScopeMine
+  case repos.ChatScopeMine, repos.ChatScopeShared, repos.ChatScopeAll:
+  default:
+    return nil, fmt.Errorf("invalid scope %q (expected mine, shared or all)", query.Scope)
+  }
+  query.Limit, query.Offset = clampPage(query.Limit, query.Offset, DefaultChatPageSize, MaxChatPageSize)
+  sessions, total, err := cs.chatRepo.SearchSessions(ctx, tx, repos.ChatSessionFilter{
+    Viewer:     viewer,
+    Scope:      query.Scope,
+    PinnedOnly: query.PinnedOnly,
+    Title:      strings.TrimSpace(query.Title),
+    Limit:      query.Limit,
+    Offset:     query.Offset,
+  })
+  if err != nil {
+    return nil, fmt.Errorf("failed to list chat sessions: %w", err)
+  }
+  if sessions == nil {
+    sessions = []*types.ChatSession{}
+  }
+  if err := cs.markPinned(ctx, tx, viewer.UserID, sessions); err != nil {
+    return nil, err
+  }
+  return &ChatSessionPage{Sessions: sessions, Total: total, Limit: query.Limit, Offset: query.Offset}, nil
+}
+
+func (cs *chatService) GetSession(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID) (*ChatSessionDetail, error) {
+  cs.log.Info("Starting GetSession now...", "sessionID", sessionID)
+  session, err := cs.getViewableSession(ctx, tx, sessionID)
+  if err != nil {
+    return nil, err
+  }
+  return cs.sessionDetail(ctx, tx, session)
+}
+
+// SearchMessages finds the user and assistant messages matching query in
+// every session the caller may read.
+func (cs *chatService) SearchMessages(ctx context.Context, tx *gorm.DB, query string, limit int, offset int) (*ChatSearchPage, error) {
+  cs.log.Info("Starting SearchMessages now...")
+  viewer, err := chatViewer(ctx)
+  if err != nil {
+    return nil, err
+  }
+  query = strings.TrimSpace(query)
+  if query == "" {
+    return nil, fmt.Errorf("search query is required")
+  }
+  if len([]rune(query)) > maxChatSearchLength {
+    return nil, fmt.Errorf("search query is longer than %d characters", maxChatSearchLength)
+  }
+  limit, offset = clampPage(limit, offset, DefaultChatPageSize, MaxChatPageSize)
+  hits, total, err := cs.chatRepo.SearchMessages(ctx, tx, viewer, query, limit, offset)
+  if err != nil {
+    return nil, fmt.Errorf("failed to search chat messages: %w", err)
+  }
+  return &ChatSearchPage{Hits: hits, Total: total, Limit: limit, Offset: offset}, nil
+}
+
+//----------------------------------------------------------------------------------------
+// Create
+//----------------------------------------------------------------------------------------
+
+func (cs *chatService) CreateSession(ctx context.Context, tx *gorm.DB, title string) (*types.ChatSession, error) {
+  cs.log.Info("Starting CreateSession now...")
+  rd := requestdata.GetRequestData(ctx)
+  if rd == nil {
+    return nil, fmt.Errorf("request data not set in context")
+  }
+  title = chatTitle(title)
+  session, err := cs.chatRepo.CreateSession(ctx, tx, &types.ChatSession{
+    UserID:     rd.UserID,
+    Title:      title,
+    TitleAuto:  title == "",
+    Visibility: types.ChatVisibilityPrivate,
+  })
+  if err != nil {
+    return nil, fmt.Errorf("failed to create chat session: %w", err)
+  }
+  return session, nil
+}
+
+// PostMessage adds the user's message to a session and generates the
+// assistant's reply, running tools as the model asks for them. emit gets
+// each stored message and every streamed token. Every message is stored as
+// soon as it is complete and no transaction is held open while the model
+// answers, so a failed reply keeps what was said so far. Only one reply per
+// session runs at a time.
+func (cs *chatService) PostMessage(ctx context.Context, sessionID uuid.UUID, content string, emit func(ChatStreamEvent) error) ([]*types.ChatMessage, error) {
+  cs.log.Info("Starting PostMessage now...", "sessionID", sessionID)
+  content = strings.TrimSpace(content)
+  if content == "" {
+    return nil, fmt.Errorf("message content is required")
+  }
+  if len([]rune(content)) > MaxChatMessageLength {
+    return nil, fmt.Errorf("message is longer than %d characters", MaxChatMessageLength)
+  }
+  if emit == nil {
+    emit = func(ChatStreamEvent) error { return nil }
+  }
+  session, err := cs.getOwnSession(ctx, nil, sessionID)
+  if err != nil {
+    return nil, err
+  }
+  if _, running := cs.busy.LoadOrStore(session.ID, struct{}{}); running {
+    return nil, ErrChatSessionBusy
+  }
+  defer cs.busy.Delete(session.ID)
+
+  history, err := cs.chatRepo.GetMessagesBySessionID(ctx, nil, session.ID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to load chat messages: %w", err)
+  }
+  rd := requestdata.GetRequestData(ctx)
+  next := 0
+  if n := len(history); n > 0 {
+    next = history[n-1].Sequence + 1
+  }
+  var added []*types.ChatMessage
+  save := func(msg *types.ChatMessage) error {
+    msg.SessionID = session.ID
+    msg.Sequence = next
+    if _, err := cs.chatRepo.CreateMessages(ctx, nil, []*types.ChatMessage{msg}); err != nil {
+      return fmt.Errorf("failed to save chat message: %w", err)
+    }
+    next++
+    history = append(history, msg)
+    added = append(added, msg)
+    return emit(ChatStreamEvent{Type: ChatEventMessage, Data: msg})
+  }
+
+  firstExchange := len(history) == 0
+  userID := rd.UserID
+  if err := save(&types.ChatMessage{UserID: &userID, Role: types.ChatRoleUser, Content: content}); err != nil {
+    return added, err
+  }
+  if session.Title == "" {
+    session.Title = chatTitle(content)
+  }
+  defer func() {
+    // Bump updated_at so the session sorts first, even when the reply failed.
+    session.UpdatedAt = time.Now()
+    if _, err := cs.chatRepo.UpdateSession(context.WithoutCancel(ctx), nil, session); err != nil {
+      cs.log.Warn("Failed to touch chat session", "sessionID", session.ID, "error", err)
+    }
+  }()
+
+  tools := cs.chatTools()
+  defs := make([]llm.Tool, 0, len(tools))
+  for _, t := range tools {
+    defs = append(defs, t.def)
+  }
+  for round := 0; ; round++ {
+    req := llm.Request{System: chatSystemPrompt, Messages: llmMessages(history)}
+    if round < maxChatToolRounds {
+      req.Tools = defs
+    }
+    resp, err := cs.provider.Stream(ctx, req, func(text string) error {
+      return emit(ChatStreamEvent{Type: ChatEventToken, Data: map[string]string{"text": text}})
+    })
+    if err != nil {
+      cs.log.Warn("Assistant reply failed", "sessionID", session.ID, "provider", cs.provider.Name(), "error", err)
+      return added, fmt.Errorf("assistant reply failed: %w", err)
+    }
+    reply := &types.ChatMessage{Role: types.ChatRoleAssistant, Content: resp.Content}
+    for _, call := range resp.ToolCalls {
+      reply.ToolCalls = append(reply.ToolCalls, types.ChatToolCall{ID: call.ID, Name: call.Name, Arguments: string(call.Arguments)})
+    }
+    if err := save(reply); err != nil {
+      return added, err
+    }
+    if len(resp.ToolCalls) == 0 {
+      break
+    }
+    for _, call := range resp.ToolCalls {
+      result, isError := cs.runTool(ctx, call)
+      if err := save(&types.ChatMessage{Role: types.ChatRoleTool, ToolCallID: call.ID, Content: result, IsError: isError}); err != nil {
+        return added, err
+      }
+    }
+  }
+  if firstExchange && session.TitleAuto {
+    // The first exchange names the session; the model may fail here
+    // without failing the reply, which keeps the title from the question.
+    if title, err := llm.Title(ctx, cs.provider, content, history[len(history)-1].Content); err != nil {
+      cs.log.Warn("Failed to generate chat session title", "sessionID", session.ID, "error", err)
+    } else {
+      session.Title = title
+    }
+    if err := emit(ChatStreamEvent{Type: ChatEventSession, Data: session}); err != nil {
+      return added, err
+    }
+  }
+  if err := emit(ChatStreamEvent{Type: ChatEventDone, Data: map[string]int{"messages": len(added)}}); err != nil {
+    return added, err
+  }
+  return added, nil
+}
+
+//----------------------------------------------------------------------------------------
+// Update
+//----------------------------------------------------------------------------------------
+
+// UpdateSession renames a session or changes who it is shared with. A title
+// set here is kept; sessions without one are named after their first
+// exchange.
+func (cs *chatService) UpdateSession(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID, update ChatSessionUpdate) (*ChatSessionDetail, error) {
+  cs.log.Info("Starting UpdateSession now...", "sessionID", sessionID)
+  var result *ChatSessionDetail
+  if tx == nil {
+    if err := cs.db.WithContext(ctx).Transaction(func(tx2 *gorm.DB) error {
+      var err error
+      result, err = cs.updateSessionLogic(ctx, tx2, sessionID, update)
+      return err
+    }); err != nil {
+      return nil, err
+    }
+    return result, nil
+  }
+  return cs.updateSessionLogic(ctx, tx, sessionID, update)
+}
+
+func (cs *chatService) updateSessionLogic(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID, update ChatSessionUpdate) (*ChatSessionDetail, error) {
+  session, err := cs.getOwnSession(ctx, tx, sessionID)
+  if err != nil {
+    return nil, err
+  }
+  if update.Title != nil {
+    title := chatTitle(*update.Title)
+    if title == "" {
+      return nil, fmt.Errorf("title cannot be empty")
+    }
+    session.Title = title
+    session.TitleAuto = false
+  }
+  if update.Visibility != nil {
+    switch *update.Visibility {
+    case types.ChatVisibilityPrivate, types.ChatVisibilityCompany, types.ChatVisibilityUsers:
+      session.Visibility = *update.Visibility
+    default:
+      return nil, fmt.Errorf("invalid visibility %q (expected private, company or users)", *update.Visibility)
+    }
+  }
+  if update.SharedWith != nil {
+    userIDs, err := cs.shareableUserIDs(ctx, tx, session, *update.SharedWith)
+    if err != nil {
+      return nil, err
+    }
+    if _, err := cs.chatRepo.ReplaceShares(ctx, tx, session.ID, userIDs); err != nil {
+      return nil, fmt.Errorf("failed to share chat session: %w", err)
+    }
+  }
+  if _, err := cs.chatRepo.UpdateSession(ctx, tx, session); err != nil {
+    return nil, fmt.Errorf("failed to update chat session: %w", err)
+  }
+  return cs.sessionDetail(ctx, tx, session)
+}
+
+// PinSession pins or unpins a session for the caller. Any session the
+// caller may read can be pinned.
+func (cs *chatService) PinSession(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID, pinned bool) error {
+  cs.log.Info("Starting PinSession now...", "sessionID", sessionID, "pinned", pinned)
+  session, err := cs.getViewableSession(ctx, tx, sessionID)
+  if err != nil {
+    return err
+  }
+  rd := requestdata.GetRequestData(ctx)
+  if !pinned {
+    if err := cs.chatRepo.DeletePin(ctx, tx, session.ID, rd.UserID); err != nil {
+      return fmt.Errorf("failed to unpin chat session: %w", err)
+    }
+    return nil
+  }
+  if err := cs.chatRepo.CreatePin(ctx, tx, &types.ChatSessionPin{SessionID: session.ID, UserID: rd.UserID}); err != nil {
+    return fmt.Errorf("failed to pin chat session: %w", err)
+  }
+  return nil
+}
+
+//----------------------------------------------------------------------------------------
+// Delete
+//----------------------------------------------------------------------------------------
+
+func (cs *chatService) DeleteSession(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID) error {
+  cs.log.Info("Starting DeleteSession now...", "sessionID", sessionID)
+  if tx == nil {
+    return cs.db.WithContext(ctx).Transaction(func(tx2 *gorm.DB) error {
+      return cs.deleteSessionLogic(ctx, tx2, sessionID)
+    })
+  }
+  return cs.deleteSessionLogic(ctx, tx, sessionID)
+}
+
+func (cs *chatService) deleteSessionLogic(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID) error {
+  session, err := cs.getOwnSession(ctx, tx, sessionID)
+  if err != nil {
+    return err
+  }
+  // Messages go with the session through fk_chat_message_session_id.
+  if err := cs.chatRepo.FullDeleteSessionsByIDs(ctx, tx, []uuid.UUID{session.ID}); err != nil {
+    return fmt.Errorf("failed to delete chat session: %w", err)
+  }
+  return nil
+}
+
+//----------------------------------------------------------------------------------------
+// Helpers
+//----------------------------------------------------------------------------------------
+
+// getViewableSession loads a session the caller may read. Sessions they
+// may not read are reported as missing.
+func (cs *chatService) getViewableSession(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID) (*types.ChatSession, error) {
+  viewer, err := chatViewer(ctx)
+  if err != nil {
+    return nil, err
+  }
+  sessions, err := cs.chatRepo.GetSessionsByIDs(ctx, tx, []uuid.UUID{sessionID})
+  if err != nil {
+    return nil, fmt.Errorf("failed to load chat session: %w", err)
+  }
+  if len(sessions) == 0 {
+    return nil, ErrChatSessionNotFound
+  }
+  session := sessions[0]
+  if session.UserID != viewer.UserID {
+    ok, err := cs.chatRepo.CanView(ctx, tx, session.ID, viewer)
+    if err != nil {
+      return nil, fmt.Errorf("failed to check chat session access: %w", err)
+    }
+    if !ok {
+      return nil, ErrChatSessionNotFound
+    }
+  }
+  return session, nil
+}
+
+// getOwnSession loads a session of the caller, for changes only the owner
+// may make.
+func (cs *chatService) getOwnSession(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID) (*types.ChatSession, error) {
+  session, err := cs.getViewableSession(ctx, tx, sessionID)
+  if err != nil {
+    return nil, err
+  }
+  if session.UserID != requestdata.GetRequestData(ctx).UserID {
+    return nil, ErrChatSessionReadOnly
+  }
+  return session, nil
+}
+
+func (cs *chatService) sessionDetail(ctx context.Context, tx *gorm.DB, session *types.ChatSession) (*ChatSessionDetail, error) {
+  rd := requestdata.GetRequestData(ctx)
+  messages, err := cs.chatRepo.GetMessagesBySessionID(ctx, tx, session.ID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to load chat messages: %w", err)
+  }
+  if messages == nil {
+    messages = []*types.ChatMessage{}
+  }
+  if err := cs.markPinned(ctx, tx, rd.UserID, []*types.ChatSession{session}); err != nil {
+    return nil, err
+  }
+  detail := &ChatSessionDetail{Session: session, Messages: messages, Owned: session.UserID == rd.UserID}
+  if detail.Owned {
+    shares, err := cs.chatRepo.GetSharesBySessionID(ctx, tx, session.ID)
+    if err != nil {
+      return nil, fmt.Errorf("failed to load chat session shares: %w", err)
+    }
+    for _, share := range shares {
+      detail.SharedWith = append(detail.SharedWith, share.UserID)
+    }
+  }
+  return detail, nil
+}
+
+func (cs *chatService) markPinned(ctx context.Context, tx *gorm.DB, userID uuid.UUID, sessions []*types.ChatSession) error {
+  ids := make([]uuid.UUID, 0, len(sessions))
+  for _, session := range sessions {
+    ids = append(ids, session.ID)
+  }
+  pinnedIDs, err := cs.chatRepo.GetPinnedSessionIDs(ctx, tx, userID, ids)
+  if err != nil {
+    return fmt.Errorf("failed to load pinned chat sessions: %w", err)
+  }
+  pinned := make(map[uuid.UUID]bool, len(pinnedIDs))
+  for _, id := range pinnedIDs {
+    pinned[id] = true
+  }
+  for _, session := range sessions {
+    session.Pinned = pinned[session.ID]
+  }
+  return nil
+}
+
+// shareableUserIDs checks that a session is shared only with other users
+// of the owner's company or WMS.
+func (cs *chatService) shareableUserIDs(ctx context.Context, tx *gorm.DB, session *types.ChatSession, userIDs []uuid.UUID) ([]uuid.UUID, error) {
+  userIDs = uniqueUUIDs(userIDs)
+  if len(userIDs) == 0 {
+    return userIDs, nil
+  }
+  viewer, err := chatViewer(ctx)
+  if err != nil {
+    return nil, err
+  }
+  users, err := cs.userRepo.GetByIDs(ctx, tx, userIDs)
+  if err != nil {
+    return nil, fmt.Errorf("failed to load users: %w", err)
+  }
+  found := make(map[uuid.UUID]*types.User, len(users))
+  for _, u := range users {
+    found[u.ID] = u
+  }
+  for _, id := range userIDs {
+    u := found[id]
+    if id == session.UserID {
+      return nil, fmt.Errorf("a chat session cannot be shared with its owner")
+    }
+    if u == nil || !sameChatTenant(viewer, u) {
+      return nil, fmt.Errorf("user %s is not in your organization", id)
+    }
+  }
+  return userIDs, nil
+}
+
+func sameChatTenant(viewer repos.ChatViewer, u *types.User) bool {
+  if viewer.CompanyID != nil {
+    return u.CompanyID != nil && *u.CompanyID == *viewer.CompanyID
+  }
+  return viewer.WmsID != nil && u.WmsID != nil && *u.WmsID == *viewer.WmsID
+}
+
+// chatViewer is the caller with the company or WMS they belong to.
+func chatViewer(ctx context.Context) (repos.ChatViewer, error) {
+  rd := requestdata.GetRequestData(ctx)
+  if rd == nil {
+    return repos.ChatViewer{}, fmt.Errorf("request data not set in context")
+  }
+  viewer := repos.ChatViewer{UserID: rd.UserID}
+  switch {
+  case rd.UserType == "company" && rd.CompanyID != uuid.Nil:
+    companyID := rd.CompanyID
+    viewer.CompanyID = &companyID
+  case rd.UserType == "wms" && rd.WmsID != uuid.Nil:
+    wmsID := rd.WmsID
+    viewer.WmsID = &wmsID
+  }
+  return viewer, nil
+}
+
+// llmMessages converts the end of a session's history for the model. The
+// window starts at a user message so no tool result loses its call.
+func llmMessages(history []*types.ChatMessage) []llm.Message {
+  start := 0
+  if len(history) > chatHistoryWindow {
+    start = len(history) - chatHistoryWindow
+  }
+  for start < len(history)-1 && history[start].Role != types.ChatRoleUser {
+    start++
+  }
+  out := make([]llm.Message, 0, len(history)-start)
+  for _, m := range history[start:] {
+    msg := llm.Message{Role: llm.Role(m.Role), Content: m.Content, ToolCallID: m.ToolCallID, IsError: m.IsError}
+    for _, c := range m.ToolCalls {
+      args := json.RawMessage(c.Arguments)
+      if !json.Valid(args) {
+        args = json.RawMessage("{}")
+      }
+      msg.ToolCalls = append(msg.ToolCalls, llm.ToolCall{ID: c.ID, Name: c.Name, Arguments: args})
+    }
+    out = append(out, msg)
+  }
+  return out
+}
+
+func chatTitle(text string) string {
+  title := strings.Join(strings.Fields(text), " ")
+  if r := []rune(title); len(r) > chatTitleLength {
+    title = strings.TrimSpace(string(r[:chatTitleLength])) + "…"
+  }
+  return title
+}
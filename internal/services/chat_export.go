@@ -0,0 +1,103 @@
+package services
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "regexp"
+  "strings"
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+const (
+  ChatExportMarkdown    = "markdown"
+  ChatExportJSON        = "json"
+)
+
+// ChatExport is a session rendered for download.
+type ChatExport struct {
+  Filename        string
+  ContentType     string
+  Data            []byte
+}
+
+var chatFilenameUnsafe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// ExportSession renders a session the caller may read as Markdown (the
+// default) or JSON. Tool calls and their results are included so the
+// numbers behind an answer can be checked.
+func (cs *chatService) ExportSession(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID, format string) (*ChatExport, error) {
+  cs.log.Info("Starting ExportSession now...", "sessionID", sessionID, "format", format)
+  format = strings.ToLower(strings.TrimSpace(format))
+  switch format {
+  case "", "md", ChatExportMarkdown:
+    format = ChatExportMarkdown
+  case ChatExportJSON:
+  default:
+    return nil, fmt.Errorf("invalid export format %q (expected markdown or json)", format)
+  }
+  session, err := cs.getViewableSession(ctx, tx, sessionID)
+  if err != nil {
+    return nil, err
+  }
+  detail, err := cs.sessionDetail(ctx, tx, session)
+  if err != nil {
+    return nil, err
+  }
+  exportedAt := time.Now().UTC()
+  name := strings.Trim(chatFilenameUnsafe.ReplaceAllString(strings.ToLower(session.Title), "-"), "-")
+  if name == "" {
+    name = session.ID.String()
+  }
+  name = "chat-" + name
+
+  if format == ChatExportJSON {
+    data, err := json.MarshalIndent(struct {
+      ExportedAt  time.Time             `json:"exportedAt"`
+      Session     *types.ChatSession    `json:"session"`
+      Messages    []*types.ChatMessage  `json:"messages"`
+    }{exportedAt, detail.Session, detail.Messages}, "", "  ")
+    if err != nil {
+      return nil, fmt.Errorf("failed to encode chat export: %w", err)
+    }
+    return &ChatExport{Filename: name + ".json", ContentType: "application/json", Data: data}, nil
+  }
+  return &ChatExport{Filename: name + ".md", ContentType: "text/markdown; charset=utf-8", Data: chatMarkdown(detail, exportedAt)}, nil
+}
+
+func chatMarkdown(detail *ChatSessionDetail, exportedAt time.Time) []byte {
+  var b strings.Builder
+  title := detail.Session.Title
+  if title == "" {
+    title = "Untitled chat"
+  }
+  fmt.Fprintf(&b, "# %s\n\n", title)
+  fmt.Fprintf(&b, "_Started %s, exported %s._\n", detail.Session.CreatedAt.UTC().Format(time.RFC3339), exportedAt.Format(time.RFC3339))
+  for _, m := range detail.Messages {
+    at := m.CreatedAt.UTC().Format("2006-01-02 15:04")
+    switch m.Role {
+    case types.ChatRoleUser:
+      fmt.Fprintf(&b, "\n## User · %s\n\n%s\n", at, m.Content)
+    case types.ChatRoleAssistant:
+      fmt.Fprintf(&b, "\n## Assistant · %s\n", at)
+      if m.Content != "" {
+        fmt.Fprintf(&b, "\n%s\n", m.Content)
+      }
+      for _, call := range m.ToolCalls {
+        fmt.Fprintf(&b, "\nCalled `%s` with:\n\n```json\n%s\n```\n", call.Name, call.Arguments)
+      }
+    case types.ChatRoleTool:
+      label := "Result"
+      if m.IsError {
+        label = "Error"
+      }
+      fmt.Fprintf(&b, "\n%s:\n\n```json\n%s\n```\n", label, m.Content)
+    }
+  }
+  return []byte(b.String())
+}
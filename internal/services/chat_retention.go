@@ -0,0 +1,152 @@
+package services
+
+import (
+  "context"
+  "fmt"
+  "sync"
+  "time"
+
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// MaxChatRetentionDays bounds a retention policy; 0 keeps sessions forever.
+const MaxChatRetentionDays = 3650
+
+// GetRetentionPolicy returns the caller's company or WMS policy, or the
+// keep-forever default when none was set.
+func (cs *chatService) GetRetentionPolicy(ctx context.Context, tx *gorm.DB) (*types.ChatRetentionPolicy, error) {
+  cs.log.Info("Starting GetRetentionPolicy now...")
+  viewer, err := chatViewer(ctx)
+  if err != nil {
+    return nil, err
+  }
+  if viewer.CompanyID == nil && viewer.WmsID == nil {
+    return nil, fmt.Errorf("user does not belong to a company or WMS")
+  }
+  policy, err := cs.chatRepo.GetRetentionPolicy(ctx, tx, viewer.CompanyID, viewer.WmsID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to load chat retention policy: %w", err)
+  }
+  if policy == nil {
+    policy = &types.ChatRetentionPolicy{CompanyID: viewer.CompanyID, WmsID: viewer.WmsID}
+  }
+  return policy, nil
+}
+
+// SetRetentionPolicy sets how many days the caller's company or WMS keeps
+// idle chat sessions. The next purge applies it.
+func (cs *chatService) SetRetentionPolicy(ctx context.Context, tx *gorm.DB, retentionDays int) (*types.ChatRetentionPolicy, error) {
+  cs.log.Info("Starting SetRetentionPolicy now...", "retentionDays", retentionDays)
+  var result *types.ChatRetentionPolicy
+  if tx == nil {
+    if err := cs.db.WithContext(ctx).Transaction(func(tx2 *gorm.DB) error {
+      var err error
+      result, err = cs.setRetentionPolicyLogic(ctx, tx2, retentionDays)
+      return err
+    }); err != nil {
+      return nil, err
+    }
+    return result, nil
+  }
+  return cs.setRetentionPolicyLogic(ctx, tx, retentionDays)
+}
+
+func (cs *chatService) setRetentionPolicyLogic(ctx context.Context, tx *gorm.DB, retentionDays int) (*types.ChatRetentionPolicy, error) {
+  if retentionDays < 0 || retentionDays > MaxChatRetentionDays {
+    return nil, fmt.Errorf("retentionDays must be between 0 and %d", MaxChatRetentionDays)
+  }
+  policy, err := cs.GetRetentionPolicy(ctx, tx)
+  if err != nil {
+    return nil, err
+  }
+  userID := requestdata.GetRequestData(ctx).UserID
+  policy.RetentionDays = retentionDays
+  policy.UpdatedByID = &userID
+  if _, err := cs.chatRepo.SaveRetentionPolicy(ctx, tx, policy); err != nil {
+    return nil, fmt.Errorf("failed to save chat retention policy: %w", err)
+  }
+  return policy, nil
+}
+
+// PurgeExpiredSessions deletes, for every policy, the sessions idle for
+// longer than its retention, and returns how many went. A failing policy is
+// logged and skipped so the others still run.
+func (cs *chatService) PurgeExpiredSessions(ctx context.Context) (int64, error) {
+  policies, err := cs.chatRepo.GetActiveRetentionPolicies(ctx, nil)
+  if err != nil {
+    return 0, fmt.Errorf("failed to load chat retention policies: %w", err)
+  }
+  var purged int64
+  for _, policy := range policies {
+    now := time.Now()
+    cutoff := now.AddDate(0, 0, -policy.RetentionDays)
+    count, err := cs.chatRepo.PurgeSessions(ctx, nil, policy, cutoff)
+    if err != nil {
+      cs.log.Warn("Failed to purge chat sessions", "policyID", policy.ID, "error", err)
+      continue
+    }
+    purged += count
+    policy.LastPurgedAt = &now
+    policy.LastPurgedCount = int(count)
+    if _, err := cs.chatRepo.SaveRetentionPolicy(ctx, nil, policy); err != nil {
+      cs.log.Warn("Failed to record chat purge", "policyID", policy.ID, "error", err)
+    }
+  }
+  return purged, nil
+}
+
+// ChatRetentionWorker runs PurgeExpiredSessions on a schedule. Purging is
+// idempotent, so running a worker on every instance is safe.
+type ChatRetentionWorker struct {
+  log           *logger.Logger
+  chatService   ChatService
+  interval      time.Duration
+  stop          chan struct{}
+  wg            sync.WaitGroup
+  once          sync.Once
+}
+
+func NewChatRetentionWorker(log *logger.Logger, chatService ChatService, interval time.Duration) *ChatRetentionWorker {
+  if interval <= 0 {
+    interval = time.Hour
+  }
+  return &ChatRetentionWorker{
+    log:         log.With("worker", "ChatRetentionWorker"),
+    chatService: chatService,
+    interval:    interval,
+    stop:        make(chan struct{}),
+  }
+}
+
+func (w *ChatRetentionWorker) Start(ctx context.Context) {
+  w.wg.Add(1)
+  go func() {
+    defer w.wg.Done()
+    ticker := time.NewTicker(w.interval)
+    defer ticker.Stop()
+    w.log.Info("Chat retention worker started", "interval", w.interval)
+    for {
+      if purged, err := w.chatService.PurgeExpiredSessions(ctx); err != nil {
+        w.log.Warn("Chat retention pass failed", "error", err)
+      } else if purged > 0 {
+        w.log.Info("Purged expired chat sessions", "count", purged)
+      }
+      select {
+      case <-ctx.Done():
+        return
+      case <-w.stop:
+        return
+      case <-ticker.C:
+      }
+    }
+  }()
+}
+
+func (w *ChatRetentionWorker) Stop() {
+  w.once.Do(func() { close(w.stop) })
+  w.wg.Wait()
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+  "context"
+  "strings"
+  "testing"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/llm"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// The fakes below embed their repo interfaces, so a call the test does not
+// expect panics instead of quietly reaching data it should not.
+
+type fakeChatRepo struct {
+  repos.ChatRepo
+  sessions      map[uuid.UUID]*types.ChatSession
+  messages      map[uuid.UUID][]*types.ChatMessage
+}
+
+func (f *fakeChatRepo) GetSessionsByIDs(ctx context.Context, tx *gorm.DB, sessionIDs []uuid.UUID) ([]*types.ChatSession, error) {
+  var out []*types.ChatSession
+  for _, id := range sessionIDs {
+    if s, ok := f.sessions[id]; ok {
+      out = append(out, s)
+    }
+  }
+  return out, nil
+}
+
+func (f *fakeChatRepo) GetMessagesBySessionID(ctx context.Context, tx *gorm.DB, sessionID uuid.UUID) ([]*types.ChatMessage, error) {
+  return append([]*types.ChatMessage(nil), f.messages[sessionID]...), nil
+}
+
+func (f *fakeChatRepo) CreateMessages(ctx context.Context, tx *gorm.DB, messages []*types.ChatMessage) ([]*types.ChatMessage, error) {
+  for _, m := range messages {
+    f.messages[m.SessionID] = append(f.messages[m.SessionID], m)
+  }
+  return messages, nil
+}
+
+func (f *fakeChatRepo) UpdateSession(ctx context.Context, tx *gorm.DB, session *types.ChatSession) (*types.ChatSession, error) {
+  f.sessions[session.ID] = session
+  return session, nil
+}
+
+type fakeCompanyRepo struct {
+  repos.CompanyRepo
+  companies     []*types.Company
+}
+
+func (f *fakeCompanyRepo) GetByIDs(ctx context.Context, tx *gorm.DB, companyIDs []uuid.UUID) ([]*types.Company, error) {
+  var out []*types.Company
+  for _, c := range f.companies {
+    for _, id := range companyIDs {
+      if c.ID == id {
+        out = append(out, c)
+      }
+    }
+  }
+  return out, nil
+}
+
+func (f *fakeCompanyRepo) GetByWmsIDs(ctx context.Context, tx *gorm.DB, wmsIDs []uuid.UUID) ([]*types.Company, error) {
+  var out []*types.Company
+  for _, c := range f.companies {
+    for _, id := range wmsIDs {
+      if c.WmsID != nil && *c.WmsID == id {
+        out = append(out, c)
+      }
+    }
+  }
+  return out, nil
+}
+
+type fakeWarehouseRepo struct {
+  repos.WarehouseRepo
+  warehouses    []*types.Warehouse
+}
+
+func (f *fakeWarehouseRepo) GetByIDs(ctx context.Context, tx *gorm.DB, warehouseIDs []uuid.UUID) ([]*types.Warehouse, error) {
+  var out []*types.Warehouse
+  for _, w := range f.warehouses {
+    for _, id := range warehouseIDs {
+      if w.ID == id {
+        out = append(out, w)
+      }
+    }
+  }
+  return out, nil
+}
+
+func (f *fakeWarehouseRepo) GetByCompanyID(ctx context.Context, tx *gorm.DB, companyID uuid.UUID) ([]*types.Warehouse, error) {
+  var out []*types.Warehouse
+  for _, w := range f.warehouses {
+    if w.CompanyID == companyID {
+      out = append(out, w)
+    }
+  }
+  return out, nil
+}
+
+type fakeInventoryRepo struct {
+  repos.InventoryRepo
+  positions     map[uuid.UUID][]*types.InventoryOnHand
+  searched      []uuid.UUID
+}
+
+func (f *fakeInventoryRepo) SearchOnHand(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filter repos.OnHandFilter) ([]*types.InventoryOnHand, int64, error) {
+  f.searched = append(f.searched, warehouseID)
+  positions := f.positions[warehouseID]
+  return positions, int64(len(positions)), nil
+}
+
+// TestChatToolCalls drives PostMessage with the stub model, which calls a
+// tool for "/tool <name> <json>" messages, and checks what each tool
+// handed back. Tools run with the requester's context, so a warehouseID of
+// another tenant must fail the same way the GET endpoints do.
+func TestChatToolCalls(t *testing.T) {
+  wmsA, wmsB := uuid.New(), uuid.New()
+  acme := &types.Company{ID: uuid.New(), Name: "Acme", WmsID: &wmsA}
+  globex := &types.Company{ID: uuid.New(), Name: "Globex", WmsID: &wmsB}
+  acmeDC := &types.Warehouse{ID: uuid.New(), Name: "Acme DC", CompanyID: acme.ID}
+  globexDC := &types.Warehouse{ID: uuid.New(), Name: "Globex DC", CompanyID: globex.ID}
+
+  companyUser := &requestdata.RequestData{UserType: "company", UserID: uuid.New(), CompanyID: acme.ID}
+  wmsUser := &requestdata.RequestData{UserType: "wms", UserID: uuid.New(), WmsID: wmsA}
+
+  tests := []struct {
+    name          string
+    rd            *requestdata.RequestData
+    message       string
+    wantTool      string
+    wantError     bool
+    wantContains  string
+    wantSearched  bool
+  }{
+    {name: "company user lists own warehouses", rd: companyUser, message: "/tool list_warehouses", wantTool: "list_warehouses", wantContains: acmeDC.ID.String()},
+    {name: "wms user lists warehouses under its wms", rd: wmsUser, message: "/tool list_warehouses {}", wantTool: "list_warehouses", wantContains: `"company":"Acme"`},
+    {name: "inventory of own warehouse", rd: companyUser, message: `/tool warehouse_inventory {"warehouseID":"` + acmeDC.ID.String() + `"}`, wantTool: "warehouse_inventory", wantContains: `"sku":"SKU-1"`, wantSearched: true},
+    {name: "inventory of another company's warehouse", rd: companyUser, message: `/tool warehouse_inventory {"warehouseID":"` + globexDC.ID.String() + `"}`, wantTool: "warehouse_inventory", wantError: true, wantContains: "another company"},
+    {name: "velocity of another wms's warehouse", rd: wmsUser, message: `/tool top_skus_by_velocity {"warehouseID":"` + globexDC.ID.String() + `"}`, wantTool: "top_skus_by_velocity", wantError: true, wantContains: "user's wms"},
+    {name: "scenarios of another company's warehouse", rd: companyUser, message: `/tool slotting_scenarios {"warehouseID":"` + globexDC.ID.String() + `"}`, wantTool: "slotting_scenarios", wantError: true, wantContains: "another company"},
+    {name: "malformed warehouseID", rd: companyUser, message: `/tool warehouse_inventory {"warehouseID":"acme"}`, wantTool: "warehouse_inventory", wantError: true, wantContains: "call list_warehouses"},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      session := &types.ChatSession{ID: uuid.New(), UserID: tt.rd.UserID}
+      chatRepo := &fakeChatRepo{
+        sessions: map[uuid.UUID]*types.ChatSession{session.ID: session},
+        messages: map[uuid.UUID][]*types.ChatMessage{},
+      }
+      companyRepo := &fakeCompanyRepo{companies: []*types.Company{acme, globex}}
+      warehouseRepo := &fakeWarehouseRepo{warehouses: []*types.Warehouse{acmeDC, globexDC}}
+      inventoryRepo := &fakeInventoryRepo{positions: map[uuid.UUID][]*types.InventoryOnHand{
+        acmeDC.ID:   {{Item: &types.Item{SKU: "SKU-1"}, Location: &types.WarehouseLocation{FullCode: "A-01-01"}, Quantity: 4}},
+        globexDC.ID: {{Item: &types.Item{SKU: "SECRET"}, Location: &types.WarehouseLocation{FullCode: "Z-99-99"}, Quantity: 9}},
+      }}
+      warehouseService := NewWarehouseService(nil, testLogger(), nil, nil, companyRepo, nil, nil, warehouseRepo)
+      cs := NewChatService(
+        nil,
+        testLogger(),
+        llm.NewStub(),
+        chatRepo,
+        nil,
+        companyRepo,
+        warehouseRepo,
+        NewVelocityService(nil, testLogger(), warehouseService, nil, nil, nil),
+        NewInventoryService(nil, testLogger(), warehouseService, nil, nil, inventoryRepo),
+        NewSlottingScenarioService(nil, testLogger(), warehouseService, nil, nil, nil, nil, nil, nil, nil, nil),
+      )
+      ctx := requestdata.WithRequestData(context.Background(), tt.rd)
+
+      added, err := cs.PostMessage(ctx, session.ID, tt.message, nil)
+      if err != nil {
+        t.Fatalf("PostMessage: %v", err)
+      }
+      if len(added) != 4 {
+        t.Fatalf("stored %d messages, want user, tool call, tool result and answer", len(added))
+      }
+      call, result, answer := added[1], added[2], added[3]
+      if len(call.ToolCalls) != 1 || call.ToolCalls[0].Name != tt.wantTool {
+        t.Fatalf("tool calls = %+v, want one call to %s", call.ToolCalls, tt.wantTool)
+      }
+      if result.Role != types.ChatRoleTool || result.ToolCallID != call.ToolCalls[0].ID {
+        t.Fatalf("tool result %+v does not answer call %s", result, call.ToolCalls[0].ID)
+      }
+      if result.IsError != tt.wantError {
+        t.Fatalf("tool result IsError = %v, want %v (%s)", result.IsError, tt.wantError, result.Content)
+      }
+      if !strings.Contains(result.Content, tt.wantContains) {
+        t.Fatalf("tool result = %s, want it to contain %s", result.Content, tt.wantContains)
+      }
+      if strings.Contains(result.Content, "SECRET") || strings.Contains(result.Content, "Globex") {
+        t.Fatalf("tool result leaks another tenant's data: %s", result.Content)
+      }
+      if (len(inventoryRepo.searched) > 0) != tt.wantSearched {
+        t.Fatalf("inventory searched for %v, wantSearched %v", inventoryRepo.searched, tt.wantSearched)
+      }
+      if answer.Role != types.ChatRoleAssistant || !strings.HasPrefix(answer.Content, "Here is what I found.") {
+        t.Fatalf("final answer = %q", answer.Content)
+      }
+    })
+  }
+}
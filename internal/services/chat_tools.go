@@ -0,0 +1,251 @@
+package services
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "strings"
+
+  "github.com/google/uuid"
+
+  "github.com/slotter-org/slotter-backend/internal/llm"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+const (
+  // maxToolResultBytes caps what one tool call hands back to the model.
+  maxToolResultBytes    = 16 << 10
+  defaultToolRows       = 20
+  maxToolRows           = 50
+)
+
+// chatTool is a read-only tool of the assistant. run gets the requester's
+// context, so every tool goes through the same service and tenant checks
+// as the matching GET endpoint.
+type chatTool struct {
+  def   llm.Tool
+  run   func(ctx context.Context, args json.RawMessage) (any, error)
+}
+
+type warehouseToolArgs struct {
+  WarehouseID   string  `json:"warehouseID"`
+  Limit         int     `json:"limit"`
+  ABCClass      string  `json:"abcClass"`
+  SKU           string  `json:"sku"`
+  Location      string  `json:"location"`
+}
+
+func (cs *chatService) chatTools() []chatTool {
+  return []chatTool{
+    {
+      def: llm.Tool{
+        Name:        "list_warehouses",
+        Description: "Lists the warehouses the user can see, with their IDs and companies. Use it to find a warehouseID.",
+        Parameters:  json.RawMessage(`{"type":"object","properties":{}}`),
+      },
+      run: cs.listWarehousesTool,
+    },
+    {
+      def: llm.Tool{
+        Name:        "top_skus_by_velocity",
+        Description: "Returns the fastest-moving SKUs of a warehouse from its latest velocity run, fastest first, with picks per day and ABC/XYZ classes.",
+        Parameters:  json.RawMessage(`{"type":"object","properties":{"warehouseID":{"type":"string","description":"Warehouse UUID"},"limit":{"type":"integer","minimum":1,"maximum":50,"description":"Number of SKUs, 20 by default"},"abcClass":{"type":"string","enum":["A","B","C"]}},"required":["warehouseID"]}`),
+      },
+      run: cs.topSKUsTool,
+    },
+    {
+      def: llm.Tool{
+        Name:        "warehouse_inventory",
+        Description: "Lists on-hand stock positions of a warehouse: SKU, location, lot and quantity. Filter by SKU or by a location code prefix such as A-03.",
+        Parameters:  json.RawMessage(`{"type":"object","properties":{"warehouseID":{"type":"string","description":"Warehouse UUID"},"sku":{"type":"string"},"location":{"type":"string","description":"Location full code prefix"},"limit":{"type":"integer","minimum":1,"maximum":50}},"required":["warehouseID"]}`),
+      },
+      run: cs.inventoryTool,
+    },
+    {
+      def: llm.Tool{
+        Name:        "slotting_scenarios",
+        Description: "Lists a warehouse's slotting scenarios with the KPIs of their last run before and after re-slotting.",
+        Parameters:  json.RawMessage(`{"type":"object","properties":{"warehouseID":{"type":"string","description":"Warehouse UUID"}},"required":["warehouseID"]}`),
+      },
+      run: cs.scenariosTool,
+    },
+  }
+}
+
+// runTool runs a tool call and returns its JSON result, or the error text
+// with isError set so the model can recover.
+func (cs *chatService) runTool(ctx context.Context, call llm.ToolCall) (string, bool) {
+  for _, t := range cs.chatTools() {
+    if t.def.Name != call.Name {
+      continue
+    }
+    result, err := t.run(ctx, call.Arguments)
+    if err != nil {
+      cs.log.Info("Chat tool failed", "tool", call.Name, "error", err)
+      return err.Error(), true
+    }
+    data, err := json.Marshal(result)
+    if err != nil {
+      return fmt.Sprintf("failed to encode result: %v", err), true
+    }
+    if len(data) > maxToolResultBytes {
+      return fmt.Sprintf("result too large (%d bytes); ask for fewer rows", len(data)), true
+    }
+    return string(data), false
+  }
+  return fmt.Sprintf("unknown tool %q", call.Name), true
+}
+
+func (cs *chatService) listWarehousesTool(ctx context.Context, _ json.RawMessage) (any, error) {
+  rd := requestdata.GetRequestData(ctx)
+  if rd == nil {
+    return nil, fmt.Errorf("request data not set in context")
+  }
+  var companies []*types.Company
+  var err error
+  switch rd.UserType {
+  case "wms":
+    companies, err = cs.companyRepo.GetByWmsIDs(ctx, nil, []uuid.UUID{rd.WmsID})
+  case "company":
+    companies, err = cs.companyRepo.GetByIDs(ctx, nil, []uuid.UUID{rd.CompanyID})
+  default:
+    return nil, fmt.Errorf("unknown user type %q", rd.UserType)
+  }
+  if err != nil {
+    return nil, fmt.Errorf("failed to load companies: %w", err)
+  }
+  type row struct {
+    WarehouseID   uuid.UUID   `json:"warehouseID"`
+    Name          string      `json:"name"`
+    Company       string      `json:"company"`
+  }
+  rows := []row{}
+  for _, company := range companies {
+    warehouses, err := cs.warehouseRepo.GetByCompanyID(ctx, nil, company.ID)
+    if err != nil {
+      return nil, fmt.Errorf("failed to load warehouses: %w", err)
+    }
+    for _, w := range warehouses {
+      rows = append(rows, row{WarehouseID: w.ID, Name: w.Name, Company: company.Name})
+    }
+  }
+  return rows, nil
+}
+
+func (cs *chatService) topSKUsTool(ctx context.Context, raw json.RawMessage) (any, error) {
+  args, warehouseID, err := parseWarehouseToolArgs(raw)
+  if err != nil {
+    return nil, err
+  }
+  page, err := cs.velocityService.ListVelocity(ctx, nil, warehouseID, nil, repos.VelocityFilter{
+    ABCClass: strings.ToUpper(strings.TrimSpace(args.ABCClass)),
+    Limit:    args.Limit,
+  })
+  if err != nil {
+    return nil, err
+  }
+  type row struct {
+    Rank          int         `json:"rank"`
+    SKU           string      `json:"sku"`
+    Description   string      `json:"description,omitempty"`
+    PicksPerDay   float64     `json:"picksPerDay"`
+    PickLines     int         `json:"pickLines"`
+    ABCClass      string      `json:"abcClass"`
+    XYZClass      string      `json:"xyzClass"`
+  }
+  rows := make([]row, 0, len(page.Stats))
+  for _, s := range page.Stats {
+    r := row{Rank: s.Rank, PicksPerDay: s.PicksPerDay, PickLines: s.PickLines, ABCClass: s.ABCClass, XYZClass: s.XYZClass}
+    if s.Item != nil {
+      r.SKU, r.Description = s.Item.SKU, s.Item.Description
+    }
+    rows = append(rows, r)
+  }
+  return map[string]any{"runID": page.Run.ID, "windowStart": page.Run.WindowStart, "windowEnd": page.Run.WindowEnd, "skus": rows}, nil
+}
+
+func (cs *chatService) inventoryTool(ctx context.Context, raw json.RawMessage) (any, error) {
+  args, warehouseID, err := parseWarehouseToolArgs(raw)
+  if err != nil {
+    return nil, err
+  }
+  filter := InventoryFilter{SKU: args.SKU}
+  filter.LocationPrefix = strings.TrimSpace(args.Location)
+  filter.Limit = args.Limit
+  page, err := cs.inventoryService.ListOnHand(ctx, nil, warehouseID, filter)
+  if err != nil {
+    return nil, err
+  }
+  type row struct {
+    SKU           string      `json:"sku"`
+    Location      string      `json:"location"`
+    Lot           string      `json:"lot,omitempty"`
+    Quantity      int         `json:"quantity"`
+  }
+  rows := make([]row, 0, len(page.Positions))
+  for _, p := range page.Positions {
+    r := row{Lot: p.Lot, Quantity: p.Quantity}
+    if p.Item != nil {
+      r.SKU = p.Item.SKU
+    }
+    if p.Location != nil {
+      r.Location = p.Location.FullCode
+    }
+    rows = append(rows, r)
+  }
+  return map[string]any{"total": page.Total, "positions": rows}, nil
+}
+
+func (cs *chatService) scenariosTool(ctx context.Context, raw json.RawMessage) (any, error) {
+  _, warehouseID, err := parseWarehouseToolArgs(raw)
+  if err != nil {
+    return nil, err
+  }
+  scenarios, err := cs.scenarioService.ListScenarios(ctx, nil, warehouseID)
+  if err != nil {
+    return nil, err
+  }
+  type row struct {
+    ScenarioID    uuid.UUID             `json:"scenarioID"`
+    Name          string                `json:"name"`
+    Status        string                `json:"status"`
+    KPIsBefore    *types.SlottingKPIs   `json:"kpisBefore,omitempty"`
+    KPIsAfter     *types.SlottingKPIs   `json:"kpisAfter,omitempty"`
+    Promoted      bool                  `json:"promoted"`
+  }
+  rows := make([]row, 0, len(scenarios))
+  for _, s := range scenarios {
+    r := row{ScenarioID: s.ID, Name: s.Name, Status: "not run", Promoted: s.PromotedPlanID != nil}
+    if s.Job != nil {
+      r.Status = string(s.Job.Status)
+      if s.Job.Status == types.SlottingJobCompleted {
+        before, after := s.Job.KPIsBefore, s.Job.KPIsAfter
+        r.KPIsBefore, r.KPIsAfter = &before, &after
+      }
+    }
+    rows = append(rows, r)
+  }
+  return rows, nil
+}
+
+func parseWarehouseToolArgs(raw json.RawMessage) (warehouseToolArgs, uuid.UUID, error) {
+  var args warehouseToolArgs
+  if len(raw) > 0 {
+    if err := json.Unmarshal(raw, &args); err != nil {
+      return args, uuid.Nil, fmt.Errorf("invalid arguments: %v", err)
+    }
+  }
+  warehouseID, err := uuid.Parse(strings.TrimSpace(args.WarehouseID))
+  if err != nil {
+    return args, uuid.Nil, fmt.Errorf("warehouseID must be a warehouse UUID; call list_warehouses to find it")
+  }
+  if args.Limit <= 0 {
+    args.Limit = defaultToolRows
+  }
+  if args.Limit > maxToolRows {
+    args.Limit = maxToolRows
+  }
+  return args, warehouseID, nil
+}
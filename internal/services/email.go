@@ -1,76 +1,132 @@
 package services
 
 import (
+  "bytes"
   "context"
+  "errors"
   "fmt"
+  "mime"
+  "mime/multipart"
+  "mime/quotedprintable"
+  "net/textproto"
   "os"
-  
+  "strings"
+  "time"
+
+  "github.com/google/uuid"
   "github.com/sendgrid/sendgrid-go"
   "github.com/sendgrid/sendgrid-go/helpers/mail"
 
   "github.com/slotter-org/slotter-backend/internal/logger"
 )
 
+// ErrInvalidEmailRecipient is returned for recipients that could smuggle
+// extra headers into a message, such as ones holding a line break.
+var ErrInvalidEmailRecipient = errors.New("invalid email recipient")
+
 type EmailService interface {
   SendEmail(ctx context.Context, toEmail string, subject string, plainText string, htmlContent string, emailType string) error
 }
 
-type emailService struct {
-  log                         *logger.Logger
-  client                      *sendgrid.Client
-  fromSupportEmail            string
-  fromInvitationEmail         string
-  fromAuthorizationEmail      string
-}
+const (
+  EmailBackendSendGrid  = "sendgrid"
+  EmailBackendSMTP      = "smtp"
+  EmailBackendFile      = "file"
+)
 
+// NewEmailService picks the backend named by EMAIL_BACKEND. When it is unset
+// SendGrid is used if SENDGRID_API_KEY is present; with neither set it fails,
+// so a deployment missing its mail config never quietly stops sending. Local
+// and CI runs opt into the .eml sink with EMAIL_BACKEND=file.
 func NewEmailService(log *logger.Logger) (EmailService, error) {
   serviceLog := log.With("Service", "EmailService")
-  apiKey := os.Getenv("SENDGRID_API_KEY")
-  if apiKey == "" {
-    return nil, fmt.Errorf("Missing SENDGRID_API_KEY environment variable")
+  backend := strings.ToLower(strings.TrimSpace(os.Getenv("EMAIL_BACKEND")))
+  if backend == "" {
+    if os.Getenv("SENDGRID_API_KEY") == "" {
+      return nil, fmt.Errorf("EMAIL_BACKEND is not set and SENDGRID_API_KEY is missing; set EMAIL_BACKEND=file to write emails to disk")
+    }
+    backend = EmailBackendSendGrid
+  }
+  senders := loadEmailSenders(serviceLog)
+  serviceLog.Info("Using email backend", "backend", backend)
+  switch backend {
+  case EmailBackendSendGrid:
+    return newSendGridEmailService(serviceLog, senders)
+  case EmailBackendSMTP:
+    return newSMTPEmailService(serviceLog, senders)
+  case EmailBackendFile:
+    return newFileEmailService(serviceLog, senders)
+  default:
+    return nil, fmt.Errorf("unknown EMAIL_BACKEND %q (expected sendgrid, smtp or file)", backend)
   }
-  fromSupport := os.Getenv("SENDGRID_SUPPORT_EMAIL")
-  if fromSupport == "" {
-    serviceLog.Warn("SENDGRID_SUPPORT_EMAIL not set; using fallback no-reply@slotter.ai")
-    fromSupport = "no-reply@slotter.ai"
-  }
-  fromInv := os.Getenv("SENDGRID_INVITATION_EMAIL")
-  if fromInv == "" {
-    serviceLog.Warn("SENDGRID_INVITATION_EMAIL not set; using fallback invitation@slotter.ai")
-    fromInv = "invitation@slotter.ai"
-  }
-  fromAuth := os.Getenv("SENDGRID_AUTHORIZATION_EMAIL")
-  if fromAuth == "" {
-    serviceLog.Warn("SENDGRID_AUTHORIZATION_EMAIL not set; using fallback authorization@slotter.ai")
-    fromAuth = "authorization@slotter.ai"
-  }
-  client := sendgrid.NewSendClient(apiKey)
-
-  return &emailService{
-    log:                    serviceLog,
-    client:                 client,
-    fromSupportEmail:       fromSupport,
-    fromInvitationEmail:    fromInv,
-    fromAuthorizationEmail: fromAuth,
-  }, nil
 }
 
-func (es *emailService) SendEmail(ctx context.Context, toEmail string, subject string, plainText string, htmlContent string, emailType string) error {
-  var fromName = "Slotter"
-  var fromEmail = es.fromSupportEmail
+// emailSenders holds the From addresses shared by every backend.
+type emailSenders struct {
+  support         string
+  invitation      string
+  authorization   string
+}
+
+func loadEmailSenders(log *logger.Logger) emailSenders {
+  return emailSenders{
+    support:        emailSenderFromEnv(log, "EMAIL_SUPPORT_ADDRESS", "SENDGRID_SUPPORT_EMAIL", "no-reply@slotter.ai"),
+    invitation:     emailSenderFromEnv(log, "EMAIL_INVITATION_ADDRESS", "SENDGRID_INVITATION_EMAIL", "invitation@slotter.ai"),
+    authorization:  emailSenderFromEnv(log, "EMAIL_AUTHORIZATION_ADDRESS", "SENDGRID_AUTHORIZATION_EMAIL", "authorization@slotter.ai"),
+  }
+}
+
+func emailSenderFromEnv(log *logger.Logger, key, legacyKey, fallback string) string {
+  if v := os.Getenv(key); v != "" {
+    return v
+  }
+  if v := os.Getenv(legacyKey); v != "" {
+    return v
+  }
+  log.Warn(key+" not set; using fallback", "fallback", fallback)
+  return fallback
+}
+
+func (s emailSenders) fromFor(emailType string) (string, string) {
   switch emailType {
   case "invitation":
-    fromName = "Slotter Invitation"
-    fromEmail = es.fromInvitationEmail
+    return "Slotter Invitation", s.invitation
   case "authorization":
-    fromName = "Slotter Invitation"
-    fromEmail = es.fromAuthorizationEmail
+    return "Slotter Invitation", s.authorization
   case "support":
-    fromName = "Slotter Support"
-    fromEmail = es.fromSupportEmail
+    return "Slotter Support", s.support
   default:
+    return "Slotter", s.support
+  }
+}
+
+//-----------------------------------------
+// SendGrid
+//-----------------------------------------
 
+type sendGridEmailService struct {
+  log       *logger.Logger
+  client    *sendgrid.Client
+  senders   emailSenders
+}
+
+func newSendGridEmailService(log *logger.Logger, senders emailSenders) (EmailService, error) {
+  apiKey := os.Getenv("SENDGRID_API_KEY")
+  if apiKey == "" {
+    return nil, fmt.Errorf("Missing SENDGRID_API_KEY environment variable")
+  }
+  return &sendGridEmailService{
+    log:      log.With("backend", EmailBackendSendGrid),
+    client:   sendgrid.NewSendClient(apiKey),
+    senders:  senders,
+  }, nil
+}
+
+func (es *sendGridEmailService) SendEmail(ctx context.Context, toEmail string, subject string, plainText string, htmlContent string, emailType string) error {
+  if err := checkEmailRecipient(toEmail); err != nil {
+    return err
   }
+  fromName, fromEmail := es.senders.fromFor(emailType)
   from := mail.NewEmail(fromName, fromEmail)
   to := mail.NewEmail("", toEmail)
   message := mail.NewSingleEmail(from, subject, to, plainText, htmlContent)
@@ -79,13 +135,92 @@ func (es *emailService) SendEmail(ctx context.Context, toEmail string, subject s
     es.log.Warn("Sendgrid email send failed", "error", err)
     return err
   }
+  if response.StatusCode >= 300 {
+    es.log.Warn("Sendgrid rejected email", "statusCode", response.StatusCode, "body", response.Body)
+    return fmt.Errorf("sendgrid returned status %d", response.StatusCode)
+  }
   es.log.Info("Email sent", "to", toEmail, "statusCode", response.StatusCode)
   return nil
 }
 
+//-----------------------------------------
+// MIME
+//-----------------------------------------
 
+// buildMIMEMessage renders a multipart/alternative RFC 5322 message. It is
+// shared by the SMTP backend and the .eml file sink so both produce the exact
+// bytes a real mail server would receive.
+func buildMIMEMessage(fromName, fromEmail, toEmail, subject, plainText, htmlContent, emailType string, now time.Time) ([]byte, error) {
+  if err := checkEmailRecipient(toEmail); err != nil {
+    return nil, err
+  }
+  if strings.ContainsAny(fromEmail+emailType, "\r\n") {
+    return nil, fmt.Errorf("sender and email type must not contain line breaks")
+  }
+  var body bytes.Buffer
+  mw := multipart.NewWriter(&body)
+  parts := []struct {
+    contentType string
+    content     string
+  }{
+    {"text/plain; charset=UTF-8", plainText},
+    {"text/html; charset=UTF-8", htmlContent},
+  }
+  for _, p := range parts {
+    if p.content == "" {
+      continue
+    }
+    pw, err := mw.CreatePart(textproto.MIMEHeader{
+      "Content-Type":              {p.contentType},
+      "Content-Transfer-Encoding": {"quoted-printable"},
+    })
+    if err != nil {
+      return nil, err
+    }
+    qp := quotedprintable.NewWriter(pw)
+    if _, err := qp.Write([]byte(p.content)); err != nil {
+      return nil, err
+    }
+    if err := qp.Close(); err != nil {
+      return nil, err
+    }
+  }
+  if err := mw.Close(); err != nil {
+    return nil, err
+  }
 
+  domain := "slotter.ai"
+  if idx := strings.LastIndex(fromEmail, "@"); idx >= 0 && idx < len(fromEmail)-1 {
+    domain = fromEmail[idx+1:]
+  }
+  var msg bytes.Buffer
+  headers := [][2]string{
+    {"From", fmt.Sprintf("%s <%s>", mime.QEncoding.Encode("utf-8", fromName), fromEmail)},
+    {"To", toEmail},
+    {"Subject", mime.QEncoding.Encode("utf-8", subject)},
+    {"Date", now.Format(time.RFC1123Z)},
+    {"Message-ID", fmt.Sprintf("<%s@%s>", uuid.NewString(), domain)},
+    {"MIME-Version", "1.0"},
+    {"Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", mw.Boundary())},
+    {"X-Slotter-Email-Type", emailType},
+  }
+  for _, h := range headers {
+    fmt.Fprintf(&msg, "%s: %s\r\n", h[0], h[1])
+  }
+  msg.WriteString("\r\n")
+  msg.Write(body.Bytes())
+  return msg.Bytes(), nil
+}
 
-
-
-
+// checkEmailRecipient rejects recipients that would break out of the To
+// header. The subject and sender name are Q-encoded, which already escapes
+// line breaks.
+func checkEmailRecipient(toEmail string) error {
+  if toEmail == "" {
+    return fmt.Errorf("%w: empty address", ErrInvalidEmailRecipient)
+  }
+  if strings.ContainsAny(toEmail, "\r\n") {
+    return fmt.Errorf("%w: address contains a line break", ErrInvalidEmailRecipient)
+  }
+  return nil
+}
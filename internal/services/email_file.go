@@ -0,0 +1,60 @@
+package services
+
+import (
+  "context"
+  "fmt"
+  "os"
+  "path/filepath"
+  "regexp"
+  "time"
+
+  "github.com/slotter-org/slotter-backend/internal/logger"
+)
+
+// fileEmailService writes every message as an .eml file under EMAIL_FILE_DIR
+// instead of sending it. The files open in any mail client and are what dev
+// and CI runs assert against.
+type fileEmailService struct {
+  log       *logger.Logger
+  dir       string
+  senders   emailSenders
+}
+
+func newFileEmailService(log *logger.Logger, senders emailSenders) (EmailService, error) {
+  dir := os.Getenv("EMAIL_FILE_DIR")
+  if dir == "" {
+    dir = filepath.Join(os.TempDir(), "slotter-emails")
+  }
+  if err := os.MkdirAll(dir, 0o755); err != nil {
+    return nil, fmt.Errorf("failed to create EMAIL_FILE_DIR %q: %w", dir, err)
+  }
+  log.Info("Writing emails to directory", "dir", dir)
+  return &fileEmailService{
+    log:      log.With("backend", EmailBackendFile),
+    dir:      dir,
+    senders:  senders,
+  }, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func (es *fileEmailService) SendEmail(ctx context.Context, toEmail string, subject string, plainText string, htmlContent string, emailType string) error {
+  now := time.Now()
+  fromName, fromEmail := es.senders.fromFor(emailType)
+  msg, err := buildMIMEMessage(fromName, fromEmail, toEmail, subject, plainText, htmlContent, emailType, now)
+  if err != nil {
+    return fmt.Errorf("failed to build email: %w", err)
+  }
+  name := fmt.Sprintf("%s-%s-%s.eml",
+    now.UTC().Format("20060102T150405.000000000Z"),
+    unsafeFileChars.ReplaceAllString(emailType, "_"),
+    unsafeFileChars.ReplaceAllString(toEmail, "_"),
+  )
+  path := filepath.Join(es.dir, name)
+  if err := os.WriteFile(path, msg, 0o644); err != nil {
+    es.log.Warn("Failed to write email file", "path", path, "error", err)
+    return err
+  }
+  es.log.Info("Email written to file", "to", toEmail, "path", path)
+  return nil
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+  "context"
+  "crypto/tls"
+  "fmt"
+  "net"
+  "net/smtp"
+  "os"
+  "strings"
+  "time"
+
+  "github.com/slotter-org/slotter-backend/internal/logger"
+)
+
+// smtpEmailService speaks plain SMTP. SMTP_TLS selects "starttls" (default),
+// "tls" for implicit TLS (usually port 465) or "none" for local catchers
+// such as MailHog.
+type smtpEmailService struct {
+  log         *logger.Logger
+  host        string
+  port        string
+  username    string
+  password    string
+  tlsMode     string
+  timeout     time.Duration
+  senders     emailSenders
+}
+
+func newSMTPEmailService(log *logger.Logger, senders emailSenders) (EmailService, error) {
+  host := os.Getenv("SMTP_HOST")
+  if host == "" {
+    return nil, fmt.Errorf("Missing SMTP_HOST environment variable")
+  }
+  port := os.Getenv("SMTP_PORT")
+  if port == "" {
+    port = "587"
+  }
+  tlsMode := strings.ToLower(strings.TrimSpace(os.Getenv("SMTP_TLS")))
+  switch tlsMode {
+  case "":
+    tlsMode = "starttls"
+  case "starttls", "tls", "none":
+  default:
+    return nil, fmt.Errorf("unknown SMTP_TLS %q (expected starttls, tls or none)", tlsMode)
+  }
+  return &smtpEmailService{
+    log:      log.With("backend", EmailBackendSMTP),
+    host:     host,
+    port:     port,
+    username: os.Getenv("SMTP_USERNAME"),
+    password: os.Getenv("SMTP_PASSWORD"),
+    tlsMode:  tlsMode,
+    timeout:  30 * time.Second,
+    senders:  senders,
+  }, nil
+}
+
+func (es *smtpEmailService) SendEmail(ctx context.Context, toEmail string, subject string, plainText string, htmlContent string, emailType string) error {
+  fromName, fromEmail := es.senders.fromFor(emailType)
+  msg, err := buildMIMEMessage(fromName, fromEmail, toEmail, subject, plainText, htmlContent, emailType, time.Now())
+  if err != nil {
+    return fmt.Errorf("failed to build email: %w", err)
+  }
+  if err := es.send(ctx, fromEmail, toEmail, msg); err != nil {
+    es.log.Warn("SMTP email send failed", "to", toEmail, "error", err)
+    return err
+  }
+  es.log.Info("Email sent", "to", toEmail, "host", es.host)
+  return nil
+}
+
+func (es *smtpEmailService) send(ctx context.Context, from, to string, msg []byte) error {
+  addr := net.JoinHostPort(es.host, es.port)
+  dialer := &net.Dialer{Timeout: es.timeout}
+  var conn net.Conn
+  var err error
+  if es.tlsMode == "tls" {
+    conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: es.host})
+  } else {
+    conn, err = dialer.DialContext(ctx, "tcp", addr)
+  }
+  if err != nil {
+    return fmt.Errorf("failed to connect to smtp server: %w", err)
+  }
+  deadline := time.Now().Add(es.timeout)
+  if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+    deadline = d
+  }
+  _ = conn.SetDeadline(deadline)
+
+  client, err := smtp.NewClient(conn, es.host)
+  if err != nil {
+    conn.Close()
+    return fmt.Errorf("failed to start smtp session: %w", err)
+  }
+  defer client.Close()
+
+  if es.tlsMode == "starttls" {
+    if ok, _ := client.Extension("STARTTLS"); !ok {
+      return fmt.Errorf("smtp server does not support STARTTLS")
+    }
+    if err := client.StartTLS(&tls.Config{ServerName: es.host}); err != nil {
+      return fmt.Errorf("smtp STARTTLS failed: %w", err)
+    }
+  }
+  if es.username != "" {
+    if err := client.Auth(smtp.PlainAuth("", es.username, es.password, es.host)); err != nil {
+      return fmt.Errorf("smtp auth failed: %w", err)
+    }
+  }
+  if err := client.Mail(from); err != nil {
+    return fmt.Errorf("smtp MAIL FROM failed: %w", err)
+  }
+  if err := client.Rcpt(to); err != nil {
+    return fmt.Errorf("smtp RCPT TO failed: %w", err)
+  }
+  w, err := client.Data()
+  if err != nil {
+    return fmt.Errorf("smtp DATA failed: %w", err)
+  }
+  if _, err := w.Write(msg); err != nil {
+    w.Close()
+    return fmt.Errorf("failed to write smtp message: %w", err)
+  }
+  if err := w.Close(); err != nil {
+    return fmt.Errorf("smtp server rejected message: %w", err)
+  }
+  return client.Quit()
+}
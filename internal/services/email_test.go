@@ -0,0 +1,289 @@
+package services
+
+import (
+  "bytes"
+  "context"
+  "errors"
+  "io"
+  "mime"
+  "mime/multipart"
+  "net/mail"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/google/uuid"
+  "go.uber.org/zap"
+
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+func testLogger() *logger.Logger {
+  return &logger.Logger{SugaredLogger: zap.NewNop().Sugar()}
+}
+
+// parseRenderedEmail parses raw as a mail client would and returns its
+// headers and the decoded body of each part keyed by media type.
+func parseRenderedEmail(t *testing.T, raw []byte) (mail.Header, map[string]string) {
+  t.Helper()
+  msg, err := mail.ReadMessage(bytes.NewReader(raw))
+  if err != nil {
+    t.Fatalf("message does not parse: %v", err)
+  }
+  mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+  if err != nil || mediaType != "multipart/alternative" {
+    t.Fatalf("Content-Type = %q, want multipart/alternative", msg.Header.Get("Content-Type"))
+  }
+  parts := make(map[string]string)
+  mr := multipart.NewReader(msg.Body, params["boundary"])
+  for {
+    part, err := mr.NextPart()
+    if err == io.EOF {
+      break
+    }
+    if err != nil {
+      t.Fatalf("failed to read part: %v", err)
+    }
+    body, err := io.ReadAll(part)
+    if err != nil {
+      t.Fatalf("failed to read part body: %v", err)
+    }
+    partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+    parts[partType] = string(body)
+  }
+  return msg.Header, parts
+}
+
+func TestBuildMIMEMessage(t *testing.T) {
+  now := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+  tests := []struct {
+    name          string
+    fromName      string
+    toEmail       string
+    subject       string
+    plainText     string
+    htmlContent   string
+    wantParts     []string
+  }{
+    {
+      name:         "plain and html",
+      fromName:     "Slotter Invitation",
+      toEmail:      "ana@example.com",
+      subject:      "You're invited to Acme",
+      plainText:    "Join here: https://app.slotter.ai/invite?token=abc",
+      htmlContent:  `<a href="https://app.slotter.ai/invite?token=abc">Join</a>`,
+      wantParts:    []string{"text/plain", "text/html"},
+    },
+    {
+      name:         "non-ascii subject and long lines",
+      fromName:     "Slotter Société",
+      toEmail:      "jose@example.com",
+      subject:      "Invitación a Almacén Norte",
+      plainText:    strings.Repeat("línea larga ", 20),
+      wantParts:    []string{"text/plain"},
+    },
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      raw, err := buildMIMEMessage(tt.fromName, "invitation@slotter.ai", tt.toEmail, tt.subject, tt.plainText, tt.htmlContent, "invitation", now)
+      if err != nil {
+        t.Fatalf("buildMIMEMessage: %v", err)
+      }
+      header, parts := parseRenderedEmail(t, raw)
+      dec := new(mime.WordDecoder)
+      subject, err := dec.DecodeHeader(header.Get("Subject"))
+      if err != nil || subject != tt.subject {
+        t.Errorf("Subject = %q, want %q", subject, tt.subject)
+      }
+      from, err := header.AddressList("From")
+      if err != nil || len(from) != 1 || from[0].Name != tt.fromName || from[0].Address != "invitation@slotter.ai" {
+        t.Errorf("From = %v (%v), want %s <invitation@slotter.ai>", from, err, tt.fromName)
+      }
+      if got := header.Get("To"); got != tt.toEmail {
+        t.Errorf("To = %q, want %q", got, tt.toEmail)
+      }
+      if got := header.Get("X-Slotter-Email-Type"); got != "invitation" {
+        t.Errorf("X-Slotter-Email-Type = %q, want invitation", got)
+      }
+      if got, _ := header.Date(); !got.Equal(now) {
+        t.Errorf("Date = %v, want %v", got, now)
+      }
+      if !strings.HasSuffix(header.Get("Message-ID"), "@slotter.ai>") {
+        t.Errorf("Message-ID = %q, want one at slotter.ai", header.Get("Message-ID"))
+      }
+      if len(parts) != len(tt.wantParts) {
+        t.Errorf("got %d parts, want %d", len(parts), len(tt.wantParts))
+      }
+      want := map[string]string{"text/plain": tt.plainText, "text/html": tt.htmlContent}
+      for _, p := range tt.wantParts {
+        if parts[p] != want[p] {
+          t.Errorf("%s part = %q, want %q", p, parts[p], want[p])
+        }
+      }
+    })
+  }
+}
+
+func TestBuildMIMEMessageRejectsHeaderInjection(t *testing.T) {
+  tests := []struct {
+    name          string
+    toEmail       string
+  }{
+    {name: "empty", toEmail: ""},
+    {name: "crlf bcc", toEmail: "ana@example.com\r\nBcc: eve@example.com"},
+    {name: "bare lf", toEmail: "ana@example.com\nBcc: eve@example.com"},
+    {name: "bare cr", toEmail: "ana@example.com\rBcc: eve@example.com"},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      _, err := buildMIMEMessage("Slotter", "no-reply@slotter.ai", tt.toEmail, "Hi", "Hello", "", "support", time.Now())
+      if !errors.Is(err, ErrInvalidEmailRecipient) {
+        t.Fatalf("err = %v, want ErrInvalidEmailRecipient", err)
+      }
+    })
+  }
+}
+
+func TestNewEmailServiceRequiresExplicitBackend(t *testing.T) {
+  t.Setenv("EMAIL_BACKEND", "")
+  t.Setenv("SENDGRID_API_KEY", "")
+  if _, err := NewEmailService(testLogger()); err == nil {
+    t.Fatal("expected an error with no email backend configured")
+  }
+}
+
+func TestFileEmailServiceWritesRenderedMessage(t *testing.T) {
+  dir := t.TempDir()
+  t.Setenv("EMAIL_BACKEND", EmailBackendFile)
+  t.Setenv("EMAIL_FILE_DIR", dir)
+  svc, err := NewEmailService(testLogger())
+  if err != nil {
+    t.Fatalf("NewEmailService: %v", err)
+  }
+  if err := svc.SendEmail(context.Background(), "ana@example.com", "Reset your password", "Use code 123456", "<p>Use code 123456</p>", "authorization"); err != nil {
+    t.Fatalf("SendEmail: %v", err)
+  }
+  if err := svc.SendEmail(context.Background(), "ana@example.com\r\nBcc: eve@example.com", "Hi", "Hello", "", "support"); !errors.Is(err, ErrInvalidEmailRecipient) {
+    t.Fatalf("SendEmail with injected header: err = %v, want ErrInvalidEmailRecipient", err)
+  }
+  files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+  if err != nil || len(files) != 1 {
+    t.Fatalf("got %d .eml files (%v), want 1", len(files), err)
+  }
+  raw, err := os.ReadFile(files[0])
+  if err != nil {
+    t.Fatalf("failed to read %s: %v", files[0], err)
+  }
+  header, parts := parseRenderedEmail(t, raw)
+  if got := header.Get("Subject"); got != "Reset your password" {
+    t.Errorf("Subject = %q, want %q", got, "Reset your password")
+  }
+  if parts["text/plain"] != "Use code 123456" || parts["text/html"] != "<p>Use code 123456</p>" {
+    t.Errorf("unexpected parts: %q", parts)
+  }
+}
+
+// TestInvitationEmailEndToEnd renders a Spanish tenant's invitation through
+// the template registry, enqueues it, dispatches it to the file backend and
+// reads the .eml back as a mail client would.
+func TestInvitationEmailEndToEnd(t *testing.T) {
+  dir := t.TempDir()
+  t.Setenv("EMAIL_BACKEND", EmailBackendFile)
+  t.Setenv("EMAIL_FILE_DIR", dir)
+  t.Setenv("SLOTTER_BRAND_LOGO_PATH", "")
+  t.Setenv("SLOTTER_FRONT_END_URL", "https://app.example.com")
+
+  wms := &types.Wms{ID: uuid.New(), Name: "Almacén Norte", AvatarURL: "https://cdn.example.com/norte.png", Locale: "es", BrandPrimaryColor: "#0a0b0c"}
+  company := &types.Company{ID: uuid.New(), Name: "Frutas & Verduras", AvatarURL: "https://cdn.example.com/frutas.png", WmsID: &wms.ID}
+  companyRepo := &fakeCompanyRepo{companies: []*types.Company{company}}
+  templateService, err := NewTemplateService(nil, testLogger(), &fakeWmsRepo{wms: []*types.Wms{wms}}, companyRepo, &fakeUserRepo{})
+  if err != nil {
+    t.Fatalf("NewTemplateService: %v", err)
+  }
+  outbox := &fakeOutboxService{}
+  is := NewInvitationService(nil, testLogger(), nil, &fakeUserRepo{}, &fakeWmsRepo{wms: []*types.Wms{wms}}, companyRepo, nil, nil, nil, templateService, outbox).(*invitationService)
+
+  name, email, note := "Ana Pérez", "ana@example.com", "Te esperamos <pronto>"
+  inv := &types.Invitation{
+    ID:             uuid.New(),
+    WmsID:          &wms.ID,
+    CompanyID:      &company.ID,
+    Name:           &name,
+    Email:          &email,
+    Message:        &note,
+    Token:          "tok123",
+    InvitationType: types.InvitationTypeJoinCompany,
+    ExpiresAt:      time.Now().Add(49 * time.Hour),
+  }
+  if err := is.enqueueInvitationOutbound(context.Background(), nil, inv); err != nil {
+    t.Fatalf("enqueueInvitationOutbound: %v", err)
+  }
+  if len(outbox.enqueued) != 1 {
+    t.Fatalf("enqueued %d messages, want 1", len(outbox.enqueued))
+  }
+
+  msg := outbox.enqueued[0]
+  msg.ID = uuid.New()
+  msg.Status = types.OutboxStatusPending
+  msg.MaxAttempts = 8
+  msg.NextAttemptAt = time.Now().Add(-time.Second)
+  emailService, err := NewEmailService(testLogger())
+  if err != nil {
+    t.Fatalf("NewEmailService: %v", err)
+  }
+  repo := &fakeOutboxMessageRepo{messages: []*types.OutboxMessage{msg}}
+  d := NewOutboxDispatcher(testLogger(), repo, map[types.OutboxChannel]OutboxTarget{types.OutboxChannelEmail: NewEmailOutboxTarget(emailService)}, OutboxDispatcherConfig{})
+  if sent, err := d.DispatchDue(context.Background()); err != nil || sent != 1 {
+    t.Fatalf("DispatchDue() = %d, %v, want 1 sent (last error %q)", sent, err, repo.get(msg.ID).LastError)
+  }
+
+  files, err := filepath.Glob(filepath.Join(dir, "*-invitation-ana_example.com.eml"))
+  if err != nil || len(files) != 1 {
+    t.Fatalf("got %d invitation .eml files (%v), want 1", len(files), err)
+  }
+  raw, err := os.ReadFile(files[0])
+  if err != nil {
+    t.Fatalf("failed to read %s: %v", files[0], err)
+  }
+  header, parts := parseRenderedEmail(t, raw)
+
+  const wantSubject = "¡Has sido invitado a Almacén Norte en Slotter!"
+  rawSubject := header.Get("Subject")
+  if !strings.HasPrefix(rawSubject, "=?utf-8?q?") || strings.ContainsAny(rawSubject, "¡é") {
+    t.Errorf("raw Subject = %q, want it Q-encoded", rawSubject)
+  }
+  if subject, err := new(mime.WordDecoder).DecodeHeader(rawSubject); err != nil || subject != wantSubject {
+    t.Errorf("Subject = %q (%v), want %q", subject, err, wantSubject)
+  }
+  if from, err := header.AddressList("From"); err != nil || len(from) != 1 || from[0].Name != "Slotter Invitation" {
+    t.Errorf("From = %v (%v), want the invitation sender", from, err)
+  }
+  if header.Get("To") != email || header.Get("X-Slotter-Email-Type") != "invitation" {
+    t.Errorf("To = %q, type = %q, want %s and invitation", header.Get("To"), header.Get("X-Slotter-Email-Type"), email)
+  }
+
+  link := "https://app.example.com/register?token=tok123"
+  if want := "¡Has sido invitado a unirte a Almacén Norte en Slotter! Haz clic aquí: " + link; parts["text/plain"] != want {
+    t.Errorf("text part = %q, want %q", parts["text/plain"], want)
+  }
+  html := parts["text/html"]
+  for _, want := range []string{
+    "Ana Pérez",
+    "Frutas &amp; Verduras",
+    "Te esperamos &lt;pronto&gt;",
+    `href="` + link + `"`,
+    `src="https://cdn.example.com/frutas.png"`,
+    "#0a0b0c",
+    "Esta invitación vence en 48 horas.",
+  } {
+    if !strings.Contains(html, want) {
+      t.Errorf("html part does not contain %q", want)
+    }
+  }
+  if strings.Contains(html, "<pronto>") {
+    t.Error("html part contains the unescaped invitation message")
+  }
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+  "context"
+
+  "github.com/slotter-org/slotter-backend/internal/events"
+  "github.com/slotter-org/slotter-backend/internal/socket"
+  "github.com/slotter-org/slotter-backend/internal/sse"
+)
+
+// sseEventHandler delivers every event to its routes on the SSE hub, named
+// after the event type so EventSource listeners keep working.
+type sseEventHandler struct {
+  hub *sse.SSEHub
+}
+
+func NewSSEEventHandler(hub *sse.SSEHub) events.Handler {
+  return &sseEventHandler{hub: hub}
+}
+
+func (h *sseEventHandler) Name() string {
+  return "sse"
+}
+
+func (h *sseEventHandler) Handle(ctx context.Context, ev events.Event) error {
+  for _, channel := range ev.Routes() {
+    h.hub.Broadcast(sse.SSEMessage{
+      Channel: channel,
+      Event:   sse.SSEEvent(ev.Type),
+      Data:    ev,
+    })
+  }
+  return nil
+}
+
+// socketEventHandler delivers every event to its routes on the websocket
+// hub, which fans it out to the other instances as well.
+type socketEventHandler struct {
+  hub *socket.Hub
+}
+
+func NewSocketEventHandler(hub *socket.Hub) events.Handler {
+  return &socketEventHandler{hub: hub}
+}
+
+func (h *socketEventHandler) Name() string {
+  return "websocket"
+}
+
+func (h *socketEventHandler) Handle(ctx context.Context, ev events.Event) error {
+  for _, channel := range ev.Routes() {
+    h.hub.BroadcastGlobal(ctx, socket.Message{Channel: channel, Data: ev})
+  }
+  return nil
+}
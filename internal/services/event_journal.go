@@ -0,0 +1,156 @@
+package services
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/events"
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// eventJournalRecipient is the recipient of journaled events, which go to
+// the audit log and webhooks rather than to an address.
+const eventJournalRecipient = "events"
+
+// EventJournal writes events for webhooks and the audit log to the outbox,
+// in the transaction of the change they describe. It is also a bus handler
+// for the events published outside a request, like finished background
+// runs; request events reach the bus live only and are skipped there.
+type EventJournal interface {
+  events.Journal
+  events.Handler
+}
+
+type eventJournal struct {
+  outboxService     OutboxService
+}
+
+func NewEventJournal(outboxService OutboxService) EventJournal {
+  return &eventJournal{outboxService: outboxService}
+}
+
+// Write queues every tenant event except transient ones like job progress.
+func (j *eventJournal) Write(ctx context.Context, tx *gorm.DB, evs ...events.Event) error {
+  var msgs []*types.OutboxMessage
+  for _, ev := range evs {
+    if ev.Transient() || (ev.WmsID == nil && ev.CompanyID == nil) {
+      continue
+    }
+    body, err := json.Marshal(ev)
+    if err != nil {
+      return fmt.Errorf("failed to encode event: %w", err)
+    }
+    eventID := ev.ID
+    msgs = append(msgs, &types.OutboxMessage{
+      WmsID:        ev.WmsID,
+      CompanyID:    ev.CompanyID,
+      Channel:      types.OutboxChannelEvent,
+      MessageType:  string(ev.Type),
+      SourceType:   "event",
+      SourceID:     &eventID,
+      Recipient:    eventJournalRecipient,
+      Body:         string(body),
+    })
+  }
+  if len(msgs) == 0 {
+    return nil
+  }
+  if _, err := j.outboxService.Enqueue(ctx, tx, msgs); err != nil {
+    return fmt.Errorf("failed to journal events: %w", err)
+  }
+  return nil
+}
+
+func (j *eventJournal) Notify() {
+  j.outboxService.Notify()
+}
+
+func (j *eventJournal) Name() string {
+  return "journal"
+}
+
+func (j *eventJournal) Handle(ctx context.Context, ev events.Event) error {
+  if ev.Transient() {
+    return nil
+  }
+  if err := j.Write(ctx, nil, ev); err != nil {
+    return err
+  }
+  j.Notify()
+  return nil
+}
+
+// eventOutboxTarget delivers a journaled event: it adds the event to the
+// audit log and queues a webhook message for every endpoint subscribed to
+// it, in one transaction. The audit entry is keyed by the event's id, so an
+// event delivered again after a crash is not logged or sent twice.
+type eventOutboxTarget struct {
+  db                *gorm.DB
+  log               *logger.Logger
+  auditRepo         repos.AuditLogRepo
+  webhookRepo       repos.WebhookEndpointRepo
+  outboxService     OutboxService
+}
+
+func NewEventOutboxTarget(db *gorm.DB, log *logger.Logger, auditRepo repos.AuditLogRepo, webhookRepo repos.WebhookEndpointRepo, outboxService OutboxService) OutboxTarget {
+  return &eventOutboxTarget{
+    db:             db,
+    log:            log.With("target", "EventOutboxTarget"),
+    auditRepo:      auditRepo,
+    webhookRepo:    webhookRepo,
+    outboxService:  outboxService,
+  }
+}
+
+func (t *eventOutboxTarget) Deliver(ctx context.Context, msg *types.OutboxMessage) error {
+  var ev events.Event
+  if err := json.Unmarshal([]byte(msg.Body), &ev); err != nil {
+    return fmt.Errorf("%w: invalid event: %v", ErrPermanentDelivery, err)
+  }
+  queued := 0
+  err := t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+    created, err := t.auditRepo.Create(ctx, tx, auditLogEntry(ev))
+    if err != nil {
+      return fmt.Errorf("failed to record audit log entry: %w", err)
+    }
+    if !created {
+      t.log.Info("Event already delivered", "eventID", ev.ID)
+      return nil
+    }
+    msgs, err := webhookMessages(ctx, tx, t.webhookRepo, ev, msg.Body)
+    if err != nil {
+      return fmt.Errorf("failed to load webhook endpoints: %w", err)
+    }
+    if len(msgs) == 0 {
+      return nil
+    }
+    if _, err := t.outboxService.Enqueue(ctx, tx, msgs); err != nil {
+      return err
+    }
+    queued = len(msgs)
+    return nil
+  })
+  if err != nil {
+    return err
+  }
+  if queued > 0 {
+    t.outboxService.Notify()
+  }
+  return nil
+}
+
+// recordCompanyEvent records an event for the company from inside the
+// transaction tx that made the change. A company-less change records
+// nothing.
+func recordCompanyEvent(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, t events.Type, data interface{}) error {
+  if companyID == uuid.Nil {
+    return nil
+  }
+  return events.RecordTx(ctx, tx, events.New(t, data).ForCompany(companyID))
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+  "context"
+  "encoding/json"
+  "testing"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/events"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type fakeOutboxService struct {
+  OutboxService
+  enqueued      []*types.OutboxMessage
+  notified      int
+}
+
+func (f *fakeOutboxService) Enqueue(ctx context.Context, tx *gorm.DB, msgs []*types.OutboxMessage) ([]*types.OutboxMessage, error) {
+  f.enqueued = append(f.enqueued, msgs...)
+  return msgs, nil
+}
+
+func (f *fakeOutboxService) Notify() {
+  f.notified++
+}
+
+func (f *fakeWebhookEndpointRepo) GetActiveByTenant(ctx context.Context, tx *gorm.DB, wmsID, companyID *uuid.UUID) ([]*types.WebhookEndpoint, error) {
+  var out []*types.WebhookEndpoint
+  for _, e := range f.endpoints {
+    if !e.Active {
+      continue
+    }
+    if (wmsID != nil && e.WmsID != nil && *e.WmsID == *wmsID) || (companyID != nil && e.CompanyID != nil && *e.CompanyID == *companyID) {
+      out = append(out, e)
+    }
+  }
+  return out, nil
+}
+
+func TestEventJournalWrite(t *testing.T) {
+  companyID := uuid.New()
+  tests := []struct {
+    name          string
+    ev            events.Event
+    journaled     bool
+  }{
+    {name: "tenant event", ev: events.New(events.ItemCreated, map[string]string{"sku": "A-1"}).ForCompany(companyID), journaled: true},
+    {name: "transient type", ev: events.New(events.SlottingJobProgress, nil).ForCompany(companyID)},
+    {name: "live only", ev: events.New(events.ItemCreated, nil).ForCompany(companyID).Live()},
+    {name: "no tenant", ev: events.New(events.ItemCreated, nil)},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      outbox := &fakeOutboxService{}
+      if err := NewEventJournal(outbox).Write(context.Background(), nil, tt.ev); err != nil {
+        t.Fatalf("Write: %v", err)
+      }
+      if !tt.journaled {
+        if len(outbox.enqueued) != 0 {
+          t.Fatalf("enqueued %d messages, want none", len(outbox.enqueued))
+        }
+        return
+      }
+      if len(outbox.enqueued) != 1 {
+        t.Fatalf("enqueued %d messages, want 1", len(outbox.enqueued))
+      }
+      msg := outbox.enqueued[0]
+      if msg.Channel != types.OutboxChannelEvent || msg.Recipient == "" || msg.SourceID == nil || *msg.SourceID != tt.ev.ID {
+        t.Fatalf("unexpected message %+v", msg)
+      }
+      if msg.CompanyID == nil || *msg.CompanyID != companyID {
+        t.Fatalf("message company = %v, want %s", msg.CompanyID, companyID)
+      }
+      var decoded events.Event
+      if err := json.Unmarshal([]byte(msg.Body), &decoded); err != nil {
+        t.Fatalf("body does not decode: %v", err)
+      }
+      if decoded.ID != tt.ev.ID || decoded.Type != tt.ev.Type {
+        t.Fatalf("decoded %s %s, want %s %s", decoded.Type, decoded.ID, tt.ev.Type, tt.ev.ID)
+      }
+    })
+  }
+}
+
+// A recorded event is journaled once, in the transaction, and reaches the
+// bus live only so the journal's bus handler does not write it again.
+func TestRecordTxJournalsOnce(t *testing.T) {
+  outbox := &fakeOutboxService{}
+  journal := NewEventJournal(outbox)
+  ctx := events.WithCollector(context.Background(), journal)
+  companyID := uuid.New()
+
+  if err := events.RecordTx(ctx, nil, events.New(events.ItemCreated, nil).ForCompany(companyID)); err != nil {
+    t.Fatalf("RecordTx: %v", err)
+  }
+  if err := events.RecordTx(ctx, nil, events.New(events.SlottingJobProgress, nil).ForCompany(companyID)); err != nil {
+    t.Fatalf("RecordTx: %v", err)
+  }
+  if len(outbox.enqueued) != 1 {
+    t.Fatalf("journaled %d events, want 1", len(outbox.enqueued))
+  }
+  collected := events.GetCollector(ctx).Drain()
+  if len(collected) != 2 {
+    t.Fatalf("collected %d events, want 2", len(collected))
+  }
+  for _, ev := range collected {
+    if err := journal.Handle(ctx, ev); err != nil {
+      t.Fatalf("Handle: %v", err)
+    }
+  }
+  if len(outbox.enqueued) != 1 {
+    t.Fatalf("journaled %d events after publishing, want 1", len(outbox.enqueued))
+  }
+}
+
+func TestWebhookMessages(t *testing.T) {
+  companyID := uuid.New()
+  otherCompanyID := uuid.New()
+  all := &types.WebhookEndpoint{ID: uuid.New(), CompanyID: &companyID, URL: "https://all.example.com/hook", Active: true}
+  items := &types.WebhookEndpoint{ID: uuid.New(), CompanyID: &companyID, URL: "https://items.example.com/hook", EventTypes: []string{string(events.ItemCreated)}, Active: true}
+  roles := &types.WebhookEndpoint{ID: uuid.New(), CompanyID: &companyID, URL: "https://roles.example.com/hook", EventTypes: []string{string(events.RoleCreated)}, Active: true}
+  disabled := &types.WebhookEndpoint{ID: uuid.New(), CompanyID: &companyID, URL: "https://off.example.com/hook"}
+  other := &types.WebhookEndpoint{ID: uuid.New(), CompanyID: &otherCompanyID, URL: "https://other.example.com/hook", Active: true}
+  repo := &fakeWebhookEndpointRepo{endpoints: map[uuid.UUID]*types.WebhookEndpoint{}}
+  for _, e := range []*types.WebhookEndpoint{all, items, roles, disabled, other} {
+    repo.endpoints[e.ID] = e
+  }
+
+  ev := events.New(events.ItemCreated, nil).ForCompany(companyID)
+  msgs, err := webhookMessages(context.Background(), nil, repo, ev, `{"body":true}`)
+  if err != nil {
+    t.Fatalf("webhookMessages: %v", err)
+  }
+  got := map[uuid.UUID]bool{}
+  for _, m := range msgs {
+    if m.Channel != types.OutboxChannelWebhook || m.Body != `{"body":true}` || m.SourceID == nil {
+      t.Fatalf("unexpected message %+v", m)
+    }
+    got[*m.SourceID] = true
+  }
+  if len(got) != 2 || !got[all.ID] || !got[items.ID] {
+    t.Fatalf("messages for %v, want the catch-all and item endpoints", got)
+  }
+}
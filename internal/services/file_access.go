@@ -0,0 +1,129 @@
+package services
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+)
+
+type SignedURL struct {
+  Key           string        `json:"key"`
+  Method        string        `json:"method"`
+  URL           string        `json:"url"`
+  ExpiresAt     time.Time     `json:"expiresAt"`
+}
+
+// ErrSignedURLWrite is returned by SignURL for PUT and DELETE, which are only
+// signed by SignWriteURL behind the manage_files permission.
+var ErrSignedURLWrite = errors.New("write urls must be requested from /files/sign-write")
+
+// FileAccessService hands out signed URLs for private objects after checking
+// that the requester belongs to the tenant that owns them. SignURL signs
+// reads only; SignWriteURL signs PUT and DELETE and is routed behind the
+// manage_files permission.
+type FileAccessService interface {
+  SignURL(ctx context.Context, tx *gorm.DB, key string, method string, ttl time.Duration) (*SignedURL, error)
+  SignWriteURL(ctx context.Context, tx *gorm.DB, key string, method string, ttl time.Duration) (*SignedURL, error)
+  CanAccess(ctx context.Context, tx *gorm.DB, key string) error
+}
+
+type fileAccessService struct {
+  db              *gorm.DB
+  log             *logger.Logger
+  companyRepo     repos.CompanyRepo
+  bucketService   BucketService
+}
+
+func NewFileAccessService(db *gorm.DB, log *logger.Logger, companyRepo repos.CompanyRepo, bucketService BucketService) FileAccessService {
+  return &fileAccessService{
+    db:             db,
+    log:            log.With("service", "FileAccessService"),
+    companyRepo:    companyRepo,
+    bucketService:  bucketService,
+  }
+}
+
+func (fs *fileAccessService) SignURL(ctx context.Context, tx *gorm.DB, key string, method string, ttl time.Duration) (*SignedURL, error) {
+  fs.log.Info("Starting SignURL now...", "key", key, "method", method, "ttl", ttl)
+  if IsSignedURLWrite(method) {
+    return nil, ErrSignedURLWrite
+  }
+  return fs.signURL(ctx, tx, key, method, ttl)
+}
+
+func (fs *fileAccessService) SignWriteURL(ctx context.Context, tx *gorm.DB, key string, method string, ttl time.Duration) (*SignedURL, error) {
+  fs.log.Info("Starting SignWriteURL now...", "key", key, "method", method, "ttl", ttl)
+  if !IsSignedURLWrite(method) {
+    return nil, fmt.Errorf("write urls are signed for PUT or DELETE, not %q", method)
+  }
+  return fs.signURL(ctx, tx, key, method, ttl)
+}
+
+func (fs *fileAccessService) signURL(ctx context.Context, tx *gorm.DB, key string, method string, ttl time.Duration) (*SignedURL, error) {
+  if err := fs.CanAccess(ctx, tx, key); err != nil {
+    fs.log.Warn("Refusing to sign url", "key", key, "error", err)
+    return nil, err
+  }
+  method, ttl, err := checkSignedURLRequest(key, ttl, method)
+  if err != nil {
+    return nil, err
+  }
+  signed, err := fs.bucketService.GetSignedURL(key, ttl, method)
+  if err != nil {
+    return nil, err
+  }
+  return &SignedURL{
+    Key:        key,
+    Method:     method,
+    URL:        signed,
+    ExpiresAt:  time.Now().Add(ttl),
+  }, nil
+}
+
+// CanAccess allows wms users into their own wms's objects and those of the
+// companies it manages, and company users into their own company's objects.
+func (fs *fileAccessService) CanAccess(ctx context.Context, tx *gorm.DB, key string) error {
+  rd := requestdata.GetRequestData(ctx)
+  if rd == nil {
+    return fmt.Errorf("request data not set in context")
+  }
+  ownerType, ownerID, err := ParsePrivateObjectKey(key)
+  if err != nil {
+    return err
+  }
+  denied := fmt.Errorf("you do not have access to this file")
+  switch ownerType {
+  case "wms":
+    if rd.UserType == "wms" && rd.WmsID != uuid.Nil && rd.WmsID == ownerID {
+      return nil
+    }
+    return denied
+  case "company":
+    if rd.UserType == "company" {
+      if rd.CompanyID != uuid.Nil && rd.CompanyID == ownerID {
+        return nil
+      }
+      return denied
+    }
+    if rd.UserType != "wms" || rd.WmsID == uuid.Nil {
+      return denied
+    }
+    companies, err := fs.companyRepo.GetByIDs(ctx, tx, []uuid.UUID{ownerID})
+    if err != nil {
+      return fmt.Errorf("failed to load owning company: %w", err)
+    }
+    if len(companies) == 0 || companies[0].WmsID == nil || *companies[0].WmsID != rd.WmsID {
+      return denied
+    }
+    return nil
+  }
+  return denied
+}
@@ -0,0 +1,626 @@
+package services
+
+import (
+  "bytes"
+  "context"
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/json"
+  "fmt"
+  "math"
+  "net/http"
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/floormap"
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+const (
+  FloorMapSideBefore    = "before"
+  FloorMapSideAfter     = "after"
+
+  // floorMapVersion is part of every fingerprint; bump it when drawing
+  // changes so cached renders are replaced.
+  floorMapVersion       = "1"
+  // floorMapRackShare is how much of the aisle spacing a bay is drawn across.
+  floorMapRackShare     = 0.5
+  // floorMapPadding keeps the depot, docks and labels off the image edge.
+  floorMapPadding       = 1.5
+)
+
+// FloorMapRequest picks what a floor map shows. Format defaults to svg and
+// Heatmap to none. A scenario heatmap needs ScenarioID and shows Side
+// (after by default); VelocityRunID pins a velocity heatmap to one run
+// instead of the latest.
+type FloorMapRequest struct {
+  Format            types.FloorMapFormat      `json:"format"`
+  Heatmap           types.FloorMapHeatmap     `json:"heatmap"`
+  ScenarioID        *uuid.UUID                `json:"scenarioID,omitempty"`
+  Side              string                    `json:"side,omitempty"`
+  VelocityRunID     *uuid.UUID                `json:"velocityRunID,omitempty"`
+}
+
+// FloorMap is a rendered floor map and a signed URL the UI can embed until
+// ExpiresAt. Cached is set when an earlier render was reused.
+type FloorMap struct {
+  Render            *types.FloorMapRender     `json:"render"`
+  URL               string                    `json:"url"`
+  ExpiresAt         time.Time                 `json:"expiresAt"`
+  Cached            bool                      `json:"cached"`
+  Heat              *floormap.Scale           `json:"heat,omitempty"`
+}
+
+// FloorMapService draws a warehouse's layout with an optional heatmap and
+// keeps the renders in the bucket, keyed by everything they show.
+type FloorMapService interface {
+  RenderFloorMap(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, req FloorMapRequest) (*FloorMap, error)
+  renderFloorMapLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, req FloorMapRequest) (*FloorMap, error)
+}
+
+type floorMapService struct {
+  db                    *gorm.DB
+  log                   *logger.Logger
+  warehouseService      WarehouseService
+  bucketService         BucketService
+  locationRepo          repos.WarehouseLocationRepo
+  inventoryRepo         repos.InventoryRepo
+  itemRepo              repos.ItemRepo
+  velocityRepo          repos.VelocityRepo
+  slottingRepo          repos.SlottingRepo
+  scenarioRepo          repos.SlottingScenarioRepo
+  navigationRepo        repos.WarehouseNavigationRepo
+  floorMapRepo          repos.FloorMapRepo
+}
+
+func NewFloorMapService(
+  db                    *gorm.DB,
+  log                   *logger.Logger,
+  warehouseService      WarehouseService,
+  bucketService         BucketService,
+  locationRepo          repos.WarehouseLocationRepo,
+  inventoryRepo         repos.InventoryRepo,
+  itemRepo              repos.ItemRepo,
+  velocityRepo          repos.VelocityRepo,
+  slottingRepo          repos.SlottingRepo,
+  scenarioRepo          repos.SlottingScenarioRepo,
+  navigationRepo        repos.WarehouseNavigationRepo,
+  floorMapRepo          repos.FloorMapRepo,
+) FloorMapService {
+  serviceLog := log.With("service", "FloorMapService")
+  return &floorMapService{
+    db:               db,
+    log:              serviceLog,
+    warehouseService: warehouseService,
+    bucketService:    bucketService,
+    locationRepo:     locationRepo,
+    inventoryRepo:    inventoryRepo,
+    itemRepo:         itemRepo,
+    velocityRepo:     velocityRepo,
+    slottingRepo:     slottingRepo,
+    scenarioRepo:     scenarioRepo,
+    navigationRepo:   navigationRepo,
+    floorMapRepo:     floorMapRepo,
+  }
+}
+
+//----------------------------------------------------------------------------------------
+// Create
+//----------------------------------------------------------------------------------------
+
+// RenderFloorMap returns the floor map for req, rendering and uploading it
+// only when nothing drawn has changed since the last render.
+func (fs *floorMapService) RenderFloorMap(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, req FloorMapRequest) (*FloorMap, error) {
+  fs.log.Info("Starting RenderFloorMap now...", "warehouseID", warehouseID, "format", req.Format, "heatmap", req.Heatmap)
+  var result *FloorMap
+  if tx == nil {
+    if err := fs.db.WithContext(ctx).Transaction(func(tx2 *gorm.DB) error {
+      var err error
+      result, err = fs.renderFloorMapLogic(ctx, tx2, warehouseID, req)
+      return err
+    }); err != nil {
+      return nil, err
+    }
+    return result, nil
+  }
+  return fs.renderFloorMapLogic(ctx, tx, warehouseID, req)
+}
+
+func (fs *floorMapService) renderFloorMapLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, req FloorMapRequest) (*FloorMap, error) {
+  req, err := normalizeFloorMapRequest(req)
+  if err != nil {
+    return nil, err
+  }
+  warehouse, err := fs.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, err
+  }
+  fm, err := fs.buildFloorMap(ctx, tx, warehouse, req)
+  if err != nil {
+    return nil, err
+  }
+  fingerprint, err := floorMapFingerprint(fm, req.Format)
+  if err != nil {
+    return nil, err
+  }
+
+  render, err := fs.floorMapRepo.GetRenderByFingerprint(ctx, tx, warehouse.ID, fingerprint)
+  if err != nil {
+    return nil, fmt.Errorf("failed to look up floor map render: %w", err)
+  }
+  cached := render != nil
+  if render == nil {
+    var data []byte
+    switch req.Format {
+    case types.FloorMapPNG:
+      data, err = floormap.PNG(*fm)
+    default:
+      data, err = floormap.SVG(*fm)
+    }
+    if err != nil {
+      return nil, fmt.Errorf("failed to render floor map: %w", err)
+    }
+    render = &types.FloorMapRender{
+      WarehouseID: warehouse.ID,
+      CompanyID:   warehouse.CompanyID,
+      Fingerprint: fingerprint,
+      Variant:     floorMapVariant(req),
+      Format:      req.Format,
+      Heatmap:     req.Heatmap,
+      ScenarioID:  req.ScenarioID,
+      Side:        req.Side,
+      StorageKey:  PrivateObjectKey("company", warehouse.CompanyID, fmt.Sprintf("floor-maps/%s/%s.%s", warehouse.ID, fingerprint, req.Format)),
+      SizeBytes:   len(data),
+    }
+    if err := fs.bucketService.UploadFile(ctx, tx, render.StorageKey, bytes.NewReader(data)); err != nil {
+      return nil, fmt.Errorf("failed to upload floor map: %w", err)
+    }
+    if render, err = fs.floorMapRepo.CreateRender(ctx, tx, render); err != nil {
+      return nil, fmt.Errorf("failed to save floor map render: %w", err)
+    }
+    if render.ID == uuid.Nil {
+      // A concurrent request rendered the same map first; use its row.
+      if render, err = fs.floorMapRepo.GetRenderByFingerprint(ctx, tx, warehouse.ID, fingerprint); err != nil {
+        return nil, fmt.Errorf("failed to load floor map render: %w", err)
+      }
+      if render == nil {
+        return nil, fmt.Errorf("floor map render disappeared while saving")
+      }
+    } else if err := fs.pruneRenders(ctx, tx, render); err != nil {
+      return nil, err
+    }
+  }
+
+  signed, err := fs.bucketService.GetSignedURL(render.StorageKey, DefaultSignedURLTTL, http.MethodGet)
+  if err != nil {
+    return nil, fmt.Errorf("failed to sign floor map url: %w", err)
+  }
+  fs.log.Info("Floor map ready", "warehouseID", warehouse.ID, "renderID", render.ID, "cached", cached)
+  return &FloorMap{
+    Render:    render,
+    URL:       signed,
+    ExpiresAt: time.Now().Add(DefaultSignedURLTTL),
+    Cached:    cached,
+    Heat:      fm.Heat,
+  }, nil
+}
+
+//----------------------------------------------------------------------------------------
+// Helpers
+//----------------------------------------------------------------------------------------
+
+// pruneRenders drops the older renders of keep's variant. Their files are
+// removed on a best-effort basis; a leftover file is only wasted space.
+func (fs *floorMapService) pruneRenders(ctx context.Context, tx *gorm.DB, keep *types.FloorMapRender) error {
+  renders, err := fs.floorMapRepo.GetRendersByVariant(ctx, tx, keep.WarehouseID, keep.Variant)
+  if err != nil {
+    return fmt.Errorf("failed to load older floor map renders: %w", err)
+  }
+  var stale []uuid.UUID
+  for _, r := range renders {
+    if r.ID == keep.ID {
+      continue
+    }
+    stale = append(stale, r.ID)
+    if r.StorageKey != keep.StorageKey {
+      if err := fs.bucketService.DeleteFile(ctx, tx, r.StorageKey); err != nil {
+        fs.log.Warn("Failed to delete stale floor map", "key", r.StorageKey, "error", err)
+      }
+    }
+  }
+  if err := fs.floorMapRepo.FullDeleteRendersByIDs(ctx, tx, stale); err != nil {
+    return fmt.Errorf("failed to delete older floor map renders: %w", err)
+  }
+  return nil
+}
+
+// buildFloorMap lays the warehouse out as its navigation does, one cell per
+// bay (or per aisle without bays), and colours the cells by req.Heatmap.
+func (fs *floorMapService) buildFloorMap(ctx context.Context, tx *gorm.DB, warehouse *types.Warehouse, req FloorMapRequest) (*floormap.Map, error) {
+  var (
+    nav         *types.WarehouseNavigation
+    locations   []*types.WarehouseLocation
+    snap        *types.SlottingSnapshot
+    scenario    *types.SlottingScenario
+    assignments []*types.SlottingAssignment
+    err         error
+  )
+  switch req.Heatmap {
+  case types.FloorMapHeatmapScenario:
+    if scenario, assignments, err = fs.loadScenarioRun(ctx, tx, warehouse.ID, *req.ScenarioID); err != nil {
+      return nil, err
+    }
+    nav, locations = scenario.Snapshot.Navigation, scenario.Snapshot.Locations
+    if nav == nil {
+      if nav, _, err = loadNavigation(ctx, tx, warehouse.ID, warehouse.CompanyID, fs.navigationRepo); err != nil {
+        return nil, err
+      }
+    }
+  case types.FloorMapHeatmapVelocity, types.FloorMapHeatmapCube:
+    if snap, err = loadSlottingSnapshot(ctx, tx, warehouse.ID, warehouse.CompanyID, req.VelocityRunID, fs.locationRepo, fs.inventoryRepo, fs.itemRepo, fs.velocityRepo, fs.navigationRepo); err != nil {
+      return nil, err
+    }
+    if req.Heatmap == types.FloorMapHeatmapVelocity && snap.VelocityRunID == nil {
+      return nil, fmt.Errorf("warehouse has no completed velocity run")
+    }
+    nav, locations = snap.Navigation, snap.Locations
+  default:
+    if nav, _, err = loadNavigation(ctx, tx, warehouse.ID, warehouse.CompanyID, fs.navigationRepo); err != nil {
+      return nil, err
+    }
+    if locations, err = fs.locationRepo.GetByWarehouseID(ctx, tx, warehouse.ID, repos.LocationFilter{}); err != nil {
+      return nil, fmt.Errorf("failed to load locations: %w", err)
+    }
+  }
+
+  model, err := buildNavigationModel(nav, locations)
+  if err != nil {
+    return nil, err
+  }
+  fm, cellOf := floorMapLayout(nav, model, locations)
+  fm.Title = warehouse.Name
+
+  switch req.Heatmap {
+  case types.FloorMapHeatmapVelocity:
+    values := velocityHeat(snap, cellOf, len(fm.Cells))
+    fm.Heat = heatScale("Pick velocity", "picks/day", values)
+    setCellValues(fm, values)
+  case types.FloorMapHeatmapCube:
+    setCellValues(fm, cubeHeat(snap, cellOf, len(fm.Cells)))
+    fm.Heat = &floormap.Scale{Label: "Cube utilization", Unit: "%", Min: 0, Max: 100}
+  case types.FloorMapHeatmapScenario:
+    before := make([]*float64, len(fm.Cells))
+    after := make([]*float64, len(fm.Cells))
+    for i := range fm.Cells {
+      before[i], after[i] = new(float64), new(float64)
+    }
+    for _, a := range assignments {
+      if a.FromLocationID != nil {
+        if i, ok := cellOf[*a.FromLocationID]; ok {
+          *before[i] += a.PicksPerDay
+        }
+      }
+      if i, ok := cellOf[a.LocationID]; ok {
+        *after[i] += a.PicksPerDay
+      }
+    }
+    // Both sides share one scale so they can be compared side by side.
+    fm.Heat = heatScale(fmt.Sprintf("Pick velocity %s scenario %q", req.Side, scenario.Name), "picks/day", append(append([]*float64{}, before...), after...))
+    if req.Side == FloorMapSideBefore {
+      setCellValues(fm, before)
+    } else {
+      setCellValues(fm, after)
+    }
+  }
+  if fm.Heat != nil {
+    fm.Title += " - " + fm.Heat.Label
+  }
+  return fm, nil
+}
+
+// loadScenarioRun loads a scenario with the assignments of its completed
+// run.
+func (fs *floorMapService) loadScenarioRun(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, scenarioID uuid.UUID) (*types.SlottingScenario, []*types.SlottingAssignment, error) {
+  scenarios, err := fs.scenarioRepo.GetScenariosByIDs(ctx, tx, []uuid.UUID{scenarioID})
+  if err != nil {
+    return nil, nil, fmt.Errorf("failed to load slotting scenario: %w", err)
+  }
+  if len(scenarios) == 0 || scenarios[0].WarehouseID != warehouseID {
+    return nil, nil, fmt.Errorf("slotting scenario not found")
+  }
+  scenario := scenarios[0]
+  if scenario.JobID == nil {
+    return nil, nil, fmt.Errorf("scenario %q has not been run yet", scenario.Name)
+  }
+  jobs, err := fs.slottingRepo.GetJobsByIDs(ctx, tx, []uuid.UUID{*scenario.JobID})
+  if err != nil {
+    return nil, nil, fmt.Errorf("failed to load scenario run: %w", err)
+  }
+  if len(jobs) == 0 || jobs[0].Status != types.SlottingJobCompleted {
+    return nil, nil, fmt.Errorf("scenario %q has no completed run", scenario.Name)
+  }
+  assignments, err := fs.slottingRepo.GetAssignmentsByJobID(ctx, tx, jobs[0].ID)
+  if err != nil {
+    return nil, nil, fmt.Errorf("failed to load scenario assignments: %w", err)
+  }
+  return scenario, assignments, nil
+}
+
+func normalizeFloorMapRequest(req FloorMapRequest) (FloorMapRequest, error) {
+  switch req.Format {
+  case "":
+    req.Format = types.FloorMapSVG
+  case types.FloorMapSVG, types.FloorMapPNG:
+  default:
+    return req, fmt.Errorf("unknown floor map format %q", req.Format)
+  }
+  switch req.Heatmap {
+  case "":
+    req.Heatmap = types.FloorMapHeatmapNone
+  case types.FloorMapHeatmapNone, types.FloorMapHeatmapVelocity, types.FloorMapHeatmapCube, types.FloorMapHeatmapScenario:
+  default:
+    return req, fmt.Errorf("unknown heatmap %q", req.Heatmap)
+  }
+  if req.Heatmap != types.FloorMapHeatmapScenario {
+    if req.ScenarioID != nil || req.Side != "" {
+      return req, fmt.Errorf("scenarioID and side only apply to the scenario heatmap")
+    }
+  } else {
+    if req.ScenarioID == nil {
+      return req, fmt.Errorf("scenarioID is required for the scenario heatmap")
+    }
+    switch req.Side {
+    case "":
+      req.Side = FloorMapSideAfter
+    case FloorMapSideBefore, FloorMapSideAfter:
+    default:
+      return req, fmt.Errorf("side must be %q or %q", FloorMapSideBefore, FloorMapSideAfter)
+    }
+  }
+  if req.VelocityRunID != nil && req.Heatmap != types.FloorMapHeatmapVelocity {
+    return req, fmt.Errorf("velocityRunID only applies to the velocity heatmap")
+  }
+  return req, nil
+}
+
+// floorMapLayout turns a navigation model into map geometry. cellOf maps
+// every location inside a drawn bay or bay-less aisle to its cell.
+func floorMapLayout(nav *types.WarehouseNavigation, model *navigationModel, locations []*types.WarehouseLocation) (*floormap.Map, map[uuid.UUID]int) {
+  spacing := model.layout.Aisles[0].X
+  if len(model.layout.Aisles) > 1 {
+    spacing = model.layout.Aisles[1].X - model.layout.Aisles[0].X
+  }
+  if spacing <= 0 {
+    spacing = nav.AisleSpacingM
+  }
+  if spacing <= 0 {
+    spacing = types.DefaultAisleSpacingM
+  }
+  rack := spacing * floorMapRackShare
+
+  // Bounds of everything drawn, in navigation coordinates.
+  last := model.layout.Aisles[len(model.layout.Aisles)-1].X
+  minX, maxX := -spacing/2, last+spacing/2
+  minY, maxY := 0.0, model.layout.Length
+  points := []types.NavigationPoint{nav.Depot}
+  for _, d := range nav.Docks {
+    points = append(points, types.NavigationPoint{X: d.X, Y: d.Y})
+  }
+  for _, p := range points {
+    minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+    minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+  }
+  minX, minY = minX-floorMapPadding, minY-floorMapPadding
+  maxX, maxY = maxX+floorMapPadding, maxY+floorMapPadding
+  ox, oy := -minX, -minY
+
+  fm := &floormap.Map{Width: maxX - minX, Length: maxY - minY}
+  for _, y := range append([]float64{0, model.layout.Length}, model.layout.CrossAisles...) {
+    fm.CrossAisles = append(fm.CrossAisles, y+oy)
+  }
+  fm.Depot = &floormap.Marker{Name: "Depot", X: nav.Depot.X + ox, Y: nav.Depot.Y + oy}
+  for _, d := range nav.Docks {
+    fm.Docks = append(fm.Docks, floormap.Marker{Name: d.Name, X: d.X + ox, Y: d.Y + oy})
+  }
+
+  byID := make(map[uuid.UUID]*types.WarehouseLocation, len(locations))
+  var bays []*types.WarehouseLocation
+  for _, loc := range locations {
+    byID[loc.ID] = loc
+    if _, ok := model.baySpans[loc.ID]; ok {
+      bays = append(bays, loc)
+    }
+  }
+  sortBySequence(bays)
+  cellFor := map[uuid.UUID]int{}
+  hasBays := map[int]bool{}
+  for _, b := range bays {
+    i := model.aisleIndex[*b.ParentID]
+    span := model.baySpans[b.ID]
+    hasBays[i] = true
+    cellFor[b.ID] = len(fm.Cells)
+    fm.Cells = append(fm.Cells, floormap.Cell{
+      Label: b.Code,
+      Rect:  floormap.Rect{X: model.layout.Aisles[i].X - rack/2 + ox, Y: span[0] + oy, W: rack, H: span[1] - span[0]},
+    })
+  }
+
+  zones := map[uuid.UUID]*floormap.Zone{}
+  var zoneOrder []uuid.UUID
+  for i, a := range model.aisles {
+    x := model.layout.Aisles[i].X + ox
+    fm.Aisles = append(fm.Aisles, floormap.Aisle{
+      Name:      a.Code,
+      X:         x,
+      Y0:        oy,
+      Y1:        model.lengths[i] + oy,
+      Direction: string(model.layout.Aisles[i].Direction),
+    })
+    if !hasBays[i] {
+      cellFor[a.ID] = len(fm.Cells)
+      fm.Cells = append(fm.Cells, floormap.Cell{
+        Label: a.Code,
+        Rect:  floormap.Rect{X: x - rack/2, Y: oy, W: rack, H: model.lengths[i]},
+      })
+    }
+    for at := a; at.ParentID != nil; {
+      if at = byID[*at.ParentID]; at == nil {
+        break
+      }
+      if at.Kind != types.LocationKindZone {
+        continue
+      }
+      z := zones[at.ID]
+      if z == nil {
+        z = &floormap.Zone{Name: at.Code, Rect: floormap.Rect{X: x - spacing/2, Y: oy, W: spacing, H: model.lengths[i]}}
+        zones[at.ID] = z
+        zoneOrder = append(zoneOrder, at.ID)
+      }
+      right := math.Max(z.Rect.X+z.Rect.W, x+spacing/2)
+      z.Rect.X = math.Min(z.Rect.X, x-spacing/2)
+      z.Rect.W = right - z.Rect.X
+      z.Rect.H = math.Max(z.Rect.H, model.lengths[i])
+      break
+    }
+  }
+  for _, id := range zoneOrder {
+    z := *zones[id]
+    // Leave room above the aisles for the zone's name.
+    z.Rect.H += 1
+    fm.Zones = append(fm.Zones, z)
+  }
+
+  cellOf := map[uuid.UUID]int{}
+  for _, loc := range locations {
+    for at := loc; at != nil; {
+      if c, ok := cellFor[at.ID]; ok {
+        cellOf[loc.ID] = c
+        break
+      }
+      if at.ParentID == nil {
+        break
+      }
+      at = byID[*at.ParentID]
+    }
+  }
+  return fm, cellOf
+}
+
+// velocityHeat sums pick velocity per cell. An item stocked in several
+// locations has its picks split evenly between them.
+func velocityHeat(snap *types.SlottingSnapshot, cellOf map[uuid.UUID]int, cells int) []*float64 {
+  values := make([]*float64, cells)
+  for i := range values {
+    values[i] = new(float64)
+  }
+  picks := make(map[uuid.UUID]float64, len(snap.Velocity))
+  for _, v := range snap.Velocity {
+    picks[v.ItemID] = v.PicksPerDay
+  }
+  held := map[uuid.UUID]map[uuid.UUID]bool{}
+  for _, oh := range snap.OnHand {
+    if oh.Quantity <= 0 || picks[oh.ItemID] == 0 {
+      continue
+    }
+    if held[oh.ItemID] == nil {
+      held[oh.ItemID] = map[uuid.UUID]bool{}
+    }
+    held[oh.ItemID][oh.LocationID] = true
+  }
+  for itemID, locs := range held {
+    share := picks[itemID] / float64(len(locs))
+    for locID := range locs {
+      if i, ok := cellOf[locID]; ok {
+        *values[i] += share
+      }
+    }
+  }
+  return values
+}
+
+// cubeHeat is the share, in percent, of each cell's measured volume taken
+// up by its stock. Cells without measured locations have no value.
+func cubeHeat(snap *types.SlottingSnapshot, cellOf map[uuid.UUID]int, cells int) []*float64 {
+  items := make(map[uuid.UUID]*types.Item, len(snap.Items))
+  for _, it := range snap.Items {
+    items[it.ID] = it
+  }
+  hasChildren := map[uuid.UUID]bool{}
+  for _, loc := range snap.Locations {
+    if loc.ParentID != nil {
+      hasChildren[*loc.ParentID] = true
+    }
+  }
+  capacity := make([]float64, cells)
+  used := make([]float64, cells)
+  for _, loc := range snap.Locations {
+    if i, ok := cellOf[loc.ID]; ok && !hasChildren[loc.ID] {
+      capacity[i] += loc.WidthCm * loc.DepthCm * loc.HeightCm
+    }
+  }
+  for _, oh := range snap.OnHand {
+    it := items[oh.ItemID]
+    i, ok := cellOf[oh.LocationID]
+    if it == nil || !ok || oh.Quantity <= 0 {
+      continue
+    }
+    l, w, h, _ := eachDimensions(it)
+    used[i] += l * w * h * float64(oh.Quantity)
+  }
+  values := make([]*float64, cells)
+  for i := range values {
+    if capacity[i] > 0 {
+      v := math.Round(used[i]/capacity[i]*1000) / 10
+      values[i] = &v
+    }
+  }
+  return values
+}
+
+// heatScale spans 0 to the largest value, so an empty warehouse is all cold.
+func heatScale(label string, unit string, values []*float64) *floormap.Scale {
+  scale := &floormap.Scale{Label: label, Unit: unit, Max: 0}
+  for _, v := range values {
+    if v != nil && *v > scale.Max {
+      scale.Max = *v
+    }
+  }
+  if scale.Max == 0 {
+    scale.Max = 1
+  }
+  scale.Max = math.Ceil(scale.Max*100) / 100
+  return scale
+}
+
+func setCellValues(fm *floormap.Map, values []*float64) {
+  for i := range fm.Cells {
+    fm.Cells[i].Value = values[i]
+  }
+}
+
+// floorMapFingerprint hashes everything drawn, so equal maps share a render.
+func floorMapFingerprint(fm *floormap.Map, format types.FloorMapFormat) (string, error) {
+  data, err := json.Marshal(fm)
+  if err != nil {
+    return "", fmt.Errorf("failed to fingerprint floor map: %w", err)
+  }
+  h := sha256.New()
+  fmt.Fprintf(h, "%s:%s:", floorMapVersion, format)
+  h.Write(data)
+  return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func floorMapVariant(req FloorMapRequest) string {
+  variant := fmt.Sprintf("%s:%s", req.Format, req.Heatmap)
+  if req.ScenarioID != nil {
+    variant += fmt.Sprintf(":%s:%s", req.ScenarioID, req.Side)
+  }
+  if req.VelocityRunID != nil {
+    variant += fmt.Sprintf(":%s", req.VelocityRunID)
+  }
+  return variant
+}
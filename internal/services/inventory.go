@@ -0,0 +1,528 @@
+package services
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "sort"
+  "strings"
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/events"
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+const (
+  DefaultInventoryPageSize      = 100
+  MaxInventoryPageSize          = 1000
+  MaxTransactionsPerRequest     = 500
+  maxLotLength                  = 64
+)
+
+// ErrInsufficientStock is returned when a pick, move or negative adjustment
+// would take a bin below zero. Nothing of the batch is written.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// InventoryTransactionInput is one stock change. Quantity is counted in UOM
+// (eaches by default) and converted to eaches with the item's UOM hierarchy.
+// LocationID is the bin received into, adjusted, picked from or moved out
+// of; ToLocationID is only used by moves. Adjustments take a signed
+// Quantity, every other type a positive one.
+type InventoryTransactionInput struct {
+  Type            types.InventoryTransactionType  `json:"type"`
+  ItemID          uuid.UUID                       `json:"itemID"`
+  LocationID      uuid.UUID                       `json:"locationID"`
+  ToLocationID    *uuid.UUID                      `json:"toLocationID,omitempty"`
+  UOM             types.UOM                       `json:"uom,omitempty"`
+  Quantity        int                             `json:"quantity"`
+  Lot             string                          `json:"lot,omitempty"`
+  ExpiresAt       *time.Time                      `json:"expiresAt,omitempty"`
+  Reference       string                          `json:"reference,omitempty"`
+  Reason          string                          `json:"reason,omitempty"`
+}
+
+// InventoryFilter narrows ListOnHand. SKU is resolved to an item of the
+// warehouse's company; the other fields are passed to the repo as they are.
+type InventoryFilter struct {
+  repos.OnHandFilter
+  SKU             string
+}
+
+// InventoryPage is one page of ListOnHand.
+type InventoryPage struct {
+  Positions       []*types.InventoryOnHand        `json:"positions"`
+  Total           int64                           `json:"total"`
+  Limit           int                             `json:"limit"`
+  Offset          int                             `json:"offset"`
+}
+
+// LedgerPage is one page of ListLedger, newest first.
+type LedgerPage struct {
+  Entries         []*types.InventoryLedgerEntry   `json:"entries"`
+  Total           int64                           `json:"total"`
+  Limit           int                             `json:"limit"`
+  Offset          int                             `json:"offset"`
+}
+
+type InventoryService interface {
+  ListOnHand(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filter InventoryFilter) (*InventoryPage, error)
+  ListLedger(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filter repos.LedgerFilter) (*LedgerPage, error)
+  RecordTransactions(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, inputs []InventoryTransactionInput) ([]*types.InventoryLedgerEntry, error)
+  recordTransactionsLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, inputs []InventoryTransactionInput) ([]*types.InventoryLedgerEntry, error)
+}
+
+type inventoryService struct {
+  db                    *gorm.DB
+  log                   *logger.Logger
+  warehouseService      WarehouseService
+  locationRepo          repos.WarehouseLocationRepo
+  itemRepo              repos.ItemRepo
+  inventoryRepo         repos.InventoryRepo
+}
+
+func NewInventoryService(
+  db                    *gorm.DB,
+  log                   *logger.Logger,
+  warehouseService      WarehouseService,
+  locationRepo          repos.WarehouseLocationRepo,
+  itemRepo              repos.ItemRepo,
+  inventoryRepo         repos.InventoryRepo,
+) InventoryService {
+  serviceLog := log.With("service", "InventoryService")
+  return &inventoryService{
+    db:               db,
+    log:              serviceLog,
+    warehouseService: warehouseService,
+    locationRepo:     locationRepo,
+    itemRepo:         itemRepo,
+    inventoryRepo:    inventoryRepo,
+  }
+}
+
+//----------------------------------------------------------------------------------------
+// Read
+//----------------------------------------------------------------------------------------
+
+func (is *inventoryService) ListOnHand(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filter InventoryFilter) (*InventoryPage, error) {
+  is.log.Info("Starting ListOnHand now...", "warehouseID", warehouseID)
+  warehouse, err := is.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, err
+  }
+  filter.Limit, filter.Offset = clampPage(filter.Limit, filter.Offset, DefaultInventoryPageSize, MaxInventoryPageSize)
+  page := &InventoryPage{Positions: []*types.InventoryOnHand{}, Limit: filter.Limit, Offset: filter.Offset}
+  if sku := strings.TrimSpace(filter.SKU); sku != "" {
+    items, err := is.itemRepo.GetBySKUs(ctx, tx, warehouse.CompanyID, []string{sku})
+    if err != nil {
+      return nil, fmt.Errorf("failed to look up SKU: %w", err)
+    }
+    if len(items) == 0 {
+      return page, nil
+    }
+    if filter.ItemID != nil && *filter.ItemID != items[0].ID {
+      return page, nil
+    }
+    filter.ItemID = &items[0].ID
+  }
+  positions, total, err := is.inventoryRepo.SearchOnHand(ctx, tx, warehouseID, filter.OnHandFilter)
+  if err != nil {
+    is.log.Warn("Failed to search on-hand inventory", "error", err)
+    return nil, fmt.Errorf("failed to search inventory: %w", err)
+  }
+  page.Positions = positions
+  page.Total = total
+  return page, nil
+}
+
+func (is *inventoryService) ListLedger(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filter repos.LedgerFilter) (*LedgerPage, error) {
+  is.log.Info("Starting ListLedger now...", "warehouseID", warehouseID)
+  if filter.Type != "" && !isValidTransactionType(filter.Type) {
+    return nil, fmt.Errorf("invalid transaction type %q", filter.Type)
+  }
+  if _, err := is.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  filter.Limit, filter.Offset = clampPage(filter.Limit, filter.Offset, DefaultInventoryPageSize, MaxInventoryPageSize)
+  entries, total, err := is.inventoryRepo.SearchLedger(ctx, tx, warehouseID, filter)
+  if err != nil {
+    is.log.Warn("Failed to search inventory ledger", "error", err)
+    return nil, fmt.Errorf("failed to search inventory ledger: %w", err)
+  }
+  return &LedgerPage{Entries: entries, Total: total, Limit: filter.Limit, Offset: filter.Offset}, nil
+}
+
+//----------------------------------------------------------------------------------------
+// Write
+//----------------------------------------------------------------------------------------
+
+// RecordTransactions applies inputs as one batch: either every ledger entry is
+// written and every bin updated, or nothing is.
+func (is *inventoryService) RecordTransactions(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, inputs []InventoryTransactionInput) ([]*types.InventoryLedgerEntry, error) {
+  is.log.Info("Starting RecordTransactions now...", "warehouseID", warehouseID, "inputs", len(inputs))
+  if tx == nil {
+    var out []*types.InventoryLedgerEntry
+    err := is.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      res, err := is.recordTransactionsLogic(ctx, innerTx, warehouseID, inputs)
+      if err != nil {
+        return err
+      }
+      if err := recordInventoryChanged(ctx, innerTx, warehouseID, res); err != nil {
+        return err
+      }
+      out = res
+      return nil
+    })
+    if err != nil {
+      return nil, err
+    }
+    return out, nil
+  }
+  res, err := is.recordTransactionsLogic(ctx, tx, warehouseID, inputs)
+  if err != nil {
+    return nil, err
+  }
+  if err := recordInventoryChanged(ctx, tx, warehouseID, res); err != nil {
+    return nil, err
+  }
+  return res, nil
+}
+
+// recordInventoryChanged announces a batch recorded directly; stock moved
+// by a move task is announced with the task instead.
+func recordInventoryChanged(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, entries []*types.InventoryLedgerEntry) error {
+  if len(entries) == 0 {
+    return nil
+  }
+  return recordCompanyEvent(ctx, tx, entries[0].CompanyID, events.InventoryChanged, map[string]interface{}{
+    "warehouseID": warehouseID,
+    "entries":     entries,
+  })
+}
+
+// binKey identifies one on-hand row.
+type binKey struct {
+  locationID      uuid.UUID
+  itemID          uuid.UUID
+  lot             string
+}
+
+// binChange is one signed change to a bin, in eaches, before it is applied.
+type binChange struct {
+  key             binKey
+  groupID         uuid.UUID
+  input           *InventoryTransactionInput
+  delta           int
+}
+
+func (is *inventoryService) recordTransactionsLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, inputs []InventoryTransactionInput) ([]*types.InventoryLedgerEntry, error) {
+  if len(inputs) == 0 {
+    return nil, fmt.Errorf("no transactions provided")
+  }
+  if len(inputs) > MaxTransactionsPerRequest {
+    return nil, fmt.Errorf("too many transactions in one request (max %d)", MaxTransactionsPerRequest)
+  }
+  warehouse, err := is.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, err
+  }
+  var userID *uuid.UUID
+  if rd := requestdata.GetRequestData(ctx); rd != nil && rd.UserID != uuid.Nil {
+    id := rd.UserID
+    userID = &id
+  }
+
+  var locationIDs, itemIDs []uuid.UUID
+  for i := range inputs {
+    in := &inputs[i]
+    if err := normalizeTransactionInput(in); err != nil {
+      return nil, fmt.Errorf("transaction %d: %w", i+1, err)
+    }
+    locationIDs = append(locationIDs, in.LocationID)
+    if in.ToLocationID != nil {
+      locationIDs = append(locationIDs, *in.ToLocationID)
+    }
+    itemIDs = append(itemIDs, in.ItemID)
+  }
+  locations, err := is.locationRepo.GetByIDs(ctx, tx, uniqueUUIDs(locationIDs))
+  if err != nil {
+    return nil, fmt.Errorf("failed to load locations: %w", err)
+  }
+  locationByID := map[uuid.UUID]*types.WarehouseLocation{}
+  for _, l := range locations {
+    if l.WarehouseID == warehouseID {
+      locationByID[l.ID] = l
+    }
+  }
+  items, err := is.itemRepo.GetByIDs(ctx, tx, uniqueUUIDs(itemIDs))
+  if err != nil {
+    return nil, fmt.Errorf("failed to load items: %w", err)
+  }
+  itemByID := map[uuid.UUID]*types.Item{}
+  for _, it := range items {
+    if it.CompanyID == warehouse.CompanyID {
+      itemByID[it.ID] = it
+    }
+  }
+
+  // Turn every input into signed bin changes first, so the bins can be locked
+  // in a fixed order before anything is applied.
+  var changes []binChange
+  for i := range inputs {
+    in := &inputs[i]
+    if locationByID[in.LocationID] == nil {
+      return nil, fmt.Errorf("transaction %d: location %s not found in this warehouse", i+1, in.LocationID)
+    }
+    if in.ToLocationID != nil && locationByID[*in.ToLocationID] == nil {
+      return nil, fmt.Errorf("transaction %d: location %s not found in this warehouse", i+1, *in.ToLocationID)
+    }
+    item := itemByID[in.ItemID]
+    if item == nil {
+      return nil, fmt.Errorf("transaction %d: item %s not found for this warehouse's company", i+1, in.ItemID)
+    }
+    perUnit := 0
+    for _, u := range item.UOMs {
+      if u.UOM == in.UOM {
+        perUnit = u.Quantity
+      }
+    }
+    if perUnit == 0 {
+      return nil, fmt.Errorf("transaction %d: item %s has no %s UOM", i+1, item.SKU, in.UOM)
+    }
+    eaches := in.Quantity * perUnit
+    groupID := uuid.New()
+    from := binKey{locationID: in.LocationID, itemID: in.ItemID, lot: in.Lot}
+    switch in.Type {
+    case types.InventoryReceipt, types.InventoryAdjustment:
+      changes = append(changes, binChange{key: from, groupID: groupID, input: in, delta: eaches})
+    case types.InventoryPick:
+      changes = append(changes, binChange{key: from, groupID: groupID, input: in, delta: -eaches})
+    case types.InventoryMove:
+      to := binKey{locationID: *in.ToLocationID, itemID: in.ItemID, lot: in.Lot}
+      changes = append(changes,
+        binChange{key: from, groupID: groupID, input: in, delta: -eaches},
+        binChange{key: to, groupID: groupID, input: in, delta: eaches},
+      )
+    }
+  }
+
+  bins, order, err := is.lockBins(ctx, tx, warehouse, changes)
+  if err != nil {
+    return nil, err
+  }
+
+  var entries []*types.InventoryLedgerEntry
+  for i, ch := range changes {
+    bin := bins[ch.key]
+    if err := applyExpiry(bin, ch, changes, bins, i); err != nil {
+      return nil, err
+    }
+    next := bin.Quantity + ch.delta
+    if next < 0 {
+      sku := itemByID[ch.key.itemID].SKU
+      return nil, fmt.Errorf("%w: %s has %d of %s%s, cannot take %d",
+        ErrInsufficientStock, locationByID[ch.key.locationID].FullCode, bin.Quantity, sku, lotSuffix(ch.key.lot), -ch.delta)
+    }
+    bin.Quantity = next
+    entries = append(entries, &types.InventoryLedgerEntry{
+      GroupID:       ch.groupID,
+      WarehouseID:   warehouseID,
+      CompanyID:     warehouse.CompanyID,
+      LocationID:    ch.key.locationID,
+      ItemID:        ch.key.itemID,
+      UserID:        userID,
+      Type:          ch.input.Type,
+      Lot:           ch.key.lot,
+      ExpiresAt:     bin.ExpiresAt,
+      QuantityDelta: ch.delta,
+      QuantityAfter: next,
+      Reference:     ch.input.Reference,
+      Reason:        ch.input.Reason,
+    })
+  }
+
+  var keep []*types.InventoryOnHand
+  var empty []uuid.UUID
+  for _, key := range order {
+    bin := bins[key]
+    if bin.Quantity == 0 {
+      empty = append(empty, bin.ID)
+    } else {
+      keep = append(keep, bin)
+    }
+  }
+  if _, err := is.inventoryRepo.UpdateOnHand(ctx, tx, keep); err != nil {
+    is.log.Warn("Failed to update on-hand rows", "error", err)
+    return nil, fmt.Errorf("failed to update inventory: %w", err)
+  }
+  if err := is.inventoryRepo.FullDeleteOnHandByIDs(ctx, tx, empty); err != nil {
+    is.log.Warn("Failed to remove empty on-hand rows", "error", err)
+    return nil, fmt.Errorf("failed to update inventory: %w", err)
+  }
+  created, err := is.inventoryRepo.CreateLedgerEntries(ctx, tx, entries)
+  if err != nil {
+    is.log.Warn("Failed to write ledger entries", "error", err)
+    return nil, fmt.Errorf("failed to write inventory ledger: %w", err)
+  }
+  is.log.Info("Inventory transactions recorded", "warehouseID", warehouseID, "entries", len(created))
+  return created, nil
+}
+
+//----------------------------------------------------------------------------------------
+// Helpers
+//----------------------------------------------------------------------------------------
+
+// lockBins locks (creating where needed) every bin touched by changes, in
+// location, item, lot order so that concurrent batches cannot deadlock.
+func (is *inventoryService) lockBins(ctx context.Context, tx *gorm.DB, warehouse *types.Warehouse, changes []binChange) (map[binKey]*types.InventoryOnHand, []binKey, error) {
+  bins := map[binKey]*types.InventoryOnHand{}
+  var order []binKey
+  for _, ch := range changes {
+    if _, seen := bins[ch.key]; !seen {
+      bins[ch.key] = nil
+      order = append(order, ch.key)
+    }
+  }
+  sort.Slice(order, func(i, j int) bool {
+    a, b := order[i], order[j]
+    if a.locationID != b.locationID {
+      return a.locationID.String() < b.locationID.String()
+    }
+    if a.itemID != b.itemID {
+      return a.itemID.String() < b.itemID.String()
+    }
+    return a.lot < b.lot
+  })
+  for _, key := range order {
+    bin, err := is.inventoryRepo.GetOrCreateOnHandForUpdate(ctx, tx, &types.InventoryOnHand{
+      WarehouseID: warehouse.ID,
+      CompanyID:   warehouse.CompanyID,
+      LocationID:  key.locationID,
+      ItemID:      key.itemID,
+      Lot:         key.lot,
+    })
+    if err != nil {
+      is.log.Warn("Failed to lock on-hand row", "error", err)
+      return nil, nil, fmt.Errorf("failed to lock inventory: %w", err)
+    }
+    bins[key] = bin
+  }
+  return bins, order, nil
+}
+
+// applyExpiry keeps one expiry date per lot. A receipt or positive adjustment
+// may set it on an empty bin but must match an existing one; a move carries
+// the source bin's date over to the target.
+func applyExpiry(bin *types.InventoryOnHand, ch binChange, changes []binChange, bins map[binKey]*types.InventoryOnHand, idx int) error {
+  if ch.delta < 0 {
+    return nil
+  }
+  expires := ch.input.ExpiresAt
+  if ch.input.Type == types.InventoryMove && idx > 0 && changes[idx-1].groupID == ch.groupID {
+    expires = bins[changes[idx-1].key].ExpiresAt
+  }
+  if expires == nil {
+    return nil
+  }
+  if bin.ExpiresAt == nil || bin.Quantity == 0 {
+    e := expires.UTC()
+    bin.ExpiresAt = &e
+    return nil
+  }
+  if !bin.ExpiresAt.Equal(*expires) {
+    return fmt.Errorf("lot %q already expires on %s, got %s", ch.key.lot, bin.ExpiresAt.Format("2006-01-02"), expires.Format("2006-01-02"))
+  }
+  return nil
+}
+
+// normalizeTransactionInput validates in and fills its defaults.
+func normalizeTransactionInput(in *InventoryTransactionInput) error {
+  if !isValidTransactionType(in.Type) {
+    return fmt.Errorf("invalid transaction type %q, expected one of receipt, move, adjustment, pick", in.Type)
+  }
+  if in.ItemID == uuid.Nil {
+    return fmt.Errorf("itemID is required")
+  }
+  if in.LocationID == uuid.Nil {
+    return fmt.Errorf("locationID is required")
+  }
+  if in.Type == types.InventoryMove {
+    if in.ToLocationID == nil || *in.ToLocationID == uuid.Nil {
+      return fmt.Errorf("toLocationID is required for a move")
+    }
+    if *in.ToLocationID == in.LocationID {
+      return fmt.Errorf("cannot move to the same location")
+    }
+  } else if in.ToLocationID != nil {
+    return fmt.Errorf("toLocationID is only allowed for a move")
+  }
+  if in.Type == types.InventoryAdjustment {
+    if in.Quantity == 0 {
+      return fmt.Errorf("adjustment quantity cannot be zero")
+    }
+    if strings.TrimSpace(in.Reason) == "" {
+      return fmt.Errorf("an adjustment needs a reason")
+    }
+  } else if in.Quantity <= 0 {
+    return fmt.Errorf("quantity must be positive")
+  }
+  in.UOM = types.UOM(strings.ToLower(strings.TrimSpace(string(in.UOM))))
+  if in.UOM == "" {
+    in.UOM = types.UOMEach
+  }
+  in.Lot = strings.TrimSpace(in.Lot)
+  if len(in.Lot) > maxLotLength {
+    return fmt.Errorf("lot is longer than %d characters", maxLotLength)
+  }
+  if in.ExpiresAt != nil && in.Lot == "" {
+    return fmt.Errorf("an expiry date needs a lot")
+  }
+  in.Reference = strings.TrimSpace(in.Reference)
+  in.Reason = strings.TrimSpace(in.Reason)
+  return nil
+}
+
+func isValidTransactionType(t types.InventoryTransactionType) bool {
+  switch t {
+  case types.InventoryReceipt, types.InventoryMove, types.InventoryAdjustment, types.InventoryPick:
+    return true
+  }
+  return false
+}
+
+func lotSuffix(lot string) string {
+  if lot == "" {
+    return ""
+  }
+  return " (lot " + lot + ")"
+}
+
+func clampPage(limit, offset, def, max int) (int, int) {
+  if limit <= 0 {
+    limit = def
+  }
+  if limit > max {
+    limit = max
+  }
+  if offset < 0 {
+    offset = 0
+  }
+  return limit, offset
+}
+
+func uniqueUUIDs(ids []uuid.UUID) []uuid.UUID {
+  seen := map[uuid.UUID]bool{}
+  var out []uuid.UUID
+  for _, id := range ids {
+    if !seen[id] {
+      seen[id] = true
+      out = append(out, id)
+    }
+  }
+  return out
+}
@@ -2,20 +2,16 @@ package services
 
 import (
 	"context"
-	"encoding/base64"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 
+	"github.com/slotter-org/slotter-backend/internal/events"
 	"github.com/slotter-org/slotter-backend/internal/logger"
+	"github.com/slotter-org/slotter-backend/internal/normalization"
 	"github.com/slotter-org/slotter-backend/internal/requestdata"
-	"github.com/slotter-org/slotter-backend/internal/sse"
-	"github.com/slotter-org/slotter-backend/internal/ssedata"
 	"github.com/slotter-org/slotter-backend/internal/repos"
 	"github.com/slotter-org/slotter-backend/internal/templates"
 	"github.com/slotter-org/slotter-backend/internal/types"
@@ -24,7 +20,7 @@ import (
 type InvitationService interface {
 	SendInvitation(ctx context.Context, tx *gorm.DB, inv *types.Invitation) error
 	sendInvitationLogic(ctx context.Context, tx *gorm.DB, inv *types.Invitation) (*types.Invitation, error)
-	sendInvitationOutbound(ctx context.Context, inv *types.Invitation) error
+	enqueueInvitationOutbound(ctx context.Context, tx *gorm.DB, inv *types.Invitation) error
 	UpdateInvitation(ctx context.Context, tx *gorm.DB, invID uuid.UUID, newName,newMessage string) (*types.Invitation, error)
 	updateInvitationLogic(ctx context.Context, tx *gorm.DB, invID uuid.UUID, newName, newMessage string) (*types.Invitation, error) 
 	canUpdateInvitation(inv *types.Invitation) bool
@@ -41,7 +37,6 @@ type InvitationService interface {
 	ValidateInvitationToken(ctx context.Context, tx *gorm.DB, token string) (*types.Invitation, error)
 	ExpirePendingInvitations(ctx context.Context, tx *gorm.DB) (int64, error)
 	expirePendingInvitationsLogic(ctx context.Context, tx *gorm.DB) (int64, error)
-	getInvitationSSEChannel(inv *types.Invitation) string
 }
 
 type invitationService struct {
@@ -53,11 +48,9 @@ type invitationService struct {
 	companyRepo					repos.CompanyRepo
 	roleRepo						repos.RoleRepo
 	permissionRepo			repos.PermissionRepo
-	textService					TextService
-	emailService				EmailService
 	avatarService				AvatarService
-	brandLogoPath				string
-	frontEndURL					string
+	templateService			TemplateService
+	outboxService				OutboxService
 }
 
 func NewInvitationService(
@@ -69,31 +62,11 @@ func NewInvitationService(
 	companyRepo					repos.CompanyRepo,
 	roleRepo						repos.RoleRepo,
 	permissionRepo			repos.PermissionRepo,
-	textService					TextService,
-	emailService				EmailService,
 	avatarService				AvatarService,
+	templateService			TemplateService,
+	outboxService				OutboxService,
 ) InvitationService {
 	serviceLog := log.With("service", "InvitationService")
-	rawLogoPath := os.Getenv("SLOTTER_BRAND_LOGO_PATH")
-	var finalLogoBase64 string
-	if rawLogoPath != "" {
-		base64Logo, err := readFileAsBase64(rawLogoPath)
-		if err != nil {
-			serviceLog.Warn("Failed to read or encode brand logo from SLOTTER_LOGO_URL; using fallback HTTP link", "error", err)
-			finalLogoBase64 = "https://slotter.ai/slotter-logo.png"
-		} else {
-			finalLogoBase64 = base64Logo
-			serviceLog.Debug("Using base64-encoded brand logo from SLOTTER_BRAND_LOGO_PATH")
-		}
-	} else {
-		serviceLog.Warn("SLOTTER_BRAND_LOGO_PATH not set; using fallback HTTP link.")
-		finalLogoBase64 = "https://slotter.ai/slotter-logo.png"
-	}
-	frontEndURL := os.Getenv("SLOTTER_FRONT_END_URL")
-	if frontEndURL == "" {
-		frontEndURL = "https://www.slotter.ai"
-		serviceLog.Warn("SLOTTER_FRONT_END_URL not set; using faillback front end URL.")
-	}
 	return &invitationService{
 		db:								db,
 		log:							serviceLog,
@@ -103,43 +76,33 @@ func NewInvitationService(
 		companyRepo:			companyRepo,
 		roleRepo:					roleRepo,
 		permissionRepo:		permissionRepo,
-		emailService:			emailService,
-		textService:			textService,
 		avatarService:    avatarService,
-		brandLogoPath:		finalLogoBase64,
-		frontEndURL:			frontEndURL,
+		templateService:	templateService,
+		outboxService:		outboxService,
 	}
 }
 
 func (is *invitationService) SendInvitation(ctx context.Context, tx *gorm.DB, inv *types.Invitation) error {
-	// If tx is provided, just use it and do everything inline.
-	// Otherwise, create a new transaction block.
+	// If tx is provided, just use it and do everything inline. The outbound
+	// message is written to the outbox in the same tx and is delivered by the
+	// dispatcher once the caller commits.
 	if tx != nil {
-		// Do transaction-bound work inside the provided tx
-		finalInv, err := is.sendInvitationLogic(ctx, tx, inv)
-		if err != nil {
-			return err
-		}
-		// Now that DB changes are done, send the actual invitation (email or text) 
-		return is.sendInvitationOutbound(ctx, finalInv)
+		_, err := is.sendInvitationLogic(ctx, tx, inv)
+		return err
 	}
 
 	// No tx provided. Create our own.
-	var finalInv *types.Invitation
 	err := is.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
-		localInv, logicErr := is.sendInvitationLogic(ctx, innerTx, inv)
-		if logicErr != nil {
-			return logicErr
-		}
-		finalInv = localInv
-		return nil
+		_, logicErr := is.sendInvitationLogic(ctx, innerTx, inv)
+		return logicErr
 	})
 	if err != nil {
 		return err
 	}
 
-	// Post-transaction: send email or text
-	return is.sendInvitationOutbound(ctx, finalInv)
+	// Post-transaction: wake the outbox dispatcher
+	is.outboxService.Notify()
+	return nil
 }
 
 // sendInvitationLogic performs all the DB operations in the transaction:
@@ -148,6 +111,7 @@ func (is *invitationService) SendInvitation(ctx context.Context, tx *gorm.DB, in
 //  3) Creates the Invitation
 //  4) Generates/uploads the Invitation avatar
 //  5) Updates the Invitation record with the avatar fields
+//  6) Enqueues the outbound email or SMS in the outbox
 func (is *invitationService) sendInvitationLogic(ctx context.Context, tx *gorm.DB, inv *types.Invitation) (*types.Invitation, error) {
 
 	// 0) Basic nil checks
@@ -203,7 +167,14 @@ func (is *invitationService) sendInvitationLogic(ctx context.Context, tx *gorm.D
 		return nil, fmt.Errorf("user does not have permission to manage invitations")
 	}
 
-	// 2) Exactly one of Email or Phone must be set
+	// 2) Exactly one of Email or Phone must be set (phone is stored as E.164)
+	if inv.PhoneNumber != nil && *inv.PhoneNumber != "" {
+		normalizedPhone, pnErr := normalization.ParsePhoneNumber(*inv.PhoneNumber)
+		if pnErr != nil {
+			return nil, pnErr
+		}
+		inv.PhoneNumber = &normalizedPhone
+	}
 	var inviteMethod string
 	if inv.Email != nil && *inv.Email != "" && inv.PhoneNumber != nil && *inv.PhoneNumber != "" {
 		return nil, fmt.Errorf("cannot have both email and phone set for invitation")
@@ -325,119 +296,109 @@ func (is *invitationService) sendInvitationLogic(ctx context.Context, tx *gorm.D
 		return nil, fmt.Errorf("failed to update invitation with avatar: %w", upErr)
 	} 
 	final := updatedInvSlice[0]
-	channel := is.getInvitationSSEChannel(final)
-	if channel != "" {
-		ssd := ssedata.GetSSEData(ctx)
-		if ssd != nil {
-			ssd.AppendMessage(sse.SSEMessage{
-				Channel: channel,
-				Event: sse.SSEEventInvitationCreated,
-				Data: final,
-			})
-		}
+	if err := events.RecordTx(ctx, tx, invitationEvent(events.InvitationCreated, final)); err != nil {
+		return nil, err
+	}
+	if err := is.enqueueInvitationOutbound(ctx, tx, final); err != nil {
+		return nil, err
 	}
 	return final, nil
 }
 
-// sendInvitationOutbound is called *after* the transaction commits
-// to send the actual invitation (email or SMS).
-func (is *invitationService) sendInvitationOutbound(ctx context.Context, inv *types.Invitation) error {
+// enqueueInvitationOutbound renders the invitation and writes it to the
+// outbox inside tx, so nothing is sent unless the invitation commits.
+func (is *invitationService) enqueueInvitationOutbound(ctx context.Context, tx *gorm.DB, inv *types.Invitation) error {
 	// Build the link
-	linkURL := fmt.Sprintf("%s/register?token=%s", is.frontEndURL, inv.Token)
+	linkURL := fmt.Sprintf("%s/register?token=%s", is.templateService.FrontEndURL(), inv.Token)
 
-	// Determine which contact method to use
-	var inviteMethod string
+	rendered, err := is.renderInvitation(ctx, tx, inv, linkURL)
+	if err != nil {
+		is.log.Warn("Failed to render invitation template", "error", err)
+		return err
+	}
+
+	msg := &types.OutboxMessage{
+		WmsID:       inv.WmsID,
+		CompanyID:   inv.CompanyID,
+		MessageType: string(templates.MessageTypeInvitation),
+		SourceType:  "invitation",
+		SourceID:    &inv.ID,
+	}
 	if inv.Email != nil && *inv.Email != "" {
-		inviteMethod = "email"
+		msg.Channel = types.OutboxChannelEmail
+		msg.Recipient = *inv.Email
+		msg.Subject = rendered.Subject
+		msg.Body = rendered.Text
+		msg.HTML = rendered.HTML
 	} else if inv.PhoneNumber != nil && *inv.PhoneNumber != "" {
-		inviteMethod = "phone"
+		msg.Channel = types.OutboxChannelSMS
+		msg.Recipient = *inv.PhoneNumber
+		msg.Body = rendered.SMS
 	} else {
 		// Edge case: no contact info
 		return fmt.Errorf("invitation has no email or phone set")
 	}
 
-	// If using email, build and send
-	if inviteMethod == "email" {
-		var finalAvatarURL string
-
-		if inv.WmsID != nil && *inv.WmsID != uuid.Nil {
-			foundWms, _ := is.wmsRepo.GetByIDs(ctx, nil, []uuid.UUID{*inv.WmsID})
-			if len(foundWms) > 0 {
-				finalAvatarURL = foundWms[0].AvatarURL
-			}
-		}
-		if inv.CompanyID != nil && *inv.CompanyID != uuid.Nil {
-			foundCompany, _ := is.companyRepo.GetByIDs(ctx, nil, []uuid.UUID{*inv.CompanyID})
-			if len(foundCompany) > 0 {
-				finalAvatarURL = foundCompany[0].AvatarURL
-			}
-		}
+	if _, err := is.outboxService.Enqueue(ctx, tx, []*types.OutboxMessage{msg}); err != nil {
+		is.log.Warn("Failed to enqueue invitation message", "error", err)
+		return err
+	}
+	return nil
+}
 
-		templateData := templates.InvitationEmailData{
-			Logo:           is.brandLogoPath,
-			InvitationLink: linkURL,
-			AvatarURL:      finalAvatarURL,
-			InvitationType: templates.InvitationType(string(inv.InvitationType)),
-			WmsName:        "",
-			CompanyName:    "",
-		}
-		// Optionally fill WmsName/CompanyName for better email rendering
-		if inv.WmsID != nil && *inv.WmsID != uuid.Nil {
-			foundWms, _ := is.wmsRepo.GetByIDs(ctx, nil, []uuid.UUID{*inv.WmsID})
-			if len(foundWms) > 0 {
-				templateData.WmsName = foundWms[0].Name
-			}
-		}
-		if inv.CompanyID != nil && *inv.CompanyID != uuid.Nil {
-			foundCompany, _ := is.companyRepo.GetByIDs(ctx, nil, []uuid.UUID{*inv.CompanyID})
-			if len(foundCompany) > 0 {
-				templateData.CompanyName = foundCompany[0].Name
-			}
+// renderInvitation resolves the tenant branding and locale for inv and renders
+// every part of the invitation message. Locale preference is the invitation's
+// own locale, then the inviting user's, then the company's, then the wms's.
+func (is *invitationService) renderInvitation(ctx context.Context, tx *gorm.DB, inv *types.Invitation, linkURL string) (*templates.RenderedMessage, error) {
+	details := templates.InvitationDetails{
+		InvitationType: templates.InvitationType(string(inv.InvitationType)),
+		AvatarURL:      inv.AvatarURL,
+	}
+	if inv.WmsID != nil && *inv.WmsID != uuid.Nil {
+		foundWms, _ := is.wmsRepo.GetByIDs(ctx, tx, []uuid.UUID{*inv.WmsID})
+		if len(foundWms) > 0 {
+			details.WmsName = foundWms[0].Name
+			details.AvatarURL = foundWms[0].AvatarURL
 		}
-
-		htmlContent, tplErr := templates.RenderInvitationHTML(templateData)
-		if tplErr != nil {
-			is.log.Warn("Failed to render invitation HTML template", "error", tplErr)
-			return tplErr
+	}
+	if inv.CompanyID != nil && *inv.CompanyID != uuid.Nil {
+		foundCompany, _ := is.companyRepo.GetByIDs(ctx, tx, []uuid.UUID{*inv.CompanyID})
+		if len(foundCompany) > 0 {
+			details.CompanyName = foundCompany[0].Name
+			details.AvatarURL = foundCompany[0].AvatarURL
 		}
-		plainText := fmt.Sprintf("You have been invited to join Slotter! Click here: %s", linkURL)
-		subject := "You've Been Invited to Slotter!"
+	}
+	if inv.Message != nil {
+		details.Message = *inv.Message
+	}
 
-		if sendErr := is.emailService.SendEmail(ctx, *inv.Email, subject, plainText, htmlContent, "invitation"); sendErr != nil {
-			is.log.Warn("Failed to send invitation email", "error", sendErr)
-			return sendErr
+	var preferred []string
+	if inv.Locale != nil {
+		preferred = append(preferred, *inv.Locale)
+	}
+	if inv.InviteUserID != uuid.Nil {
+		foundUsers, _ := is.userRepo.GetByIDs(ctx, tx, []uuid.UUID{inv.InviteUserID})
+		if len(foundUsers) > 0 {
+			preferred = append(preferred, foundUsers[0].Locale)
 		}
-		return nil
 	}
+	locale := is.templateService.ResolveLocale(ctx, tx, inv.WmsID, inv.CompanyID, preferred...)
 
-	// Otherwise, phone
-	textBody := fmt.Sprintf("Slotter invitation! Click here: %s", linkURL)
-	if err := is.textService.SendText(ctx, *inv.PhoneNumber, textBody); err != nil {
-		is.log.Warn("Failed to send invitation text", "error", err)
-		return err
+	data := templates.MessageData{
+		Brand:      is.templateService.ResolveBranding(ctx, tx, inv.WmsID, inv.CompanyID),
+		ActionLink: linkURL,
+		Year:       time.Now().Year(),
+		Invitation: details,
 	}
-	return nil
-}
-
-func readFileAsBase64(path string) (string, error) {
-	data, err := ioutil.ReadFile(path)
-	if err != nil {
-		return "", err
-	}
-	ext := filepath.Ext(path)
-	var mimeType string
-	switch ext {
-	case ".png":
-		mimeType = "image/png"
-	case ".jpg", ".jpeg":
-		mimeType = "image/jpeg"
-	case ".svg":
-		mimeType = "image/svg+xml"
-	default:
-		mimeType = "image/png"
+	if inv.Name != nil {
+		data.RecipientName = *inv.Name
 	}
-	encoded := base64.StdEncoding.EncodeToString(data)
-	return "data:" + mimeType + ";base64," + encoded, nil
+	if !inv.ExpiresAt.IsZero() {
+		if hours := int(time.Until(inv.ExpiresAt).Hours()); hours > 0 {
+			data.ExpiresInHours = hours
+		}
+	}
+	return is.templateService.Render(ctx, templates.MessageTypeInvitation, locale, data)
 }
 
 func (is *invitationService) UpdateInvitation(ctx context.Context, tx *gorm.DB, invID uuid.UUID, newName, newMessage string) (*types.Invitation, error) {
@@ -482,16 +443,8 @@ func (is *invitationService) updateInvitationLogic(ctx context.Context, tx *gorm
 		return nil, fmt.Errorf("failed to update invitation: %w", err)
 	}
 	final := updated[0]
-	channel := is.getInvitationSSEChannel(final)
-	if channel != "" {
-		ssd := ssedata.GetSSEData(ctx)
-		if ssd != nil {
-			ssd.AppendMessage(sse.SSEMessage{
-				Channel: channel,
-				Event: sse.SSEEventInvitationUpdated,
-				Data: final,
-			})
-		}
+	if err := events.RecordTx(ctx, tx, invitationEvent(events.InvitationUpdated, final)); err != nil {
+		return nil, err
 	}
 	return final, nil
 }
@@ -567,16 +520,8 @@ func (is *invitationService) updateInvitationRoleLogic(ctx context.Context, tx *
 		return nil, fmt.Errorf("failed to update invitation role: %w", upErr)
 	}
 	final := updated[0]
-	channel := is.getInvitationSSEChannel(final)
-	if channel != "" {
-		ssd := ssedata.GetSSEData(ctx)
-		if ssd != nil {
-			ssd.AppendMessage(sse.SSEMessage{
-				Channel: channel,
-				Event: sse.SSEEventInvitationUpdated,
-				Data: final,
-			})
-		}
+	if err := events.RecordTx(ctx, tx, invitationEvent(events.InvitationUpdated, final)); err != nil {
+		return nil, err
 	}
 	return final, nil
 }
@@ -621,16 +566,8 @@ func (is *invitationService) cancelInvitationLogic(ctx context.Context, tx *gorm
 		return nil, fmt.Errorf("failed to update invitation as canceled: %w", upErr)
 	}
 	final := updated[0]
-	channel := is.getInvitationSSEChannel(final)
-	if channel != "" {
-		ssd := ssedata.GetSSEData(ctx)
-		if ssd != nil {
-			ssd.AppendMessage(sse.SSEMessage{
-				Channel: channel,
-				Event: sse.SSEEventInvitationCanceled,
-				Data: final,
-			})
-		}
+	if err := events.RecordTx(ctx, tx, invitationEvent(events.InvitationCanceled, final)); err != nil {
+		return nil, err
 	}
 	return final, nil
 }
@@ -644,18 +581,8 @@ func (is *invitationService) canCancelInvitation(inv *types.Invitation) bool {
 
 func (is *invitationService) ResendInvitation(ctx context.Context, tx *gorm.DB, invID uuid.UUID) (*types.Invitation, error) {
 	if tx != nil {
-		// Use the provided transaction
-		finalInv, err := is.resendInvitationLogic(ctx, tx, invID)
-		if err != nil {
-			return nil, err
-		}
-		// After the transaction logic, send the outbound invitation
-		outErr := is.sendInvitationOutbound(ctx, finalInv)
-		if outErr != nil {
-			is.log.Warn("Failed to resend invitation outbound", "error", outErr)
-			return finalInv, outErr
-		}
-		return finalInv, nil
+		// Use the provided transaction; the outbound message is enqueued in it.
+		return is.resendInvitationLogic(ctx, tx, invID)
 	}
 
 	// No transaction provided, so start our own.
@@ -671,12 +598,8 @@ func (is *invitationService) ResendInvitation(ctx context.Context, tx *gorm.DB,
 	if err != nil {
 		return nil, err
 	}
-	// Now that DB changes have committed, do the outbound send.
-	outErr := is.sendInvitationOutbound(ctx, finalInv)
-	if outErr != nil {
-		is.log.Warn("Failed to resend invitation outbound", "error", outErr)
-		return finalInv, outErr
-	}
+	// Now that DB changes have committed, wake the outbox dispatcher.
+	is.outboxService.Notify()
 	return finalInv, nil
 }
 
@@ -714,16 +637,11 @@ func (is *invitationService) resendInvitationLogic(ctx context.Context, tx *gorm
 	final := updated[0]
 
 	// SSE event inside the transaction
-	channel := is.getInvitationSSEChannel(final)
-	if channel != "" {
-		ssd := ssedata.GetSSEData(ctx)
-		if ssd != nil {
-			ssd.AppendMessage(sse.SSEMessage{
-				Channel: channel,
-				Event:   sse.SSEEventInvitationResent,
-				Data: final,
-			})
-		}
+	if err := events.RecordTx(ctx, tx, invitationEvent(events.InvitationResent, final)); err != nil {
+		return nil, err
+	}
+	if err := is.enqueueInvitationOutbound(ctx, tx, final); err != nil {
+		return nil, err
 	}
 	return final, nil
 }
@@ -752,18 +670,7 @@ func (is *invitationService) deleteInvitationLogic(ctx context.Context, tx *gorm
 	if err := is.invitationRepo.SoftDeleteByInvitations(ctx, tx, []*types.Invitation{inv}); err != nil {
 		return err
 	}
-	channel := is.getInvitationSSEChannel(inv)
-	if channel != "" {
-		ssd := ssedata.GetSSEData(ctx)
-		if ssd != nil {
-			ssd.AppendMessage(sse.SSEMessage{
-				Channel: channel,
-				Event: sse.SSEEventInvitationDeleted,
-				Data: inv,
-			})
-		}
-	}
-	return nil
+	return events.RecordTx(ctx, tx, invitationEvent(events.InvitationDeleted, inv))
 }
 
 func (is *invitationService) canDeleteInvitation(inv *types.Invitation) bool {
@@ -844,12 +751,12 @@ func (is *invitationService) expirePendingInvitationsLogic(ctx context.Context,
 	return is.invitationRepo.BulkExpireInvitations(ctx, tx)
 }
 
-func (is *invitationService) getInvitationSSEChannel(inv *types.Invitation) string {
+// invitationEvent routes an invitation event to the wms that sent it, or
+// else to its company.
+func invitationEvent(t events.Type, inv *types.Invitation) events.Event {
+	ev := events.New(t, inv)
 	if inv.WmsID != nil && *inv.WmsID != uuid.Nil {
-		return "wms:" + inv.WmsID.String()
-	}
-	if inv.CompanyID != nil && *inv.CompanyID != uuid.Nil {
-		return "company:" + inv.CompanyID.String()
+		return ev.ForWms(*inv.WmsID)
 	}
-	return ""
+	return ev.ForTenant(nil, inv.CompanyID)
 }
@@ -0,0 +1,540 @@
+package services
+
+import (
+  "context"
+  "fmt"
+  "io"
+  "regexp"
+  "strings"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/events"
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/normalization"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+const (
+  DefaultItemPageSize   = 50
+  MaxItemPageSize       = 500
+  maxSKULength          = 64
+)
+
+// hazmatClassPattern matches UN dangerous goods classes and divisions,
+// 1 to 9 with an optional ".1" to ".6".
+var hazmatClassPattern = regexp.MustCompile(`^[1-9](\.[1-6])?$`)
+
+// ItemUOMInput is one packaging level of an ItemInput.
+type ItemUOMInput struct {
+  UOM             types.UOM               `json:"uom"`
+  Quantity        int                     `json:"quantity"`
+  GTIN            string                  `json:"gtin,omitempty"`
+  LengthCm        float64                 `json:"lengthCm"`
+  WidthCm         float64                 `json:"widthCm"`
+  HeightCm        float64                 `json:"heightCm"`
+  WeightKg        float64                 `json:"weightKg"`
+}
+
+// ItemInput is the full description of an item used for both create and
+// update. Stackable defaults to true and TemperatureClass to ambient.
+type ItemInput struct {
+  SKU               string                  `json:"sku"`
+  Description       string                  `json:"description"`
+  HazmatClass       string                  `json:"hazmatClass,omitempty"`
+  TemperatureClass  types.TemperatureClass  `json:"temperatureClass,omitempty"`
+  Stackable         *bool                   `json:"stackable,omitempty"`
+  MaxStackHeight    int                     `json:"maxStackHeight,omitempty"`
+  UOMs              []ItemUOMInput          `json:"uoms"`
+}
+
+// ItemPage is one page of ListItems.
+type ItemPage struct {
+  Items           []*types.Item           `json:"items"`
+  Total           int64                   `json:"total"`
+  Limit           int                     `json:"limit"`
+  Offset          int                     `json:"offset"`
+}
+
+// GTINMatch is the result of a barcode lookup: the item and the packaging
+// level the barcode is printed on.
+type GTINMatch struct {
+  Item            *types.Item             `json:"item"`
+  UOM             *types.ItemUOM          `json:"uom"`
+}
+
+type ItemService interface {
+  ListItems(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, query string, limit int, offset int) (*ItemPage, error)
+  GetItem(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, itemID uuid.UUID) (*types.Item, error)
+  LookupGTIN(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, code string) (*GTINMatch, error)
+  CreateItem(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, input ItemInput) (*types.Item, error)
+  createItemLogic(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, input ItemInput) (*types.Item, error)
+  UpdateItem(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, itemID uuid.UUID, input ItemInput) (*types.Item, error)
+  updateItemLogic(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, itemID uuid.UUID, input ItemInput) (*types.Item, error)
+  DeleteItem(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, itemID uuid.UUID) (*types.Item, error)
+  deleteItemLogic(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, itemID uuid.UUID) (*types.Item, error)
+  ImportItemsCSV(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, file io.Reader, dryRun bool) (*ItemImportReport, error)
+  importItemsLogic(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, rows []itemCSVRow, rowErrs []ItemImportError, dryRun bool) (*ItemImportReport, error)
+  ExportItemsCSV(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, w io.Writer) error
+}
+
+type itemService struct {
+  db              *gorm.DB
+  log             *logger.Logger
+  companyRepo     repos.CompanyRepo
+  itemRepo        repos.ItemRepo
+}
+
+func NewItemService(
+  db              *gorm.DB,
+  log             *logger.Logger,
+  companyRepo     repos.CompanyRepo,
+  itemRepo        repos.ItemRepo,
+) ItemService {
+  serviceLog := log.With("service", "ItemService")
+  return &itemService{
+    db:           db,
+    log:          serviceLog,
+    companyRepo:  companyRepo,
+    itemRepo:     itemRepo,
+  }
+}
+
+//----------------------------------------------------------------------------------------
+// Read
+//----------------------------------------------------------------------------------------
+
+func (is *itemService) ListItems(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, query string, limit int, offset int) (*ItemPage, error) {
+  is.log.Info("Starting ListItems now...", "companyID", companyID, "query", query)
+  if _, err := is.authorizedCompany(ctx, tx, companyID); err != nil {
+    return nil, err
+  }
+  if limit <= 0 {
+    limit = DefaultItemPageSize
+  }
+  if limit > MaxItemPageSize {
+    limit = MaxItemPageSize
+  }
+  if offset < 0 {
+    offset = 0
+  }
+  items, total, err := is.itemRepo.Search(ctx, tx, companyID, query, limit, offset)
+  if err != nil {
+    is.log.Warn("Failed to search items", "error", err)
+    return nil, fmt.Errorf("failed to search items: %w", err)
+  }
+  return &ItemPage{Items: items, Total: total, Limit: limit, Offset: offset}, nil
+}
+
+func (is *itemService) GetItem(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, itemID uuid.UUID) (*types.Item, error) {
+  is.log.Info("Starting GetItem now...", "companyID", companyID, "itemID", itemID)
+  if _, err := is.authorizedCompany(ctx, tx, companyID); err != nil {
+    return nil, err
+  }
+  return is.loadItem(ctx, tx, companyID, itemID)
+}
+
+// LookupGTIN finds the item whose UPC/EAN/GTIN matches code.
+func (is *itemService) LookupGTIN(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, code string) (*GTINMatch, error) {
+  is.log.Info("Starting LookupGTIN now...", "companyID", companyID, "code", code)
+  gtin, err := normalization.ParseGTIN(code)
+  if err != nil {
+    return nil, err
+  }
+  if _, err := is.authorizedCompany(ctx, tx, companyID); err != nil {
+    return nil, err
+  }
+  uoms, err := is.itemRepo.GetUOMsByGTINs(ctx, tx, companyID, []string{gtin})
+  if err != nil {
+    return nil, fmt.Errorf("failed to look up GTIN: %w", err)
+  }
+  if len(uoms) == 0 {
+    return nil, fmt.Errorf("no item found for GTIN %s", gtin)
+  }
+  item, err := is.loadItem(ctx, tx, companyID, uoms[0].ItemID)
+  if err != nil {
+    return nil, err
+  }
+  return &GTINMatch{Item: item, UOM: uoms[0]}, nil
+}
+
+//----------------------------------------------------------------------------------------
+// Create
+//----------------------------------------------------------------------------------------
+
+func (is *itemService) CreateItem(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, input ItemInput) (*types.Item, error) {
+  is.log.Info("Starting CreateItem now...", "companyID", companyID, "sku", input.SKU)
+  if tx == nil {
+    var out *types.Item
+    err := is.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      res, err := is.createItemLogic(ctx, innerTx, companyID, input)
+      if err != nil {
+        return err
+      }
+      out = res
+      return nil
+    })
+    if err != nil {
+      return nil, err
+    }
+    return out, nil
+  }
+  return is.createItemLogic(ctx, tx, companyID, input)
+}
+
+func (is *itemService) createItemLogic(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, input ItemInput) (*types.Item, error) {
+  if _, err := is.authorizedCompany(ctx, tx, companyID); err != nil {
+    return nil, err
+  }
+  item, err := buildItem(companyID, input)
+  if err != nil {
+    return nil, err
+  }
+  existing, err := is.itemRepo.GetBySKUs(ctx, tx, companyID, []string{item.SKU})
+  if err != nil {
+    return nil, fmt.Errorf("failed to check SKU: %w", err)
+  }
+  if len(existing) > 0 {
+    return nil, fmt.Errorf("SKU %s already exists", item.SKU)
+  }
+  if err := is.checkGTINsFree(ctx, tx, companyID, uuid.Nil, item.UOMs); err != nil {
+    return nil, err
+  }
+  created, err := is.itemRepo.Create(ctx, tx, []*types.Item{item})
+  if err != nil {
+    is.log.Warn("Failed to create item", "error", err)
+    return nil, fmt.Errorf("failed to create item: %w", err)
+  }
+  if len(created) == 0 {
+    return nil, fmt.Errorf("item creation returned empty result")
+  }
+  if err := recordCompanyEvent(ctx, tx, companyID, events.ItemCreated, created[0]); err != nil {
+    return nil, err
+  }
+  return created[0], nil
+}
+
+//----------------------------------------------------------------------------------------
+// Update
+//----------------------------------------------------------------------------------------
+
+func (is *itemService) UpdateItem(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, itemID uuid.UUID, input ItemInput) (*types.Item, error) {
+  is.log.Info("Starting UpdateItem now...", "companyID", companyID, "itemID", itemID)
+  if tx == nil {
+    var out *types.Item
+    err := is.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      res, err := is.updateItemLogic(ctx, innerTx, companyID, itemID, input)
+      if err != nil {
+        return err
+      }
+      out = res
+      return nil
+    })
+    if err != nil {
+      return nil, err
+    }
+    return out, nil
+  }
+  return is.updateItemLogic(ctx, tx, companyID, itemID, input)
+}
+
+// updateItemLogic replaces the item, including its UOMs, with input.
+func (is *itemService) updateItemLogic(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, itemID uuid.UUID, input ItemInput) (*types.Item, error) {
+  if _, err := is.authorizedCompany(ctx, tx, companyID); err != nil {
+    return nil, err
+  }
+  item, err := is.loadItem(ctx, tx, companyID, itemID)
+  if err != nil {
+    return nil, err
+  }
+  next, err := buildItem(companyID, input)
+  if err != nil {
+    return nil, err
+  }
+  if next.SKU != item.SKU {
+    existing, err := is.itemRepo.GetBySKUs(ctx, tx, companyID, []string{next.SKU})
+    if err != nil {
+      return nil, fmt.Errorf("failed to check SKU: %w", err)
+    }
+    if len(existing) > 0 {
+      return nil, fmt.Errorf("SKU %s already exists", next.SKU)
+    }
+  }
+  if err := is.checkGTINsFree(ctx, tx, companyID, item.ID, next.UOMs); err != nil {
+    return nil, err
+  }
+  saved, err := is.saveItem(ctx, tx, item, next)
+  if err != nil {
+    return nil, err
+  }
+  if err := recordCompanyEvent(ctx, tx, companyID, events.ItemUpdated, saved); err != nil {
+    return nil, err
+  }
+  return saved, nil
+}
+
+// saveItem copies next's fields onto item and stores it with its new UOMs.
+func (is *itemService) saveItem(ctx context.Context, tx *gorm.DB, item *types.Item, next *types.Item) (*types.Item, error) {
+  item.SKU = next.SKU
+  item.Description = next.Description
+  item.HazmatClass = next.HazmatClass
+  item.TemperatureClass = next.TemperatureClass
+  item.Stackable = next.Stackable
+  item.MaxStackHeight = next.MaxStackHeight
+  if _, err := is.itemRepo.Update(ctx, tx, []*types.Item{item}); err != nil {
+    is.log.Warn("Failed to update item", "error", err)
+    return nil, fmt.Errorf("failed to update item: %w", err)
+  }
+  uoms, err := is.itemRepo.ReplaceUOMs(ctx, tx, item, next.UOMs)
+  if err != nil {
+    is.log.Warn("Failed to update item UOMs", "error", err)
+    return nil, fmt.Errorf("failed to update item UOMs: %w", err)
+  }
+  item.UOMs = uoms
+  return item, nil
+}
+
+//----------------------------------------------------------------------------------------
+// Delete
+//----------------------------------------------------------------------------------------
+
+func (is *itemService) DeleteItem(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, itemID uuid.UUID) (*types.Item, error) {
+  is.log.Info("Starting DeleteItem now...", "companyID", companyID, "itemID", itemID)
+  if tx == nil {
+    var out *types.Item
+    err := is.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      res, err := is.deleteItemLogic(ctx, innerTx, companyID, itemID)
+      if err != nil {
+        return err
+      }
+      out = res
+      return nil
+    })
+    if err != nil {
+      return nil, err
+    }
+    return out, nil
+  }
+  return is.deleteItemLogic(ctx, tx, companyID, itemID)
+}
+
+func (is *itemService) deleteItemLogic(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, itemID uuid.UUID) (*types.Item, error) {
+  if _, err := is.authorizedCompany(ctx, tx, companyID); err != nil {
+    return nil, err
+  }
+  item, err := is.loadItem(ctx, tx, companyID, itemID)
+  if err != nil {
+    return nil, err
+  }
+  if err := is.itemRepo.FullDeleteByIDs(ctx, tx, []uuid.UUID{item.ID}); err != nil {
+    is.log.Warn("Failed to delete item", "error", err)
+    return nil, fmt.Errorf("failed to delete item: %w", err)
+  }
+  is.log.Info("Item deleted", "companyID", companyID, "sku", item.SKU)
+  if err := recordCompanyEvent(ctx, tx, companyID, events.ItemDeleted, item); err != nil {
+    return nil, err
+  }
+  return item, nil
+}
+
+//----------------------------------------------------------------------------------------
+// Helpers
+//----------------------------------------------------------------------------------------
+
+// authorizedCompany returns the company if the requester is one of its users
+// or belongs to the wms that manages it.
+func (is *itemService) authorizedCompany(ctx context.Context, tx *gorm.DB, companyID uuid.UUID) (*types.Company, error) {
+  rd := requestdata.GetRequestData(ctx)
+  if rd == nil {
+    is.log.Warn("Request Data is not set in context.")
+    return nil, fmt.Errorf("Request Data is not set in context.")
+  }
+  if rd.UserID == uuid.Nil {
+    is.log.Warn("User ID not set in RequestData.")
+    return nil, fmt.Errorf("User ID not set in Request Data.")
+  }
+  companies, err := is.companyRepo.GetByIDs(ctx, tx, []uuid.UUID{companyID})
+  if err != nil {
+    return nil, fmt.Errorf("failed to load company: %w", err)
+  }
+  if len(companies) == 0 {
+    return nil, fmt.Errorf("company not found")
+  }
+  company := companies[0]
+  switch rd.UserType {
+  case "company":
+    if company.ID == rd.CompanyID {
+      return company, nil
+    }
+  case "wms":
+    if company.WmsID != nil && *company.WmsID == rd.WmsID {
+      return company, nil
+    }
+  }
+  is.log.Warn("User tried to reach another organization's items", "companyID", companyID, "userType", rd.UserType)
+  return nil, fmt.Errorf("company does not belong to your organization")
+}
+
+func (is *itemService) loadItem(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, itemID uuid.UUID) (*types.Item, error) {
+  items, err := is.itemRepo.GetByIDs(ctx, tx, []uuid.UUID{itemID})
+  if err != nil {
+    return nil, fmt.Errorf("failed to load item: %w", err)
+  }
+  if len(items) == 0 || items[0].CompanyID != companyID {
+    return nil, fmt.Errorf("item not found")
+  }
+  return items[0], nil
+}
+
+// checkGTINsFree makes sure no other item of the company already carries one
+// of the barcodes in uoms. ownItemID is skipped so an update may keep its own.
+func (is *itemService) checkGTINsFree(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, ownItemID uuid.UUID, uoms []*types.ItemUOM) error {
+  var gtins []string
+  for _, u := range uoms {
+    if u.GTIN != "" {
+      gtins = append(gtins, u.GTIN)
+    }
+  }
+  taken, err := is.itemRepo.GetUOMsByGTINs(ctx, tx, companyID, gtins)
+  if err != nil {
+    return fmt.Errorf("failed to check GTINs: %w", err)
+  }
+  for _, t := range taken {
+    if t.ItemID != ownItemID {
+      return fmt.Errorf("GTIN %s is already used by another item", t.GTIN)
+    }
+  }
+  return nil
+}
+
+// buildItem validates input and turns it into an unsaved item.
+func buildItem(companyID uuid.UUID, input ItemInput) (*types.Item, error) {
+  sku := strings.TrimSpace(input.SKU)
+  if sku == "" {
+    return nil, fmt.Errorf("SKU is required")
+  }
+  if len(sku) > maxSKULength {
+    return nil, fmt.Errorf("SKU is longer than %d characters", maxSKULength)
+  }
+  hazmat := strings.TrimSpace(input.HazmatClass)
+  if hazmat != "" && !hazmatClassPattern.MatchString(hazmat) {
+    return nil, fmt.Errorf("invalid hazmat class %q, expected a UN class such as 3 or 2.1", hazmat)
+  }
+  temperature := input.TemperatureClass
+  switch temperature {
+  case "":
+    temperature = types.TemperatureAmbient
+  case types.TemperatureAmbient, types.TemperatureChilled, types.TemperatureFrozen:
+  default:
+    return nil, fmt.Errorf("invalid temperature class %q", temperature)
+  }
+  stackable := true
+  if input.Stackable != nil {
+    stackable = *input.Stackable
+  }
+  if input.MaxStackHeight < 0 {
+    return nil, fmt.Errorf("max stack height cannot be negative")
+  }
+  if !stackable && input.MaxStackHeight > 1 {
+    return nil, fmt.Errorf("a non-stackable item cannot have a max stack height above 1")
+  }
+  uoms, err := buildItemUOMs(input.UOMs)
+  if err != nil {
+    return nil, err
+  }
+  for _, u := range uoms {
+    u.CompanyID = companyID
+  }
+  return &types.Item{
+    CompanyID:        companyID,
+    SKU:              sku,
+    Description:      strings.TrimSpace(input.Description),
+    HazmatClass:      hazmat,
+    TemperatureClass: temperature,
+    Stackable:        stackable,
+    MaxStackHeight:   input.MaxStackHeight,
+    UOMs:             uoms,
+  }, nil
+}
+
+// buildItemUOMs checks the UOM hierarchy: every item has an each of quantity
+// 1, no level appears twice, and each larger level holds more eaches than
+// the one below it.
+func buildItemUOMs(inputs []ItemUOMInput) ([]*types.ItemUOM, error) {
+  byUOM := map[types.UOM]ItemUOMInput{}
+  for _, in := range inputs {
+    uom := types.UOM(strings.ToLower(strings.TrimSpace(string(in.UOM))))
+    if uomRank(uom) < 0 {
+      return nil, fmt.Errorf("invalid UOM %q, expected one of each, inner, case, pallet", in.UOM)
+    }
+    if _, dup := byUOM[uom]; dup {
+      return nil, fmt.Errorf("UOM %s is listed more than once", uom)
+    }
+    in.UOM = uom
+    byUOM[uom] = in
+  }
+  each, ok := byUOM[types.UOMEach]
+  if !ok {
+    each = ItemUOMInput{UOM: types.UOMEach, Quantity: 1}
+    byUOM[types.UOMEach] = each
+  }
+  if each.Quantity == 0 {
+    each.Quantity = 1
+    byUOM[types.UOMEach] = each
+  }
+  if each.Quantity != 1 {
+    return nil, fmt.Errorf("the each UOM must have quantity 1")
+  }
+
+  var uoms []*types.ItemUOM
+  prevQty := 0
+  prevUOM := types.UOM("")
+  seenGTIN := map[string]types.UOM{}
+  for _, uom := range types.UOMs {
+    in, ok := byUOM[uom]
+    if !ok {
+      continue
+    }
+    if in.Quantity <= prevQty {
+      return nil, fmt.Errorf("%s quantity (%d) must be greater than %s quantity (%d)", uom, in.Quantity, prevUOM, prevQty)
+    }
+    if in.LengthCm < 0 || in.WidthCm < 0 || in.HeightCm < 0 || in.WeightKg < 0 {
+      return nil, fmt.Errorf("%s dimensions and weight cannot be negative", uom)
+    }
+    gtin := ""
+    if strings.TrimSpace(in.GTIN) != "" {
+      parsed, err := normalization.ParseGTIN(in.GTIN)
+      if err != nil {
+        return nil, fmt.Errorf("%s: %w", uom, err)
+      }
+      if other, dup := seenGTIN[parsed]; dup {
+        return nil, fmt.Errorf("GTIN %s is used for both %s and %s", parsed, other, uom)
+      }
+      seenGTIN[parsed] = uom
+      gtin = parsed
+    }
+    uoms = append(uoms, &types.ItemUOM{
+      UOM:      uom,
+      Quantity: in.Quantity,
+      GTIN:     gtin,
+      LengthCm: in.LengthCm,
+      WidthCm:  in.WidthCm,
+      HeightCm: in.HeightCm,
+      WeightKg: in.WeightKg,
+    })
+    prevQty, prevUOM = in.Quantity, uom
+  }
+  return uoms, nil
+}
+
+func uomRank(uom types.UOM) int {
+  for i, u := range types.UOMs {
+    if u == uom {
+      return i
+    }
+  }
+  return -1
+}
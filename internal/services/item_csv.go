@@ -0,0 +1,428 @@
+package services
+
+import (
+  "context"
+  "encoding/csv"
+  "errors"
+  "fmt"
+  "io"
+  "sort"
+  "strconv"
+  "strings"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/events"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+const (
+  // MaxItemImportBytes is the largest CSV accepted by ImportItemsCSV.
+  MaxItemImportBytes    = 20 << 20
+  maxItemImportRows     = 50000
+  // gtinLookupChunk keeps the GTIN check, up to four per item, under the
+  // bind limit of Postgres; SKUs go in chunks of skuLookupChunk.
+  gtinLookupChunk       = 1000
+)
+
+// ErrItemImportInvalid is returned together with a report when a real (non
+// dry-run) item import is rejected because some rows failed validation.
+var ErrItemImportInvalid = errors.New("item import has invalid rows")
+
+// ItemImportError points at a bad CSV row; Row is 1-based with the header
+// as row 1.
+type ItemImportError struct {
+  Row             int                     `json:"row"`
+  SKU             string                  `json:"sku,omitempty"`
+  Message         string                  `json:"message"`
+}
+
+// ItemImportReport describes what an item import did, or would do on a dry
+// run. Rows whose SKU already exists update that item, others create one.
+type ItemImportReport struct {
+  CompanyID       uuid.UUID               `json:"companyID"`
+  DryRun          bool                    `json:"dryRun"`
+  Valid           bool                    `json:"valid"`
+  TotalRows       int                     `json:"totalRows"`
+  Created         int                     `json:"created"`
+  Updated         int                     `json:"updated"`
+  Errors          []ItemImportError       `json:"errors"`
+}
+
+type itemCSVRow struct {
+  row             int
+  input           ItemInput
+}
+
+var itemCSVBaseColumns = []string{"sku", "description", "hazmat_class", "temperature_class", "stackable", "max_stack_height"}
+
+var itemCSVUOMFields = []string{"qty", "gtin", "length_cm", "width_cm", "height_cm", "weight_kg"}
+
+// itemCSVHeader is the column layout shared by import and export: the item
+// fields, then one block per UOM such as case_qty, case_gtin, case_length_cm.
+func itemCSVHeader() []string {
+  header := append([]string{}, itemCSVBaseColumns...)
+  for _, uom := range types.UOMs {
+    for _, field := range itemCSVUOMFields {
+      header = append(header, string(uom)+"_"+field)
+    }
+  }
+  return header
+}
+
+//----------------------------------------------------------------------------------------
+// Import
+//----------------------------------------------------------------------------------------
+
+// ImportItemsCSV creates or updates items from a CSV in the export layout.
+// Only the sku column is required; unknown columns are ignored.
+func (is *itemService) ImportItemsCSV(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, file io.Reader, dryRun bool) (*ItemImportReport, error) {
+  is.log.Info("Starting ImportItemsCSV now...", "companyID", companyID, "dryRun", dryRun)
+  rows, rowErrs, err := parseItemCSV(file)
+  if err != nil {
+    return nil, err
+  }
+  if tx == nil {
+    var out *ItemImportReport
+    err := is.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      res, err := is.importItemsLogic(ctx, innerTx, companyID, rows, rowErrs, dryRun)
+      out = res
+      return err
+    })
+    if err != nil {
+      return out, err
+    }
+    return out, nil
+  }
+  return is.importItemsLogic(ctx, tx, companyID, rows, rowErrs, dryRun)
+}
+
+func (is *itemService) importItemsLogic(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, rows []itemCSVRow, rowErrs []ItemImportError, dryRun bool) (*ItemImportReport, error) {
+  if _, err := is.authorizedCompany(ctx, tx, companyID); err != nil {
+    return nil, err
+  }
+  report := &ItemImportReport{
+    CompanyID: companyID,
+    DryRun:    dryRun,
+    TotalRows: len(rows) + len(rowErrs),
+    Errors:    append([]ItemImportError{}, rowErrs...),
+  }
+  fail := func(row int, sku string, format string, args ...interface{}) {
+    report.Errors = append(report.Errors, ItemImportError{Row: row, SKU: sku, Message: fmt.Sprintf(format, args...)})
+  }
+
+  type plannedItem struct {
+    row     int
+    item    *types.Item
+  }
+  var planned []plannedItem
+  skuRow := map[string]int{}
+  gtinSKU := map[string]string{}
+  var skus, gtins []string
+  for _, r := range rows {
+    item, err := buildItem(companyID, r.input)
+    if err != nil {
+      fail(r.row, strings.TrimSpace(r.input.SKU), "%s", err.Error())
+      continue
+    }
+    if first, ok := skuRow[item.SKU]; ok {
+      fail(r.row, item.SKU, "duplicate of row %d", first)
+      continue
+    }
+    skuRow[item.SKU] = r.row
+    dupGTIN := false
+    for _, u := range item.UOMs {
+      if u.GTIN == "" {
+        continue
+      }
+      if other, ok := gtinSKU[u.GTIN]; ok {
+        fail(r.row, item.SKU, "GTIN %s is also used by SKU %s in this file", u.GTIN, other)
+        dupGTIN = true
+        continue
+      }
+      gtinSKU[u.GTIN] = item.SKU
+      gtins = append(gtins, u.GTIN)
+    }
+    if dupGTIN {
+      continue
+    }
+    skus = append(skus, item.SKU)
+    planned = append(planned, plannedItem{row: r.row, item: item})
+  }
+
+  var existing []*types.Item
+  for start := 0; start < len(skus); start += skuLookupChunk {
+    end := start + skuLookupChunk
+    if end > len(skus) {
+      end = len(skus)
+    }
+    items, err := is.itemRepo.GetBySKUs(ctx, tx, companyID, skus[start:end])
+    if err != nil {
+      return nil, fmt.Errorf("failed to load existing items: %w", err)
+    }
+    existing = append(existing, items...)
+  }
+  existingBySKU := make(map[string]*types.Item, len(existing))
+  existingSKUByID := make(map[uuid.UUID]string, len(existing))
+  for _, item := range existing {
+    existingBySKU[item.SKU] = item
+    existingSKUByID[item.ID] = item.SKU
+  }
+  var taken []*types.ItemUOM
+  for start := 0; start < len(gtins); start += gtinLookupChunk {
+    end := start + gtinLookupChunk
+    if end > len(gtins) {
+      end = len(gtins)
+    }
+    uoms, err := is.itemRepo.GetUOMsByGTINs(ctx, tx, companyID, gtins[start:end])
+    if err != nil {
+      return nil, fmt.Errorf("failed to check GTINs: %w", err)
+    }
+    taken = append(taken, uoms...)
+  }
+  for _, t := range taken {
+    sku := gtinSKU[t.GTIN]
+    if owner, ok := existingSKUByID[t.ItemID]; ok && owner == sku {
+      continue
+    }
+    fail(skuRow[sku], sku, "GTIN %s is already used by another item", t.GTIN)
+  }
+
+  report.Valid = len(report.Errors) == 0
+  sort.SliceStable(report.Errors, func(i, j int) bool { return report.Errors[i].Row < report.Errors[j].Row })
+  if !report.Valid {
+    if dryRun {
+      return report, nil
+    }
+    is.log.Warn("Rejected item import", "companyID", companyID, "errors", len(report.Errors))
+    return report, ErrItemImportInvalid
+  }
+
+  var toCreate []*types.Item
+  for _, p := range planned {
+    if current, ok := existingBySKU[p.item.SKU]; ok {
+      report.Updated++
+      if !dryRun {
+        if _, err := is.saveItem(ctx, tx, current, p.item); err != nil {
+          return nil, err
+        }
+      }
+      continue
+    }
+    report.Created++
+    toCreate = append(toCreate, p.item)
+  }
+  if dryRun {
+    return report, nil
+  }
+  if len(toCreate) > 0 {
+    if _, err := is.itemRepo.Create(ctx, tx, toCreate); err != nil {
+      is.log.Warn("Failed to create imported items", "error", err)
+      return nil, fmt.Errorf("failed to create imported items: %w", err)
+    }
+  }
+  is.log.Info("Items imported", "companyID", companyID, "created", report.Created, "updated", report.Updated)
+  if err := recordCompanyEvent(ctx, tx, companyID, events.ItemsImported, report); err != nil {
+    return nil, err
+  }
+  return report, nil
+}
+
+// parseItemCSV reads the CSV into ItemInputs. Cells that do not parse are
+// reported per row; buildItem validates the rest later.
+func parseItemCSV(file io.Reader) ([]itemCSVRow, []ItemImportError, error) {
+  reader := csv.NewReader(file)
+  reader.FieldsPerRecord = -1
+  reader.TrimLeadingSpace = true
+  records, err := reader.ReadAll()
+  if err != nil {
+    return nil, nil, fmt.Errorf("failed to read csv: %w", err)
+  }
+  if len(records) == 0 {
+    return nil, nil, fmt.Errorf("file is empty")
+  }
+  if len(records)-1 > maxItemImportRows {
+    return nil, nil, fmt.Errorf("file has %d rows, the limit is %d", len(records)-1, maxItemImportRows)
+  }
+  columns := map[string]int{}
+  for i, name := range records[0] {
+    name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+    if name != "" {
+      columns[name] = i
+    }
+  }
+  if _, ok := columns["sku"]; !ok {
+    return nil, nil, fmt.Errorf("missing required column \"sku\"")
+  }
+
+  var rows []itemCSVRow
+  var rowErrs []ItemImportError
+  for i, record := range records[1:] {
+    line := i + 2
+    cell := func(name string) string {
+      idx, ok := columns[name]
+      if !ok || idx >= len(record) {
+        return ""
+      }
+      return strings.TrimSpace(record[idx])
+    }
+    blank := true
+    for _, c := range record {
+      if strings.TrimSpace(c) != "" {
+        blank = false
+        break
+      }
+    }
+    if blank {
+      continue
+    }
+    sku := cell("sku")
+    var problems []string
+    number := func(name string) float64 {
+      raw := cell(name)
+      if raw == "" {
+        return 0
+      }
+      v, err := strconv.ParseFloat(raw, 64)
+      if err != nil {
+        problems = append(problems, fmt.Sprintf("%s: %q is not a number", name, raw))
+      }
+      return v
+    }
+    whole := func(name string) int {
+      raw := cell(name)
+      if raw == "" {
+        return 0
+      }
+      v, err := strconv.Atoi(raw)
+      if err != nil {
+        problems = append(problems, fmt.Sprintf("%s: %q is not a whole number", name, raw))
+      }
+      return v
+    }
+
+    input := ItemInput{
+      SKU:              sku,
+      Description:      cell("description"),
+      HazmatClass:      cell("hazmat_class"),
+      TemperatureClass: types.TemperatureClass(strings.ToLower(cell("temperature_class"))),
+      MaxStackHeight:   whole("max_stack_height"),
+    }
+    if raw := cell("stackable"); raw != "" {
+      v, ok := parseCSVBool(raw)
+      if !ok {
+        problems = append(problems, fmt.Sprintf("stackable: %q is not yes/no", raw))
+      }
+      input.Stackable = &v
+    }
+    for _, uom := range types.UOMs {
+      prefix := string(uom) + "_"
+      present := false
+      for _, field := range itemCSVUOMFields {
+        if cell(prefix+field) != "" {
+          present = true
+          break
+        }
+      }
+      if !present && uom != types.UOMEach {
+        continue
+      }
+      input.UOMs = append(input.UOMs, ItemUOMInput{
+        UOM:      uom,
+        Quantity: whole(prefix + "qty"),
+        GTIN:     cell(prefix + "gtin"),
+        LengthCm: number(prefix + "length_cm"),
+        WidthCm:  number(prefix + "width_cm"),
+        HeightCm: number(prefix + "height_cm"),
+        WeightKg: number(prefix + "weight_kg"),
+      })
+    }
+    if len(problems) > 0 {
+      rowErrs = append(rowErrs, ItemImportError{Row: line, SKU: sku, Message: strings.Join(problems, "; ")})
+      continue
+    }
+    rows = append(rows, itemCSVRow{row: line, input: input})
+  }
+  return rows, rowErrs, nil
+}
+
+func parseCSVBool(raw string) (bool, bool) {
+  switch strings.ToLower(raw) {
+  case "true", "yes", "y", "1":
+    return true, true
+  case "false", "no", "n", "0":
+    return false, true
+  }
+  return false, false
+}
+
+//----------------------------------------------------------------------------------------
+// Export
+//----------------------------------------------------------------------------------------
+
+// ExportItemsCSV writes every item of the company in the layout that
+// ImportItemsCSV reads back.
+func (is *itemService) ExportItemsCSV(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, w io.Writer) error {
+  is.log.Info("Starting ExportItemsCSV now...", "companyID", companyID)
+  if _, err := is.authorizedCompany(ctx, tx, companyID); err != nil {
+    return err
+  }
+  items, err := is.itemRepo.GetByCompanyID(ctx, tx, companyID)
+  if err != nil {
+    return fmt.Errorf("failed to load items: %w", err)
+  }
+  writer := csv.NewWriter(w)
+  if err := writer.Write(itemCSVHeader()); err != nil {
+    return err
+  }
+  for _, item := range items {
+    record := []string{
+      item.SKU,
+      item.Description,
+      item.HazmatClass,
+      string(item.TemperatureClass),
+      strconv.FormatBool(item.Stackable),
+      formatCSVInt(item.MaxStackHeight),
+    }
+    byUOM := map[types.UOM]*types.ItemUOM{}
+    for _, u := range item.UOMs {
+      byUOM[u.UOM] = u
+    }
+    for _, uom := range types.UOMs {
+      u, ok := byUOM[uom]
+      if !ok {
+        record = append(record, make([]string, len(itemCSVUOMFields))...)
+        continue
+      }
+      record = append(record,
+        formatCSVInt(u.Quantity),
+        u.GTIN,
+        formatCSVFloat(u.LengthCm),
+        formatCSVFloat(u.WidthCm),
+        formatCSVFloat(u.HeightCm),
+        formatCSVFloat(u.WeightKg),
+      )
+    }
+    if err := writer.Write(record); err != nil {
+      return err
+    }
+  }
+  writer.Flush()
+  return writer.Error()
+}
+
+func formatCSVInt(v int) string {
+  if v == 0 {
+    return ""
+  }
+  return strconv.Itoa(v)
+}
+
+func formatCSVFloat(v float64) string {
+  if v == 0 {
+    return ""
+  }
+  return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
@@ -0,0 +1,95 @@
+package services
+
+import (
+  "context"
+  "fmt"
+  "strings"
+  "testing"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// fakeLookupItemRepo records the size of each lookup and finds nothing.
+type fakeLookupItemRepo struct {
+  repos.ItemRepo
+  skuLookups    []int
+  gtinLookups   []int
+}
+
+func (f *fakeLookupItemRepo) GetBySKUs(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, skus []string) ([]*types.Item, error) {
+  f.skuLookups = append(f.skuLookups, len(skus))
+  return nil, nil
+}
+
+func (f *fakeLookupItemRepo) GetUOMsByGTINs(ctx context.Context, tx *gorm.DB, companyID uuid.UUID, gtins []string) ([]*types.ItemUOM, error) {
+  f.gtinLookups = append(f.gtinLookups, len(gtins))
+  return nil, nil
+}
+
+// TestImportItemsCSVChunksLookups imports more items and GTINs than one
+// lookup may carry and checks every lookup stays within its chunk.
+func TestImportItemsCSVChunksLookups(t *testing.T) {
+  const rows = 1500
+  company := &types.Company{ID: uuid.New()}
+  itemRepo := &fakeLookupItemRepo{}
+  is := NewItemService(nil, testLogger(), &fakeCompanyRepo{companies: []*types.Company{company}}, itemRepo)
+
+  var csv strings.Builder
+  csv.WriteString("sku,each_gtin,case_qty,case_gtin\n")
+  for i := 0; i < rows; i++ {
+    fmt.Fprintf(&csv, "SKU-%d,%s,12,%s\n", i, testGTIN(2*i), testGTIN(2*i+1))
+  }
+
+  rd := &requestdata.RequestData{UserType: "company", UserID: uuid.New(), CompanyID: company.ID}
+  ctx := requestdata.WithRequestData(context.Background(), rd)
+  report, err := is.ImportItemsCSV(ctx, &gorm.DB{}, company.ID, strings.NewReader(csv.String()), true)
+  if err != nil {
+    t.Fatalf("ImportItemsCSV() error = %v", err)
+  }
+  if !report.Valid || report.Created != rows {
+    t.Fatalf("report valid = %v, created = %d, errors = %v; want valid with %d created", report.Valid, report.Created, report.Errors, rows)
+  }
+
+  tests := []struct {
+    name          string
+    lookups       []int
+    chunk         int
+    total         int
+  }{
+    {name: "skus", lookups: itemRepo.skuLookups, chunk: skuLookupChunk, total: rows},
+    {name: "gtins", lookups: itemRepo.gtinLookups, chunk: gtinLookupChunk, total: 2 * rows},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      sum := 0
+      for _, n := range tt.lookups {
+        if n > tt.chunk {
+          t.Errorf("lookup of %d exceeds the chunk of %d", n, tt.chunk)
+        }
+        sum += n
+      }
+      if sum != tt.total {
+        t.Errorf("looked up %d, want %d", sum, tt.total)
+      }
+    })
+  }
+}
+
+// testGTIN is a valid GTIN-14 numbered n.
+func testGTIN(n int) string {
+  body := fmt.Sprintf("1%012d", n)
+  sum := 0
+  for i := 0; i < len(body); i++ {
+    d := int(body[i] - '0')
+    if i%2 == 0 {
+      d *= 3
+    }
+    sum += d
+  }
+  return body + fmt.Sprint((10-sum%10)%10)
+}
@@ -0,0 +1,676 @@
+package services
+
+import (
+  "context"
+  "fmt"
+  "strings"
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/events"
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/normalization"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+const (
+  DefaultMoveTaskPageSize       = 100
+  MaxMoveTaskPageSize           = 500
+  maxMoveTaskNoteLength         = 500
+)
+
+// MoveTaskConfirmation is what the worker scanned at the destination: the
+// bin's full code, and the item's SKU or any of its GTINs. Quantity is in
+// eaches and defaults to the task's; a smaller one is a short move and
+// leaves the rest in the source bin.
+type MoveTaskConfirmation struct {
+  LocationCode      string                          `json:"locationCode"`
+  ItemCode          string                          `json:"itemCode"`
+  Quantity          int                             `json:"quantity,omitempty"`
+}
+
+type MoveTaskExceptionInput struct {
+  Reason            types.MoveTaskExceptionReason   `json:"reason"`
+  Note              string                          `json:"note,omitempty"`
+}
+
+// MoveTaskPage is one page of ListTasks in work order.
+type MoveTaskPage struct {
+  Tasks             []*types.MoveTask               `json:"tasks"`
+  Total             int64                           `json:"total"`
+  Limit             int                             `json:"limit"`
+  Offset            int                             `json:"offset"`
+}
+
+// MovePlanProgress counts a plan's tasks by outcome. Open is everything
+// not yet completed, canceled or in exception.
+type MovePlanProgress struct {
+  PlanID            uuid.UUID                       `json:"planID"`
+  Status            types.SlottingPlanStatus        `json:"status"`
+  Total             int                             `json:"total"`
+  Open              int                             `json:"open"`
+  Completed         int                             `json:"completed"`
+  Exceptions        int                             `json:"exceptions"`
+  Canceled          int                             `json:"canceled"`
+}
+
+// MoveTaskUpdate is a task after a state change with its plan's progress,
+// which is what supervisors are sent.
+type MoveTaskUpdate struct {
+  Task              *types.MoveTask                 `json:"task"`
+  Progress          MovePlanProgress                `json:"progress"`
+}
+
+// MoveTaskBatch is the tasks generated for a plan.
+type MoveTaskBatch struct {
+  Plan              *types.SlottingPlan             `json:"plan"`
+  Tasks             []*types.MoveTask               `json:"tasks"`
+  Progress          MovePlanProgress                `json:"progress"`
+}
+
+type MoveTaskService interface {
+  GenerateTasks(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, planID uuid.UUID) (*MoveTaskBatch, error)
+  generateTasksLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, planID uuid.UUID) (*MoveTaskBatch, error)
+  ListTasks(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filter repos.MoveTaskFilter) (*MoveTaskPage, error)
+  GetTask(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID) (*types.MoveTask, error)
+  AssignTask(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID, userID uuid.UUID) (*MoveTaskUpdate, error)
+  ClaimTask(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID) (*MoveTaskUpdate, error)
+  StartTask(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID) (*MoveTaskUpdate, error)
+  ConfirmTask(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID, input MoveTaskConfirmation) (*MoveTaskUpdate, error)
+  ReportException(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID, input MoveTaskExceptionInput) (*MoveTaskUpdate, error)
+  ReopenTask(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID) (*MoveTaskUpdate, error)
+  CancelTask(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID) (*MoveTaskUpdate, error)
+  updateTaskLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID, apply moveTaskTransition) (*MoveTaskUpdate, error)
+}
+
+// moveTaskTransition changes a locked task in place. userID is the caller.
+type moveTaskTransition func(ctx context.Context, tx *gorm.DB, warehouse *types.Warehouse, task *types.MoveTask, userID uuid.UUID) error
+
+type moveTaskService struct {
+  db                    *gorm.DB
+  log                   *logger.Logger
+  warehouseService      WarehouseService
+  inventoryService      InventoryService
+  companyRepo           repos.CompanyRepo
+  userRepo              repos.UserRepo
+  locationRepo          repos.WarehouseLocationRepo
+  itemRepo              repos.ItemRepo
+  slottingRepo          repos.SlottingRepo
+  scenarioRepo          repos.SlottingScenarioRepo
+  moveTaskRepo          repos.MoveTaskRepo
+}
+
+func NewMoveTaskService(
+  db                    *gorm.DB,
+  log                   *logger.Logger,
+  warehouseService      WarehouseService,
+  inventoryService      InventoryService,
+  companyRepo           repos.CompanyRepo,
+  userRepo              repos.UserRepo,
+  locationRepo          repos.WarehouseLocationRepo,
+  itemRepo              repos.ItemRepo,
+  slottingRepo          repos.SlottingRepo,
+  scenarioRepo          repos.SlottingScenarioRepo,
+  moveTaskRepo          repos.MoveTaskRepo,
+) MoveTaskService {
+  serviceLog := log.With("service", "MoveTaskService")
+  return &moveTaskService{
+    db:               db,
+    log:              serviceLog,
+    warehouseService: warehouseService,
+    inventoryService: inventoryService,
+    companyRepo:      companyRepo,
+    userRepo:         userRepo,
+    locationRepo:     locationRepo,
+    itemRepo:         itemRepo,
+    slottingRepo:     slottingRepo,
+    scenarioRepo:     scenarioRepo,
+    moveTaskRepo:     moveTaskRepo,
+  }
+}
+
+//----------------------------------------------------------------------------------------
+// Read
+//----------------------------------------------------------------------------------------
+
+func (ms *moveTaskService) ListTasks(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filter repos.MoveTaskFilter) (*MoveTaskPage, error) {
+  ms.log.Info("Starting ListTasks now...", "warehouseID", warehouseID)
+  if _, err := ms.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  for _, st := range filter.Statuses {
+    if !isValidMoveTaskStatus(st) {
+      return nil, fmt.Errorf("invalid move task status %q", st)
+    }
+  }
+  filter.Limit, filter.Offset = clampPage(filter.Limit, filter.Offset, DefaultMoveTaskPageSize, MaxMoveTaskPageSize)
+  tasks, total, err := ms.moveTaskRepo.SearchTasks(ctx, tx, warehouseID, filter)
+  if err != nil {
+    ms.log.Warn("Failed to search move tasks", "error", err)
+    return nil, fmt.Errorf("failed to search move tasks: %w", err)
+  }
+  return &MoveTaskPage{Tasks: tasks, Total: total, Limit: filter.Limit, Offset: filter.Offset}, nil
+}
+
+func (ms *moveTaskService) GetTask(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID) (*types.MoveTask, error) {
+  ms.log.Info("Starting GetTask now...", "warehouseID", warehouseID, "taskID", taskID)
+  if _, err := ms.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  task, err := ms.loadTask(ctx, tx, warehouseID, taskID)
+  if err != nil {
+    return nil, err
+  }
+  if err := ms.attachTaskDetails(ctx, tx, task); err != nil {
+    return nil, err
+  }
+  return task, nil
+}
+
+//----------------------------------------------------------------------------------------
+// Create
+//----------------------------------------------------------------------------------------
+
+// GenerateTasks turns every move of an approved plan into a task and puts the
+// plan in progress. A move's priority follows the ABC class of its SKU.
+func (ms *moveTaskService) GenerateTasks(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, planID uuid.UUID) (*MoveTaskBatch, error) {
+  ms.log.Info("Starting GenerateTasks now...", "warehouseID", warehouseID, "planID", planID)
+  if tx == nil {
+    var out *MoveTaskBatch
+    if err := ms.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      var err error
+      out, err = ms.generateTasksLogic(ctx, innerTx, warehouseID, planID)
+      return err
+    }); err != nil {
+      return nil, err
+    }
+    return out, nil
+  }
+  return ms.generateTasksLogic(ctx, tx, warehouseID, planID)
+}
+
+func (ms *moveTaskService) generateTasksLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, planID uuid.UUID) (*MoveTaskBatch, error) {
+  if _, err := ms.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  plan, err := ms.loadPlan(ctx, tx, warehouseID, planID)
+  if err != nil {
+    return nil, err
+  }
+  if plan.Status != types.SlottingPlanApproved {
+    return nil, fmt.Errorf("tasks can only be generated for an approved plan, this one is %s", plan.Status)
+  }
+  moves, err := ms.slottingRepo.GetMovesByJobID(ctx, tx, plan.JobID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to load slotting plan moves: %w", err)
+  }
+  if len(moves) == 0 {
+    return nil, fmt.Errorf("slotting plan has no moves")
+  }
+  assignments, err := ms.slottingRepo.GetAssignmentsByJobID(ctx, tx, plan.JobID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to load slotting assignments: %w", err)
+  }
+  priority := make(map[uuid.UUID]int, len(assignments))
+  for _, a := range assignments {
+    priority[a.ItemID] = abcPriority(a.ABCClass)
+  }
+
+  tasks := make([]*types.MoveTask, 0, len(moves))
+  for _, m := range moves {
+    tasks = append(tasks, &types.MoveTask{
+      PlanID:         plan.ID,
+      WarehouseID:    warehouseID,
+      CompanyID:      plan.CompanyID,
+      MoveID:         m.ID,
+      Sequence:       m.Sequence,
+      Priority:       priority[m.ItemID],
+      ItemID:         m.ItemID,
+      FromLocationID: m.FromLocationID,
+      ToLocationID:   m.ToLocationID,
+      Lot:            m.Lot,
+      Quantity:       m.Quantity,
+      ViaStaging:     m.ViaStaging,
+      Status:         types.MoveTaskOpen,
+    })
+  }
+  if _, err := ms.moveTaskRepo.CreateTasks(ctx, tx, tasks); err != nil {
+    return nil, fmt.Errorf("failed to create move tasks: %w", err)
+  }
+  plan.Status = types.SlottingPlanInProgress
+  if _, err := ms.scenarioRepo.UpdatePlan(ctx, tx, plan); err != nil {
+    return nil, fmt.Errorf("failed to update slotting plan: %w", err)
+  }
+  batch := &MoveTaskBatch{Plan: plan, Tasks: tasks, Progress: planProgress(plan, tasks)}
+  if err := recordCompanyEvent(ctx, tx, plan.CompanyID, events.MoveTasksGenerated, map[string]interface{}{
+    "plan":     batch.Plan,
+    "progress": batch.Progress,
+  }); err != nil {
+    return nil, err
+  }
+  return batch, nil
+}
+
+//----------------------------------------------------------------------------------------
+// Update
+//----------------------------------------------------------------------------------------
+
+// AssignTask gives an open or assigned task to userID, who must be able to
+// see the warehouse. uuid.Nil puts the task back in the open pool.
+func (ms *moveTaskService) AssignTask(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID, userID uuid.UUID) (*MoveTaskUpdate, error) {
+  ms.log.Info("Starting AssignTask now...", "warehouseID", warehouseID, "taskID", taskID, "userID", userID)
+  return ms.updateTask(ctx, tx, warehouseID, taskID, func(ctx context.Context, tx *gorm.DB, warehouse *types.Warehouse, task *types.MoveTask, _ uuid.UUID) error {
+    if task.Status != types.MoveTaskOpen && task.Status != types.MoveTaskAssigned {
+      return fmt.Errorf("only an open or assigned task can be assigned, this one is %s", task.Status)
+    }
+    task.ClaimedAt = nil
+    if userID == uuid.Nil {
+      task.Status = types.MoveTaskOpen
+      task.AssignedToID = nil
+      task.AssignedAt = nil
+      return nil
+    }
+    if err := ms.checkAssignee(ctx, tx, warehouse, userID); err != nil {
+      return err
+    }
+    now := time.Now().UTC()
+    task.Status = types.MoveTaskAssigned
+    task.AssignedToID = &userID
+    task.AssignedAt = &now
+    return nil
+  })
+}
+
+// ClaimTask takes a task for the caller: their own assigned task, or any
+// open one.
+func (ms *moveTaskService) ClaimTask(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID) (*MoveTaskUpdate, error) {
+  ms.log.Info("Starting ClaimTask now...", "warehouseID", warehouseID, "taskID", taskID)
+  return ms.updateTask(ctx, tx, warehouseID, taskID, func(_ context.Context, _ *gorm.DB, _ *types.Warehouse, task *types.MoveTask, userID uuid.UUID) error {
+    switch task.Status {
+    case types.MoveTaskOpen:
+    case types.MoveTaskAssigned:
+      if task.AssignedToID == nil || *task.AssignedToID != userID {
+        return fmt.Errorf("task is assigned to another user")
+      }
+    default:
+      return fmt.Errorf("only an open or assigned task can be claimed, this one is %s", task.Status)
+    }
+    now := time.Now().UTC()
+    if task.AssignedToID == nil {
+      task.AssignedToID = &userID
+      task.AssignedAt = &now
+    }
+    task.Status = types.MoveTaskClaimed
+    task.ClaimedAt = &now
+    return nil
+  })
+}
+
+func (ms *moveTaskService) StartTask(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID) (*MoveTaskUpdate, error) {
+  ms.log.Info("Starting StartTask now...", "warehouseID", warehouseID, "taskID", taskID)
+  return ms.updateTask(ctx, tx, warehouseID, taskID, func(_ context.Context, _ *gorm.DB, _ *types.Warehouse, task *types.MoveTask, userID uuid.UUID) error {
+    if task.Status != types.MoveTaskClaimed {
+      return fmt.Errorf("only a claimed task can be started, this one is %s", task.Status)
+    }
+    if err := checkTaskHolder(task, userID); err != nil {
+      return err
+    }
+    now := time.Now().UTC()
+    task.Status = types.MoveTaskInProgress
+    task.StartedAt = &now
+    return nil
+  })
+}
+
+// ConfirmTask completes a started task once the scans match its destination
+// bin and item, and moves the stock in the ledger in the same transaction.
+func (ms *moveTaskService) ConfirmTask(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID, input MoveTaskConfirmation) (*MoveTaskUpdate, error) {
+  ms.log.Info("Starting ConfirmTask now...", "warehouseID", warehouseID, "taskID", taskID)
+  return ms.updateTask(ctx, tx, warehouseID, taskID, func(ctx context.Context, tx *gorm.DB, _ *types.Warehouse, task *types.MoveTask, userID uuid.UUID) error {
+    if task.Status != types.MoveTaskInProgress {
+      return fmt.Errorf("only a started task can be confirmed, this one is %s", task.Status)
+    }
+    if err := checkTaskHolder(task, userID); err != nil {
+      return err
+    }
+    if err := ms.checkScans(ctx, tx, task, input); err != nil {
+      return err
+    }
+    quantity := input.Quantity
+    if quantity == 0 {
+      quantity = task.Quantity
+    }
+    if quantity < 0 || quantity > task.Quantity {
+      return fmt.Errorf("quantity must be between 1 and %d", task.Quantity)
+    }
+    toLocationID := task.ToLocationID
+    entries, err := ms.inventoryService.recordTransactionsLogic(ctx, tx, task.WarehouseID, []InventoryTransactionInput{{
+      Type:         types.InventoryMove,
+      ItemID:       task.ItemID,
+      LocationID:   task.FromLocationID,
+      ToLocationID: &toLocationID,
+      UOM:          types.UOMEach,
+      Quantity:     quantity,
+      Lot:          task.Lot,
+      Reference:    "move-task:" + task.ID.String(),
+      Reason:       "slotting plan",
+    }})
+    if err != nil {
+      return err
+    }
+    now := time.Now().UTC()
+    task.Status = types.MoveTaskCompleted
+    task.CompletedAt = &now
+    task.CompletedByID = &userID
+    task.ConfirmedQuantity = quantity
+    if len(entries) > 0 {
+      groupID := entries[0].GroupID
+      task.LedgerGroupID = &groupID
+    }
+    return nil
+  })
+}
+
+// ReportException stops a task the caller holds and flags it for a
+// supervisor, who can reopen or cancel it.
+func (ms *moveTaskService) ReportException(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID, input MoveTaskExceptionInput) (*MoveTaskUpdate, error) {
+  ms.log.Info("Starting ReportException now...", "warehouseID", warehouseID, "taskID", taskID, "reason", input.Reason)
+  return ms.updateTask(ctx, tx, warehouseID, taskID, func(_ context.Context, _ *gorm.DB, _ *types.Warehouse, task *types.MoveTask, userID uuid.UUID) error {
+    if task.Status != types.MoveTaskClaimed && task.Status != types.MoveTaskInProgress {
+      return fmt.Errorf("only a claimed or started task can raise an exception, this one is %s", task.Status)
+    }
+    if err := checkTaskHolder(task, userID); err != nil {
+      return err
+    }
+    if !isValidMoveTaskExceptionReason(input.Reason) {
+      return fmt.Errorf("invalid exception reason %q, expected one of short_pick, item_missing, location_blocked, damaged, other", input.Reason)
+    }
+    note := strings.TrimSpace(input.Note)
+    if input.Reason == types.MoveTaskExceptionOther && note == "" {
+      return fmt.Errorf("an exception of type other needs a note")
+    }
+    if len(note) > maxMoveTaskNoteLength {
+      return fmt.Errorf("note cannot be longer than %d characters", maxMoveTaskNoteLength)
+    }
+    task.Status = types.MoveTaskException
+    task.ExceptionReason = input.Reason
+    task.ExceptionNote = note
+    return nil
+  })
+}
+
+// ReopenTask clears an exception. The task goes back to its assignee, or to
+// the open pool if it has none.
+func (ms *moveTaskService) ReopenTask(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID) (*MoveTaskUpdate, error) {
+  ms.log.Info("Starting ReopenTask now...", "warehouseID", warehouseID, "taskID", taskID)
+  return ms.updateTask(ctx, tx, warehouseID, taskID, func(_ context.Context, _ *gorm.DB, _ *types.Warehouse, task *types.MoveTask, _ uuid.UUID) error {
+    if task.Status != types.MoveTaskException {
+      return fmt.Errorf("only a task in exception can be reopened, this one is %s", task.Status)
+    }
+    task.Status = types.MoveTaskOpen
+    if task.AssignedToID != nil {
+      task.Status = types.MoveTaskAssigned
+    }
+    task.ClaimedAt = nil
+    task.StartedAt = nil
+    task.ExceptionReason = ""
+    task.ExceptionNote = ""
+    return nil
+  })
+}
+
+func (ms *moveTaskService) CancelTask(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID) (*MoveTaskUpdate, error) {
+  ms.log.Info("Starting CancelTask now...", "warehouseID", warehouseID, "taskID", taskID)
+  return ms.updateTask(ctx, tx, warehouseID, taskID, func(_ context.Context, _ *gorm.DB, _ *types.Warehouse, task *types.MoveTask, _ uuid.UUID) error {
+    if task.Status == types.MoveTaskCompleted || task.Status == types.MoveTaskCanceled {
+      return fmt.Errorf("task is already %s", task.Status)
+    }
+    task.Status = types.MoveTaskCanceled
+    return nil
+  })
+}
+
+func (ms *moveTaskService) updateTask(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID, apply moveTaskTransition) (*MoveTaskUpdate, error) {
+  if tx == nil {
+    var out *MoveTaskUpdate
+    if err := ms.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      var err error
+      out, err = ms.updateTaskLogic(ctx, innerTx, warehouseID, taskID, apply)
+      return err
+    }); err != nil {
+      return nil, err
+    }
+    return out, nil
+  }
+  return ms.updateTaskLogic(ctx, tx, warehouseID, taskID, apply)
+}
+
+// updateTaskLogic locks the task, applies the transition, and completes the
+// plan once none of its tasks is left to do.
+func (ms *moveTaskService) updateTaskLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID, apply moveTaskTransition) (*MoveTaskUpdate, error) {
+  warehouse, err := ms.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, err
+  }
+  rd := requestdata.GetRequestData(ctx)
+  if rd == nil || rd.UserID == uuid.Nil {
+    return nil, fmt.Errorf("User ID not set in Request Data.")
+  }
+  task, err := ms.loadTask(ctx, tx, warehouseID, taskID)
+  if err != nil {
+    return nil, err
+  }
+  plan, err := ms.loadPlan(ctx, tx, warehouseID, task.PlanID)
+  if err != nil {
+    return nil, err
+  }
+  if plan.Status != types.SlottingPlanInProgress {
+    return nil, fmt.Errorf("slotting plan is %s", plan.Status)
+  }
+  if err := apply(ctx, tx, warehouse, task, rd.UserID); err != nil {
+    return nil, err
+  }
+  if _, err := ms.moveTaskRepo.UpdateTask(ctx, tx, task); err != nil {
+    return nil, fmt.Errorf("failed to update move task: %w", err)
+  }
+
+  tasks, err := ms.moveTaskRepo.GetTasksByPlanID(ctx, tx, plan.ID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to load plan tasks: %w", err)
+  }
+  progress := planProgress(plan, tasks)
+  if progress.Open == 0 && progress.Exceptions == 0 {
+    now := time.Now().UTC()
+    plan.Status = types.SlottingPlanCompleted
+    plan.CompletedAt = &now
+    if _, err := ms.scenarioRepo.UpdatePlan(ctx, tx, plan); err != nil {
+      return nil, fmt.Errorf("failed to complete slotting plan: %w", err)
+    }
+    progress.Status = plan.Status
+  }
+  if err := ms.attachTaskDetails(ctx, tx, task); err != nil {
+    return nil, err
+  }
+  update := &MoveTaskUpdate{Task: task, Progress: progress}
+  if err := recordCompanyEvent(ctx, tx, task.CompanyID, events.MoveTaskUpdated, update); err != nil {
+    return nil, err
+  }
+  return update, nil
+}
+
+//----------------------------------------------------------------------------------------
+// Helpers
+//----------------------------------------------------------------------------------------
+
+func (ms *moveTaskService) loadTask(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, taskID uuid.UUID) (*types.MoveTask, error) {
+  tasks, err := ms.moveTaskRepo.GetTasksByIDs(ctx, tx, []uuid.UUID{taskID})
+  if err != nil {
+    return nil, fmt.Errorf("failed to load move task: %w", err)
+  }
+  if len(tasks) == 0 || tasks[0].WarehouseID != warehouseID {
+    return nil, fmt.Errorf("move task not found")
+  }
+  return tasks[0], nil
+}
+
+func (ms *moveTaskService) loadPlan(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, planID uuid.UUID) (*types.SlottingPlan, error) {
+  plans, err := ms.scenarioRepo.GetPlansByIDs(ctx, tx, []uuid.UUID{planID})
+  if err != nil {
+    return nil, fmt.Errorf("failed to load slotting plan: %w", err)
+  }
+  if len(plans) == 0 || plans[0].WarehouseID != warehouseID {
+    return nil, fmt.Errorf("slotting plan not found")
+  }
+  return plans[0], nil
+}
+
+// attachTaskDetails fills in the item and bins, so a task read on its own
+// looks like one from ListTasks.
+func (ms *moveTaskService) attachTaskDetails(ctx context.Context, tx *gorm.DB, task *types.MoveTask) error {
+  items, err := ms.itemRepo.GetByIDs(ctx, tx, []uuid.UUID{task.ItemID})
+  if err != nil {
+    return fmt.Errorf("failed to load item: %w", err)
+  }
+  if len(items) > 0 {
+    task.Item = items[0]
+  }
+  locations, err := ms.locationRepo.GetByIDs(ctx, tx, []uuid.UUID{task.FromLocationID, task.ToLocationID})
+  if err != nil {
+    return fmt.Errorf("failed to load locations: %w", err)
+  }
+  for _, l := range locations {
+    switch l.ID {
+    case task.FromLocationID:
+      task.FromLocation = l
+    case task.ToLocationID:
+      task.ToLocation = l
+    }
+  }
+  return nil
+}
+
+// checkAssignee makes sure userID works for the warehouse's company or for
+// the WMS that serves it.
+func (ms *moveTaskService) checkAssignee(ctx context.Context, tx *gorm.DB, warehouse *types.Warehouse, userID uuid.UUID) error {
+  users, err := ms.userRepo.GetByIDs(ctx, tx, []uuid.UUID{userID})
+  if err != nil {
+    return fmt.Errorf("failed to load user: %w", err)
+  }
+  if len(users) == 0 {
+    return fmt.Errorf("user not found")
+  }
+  user := users[0]
+  if user.CompanyID != nil && *user.CompanyID == warehouse.CompanyID {
+    return nil
+  }
+  if user.WmsID != nil {
+    companies, err := ms.companyRepo.GetByIDs(ctx, tx, []uuid.UUID{warehouse.CompanyID})
+    if err != nil {
+      return fmt.Errorf("failed to load warehouse's company: %w", err)
+    }
+    if len(companies) > 0 && companies[0].WmsID != nil && *companies[0].WmsID == *user.WmsID {
+      return nil
+    }
+  }
+  return fmt.Errorf("user cannot work in this warehouse")
+}
+
+// checkScans matches the scanned bin against the task's destination and the
+// scanned item against its SKU or GTINs.
+func (ms *moveTaskService) checkScans(ctx context.Context, tx *gorm.DB, task *types.MoveTask, input MoveTaskConfirmation) error {
+  locationCode := strings.TrimSpace(input.LocationCode)
+  itemCode := strings.TrimSpace(input.ItemCode)
+  if locationCode == "" || itemCode == "" {
+    return fmt.Errorf("scan both the destination location and the item")
+  }
+  locations, err := ms.locationRepo.GetByIDs(ctx, tx, []uuid.UUID{task.ToLocationID})
+  if err != nil {
+    return fmt.Errorf("failed to load destination location: %w", err)
+  }
+  if len(locations) == 0 {
+    return fmt.Errorf("destination location no longer exists")
+  }
+  if !strings.EqualFold(locationCode, locations[0].FullCode) {
+    return fmt.Errorf("scanned location %s is not the destination %s", locationCode, locations[0].FullCode)
+  }
+  items, err := ms.itemRepo.GetByIDs(ctx, tx, []uuid.UUID{task.ItemID})
+  if err != nil {
+    return fmt.Errorf("failed to load item: %w", err)
+  }
+  if len(items) == 0 {
+    return fmt.Errorf("item no longer exists")
+  }
+  item := items[0]
+  if strings.EqualFold(itemCode, item.SKU) {
+    return nil
+  }
+  if gtin, err := normalization.ParseGTIN(itemCode); err == nil {
+    for _, u := range item.UOMs {
+      if u.GTIN == gtin {
+        return nil
+      }
+    }
+  }
+  return fmt.Errorf("scanned item %s is not %s", itemCode, item.SKU)
+}
+
+// checkTaskHolder makes sure the caller is the one the task was claimed by.
+func checkTaskHolder(task *types.MoveTask, userID uuid.UUID) error {
+  if task.AssignedToID == nil || *task.AssignedToID != userID {
+    return fmt.Errorf("task is held by another user")
+  }
+  return nil
+}
+
+func planProgress(plan *types.SlottingPlan, tasks []*types.MoveTask) MovePlanProgress {
+  p := MovePlanProgress{PlanID: plan.ID, Status: plan.Status, Total: len(tasks)}
+  for _, t := range tasks {
+    switch t.Status {
+    case types.MoveTaskCompleted:
+      p.Completed++
+    case types.MoveTaskCanceled:
+      p.Canceled++
+    case types.MoveTaskException:
+      p.Exceptions++
+    default:
+      p.Open++
+    }
+  }
+  return p
+}
+
+// abcPriority ranks fast movers first, so the plan's biggest wins land
+// early even if it is not finished.
+func abcPriority(class string) int {
+  switch strings.ToUpper(class) {
+  case "A":
+    return 3
+  case "B":
+    return 2
+  case "C":
+    return 1
+  }
+  return 0
+}
+
+func isValidMoveTaskStatus(st types.MoveTaskStatus) bool {
+  switch st {
+  case types.MoveTaskOpen, types.MoveTaskAssigned, types.MoveTaskClaimed, types.MoveTaskInProgress,
+    types.MoveTaskCompleted, types.MoveTaskException, types.MoveTaskCanceled:
+    return true
+  }
+  return false
+}
+
+func isValidMoveTaskExceptionReason(r types.MoveTaskExceptionReason) bool {
+  switch r {
+  case types.MoveTaskExceptionShortPick, types.MoveTaskExceptionItemMissing, types.MoveTaskExceptionLocationBlocked,
+    types.MoveTaskExceptionDamaged, types.MoveTaskExceptionOther:
+    return true
+  }
+  return false
+}
@@ -0,0 +1,443 @@
+package services
+
+import (
+  "context"
+  "errors"
+  "testing"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// fakeMoveTaskRepo hands out copies of its tasks, so a transition that
+// fails part way leaves the stored task as a rolled back transaction would.
+type fakeMoveTaskRepo struct {
+  repos.MoveTaskRepo
+  tasks         []*types.MoveTask
+  updates       int
+}
+
+func (f *fakeMoveTaskRepo) GetTasksByIDs(ctx context.Context, tx *gorm.DB, taskIDs []uuid.UUID) ([]*types.MoveTask, error) {
+  var out []*types.MoveTask
+  for _, t := range f.tasks {
+    for _, id := range taskIDs {
+      if t.ID == id {
+        c := *t
+        out = append(out, &c)
+      }
+    }
+  }
+  return out, nil
+}
+
+func (f *fakeMoveTaskRepo) GetTasksByPlanID(ctx context.Context, tx *gorm.DB, planID uuid.UUID) ([]*types.MoveTask, error) {
+  var out []*types.MoveTask
+  for _, t := range f.tasks {
+    if t.PlanID == planID {
+      c := *t
+      out = append(out, &c)
+    }
+  }
+  return out, nil
+}
+
+func (f *fakeMoveTaskRepo) UpdateTask(ctx context.Context, tx *gorm.DB, task *types.MoveTask) (*types.MoveTask, error) {
+  f.updates++
+  for i, t := range f.tasks {
+    if t.ID == task.ID {
+      c := *task
+      f.tasks[i] = &c
+    }
+  }
+  return task, nil
+}
+
+type fakeScenarioRepo struct {
+  repos.SlottingScenarioRepo
+  plans         []*types.SlottingPlan
+  planUpdates   int
+}
+
+func (f *fakeScenarioRepo) GetPlansByIDs(ctx context.Context, tx *gorm.DB, planIDs []uuid.UUID) ([]*types.SlottingPlan, error) {
+  var out []*types.SlottingPlan
+  for _, p := range f.plans {
+    for _, id := range planIDs {
+      if p.ID == id {
+        c := *p
+        out = append(out, &c)
+      }
+    }
+  }
+  return out, nil
+}
+
+func (f *fakeScenarioRepo) UpdatePlan(ctx context.Context, tx *gorm.DB, plan *types.SlottingPlan) (*types.SlottingPlan, error) {
+  f.planUpdates++
+  for i, p := range f.plans {
+    if p.ID == plan.ID {
+      c := *plan
+      f.plans[i] = &c
+    }
+  }
+  return plan, nil
+}
+
+type fakeLocationRepo struct {
+  repos.WarehouseLocationRepo
+  locations     []*types.WarehouseLocation
+}
+
+func (f *fakeLocationRepo) GetByIDs(ctx context.Context, tx *gorm.DB, locationIDs []uuid.UUID) ([]*types.WarehouseLocation, error) {
+  var out []*types.WarehouseLocation
+  for _, l := range f.locations {
+    for _, id := range locationIDs {
+      if l.ID == id {
+        out = append(out, l)
+      }
+    }
+  }
+  return out, nil
+}
+
+type fakeItemRepo struct {
+  repos.ItemRepo
+  items         []*types.Item
+}
+
+func (f *fakeItemRepo) GetByIDs(ctx context.Context, tx *gorm.DB, itemIDs []uuid.UUID) ([]*types.Item, error) {
+  var out []*types.Item
+  for _, it := range f.items {
+    for _, id := range itemIDs {
+      if it.ID == id {
+        out = append(out, it)
+      }
+    }
+  }
+  return out, nil
+}
+
+// fakeInventoryService records the moves a confirmation books in the ledger.
+type fakeInventoryService struct {
+  InventoryService
+  inputs        []InventoryTransactionInput
+  err           error
+}
+
+func (f *fakeInventoryService) recordTransactionsLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, inputs []InventoryTransactionInput) ([]*types.InventoryLedgerEntry, error) {
+  if f.err != nil {
+    return nil, f.err
+  }
+  f.inputs = append(f.inputs, inputs...)
+  groupID := uuid.New()
+  var out []*types.InventoryLedgerEntry
+  for range inputs {
+    out = append(out, &types.InventoryLedgerEntry{ID: uuid.New(), GroupID: groupID, WarehouseID: warehouseID})
+  }
+  return out, nil
+}
+
+// moveTaskFixture is a warehouse with an in-progress plan of two tasks,
+// moving ten eaches of one item from bin A-01-01 to B-02-03.
+type moveTaskFixture struct {
+  ms            *moveTaskService
+  warehouse     *types.Warehouse
+  plan          *types.SlottingPlan
+  task          *types.MoveTask
+  other         *types.MoveTask
+  item          *types.Item
+  worker        uuid.UUID
+  tasks         *fakeMoveTaskRepo
+  scenarios     *fakeScenarioRepo
+  inventory     *fakeInventoryService
+}
+
+func newMoveTaskFixture() *moveTaskFixture {
+  company := &types.Company{ID: uuid.New(), Name: "Acme"}
+  warehouse := &types.Warehouse{ID: uuid.New(), CompanyID: company.ID}
+  from := &types.WarehouseLocation{ID: uuid.New(), WarehouseID: warehouse.ID, FullCode: "A-01-01"}
+  to := &types.WarehouseLocation{ID: uuid.New(), WarehouseID: warehouse.ID, FullCode: "B-02-03"}
+  item := &types.Item{ID: uuid.New(), CompanyID: company.ID, SKU: "SKU-1"}
+  item.UOMs = []*types.ItemUOM{{ItemID: item.ID, UOM: types.UOMEach, GTIN: testGTIN(7)}}
+  plan := &types.SlottingPlan{ID: uuid.New(), WarehouseID: warehouse.ID, CompanyID: company.ID, Status: types.SlottingPlanInProgress, MoveCount: 2}
+  newTask := func() *types.MoveTask {
+    return &types.MoveTask{
+      ID:             uuid.New(),
+      PlanID:         plan.ID,
+      WarehouseID:    warehouse.ID,
+      CompanyID:      company.ID,
+      ItemID:         item.ID,
+      FromLocationID: from.ID,
+      ToLocationID:   to.ID,
+      Lot:            "L1",
+      Quantity:       10,
+      Status:         types.MoveTaskOpen,
+    }
+  }
+  f := &moveTaskFixture{
+    warehouse: warehouse,
+    plan:      plan,
+    task:      newTask(),
+    other:     newTask(),
+    item:      item,
+    worker:    uuid.New(),
+    scenarios: &fakeScenarioRepo{plans: []*types.SlottingPlan{plan}},
+    inventory: &fakeInventoryService{},
+  }
+  f.tasks = &fakeMoveTaskRepo{tasks: []*types.MoveTask{f.task, f.other}}
+  companyRepo := &fakeCompanyRepo{companies: []*types.Company{company}}
+  f.ms = NewMoveTaskService(
+    nil,
+    testLogger(),
+    NewWarehouseService(nil, testLogger(), nil, nil, companyRepo, nil, nil, &fakeWarehouseRepo{warehouses: []*types.Warehouse{warehouse}}),
+    f.inventory,
+    companyRepo,
+    &fakeUserRepo{},
+    &fakeLocationRepo{locations: []*types.WarehouseLocation{from, to}},
+    &fakeItemRepo{items: []*types.Item{item}},
+    nil,
+    f.scenarios,
+    f.tasks,
+  ).(*moveTaskService)
+  return f
+}
+
+// as is a request context for a user of the warehouse's company.
+func (f *moveTaskFixture) as(userID uuid.UUID) context.Context {
+  rd := &requestdata.RequestData{UserType: "company", UserID: userID, CompanyID: f.warehouse.CompanyID}
+  return requestdata.WithRequestData(context.Background(), rd)
+}
+
+// stored is the task as the repo holds it.
+func (f *moveTaskFixture) stored(taskID uuid.UUID) *types.MoveTask {
+  for _, t := range f.tasks.tasks {
+    if t.ID == taskID {
+      return t
+    }
+  }
+  return nil
+}
+
+// TestMoveTaskTransitions runs each transition from each state it may or may
+// not start in, as the task's holder or as someone else.
+func TestMoveTaskTransitions(t *testing.T) {
+  claim := func(f *moveTaskFixture, ctx context.Context) (*MoveTaskUpdate, error) {
+    return f.ms.ClaimTask(ctx, &gorm.DB{}, f.warehouse.ID, f.task.ID)
+  }
+  start := func(f *moveTaskFixture, ctx context.Context) (*MoveTaskUpdate, error) {
+    return f.ms.StartTask(ctx, &gorm.DB{}, f.warehouse.ID, f.task.ID)
+  }
+  exception := func(reason types.MoveTaskExceptionReason, note string) func(f *moveTaskFixture, ctx context.Context) (*MoveTaskUpdate, error) {
+    return func(f *moveTaskFixture, ctx context.Context) (*MoveTaskUpdate, error) {
+      return f.ms.ReportException(ctx, &gorm.DB{}, f.warehouse.ID, f.task.ID, MoveTaskExceptionInput{Reason: reason, Note: note})
+    }
+  }
+  reopen := func(f *moveTaskFixture, ctx context.Context) (*MoveTaskUpdate, error) {
+    return f.ms.ReopenTask(ctx, &gorm.DB{}, f.warehouse.ID, f.task.ID)
+  }
+  cancel := func(f *moveTaskFixture, ctx context.Context) (*MoveTaskUpdate, error) {
+    return f.ms.CancelTask(ctx, &gorm.DB{}, f.warehouse.ID, f.task.ID)
+  }
+  confirm := func(f *moveTaskFixture, ctx context.Context) (*MoveTaskUpdate, error) {
+    return f.ms.ConfirmTask(ctx, &gorm.DB{}, f.warehouse.ID, f.task.ID, MoveTaskConfirmation{LocationCode: "B-02-03", ItemCode: "SKU-1"})
+  }
+
+  tests := []struct {
+    name          string
+    status        types.MoveTaskStatus
+    held          bool
+    heldByOther   bool
+    do            func(f *moveTaskFixture, ctx context.Context) (*MoveTaskUpdate, error)
+    wantErr       bool
+    wantStatus    types.MoveTaskStatus
+  }{
+    {name: "claim open", status: types.MoveTaskOpen, do: claim, wantStatus: types.MoveTaskClaimed},
+    {name: "claim own assigned", status: types.MoveTaskAssigned, held: true, do: claim, wantStatus: types.MoveTaskClaimed},
+    {name: "claim another's assigned", status: types.MoveTaskAssigned, heldByOther: true, do: claim, wantErr: true},
+    {name: "claim started", status: types.MoveTaskInProgress, held: true, do: claim, wantErr: true},
+    {name: "start claimed", status: types.MoveTaskClaimed, held: true, do: start, wantStatus: types.MoveTaskInProgress},
+    {name: "start another's claimed", status: types.MoveTaskClaimed, heldByOther: true, do: start, wantErr: true},
+    {name: "start open", status: types.MoveTaskOpen, do: start, wantErr: true},
+    {name: "confirm claimed", status: types.MoveTaskClaimed, held: true, do: confirm, wantErr: true},
+    {name: "exception while started", status: types.MoveTaskInProgress, held: true, do: exception(types.MoveTaskExceptionShortPick, ""), wantStatus: types.MoveTaskException},
+    {name: "exception while claimed", status: types.MoveTaskClaimed, held: true, do: exception(types.MoveTaskExceptionOther, "pallet in the way"), wantStatus: types.MoveTaskException},
+    {name: "exception of type other needs a note", status: types.MoveTaskInProgress, held: true, do: exception(types.MoveTaskExceptionOther, "  "), wantErr: true},
+    {name: "exception with unknown reason", status: types.MoveTaskInProgress, held: true, do: exception("lost", ""), wantErr: true},
+    {name: "exception on another's task", status: types.MoveTaskInProgress, heldByOther: true, do: exception(types.MoveTaskExceptionDamaged, ""), wantErr: true},
+    {name: "exception on open", status: types.MoveTaskOpen, do: exception(types.MoveTaskExceptionDamaged, ""), wantErr: true},
+    {name: "reopen to assignee", status: types.MoveTaskException, held: true, do: reopen, wantStatus: types.MoveTaskAssigned},
+    {name: "reopen to the pool", status: types.MoveTaskException, do: reopen, wantStatus: types.MoveTaskOpen},
+    {name: "reopen open", status: types.MoveTaskOpen, do: reopen, wantErr: true},
+    {name: "cancel exception", status: types.MoveTaskException, held: true, do: cancel, wantStatus: types.MoveTaskCanceled},
+    {name: "cancel completed", status: types.MoveTaskCompleted, held: true, do: cancel, wantErr: true},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      f := newMoveTaskFixture()
+      f.task.Status = tt.status
+      if tt.held {
+        f.task.AssignedToID = &f.worker
+      }
+      if tt.heldByOther {
+        otherID := uuid.New()
+        f.task.AssignedToID = &otherID
+      }
+      if tt.status == types.MoveTaskException {
+        f.task.ExceptionReason = types.MoveTaskExceptionDamaged
+      }
+
+      update, err := tt.do(f, f.as(f.worker))
+      if tt.wantErr {
+        if err == nil {
+          t.Fatalf("error = nil, want an error")
+        }
+        if got := f.stored(f.task.ID); got.Status != tt.status || f.tasks.updates != 0 {
+          t.Errorf("stored status = %s after %d updates, want %s untouched", got.Status, f.tasks.updates, tt.status)
+        }
+        return
+      }
+      if err != nil {
+        t.Fatalf("error = %v", err)
+      }
+      got := f.stored(f.task.ID)
+      if got.Status != tt.wantStatus || update.Task.Status != tt.wantStatus {
+        t.Fatalf("status = %s (update %s), want %s", got.Status, update.Task.Status, tt.wantStatus)
+      }
+      switch tt.wantStatus {
+      case types.MoveTaskClaimed:
+        if got.AssignedToID == nil || *got.AssignedToID != f.worker || got.ClaimedAt == nil {
+          t.Errorf("claimed task held by %v at %v, want the caller", got.AssignedToID, got.ClaimedAt)
+        }
+      case types.MoveTaskInProgress:
+        if got.StartedAt == nil {
+          t.Error("started task has no start time")
+        }
+      case types.MoveTaskAssigned, types.MoveTaskOpen:
+        if got.ClaimedAt != nil || got.StartedAt != nil || got.ExceptionReason != "" {
+          t.Errorf("reopened task kept claimed %v, started %v, exception %q", got.ClaimedAt, got.StartedAt, got.ExceptionReason)
+        }
+      }
+      if update.Task.Item == nil || update.Task.ToLocation == nil || update.Progress.Total != 2 {
+        t.Errorf("update = %+v, want the task's details and its plan's progress", update)
+      }
+    })
+  }
+}
+
+// TestMoveTaskClaimStartConfirm walks one task from the open pool to done.
+func TestMoveTaskClaimStartConfirm(t *testing.T) {
+  f := newMoveTaskFixture()
+  ctx := f.as(f.worker)
+  if _, err := f.ms.ClaimTask(ctx, &gorm.DB{}, f.warehouse.ID, f.task.ID); err != nil {
+    t.Fatalf("ClaimTask() error = %v", err)
+  }
+  if _, err := f.ms.ClaimTask(f.as(uuid.New()), &gorm.DB{}, f.warehouse.ID, f.task.ID); err == nil {
+    t.Fatal("second ClaimTask() error = nil, want the task taken")
+  }
+  if _, err := f.ms.StartTask(ctx, &gorm.DB{}, f.warehouse.ID, f.task.ID); err != nil {
+    t.Fatalf("StartTask() error = %v", err)
+  }
+  update, err := f.ms.ConfirmTask(ctx, &gorm.DB{}, f.warehouse.ID, f.task.ID, MoveTaskConfirmation{LocationCode: "B-02-03", ItemCode: "SKU-1"})
+  if err != nil {
+    t.Fatalf("ConfirmTask() error = %v", err)
+  }
+  want := MovePlanProgress{PlanID: f.plan.ID, Status: types.SlottingPlanInProgress, Total: 2, Open: 1, Completed: 1}
+  if update.Progress != want {
+    t.Errorf("progress = %+v, want %+v", update.Progress, want)
+  }
+  if got := f.stored(f.task.ID); got.Status != types.MoveTaskCompleted || got.CompletedByID == nil || *got.CompletedByID != f.worker {
+    t.Errorf("task = %s completed by %v, want completed by the worker", got.Status, got.CompletedByID)
+  }
+}
+
+func TestConfirmTask(t *testing.T) {
+  tests := []struct {
+    name          string
+    input         MoveTaskConfirmation
+    holder        bool
+    inventoryErr  error
+    wantErr       bool
+    wantQuantity  int
+  }{
+    {name: "sku scan moves the whole task", input: MoveTaskConfirmation{LocationCode: "B-02-03", ItemCode: "SKU-1"}, holder: true, wantQuantity: 10},
+    {name: "gtin scan and lower case bin", input: MoveTaskConfirmation{LocationCode: " b-02-03 ", ItemCode: testGTIN(7)}, holder: true, wantQuantity: 10},
+    {name: "short move", input: MoveTaskConfirmation{LocationCode: "B-02-03", ItemCode: "sku-1", Quantity: 4}, holder: true, wantQuantity: 4},
+    {name: "scanned the source bin", input: MoveTaskConfirmation{LocationCode: "A-01-01", ItemCode: "SKU-1"}, holder: true, wantErr: true},
+    {name: "scanned another item", input: MoveTaskConfirmation{LocationCode: "B-02-03", ItemCode: "SKU-2"}, holder: true, wantErr: true},
+    {name: "scanned another gtin", input: MoveTaskConfirmation{LocationCode: "B-02-03", ItemCode: testGTIN(8)}, holder: true, wantErr: true},
+    {name: "missing scan", input: MoveTaskConfirmation{LocationCode: "B-02-03"}, holder: true, wantErr: true},
+    {name: "more than the task", input: MoveTaskConfirmation{LocationCode: "B-02-03", ItemCode: "SKU-1", Quantity: 11}, holder: true, wantErr: true},
+    {name: "negative quantity", input: MoveTaskConfirmation{LocationCode: "B-02-03", ItemCode: "SKU-1", Quantity: -1}, holder: true, wantErr: true},
+    {name: "not the holder", input: MoveTaskConfirmation{LocationCode: "B-02-03", ItemCode: "SKU-1"}, wantErr: true},
+    {name: "ledger refuses the move", input: MoveTaskConfirmation{LocationCode: "B-02-03", ItemCode: "SKU-1"}, holder: true, inventoryErr: errors.New("not enough stock"), wantErr: true},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      f := newMoveTaskFixture()
+      f.task.Status = types.MoveTaskInProgress
+      holder := uuid.New()
+      if tt.holder {
+        holder = f.worker
+      }
+      f.task.AssignedToID = &holder
+      f.inventory.err = tt.inventoryErr
+
+      _, err := f.ms.ConfirmTask(f.as(f.worker), &gorm.DB{}, f.warehouse.ID, f.task.ID, tt.input)
+      got := f.stored(f.task.ID)
+      if tt.wantErr {
+        if err == nil {
+          t.Fatal("ConfirmTask() error = nil, want an error")
+        }
+        if got.Status != types.MoveTaskInProgress || len(f.inventory.inputs) != 0 {
+          t.Errorf("task %s with %d moves booked, want it still started and nothing moved", got.Status, len(f.inventory.inputs))
+        }
+        return
+      }
+      if err != nil {
+        t.Fatalf("ConfirmTask() error = %v", err)
+      }
+      if len(f.inventory.inputs) != 1 {
+        t.Fatalf("booked %d moves, want 1", len(f.inventory.inputs))
+      }
+      in := f.inventory.inputs[0]
+      if in.Type != types.InventoryMove || in.ItemID != f.task.ItemID || in.LocationID != f.task.FromLocationID ||
+        in.ToLocationID == nil || *in.ToLocationID != f.task.ToLocationID || in.UOM != types.UOMEach ||
+        in.Quantity != tt.wantQuantity || in.Lot != "L1" || in.Reference != "move-task:"+f.task.ID.String() {
+        t.Errorf("booked %+v, want %d eaches of lot L1 from the source to the destination", in, tt.wantQuantity)
+      }
+      if got.Status != types.MoveTaskCompleted || got.ConfirmedQuantity != tt.wantQuantity || got.LedgerGroupID == nil || got.CompletedAt == nil {
+        t.Errorf("task = %s confirmed %d ledger %v, want completed with %d", got.Status, got.ConfirmedQuantity, got.LedgerGroupID, tt.wantQuantity)
+      }
+    })
+  }
+}
+
+// TestMoveTaskCompletesPlan completes the plan once its last task is done
+// and refuses changes to tasks of a plan that is not in progress.
+func TestMoveTaskCompletesPlan(t *testing.T) {
+  f := newMoveTaskFixture()
+  f.task.Status = types.MoveTaskCompleted
+  f.other.Status = types.MoveTaskException
+  ctx := f.as(f.worker)
+
+  update, err := f.ms.CancelTask(ctx, &gorm.DB{}, f.warehouse.ID, f.other.ID)
+  if err != nil {
+    t.Fatalf("CancelTask() error = %v", err)
+  }
+  want := MovePlanProgress{PlanID: f.plan.ID, Status: types.SlottingPlanCompleted, Total: 2, Completed: 1, Canceled: 1}
+  if update.Progress != want {
+    t.Errorf("progress = %+v, want %+v", update.Progress, want)
+  }
+  if plan := f.scenarios.plans[0]; plan.Status != types.SlottingPlanCompleted || plan.CompletedAt == nil || f.scenarios.planUpdates != 1 {
+    t.Fatalf("plan = %s completed at %v, want completed once", plan.Status, plan.CompletedAt)
+  }
+
+  f.task.Status = types.MoveTaskException
+  f.tasks.tasks[0] = f.task
+  if _, err := f.ms.ReopenTask(ctx, &gorm.DB{}, f.warehouse.ID, f.task.ID); err == nil {
+    t.Error("ReopenTask() on a completed plan error = nil, want an error")
+  }
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "io"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/events"
+  "github.com/slotter-org/slotter-backend/internal/layout"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+const (
+  // MaxOrderLineImportBytes is the largest file accepted by ImportOrderLines.
+  MaxOrderLineImportBytes     = 64 << 20
+  // MaxOrderLinesPerRequest caps the JSON body of IngestOrderLines.
+  MaxOrderLinesPerRequest     = 10000
+  maxOrderLineImportRows      = 500000
+  maxOrderIDLength            = 64
+  skuLookupChunk              = 1000
+)
+
+// ErrOrderLineImportInvalid is returned together with a report when order
+// lines are rejected because some of them failed validation.
+var ErrOrderLineImportInvalid = errors.New("order line import has invalid rows")
+
+// OrderLineInput is one historical order line. Quantity is in eaches.
+type OrderLineInput struct {
+  OrderID         string                  `json:"orderID"`
+  SKU             string                  `json:"sku"`
+  Quantity        int                     `json:"quantity"`
+  OrderedAt       time.Time               `json:"orderedAt"`
+}
+
+// OrderLineImportError points at a bad line. For a file Row is 1-based with
+// the header as row 1; for a JSON body it is the 1-based index in the list.
+type OrderLineImportError struct {
+  Row             int                     `json:"row"`
+  OrderID         string                  `json:"orderID,omitempty"`
+  SKU             string                  `json:"sku,omitempty"`
+  Message         string                  `json:"message"`
+}
+
+// OrderLineImportReport describes what an ingestion did, or would do on a dry
+// run. Lines repeating an order and SKU are summed into one (Merged counts
+// them), and a line already stored for that order and SKU is replaced.
+type OrderLineImportReport struct {
+  WarehouseID     uuid.UUID               `json:"warehouseID"`
+  CompanyID       uuid.UUID               `json:"companyID"`
+  DryRun          bool                    `json:"dryRun"`
+  Valid           bool                    `json:"valid"`
+  TotalRows       int                     `json:"totalRows"`
+  Imported        int                     `json:"imported"`
+  Merged          int                     `json:"merged"`
+  Errors          []OrderLineImportError  `json:"errors"`
+}
+
+type orderLineRow struct {
+  row             int
+  input           OrderLineInput
+}
+
+// orderLineColumnAliases maps accepted headers to the canonical column.
+var orderLineColumnAliases = map[string]string{
+  "order_id":     "order_id",
+  "orderid":      "order_id",
+  "order":        "order_id",
+  "order_number": "order_id",
+  "order_no":     "order_id",
+  "sku":          "sku",
+  "item":         "sku",
+  "item_sku":     "sku",
+  "quantity":     "quantity",
+  "qty":          "quantity",
+  "units":        "quantity",
+  "ordered_at":   "ordered_at",
+  "timestamp":    "ordered_at",
+  "order_date":   "ordered_at",
+  "date":         "ordered_at",
+  "created_at":   "ordered_at",
+}
+
+var orderLineTimeLayouts = []string{
+  time.RFC3339,
+  "2006-01-02T15:04:05",
+  "2006-01-02 15:04:05",
+  "2006-01-02 15:04",
+  "2006-01-02",
+  "01/02/2006 15:04:05",
+  "01/02/2006 15:04",
+  "01/02/2006",
+  "1/2/2006 15:04",
+  "1/2/2006",
+  "1/2/06 15:04",
+  "1/2/06",
+}
+
+//----------------------------------------------------------------------------------------
+// Ingest
+//----------------------------------------------------------------------------------------
+
+// IngestOrderLines stores order lines sent as JSON. Either all of them are
+// stored or, when one is invalid, none.
+func (vs *velocityService) IngestOrderLines(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, inputs []OrderLineInput) (*OrderLineImportReport, error) {
+  vs.log.Info("Starting IngestOrderLines now...", "warehouseID", warehouseID, "lines", len(inputs))
+  if len(inputs) == 0 {
+    return nil, fmt.Errorf("no order lines provided")
+  }
+  if len(inputs) > MaxOrderLinesPerRequest {
+    return nil, fmt.Errorf("too many order lines in one request (max %d), use the file import instead", MaxOrderLinesPerRequest)
+  }
+  rows := make([]orderLineRow, len(inputs))
+  for i, in := range inputs {
+    rows[i] = orderLineRow{row: i + 1, input: in}
+  }
+  return vs.runOrderLineImport(ctx, tx, warehouseID, rows, nil, false)
+}
+
+// ImportOrderLines stores order lines from a .csv or .xlsx with the columns
+// order_id, sku, quantity and ordered_at (common aliases such as qty or
+// order_date are accepted).
+func (vs *velocityService) ImportOrderLines(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filename string, file io.Reader, dryRun bool) (*OrderLineImportReport, error) {
+  vs.log.Info("Starting ImportOrderLines now...", "warehouseID", warehouseID, "filename", filename, "dryRun", dryRun)
+  records, err := layout.ReadSheet(filename, file, maxOrderLineImportRows)
+  if err != nil {
+    return nil, err
+  }
+  rows, rowErrs, err := parseOrderLineSheet(records)
+  if err != nil {
+    return nil, err
+  }
+  return vs.runOrderLineImport(ctx, tx, warehouseID, rows, rowErrs, dryRun)
+}
+
+func (vs *velocityService) runOrderLineImport(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, rows []orderLineRow, rowErrs []OrderLineImportError, dryRun bool) (*OrderLineImportReport, error) {
+  if tx == nil {
+    var out *OrderLineImportReport
+    err := vs.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      res, err := vs.importOrderLinesLogic(ctx, innerTx, warehouseID, rows, rowErrs, dryRun)
+      out = res
+      return err
+    })
+    if err != nil {
+      return out, err
+    }
+    return out, nil
+  }
+  return vs.importOrderLinesLogic(ctx, tx, warehouseID, rows, rowErrs, dryRun)
+}
+
+func (vs *velocityService) importOrderLinesLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, rows []orderLineRow, rowErrs []OrderLineImportError, dryRun bool) (*OrderLineImportReport, error) {
+  warehouse, err := vs.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, err
+  }
+  report := &OrderLineImportReport{
+    WarehouseID: warehouseID,
+    CompanyID:   warehouse.CompanyID,
+    DryRun:      dryRun,
+    TotalRows:   len(rows) + len(rowErrs),
+    Errors:      append([]OrderLineImportError{}, rowErrs...),
+  }
+  fail := func(r orderLineRow, format string, args ...interface{}) {
+    report.Errors = append(report.Errors, OrderLineImportError{Row: r.row, OrderID: r.input.OrderID, SKU: r.input.SKU, Message: fmt.Sprintf(format, args...)})
+  }
+
+  latest := time.Now().Add(24 * time.Hour)
+  var valid []orderLineRow
+  skuSeen := map[string]bool{}
+  var skus []string
+  for _, r := range rows {
+    r.input.OrderID = strings.TrimSpace(r.input.OrderID)
+    r.input.SKU = strings.TrimSpace(r.input.SKU)
+    switch {
+    case r.input.OrderID == "":
+      fail(r, "order ID is required")
+    case len(r.input.OrderID) > maxOrderIDLength:
+      fail(r, "order ID is longer than %d characters", maxOrderIDLength)
+    case r.input.SKU == "":
+      fail(r, "SKU is required")
+    case r.input.Quantity <= 0:
+      fail(r, "quantity must be positive")
+    case r.input.OrderedAt.IsZero():
+      fail(r, "order timestamp is required")
+    case r.input.OrderedAt.After(latest):
+      fail(r, "order timestamp %s is in the future", r.input.OrderedAt.Format(time.RFC3339))
+    default:
+      valid = append(valid, r)
+      if !skuSeen[r.input.SKU] {
+        skuSeen[r.input.SKU] = true
+        skus = append(skus, r.input.SKU)
+      }
+    }
+  }
+
+  itemBySKU := map[string]*types.Item{}
+  for start := 0; start < len(skus); start += skuLookupChunk {
+    end := start + skuLookupChunk
+    if end > len(skus) {
+      end = len(skus)
+    }
+    items, err := vs.itemRepo.GetBySKUs(ctx, tx, warehouse.CompanyID, skus[start:end])
+    if err != nil {
+      return nil, fmt.Errorf("failed to look up SKUs: %w", err)
+    }
+    for _, it := range items {
+      itemBySKU[it.SKU] = it
+    }
+  }
+
+  type lineKey struct {
+    orderID string
+    itemID  uuid.UUID
+  }
+  merged := map[lineKey]*types.OrderLine{}
+  var lines []*types.OrderLine
+  for _, r := range valid {
+    item := itemBySKU[r.input.SKU]
+    if item == nil {
+      fail(r, "unknown SKU %s", r.input.SKU)
+      continue
+    }
+    key := lineKey{orderID: r.input.OrderID, itemID: item.ID}
+    if line, ok := merged[key]; ok {
+      line.Quantity += r.input.Quantity
+      if r.input.OrderedAt.Before(line.OrderedAt) {
+        line.OrderedAt = r.input.OrderedAt.UTC()
+      }
+      report.Merged++
+      continue
+    }
+    line := &types.OrderLine{
+      WarehouseID: warehouseID,
+      CompanyID:   warehouse.CompanyID,
+      OrderID:     r.input.OrderID,
+      ItemID:      item.ID,
+      SKU:         item.SKU,
+      Quantity:    r.input.Quantity,
+      OrderedAt:   r.input.OrderedAt.UTC(),
+    }
+    merged[key] = line
+    lines = append(lines, line)
+  }
+
+  report.Valid = len(report.Errors) == 0
+  sort.SliceStable(report.Errors, func(i, j int) bool { return report.Errors[i].Row < report.Errors[j].Row })
+  if !report.Valid {
+    if dryRun {
+      return report, nil
+    }
+    vs.log.Warn("Rejected order line import", "warehouseID", warehouseID, "errors", len(report.Errors))
+    return report, ErrOrderLineImportInvalid
+  }
+  report.Imported = len(lines)
+  if dryRun {
+    return report, nil
+  }
+  if _, err := vs.orderLineRepo.Upsert(ctx, tx, lines); err != nil {
+    vs.log.Warn("Failed to store order lines", "error", err)
+    return nil, fmt.Errorf("failed to store order lines: %w", err)
+  }
+  vs.log.Info("Order lines imported", "warehouseID", warehouseID, "imported", report.Imported, "merged", report.Merged)
+  if err := recordCompanyEvent(ctx, tx, report.CompanyID, events.OrderLinesImported, report); err != nil {
+    return nil, err
+  }
+  return report, nil
+}
+
+// parseOrderLineSheet maps the sheet's header through orderLineColumnAliases
+// and turns each row into an OrderLineInput. Cells that do not parse are
+// reported per row.
+func parseOrderLineSheet(records [][]string) ([]orderLineRow, []OrderLineImportError, error) {
+  if len(records) == 0 {
+    return nil, nil, fmt.Errorf("file is empty")
+  }
+  if len(records)-1 > maxOrderLineImportRows {
+    return nil, nil, fmt.Errorf("file has %d rows, the limit is %d", len(records)-1, maxOrderLineImportRows)
+  }
+  columns := map[string]int{}
+  for i, name := range records[0] {
+    name = strings.ToLower(strings.TrimSpace(name))
+    name = strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+    if canonical, ok := orderLineColumnAliases[name]; ok {
+      if _, dup := columns[canonical]; !dup {
+        columns[canonical] = i
+      }
+    }
+  }
+  for _, required := range []string{"order_id", "sku", "quantity", "ordered_at"} {
+    if _, ok := columns[required]; !ok {
+      return nil, nil, fmt.Errorf("missing required column %q", required)
+    }
+  }
+
+  var rows []orderLineRow
+  var rowErrs []OrderLineImportError
+  for i, record := range records[1:] {
+    line := i + 2
+    cell := func(name string) string {
+      idx := columns[name]
+      if idx >= len(record) {
+        return ""
+      }
+      return strings.TrimSpace(record[idx])
+    }
+    blank := true
+    for _, c := range record {
+      if strings.TrimSpace(c) != "" {
+        blank = false
+        break
+      }
+    }
+    if blank {
+      continue
+    }
+    input := OrderLineInput{OrderID: cell("order_id"), SKU: cell("sku")}
+    var problems []string
+    if raw := cell("quantity"); raw != "" {
+      qty, err := strconv.ParseFloat(raw, 64)
+      if err != nil || qty != float64(int(qty)) {
+        problems = append(problems, fmt.Sprintf("quantity: %q is not a whole number", raw))
+      }
+      input.Quantity = int(qty)
+    }
+    if raw := cell("ordered_at"); raw != "" {
+      t, ok := parseOrderTimestamp(raw)
+      if !ok {
+        problems = append(problems, fmt.Sprintf("ordered_at: %q is not a date or timestamp", raw))
+      }
+      input.OrderedAt = t
+    }
+    if len(problems) > 0 {
+      rowErrs = append(rowErrs, OrderLineImportError{Row: line, OrderID: input.OrderID, SKU: input.SKU, Message: strings.Join(problems, "; ")})
+      continue
+    }
+    rows = append(rows, orderLineRow{row: line, input: input})
+  }
+  return rows, rowErrs, nil
+}
+
+// parseOrderTimestamp accepts the layouts in orderLineTimeLayouts, read as
+// UTC when they carry no zone.
+func parseOrderTimestamp(raw string) (time.Time, bool) {
+  for _, l := range orderLineTimeLayouts {
+    if t, err := time.Parse(l, raw); err == nil {
+      return t.UTC(), true
+    }
+  }
+  return time.Time{}, false
+}
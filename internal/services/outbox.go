@@ -0,0 +1,188 @@
+package services
+
+import (
+  "context"
+  "fmt"
+  "strings"
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// OutboxService records outbound email/SMS/webhook calls in the caller's
+// transaction so a message is only ever delivered if the business change
+// that produced it commits. Delivery itself is done by the OutboxDispatcher.
+type OutboxService interface {
+  Enqueue(ctx context.Context, tx *gorm.DB, msgs []*types.OutboxMessage) ([]*types.OutboxMessage, error)
+  ListFailed(ctx context.Context, tx *gorm.DB, statuses []types.OutboxStatus) ([]*types.OutboxMessage, error)
+  RetryMessages(ctx context.Context, tx *gorm.DB, ids []uuid.UUID) ([]*types.OutboxMessage, error)
+  retryMessagesLogic(ctx context.Context, tx *gorm.DB, ids []uuid.UUID) ([]*types.OutboxMessage, error)
+  SetNotifier(notify func())
+  Notify()
+}
+
+type outboxService struct {
+  db                *gorm.DB
+  log               *logger.Logger
+  outboxRepo        repos.OutboxMessageRepo
+  maxAttempts       int
+  notify            func()
+}
+
+func NewOutboxService(db *gorm.DB, log *logger.Logger, outboxRepo repos.OutboxMessageRepo, maxAttempts int) OutboxService {
+  if maxAttempts <= 0 {
+    maxAttempts = 8
+  }
+  return &outboxService{
+    db:           db,
+    log:          log.With("service", "OutboxService"),
+    outboxRepo:   outboxRepo,
+    maxAttempts:  maxAttempts,
+  }
+}
+
+// SetNotifier lets the dispatcher be woken as soon as a transaction that
+// enqueued messages commits instead of waiting for its next poll.
+func (obs *outboxService) SetNotifier(notify func()) {
+  obs.notify = notify
+}
+
+func (obs *outboxService) Notify() {
+  if obs.notify != nil {
+    obs.notify()
+  }
+}
+
+func (obs *outboxService) Enqueue(ctx context.Context, tx *gorm.DB, msgs []*types.OutboxMessage) ([]*types.OutboxMessage, error) {
+  obs.log.Info("Starting Enqueue now...", "count", len(msgs))
+  for _, m := range msgs {
+    switch m.Channel {
+    case types.OutboxChannelEmail, types.OutboxChannelSMS, types.OutboxChannelWebhook, types.OutboxChannelEvent:
+    default:
+      return nil, fmt.Errorf("unsupported outbox channel: %s", m.Channel)
+    }
+    if strings.TrimSpace(m.Recipient) == "" {
+      return nil, fmt.Errorf("outbox message has no recipient")
+    }
+    m.Status = types.OutboxStatusPending
+    m.Attempts = 0
+    if m.MaxAttempts <= 0 {
+      m.MaxAttempts = obs.maxAttempts
+    }
+    if m.NextAttemptAt.IsZero() {
+      m.NextAttemptAt = time.Now()
+    }
+  }
+  created, err := obs.outboxRepo.Create(ctx, tx, msgs)
+  if err != nil {
+    return nil, fmt.Errorf("failed to enqueue outbox messages: %w", err)
+  }
+  return created, nil
+}
+
+// ListFailed returns the requester's tenant's messages in the given statuses,
+// defaulting to failed and dead.
+func (obs *outboxService) ListFailed(ctx context.Context, tx *gorm.DB, statuses []types.OutboxStatus) ([]*types.OutboxMessage, error) {
+  obs.log.Info("Starting ListFailed now...")
+  wmsID, companyID, err := outboxTenantFromRequest(ctx)
+  if err != nil {
+    return nil, err
+  }
+  if len(statuses) == 0 {
+    statuses = []types.OutboxStatus{types.OutboxStatusFailed, types.OutboxStatusDead}
+  }
+  return obs.outboxRepo.GetByTenantAndStatuses(ctx, tx, wmsID, companyID, statuses, 500)
+}
+
+func (obs *outboxService) RetryMessages(ctx context.Context, tx *gorm.DB, ids []uuid.UUID) ([]*types.OutboxMessage, error) {
+  if tx == nil {
+    var out []*types.OutboxMessage
+    err := obs.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      retried, err := obs.retryMessagesLogic(ctx, innerTx, ids)
+      if err != nil {
+        return err
+      }
+      out = retried
+      return nil
+    })
+    if err != nil {
+      return nil, err
+    }
+    obs.Notify()
+    return out, nil
+  }
+  return obs.retryMessagesLogic(ctx, tx, ids)
+}
+
+// retryMessagesLogic puts failed or dead messages back in the queue with a
+// fresh attempt budget. Every message must belong to the requester's tenant.
+func (obs *outboxService) retryMessagesLogic(ctx context.Context, tx *gorm.DB, ids []uuid.UUID) ([]*types.OutboxMessage, error) {
+  if len(ids) == 0 {
+    return nil, fmt.Errorf("no outbox message IDs provided")
+  }
+  wmsID, companyID, err := outboxTenantFromRequest(ctx)
+  if err != nil {
+    return nil, err
+  }
+  found, err := obs.outboxRepo.GetByIDs(ctx, tx, ids)
+  if err != nil {
+    return nil, fmt.Errorf("failed to fetch outbox messages: %w", err)
+  }
+  if len(found) != len(ids) {
+    return nil, fmt.Errorf("one or more outbox messages not found")
+  }
+  now := time.Now()
+  for _, m := range found {
+    if !outboxMessageOwnedBy(m, wmsID, companyID) {
+      return nil, fmt.Errorf("outbox message %s does not belong to your organization", m.ID)
+    }
+    if m.Status != types.OutboxStatusFailed && m.Status != types.OutboxStatusDead {
+      return nil, fmt.Errorf("outbox message %s cannot be retried in status: %s", m.ID, m.Status)
+    }
+    m.Status = types.OutboxStatusPending
+    m.Attempts = 0
+    m.NextAttemptAt = now
+    m.LockedUntil = nil
+    m.DeadAt = nil
+  }
+  return obs.outboxRepo.Update(ctx, tx, found)
+}
+
+func outboxTenantFromRequest(ctx context.Context) (*uuid.UUID, *uuid.UUID, error) {
+  rd := requestdata.GetRequestData(ctx)
+  if rd == nil {
+    return nil, nil, fmt.Errorf("no request data in context")
+  }
+  switch rd.UserType {
+  case "wms":
+    if rd.WmsID == uuid.Nil {
+      return nil, nil, fmt.Errorf("wms user has no wms")
+    }
+    wmsID := rd.WmsID
+    return &wmsID, nil, nil
+  case "company":
+    if rd.CompanyID == uuid.Nil {
+      return nil, nil, fmt.Errorf("company user has no company")
+    }
+    companyID := rd.CompanyID
+    return nil, &companyID, nil
+  default:
+    return nil, nil, fmt.Errorf("invalid user type: %s", rd.UserType)
+  }
+}
+
+func outboxMessageOwnedBy(m *types.OutboxMessage, wmsID, companyID *uuid.UUID) bool {
+  if wmsID != nil {
+    return m.WmsID != nil && *m.WmsID == *wmsID
+  }
+  if companyID != nil {
+    return m.CompanyID != nil && *m.CompanyID == *companyID
+  }
+  return false
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "math/rand"
+  "sync"
+  "time"
+
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// OutboxTarget delivers one outbox message over a single channel.
+type OutboxTarget interface {
+  Deliver(ctx context.Context, msg *types.OutboxMessage) error
+}
+
+type emailOutboxTarget struct {
+  emailService EmailService
+}
+
+func NewEmailOutboxTarget(emailService EmailService) OutboxTarget {
+  return &emailOutboxTarget{emailService: emailService}
+}
+
+func (t *emailOutboxTarget) Deliver(ctx context.Context, msg *types.OutboxMessage) error {
+  if t.emailService == nil {
+    return fmt.Errorf("email service is not configured")
+  }
+  err := t.emailService.SendEmail(ctx, msg.Recipient, msg.Subject, msg.Body, msg.HTML, msg.MessageType)
+  if errors.Is(err, ErrInvalidEmailRecipient) {
+    return fmt.Errorf("%w: %v", ErrPermanentDelivery, err)
+  }
+  return err
+}
+
+type textOutboxTarget struct {
+  textService TextService
+}
+
+func NewTextOutboxTarget(textService TextService) OutboxTarget {
+  return &textOutboxTarget{textService: textService}
+}
+
+func (t *textOutboxTarget) Deliver(ctx context.Context, msg *types.OutboxMessage) error {
+  if t.textService == nil {
+    return fmt.Errorf("text service is not configured")
+  }
+  return t.textService.SendText(ctx, msg.Recipient, msg.Body)
+}
+
+type OutboxDispatcherConfig struct {
+  PollInterval  time.Duration
+  BatchSize     int
+  Lease         time.Duration
+  BackoffBase   time.Duration
+  BackoffMax    time.Duration
+}
+
+// OutboxDispatcher polls outbox_message for due rows and hands them to the
+// target registered for their channel. Failures are retried with exponential
+// backoff until MaxAttempts, after which the message is marked dead.
+type OutboxDispatcher struct {
+  log           *logger.Logger
+  outboxRepo    repos.OutboxMessageRepo
+  targets       map[types.OutboxChannel]OutboxTarget
+  cfg           OutboxDispatcherConfig
+  wake          chan struct{}
+  stop          chan struct{}
+  wg            sync.WaitGroup
+  once          sync.Once
+}
+
+func NewOutboxDispatcher(log *logger.Logger, outboxRepo repos.OutboxMessageRepo, targets map[types.OutboxChannel]OutboxTarget, cfg OutboxDispatcherConfig) *OutboxDispatcher {
+  if cfg.PollInterval <= 0 {
+    cfg.PollInterval = 5 * time.Second
+  }
+  if cfg.BatchSize <= 0 {
+    cfg.BatchSize = 20
+  }
+  if cfg.Lease <= 0 {
+    cfg.Lease = 5 * time.Minute
+  }
+  if cfg.BackoffBase <= 0 {
+    cfg.BackoffBase = 30 * time.Second
+  }
+  if cfg.BackoffMax <= 0 {
+    cfg.BackoffMax = time.Hour
+  }
+  return &OutboxDispatcher{
+    log:        log.With("worker", "OutboxDispatcher"),
+    outboxRepo: outboxRepo,
+    targets:    targets,
+    cfg:        cfg,
+    wake:       make(chan struct{}, 1),
+    stop:       make(chan struct{}),
+  }
+}
+
+func (d *OutboxDispatcher) Start(ctx context.Context) {
+  d.wg.Add(1)
+  go func() {
+    defer d.wg.Done()
+    ticker := time.NewTicker(d.cfg.PollInterval)
+    defer ticker.Stop()
+    d.log.Info("Outbox dispatcher started", "pollInterval", d.cfg.PollInterval, "batchSize", d.cfg.BatchSize)
+    for {
+      if _, err := d.DispatchDue(ctx); err != nil {
+        d.log.Warn("Outbox dispatch pass failed", "error", err)
+      }
+      select {
+      case <-ctx.Done():
+        return
+      case <-d.stop:
+        return
+      case <-ticker.C:
+      case <-d.wake:
+      }
+    }
+  }()
+}
+
+func (d *OutboxDispatcher) Stop() {
+  d.once.Do(func() { close(d.stop) })
+  d.wg.Wait()
+}
+
+// Notify wakes the dispatcher without blocking the caller.
+func (d *OutboxDispatcher) Notify() {
+  select {
+  case d.wake <- struct{}{}:
+  default:
+  }
+}
+
+// DispatchDue claims and delivers one batch, returning how many were sent.
+func (d *OutboxDispatcher) DispatchDue(ctx context.Context) (int, error) {
+  now := time.Now()
+  claimed, err := d.outboxRepo.ClaimDue(ctx, nil, now, d.cfg.Lease, d.cfg.BatchSize)
+  if err != nil {
+    return 0, err
+  }
+  sent := 0
+  for _, msg := range claimed {
+    if d.deliver(ctx, msg) {
+      sent++
+    }
+  }
+  return sent, nil
+}
+
+func (d *OutboxDispatcher) deliver(ctx context.Context, msg *types.OutboxMessage) bool {
+  var lease time.Time
+  if msg.LockedUntil != nil {
+    lease = *msg.LockedUntil
+  }
+  msg.Attempts++
+  msg.LockedUntil = nil
+  var deliverErr error
+  target, ok := d.targets[msg.Channel]
+  if !ok || target == nil {
+    deliverErr = fmt.Errorf("no outbox target registered for channel %s", msg.Channel)
+  } else {
+    deliverErr = target.Deliver(ctx, msg)
+  }
+
+  now := time.Now()
+  if deliverErr == nil {
+    msg.Status = types.OutboxStatusSent
+    msg.SentAt = &now
+    msg.LastError = ""
+  } else {
+    msg.LastError = truncateOutboxError(deliverErr.Error())
+    if msg.Attempts >= msg.MaxAttempts || errors.Is(deliverErr, ErrPermanentDelivery) {
+      msg.Status = types.OutboxStatusDead
+      msg.DeadAt = &now
+      d.log.Error("Outbox message moved to dead letter", "id", msg.ID, "channel", msg.Channel, "attempts", msg.Attempts, "error", deliverErr)
+    } else {
+      msg.Status = types.OutboxStatusFailed
+      msg.NextAttemptAt = now.Add(d.backoff(msg.Attempts))
+      d.log.Warn("Outbox delivery failed; will retry", "id", msg.ID, "channel", msg.Channel, "attempts", msg.Attempts, "nextAttemptAt", msg.NextAttemptAt, "error", deliverErr)
+    }
+  }
+  owned, err := d.outboxRepo.UpdateClaimed(ctx, nil, msg, lease)
+  if err != nil {
+    // The lease will expire and the message will be reclaimed.
+    d.log.Error("Failed to record outbox delivery result", "id", msg.ID, "error", err)
+  } else if !owned {
+    d.log.Warn("Outbox lease expired before delivery finished; keeping the newer attempt", "id", msg.ID, "channel", msg.Channel)
+  }
+  return deliverErr == nil
+}
+
+// backoff is base*2^(attempts-1), capped at BackoffMax, with up to 20% jitter
+// so a burst of failures does not retry in lockstep.
+func (d *OutboxDispatcher) backoff(attempts int) time.Duration {
+  delay := d.cfg.BackoffBase
+  for i := 1; i < attempts && delay < d.cfg.BackoffMax; i++ {
+    delay *= 2
+  }
+  if delay > d.cfg.BackoffMax {
+    delay = d.cfg.BackoffMax
+  }
+  jitter := time.Duration(rand.Int63n(int64(delay)/5 + 1))
+  return delay + jitter
+}
+
+func truncateOutboxError(s string) string {
+  const max = 2000
+  if len(s) > max {
+    return s[:max]
+  }
+  return s
+}
@@ -0,0 +1,325 @@
+package services
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "sync"
+  "testing"
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// fakeOutboxMessageRepo keeps messages in memory and claims and releases
+// them the way the Postgres repo does, so several dispatchers can share it.
+type fakeOutboxMessageRepo struct {
+  repos.OutboxMessageRepo
+  mu            sync.Mutex
+  messages      []*types.OutboxMessage
+}
+
+func (f *fakeOutboxMessageRepo) ClaimDue(ctx context.Context, tx *gorm.DB, now time.Time, lease time.Duration, limit int) ([]*types.OutboxMessage, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  var out []*types.OutboxMessage
+  for _, m := range f.messages {
+    if len(out) == limit {
+      break
+    }
+    due := (m.Status == types.OutboxStatusPending || m.Status == types.OutboxStatusFailed) && !m.NextAttemptAt.After(now)
+    expired := m.Status == types.OutboxStatusProcessing && m.LockedUntil != nil && m.LockedUntil.Before(now)
+    if !due && !expired {
+      continue
+    }
+    lockedUntil := now.Add(lease)
+    m.Status = types.OutboxStatusProcessing
+    m.LockedUntil = &lockedUntil
+    c := *m
+    out = append(out, &c)
+  }
+  return out, nil
+}
+
+func (f *fakeOutboxMessageRepo) UpdateClaimed(ctx context.Context, tx *gorm.DB, message *types.OutboxMessage, lockedUntil time.Time) (bool, error) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  for i, m := range f.messages {
+    if m.ID != message.ID {
+      continue
+    }
+    if m.Status != types.OutboxStatusProcessing || m.LockedUntil == nil || !m.LockedUntil.Equal(lockedUntil) {
+      return false, nil
+    }
+    c := *message
+    f.messages[i] = &c
+    return true, nil
+  }
+  return false, nil
+}
+
+// get is a copy of the stored message.
+func (f *fakeOutboxMessageRepo) get(id uuid.UUID) types.OutboxMessage {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  for _, m := range f.messages {
+    if m.ID == id {
+      return *m
+    }
+  }
+  return types.OutboxMessage{}
+}
+
+// makeDue moves a failed message's retry time into the past.
+func (f *fakeOutboxMessageRepo) makeDue(id uuid.UUID) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  for _, m := range f.messages {
+    if m.ID == id {
+      m.NextAttemptAt = time.Now().Add(-time.Second)
+    }
+  }
+}
+
+type fakeOutboxTarget struct {
+  mu            sync.Mutex
+  calls         int
+  deliver       func(ctx context.Context, msg *types.OutboxMessage) error
+}
+
+func (f *fakeOutboxTarget) Deliver(ctx context.Context, msg *types.OutboxMessage) error {
+  f.mu.Lock()
+  f.calls++
+  f.mu.Unlock()
+  if f.deliver == nil {
+    return nil
+  }
+  return f.deliver(ctx, msg)
+}
+
+func (f *fakeOutboxTarget) callCount() int {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  return f.calls
+}
+
+type fakeEmailService struct {
+  EmailService
+  err           error
+}
+
+func (f *fakeEmailService) SendEmail(ctx context.Context, to, subject, body, html, messageType string) error {
+  return f.err
+}
+
+func newOutboxMessage(channel types.OutboxChannel, maxAttempts int) *types.OutboxMessage {
+  return &types.OutboxMessage{
+    ID:            uuid.New(),
+    Channel:       channel,
+    Recipient:     "ops@example.com",
+    Status:        types.OutboxStatusPending,
+    MaxAttempts:   maxAttempts,
+    NextAttemptAt: time.Now().Add(-time.Minute),
+  }
+}
+
+func TestOutboxBackoff(t *testing.T) {
+  d := NewOutboxDispatcher(testLogger(), nil, nil, OutboxDispatcherConfig{BackoffBase: time.Second, BackoffMax: 10 * time.Second})
+  tests := []struct {
+    attempts      int
+    want          time.Duration
+  }{
+    {attempts: 1, want: time.Second},
+    {attempts: 2, want: 2 * time.Second},
+    {attempts: 3, want: 4 * time.Second},
+    {attempts: 4, want: 8 * time.Second},
+    {attempts: 5, want: 10 * time.Second},
+    {attempts: 30, want: 10 * time.Second},
+  }
+  for _, tt := range tests {
+    t.Run(fmt.Sprint(tt.attempts), func(t *testing.T) {
+      for i := 0; i < 100; i++ {
+        if got := d.backoff(tt.attempts); got < tt.want || got > tt.want+tt.want/5 {
+          t.Fatalf("backoff(%d) = %s, want %s plus up to 20%% jitter", tt.attempts, got, tt.want)
+        }
+      }
+    })
+  }
+}
+
+// TestOutboxDispatchRetriesThenDeadLetters fails every delivery and checks
+// the message is retried with a growing delay until its last attempt.
+func TestOutboxDispatchRetriesThenDeadLetters(t *testing.T) {
+  const maxAttempts = 3
+  msg := newOutboxMessage(types.OutboxChannelEmail, maxAttempts)
+  repo := &fakeOutboxMessageRepo{messages: []*types.OutboxMessage{msg}}
+  target := &fakeOutboxTarget{deliver: func(context.Context, *types.OutboxMessage) error { return errors.New("smtp: 421 try again later") }}
+  d := NewOutboxDispatcher(testLogger(), repo, map[types.OutboxChannel]OutboxTarget{types.OutboxChannelEmail: target}, OutboxDispatcherConfig{BackoffBase: time.Minute, BackoffMax: time.Hour})
+
+  for attempt := 1; attempt <= maxAttempts; attempt++ {
+    before := time.Now()
+    sent, err := d.DispatchDue(context.Background())
+    if err != nil || sent != 0 {
+      t.Fatalf("attempt %d: DispatchDue() = %d, %v, want nothing sent", attempt, sent, err)
+    }
+    got := repo.get(msg.ID)
+    if got.Attempts != attempt || got.LastError != "smtp: 421 try again later" || got.LockedUntil != nil {
+      t.Fatalf("attempt %d: stored %+v", attempt, got)
+    }
+    if attempt < maxAttempts {
+      delay := time.Minute << (attempt - 1)
+      if got.Status != types.OutboxStatusFailed || got.NextAttemptAt.Before(before.Add(delay)) || got.NextAttemptAt.After(time.Now().Add(delay+delay/5)) {
+        t.Fatalf("attempt %d: status %s retrying at %s, want failed and retried in %s", attempt, got.Status, got.NextAttemptAt.Sub(before), delay)
+      }
+      if sent, _ := d.DispatchDue(context.Background()); sent != 0 || target.callCount() != attempt {
+        t.Fatalf("attempt %d: retried before its backoff", attempt)
+      }
+      repo.makeDue(msg.ID)
+      continue
+    }
+    if got.Status != types.OutboxStatusDead || got.DeadAt == nil {
+      t.Fatalf("last attempt: status %s dead at %v, want dead", got.Status, got.DeadAt)
+    }
+  }
+
+  repo.makeDue(msg.ID)
+  if _, err := d.DispatchDue(context.Background()); err != nil || target.callCount() != maxAttempts {
+    t.Errorf("dead message delivered again: %d deliveries, want %d", target.callCount(), maxAttempts)
+  }
+}
+
+func TestOutboxDispatchOutcomes(t *testing.T) {
+  tests := []struct {
+    name          string
+    channel       types.OutboxChannel
+    target        OutboxTarget
+    wantSent      int
+    wantStatus    types.OutboxStatus
+    wantError     bool
+  }{
+    {
+      name:       "delivered",
+      channel:    types.OutboxChannelEmail,
+      target:     &fakeOutboxTarget{},
+      wantSent:   1,
+      wantStatus: types.OutboxStatusSent,
+    },
+    {
+      name:       "permanent failure is dead-lettered at once",
+      channel:    types.OutboxChannelSMS,
+      target:     &fakeOutboxTarget{deliver: func(context.Context, *types.OutboxMessage) error { return fmt.Errorf("%w: recipient opted out", ErrPermanentDelivery) }},
+      wantStatus: types.OutboxStatusDead,
+      wantError:  true,
+    },
+    {
+      name:       "invalid email recipient is permanent",
+      channel:    types.OutboxChannelEmail,
+      target:     NewEmailOutboxTarget(&fakeEmailService{err: fmt.Errorf("%w: no mailbox", ErrInvalidEmailRecipient)}),
+      wantStatus: types.OutboxStatusDead,
+      wantError:  true,
+    },
+    {
+      name:       "other email errors are retried",
+      channel:    types.OutboxChannelEmail,
+      target:     NewEmailOutboxTarget(&fakeEmailService{err: errors.New("connection reset")}),
+      wantStatus: types.OutboxStatusFailed,
+      wantError:  true,
+    },
+    {
+      name:       "no target for the channel is retried",
+      channel:    types.OutboxChannelSMS,
+      wantStatus: types.OutboxStatusFailed,
+      wantError:  true,
+    },
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      msg := newOutboxMessage(tt.channel, 5)
+      msg.LastError = "earlier failure"
+      repo := &fakeOutboxMessageRepo{messages: []*types.OutboxMessage{msg}}
+      targets := map[types.OutboxChannel]OutboxTarget{}
+      if tt.target != nil {
+        targets[tt.channel] = tt.target
+      }
+      d := NewOutboxDispatcher(testLogger(), repo, targets, OutboxDispatcherConfig{})
+
+      sent, err := d.DispatchDue(context.Background())
+      if err != nil || sent != tt.wantSent {
+        t.Fatalf("DispatchDue() = %d, %v, want %d sent", sent, err, tt.wantSent)
+      }
+      got := repo.get(msg.ID)
+      if got.Status != tt.wantStatus || got.Attempts != 1 || (got.LastError != "") != tt.wantError {
+        t.Errorf("stored status %s, attempts %d, last error %q, want %s after one attempt", got.Status, got.Attempts, got.LastError, tt.wantStatus)
+      }
+      if (got.SentAt != nil) != (tt.wantStatus == types.OutboxStatusSent) || (got.DeadAt != nil) != (tt.wantStatus == types.OutboxStatusDead) {
+        t.Errorf("sent at %v, dead at %v for status %s", got.SentAt, got.DeadAt, got.Status)
+      }
+    })
+  }
+}
+
+// TestOutboxLeaseOwnership runs two dispatchers on one message: the second
+// leaves it alone while the first holds its lease, and once the lease has
+// expired it takes the message over and the first one's late result is
+// dropped.
+func TestOutboxLeaseOwnership(t *testing.T) {
+  tests := []struct {
+    name          string
+    lease         time.Duration
+    wantTakeover  bool
+  }{
+    {name: "held lease", lease: time.Hour},
+    {name: "expired lease", lease: time.Millisecond, wantTakeover: true},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      msg := newOutboxMessage(types.OutboxChannelEmail, 5)
+      repo := &fakeOutboxMessageRepo{messages: []*types.OutboxMessage{msg}}
+      started := make(chan struct{})
+      release := make(chan struct{})
+      slow := &fakeOutboxTarget{deliver: func(context.Context, *types.OutboxMessage) error {
+        close(started)
+        <-release
+        return errors.New("timed out")
+      }}
+      fast := &fakeOutboxTarget{}
+      first := NewOutboxDispatcher(testLogger(), repo, map[types.OutboxChannel]OutboxTarget{types.OutboxChannelEmail: slow}, OutboxDispatcherConfig{Lease: tt.lease})
+      second := NewOutboxDispatcher(testLogger(), repo, map[types.OutboxChannel]OutboxTarget{types.OutboxChannelEmail: fast}, OutboxDispatcherConfig{})
+
+      done := make(chan struct{})
+      go func() {
+        defer close(done)
+        first.DispatchDue(context.Background())
+      }()
+      <-started
+      time.Sleep(5 * time.Millisecond)
+      sent, err := second.DispatchDue(context.Background())
+      if err != nil {
+        t.Fatalf("second DispatchDue() error = %v", err)
+      }
+      close(release)
+      <-done
+
+      got := repo.get(msg.ID)
+      if !tt.wantTakeover {
+        if sent != 0 || fast.callCount() != 0 {
+          t.Fatalf("second dispatcher delivered a message under a live lease")
+        }
+        if got.Status != types.OutboxStatusFailed || got.Attempts != 1 || got.LastError != "timed out" {
+          t.Errorf("stored status %s, attempts %d, error %q, want the first dispatcher's failure", got.Status, got.Attempts, got.LastError)
+        }
+        return
+      }
+      if sent != 1 {
+        t.Fatalf("second DispatchDue() sent %d, want the expired message", sent)
+      }
+      if got.Status != types.OutboxStatusSent || got.Attempts != 1 || got.LastError != "" || got.SentAt == nil {
+        t.Errorf("stored status %s, attempts %d, error %q, want the second dispatcher's delivery kept", got.Status, got.Attempts, got.LastError)
+      }
+    })
+  }
+}
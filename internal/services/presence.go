@@ -0,0 +1,218 @@
+package services
+
+import (
+  "context"
+  "fmt"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/channels"
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/presence"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// PresenceMember is one online user and what they are looking at.
+type PresenceMember struct {
+  presence.Member
+  User                *types.User           `json:"user,omitempty"`
+}
+
+// PresenceService answers who of a company or WMS is online. Access to a
+// scope follows the SSE channel of the same tenant.
+type PresenceService interface {
+  List(ctx context.Context, tx *gorm.DB, scope string, companyID *uuid.UUID) ([]*PresenceMember, error)
+  SetViewing(ctx context.Context, tx *gorm.DB, connectionID uuid.UUID, warehouseID, scenarioID *uuid.UUID) error
+}
+
+type presenceService struct {
+  log                   *logger.Logger
+  tracker               *presence.Tracker
+  channelService        ChannelService
+  userRepo              repos.UserRepo
+  companyRepo           repos.CompanyRepo
+  warehouseRepo         repos.WarehouseRepo
+  slottingScenarioRepo  repos.SlottingScenarioRepo
+}
+
+func NewPresenceService(
+  log                   *logger.Logger,
+  tracker               *presence.Tracker,
+  channelService        ChannelService,
+  userRepo              repos.UserRepo,
+  companyRepo           repos.CompanyRepo,
+  warehouseRepo         repos.WarehouseRepo,
+  slottingScenarioRepo  repos.SlottingScenarioRepo,
+) PresenceService {
+  return &presenceService{
+    log:                  log.With("service", "PresenceService"),
+    tracker:              tracker,
+    channelService:       channelService,
+    userRepo:             userRepo,
+    companyRepo:          companyRepo,
+    warehouseRepo:        warehouseRepo,
+    slottingScenarioRepo: slottingScenarioRepo,
+  }
+}
+
+// List returns the online members of the requester's company (scope
+// "company"; WMS users pass the companyID of one of their companies) or
+// of the requester's WMS (scope "wms"). A company's members include the
+// users of its WMS who have one of its warehouses open.
+func (ps *presenceService) List(ctx context.Context, tx *gorm.DB, scope string, companyID *uuid.UUID) ([]*PresenceMember, error) {
+  ps.log.Info("Starting List now...", "scope", scope)
+  rd := requestdata.GetRequestData(ctx)
+  if rd == nil {
+    return nil, fmt.Errorf("no request data in context")
+  }
+  var conns []presence.Connection
+  switch scope {
+  case "company", "":
+    id := rd.CompanyID
+    if companyID != nil {
+      id = *companyID
+    }
+    if id == uuid.Nil {
+      return nil, fmt.Errorf("companyId is required for wms users")
+    }
+    found, err := ps.companyConnections(ctx, tx, id)
+    if err != nil {
+      return nil, err
+    }
+    conns = found
+  case "wms":
+    tenant := channels.Wms(rd.WmsID)
+    if err := ps.channelService.Authorize(ctx, tenant); err != nil {
+      return nil, err
+    }
+    found, err := ps.tracker.List(ctx, tenant)
+    if err != nil {
+      return nil, fmt.Errorf("failed to list presence: %w", err)
+    }
+    conns = found
+  default:
+    return nil, fmt.Errorf("invalid scope: %s", scope)
+  }
+  members := presence.Members(conns)
+  userIDs := make([]uuid.UUID, 0, len(members))
+  for _, m := range members {
+    userIDs = append(userIDs, m.UserID)
+  }
+  users, err := ps.userRepo.GetByIDs(ctx, tx, userIDs)
+  if err != nil {
+    return nil, fmt.Errorf("failed to load present users: %w", err)
+  }
+  byID := make(map[uuid.UUID]*types.User, len(users))
+  for _, u := range users {
+    byID[u.ID] = u
+  }
+  out := make([]*PresenceMember, 0, len(members))
+  for _, m := range members {
+    out = append(out, &PresenceMember{Member: m, User: byID[m.UserID]})
+  }
+  return out, nil
+}
+
+// companyConnections lists the connections of companyID's users and those
+// of its WMS's users that have one of its warehouses open.
+func (ps *presenceService) companyConnections(ctx context.Context, tx *gorm.DB, companyID uuid.UUID) ([]presence.Connection, error) {
+  tenant := channels.Company(companyID)
+  if err := ps.channelService.Authorize(ctx, tenant); err != nil {
+    return nil, err
+  }
+  conns, err := ps.tracker.List(ctx, tenant)
+  if err != nil {
+    return nil, fmt.Errorf("failed to list presence: %w", err)
+  }
+  companies, err := ps.companyRepo.GetByIDs(ctx, tx, []uuid.UUID{companyID})
+  if err != nil {
+    return nil, fmt.Errorf("failed to load company: %w", err)
+  }
+  if len(companies) == 0 || companies[0].WmsID == nil {
+    return conns, nil
+  }
+  wmsConns, err := ps.tracker.List(ctx, channels.Wms(*companies[0].WmsID))
+  if err != nil {
+    return nil, fmt.Errorf("failed to list presence: %w", err)
+  }
+  for _, c := range wmsConns {
+    if c.CompanyID != nil && *c.CompanyID == companyID {
+      conns = append(conns, c)
+    }
+  }
+  return conns, nil
+}
+
+// SetViewing records the warehouse, and optionally the scenario in it,
+// the requester has open on connectionID, the ID their SSE or websocket
+// connection was given when it opened. Passing neither clears it.
+func (ps *presenceService) SetViewing(ctx context.Context, tx *gorm.DB, connectionID uuid.UUID, warehouseID, scenarioID *uuid.UUID) error {
+  ps.log.Info("Starting SetViewing now...", "connectionID", connectionID)
+  rd := requestdata.GetRequestData(ctx)
+  if rd == nil {
+    return fmt.Errorf("no request data in context")
+  }
+  tenant, err := presenceTenant(rd)
+  if err != nil {
+    return err
+  }
+  if scenarioID != nil && warehouseID == nil {
+    return fmt.Errorf("warehouseId is required with scenarioId")
+  }
+  if connectionID == uuid.Nil {
+    return fmt.Errorf("connectionId is required")
+  }
+  conn := presence.Connection{ID: connectionID, UserID: rd.UserID, Tenant: tenant, WarehouseID: warehouseID, ScenarioID: scenarioID}
+  if warehouseID != nil {
+    if err := ps.channelService.Authorize(ctx, channels.Warehouse(*warehouseID)); err != nil {
+      return err
+    }
+    warehouses, err := ps.warehouseRepo.GetByIDs(ctx, tx, []uuid.UUID{*warehouseID})
+    if err != nil {
+      return fmt.Errorf("failed to load warehouse: %w", err)
+    }
+    if len(warehouses) == 0 {
+      return fmt.Errorf("warehouse not found")
+    }
+    companyID := warehouses[0].CompanyID
+    conn.CompanyID = &companyID
+  }
+  if scenarioID != nil {
+    scenarios, err := ps.slottingScenarioRepo.GetScenariosByIDs(ctx, tx, []uuid.UUID{*scenarioID})
+    if err != nil {
+      return fmt.Errorf("failed to load scenario: %w", err)
+    }
+    if len(scenarios) == 0 || scenarios[0].WarehouseID != *warehouseID {
+      return fmt.Errorf("scenario not found in warehouse")
+    }
+  }
+  return ps.tracker.SetViewing(ctx, conn)
+}
+
+// presenceTenant is the channel of the company or WMS the requester
+// belongs to, which is where their presence is kept and announced.
+func presenceTenant(rd *requestdata.RequestData) (string, error) {
+  switch {
+  case rd.UserType == "wms" && rd.WmsID != uuid.Nil:
+    return channels.Wms(rd.WmsID), nil
+  case rd.UserType == "company" && rd.CompanyID != uuid.Nil:
+    return channels.Company(rd.CompanyID), nil
+  }
+  return "", fmt.Errorf("user does not belong to a company or wms")
+}
+
+// PresenceConnection describes a new SSE or websocket connection of the
+// requester, or reports false when they have no tenant to be present in.
+func PresenceConnection(rd *requestdata.RequestData, transport string) (presence.Connection, bool) {
+  if rd == nil {
+    return presence.Connection{}, false
+  }
+  tenant, err := presenceTenant(rd)
+  if err != nil {
+    return presence.Connection{}, false
+  }
+  return presence.Connection{UserID: rd.UserID, Tenant: tenant, Transport: transport}, true
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+  "context"
+  "errors"
+  "testing"
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/channels"
+  "github.com/slotter-org/slotter-backend/internal/presence"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type fakeUserRepo struct {
+  repos.UserRepo
+}
+
+func (f *fakeUserRepo) GetByIDs(ctx context.Context, tx *gorm.DB, userIDs []uuid.UUID) ([]*types.User, error) {
+  var out []*types.User
+  for _, id := range userIDs {
+    out = append(out, &types.User{ID: id})
+  }
+  return out, nil
+}
+
+// TestPresenceViewingPerConnection has a WMS user open one company's
+// warehouse in one tab and another company's in a second, and checks each
+// company lists them on its own warehouse next to its own users.
+func TestPresenceViewingPerConnection(t *testing.T) {
+  wmsID := uuid.New()
+  acme := &types.Company{ID: uuid.New(), Name: "Acme", WmsID: &wmsID}
+  initech := &types.Company{ID: uuid.New(), Name: "Initech", WmsID: &wmsID}
+  acmeDC := &types.Warehouse{ID: uuid.New(), CompanyID: acme.ID}
+  initechDC := &types.Warehouse{ID: uuid.New(), CompanyID: initech.ID}
+
+  wmsUser := &requestdata.RequestData{UserType: "wms", UserID: uuid.New(), WmsID: wmsID}
+  acmeUser := &requestdata.RequestData{UserType: "company", UserID: uuid.New(), CompanyID: acme.ID}
+
+  companyRepo := &fakeCompanyRepo{companies: []*types.Company{acme, initech}}
+  warehouseRepo := &fakeWarehouseRepo{warehouses: []*types.Warehouse{acmeDC, initechDC}}
+  tracker := presence.NewTracker(testLogger(), presence.NewMemoryStore(), time.Minute)
+  ps := NewPresenceService(
+    testLogger(),
+    tracker,
+    NewChannelService(testLogger(), companyRepo, nil, nil, NewWarehouseService(nil, testLogger(), nil, nil, companyRepo, nil, nil, warehouseRepo)),
+    &fakeUserRepo{},
+    companyRepo,
+    warehouseRepo,
+    nil,
+  )
+
+  connect := func(rd *requestdata.RequestData) uuid.UUID {
+    conn, ok := PresenceConnection(rd, presence.TransportSSE)
+    if !ok {
+      t.Fatalf("no presence connection for %s user", rd.UserType)
+    }
+    return tracker.Connect(context.Background(), conn).ID
+  }
+  wmsTabA, wmsTabB := connect(wmsUser), connect(wmsUser)
+  acmeTab := connect(acmeUser)
+
+  viewing := []struct {
+    name          string
+    rd            *requestdata.RequestData
+    connectionID  uuid.UUID
+    warehouseID   *uuid.UUID
+    wantErr       error
+  }{
+    {name: "wms tab on acme", rd: wmsUser, connectionID: wmsTabA, warehouseID: &acmeDC.ID},
+    {name: "wms tab on initech", rd: wmsUser, connectionID: wmsTabB, warehouseID: &initechDC.ID},
+    {name: "company tab on acme", rd: acmeUser, connectionID: acmeTab, warehouseID: &acmeDC.ID},
+    {name: "unknown connection", rd: wmsUser, connectionID: uuid.New(), warehouseID: &acmeDC.ID, wantErr: presence.ErrConnectionNotFound},
+    {name: "someone else's connection", rd: acmeUser, connectionID: wmsTabA, wantErr: presence.ErrConnectionNotFound},
+    {name: "warehouse of another company", rd: acmeUser, connectionID: acmeTab, warehouseID: &initechDC.ID, wantErr: channels.ErrForbidden},
+  }
+  for _, tt := range viewing {
+    t.Run("set viewing: "+tt.name, func(t *testing.T) {
+      ctx := requestdata.WithRequestData(context.Background(), tt.rd)
+      if err := ps.SetViewing(ctx, nil, tt.connectionID, tt.warehouseID, nil); !errors.Is(err, tt.wantErr) {
+        t.Fatalf("SetViewing() error = %v, want %v", err, tt.wantErr)
+      }
+    })
+  }
+
+  lists := []struct {
+    name          string
+    rd            *requestdata.RequestData
+    companyID     *uuid.UUID
+    want          map[uuid.UUID]uuid.UUID
+  }{
+    {
+      name: "acme as its own user",
+      rd:   acmeUser,
+      want: map[uuid.UUID]uuid.UUID{acmeUser.UserID: acmeDC.ID, wmsUser.UserID: acmeDC.ID},
+    },
+    {
+      name:      "initech as the wms user",
+      rd:        wmsUser,
+      companyID: &initech.ID,
+      want:      map[uuid.UUID]uuid.UUID{wmsUser.UserID: initechDC.ID},
+    },
+  }
+  for _, tt := range lists {
+    t.Run("list: "+tt.name, func(t *testing.T) {
+      ctx := requestdata.WithRequestData(context.Background(), tt.rd)
+      members, err := ps.List(ctx, nil, "company", tt.companyID)
+      if err != nil {
+        t.Fatalf("List() error = %v", err)
+      }
+      if len(members) != len(tt.want) {
+        t.Fatalf("List() returned %d members, want %d", len(members), len(tt.want))
+      }
+      for _, m := range members {
+        want, ok := tt.want[m.UserID]
+        if !ok {
+          t.Fatalf("List() returned unexpected user %s", m.UserID)
+        }
+        if m.WarehouseID == nil || *m.WarehouseID != want {
+          t.Errorf("user %s viewing %v, want %s", m.UserID, m.WarehouseID, want)
+        }
+      }
+    })
+  }
+}
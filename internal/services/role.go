@@ -9,11 +9,10 @@ import (
 
     "github.com/google/uuid"
 
+    "github.com/slotter-org/slotter-backend/internal/events"
     "github.com/slotter-org/slotter-backend/internal/logger"
     "github.com/slotter-org/slotter-backend/internal/normalization"
     "github.com/slotter-org/slotter-backend/internal/requestdata"
-    "github.com/slotter-org/slotter-backend/internal/ssedata"
-    "github.com/slotter-org/slotter-backend/internal/sse"
     "github.com/slotter-org/slotter-backend/internal/types"
     "github.com/slotter-org/slotter-backend/internal/repos"
 )
@@ -126,7 +125,11 @@ func (rs *roleService) CreateLoggedIn(ctx context.Context, tx *gorm.DB, name str
             rs.log.Warn("Creating role returned no roles")
             return nil, fmt.Errorf("creating role returned no roles")
         }
-        return newRoles[0], nil
+        role := newRoles[0]
+        if err := events.RecordTx(ctx, innerTx, events.New(events.RoleCreated, role).ForTenant(role.WmsID, role.CompanyID)); err != nil {
+            return nil, err
+        }
+        return role, nil
     }
     if tx != nil {
         return createRoleFn(tx)
@@ -140,27 +143,6 @@ func (rs *roleService) CreateLoggedIn(ctx context.Context, tx *gorm.DB, name str
     if err != nil {
         return nil, err
     }
-    var channel string
-    switch entityType {
-    case "company":
-        if role.CompanyID != nil && *role.CompanyID != uuid.Nil {
-            channel = "company:" + role.CompanyID.String()
-        }
-    case "wms":
-        if role.WmsID != nil && *role.WmsID != uuid.Nil {
-            channel = "wms:" + role.WmsID.String()
-        }
-    }
-    if channel != "" {
-        sseData := ssedata.GetSSEData(ctx)
-        if sseData != nil {
-            sseData.AppendMessage(sse.SSEMessage{
-                Channel: channel,
-                Event: sse.SSEEventRoleCreated,
-                Data: role,
-            })
-        }
-    }
     return role, nil
 }
 
@@ -180,10 +162,6 @@ func (rs *roleService) UpdatePermissions(ctx context.Context, tx *gorm.DB, roleI
         rs.log.Warn("No valid roleID passed")
         return nil, fmt.Errorf("invalid roleID")
     }
-    entityType := "wms"
-    if rd.UserType == "company" {
-        entityType = "company"
-    }
     var updatedRole *types.Role
     outerErr := rs.db.Transaction(func(innerTx *gorm.DB) error {
         effectiveTx := innerTx
@@ -292,7 +270,7 @@ func (rs *roleService) UpdatePermissions(ctx context.Context, tx *gorm.DB, roleI
             return fmt.Errorf("cannot reload role after updates: %v", reloadErr)
         }
         updatedRole = newList[0]
-        return nil
+        return events.RecordTx(ctx, effectiveTx, events.New(events.RoleUpdated, updatedRole).ForTenant(updatedRole.WmsID, updatedRole.CompanyID))
     })
 
     if outerErr != nil {
@@ -300,27 +278,6 @@ func (rs *roleService) UpdatePermissions(ctx context.Context, tx *gorm.DB, roleI
         return nil, outerErr
     }
     rs.log.Info("UpdatePermissions completed successfully", "roleID", updatedRole.ID)
-    var channel string
-    switch entityType {
-    case "company":
-        if updatedRole.CompanyID != nil && *updatedRole.CompanyID != uuid.Nil {
-            channel = "company:" + updatedRole.CompanyID.String()
-        }
-    case "wms":
-        if updatedRole.WmsID != nil && *updatedRole.WmsID != uuid.Nil {
-            channel = "wms:" + updatedRole.WmsID.String()
-        }
-    }
-    if channel != "" {
-        sseData := ssedata.GetSSEData(ctx)
-        if sseData != nil {
-            sseData.AppendMessage(sse.SSEMessage{
-                Channel: channel,
-                Event: sse.SSEEventRoleUpdated,
-                Data: updatedRole,
-            })
-        }
-    }
     return updatedRole, nil
 }
 
@@ -336,10 +293,6 @@ func (rs *roleService) UpdateRole(ctx context.Context, tx *gorm.DB, roleID uuid.
         rs.log.Warn("Invalid roleID passed")
         return nil, fmt.Errorf("invalid roleID")
     }
-    entityType := "wms"
-    if rd.UserType == "company" {
-        entityType = "company"
-    }
     var updatedRole *types.Role
     outerErr := rs.db.Transaction(func(innerTx *gorm.DB) error {
         effectiveTx := innerTx
@@ -395,33 +348,12 @@ func (rs *roleService) UpdateRole(ctx context.Context, tx *gorm.DB, roleID uuid.
             return fmt.Errorf("no updated role returned, unexpected DB behavior")
         }
         updatedRole = updatedSlice[0]
-        return nil
+        return events.RecordTx(ctx, effectiveTx, events.New(events.RoleUpdated, updatedRole).ForTenant(updatedRole.WmsID, updatedRole.CompanyID))
     })
     if outerErr != nil {
         return nil, outerErr
     }
     rs.log.Info("Successfully updated Role's name/description", "roleID", roleID)
-    var channel string
-    switch entityType {
-    case "company":
-        if updatedRole.CompanyID != nil && *updatedRole.CompanyID != uuid.Nil {
-            channel = "company:" + rd.CompanyID.String()
-        }
-    case "wms":
-        if updatedRole.WmsID != nil && *updatedRole.WmsID != uuid.Nil {
-            channel = "wms:" + rd.WmsID.String()
-        }
-    }
-    if channel != "" {
-        sseData := ssedata.GetSSEData(ctx)
-        if sseData != nil {
-            sseData.AppendMessage(sse.SSEMessage{
-                Channel: channel,
-                Event: sse.SSEEventRoleUpdated,
-                Data: updatedRole,
-            })
-        }
-    }
     return updatedRole, nil
 }
 
@@ -437,10 +369,6 @@ func (rs *roleService) DeleteRole(ctx context.Context, tx *gorm.DB, roleID uuid.
         rs.log.Warn("Invalid roleID passed")
         return fmt.Errorf("invalid roleID")
     }
-    entityType := "wms"
-    if rd.UserType == "company" {
-        entityType = "company"
-    }
     return rs.db.Transaction(func(innerTx *gorm.DB) error {
         effectiveTx := innerTx
         if tx != nil {
@@ -498,28 +426,7 @@ func (rs *roleService) DeleteRole(ctx context.Context, tx *gorm.DB, roleID uuid.
             return fmt.Errorf("failed to delete role: %w", delErr)
         }
         rs.log.Info("Role successfully deleted", "roleID", theRole.ID)
-        var channel string
-        switch entityType {
-        case "company":
-            if theRole.CompanyID != nil && *theRole.CompanyID != uuid.Nil {
-                channel = "company:" + theRole.CompanyID.String()
-            }
-        case "wms":
-            if theRole.WmsID != nil && *theRole.WmsID != uuid.Nil {
-                channel = "wms:" + theRole.WmsID.String()
-            }
-        }
-        if channel != "" {
-            ssd := ssedata.GetSSEData(ctx)
-            if ssd != nil {
-                ssd.AppendMessage(sse.SSEMessage{
-                    Channel: channel,
-                    Event: sse.SSEEventRoleDeleted,
-                    Data: theRole,
-                })
-            }
-        }
-        return nil
+        return events.RecordTx(ctx, effectiveTx, events.New(events.RoleDeleted, theRole).ForTenant(theRole.WmsID, theRole.CompanyID))
     })
 }
 
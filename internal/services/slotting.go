@@ -0,0 +1,591 @@
+package services
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "sync"
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/layout"
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/slotting"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+const (
+  // slottingJobsKept is how many finished jobs per warehouse are kept;
+  // older ones are removed with their assignments and moves.
+  slottingJobsKept            = 20
+  // slottingProgressInterval throttles progress writes and notifications.
+  slottingProgressInterval    = 500 * time.Millisecond
+  // slottingHeartbeatInterval is how often a running job shows it is alive
+  // and checks it has not been canceled by another instance.
+  slottingHeartbeatInterval   = 30 * time.Second
+  // slottingJobLease is how long a pending or running job may go without a
+  // heartbeat before it is taken to have died with its instance.
+  slottingJobLease            = 3 * slottingHeartbeatInterval
+)
+
+// errSlottingJobStopped is returned when a job finishes after it was
+// canceled or failed elsewhere; its outcome is discarded.
+var errSlottingJobStopped = errors.New("slotting job is no longer running")
+
+type SlottingService interface {
+  StartJob(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, params types.SlottingParams) (*types.SlottingJob, error)
+  startJobLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, companyID uuid.UUID, params types.SlottingParams, scenarioID *uuid.UUID) (*types.SlottingJob, error)
+  CancelJob(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, jobID uuid.UUID) (*types.SlottingJob, error)
+  ListJobs(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) ([]*types.SlottingJob, error)
+  GetJob(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, jobID uuid.UUID) (*types.SlottingJob, error)
+  ListAssignments(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, jobID uuid.UUID) ([]*types.SlottingAssignment, error)
+  ListMoves(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, jobID uuid.UUID) ([]*types.SlottingMove, error)
+  FailInterruptedJobs(ctx context.Context) error
+  SetNotifier(notify func(job *types.SlottingJob))
+}
+
+type slottingService struct {
+  db                    *gorm.DB
+  log                   *logger.Logger
+  warehouseService      WarehouseService
+  locationRepo          repos.WarehouseLocationRepo
+  inventoryRepo         repos.InventoryRepo
+  itemRepo              repos.ItemRepo
+  velocityRepo          repos.VelocityRepo
+  slottingRepo          repos.SlottingRepo
+  scenarioRepo          repos.SlottingScenarioRepo
+  navigationRepo        repos.WarehouseNavigationRepo
+  notify                func(job *types.SlottingJob)
+
+  mu                    sync.Mutex
+  running               map[uuid.UUID]context.CancelFunc
+}
+
+func NewSlottingService(
+  db                    *gorm.DB,
+  log                   *logger.Logger,
+  warehouseService      WarehouseService,
+  locationRepo          repos.WarehouseLocationRepo,
+  inventoryRepo         repos.InventoryRepo,
+  itemRepo              repos.ItemRepo,
+  velocityRepo          repos.VelocityRepo,
+  slottingRepo          repos.SlottingRepo,
+  scenarioRepo          repos.SlottingScenarioRepo,
+  navigationRepo        repos.WarehouseNavigationRepo,
+) SlottingService {
+  serviceLog := log.With("service", "SlottingService")
+  return &slottingService{
+    db:               db,
+    log:              serviceLog,
+    warehouseService: warehouseService,
+    locationRepo:     locationRepo,
+    inventoryRepo:    inventoryRepo,
+    itemRepo:         itemRepo,
+    velocityRepo:     velocityRepo,
+    slottingRepo:     slottingRepo,
+    scenarioRepo:     scenarioRepo,
+    navigationRepo:   navigationRepo,
+    running:          map[uuid.UUID]context.CancelFunc{},
+  }
+}
+
+// SetNotifier registers a callback invoked whenever a background job makes
+// progress or finishes, so the handler layer can push it to clients.
+func (ss *slottingService) SetNotifier(notify func(job *types.SlottingJob)) {
+  ss.notify = notify
+}
+
+//----------------------------------------------------------------------------------------
+// Read
+//----------------------------------------------------------------------------------------
+
+func (ss *slottingService) ListJobs(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) ([]*types.SlottingJob, error) {
+  ss.log.Info("Starting ListJobs now...", "warehouseID", warehouseID)
+  if _, err := ss.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  jobs, err := ss.slottingRepo.GetJobsByWarehouseID(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to list slotting jobs: %w", err)
+  }
+  return jobs, nil
+}
+
+func (ss *slottingService) GetJob(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, jobID uuid.UUID) (*types.SlottingJob, error) {
+  ss.log.Info("Starting GetJob now...", "warehouseID", warehouseID, "jobID", jobID)
+  if _, err := ss.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  return ss.loadJob(ctx, tx, warehouseID, jobID)
+}
+
+// ListAssignments returns the slot a completed job gives every item.
+func (ss *slottingService) ListAssignments(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, jobID uuid.UUID) ([]*types.SlottingAssignment, error) {
+  ss.log.Info("Starting ListAssignments now...", "warehouseID", warehouseID, "jobID", jobID)
+  if _, err := ss.completedJob(ctx, tx, warehouseID, jobID); err != nil {
+    return nil, err
+  }
+  assignments, err := ss.slottingRepo.GetAssignmentsByJobID(ctx, tx, jobID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to list slotting assignments: %w", err)
+  }
+  return assignments, nil
+}
+
+// ListMoves returns the move list of a completed job in execution order.
+func (ss *slottingService) ListMoves(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, jobID uuid.UUID) ([]*types.SlottingMove, error) {
+  ss.log.Info("Starting ListMoves now...", "warehouseID", warehouseID, "jobID", jobID)
+  if _, err := ss.completedJob(ctx, tx, warehouseID, jobID); err != nil {
+    return nil, err
+  }
+  moves, err := ss.slottingRepo.GetMovesByJobID(ctx, tx, jobID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to list slotting moves: %w", err)
+  }
+  return moves, nil
+}
+
+//----------------------------------------------------------------------------------------
+// Jobs
+//----------------------------------------------------------------------------------------
+
+// StartJob records a pending job on the warehouse's live state and solves it
+// in the background.
+func (ss *slottingService) StartJob(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, params types.SlottingParams) (*types.SlottingJob, error) {
+  ss.log.Info("Starting StartJob now...", "warehouseID", warehouseID)
+  warehouse, err := ss.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, err
+  }
+  if err := validateSlottingParams(params); err != nil {
+    return nil, err
+  }
+  return ss.startJobLogic(ctx, tx, warehouseID, warehouse.CompanyID, params, nil)
+}
+
+// startJobLogic creates and launches a job without checking access. Only one
+// job may be pending or running at a time per warehouse for its live state,
+// and per scenario.
+func (ss *slottingService) startJobLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, companyID uuid.UUID, params types.SlottingParams, scenarioID *uuid.UUID) (*types.SlottingJob, error) {
+  jobs, err := ss.slottingRepo.GetJobsByWarehouseID(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to check running slotting jobs: %w", err)
+  }
+  staleBefore := time.Now().Add(-slottingJobLease)
+  for _, j := range jobs {
+    if j.Status != types.SlottingJobPending && j.Status != types.SlottingJobRunning {
+      continue
+    }
+    if j.UpdatedAt.Before(staleBefore) {
+      // Its instance went away without the startup sweep seeing it.
+      if err := ss.failInterruptedJob(ctx, tx, j); err != nil {
+        return nil, err
+      }
+      continue
+    }
+    if scenarioID == nil && j.ScenarioID == nil {
+      return nil, fmt.Errorf("a slotting job is already in progress for this warehouse")
+    }
+    if scenarioID != nil && j.ScenarioID != nil && *j.ScenarioID == *scenarioID {
+      return nil, fmt.Errorf("a slotting job is already in progress for this scenario")
+    }
+  }
+  job := &types.SlottingJob{
+    WarehouseID:   warehouseID,
+    CompanyID:     companyID,
+    ScenarioID:    scenarioID,
+    Status:        types.SlottingJobPending,
+    Params:        params,
+    VelocityRunID: params.VelocityRunID,
+  }
+  if rd := requestdata.GetRequestData(ctx); rd != nil && rd.UserID != uuid.Nil {
+    userID := rd.UserID
+    job.RequestedByID = &userID
+  }
+  if _, err := ss.slottingRepo.CreateJob(ctx, tx, job); err != nil {
+    return nil, fmt.Errorf("failed to create slotting job: %w", err)
+  }
+  // As with velocity runs, a caller-supplied tx has not committed the job
+  // yet, so the goroutine is only started for our own writes.
+  if tx == nil {
+    jobCtx, cancel := context.WithCancel(context.Background())
+    ss.mu.Lock()
+    ss.running[job.ID] = cancel
+    ss.mu.Unlock()
+    go ss.executeJob(jobCtx, job.ID)
+  }
+  return job, nil
+}
+
+// CancelJob stops a pending or running job. A job running in this process
+// is signalled and marks itself canceled when the solver notices; any other
+// job is marked canceled right away, and the instance running it stops at
+// its next progress report or heartbeat.
+func (ss *slottingService) CancelJob(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, jobID uuid.UUID) (*types.SlottingJob, error) {
+  ss.log.Info("Starting CancelJob now...", "warehouseID", warehouseID, "jobID", jobID)
+  if _, err := ss.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  job, err := ss.loadJob(ctx, tx, warehouseID, jobID)
+  if err != nil {
+    return nil, err
+  }
+  if job.Status != types.SlottingJobPending && job.Status != types.SlottingJobRunning {
+    return nil, fmt.Errorf("slotting job is already %s", job.Status)
+  }
+  ss.mu.Lock()
+  cancel, ok := ss.running[jobID]
+  ss.mu.Unlock()
+  if ok {
+    cancel()
+    return job, nil
+  }
+  // Locked and re-checked, so a completion committed since the read above
+  // is not overwritten.
+  update := func(tx *gorm.DB) error {
+    current, err := ss.loadJob(ctx, tx, warehouseID, jobID)
+    if err != nil {
+      return err
+    }
+    if current.Status != types.SlottingJobPending && current.Status != types.SlottingJobRunning {
+      return fmt.Errorf("slotting job is already %s", current.Status)
+    }
+    now := time.Now().UTC()
+    current.Status = types.SlottingJobCanceled
+    current.Message = "canceled"
+    current.CompletedAt = &now
+    if _, err := ss.slottingRepo.UpdateJob(ctx, tx, current); err != nil {
+      return fmt.Errorf("failed to cancel slotting job: %w", err)
+    }
+    job = current
+    return nil
+  }
+  if tx != nil {
+    err = update(tx)
+  } else {
+    err = ss.db.WithContext(ctx).Transaction(update)
+  }
+  if err != nil {
+    return nil, err
+  }
+  return job, nil
+}
+
+// FailInterruptedJobs marks jobs left pending or running by an instance
+// that stopped as failed, so a new job can be started. Jobs still
+// heartbeating on other instances are left alone. Call at startup.
+func (ss *slottingService) FailInterruptedJobs(ctx context.Context) error {
+  jobs, err := ss.slottingRepo.GetStaleJobs(ctx, nil, []types.SlottingJobStatus{types.SlottingJobPending, types.SlottingJobRunning}, time.Now().Add(-slottingJobLease))
+  if err != nil {
+    return fmt.Errorf("failed to load interrupted slotting jobs: %w", err)
+  }
+  for _, job := range jobs {
+    if err := ss.failInterruptedJob(ctx, nil, job); err != nil {
+      return err
+    }
+  }
+  if len(jobs) > 0 {
+    ss.log.Info("Marked interrupted slotting jobs as failed", "count", len(jobs))
+  }
+  return nil
+}
+
+// failInterruptedJob marks a job whose instance went away as failed.
+func (ss *slottingService) failInterruptedJob(ctx context.Context, tx *gorm.DB, job *types.SlottingJob) error {
+  now := time.Now().UTC()
+  job.Status = types.SlottingJobFailed
+  job.Error = "interrupted by a server restart"
+  job.CompletedAt = &now
+  if _, err := ss.slottingRepo.UpdateJob(ctx, tx, job); err != nil {
+    return fmt.Errorf("failed to update interrupted slotting job: %w", err)
+  }
+  return nil
+}
+
+// executeJob solves a job outside any request. ctx is the job's own context,
+// canceled by CancelJob or when the job stops running elsewhere; database
+// writes use a fresh one so a canceled job can still record that it was
+// canceled.
+func (ss *slottingService) executeJob(ctx context.Context, jobID uuid.UUID) {
+  defer func() {
+    ss.mu.Lock()
+    if cancel, ok := ss.running[jobID]; ok {
+      cancel()
+      delete(ss.running, jobID)
+    }
+    ss.mu.Unlock()
+  }()
+  dbCtx := context.Background()
+  jobs, err := ss.slottingRepo.GetJobsByIDs(dbCtx, nil, []uuid.UUID{jobID})
+  if err != nil || len(jobs) == 0 {
+    ss.log.Error("Failed to load slotting job", "jobID", jobID, "error", err)
+    return
+  }
+  job := jobs[0]
+  started := time.Now().UTC()
+  job.Status = types.SlottingJobRunning
+  job.StartedAt = &started
+  job.Message = "loading warehouse"
+  if _, err := ss.slottingRepo.UpdateJob(dbCtx, nil, job); err != nil {
+    ss.log.Error("Failed to mark slotting job running", "jobID", jobID, "error", err)
+    return
+  }
+  ss.emit(job)
+
+  ctx, stop := context.WithCancel(ctx)
+  defer stop()
+  go ss.heartbeat(ctx, stop, jobID)
+
+  err = ss.solveJob(ctx, stop, dbCtx, job)
+  if err == nil || errors.Is(err, errSlottingJobStopped) {
+    ss.emit(job)
+    return
+  }
+  outcome := *job
+  completed := time.Now().UTC()
+  outcome.CompletedAt = &completed
+  if errors.Is(err, context.Canceled) {
+    ss.log.Info("Slotting job canceled", "jobID", jobID)
+    outcome.Status = types.SlottingJobCanceled
+    outcome.Message = "canceled"
+  } else {
+    ss.log.Warn("Slotting job failed", "jobID", jobID, "error", err)
+    outcome.Status = types.SlottingJobFailed
+    outcome.Error = err.Error()
+  }
+  if err := ss.db.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+    if err := ss.claimFinish(dbCtx, tx, job); err != nil {
+      return err
+    }
+    _, err := ss.slottingRepo.UpdateJob(dbCtx, tx, &outcome)
+    return err
+  }); err != nil && !errors.Is(err, errSlottingJobStopped) {
+    ss.log.Error("Failed to record slotting job outcome", "jobID", jobID, "error", err)
+  } else if err == nil {
+    *job = outcome
+  }
+  ss.emit(job)
+}
+
+// heartbeat keeps a running job's lease fresh and stops it once it is no
+// longer running, e.g. because another instance canceled it.
+func (ss *slottingService) heartbeat(ctx context.Context, stop context.CancelFunc, jobID uuid.UUID) {
+  ticker := time.NewTicker(slottingHeartbeatInterval)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-ctx.Done():
+      return
+    case <-ticker.C:
+      running, err := ss.slottingRepo.HeartbeatJob(context.Background(), nil, jobID)
+      if err != nil {
+        ss.log.Warn("Failed to heartbeat slotting job", "jobID", jobID, "error", err)
+        continue
+      }
+      if !running {
+        ss.log.Info("Slotting job stopped elsewhere; stopping the solver", "jobID", jobID)
+        stop()
+        return
+      }
+    }
+  }
+}
+
+// claimFinish locks the job and checks it is still running, so its outcome
+// never overwrites a cancel or failure recorded by another instance. When
+// it is not, job is refreshed and errSlottingJobStopped returned.
+func (ss *slottingService) claimFinish(ctx context.Context, tx *gorm.DB, job *types.SlottingJob) error {
+  current, err := ss.slottingRepo.GetJobsByIDs(ctx, tx, []uuid.UUID{job.ID})
+  if err != nil {
+    return fmt.Errorf("failed to reload slotting job: %w", err)
+  }
+  if len(current) == 0 {
+    return errSlottingJobStopped
+  }
+  if current[0].Status != types.SlottingJobRunning {
+    ss.log.Info("Slotting job stopped elsewhere; discarding its outcome", "jobID", job.ID, "status", current[0].Status)
+    *job = *current[0]
+    return errSlottingJobStopped
+  }
+  return nil
+}
+
+// solveJob runs the solver and stores its result. stop ends the solve when
+// a progress report finds the job no longer running.
+func (ss *slottingService) solveJob(ctx context.Context, stop context.CancelFunc, dbCtx context.Context, job *types.SlottingJob) (err error) {
+  defer func() {
+    if r := recover(); r != nil {
+      err = fmt.Errorf("slotting solve panicked: %v", r)
+    }
+  }()
+  snap, err := ss.jobSnapshot(dbCtx, job)
+  if err != nil {
+    return err
+  }
+  job.VelocityRunID = snap.VelocityRunID
+  model, err := buildSlottingModel(snap, job.Params)
+  if err != nil {
+    return err
+  }
+  job.SKUCount = len(model.problem.SKUs)
+  job.SlotCount = len(model.problem.Slots)
+
+  var last time.Time
+  result, err := slotting.Solve(ctx, model.problem, func(fraction float64, message string) {
+    if time.Since(last) < slottingProgressInterval && fraction < 1 {
+      return
+    }
+    last = time.Now()
+    job.Progress = fraction
+    job.Message = message
+    running, err := ss.slottingRepo.UpdateJobProgress(dbCtx, nil, job.ID, fraction, message)
+    if err != nil {
+      ss.log.Warn("Failed to record slotting progress", "jobID", job.ID, "error", err)
+    } else if !running {
+      ss.log.Info("Slotting job stopped elsewhere; stopping the solver", "jobID", job.ID)
+      stop()
+      return
+    }
+    ss.emit(job)
+  })
+  if err != nil {
+    return err
+  }
+  planned := slotting.PlanMoves(result.Placements)
+
+  return ss.db.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+    if err := ss.claimFinish(dbCtx, tx, job); err != nil {
+      return err
+    }
+    if _, err := ss.slottingRepo.CreateAssignments(dbCtx, tx, model.assignments(job.ID, job.WarehouseID, result)); err != nil {
+      return fmt.Errorf("failed to store slotting assignments: %w", err)
+    }
+    if _, err := ss.slottingRepo.CreateMoves(dbCtx, tx, model.moves(job.ID, job.WarehouseID, planned)); err != nil {
+      return fmt.Errorf("failed to store slotting moves: %w", err)
+    }
+    completed := time.Now().UTC()
+    job.Status = types.SlottingJobCompleted
+    job.Progress = 1
+    job.Message = "completed"
+    job.CostBefore = result.CostBefore
+    job.CostAfter = result.CostAfter
+    job.MovesRequired = len(planned)
+    job.Unplaced = model.unplaced(result)
+    job.KPIsBefore = slottingKPIs(slotting.Measure(model.problem, slotting.CurrentAssignment(model.problem)))
+    job.KPIsAfter = slottingKPIs(slotting.Measure(model.problem, result.Assignment()))
+    job.CompletedAt = &completed
+    if _, err := ss.slottingRepo.UpdateJob(dbCtx, tx, job); err != nil {
+      return fmt.Errorf("failed to complete slotting job: %w", err)
+    }
+    ss.log.Info("Slotting job completed", "jobID", job.ID, "skus", job.SKUCount, "moves", job.MovesRequired)
+    return ss.pruneJobs(dbCtx, tx, job.WarehouseID)
+  })
+}
+
+//----------------------------------------------------------------------------------------
+// Helpers
+//----------------------------------------------------------------------------------------
+
+// jobSnapshot is the state a job solves: its scenario's frozen snapshot or
+// the warehouse as it is now.
+func (ss *slottingService) jobSnapshot(ctx context.Context, job *types.SlottingJob) (*types.SlottingSnapshot, error) {
+  if job.ScenarioID == nil {
+    return loadSlottingSnapshot(ctx, nil, job.WarehouseID, job.CompanyID, job.Params.VelocityRunID, ss.locationRepo, ss.inventoryRepo, ss.itemRepo, ss.velocityRepo, ss.navigationRepo)
+  }
+  scenarios, err := ss.scenarioRepo.GetScenariosByIDs(ctx, nil, []uuid.UUID{*job.ScenarioID})
+  if err != nil {
+    return nil, fmt.Errorf("failed to load slotting scenario: %w", err)
+  }
+  if len(scenarios) == 0 {
+    return nil, fmt.Errorf("slotting scenario not found")
+  }
+  return &scenarios[0].Snapshot, nil
+}
+
+func (ss *slottingService) emit(job *types.SlottingJob) {
+  if ss.notify != nil {
+    ss.notify(job)
+  }
+}
+
+// pruneJobs removes the finished live jobs beyond slottingJobsKept, oldest
+// first. Scenario jobs live as long as their scenario.
+func (ss *slottingService) pruneJobs(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) error {
+  jobs, err := ss.slottingRepo.GetJobsByWarehouseID(ctx, tx, warehouseID)
+  if err != nil {
+    return fmt.Errorf("failed to load slotting jobs: %w", err)
+  }
+  var old []uuid.UUID
+  kept := 0
+  for _, j := range jobs {
+    if j.ScenarioID != nil || j.Status == types.SlottingJobPending || j.Status == types.SlottingJobRunning {
+      continue
+    }
+    kept++
+    if kept > slottingJobsKept {
+      old = append(old, j.ID)
+    }
+  }
+  return ss.slottingRepo.FullDeleteJobsByIDs(ctx, tx, old)
+}
+
+func (ss *slottingService) loadJob(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, jobID uuid.UUID) (*types.SlottingJob, error) {
+  jobs, err := ss.slottingRepo.GetJobsByIDs(ctx, tx, []uuid.UUID{jobID})
+  if err != nil {
+    return nil, fmt.Errorf("failed to load slotting job: %w", err)
+  }
+  if len(jobs) == 0 || jobs[0].WarehouseID != warehouseID {
+    return nil, fmt.Errorf("slotting job not found")
+  }
+  return jobs[0], nil
+}
+
+func (ss *slottingService) completedJob(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, jobID uuid.UUID) (*types.SlottingJob, error) {
+  if _, err := ss.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  job, err := ss.loadJob(ctx, tx, warehouseID, jobID)
+  if err != nil {
+    return nil, err
+  }
+  if job.Status != types.SlottingJobCompleted {
+    return nil, fmt.Errorf("slotting job is %s", job.Status)
+  }
+  return job, nil
+}
+
+// validateSlottingParams rejects params the solver would silently clamp.
+func validateSlottingParams(p types.SlottingParams) error {
+  if p.Iterations < 0 || p.Iterations > slotting.MaxIterations {
+    return fmt.Errorf("iterations must be between 1 and %d", slotting.MaxIterations)
+  }
+  if p.GoldenLevelMin < 0 || p.GoldenLevelMax < p.GoldenLevelMin {
+    return fmt.Errorf("golden levels must satisfy 0 <= min <= max")
+  }
+  if p.FillFactor < 0 || p.FillFactor > 1 {
+    return fmt.Errorf("fillFactor must be between 0 and 1")
+  }
+  if p.GoldenPenalty < 0 || p.HeavyThresholdKg < 0 || p.HeavyMaxLevel < 0 || p.MovePenalty < 0 || p.MaxMoves < 0 {
+    return fmt.Errorf("slotting params cannot be negative")
+  }
+  for _, t := range p.SlotTypes {
+    if !layout.IsValidLocationType(t) {
+      return fmt.Errorf("invalid slot type %q", t)
+    }
+  }
+  return nil
+}
+
+func slottingKPIs(k slotting.KPIs) types.SlottingKPIs {
+  return types.SlottingKPIs{
+    TravelDistance:  k.TravelDistance,
+    PickDensity:     k.PickDensity,
+    CubeUtilization: k.CubeUtilization,
+    MovesRequired:   k.MovesRequired,
+    SlottedSKUs:     k.SlottedSKUs,
+  }
+}
@@ -0,0 +1,404 @@
+package services
+
+import (
+  "context"
+  "fmt"
+  "math"
+  "sort"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/layout"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/routing"
+  "github.com/slotter-org/slotter-backend/internal/slotting"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// slottingModel is a slotting.Problem together with what is needed to turn
+// its result back into rows.
+type slottingModel struct {
+  problem         slotting.Problem
+  locations       map[uuid.UUID]*types.WarehouseLocation
+  items           map[uuid.UUID]*types.Item
+  skus            map[uuid.UUID]slotting.SKU
+  stock           map[uuid.UUID][]*types.InventoryOnHand
+}
+
+// loadSlottingSnapshot reads the current state of a warehouse. Velocity comes
+// from velocityRunID or, when nil, the latest completed run; without any run
+// every item counts as a slow mover. The navigation is the saved one or the
+// defaults.
+func loadSlottingSnapshot(
+  ctx             context.Context,
+  tx              *gorm.DB,
+  warehouseID     uuid.UUID,
+  companyID       uuid.UUID,
+  velocityRunID   *uuid.UUID,
+  locationRepo    repos.WarehouseLocationRepo,
+  inventoryRepo   repos.InventoryRepo,
+  itemRepo        repos.ItemRepo,
+  velocityRepo    repos.VelocityRepo,
+  navigationRepo  repos.WarehouseNavigationRepo,
+) (*types.SlottingSnapshot, error) {
+  snap := &types.SlottingSnapshot{}
+  var err error
+  if snap.Navigation, _, err = loadNavigation(ctx, tx, warehouseID, companyID, navigationRepo); err != nil {
+    return nil, err
+  }
+  if snap.Locations, err = locationRepo.GetByWarehouseID(ctx, tx, warehouseID, repos.LocationFilter{}); err != nil {
+    return nil, fmt.Errorf("failed to load locations: %w", err)
+  }
+  if snap.OnHand, err = inventoryRepo.GetOnHandByWarehouseID(ctx, tx, warehouseID); err != nil {
+    return nil, fmt.Errorf("failed to load inventory: %w", err)
+  }
+  if snap.Items, err = itemRepo.GetByCompanyID(ctx, tx, companyID); err != nil {
+    return nil, fmt.Errorf("failed to load items: %w", err)
+  }
+
+  var run *types.VelocityRun
+  if velocityRunID != nil {
+    runs, err := velocityRepo.GetRunsByIDs(ctx, tx, []uuid.UUID{*velocityRunID})
+    if err != nil {
+      return nil, fmt.Errorf("failed to load velocity run: %w", err)
+    }
+    if len(runs) == 0 || runs[0].WarehouseID != warehouseID {
+      return nil, fmt.Errorf("velocity run not found")
+    }
+    if runs[0].Status != types.VelocityRunCompleted {
+      return nil, fmt.Errorf("velocity run is %s", runs[0].Status)
+    }
+    run = runs[0]
+  } else if run, err = velocityRepo.GetLatestCompletedRun(ctx, tx, warehouseID); err != nil {
+    return nil, fmt.Errorf("failed to load latest velocity run: %w", err)
+  }
+  if run != nil {
+    runID := run.ID
+    snap.VelocityRunID = &runID
+    if snap.Velocity, err = velocityRepo.GetStatsByRunID(ctx, tx, run.ID); err != nil {
+      return nil, fmt.Errorf("failed to load velocity stats: %w", err)
+    }
+  }
+  return snap, nil
+}
+
+// buildSlottingModel turns a snapshot into a problem.
+//
+// Candidate slots are the leaf locations whose type is one of params'
+// SlotTypes. A slot's zone is its root's code, its bay the bay above it and
+// its level the 0-based index of the level above it. Distance is half the
+// walk from the depot to the slot and back, in metres, when the snapshot's
+// navigation places every slot; otherwise it is the slot's rank in pick
+// sequence (sequenced slots first), then in code order.
+//
+// Every item with stock in a candidate slot, or with picks in the velocity
+// run, is a SKU to slot. Its current slot is the candidate holding most of
+// it; other candidates holding only it stay where they are and are not
+// offered to anyone else. A mixed bin is the current slot of its fastest
+// item only; the other items' stock in it is left alone by the plan.
+func buildSlottingModel(snap *types.SlottingSnapshot, params types.SlottingParams) (*slottingModel, error) {
+  m := &slottingModel{
+    locations: make(map[uuid.UUID]*types.WarehouseLocation, len(snap.Locations)),
+    items:     make(map[uuid.UUID]*types.Item, len(snap.Items)),
+    skus:      map[uuid.UUID]slotting.SKU{},
+    stock:     map[uuid.UUID][]*types.InventoryOnHand{},
+  }
+  slotTypes := map[types.LocationType]bool{}
+  for _, t := range params.SlotTypes {
+    slotTypes[t] = true
+  }
+  if len(slotTypes) == 0 {
+    slotTypes[types.LocationTypePickFace] = true
+  }
+
+  hasChildren := map[uuid.UUID]bool{}
+  for _, loc := range snap.Locations {
+    m.locations[loc.ID] = loc
+    if loc.ParentID != nil {
+      hasChildren[*loc.ParentID] = true
+    }
+  }
+  var candidates []*types.WarehouseLocation
+  for _, loc := range snap.Locations {
+    if !hasChildren[loc.ID] && slotTypes[loc.LocationType] {
+      candidates = append(candidates, loc)
+    }
+  }
+  if len(candidates) == 0 {
+    return nil, fmt.Errorf("warehouse has no candidate slots")
+  }
+  sortBySequence(candidates)
+  distances := navigationDistances(snap, candidates)
+  isCandidate := make(map[uuid.UUID]bool, len(candidates))
+  for _, loc := range candidates {
+    isCandidate[loc.ID] = true
+  }
+
+  for _, it := range snap.Items {
+    m.items[it.ID] = it
+  }
+  picks := map[uuid.UUID]*types.SKUVelocity{}
+  for _, v := range snap.Velocity {
+    picks[v.ItemID] = v
+  }
+
+  // Quantity per item per candidate slot, to find current slots.
+  held := map[uuid.UUID]map[uuid.UUID]int{}
+  for _, oh := range snap.OnHand {
+    if !isCandidate[oh.LocationID] || m.items[oh.ItemID] == nil {
+      continue
+    }
+    m.stock[oh.LocationID] = append(m.stock[oh.LocationID], oh)
+    if held[oh.ItemID] == nil {
+      held[oh.ItemID] = map[uuid.UUID]int{}
+    }
+    held[oh.ItemID][oh.LocationID] += oh.Quantity
+  }
+
+  itemIDs := make([]uuid.UUID, 0, len(held)+len(picks))
+  for id := range held {
+    itemIDs = append(itemIDs, id)
+  }
+  for id, v := range picks {
+    if held[id] == nil && v.PicksPerDay > 0 && m.items[id] != nil {
+      itemIDs = append(itemIDs, id)
+    }
+  }
+  // Fastest movers claim their current slot first in a mixed bin.
+  sort.Slice(itemIDs, func(i, j int) bool {
+    pi, pj := 0.0, 0.0
+    if v := picks[itemIDs[i]]; v != nil {
+      pi = v.PicksPerDay
+    }
+    if v := picks[itemIDs[j]]; v != nil {
+      pj = v.PicksPerDay
+    }
+    if pi != pj {
+      return pi > pj
+    }
+    return itemIDs[i].String() < itemIDs[j].String()
+  })
+
+  claimed := map[uuid.UUID]bool{}
+  blocked := map[uuid.UUID]bool{}
+  for _, id := range itemIDs {
+    it := m.items[id]
+    sku := slotting.SKU{ItemID: id, SKU: it.SKU, ABCClass: "C", HazmatClass: it.HazmatClass}
+    if v := picks[id]; v != nil {
+      sku.PicksPerDay = v.PicksPerDay
+      sku.ABCClass = v.ABCClass
+    }
+    sku.EachLengthCm, sku.EachWidthCm, sku.EachHeightCm, sku.EachWeightKg = eachDimensions(it)
+
+    bins := make([]uuid.UUID, 0, len(held[id]))
+    for loc := range held[id] {
+      bins = append(bins, loc)
+    }
+    sort.Slice(bins, func(i, j int) bool {
+      qi, qj := held[id][bins[i]], held[id][bins[j]]
+      if qi != qj {
+        return qi > qj
+      }
+      return m.locations[bins[i]].FullCode < m.locations[bins[j]].FullCode
+    })
+    for _, loc := range bins {
+      if sku.Current == nil && !claimed[loc] {
+        current := loc
+        sku.Current = &current
+        sku.Quantity = held[id][loc]
+        claimed[loc] = true
+        continue
+      }
+      blocked[loc] = true
+    }
+    if sku.Quantity == 0 {
+      sku.Quantity = 1
+    }
+    m.skus[id] = sku
+    m.problem.SKUs = append(m.problem.SKUs, sku)
+  }
+
+  for rank, loc := range candidates {
+    if blocked[loc.ID] && !claimed[loc.ID] {
+      continue
+    }
+    distance := float64(rank + 1)
+    if distances != nil {
+      distance = distances[rank]
+    }
+    m.problem.Slots = append(m.problem.Slots, slotting.Slot{
+      ID:          loc.ID,
+      Code:        loc.FullCode,
+      Zone:        m.zoneOf(loc),
+      Bay:         m.bayOf(loc),
+      Level:       m.levelOf(loc),
+      Distance:    distance,
+      WidthCm:     loc.WidthCm,
+      DepthCm:     loc.DepthCm,
+      HeightCm:    loc.HeightCm,
+      MaxWeightKg: loc.MaxWeightKg,
+    })
+  }
+
+  m.problem.Params = slotting.Params{
+    Seed:             params.Seed,
+    Iterations:       params.Iterations,
+    GoldenLevelMin:   params.GoldenLevelMin,
+    GoldenLevelMax:   params.GoldenLevelMax,
+    GoldenPenalty:    params.GoldenPenalty,
+    HeavyThresholdKg: params.HeavyThresholdKg,
+    HeavyMaxLevel:    params.HeavyMaxLevel,
+    HazmatZones:      params.HazmatZones,
+    FillFactor:       params.FillFactor,
+    MovePenalty:      params.MovePenalty,
+    MaxMoves:         params.MaxMoves,
+  }.WithDefaults()
+  return m, nil
+}
+
+// navigationDistances is, per candidate, half its round trip from the depot
+// on the snapshot's navigation graph. It is nil when the snapshot has no
+// navigation or some candidate cannot be placed on it or reached.
+func navigationDistances(snap *types.SlottingSnapshot, candidates []*types.WarehouseLocation) []float64 {
+  if snap.Navigation == nil {
+    return nil
+  }
+  model, err := buildNavigationModel(snap.Navigation, snap.Locations)
+  if err != nil {
+    return nil
+  }
+  stops := make([]routing.Stop, 0, len(candidates))
+  for _, loc := range candidates {
+    stop, ok := model.positions[loc.ID]
+    if !ok {
+      return nil
+    }
+    stops = append(stops, stop)
+  }
+  g, err := routing.Build(model.layout, stops)
+  if err != nil {
+    return nil
+  }
+  trips := g.RoundTrips(g.Depot())
+  out := make([]float64, len(trips))
+  for i, t := range trips {
+    if math.IsInf(t, 1) {
+      return nil
+    }
+    out[i] = t / 2
+  }
+  return out
+}
+
+// assignments turns a result into one row per placed item.
+func (m *slottingModel) assignments(jobID uuid.UUID, warehouseID uuid.UUID, result *slotting.Result) []*types.SlottingAssignment {
+  out := make([]*types.SlottingAssignment, 0, len(result.Placements))
+  for _, pl := range result.Placements {
+    sku := m.skus[pl.ItemID]
+    out = append(out, &types.SlottingAssignment{
+      JobID:          jobID,
+      WarehouseID:    warehouseID,
+      ItemID:         pl.ItemID,
+      LocationID:     pl.SlotID,
+      FromLocationID: pl.FromSlotID,
+      Moved:          pl.Moved,
+      PicksPerDay:    sku.PicksPerDay,
+      ABCClass:       sku.ABCClass,
+    })
+  }
+  return out
+}
+
+// moves expands the planned moves into one row per lot on hand in the
+// source slot.
+func (m *slottingModel) moves(jobID uuid.UUID, warehouseID uuid.UUID, planned []slotting.Move) []*types.SlottingMove {
+  var out []*types.SlottingMove
+  for _, mv := range planned {
+    for _, oh := range m.stock[mv.FromSlotID] {
+      if oh.ItemID != mv.ItemID || oh.Quantity <= 0 {
+        continue
+      }
+      out = append(out, &types.SlottingMove{
+        JobID:          jobID,
+        WarehouseID:    warehouseID,
+        Sequence:       mv.Sequence,
+        ItemID:         mv.ItemID,
+        FromLocationID: mv.FromSlotID,
+        ToLocationID:   mv.ToSlotID,
+        Lot:            oh.Lot,
+        Quantity:       oh.Quantity,
+        ViaStaging:     mv.ViaStaging,
+      })
+    }
+  }
+  return out
+}
+
+// unplaced resolves the SKU codes of the items left without a slot.
+func (m *slottingModel) unplaced(result *slotting.Result) []types.SlottingUnplaced {
+  out := make([]types.SlottingUnplaced, 0, len(result.Unplaced))
+  for _, u := range result.Unplaced {
+    out = append(out, types.SlottingUnplaced{ItemID: u.ItemID, SKU: m.skus[u.ItemID].SKU, Reason: u.Reason})
+  }
+  return out
+}
+
+// ancestor walks up from loc to the nearest location of kind, loc included.
+func (m *slottingModel) ancestor(loc *types.WarehouseLocation, kind types.LocationKind) *types.WarehouseLocation {
+  for cur := loc; cur != nil; {
+    if cur.Kind == kind {
+      return cur
+    }
+    if cur.ParentID == nil {
+      return nil
+    }
+    cur = m.locations[*cur.ParentID]
+  }
+  return nil
+}
+
+func (m *slottingModel) zoneOf(loc *types.WarehouseLocation) string {
+  root := loc
+  for root.ParentID != nil && m.locations[*root.ParentID] != nil {
+    root = m.locations[*root.ParentID]
+  }
+  return root.Code
+}
+
+func (m *slottingModel) bayOf(loc *types.WarehouseLocation) string {
+  if bay := m.ancestor(loc, types.LocationKindBay); bay != nil {
+    return bay.FullCode
+  }
+  if loc.ParentID != nil && m.locations[*loc.ParentID] != nil {
+    return m.locations[*loc.ParentID].FullCode
+  }
+  return loc.FullCode
+}
+
+func (m *slottingModel) levelOf(loc *types.WarehouseLocation) int {
+  level := m.ancestor(loc, types.LocationKindLevel)
+  if level == nil {
+    return -1
+  }
+  n, ok := layout.ParseSegment(types.LocationKindLevel, level.Code)
+  if !ok {
+    return -1
+  }
+  return n - 1
+}
+
+// eachDimensions returns the size and weight of one each. Dimensions come
+// from the each UOM only, since a case's cannot be split; the weight falls
+// back to the smallest UOM that has one, divided by its quantity.
+func eachDimensions(item *types.Item) (length, width, height, weight float64) {
+  for _, u := range item.UOMs {
+    if u.UOM == types.UOMEach {
+      length, width, height = u.LengthCm, u.WidthCm, u.HeightCm
+    }
+    if weight == 0 && u.WeightKg > 0 && u.Quantity > 0 {
+      weight = u.WeightKg / float64(u.Quantity)
+    }
+  }
+  return length, width, height, weight
+}
@@ -0,0 +1,674 @@
+package services
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "sort"
+  "strings"
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/events"
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+const MaxScenarioNameLength = 100
+
+// ErrSlottingPlanStale is returned when a scenario's moves no longer match
+// the stock on hand, so its plan could not be executed as computed.
+var ErrSlottingPlanStale = errors.New("inventory has changed since the scenario snapshot; refresh the snapshot and run the scenario again")
+
+type SlottingScenarioInput struct {
+  Name              string                      `json:"name"`
+  Description       string                      `json:"description"`
+  Params            types.SlottingParams        `json:"params"`
+}
+
+// SlottingScenarioPatch changes a scenario. Changing the params or refreshing
+// the snapshot discards the scenario's last run.
+type SlottingScenarioPatch struct {
+  Name              *string                     `json:"name,omitempty"`
+  Description       *string                     `json:"description,omitempty"`
+  Params            *types.SlottingParams       `json:"params,omitempty"`
+  RefreshSnapshot   bool                        `json:"refreshSnapshot,omitempty"`
+}
+
+// ScenarioSide is one scenario of a comparison with the KPIs of its run.
+type ScenarioSide struct {
+  Scenario          *types.SlottingScenario     `json:"scenario"`
+  KPIs              types.SlottingKPIs          `json:"kpis"`
+}
+
+// ScenarioAssignmentDiff is an item the two scenarios slot differently. A
+// nil location means the scenario leaves the item unslotted.
+type ScenarioAssignmentDiff struct {
+  ItemID            uuid.UUID                   `json:"itemID"`
+  SKU               string                      `json:"sku"`
+  ALocationID       *uuid.UUID                  `json:"aLocationID,omitempty"`
+  ALocationCode     string                      `json:"aLocationCode,omitempty"`
+  BLocationID       *uuid.UUID                  `json:"bLocationID,omitempty"`
+  BLocationCode     string                      `json:"bLocationCode,omitempty"`
+}
+
+// ScenarioComparison puts two scenarios side by side. Delta is B minus A.
+type ScenarioComparison struct {
+  A                 ScenarioSide                `json:"a"`
+  B                 ScenarioSide                `json:"b"`
+  Delta             types.SlottingKPIs          `json:"delta"`
+  Differences       []ScenarioAssignmentDiff    `json:"differences"`
+}
+
+// SlottingPlanDetail is a plan with its moves in execution order.
+type SlottingPlanDetail struct {
+  Plan              *types.SlottingPlan         `json:"plan"`
+  Moves             []*types.SlottingMove       `json:"moves"`
+}
+
+type SlottingScenarioService interface {
+  ListScenarios(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) ([]*types.SlottingScenario, error)
+  GetScenario(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, scenarioID uuid.UUID) (*types.SlottingScenario, error)
+  CreateScenario(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, input SlottingScenarioInput) (*types.SlottingScenario, error)
+  createScenarioLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, input SlottingScenarioInput) (*types.SlottingScenario, error)
+  UpdateScenario(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, scenarioID uuid.UUID, patch SlottingScenarioPatch) (*types.SlottingScenario, error)
+  updateScenarioLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, scenarioID uuid.UUID, patch SlottingScenarioPatch) (*types.SlottingScenario, error)
+  DeleteScenario(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, scenarioID uuid.UUID) (*types.SlottingScenario, error)
+  deleteScenarioLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, scenarioID uuid.UUID) (*types.SlottingScenario, error)
+  RunScenario(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, scenarioID uuid.UUID) (*types.SlottingJob, error)
+  CompareScenarios(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, aID uuid.UUID, bID uuid.UUID) (*ScenarioComparison, error)
+  PromoteScenario(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, scenarioID uuid.UUID) (*types.SlottingPlan, error)
+  promoteScenarioLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, scenarioID uuid.UUID) (*types.SlottingPlan, error)
+  ListPlans(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) ([]*types.SlottingPlan, error)
+  GetPlan(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, planID uuid.UUID) (*SlottingPlanDetail, error)
+}
+
+type slottingScenarioService struct {
+  db                    *gorm.DB
+  log                   *logger.Logger
+  warehouseService      WarehouseService
+  slottingService       SlottingService
+  locationRepo          repos.WarehouseLocationRepo
+  inventoryRepo         repos.InventoryRepo
+  itemRepo              repos.ItemRepo
+  velocityRepo          repos.VelocityRepo
+  slottingRepo          repos.SlottingRepo
+  scenarioRepo          repos.SlottingScenarioRepo
+  navigationRepo        repos.WarehouseNavigationRepo
+}
+
+func NewSlottingScenarioService(
+  db                    *gorm.DB,
+  log                   *logger.Logger,
+  warehouseService      WarehouseService,
+  slottingService       SlottingService,
+  locationRepo          repos.WarehouseLocationRepo,
+  inventoryRepo         repos.InventoryRepo,
+  itemRepo              repos.ItemRepo,
+  velocityRepo          repos.VelocityRepo,
+  slottingRepo          repos.SlottingRepo,
+  scenarioRepo          repos.SlottingScenarioRepo,
+  navigationRepo        repos.WarehouseNavigationRepo,
+) SlottingScenarioService {
+  serviceLog := log.With("service", "SlottingScenarioService")
+  return &slottingScenarioService{
+    db:               db,
+    log:              serviceLog,
+    warehouseService: warehouseService,
+    slottingService:  slottingService,
+    locationRepo:     locationRepo,
+    inventoryRepo:    inventoryRepo,
+    itemRepo:         itemRepo,
+    velocityRepo:     velocityRepo,
+    slottingRepo:     slottingRepo,
+    scenarioRepo:     scenarioRepo,
+    navigationRepo:   navigationRepo,
+  }
+}
+
+//----------------------------------------------------------------------------------------
+// Read
+//----------------------------------------------------------------------------------------
+
+func (ss *slottingScenarioService) ListScenarios(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) ([]*types.SlottingScenario, error) {
+  ss.log.Info("Starting ListScenarios now...", "warehouseID", warehouseID)
+  if _, err := ss.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  scenarios, err := ss.scenarioRepo.GetScenariosByWarehouseID(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to list slotting scenarios: %w", err)
+  }
+  return scenarios, nil
+}
+
+func (ss *slottingScenarioService) GetScenario(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, scenarioID uuid.UUID) (*types.SlottingScenario, error) {
+  ss.log.Info("Starting GetScenario now...", "warehouseID", warehouseID, "scenarioID", scenarioID)
+  if _, err := ss.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  scenario, err := ss.loadScenario(ctx, tx, warehouseID, scenarioID)
+  if err != nil {
+    return nil, err
+  }
+  if err := ss.attachJob(ctx, tx, scenario); err != nil {
+    return nil, err
+  }
+  return scenario, nil
+}
+
+// CompareScenarios diffs the last runs of two scenarios: their KPIs and
+// every item they slot differently, ordered by SKU.
+func (ss *slottingScenarioService) CompareScenarios(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, aID uuid.UUID, bID uuid.UUID) (*ScenarioComparison, error) {
+  ss.log.Info("Starting CompareScenarios now...", "warehouseID", warehouseID, "a", aID, "b", bID)
+  if aID == bID {
+    return nil, fmt.Errorf("cannot compare a scenario with itself")
+  }
+  if _, err := ss.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  sides := make([]ScenarioSide, 2)
+  placed := make([]map[uuid.UUID]*types.SlottingAssignment, 2)
+  for i, id := range []uuid.UUID{aID, bID} {
+    scenario, err := ss.loadScenario(ctx, tx, warehouseID, id)
+    if err != nil {
+      return nil, err
+    }
+    job, err := ss.completedRun(ctx, tx, scenario)
+    if err != nil {
+      return nil, err
+    }
+    assignments, err := ss.slottingRepo.GetAssignmentsByJobID(ctx, tx, job.ID)
+    if err != nil {
+      return nil, fmt.Errorf("failed to load slotting assignments: %w", err)
+    }
+    placed[i] = make(map[uuid.UUID]*types.SlottingAssignment, len(assignments))
+    for _, a := range assignments {
+      placed[i][a.ItemID] = a
+    }
+    sides[i] = ScenarioSide{Scenario: scenario, KPIs: job.KPIsAfter}
+  }
+
+  out := &ScenarioComparison{A: sides[0], B: sides[1], Differences: []ScenarioAssignmentDiff{}}
+  out.Delta = types.SlottingKPIs{
+    TravelDistance:  sides[1].KPIs.TravelDistance - sides[0].KPIs.TravelDistance,
+    PickDensity:     sides[1].KPIs.PickDensity - sides[0].KPIs.PickDensity,
+    CubeUtilization: sides[1].KPIs.CubeUtilization - sides[0].KPIs.CubeUtilization,
+    MovesRequired:   sides[1].KPIs.MovesRequired - sides[0].KPIs.MovesRequired,
+    SlottedSKUs:     sides[1].KPIs.SlottedSKUs - sides[0].KPIs.SlottedSKUs,
+  }
+  seen := map[uuid.UUID]bool{}
+  for _, side := range placed {
+    for itemID := range side {
+      if seen[itemID] {
+        continue
+      }
+      seen[itemID] = true
+      a, b := placed[0][itemID], placed[1][itemID]
+      if a != nil && b != nil && a.LocationID == b.LocationID {
+        continue
+      }
+      diff := ScenarioAssignmentDiff{ItemID: itemID}
+      for _, s := range []*types.SlottingAssignment{a, b} {
+        if s != nil && s.Item != nil {
+          diff.SKU = s.Item.SKU
+        }
+      }
+      diff.ALocationID, diff.ALocationCode = assignmentLocation(a)
+      diff.BLocationID, diff.BLocationCode = assignmentLocation(b)
+      out.Differences = append(out.Differences, diff)
+    }
+  }
+  sort.Slice(out.Differences, func(i, j int) bool {
+    if out.Differences[i].SKU != out.Differences[j].SKU {
+      return out.Differences[i].SKU < out.Differences[j].SKU
+    }
+    return out.Differences[i].ItemID.String() < out.Differences[j].ItemID.String()
+  })
+  return out, nil
+}
+
+func (ss *slottingScenarioService) ListPlans(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) ([]*types.SlottingPlan, error) {
+  ss.log.Info("Starting ListPlans now...", "warehouseID", warehouseID)
+  if _, err := ss.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  plans, err := ss.scenarioRepo.GetPlansByWarehouseID(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to list slotting plans: %w", err)
+  }
+  return plans, nil
+}
+
+func (ss *slottingScenarioService) GetPlan(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, planID uuid.UUID) (*SlottingPlanDetail, error) {
+  ss.log.Info("Starting GetPlan now...", "warehouseID", warehouseID, "planID", planID)
+  if _, err := ss.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  plans, err := ss.scenarioRepo.GetPlansByIDs(ctx, tx, []uuid.UUID{planID})
+  if err != nil {
+    return nil, fmt.Errorf("failed to load slotting plan: %w", err)
+  }
+  if len(plans) == 0 || plans[0].WarehouseID != warehouseID {
+    return nil, fmt.Errorf("slotting plan not found")
+  }
+  moves, err := ss.slottingRepo.GetMovesByJobID(ctx, tx, plans[0].JobID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to load slotting plan moves: %w", err)
+  }
+  return &SlottingPlanDetail{Plan: plans[0], Moves: moves}, nil
+}
+
+//----------------------------------------------------------------------------------------
+// Create
+//----------------------------------------------------------------------------------------
+
+// CreateScenario snapshots the warehouse as it is now under a new name.
+func (ss *slottingScenarioService) CreateScenario(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, input SlottingScenarioInput) (*types.SlottingScenario, error) {
+  ss.log.Info("Starting CreateScenario now...", "warehouseID", warehouseID)
+  if tx == nil {
+    var out *types.SlottingScenario
+    if err := ss.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      var err error
+      out, err = ss.createScenarioLogic(ctx, innerTx, warehouseID, input)
+      return err
+    }); err != nil {
+      return nil, err
+    }
+    return out, nil
+  }
+  return ss.createScenarioLogic(ctx, tx, warehouseID, input)
+}
+
+func (ss *slottingScenarioService) createScenarioLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, input SlottingScenarioInput) (*types.SlottingScenario, error) {
+  warehouse, err := ss.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, err
+  }
+  name, err := normalizeScenarioName(input.Name)
+  if err != nil {
+    return nil, err
+  }
+  if err := ss.checkScenarioName(ctx, tx, warehouseID, name, nil); err != nil {
+    return nil, err
+  }
+  if err := validateSlottingParams(input.Params); err != nil {
+    return nil, err
+  }
+  scenario := &types.SlottingScenario{
+    WarehouseID: warehouseID,
+    CompanyID:   warehouse.CompanyID,
+    Name:        name,
+    Description: strings.TrimSpace(input.Description),
+    Params:      input.Params,
+  }
+  if rd := requestdata.GetRequestData(ctx); rd != nil && rd.UserID != uuid.Nil {
+    userID := rd.UserID
+    scenario.CreatedByID = &userID
+  }
+  if err := ss.takeSnapshot(ctx, tx, scenario); err != nil {
+    return nil, err
+  }
+  if _, err := ss.scenarioRepo.CreateScenario(ctx, tx, scenario); err != nil {
+    return nil, fmt.Errorf("failed to create slotting scenario: %w", err)
+  }
+  return recordScenarioEvent(ctx, tx, events.SlottingScenarioCreated, scenario)
+}
+
+//----------------------------------------------------------------------------------------
+// Update
+//----------------------------------------------------------------------------------------
+
+func (ss *slottingScenarioService) UpdateScenario(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, scenarioID uuid.UUID, patch SlottingScenarioPatch) (*types.SlottingScenario, error) {
+  ss.log.Info("Starting UpdateScenario now...", "warehouseID", warehouseID, "scenarioID", scenarioID)
+  if tx == nil {
+    var out *types.SlottingScenario
+    if err := ss.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      var err error
+      out, err = ss.updateScenarioLogic(ctx, innerTx, warehouseID, scenarioID, patch)
+      return err
+    }); err != nil {
+      return nil, err
+    }
+    return out, nil
+  }
+  return ss.updateScenarioLogic(ctx, tx, warehouseID, scenarioID, patch)
+}
+
+func (ss *slottingScenarioService) updateScenarioLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, scenarioID uuid.UUID, patch SlottingScenarioPatch) (*types.SlottingScenario, error) {
+  if _, err := ss.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  scenario, err := ss.loadScenario(ctx, tx, warehouseID, scenarioID)
+  if err != nil {
+    return nil, err
+  }
+  if patch.Name != nil {
+    name, err := normalizeScenarioName(*patch.Name)
+    if err != nil {
+      return nil, err
+    }
+    if err := ss.checkScenarioName(ctx, tx, warehouseID, name, &scenario.ID); err != nil {
+      return nil, err
+    }
+    scenario.Name = name
+  }
+  if patch.Description != nil {
+    scenario.Description = strings.TrimSpace(*patch.Description)
+  }
+
+  if patch.Params != nil || patch.RefreshSnapshot {
+    if scenario.PromotedPlanID != nil {
+      return nil, fmt.Errorf("a promoted scenario cannot change its params or snapshot")
+    }
+    if err := ss.attachJob(ctx, tx, scenario); err != nil {
+      return nil, err
+    }
+    if scenario.Job != nil && (scenario.Job.Status == types.SlottingJobPending || scenario.Job.Status == types.SlottingJobRunning) {
+      return nil, fmt.Errorf("scenario is running; cancel its job first")
+    }
+    if patch.Params != nil {
+      if err := validateSlottingParams(*patch.Params); err != nil {
+        return nil, err
+      }
+      scenario.Params = *patch.Params
+    }
+    if patch.RefreshSnapshot {
+      if err := ss.takeSnapshot(ctx, tx, scenario); err != nil {
+        return nil, err
+      }
+    }
+    // The old run no longer matches the scenario.
+    if scenario.JobID != nil {
+      oldJobID := *scenario.JobID
+      scenario.JobID = nil
+      scenario.Job = nil
+      if _, err := ss.scenarioRepo.UpdateScenario(ctx, tx, scenario); err != nil {
+        return nil, fmt.Errorf("failed to update slotting scenario: %w", err)
+      }
+      if err := ss.slottingRepo.FullDeleteJobsByIDs(ctx, tx, []uuid.UUID{oldJobID}); err != nil {
+        return nil, fmt.Errorf("failed to discard the previous scenario run: %w", err)
+      }
+      return recordScenarioEvent(ctx, tx, events.SlottingScenarioUpdated, scenario)
+    }
+  }
+  if _, err := ss.scenarioRepo.UpdateScenario(ctx, tx, scenario); err != nil {
+    return nil, fmt.Errorf("failed to update slotting scenario: %w", err)
+  }
+  return recordScenarioEvent(ctx, tx, events.SlottingScenarioUpdated, scenario)
+}
+
+// RunScenario solves the scenario's snapshot with its params in the
+// background, replacing its previous run.
+func (ss *slottingScenarioService) RunScenario(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, scenarioID uuid.UUID) (*types.SlottingJob, error) {
+  ss.log.Info("Starting RunScenario now...", "warehouseID", warehouseID, "scenarioID", scenarioID)
+  if _, err := ss.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  scenario, err := ss.loadScenario(ctx, tx, warehouseID, scenarioID)
+  if err != nil {
+    return nil, err
+  }
+  if scenario.PromotedPlanID != nil {
+    return nil, fmt.Errorf("scenario has been promoted and cannot run again")
+  }
+  // Not wrapped in a transaction: the job must be committed before its
+  // goroutine starts, as StartJob does for live jobs.
+  job, err := ss.slottingService.startJobLogic(ctx, tx, warehouseID, scenario.CompanyID, scenario.Params, &scenario.ID)
+  if err != nil {
+    return nil, err
+  }
+  previous := scenario.JobID
+  scenario.JobID = &job.ID
+  if _, err := ss.scenarioRepo.UpdateScenario(ctx, tx, scenario); err != nil {
+    return nil, fmt.Errorf("failed to update slotting scenario: %w", err)
+  }
+  if previous != nil {
+    if err := ss.slottingRepo.FullDeleteJobsByIDs(ctx, tx, []uuid.UUID{*previous}); err != nil {
+      ss.log.Warn("Failed to discard the previous scenario run", "scenarioID", scenarioID, "jobID", *previous, "error", err)
+    }
+  }
+  return job, nil
+}
+
+// PromoteScenario approves a scenario's last run as the warehouse's move
+// plan. Only one plan per warehouse may be approved or in progress, and the
+// moves must still match the stock on hand.
+func (ss *slottingScenarioService) PromoteScenario(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, scenarioID uuid.UUID) (*types.SlottingPlan, error) {
+  ss.log.Info("Starting PromoteScenario now...", "warehouseID", warehouseID, "scenarioID", scenarioID)
+  if tx == nil {
+    var out *types.SlottingPlan
+    if err := ss.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      var err error
+      out, err = ss.promoteScenarioLogic(ctx, innerTx, warehouseID, scenarioID)
+      return err
+    }); err != nil {
+      return nil, err
+    }
+    return out, nil
+  }
+  return ss.promoteScenarioLogic(ctx, tx, warehouseID, scenarioID)
+}
+
+func (ss *slottingScenarioService) promoteScenarioLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, scenarioID uuid.UUID) (*types.SlottingPlan, error) {
+  if _, err := ss.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  scenario, err := ss.loadScenario(ctx, tx, warehouseID, scenarioID)
+  if err != nil {
+    return nil, err
+  }
+  if scenario.PromotedPlanID != nil {
+    return nil, fmt.Errorf("scenario has already been promoted")
+  }
+  job, err := ss.completedRun(ctx, tx, scenario)
+  if err != nil {
+    return nil, err
+  }
+  plans, err := ss.scenarioRepo.GetPlansByWarehouseID(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to check active slotting plans: %w", err)
+  }
+  for _, p := range plans {
+    if p.Status == types.SlottingPlanApproved || p.Status == types.SlottingPlanInProgress {
+      return nil, fmt.Errorf("warehouse already has an active slotting plan")
+    }
+  }
+
+  moves, err := ss.slottingRepo.GetMovesByJobID(ctx, tx, job.ID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to load slotting moves: %w", err)
+  }
+  onHand, err := ss.inventoryRepo.GetOnHandByWarehouseID(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to load inventory: %w", err)
+  }
+  type bin struct {
+    location, item uuid.UUID
+    lot            string
+  }
+  live := make(map[bin]int, len(onHand))
+  for _, oh := range onHand {
+    live[bin{oh.LocationID, oh.ItemID, oh.Lot}] += oh.Quantity
+  }
+  for _, m := range moves {
+    if live[bin{m.FromLocationID, m.ItemID, m.Lot}] < m.Quantity {
+      return nil, ErrSlottingPlanStale
+    }
+  }
+
+  plan := &types.SlottingPlan{
+    WarehouseID: warehouseID,
+    CompanyID:   scenario.CompanyID,
+    ScenarioID:  scenario.ID,
+    JobID:       job.ID,
+    Status:      types.SlottingPlanApproved,
+    MoveCount:   len(moves),
+    ApprovedAt:  time.Now().UTC(),
+  }
+  if rd := requestdata.GetRequestData(ctx); rd != nil && rd.UserID != uuid.Nil {
+    userID := rd.UserID
+    plan.ApprovedByID = &userID
+  }
+  if _, err := ss.scenarioRepo.CreatePlan(ctx, tx, plan); err != nil {
+    return nil, fmt.Errorf("failed to create slotting plan: %w", err)
+  }
+  scenario.PromotedPlanID = &plan.ID
+  if _, err := ss.scenarioRepo.UpdateScenario(ctx, tx, scenario); err != nil {
+    return nil, fmt.Errorf("failed to update slotting scenario: %w", err)
+  }
+  if err := recordCompanyEvent(ctx, tx, plan.CompanyID, events.SlottingPlanApproved, plan); err != nil {
+    return nil, err
+  }
+  return plan, nil
+}
+
+//----------------------------------------------------------------------------------------
+// Delete
+//----------------------------------------------------------------------------------------
+
+// DeleteScenario removes a scenario and its runs. Promoted scenarios are kept
+// as the record behind their plan.
+func (ss *slottingScenarioService) DeleteScenario(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, scenarioID uuid.UUID) (*types.SlottingScenario, error) {
+  ss.log.Info("Starting DeleteScenario now...", "warehouseID", warehouseID, "scenarioID", scenarioID)
+  if tx == nil {
+    var out *types.SlottingScenario
+    if err := ss.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      var err error
+      out, err = ss.deleteScenarioLogic(ctx, innerTx, warehouseID, scenarioID)
+      return err
+    }); err != nil {
+      return nil, err
+    }
+    return out, nil
+  }
+  return ss.deleteScenarioLogic(ctx, tx, warehouseID, scenarioID)
+}
+
+func (ss *slottingScenarioService) deleteScenarioLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, scenarioID uuid.UUID) (*types.SlottingScenario, error) {
+  if _, err := ss.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  scenario, err := ss.loadScenario(ctx, tx, warehouseID, scenarioID)
+  if err != nil {
+    return nil, err
+  }
+  if scenario.PromotedPlanID != nil {
+    return nil, fmt.Errorf("a promoted scenario cannot be deleted")
+  }
+  if err := ss.attachJob(ctx, tx, scenario); err != nil {
+    return nil, err
+  }
+  if scenario.Job != nil && (scenario.Job.Status == types.SlottingJobPending || scenario.Job.Status == types.SlottingJobRunning) {
+    return nil, fmt.Errorf("scenario is running; cancel its job first")
+  }
+  if err := ss.scenarioRepo.FullDeleteScenariosByIDs(ctx, tx, []uuid.UUID{scenario.ID}); err != nil {
+    return nil, fmt.Errorf("failed to delete slotting scenario: %w", err)
+  }
+  return recordScenarioEvent(ctx, tx, events.SlottingScenarioDeleted, scenario)
+}
+
+//----------------------------------------------------------------------------------------
+// Helpers
+//----------------------------------------------------------------------------------------
+
+func recordScenarioEvent(ctx context.Context, tx *gorm.DB, t events.Type, scenario *types.SlottingScenario) (*types.SlottingScenario, error) {
+  if err := recordCompanyEvent(ctx, tx, scenario.CompanyID, t, scenario); err != nil {
+    return nil, err
+  }
+  return scenario, nil
+}
+
+func (ss *slottingScenarioService) loadScenario(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, scenarioID uuid.UUID) (*types.SlottingScenario, error) {
+  scenarios, err := ss.scenarioRepo.GetScenariosByIDs(ctx, tx, []uuid.UUID{scenarioID})
+  if err != nil {
+    return nil, fmt.Errorf("failed to load slotting scenario: %w", err)
+  }
+  if len(scenarios) == 0 || scenarios[0].WarehouseID != warehouseID {
+    return nil, fmt.Errorf("slotting scenario not found")
+  }
+  return scenarios[0], nil
+}
+
+func (ss *slottingScenarioService) attachJob(ctx context.Context, tx *gorm.DB, scenario *types.SlottingScenario) error {
+  if scenario.JobID == nil {
+    return nil
+  }
+  jobs, err := ss.slottingRepo.GetJobsByIDs(ctx, tx, []uuid.UUID{*scenario.JobID})
+  if err != nil {
+    return fmt.Errorf("failed to load scenario run: %w", err)
+  }
+  if len(jobs) > 0 {
+    scenario.Job = jobs[0]
+  }
+  return nil
+}
+
+// completedRun returns the scenario's last run, which must have completed.
+func (ss *slottingScenarioService) completedRun(ctx context.Context, tx *gorm.DB, scenario *types.SlottingScenario) (*types.SlottingJob, error) {
+  if err := ss.attachJob(ctx, tx, scenario); err != nil {
+    return nil, err
+  }
+  if scenario.Job == nil {
+    return nil, fmt.Errorf("scenario %q has not been run yet", scenario.Name)
+  }
+  if scenario.Job.Status != types.SlottingJobCompleted {
+    return nil, fmt.Errorf("scenario %q run is %s", scenario.Name, scenario.Job.Status)
+  }
+  return scenario.Job, nil
+}
+
+func (ss *slottingScenarioService) takeSnapshot(ctx context.Context, tx *gorm.DB, scenario *types.SlottingScenario) error {
+  snap, err := loadSlottingSnapshot(ctx, tx, scenario.WarehouseID, scenario.CompanyID, scenario.Params.VelocityRunID, ss.locationRepo, ss.inventoryRepo, ss.itemRepo, ss.velocityRepo, ss.navigationRepo)
+  if err != nil {
+    return err
+  }
+  // Build once so a scenario that could never run is rejected up front.
+  if _, err := buildSlottingModel(snap, scenario.Params); err != nil {
+    return err
+  }
+  scenario.Snapshot = *snap
+  scenario.SnapshotAt = time.Now().UTC()
+  scenario.SnapshotLocations = len(snap.Locations)
+  scenario.SnapshotItems = len(snap.Items)
+  scenario.SnapshotPositions = len(snap.OnHand)
+  return nil
+}
+
+func (ss *slottingScenarioService) checkScenarioName(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, name string, self *uuid.UUID) error {
+  existing, err := ss.scenarioRepo.GetScenarioByName(ctx, tx, warehouseID, name)
+  if err != nil {
+    return fmt.Errorf("failed checking scenario name uniqueness: %w", err)
+  }
+  if existing != nil && (self == nil || existing.ID != *self) {
+    return fmt.Errorf("a scenario named %q already exists in this warehouse", name)
+  }
+  return nil
+}
+
+func normalizeScenarioName(name string) (string, error) {
+  name = strings.TrimSpace(name)
+  if name == "" {
+    return "", fmt.Errorf("scenario name is required")
+  }
+  if len(name) > MaxScenarioNameLength {
+    return "", fmt.Errorf("scenario name cannot be longer than %d characters", MaxScenarioNameLength)
+  }
+  return name, nil
+}
+
+func assignmentLocation(a *types.SlottingAssignment) (*uuid.UUID, string) {
+  if a == nil {
+    return nil, ""
+  }
+  id := a.LocationID
+  code := ""
+  if a.Location != nil {
+    code = a.Location.FullCode
+  }
+  return &id, code
+}
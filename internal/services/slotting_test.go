@@ -0,0 +1,48 @@
+package services
+
+import (
+  "context"
+  "errors"
+  "testing"
+
+  "github.com/google/uuid"
+
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// TestClaimFinish checks a job's outcome is only written while it is still
+// running, so a cancel or failure recorded by another instance stands.
+func TestClaimFinish(t *testing.T) {
+  tests := []struct {
+    name          string
+    stored        *types.SlottingJobStatus
+    wantErr       error
+    wantStatus    types.SlottingJobStatus
+  }{
+    {name: "still running", stored: statusPtr(types.SlottingJobRunning), wantStatus: types.SlottingJobRunning},
+    {name: "canceled elsewhere", stored: statusPtr(types.SlottingJobCanceled), wantErr: errSlottingJobStopped, wantStatus: types.SlottingJobCanceled},
+    {name: "failed by the stale sweep", stored: statusPtr(types.SlottingJobFailed), wantErr: errSlottingJobStopped, wantStatus: types.SlottingJobFailed},
+    {name: "deleted", wantErr: errSlottingJobStopped, wantStatus: types.SlottingJobRunning},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      id := uuid.New()
+      repo := &fakeSlottingRepo{}
+      if tt.stored != nil {
+        repo.jobs = []*types.SlottingJob{{ID: id, Status: *tt.stored}}
+      }
+      ss := &slottingService{log: testLogger(), slottingRepo: repo}
+      job := &types.SlottingJob{ID: id, Status: types.SlottingJobRunning}
+      if err := ss.claimFinish(context.Background(), nil, job); !errors.Is(err, tt.wantErr) {
+        t.Fatalf("claimFinish() error = %v, want %v", err, tt.wantErr)
+      }
+      if job.Status != tt.wantStatus {
+        t.Errorf("job status = %s, want %s", job.Status, tt.wantStatus)
+      }
+    })
+  }
+}
+
+func statusPtr(s types.SlottingJobStatus) *types.SlottingJobStatus {
+  return &s
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+  "context"
+  "encoding/base64"
+  "fmt"
+  "os"
+  "path/filepath"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/templates"
+)
+
+type TemplateInfo struct {
+  Type    templates.MessageType `json:"type"`
+  Locales []string              `json:"locales"`
+}
+
+type TemplateService interface {
+  ListTemplates(ctx context.Context) []TemplateInfo
+  ResolveBranding(ctx context.Context, tx *gorm.DB, wmsID, companyID *uuid.UUID) templates.Branding
+  ResolveLocale(ctx context.Context, tx *gorm.DB, wmsID, companyID *uuid.UUID, preferred ...string) string
+  Render(ctx context.Context, msgType templates.MessageType, locale string, data templates.MessageData) (*templates.RenderedMessage, error)
+  Preview(ctx context.Context, tx *gorm.DB, msgType templates.MessageType, locale string) (*templates.RenderedMessage, error)
+  FrontEndURL() string
+}
+
+type templateService struct {
+  db          *gorm.DB
+  log         *logger.Logger
+  registry    *templates.Registry
+  wmsRepo     repos.WmsRepo
+  companyRepo repos.CompanyRepo
+  userRepo    repos.UserRepo
+  brandLogo   string
+  frontEndURL string
+}
+
+func NewTemplateService(
+  db          *gorm.DB,
+  log         *logger.Logger,
+  wmsRepo     repos.WmsRepo,
+  companyRepo repos.CompanyRepo,
+  userRepo    repos.UserRepo,
+) (TemplateService, error) {
+  serviceLog := log.With("service", "TemplateService")
+  registry, err := templates.NewRegistry()
+  if err != nil {
+    return nil, fmt.Errorf("failed to build template registry: %w", err)
+  }
+  rawLogoPath := os.Getenv("SLOTTER_BRAND_LOGO_PATH")
+  var finalLogo string
+  if rawLogoPath != "" {
+    base64Logo, err := readFileAsBase64(rawLogoPath)
+    if err != nil {
+      serviceLog.Warn("Failed to read or encode brand logo from SLOTTER_BRAND_LOGO_PATH; using fallback HTTP link", "error", err)
+      finalLogo = "https://slotter.ai/slotter-logo.png"
+    } else {
+      finalLogo = base64Logo
+      serviceLog.Debug("Using base64-encoded brand logo from SLOTTER_BRAND_LOGO_PATH")
+    }
+  } else {
+    serviceLog.Warn("SLOTTER_BRAND_LOGO_PATH not set; using fallback HTTP link.")
+    finalLogo = "https://slotter.ai/slotter-logo.png"
+  }
+  frontEndURL := os.Getenv("SLOTTER_FRONT_END_URL")
+  if frontEndURL == "" {
+    frontEndURL = "https://www.slotter.ai"
+    serviceLog.Warn("SLOTTER_FRONT_END_URL not set; using fallback front end URL.")
+  }
+  return &templateService{
+    db:          db,
+    log:         serviceLog,
+    registry:    registry,
+    wmsRepo:     wmsRepo,
+    companyRepo: companyRepo,
+    userRepo:    userRepo,
+    brandLogo:   finalLogo,
+    frontEndURL: frontEndURL,
+  }, nil
+}
+
+func (ts *templateService) FrontEndURL() string {
+  return ts.frontEndURL
+}
+
+func (ts *templateService) ListTemplates(ctx context.Context) []TemplateInfo {
+  var out []TemplateInfo
+  for _, t := range ts.registry.Types() {
+    out = append(out, TemplateInfo{Type: t, Locales: ts.registry.Locales(t)})
+  }
+  return out
+}
+
+// ResolveBranding builds the branding for a tenant. A company that belongs to a
+// Wms is branded as that Wms; a standalone company uses its own name and avatar.
+func (ts *templateService) ResolveBranding(ctx context.Context, tx *gorm.DB, wmsID, companyID *uuid.UUID) templates.Branding {
+  brand := templates.Branding{Logo: ts.brandLogo}
+  if companyID != nil && *companyID != uuid.Nil {
+    foundCompanies, err := ts.companyRepo.GetByIDs(ctx, tx, []uuid.UUID{*companyID})
+    if err != nil {
+      ts.log.Warn("Failed to load company for branding", "companyID", *companyID, "error", err)
+    } else if len(foundCompanies) > 0 {
+      brand.TenantName = foundCompanies[0].Name
+      brand.TenantLogoURL = foundCompanies[0].AvatarURL
+      if wmsID == nil || *wmsID == uuid.Nil {
+        wmsID = foundCompanies[0].WmsID
+      }
+    }
+  }
+  if wmsID != nil && *wmsID != uuid.Nil {
+    foundWms, err := ts.wmsRepo.GetByIDs(ctx, tx, []uuid.UUID{*wmsID})
+    if err != nil {
+      ts.log.Warn("Failed to load wms for branding", "wmsID", *wmsID, "error", err)
+    } else if len(foundWms) > 0 {
+      brand.TenantName = foundWms[0].Name
+      brand.TenantLogoURL = foundWms[0].AvatarURL
+      brand.PrimaryColor = foundWms[0].BrandPrimaryColor
+      brand.AccentColor = foundWms[0].BrandAccentColor
+    }
+  }
+  return brand
+}
+
+// ResolveLocale returns the first usable locale from preferred, then the
+// company, then the wms.
+func (ts *templateService) ResolveLocale(ctx context.Context, tx *gorm.DB, wmsID, companyID *uuid.UUID, preferred ...string) string {
+  candidates := append([]string{}, preferred...)
+  if companyID != nil && *companyID != uuid.Nil {
+    foundCompanies, err := ts.companyRepo.GetByIDs(ctx, tx, []uuid.UUID{*companyID})
+    if err == nil && len(foundCompanies) > 0 {
+      candidates = append(candidates, foundCompanies[0].Locale)
+      if wmsID == nil || *wmsID == uuid.Nil {
+        wmsID = foundCompanies[0].WmsID
+      }
+    }
+  }
+  if wmsID != nil && *wmsID != uuid.Nil {
+    foundWms, err := ts.wmsRepo.GetByIDs(ctx, tx, []uuid.UUID{*wmsID})
+    if err == nil && len(foundWms) > 0 {
+      candidates = append(candidates, foundWms[0].Locale)
+    }
+  }
+  return templates.ResolveLocale(candidates...)
+}
+
+func (ts *templateService) Render(ctx context.Context, msgType templates.MessageType, locale string, data templates.MessageData) (*templates.RenderedMessage, error) {
+  if data.Brand.Logo == "" {
+    data.Brand.Logo = ts.brandLogo
+  }
+  rendered, err := ts.registry.Render(msgType, locale, data)
+  if err != nil {
+    ts.log.Warn("Failed to render message template", "type", msgType, "locale", locale, "error", err)
+    return nil, err
+  }
+  return rendered, nil
+}
+
+// Preview renders msgType with sample data, branded as the requesting user's
+// tenant so admins see exactly what their recipients will receive.
+func (ts *templateService) Preview(ctx context.Context, tx *gorm.DB, msgType templates.MessageType, locale string) (*templates.RenderedMessage, error) {
+  ts.log.Info("Starting Preview now...")
+  rd := requestdata.GetRequestData(ctx)
+  if rd == nil {
+    return nil, fmt.Errorf("no request data in context")
+  }
+  var wmsID, companyID *uuid.UUID
+  switch rd.UserType {
+  case "wms":
+    wmsID = &rd.WmsID
+  case "company":
+    companyID = &rd.CompanyID
+  default:
+    return nil, fmt.Errorf("invalid user type: %s", rd.UserType)
+  }
+  if locale == "" {
+    locale = ts.ResolveLocale(ctx, tx, wmsID, companyID)
+  }
+  brand := ts.ResolveBranding(ctx, tx, wmsID, companyID)
+  data := templates.SampleData(msgType, brand)
+  data.ActionLink = ts.frontEndURL
+  return ts.Render(ctx, msgType, locale, data)
+}
+
+func readFileAsBase64(path string) (string, error) {
+  data, err := os.ReadFile(path)
+  if err != nil {
+    return "", err
+  }
+  ext := filepath.Ext(path)
+  var mimeType string
+  switch ext {
+  case ".png":
+    mimeType = "image/png"
+  case ".jpg", ".jpeg":
+    mimeType = "image/jpeg"
+  case ".svg":
+    mimeType = "image/svg+xml"
+  default:
+    mimeType = "image/png"
+  }
+  encoded := base64.StdEncoding.EncodeToString(data)
+  return "data:" + mimeType + ";base64," + encoded, nil
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+  "context"
+  "testing"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/templates"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type fakeWmsRepo struct {
+  repos.WmsRepo
+  wms           []*types.Wms
+}
+
+func (f *fakeWmsRepo) GetByIDs(ctx context.Context, tx *gorm.DB, wmsIDs []uuid.UUID) ([]*types.Wms, error) {
+  var out []*types.Wms
+  for _, w := range f.wms {
+    for _, id := range wmsIDs {
+      if w.ID == id {
+        out = append(out, w)
+      }
+    }
+  }
+  return out, nil
+}
+
+// TestResolveBrandingAndLocale brands a company under a Wms as that Wms and a
+// standalone company as itself, with the locale following the same chain.
+func TestResolveBrandingAndLocale(t *testing.T) {
+  wms := &types.Wms{ID: uuid.New(), Name: "Acme WMS", AvatarURL: "https://cdn.example.com/wms.png", Locale: "es", BrandPrimaryColor: "#0a0b0c", BrandAccentColor: "#d0e0f0"}
+  client := &types.Company{ID: uuid.New(), Name: "Client Co", AvatarURL: "https://cdn.example.com/client.png", WmsID: &wms.ID}
+  standalone := &types.Company{ID: uuid.New(), Name: "Initech", AvatarURL: "https://cdn.example.com/initech.png", Locale: "es-MX"}
+  ts := &templateService{
+    log:         testLogger(),
+    wmsRepo:     &fakeWmsRepo{wms: []*types.Wms{wms}},
+    companyRepo: &fakeCompanyRepo{companies: []*types.Company{client, standalone}},
+    brandLogo:   "https://slotter.ai/slotter-logo.png",
+  }
+
+  tests := []struct {
+    name          string
+    wmsID         *uuid.UUID
+    companyID     *uuid.UUID
+    preferred     []string
+    want          templates.Branding
+    wantLocale    string
+  }{
+    {
+      name:       "wms",
+      wmsID:      &wms.ID,
+      want:       templates.Branding{TenantName: wms.Name, TenantLogoURL: wms.AvatarURL, PrimaryColor: wms.BrandPrimaryColor, AccentColor: wms.BrandAccentColor},
+      wantLocale: "es",
+    },
+    {
+      name:       "company under a wms",
+      companyID:  &client.ID,
+      want:       templates.Branding{TenantName: wms.Name, TenantLogoURL: wms.AvatarURL, PrimaryColor: wms.BrandPrimaryColor, AccentColor: wms.BrandAccentColor},
+      wantLocale: "es",
+    },
+    {
+      name:       "standalone company",
+      companyID:  &standalone.ID,
+      want:       templates.Branding{TenantName: standalone.Name, TenantLogoURL: standalone.AvatarURL},
+      wantLocale: "es-mx",
+    },
+    {
+      name:       "user preference first",
+      companyID:  &standalone.ID,
+      preferred:  []string{"", "en-GB"},
+      want:       templates.Branding{TenantName: standalone.Name, TenantLogoURL: standalone.AvatarURL},
+      wantLocale: "en-gb",
+    },
+    {
+      name:       "no tenant",
+      wantLocale: templates.DefaultLocale,
+    },
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      tt.want.Logo = ts.brandLogo
+      if got := ts.ResolveBranding(context.Background(), nil, tt.wmsID, tt.companyID); got != tt.want {
+        t.Errorf("ResolveBranding() = %+v, want %+v", got, tt.want)
+      }
+      if got := ts.ResolveLocale(context.Background(), nil, tt.wmsID, tt.companyID, tt.preferred...); got != tt.wantLocale {
+        t.Errorf("ResolveLocale() = %q, want %q", got, tt.wantLocale)
+      }
+    })
+  }
+}
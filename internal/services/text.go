@@ -2,58 +2,217 @@ package services
 
 import (
   "context"
+  "errors"
   "fmt"
+  "net/http"
   "os"
-  
-  twilio "github.com/twilio/twilio-go"
-  openapi "github.com/twilio/twilio-go/rest/api/v2010"
+  "strings"
+  "time"
+
+  "gorm.io/gorm"
+
   "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/normalization"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+const (
+  SMSBackendTwilio    = "twilio"
+  SMSBackendWebhook   = "webhook"
+  SMSBackendCapture   = "capture"
 )
 
+// ErrPermanentDelivery marks a send that must not be retried (for example a
+// recipient who has opted out). The outbox dead-letters these immediately.
+var ErrPermanentDelivery = errors.New("permanent delivery failure")
+
+// ErrUnverifiedInboundSMS is returned for inbound callbacks whose signature
+// is missing or wrong, and by providers that cannot verify callbacks at all.
+var ErrUnverifiedInboundSMS = errors.New("inbound sms could not be verified")
+
+// SMSProvider is a single SMS transport.
+type SMSProvider interface {
+  Name() string
+  Send(ctx context.Context, toNumber string, body string) error
+  // ParseInbound verifies and decodes a provider callback for an inbound SMS.
+  ParseInbound(r *http.Request) (*InboundSMS, error)
+}
+
+type InboundSMS struct {
+  From        string      `json:"from"`
+  To          string      `json:"to"`
+  Body        string      `json:"body"`
+}
+
+type InboundSMSResult struct {
+  Action      string      `json:"action"`
+  Reply       string      `json:"reply,omitempty"`
+}
+
 type TextService interface {
   SendText(ctx context.Context, toNumber string, body string) error
+  ParseInbound(r *http.Request) (*InboundSMS, error)
+  HandleInbound(ctx context.Context, tx *gorm.DB, msg *InboundSMS) (*InboundSMSResult, error)
+  ProviderName() string
 }
 
 type textService struct {
-  log         *logger.Logger
-  client      *twilio.RestClient
-  from        string
+  db            *gorm.DB
+  log           *logger.Logger
+  provider      SMSProvider
+  optOutRepo    repos.SmsOptOutRepo
 }
 
-func NewTextService(log *logger.Logger) (TextService, error) {
+// NewTextService picks the provider named by SMS_BACKEND. When it is unset
+// Twilio is used if its credentials are present; with neither set it fails,
+// so a deployment missing its SMS config never quietly stops sending. Dev and
+// CI runs opt into local capture with SMS_BACKEND=capture.
+func NewTextService(db *gorm.DB, log *logger.Logger, optOutRepo repos.SmsOptOutRepo) (TextService, error) {
   serviceLog := log.With("service", "TextService")
-  accountSid := os.Getenv("TWILIO_ACCOUNT_SID")
-  authToken := os.Getenv("TWILIO_AUTH_TOKEN")
-  fromNumber := os.Getenv("TWILIO_FROM_NUMBER")
-
-  if accountSid == "" || authToken == "" || fromNumber == "" {
-    return nil, fmt.Errorf("Missing Twilio  env variables: TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN, TWILIO_FROM_NUMBER")
+  backend := strings.ToLower(strings.TrimSpace(os.Getenv("SMS_BACKEND")))
+  if backend == "" {
+    if os.Getenv("TWILIO_ACCOUNT_SID") == "" {
+      return nil, fmt.Errorf("SMS_BACKEND is not set and TWILIO_ACCOUNT_SID is missing; set SMS_BACKEND=capture to capture texts locally")
+    }
+    backend = SMSBackendTwilio
+  }
+  var provider SMSProvider
+  var err error
+  switch backend {
+  case SMSBackendTwilio:
+    provider, err = newTwilioSMSProvider(serviceLog)
+  case SMSBackendWebhook:
+    provider, err = newWebhookSMSProvider(serviceLog)
+  case SMSBackendCapture:
+    provider, err = NewCaptureSMSProvider(os.Getenv("SMS_CAPTURE_FILE"))
+  default:
+    err = fmt.Errorf("unknown SMS_BACKEND %q (expected twilio, webhook or capture)", backend)
   }
-  
-  client := twilio.NewRestClientWithParams(twilio.ClientParams{
-    Username: accountSid,
-    Password: authToken,
-  })
+  if err != nil {
+    return nil, err
+  }
+  serviceLog.Info("Using sms backend", "backend", provider.Name())
+  return NewTextServiceWithProvider(db, log, provider, optOutRepo), nil
+}
 
-  ts := &textService{
-    log:        serviceLog,
-    client:     client,
-    from:       fromNumber,
+func NewTextServiceWithProvider(db *gorm.DB, log *logger.Logger, provider SMSProvider, optOutRepo repos.SmsOptOutRepo) TextService {
+  return &textService{
+    db:         db,
+    log:        log.With("service", "TextService"),
+    provider:   provider,
+    optOutRepo: optOutRepo,
   }
-  return ts, nil
 }
 
-func (ts *textService) SendText(ctx context.Context, toNumber string, body string) error {
-  params := &openapi.CreateMessageParams{}
-  params.SetTo(toNumber)
-  params.SetFrom(ts.from)
-  params.SetBody(body)
+func (ts *textService) ProviderName() string {
+  return ts.provider.Name()
+}
 
-  resp, err := ts.client.Api.CreateMessage(params)
+// SendText normalizes toNumber to E.164 and refuses numbers that have
+// replied STOP.
+func (ts *textService) SendText(ctx context.Context, toNumber string, body string) error {
+  normalized, err := normalization.ParsePhoneNumber(toNumber)
   if err != nil {
-    ts.log.Warn("Failed to send Text via Twilio", "error", err)
+    return fmt.Errorf("%w: %v", ErrPermanentDelivery, err)
+  }
+  if ts.optOutRepo != nil {
+    optedOut, err := ts.optOutRepo.IsOptedOut(ctx, nil, normalized)
+    if err != nil {
+      return fmt.Errorf("failed checking sms opt out: %w", err)
+    }
+    if optedOut {
+      ts.log.Info("Skipping text to opted-out number", "toNumber", normalized)
+      return fmt.Errorf("%w: %s has opted out of sms", ErrPermanentDelivery, normalized)
+    }
+  }
+  if err := ts.provider.Send(ctx, normalized, body); err != nil {
+    ts.log.Warn("Failed to send Text", "provider", ts.provider.Name(), "error", err)
     return err
   }
-  ts.log.Info("Successfully sent Text via Twilio", "toNumber", toNumber, "sid", *resp.Sid, "status", *resp.Status)
+  ts.log.Info("Successfully sent Text", "provider", ts.provider.Name(), "toNumber", normalized)
   return nil
 }
+
+func (ts *textService) ParseInbound(r *http.Request) (*InboundSMS, error) {
+  return ts.provider.ParseInbound(r)
+}
+
+var (
+  smsOptOutKeywords = map[string]bool{"STOP": true, "STOPALL": true, "UNSUBSCRIBE": true, "CANCEL": true, "END": true, "QUIT": true}
+  smsOptInKeywords  = map[string]bool{"START": true, "YES": true, "UNSTOP": true}
+  smsHelpKeywords   = map[string]bool{"HELP": true, "INFO": true}
+)
+
+// HandleInbound applies the carrier-standard STOP/START/HELP keywords. Any
+// other message is acknowledged and ignored.
+func (ts *textService) HandleInbound(ctx context.Context, tx *gorm.DB, msg *InboundSMS) (*InboundSMSResult, error) {
+  if tx == nil {
+    var out *InboundSMSResult
+    err := ts.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      res, err := ts.handleInboundLogic(ctx, innerTx, msg)
+      if err != nil {
+        return err
+      }
+      out = res
+      return nil
+    })
+    if err != nil {
+      return nil, err
+    }
+    return out, nil
+  }
+  return ts.handleInboundLogic(ctx, tx, msg)
+}
+
+func (ts *textService) handleInboundLogic(ctx context.Context, tx *gorm.DB, msg *InboundSMS) (*InboundSMSResult, error) {
+  if msg == nil {
+    return nil, fmt.Errorf("inbound sms is nil")
+  }
+  from, err := normalization.ParsePhoneNumber(msg.From)
+  if err != nil {
+    return nil, err
+  }
+  keyword := strings.ToUpper(strings.TrimSpace(msg.Body))
+  if fields := strings.Fields(keyword); len(fields) > 0 {
+    keyword = strings.Trim(fields[0], ".!")
+  }
+  ts.log.Info("Handling inbound sms", "from", from, "keyword", keyword)
+
+  optedOut := false
+  switch {
+  case smsOptOutKeywords[keyword]:
+    optedOut = true
+  case smsOptInKeywords[keyword]:
+  case smsHelpKeywords[keyword]:
+    return &InboundSMSResult{Action: "help", Reply: "Slotter: reply STOP to unsubscribe, START to resubscribe."}, nil
+  default:
+    return &InboundSMSResult{Action: "ignored"}, nil
+  }
+
+  record := &types.SmsOptOut{PhoneNumber: from}
+  existing, err := ts.optOutRepo.GetByPhoneNumbers(ctx, tx, []string{from})
+  if err != nil {
+    return nil, fmt.Errorf("failed fetching sms opt out: %w", err)
+  }
+  if len(existing) > 0 {
+    record = existing[0]
+  }
+  now := time.Now()
+  record.OptedOut = optedOut
+  record.Keyword = keyword
+  record.Provider = ts.provider.Name()
+  record.UpdatedAt = now
+  if optedOut {
+    record.OptedOutAt = &now
+  } else {
+    record.OptedInAt = &now
+  }
+  if _, err := ts.optOutRepo.Upsert(ctx, tx, record); err != nil {
+    return nil, fmt.Errorf("failed saving sms opt out: %w", err)
+  }
+  if optedOut {
+    return &InboundSMSResult{Action: "opted_out", Reply: "Slotter: you have been unsubscribed and will receive no further messages. Reply START to resubscribe."}, nil
+  }
+  return &InboundSMSResult{Action: "opted_in", Reply: "Slotter: you have been resubscribed. Reply STOP to unsubscribe."}, nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "net/http"
+  "os"
+  "path/filepath"
+  "sync"
+  "time"
+)
+
+type CapturedSMS struct {
+  To          string      `json:"to"`
+  Body        string      `json:"body"`
+  SentAt      time.Time   `json:"sentAt"`
+}
+
+// CaptureSMSProvider keeps every message in memory and, when path is set,
+// appends it as a JSON line to that file. It never talks to a carrier.
+type CaptureSMSProvider struct {
+  mu          sync.Mutex
+  path        string
+  messages    []CapturedSMS
+}
+
+func NewCaptureSMSProvider(path string) (*CaptureSMSProvider, error) {
+  if path != "" {
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+      return nil, fmt.Errorf("failed to create SMS_CAPTURE_FILE directory: %w", err)
+    }
+  }
+  return &CaptureSMSProvider{path: path}, nil
+}
+
+func (p *CaptureSMSProvider) Name() string {
+  return SMSBackendCapture
+}
+
+func (p *CaptureSMSProvider) Send(ctx context.Context, toNumber string, body string) error {
+  msg := CapturedSMS{To: toNumber, Body: body, SentAt: time.Now()}
+  p.mu.Lock()
+  defer p.mu.Unlock()
+  p.messages = append(p.messages, msg)
+  if p.path == "" {
+    return nil
+  }
+  line, err := json.Marshal(msg)
+  if err != nil {
+    return err
+  }
+  f, err := os.OpenFile(p.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+  if err != nil {
+    return err
+  }
+  defer f.Close()
+  _, err = f.Write(append(line, '\n'))
+  return err
+}
+
+// ParseInbound refuses every callback. Nothing can sign them, and the
+// inbound route is public, so accepting them would let anyone opt any number
+// out. Tests feed replies to TextService.HandleInbound directly.
+func (p *CaptureSMSProvider) ParseInbound(r *http.Request) (*InboundSMS, error) {
+  return nil, fmt.Errorf("%w: the capture sms backend does not accept inbound callbacks", ErrUnverifiedInboundSMS)
+}
+
+// Messages returns a copy of everything captured so far.
+func (p *CaptureSMSProvider) Messages() []CapturedSMS {
+  p.mu.Lock()
+  defer p.mu.Unlock()
+  out := make([]CapturedSMS, len(p.messages))
+  copy(out, p.messages)
+  return out
+}
+
+func (p *CaptureSMSProvider) Reset() {
+  p.mu.Lock()
+  defer p.mu.Unlock()
+  p.messages = nil
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type fakeSmsOptOutRepo struct {
+  repos.SmsOptOutRepo
+  records       map[string]*types.SmsOptOut
+  upserts       int
+}
+
+func (f *fakeSmsOptOutRepo) GetByPhoneNumbers(ctx context.Context, tx *gorm.DB, phoneNumbers []string) ([]*types.SmsOptOut, error) {
+  var out []*types.SmsOptOut
+  for _, n := range phoneNumbers {
+    if r, ok := f.records[n]; ok {
+      c := *r
+      out = append(out, &c)
+    }
+  }
+  return out, nil
+}
+
+func (f *fakeSmsOptOutRepo) IsOptedOut(ctx context.Context, tx *gorm.DB, phoneNumber string) (bool, error) {
+  r, ok := f.records[phoneNumber]
+  return ok && r.OptedOut, nil
+}
+
+func (f *fakeSmsOptOutRepo) Upsert(ctx context.Context, tx *gorm.DB, optOut *types.SmsOptOut) (*types.SmsOptOut, error) {
+  f.upserts++
+  if f.records == nil {
+    f.records = map[string]*types.SmsOptOut{}
+  }
+  c := *optOut
+  f.records[optOut.PhoneNumber] = &c
+  return optOut, nil
+}
+
+func TestNewTextServiceRequiresExplicitBackend(t *testing.T) {
+  t.Setenv("SMS_BACKEND", "")
+  t.Setenv("TWILIO_ACCOUNT_SID", "")
+  if _, err := NewTextService(nil, testLogger(), nil); err == nil {
+    t.Fatal("expected an error with no sms backend configured")
+  }
+}
+
+func TestWebhookSMSProviderRequiresSecret(t *testing.T) {
+  t.Setenv("SMS_WEBHOOK_URL", "https://sms.example.com/send")
+  t.Setenv("SMS_WEBHOOK_SECRET", "")
+  if _, err := newWebhookSMSProvider(testLogger()); err == nil {
+    t.Fatal("expected an error without SMS_WEBHOOK_SECRET")
+  }
+}
+
+func TestInboundSMSVerification(t *testing.T) {
+  t.Setenv("SMS_WEBHOOK_URL", "https://sms.example.com/send")
+  t.Setenv("SMS_WEBHOOK_SECRET", "shh")
+  webhook, err := newWebhookSMSProvider(testLogger())
+  if err != nil {
+    t.Fatalf("newWebhookSMSProvider: %v", err)
+  }
+  capture, err := NewCaptureSMSProvider("")
+  if err != nil {
+    t.Fatalf("NewCaptureSMSProvider: %v", err)
+  }
+  body := `{"from":"+15555550100","to":"+15555550199","body":"STOP"}`
+  validSignature := webhook.(*webhookSMSProvider).sign([]byte(body))
+
+  tests := []struct {
+    name          string
+    provider      SMSProvider
+    signature     string
+    wantErr       error
+  }{
+    {name: "webhook signed", provider: webhook, signature: validSignature},
+    {name: "webhook unsigned", provider: webhook, signature: "", wantErr: ErrUnverifiedInboundSMS},
+    {name: "webhook wrong signature", provider: webhook, signature: strings.Repeat("0", len(validSignature)), wantErr: ErrUnverifiedInboundSMS},
+    {name: "capture never accepts", provider: capture, signature: validSignature, wantErr: ErrUnverifiedInboundSMS},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      req := httptest.NewRequest(http.MethodPost, "/api/sms/inbound", strings.NewReader(body))
+      if tt.signature != "" {
+        req.Header.Set("X-Slotter-Signature", tt.signature)
+      }
+      msg, err := tt.provider.ParseInbound(req)
+      if tt.wantErr != nil {
+        if !errors.Is(err, tt.wantErr) {
+          t.Fatalf("err = %v, want %v", err, tt.wantErr)
+        }
+        return
+      }
+      if err != nil {
+        t.Fatalf("ParseInbound: %v", err)
+      }
+      if msg.From != "+15555550100" || msg.Body != "STOP" {
+        t.Errorf("unexpected message: %+v", msg)
+      }
+    })
+  }
+}
+
+// TestCaptureSMSProviderSend sends through the capture backend and checks
+// the number is normalized and the message kept in memory and on disk.
+func TestCaptureSMSProviderSend(t *testing.T) {
+  path := filepath.Join(t.TempDir(), "sms", "captured.jsonl")
+  capture, err := NewCaptureSMSProvider(path)
+  if err != nil {
+    t.Fatalf("NewCaptureSMSProvider: %v", err)
+  }
+  ts := NewTextServiceWithProvider(nil, testLogger(), capture, &fakeSmsOptOutRepo{})
+  if ts.ProviderName() != SMSBackendCapture {
+    t.Errorf("ProviderName() = %q, want %q", ts.ProviderName(), SMSBackendCapture)
+  }
+
+  if err := ts.SendText(context.Background(), "(555) 555-0100", "Your invitation is waiting"); err != nil {
+    t.Fatalf("SendText: %v", err)
+  }
+  if err := ts.SendText(context.Background(), "not a number", "dropped"); !errors.Is(err, ErrPermanentDelivery) {
+    t.Errorf("SendText(invalid) error = %v, want ErrPermanentDelivery", err)
+  }
+
+  msgs := capture.Messages()
+  if len(msgs) != 1 || msgs[0].To != "+15555550100" || msgs[0].Body != "Your invitation is waiting" {
+    t.Fatalf("captured %+v, want one text to +15555550100", msgs)
+  }
+  data, err := os.ReadFile(path)
+  if err != nil {
+    t.Fatalf("reading capture file: %v", err)
+  }
+  var line CapturedSMS
+  if err := json.Unmarshal([]byte(strings.TrimSpace(string(data))), &line); err != nil || line.To != msgs[0].To || line.Body != msgs[0].Body {
+    t.Errorf("capture file = %q (%v), want the sent text as one JSON line", data, err)
+  }
+  capture.Reset()
+  if len(capture.Messages()) != 0 {
+    t.Error("Reset() kept captured messages")
+  }
+}
+
+// TestHandleInboundKeywords replies STOP, HELP, other text and START from one
+// number and checks the opt out is stored and cleared and sends to the
+// number are refused in between.
+func TestHandleInboundKeywords(t *testing.T) {
+  capture, err := NewCaptureSMSProvider("")
+  if err != nil {
+    t.Fatalf("NewCaptureSMSProvider: %v", err)
+  }
+  optOuts := &fakeSmsOptOutRepo{}
+  ts := NewTextServiceWithProvider(nil, testLogger(), capture, optOuts)
+  const number = "+15555550100"
+
+  steps := []struct {
+    body          string
+    wantAction    string
+    wantOptedOut  bool
+    wantUpserts   int
+  }{
+    {body: " stop ", wantAction: "opted_out", wantOptedOut: true, wantUpserts: 1},
+    {body: "HELP", wantAction: "help", wantOptedOut: true, wantUpserts: 1},
+    {body: "where is my order?", wantAction: "ignored", wantOptedOut: true, wantUpserts: 1},
+    {body: "Start.", wantAction: "opted_in", wantUpserts: 2},
+    {body: "unsubscribe please", wantAction: "opted_out", wantOptedOut: true, wantUpserts: 3},
+    {body: "yes", wantAction: "opted_in", wantUpserts: 4},
+  }
+  for _, step := range steps {
+    t.Run(step.body, func(t *testing.T) {
+      capture.Reset()
+      res, err := ts.HandleInbound(context.Background(), &gorm.DB{}, &InboundSMS{From: "+1 555-555-0100", Body: step.body})
+      if err != nil {
+        t.Fatalf("HandleInbound: %v", err)
+      }
+      if res.Action != step.wantAction || (step.wantAction != "ignored" && res.Reply == "") {
+        t.Errorf("result = %+v, want action %s with a reply", res, step.wantAction)
+      }
+      if optOuts.upserts != step.wantUpserts {
+        t.Errorf("saved the opt out %d times, want %d", optOuts.upserts, step.wantUpserts)
+      }
+      record := optOuts.records[number]
+      if record == nil || record.OptedOut != step.wantOptedOut {
+        t.Fatalf("stored opt out = %+v, want opted out %v", record, step.wantOptedOut)
+      }
+      if record.Provider != SMSBackendCapture || (step.wantOptedOut && record.OptedOutAt == nil) || (!step.wantOptedOut && record.OptedInAt == nil) {
+        t.Errorf("stored opt out = %+v", record)
+      }
+
+      err = ts.SendText(context.Background(), "555-555-0100", "Your invitation is waiting")
+      if step.wantOptedOut {
+        if !errors.Is(err, ErrPermanentDelivery) || len(capture.Messages()) != 0 {
+          t.Errorf("SendText to an opted out number: error %v, %d sent", err, len(capture.Messages()))
+        }
+        return
+      }
+      if err != nil || len(capture.Messages()) != 1 {
+        t.Errorf("SendText to an opted in number: error %v, %d sent", err, len(capture.Messages()))
+      }
+    })
+  }
+
+  if _, err := ts.HandleInbound(context.Background(), &gorm.DB{}, &InboundSMS{From: "nobody", Body: "STOP"}); err == nil {
+    t.Error("HandleInbound from an invalid number error = nil, want an error")
+  }
+}
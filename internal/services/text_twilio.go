@@ -0,0 +1,90 @@
+package services
+
+import (
+  "fmt"
+  "context"
+  "net/http"
+  "os"
+
+  twilio "github.com/twilio/twilio-go"
+  twilioclient "github.com/twilio/twilio-go/client"
+  openapi "github.com/twilio/twilio-go/rest/api/v2010"
+
+  "github.com/slotter-org/slotter-backend/internal/logger"
+)
+
+type twilioSMSProvider struct {
+  log           *logger.Logger
+  client        *twilio.RestClient
+  validator     twilioclient.RequestValidator
+  from          string
+  webhookURL    string
+}
+
+func newTwilioSMSProvider(log *logger.Logger) (SMSProvider, error) {
+  accountSid := os.Getenv("TWILIO_ACCOUNT_SID")
+  authToken := os.Getenv("TWILIO_AUTH_TOKEN")
+  fromNumber := os.Getenv("TWILIO_FROM_NUMBER")
+
+  if accountSid == "" || authToken == "" || fromNumber == "" {
+    return nil, fmt.Errorf("Missing Twilio  env variables: TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN, TWILIO_FROM_NUMBER")
+  }
+  
+  client := twilio.NewRestClientWithParams(twilio.ClientParams{
+    Username: accountSid,
+    Password: authToken,
+  })
+
+  return &twilioSMSProvider{
+    log:        log.With("backend", SMSBackendTwilio),
+    client:     client,
+    validator:  twilioclient.NewRequestValidator(authToken),
+    from:       fromNumber,
+    webhookURL: os.Getenv("TWILIO_INBOUND_WEBHOOK_URL"),
+  }, nil
+}
+
+func (p *twilioSMSProvider) Name() string {
+  return SMSBackendTwilio
+}
+
+func (p *twilioSMSProvider) Send(ctx context.Context, toNumber string, body string) error {
+  params := &openapi.CreateMessageParams{}
+  params.SetTo(toNumber)
+  params.SetFrom(p.from)
+  params.SetBody(body)
+
+  resp, err := p.client.Api.CreateMessage(params)
+  if err != nil {
+    return err
+  }
+  p.log.Debug("Twilio accepted message", "toNumber", toNumber, "sid", *resp.Sid, "status", *resp.Status)
+  return nil
+}
+
+// ParseInbound checks X-Twilio-Signature against the URL Twilio was
+// configured to call. Behind a proxy set TWILIO_INBOUND_WEBHOOK_URL, since the
+// request URL seen here will not match.
+func (p *twilioSMSProvider) ParseInbound(r *http.Request) (*InboundSMS, error) {
+  if err := r.ParseForm(); err != nil {
+    return nil, fmt.Errorf("invalid twilio callback body: %w", err)
+  }
+  params := make(map[string]string, len(r.PostForm))
+  for k := range r.PostForm {
+    params[k] = r.PostForm.Get(k)
+  }
+  url := p.webhookURL
+  if url == "" {
+    scheme := "https"
+    if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+      scheme = proto
+    } else if r.TLS == nil {
+      scheme = "http"
+    }
+    url = scheme + "://" + r.Host + r.URL.RequestURI()
+  }
+  if !p.validator.Validate(url, params, r.Header.Get("X-Twilio-Signature")) {
+    return nil, fmt.Errorf("%w: invalid twilio signature", ErrUnverifiedInboundSMS)
+  }
+  return &InboundSMS{From: params["From"], To: params["To"], Body: params["Body"]}, nil
+}
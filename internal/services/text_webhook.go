@@ -0,0 +1,95 @@
+package services
+
+import (
+  "bytes"
+  "context"
+  "crypto/hmac"
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/json"
+  "fmt"
+  "io"
+  "net/http"
+  "os"
+  "time"
+
+  "github.com/slotter-org/slotter-backend/internal/logger"
+)
+
+// webhookSMSProvider hands messages to any HTTP gateway. Outbound requests
+// are JSON {to, from, body}. Both directions carry X-Slotter-Signature, the
+// hex HMAC-SHA256 of the raw body keyed with SMS_WEBHOOK_SECRET, and inbound
+// callbacks without a valid one are refused.
+type webhookSMSProvider struct {
+  log         *logger.Logger
+  client      *http.Client
+  url         string
+  from        string
+  secret      []byte
+}
+
+func newWebhookSMSProvider(log *logger.Logger) (SMSProvider, error) {
+  url := os.Getenv("SMS_WEBHOOK_URL")
+  if url == "" {
+    return nil, fmt.Errorf("Missing SMS_WEBHOOK_URL environment variable")
+  }
+  secret := os.Getenv("SMS_WEBHOOK_SECRET")
+  if secret == "" {
+    return nil, fmt.Errorf("Missing SMS_WEBHOOK_SECRET environment variable")
+  }
+  return &webhookSMSProvider{
+    log:    log.With("backend", SMSBackendWebhook),
+    client: &http.Client{Timeout: 15 * time.Second},
+    url:    url,
+    from:   os.Getenv("SMS_WEBHOOK_FROM_NUMBER"),
+    secret: []byte(secret),
+  }, nil
+}
+
+func (p *webhookSMSProvider) Name() string {
+  return SMSBackendWebhook
+}
+
+func (p *webhookSMSProvider) Send(ctx context.Context, toNumber string, body string) error {
+  payload, err := json.Marshal(map[string]string{"to": toNumber, "from": p.from, "body": body})
+  if err != nil {
+    return err
+  }
+  req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(payload))
+  if err != nil {
+    return err
+  }
+  req.Header.Set("Content-Type", "application/json")
+  req.Header.Set("X-Slotter-Signature", p.sign(payload))
+  resp, err := p.client.Do(req)
+  if err != nil {
+    return fmt.Errorf("sms webhook request failed: %w", err)
+  }
+  defer resp.Body.Close()
+  if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+    respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+    return fmt.Errorf("sms webhook returned status %d: %s", resp.StatusCode, string(respBody))
+  }
+  return nil
+}
+
+func (p *webhookSMSProvider) ParseInbound(r *http.Request) (*InboundSMS, error) {
+  raw, err := io.ReadAll(io.LimitReader(r.Body, 64*1024))
+  if err != nil {
+    return nil, fmt.Errorf("failed to read sms webhook body: %w", err)
+  }
+  if !hmac.Equal([]byte(p.sign(raw)), []byte(r.Header.Get("X-Slotter-Signature"))) {
+    return nil, fmt.Errorf("%w: invalid sms webhook signature", ErrUnverifiedInboundSMS)
+  }
+  var msg InboundSMS
+  if err := json.Unmarshal(raw, &msg); err != nil {
+    return nil, fmt.Errorf("invalid sms webhook body: %w", err)
+  }
+  return &msg, nil
+}
+
+func (p *webhookSMSProvider) sign(body []byte) string {
+  mac := hmac.New(sha256.New, p.secret)
+  mac.Write(body)
+  return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,508 @@
+package services
+
+import (
+  "context"
+  "fmt"
+  "io"
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/types"
+  "github.com/slotter-org/slotter-backend/internal/velocity"
+)
+
+const (
+  DefaultVelocityPageSize     = 100
+  MaxVelocityPageSize         = 1000
+  // MaxVelocityWindowDays bounds how much history one run may read.
+  MaxVelocityWindowDays       = 730
+  // velocityRunsKept is how many completed runs per warehouse are kept;
+  // older ones are removed with their stats when a new run completes.
+  velocityRunsKept            = 10
+  // velocityHeartbeatInterval is how often a running run shows it is alive.
+  velocityHeartbeatInterval   = 30 * time.Second
+  // velocityRunLease is how long a pending or running run may go without a
+  // heartbeat before it is taken to have died with its instance.
+  velocityRunLease            = 3 * velocityHeartbeatInterval
+)
+
+// VelocityRunInput configures a velocity run. Zero fields take the defaults
+// of the velocity package: a 90 day window ending now, weekly periods for
+// XYZ, ABC cut at 80/95% of pick lines and XYZ at a CV of 0.5/1.0.
+type VelocityRunInput struct {
+  WindowDays      int                     `json:"windowDays,omitempty"`
+  WindowEnd       *time.Time              `json:"windowEnd,omitempty"`
+  PeriodDays      int                     `json:"periodDays,omitempty"`
+  ABCThresholdA   float64                 `json:"abcThresholdA,omitempty"`
+  ABCThresholdB   float64                 `json:"abcThresholdB,omitempty"`
+  XYZThresholdX   float64                 `json:"xyzThresholdX,omitempty"`
+  XYZThresholdY   float64                 `json:"xyzThresholdY,omitempty"`
+}
+
+// VelocityPage is one page of the per-SKU stats of a run.
+type VelocityPage struct {
+  Run             *types.VelocityRun      `json:"run"`
+  Stats           []*types.SKUVelocity    `json:"stats"`
+  Total           int64                   `json:"total"`
+  Limit           int                     `json:"limit"`
+  Offset          int                     `json:"offset"`
+}
+
+type VelocityService interface {
+  IngestOrderLines(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, inputs []OrderLineInput) (*OrderLineImportReport, error)
+  ImportOrderLines(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filename string, file io.Reader, dryRun bool) (*OrderLineImportReport, error)
+  importOrderLinesLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, rows []orderLineRow, rowErrs []OrderLineImportError, dryRun bool) (*OrderLineImportReport, error)
+  StartRun(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, input VelocityRunInput) (*types.VelocityRun, error)
+  ListRuns(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) ([]*types.VelocityRun, error)
+  GetRun(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, runID uuid.UUID) (*types.VelocityRun, error)
+  ListVelocity(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, runID *uuid.UUID, filter repos.VelocityFilter) (*VelocityPage, error)
+  ListAffinities(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, runID *uuid.UUID, itemID *uuid.UUID) (*types.VelocityRun, []*types.SKUAffinity, error)
+  FailInterruptedRuns(ctx context.Context) error
+  SetNotifier(notify func(run *types.VelocityRun))
+}
+
+type velocityService struct {
+  db                    *gorm.DB
+  log                   *logger.Logger
+  warehouseService      WarehouseService
+  itemRepo              repos.ItemRepo
+  orderLineRepo         repos.OrderLineRepo
+  velocityRepo          repos.VelocityRepo
+  notify                func(run *types.VelocityRun)
+}
+
+func NewVelocityService(
+  db                    *gorm.DB,
+  log                   *logger.Logger,
+  warehouseService      WarehouseService,
+  itemRepo              repos.ItemRepo,
+  orderLineRepo         repos.OrderLineRepo,
+  velocityRepo          repos.VelocityRepo,
+) VelocityService {
+  serviceLog := log.With("service", "VelocityService")
+  return &velocityService{
+    db:               db,
+    log:              serviceLog,
+    warehouseService: warehouseService,
+    itemRepo:         itemRepo,
+    orderLineRepo:    orderLineRepo,
+    velocityRepo:     velocityRepo,
+  }
+}
+
+// SetNotifier registers a callback invoked whenever a background run
+// completes or fails, so the handler layer can push it to clients.
+func (vs *velocityService) SetNotifier(notify func(run *types.VelocityRun)) {
+  vs.notify = notify
+}
+
+//----------------------------------------------------------------------------------------
+// Read
+//----------------------------------------------------------------------------------------
+
+func (vs *velocityService) ListRuns(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) ([]*types.VelocityRun, error) {
+  vs.log.Info("Starting ListRuns now...", "warehouseID", warehouseID)
+  if _, err := vs.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  runs, err := vs.velocityRepo.GetRunsByWarehouseID(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to list velocity runs: %w", err)
+  }
+  return runs, nil
+}
+
+func (vs *velocityService) GetRun(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, runID uuid.UUID) (*types.VelocityRun, error) {
+  vs.log.Info("Starting GetRun now...", "warehouseID", warehouseID, "runID", runID)
+  if _, err := vs.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  return vs.loadRun(ctx, tx, warehouseID, runID)
+}
+
+// ListVelocity returns the stats of runID, or of the latest completed run
+// when runID is nil, ordered by rank.
+func (vs *velocityService) ListVelocity(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, runID *uuid.UUID, filter repos.VelocityFilter) (*VelocityPage, error) {
+  vs.log.Info("Starting ListVelocity now...", "warehouseID", warehouseID)
+  if filter.ABCClass != "" && filter.ABCClass != "A" && filter.ABCClass != "B" && filter.ABCClass != "C" {
+    return nil, fmt.Errorf("invalid ABC class %q", filter.ABCClass)
+  }
+  if filter.XYZClass != "" && filter.XYZClass != "X" && filter.XYZClass != "Y" && filter.XYZClass != "Z" {
+    return nil, fmt.Errorf("invalid XYZ class %q", filter.XYZClass)
+  }
+  if _, err := vs.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  run, err := vs.resultRun(ctx, tx, warehouseID, runID)
+  if err != nil {
+    return nil, err
+  }
+  filter.Limit, filter.Offset = clampPage(filter.Limit, filter.Offset, DefaultVelocityPageSize, MaxVelocityPageSize)
+  stats, total, err := vs.velocityRepo.SearchStats(ctx, tx, run.ID, filter)
+  if err != nil {
+    return nil, fmt.Errorf("failed to list velocity stats: %w", err)
+  }
+  return &VelocityPage{Run: run, Stats: stats, Total: total, Limit: filter.Limit, Offset: filter.Offset}, nil
+}
+
+func (vs *velocityService) ListAffinities(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, runID *uuid.UUID, itemID *uuid.UUID) (*types.VelocityRun, []*types.SKUAffinity, error) {
+  vs.log.Info("Starting ListAffinities now...", "warehouseID", warehouseID)
+  if _, err := vs.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, nil, err
+  }
+  run, err := vs.resultRun(ctx, tx, warehouseID, runID)
+  if err != nil {
+    return nil, nil, err
+  }
+  affinities, err := vs.velocityRepo.GetAffinities(ctx, tx, run.ID, itemID)
+  if err != nil {
+    return nil, nil, fmt.Errorf("failed to list affinities: %w", err)
+  }
+  return run, affinities, nil
+}
+
+//----------------------------------------------------------------------------------------
+// Runs
+//----------------------------------------------------------------------------------------
+
+// StartRun records a pending run and computes it in the background. Only one
+// run per warehouse may be pending or running at a time.
+func (vs *velocityService) StartRun(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, input VelocityRunInput) (*types.VelocityRun, error) {
+  vs.log.Info("Starting StartRun now...", "warehouseID", warehouseID)
+  warehouse, err := vs.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, err
+  }
+  cfg, err := velocityConfig(input, time.Now().UTC())
+  if err != nil {
+    return nil, err
+  }
+  runs, err := vs.velocityRepo.GetRunsByWarehouseID(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to check running velocity runs: %w", err)
+  }
+  staleBefore := time.Now().Add(-velocityRunLease)
+  for _, r := range runs {
+    if r.Status != types.VelocityRunPending && r.Status != types.VelocityRunRunning {
+      continue
+    }
+    if r.UpdatedAt.Before(staleBefore) {
+      // Its instance went away without the startup sweep seeing it.
+      if err := vs.failInterruptedRun(ctx, tx, r); err != nil {
+        return nil, err
+      }
+      continue
+    }
+    return nil, fmt.Errorf("a velocity run is already in progress for this warehouse")
+  }
+  run := &types.VelocityRun{
+    WarehouseID:   warehouseID,
+    CompanyID:     warehouse.CompanyID,
+    Status:        types.VelocityRunPending,
+    WindowStart:   cfg.WindowStart,
+    WindowEnd:     cfg.WindowEnd,
+    PeriodDays:    cfg.PeriodDays,
+    ABCThresholdA: cfg.ABCThresholdA,
+    ABCThresholdB: cfg.ABCThresholdB,
+    XYZThresholdX: cfg.XYZThresholdX,
+    XYZThresholdY: cfg.XYZThresholdY,
+  }
+  if rd := requestdata.GetRequestData(ctx); rd != nil && rd.UserID != uuid.Nil {
+    userID := rd.UserID
+    run.RequestedByID = &userID
+  }
+  if _, err := vs.velocityRepo.CreateRun(ctx, tx, run); err != nil {
+    return nil, fmt.Errorf("failed to create velocity run: %w", err)
+  }
+  // A caller-supplied tx has not committed the run yet; the goroutine must
+  // not start before it has, so only kick it off for our own writes.
+  if tx == nil {
+    go vs.executeRun(context.Background(), run.ID)
+  }
+  return run, nil
+}
+
+// FailInterruptedRuns marks runs left pending or running by an instance
+// that stopped as failed, so a new run can be started. Runs still
+// heartbeating on other instances are left alone. Call at startup.
+func (vs *velocityService) FailInterruptedRuns(ctx context.Context) error {
+  runs, err := vs.velocityRepo.GetStaleRuns(ctx, nil, []types.VelocityRunStatus{types.VelocityRunPending, types.VelocityRunRunning}, time.Now().Add(-velocityRunLease))
+  if err != nil {
+    return fmt.Errorf("failed to load interrupted velocity runs: %w", err)
+  }
+  for _, run := range runs {
+    if err := vs.failInterruptedRun(ctx, nil, run); err != nil {
+      return err
+    }
+  }
+  if len(runs) > 0 {
+    vs.log.Info("Marked interrupted velocity runs as failed", "count", len(runs))
+  }
+  return nil
+}
+
+// failInterruptedRun marks a run whose instance went away as failed.
+func (vs *velocityService) failInterruptedRun(ctx context.Context, tx *gorm.DB, run *types.VelocityRun) error {
+  now := time.Now().UTC()
+  run.Status = types.VelocityRunFailed
+  run.Error = "interrupted by a server restart"
+  run.CompletedAt = &now
+  if _, err := vs.velocityRepo.UpdateRun(ctx, tx, run); err != nil {
+    return fmt.Errorf("failed to update interrupted velocity run: %w", err)
+  }
+  return nil
+}
+
+// executeRun computes a run outside any request. The stats, affinities and
+// the completed run are written in one transaction, so readers only ever see
+// a complete result.
+func (vs *velocityService) executeRun(ctx context.Context, runID uuid.UUID) {
+  runs, err := vs.velocityRepo.GetRunsByIDs(ctx, nil, []uuid.UUID{runID})
+  if err != nil || len(runs) == 0 {
+    vs.log.Error("Failed to load velocity run", "runID", runID, "error", err)
+    return
+  }
+  run := runs[0]
+  started := time.Now().UTC()
+  run.Status = types.VelocityRunRunning
+  run.StartedAt = &started
+  if _, err := vs.velocityRepo.UpdateRun(ctx, nil, run); err != nil {
+    vs.log.Error("Failed to mark velocity run running", "runID", runID, "error", err)
+    return
+  }
+  beatCtx, stop := context.WithCancel(ctx)
+  defer stop()
+  go vs.heartbeat(beatCtx, runID)
+
+  if err := vs.computeRun(ctx, run); err != nil {
+    vs.log.Warn("Velocity run failed", "runID", runID, "error", err)
+    completed := time.Now().UTC()
+    run.Status = types.VelocityRunFailed
+    run.Error = err.Error()
+    run.CompletedAt = &completed
+    if _, err := vs.velocityRepo.UpdateRun(ctx, nil, run); err != nil {
+      vs.log.Error("Failed to mark velocity run failed", "runID", runID, "error", err)
+    }
+  }
+  if vs.notify != nil {
+    vs.notify(run)
+  }
+}
+
+// heartbeat keeps a running run's lease fresh until ctx ends.
+func (vs *velocityService) heartbeat(ctx context.Context, runID uuid.UUID) {
+  ticker := time.NewTicker(velocityHeartbeatInterval)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-ctx.Done():
+      return
+    case <-ticker.C:
+      if err := vs.velocityRepo.HeartbeatRun(ctx, nil, runID); err != nil {
+        vs.log.Warn("Failed to heartbeat velocity run", "runID", runID, "error", err)
+      }
+    }
+  }
+}
+
+func (vs *velocityService) computeRun(ctx context.Context, run *types.VelocityRun) (err error) {
+  defer func() {
+    if r := recover(); r != nil {
+      err = fmt.Errorf("velocity computation panicked: %v", r)
+    }
+  }()
+  lines, err := vs.orderLineRepo.GetInWindow(ctx, nil, run.WarehouseID, run.WindowStart, run.WindowEnd)
+  if err != nil {
+    return fmt.Errorf("failed to load order lines: %w", err)
+  }
+  items, err := vs.itemRepo.GetByCompanyID(ctx, nil, run.CompanyID)
+  if err != nil {
+    return fmt.Errorf("failed to load items: %w", err)
+  }
+  cube := eachCubes(items)
+  in := make([]velocity.Line, len(lines))
+  for i, l := range lines {
+    in[i] = velocity.Line{OrderID: l.OrderID, ItemID: l.ItemID, Quantity: l.Quantity, OrderedAt: l.OrderedAt}
+  }
+  cfg := velocity.Config{
+    WindowStart:   run.WindowStart,
+    WindowEnd:     run.WindowEnd,
+    PeriodDays:    run.PeriodDays,
+    ABCThresholdA: run.ABCThresholdA,
+    ABCThresholdB: run.ABCThresholdB,
+    XYZThresholdX: run.XYZThresholdX,
+    XYZThresholdY: run.XYZThresholdY,
+  }.WithDefaults(run.WindowEnd)
+  stats, affinities, summary := velocity.Compute(in, cube, cfg)
+
+  rows := make([]*types.SKUVelocity, len(stats))
+  for i, s := range stats {
+    rows[i] = &types.SKUVelocity{
+      RunID:       run.ID,
+      WarehouseID: run.WarehouseID,
+      CompanyID:   run.CompanyID,
+      ItemID:      s.ItemID,
+      Rank:        s.Rank,
+      PickLines:   s.Lines,
+      OrderCount:  s.Orders,
+      Quantity:    s.Quantity,
+      PicksPerDay: s.PicksPerDay,
+      CubeCm3:     s.CubeCm3,
+      CubePerDay:  s.CubePerDay,
+      DemandCV:    s.DemandCV,
+      ABCClass:    s.ABCClass,
+      XYZClass:    s.XYZClass,
+    }
+  }
+  pairs := make([]*types.SKUAffinity, len(affinities))
+  for i, a := range affinities {
+    pairs[i] = &types.SKUAffinity{
+      RunID:         run.ID,
+      WarehouseID:   run.WarehouseID,
+      ItemID:        a.ItemID,
+      OtherItemID:   a.OtherItemID,
+      CoOccurrences: a.CoOccurrences,
+      Support:       a.Support,
+      Confidence:    a.Confidence,
+      Lift:          a.Lift,
+    }
+  }
+
+  return vs.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+    if _, err := vs.velocityRepo.CreateStats(ctx, tx, rows); err != nil {
+      return fmt.Errorf("failed to store velocity stats: %w", err)
+    }
+    if _, err := vs.velocityRepo.CreateAffinities(ctx, tx, pairs); err != nil {
+      return fmt.Errorf("failed to store affinities: %w", err)
+    }
+    completed := time.Now().UTC()
+    run.Status = types.VelocityRunCompleted
+    run.OrderCount = summary.Orders
+    run.LineCount = summary.Lines
+    run.ItemCount = summary.Items
+    run.CompletedAt = &completed
+    if _, err := vs.velocityRepo.UpdateRun(ctx, tx, run); err != nil {
+      return fmt.Errorf("failed to complete velocity run: %w", err)
+    }
+    vs.log.Info("Velocity run completed", "runID", run.ID, "items", summary.Items, "lines", summary.Lines)
+    return vs.pruneRuns(ctx, tx, run.WarehouseID)
+  })
+}
+
+//----------------------------------------------------------------------------------------
+// Helpers
+//----------------------------------------------------------------------------------------
+
+// pruneRuns removes the completed runs beyond velocityRunsKept, oldest first;
+// their stats and affinities go with them through the run_id foreign keys.
+func (vs *velocityService) pruneRuns(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) error {
+  runs, err := vs.velocityRepo.GetRunsByWarehouseID(ctx, tx, warehouseID)
+  if err != nil {
+    return fmt.Errorf("failed to load velocity runs: %w", err)
+  }
+  var old []uuid.UUID
+  kept := 0
+  for _, r := range runs {
+    if r.Status == types.VelocityRunPending || r.Status == types.VelocityRunRunning {
+      continue
+    }
+    kept++
+    if kept > velocityRunsKept {
+      old = append(old, r.ID)
+    }
+  }
+  return vs.velocityRepo.FullDeleteRunsByIDs(ctx, tx, old)
+}
+
+// resultRun returns runID, or the latest completed run when it is nil.
+func (vs *velocityService) resultRun(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, runID *uuid.UUID) (*types.VelocityRun, error) {
+  if runID != nil {
+    run, err := vs.loadRun(ctx, tx, warehouseID, *runID)
+    if err != nil {
+      return nil, err
+    }
+    if run.Status != types.VelocityRunCompleted {
+      return nil, fmt.Errorf("velocity run is %s", run.Status)
+    }
+    return run, nil
+  }
+  run, err := vs.velocityRepo.GetLatestCompletedRun(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to load latest velocity run: %w", err)
+  }
+  if run == nil {
+    return nil, fmt.Errorf("no completed velocity run for this warehouse yet")
+  }
+  return run, nil
+}
+
+func (vs *velocityService) loadRun(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, runID uuid.UUID) (*types.VelocityRun, error) {
+  runs, err := vs.velocityRepo.GetRunsByIDs(ctx, tx, []uuid.UUID{runID})
+  if err != nil {
+    return nil, fmt.Errorf("failed to load velocity run: %w", err)
+  }
+  if len(runs) == 0 || runs[0].WarehouseID != warehouseID {
+    return nil, fmt.Errorf("velocity run not found")
+  }
+  return runs[0], nil
+}
+
+// velocityConfig validates input and resolves it against now.
+func velocityConfig(input VelocityRunInput, now time.Time) (velocity.Config, error) {
+  if input.WindowDays < 0 || input.WindowDays > MaxVelocityWindowDays {
+    return velocity.Config{}, fmt.Errorf("windowDays must be between 1 and %d", MaxVelocityWindowDays)
+  }
+  if input.PeriodDays < 0 {
+    return velocity.Config{}, fmt.Errorf("periodDays cannot be negative")
+  }
+  cfg := velocity.Config{
+    PeriodDays:    input.PeriodDays,
+    ABCThresholdA: input.ABCThresholdA,
+    ABCThresholdB: input.ABCThresholdB,
+    XYZThresholdX: input.XYZThresholdX,
+    XYZThresholdY: input.XYZThresholdY,
+  }
+  if input.WindowEnd != nil {
+    cfg.WindowEnd = input.WindowEnd.UTC()
+  }
+  if input.WindowDays > 0 {
+    end := cfg.WindowEnd
+    if end.IsZero() {
+      end = now
+    }
+    cfg.WindowStart = end.AddDate(0, 0, -input.WindowDays)
+  }
+  cfg = cfg.WithDefaults(now)
+  if cfg.ABCThresholdA >= cfg.ABCThresholdB || cfg.ABCThresholdB > 1 {
+    return velocity.Config{}, fmt.Errorf("ABC thresholds must satisfy 0 < A < B <= 1")
+  }
+  if cfg.XYZThresholdX >= cfg.XYZThresholdY {
+    return velocity.Config{}, fmt.Errorf("XYZ thresholds must satisfy 0 < X < Y")
+  }
+  if cfg.PeriodDays > int(cfg.WindowEnd.Sub(cfg.WindowStart).Hours()/24) {
+    return velocity.Config{}, fmt.Errorf("periodDays cannot be longer than the window")
+  }
+  return cfg, nil
+}
+
+// eachCubes returns the volume of one each per item in cm3, from the each UOM
+// or, when that has no dimensions, the smallest UOM that has them divided by
+// its quantity.
+func eachCubes(items []*types.Item) map[uuid.UUID]float64 {
+  out := make(map[uuid.UUID]float64, len(items))
+  for _, it := range items {
+    for _, u := range it.UOMs {
+      v := u.LengthCm * u.WidthCm * u.HeightCm
+      if v > 0 && u.Quantity > 0 {
+        out[it.ID] = v / float64(u.Quantity)
+        break
+      }
+    }
+  }
+  return out
+}
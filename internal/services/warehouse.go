@@ -8,6 +8,7 @@ import (
   "gorm.io/gorm"
   "github.com/google/uuid"
 
+  "github.com/slotter-org/slotter-backend/internal/events"
   "github.com/slotter-org/slotter-backend/internal/logger"
   "github.com/slotter-org/slotter-backend/internal/types"
   "github.com/slotter-org/slotter-backend/internal/repos"
@@ -21,6 +22,7 @@ type WarehouseService interface {
   UpdateWarehouseNameWithTransaction(ctx context.Context, tx *gorm.DB, warehouse *types.Warehouse, newWarehouseName string) (*types.Warehouse, error) 
   DeleteWarehouse(ctx context.Context, warehouse *types.Warehouse) error
   DeleteWarehouseWithTransaction(ctx context.Context, tx *gorm.DB, warehouse *types.Warehouse) error
+  GetAuthorizedWarehouse(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) (*types.Warehouse, error)
 }
 
 type warehouseService struct {
@@ -68,6 +70,9 @@ func (ws *warehouseService) CreateWarehouse(
     if createErr != nil {
       return createErr
     }
+    if err := recordCompanyEvent(ctx, tx, w.CompanyID, events.WarehouseCreated, w); err != nil {
+      return err
+    }
     theWarehouse = w
     return nil
   })
@@ -354,3 +359,59 @@ func (ws *warehouseService) DeleteWarehouseWithTransaction(
   ws.log.Info("Warehouse successfully deleted", "warehouseID", warehouse.ID)
   return nil
 }
+
+// GetAuthorizedWarehouse loads a warehouse and applies the same tenant rules as
+// the create/update/delete paths: wms users may reach warehouses of companies
+// under their wms, company users only their own company's warehouses.
+func (ws *warehouseService) GetAuthorizedWarehouse(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) (*types.Warehouse, error) {
+  rd := requestdata.GetRequestData(ctx)
+  if rd == nil {
+    ws.log.Warn("Request Data is not set in context.")
+    return nil, fmt.Errorf("Request Data is not set in context.")
+  }
+  if rd.UserID == uuid.Nil {
+    ws.log.Warn("User ID not set in RequestData.")
+    return nil, fmt.Errorf("User ID not set in Request Data.")
+  }
+  if warehouseID == uuid.Nil {
+    ws.log.Warn("GetAuthorizedWarehouse called with nil warehouseID")
+    return nil, fmt.Errorf("warehouse ID cannot be nil")
+  }
+  warehouses, wErr := ws.warehouseRepo.GetByIDs(ctx, tx, []uuid.UUID{warehouseID})
+  if wErr != nil {
+    ws.log.Warn("Failed to fetch warehouse by ID", "error", wErr)
+    return nil, fmt.Errorf("Failed to fetch warehouse: %w", wErr)
+  }
+  if len(warehouses) == 0 {
+    ws.log.Warn("No warehouse found with the given ID", "warehouseID", warehouseID)
+    return nil, fmt.Errorf("warehouse not found")
+  }
+  warehouse := warehouses[0]
+  switch rd.UserType {
+  case "wms":
+    companies, cErr := ws.companyRepo.GetByIDs(ctx, tx, []uuid.UUID{warehouse.CompanyID})
+    if cErr != nil {
+      ws.log.Warn("Failed to fetch warehouse's company", "error", cErr)
+      return nil, cErr
+    }
+    if len(companies) == 0 {
+      ws.log.Warn("No company found matching warehouse's companyID")
+      return nil, fmt.Errorf("no matching company found for warehouse's companyID")
+    }
+    if companies[0].WmsID == nil || *companies[0].WmsID != rd.WmsID {
+      ws.log.Warn("Warehouse's company does not belong to the same WMS as the user")
+      return nil, fmt.Errorf("The warehouse's company does not match the user's wms")
+    }
+
+  case "company":
+    if rd.CompanyID != warehouse.CompanyID {
+      ws.log.Warn("Company user tried to access a warehouse from another company")
+      return nil, fmt.Errorf("Cannot access a warehouse belonging to another company")
+    }
+
+  default:
+    ws.log.Warn("Invalid userType for accessing a warehouse", "userType", rd.UserType)
+    return nil, fmt.Errorf("invalid userType '%s' for accessing a warehouse", rd.UserType)
+  }
+  return warehouse, nil
+}
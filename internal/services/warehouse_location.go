@@ -0,0 +1,540 @@
+package services
+
+import (
+  "context"
+  "fmt"
+  "io"
+  "strings"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/events"
+  "github.com/slotter-org/slotter-backend/internal/layout"
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// MaxLocationsPerRequest caps how many locations one create call may expand
+// to once counts and child templates are multiplied out.
+const MaxLocationsPerRequest = 20000
+
+const fullCodeCheckChunk = 1000
+
+// LocationInput describes locations to create. With Count > 1 that many
+// siblings are created with generated codes; Children is applied as a
+// template under every created location, so a whole aisle of bays, levels
+// and positions can be laid out in one call. Kind may be left empty, it is
+// always the level below the parent (or zone at the top).
+type LocationInput struct {
+  ParentID        *uuid.UUID              `json:"parentID,omitempty"`
+  Kind            types.LocationKind      `json:"kind,omitempty"`
+  Code            string                  `json:"code,omitempty"`
+  Name            string                  `json:"name,omitempty"`
+  LocationType    types.LocationType      `json:"locationType,omitempty"`
+  WidthCm         float64                 `json:"widthCm"`
+  DepthCm         float64                 `json:"depthCm"`
+  HeightCm        float64                 `json:"heightCm"`
+  MaxWeightKg     float64                 `json:"maxWeightKg"`
+  PickSequence    int                     `json:"pickSequence"`
+  Count           int                     `json:"count,omitempty"`
+  Children        []LocationInput         `json:"children,omitempty"`
+}
+
+// LocationPatch holds the fields UpdateLocation may change; nil means keep.
+type LocationPatch struct {
+  Code            *string                 `json:"code,omitempty"`
+  Name            *string                 `json:"name,omitempty"`
+  LocationType    *types.LocationType     `json:"locationType,omitempty"`
+  WidthCm         *float64                `json:"widthCm,omitempty"`
+  DepthCm         *float64                `json:"depthCm,omitempty"`
+  HeightCm        *float64                `json:"heightCm,omitempty"`
+  MaxWeightKg     *float64                `json:"maxWeightKg,omitempty"`
+  PickSequence    *int                    `json:"pickSequence,omitempty"`
+}
+
+type WarehouseLocationService interface {
+  ListLocations(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filter repos.LocationFilter) ([]*types.WarehouseLocation, error)
+  GetLocation(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, locationID uuid.UUID) (*types.WarehouseLocation, error)
+  CreateLocations(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, inputs []LocationInput) ([]*types.WarehouseLocation, error)
+  createLocationsLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, inputs []LocationInput) ([]*types.WarehouseLocation, error)
+  UpdateLocation(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, locationID uuid.UUID, patch LocationPatch) (*types.WarehouseLocation, error)
+  updateLocationLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, locationID uuid.UUID, patch LocationPatch) (*types.WarehouseLocation, error)
+  DeleteLocation(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, locationID uuid.UUID) (*types.WarehouseLocation, error)
+  deleteLocationLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, locationID uuid.UUID) (*types.WarehouseLocation, error)
+  ImportLayout(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filename string, file io.Reader, dryRun bool) (*LayoutImportReport, error)
+  importLayoutLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, rows []layout.ImportRow, rowErrs []layout.RowError, dryRun bool) (*LayoutImportReport, error)
+}
+
+type warehouseLocationService struct {
+  db                    *gorm.DB
+  log                   *logger.Logger
+  warehouseService      WarehouseService
+  locationRepo          repos.WarehouseLocationRepo
+}
+
+func NewWarehouseLocationService(
+  db                    *gorm.DB,
+  log                   *logger.Logger,
+  warehouseService      WarehouseService,
+  locationRepo          repos.WarehouseLocationRepo,
+) WarehouseLocationService {
+  serviceLog := log.With("service", "WarehouseLocationService")
+  return &warehouseLocationService{
+    db:               db,
+    log:              serviceLog,
+    warehouseService: warehouseService,
+    locationRepo:     locationRepo,
+  }
+}
+
+//----------------------------------------------------------------------------------------
+// Read
+//----------------------------------------------------------------------------------------
+
+func (ls *warehouseLocationService) ListLocations(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filter repos.LocationFilter) ([]*types.WarehouseLocation, error) {
+  ls.log.Info("Starting ListLocations now...", "warehouseID", warehouseID)
+  if filter.Kind != "" && !layout.IsValidKind(filter.Kind) {
+    return nil, fmt.Errorf("invalid location kind %q", filter.Kind)
+  }
+  if filter.LocationType != "" && !layout.IsValidLocationType(filter.LocationType) {
+    return nil, fmt.Errorf("invalid location type %q", filter.LocationType)
+  }
+  if _, err := ls.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  locations, err := ls.locationRepo.GetByWarehouseID(ctx, tx, warehouseID, filter)
+  if err != nil {
+    ls.log.Warn("Failed to list locations", "error", err)
+    return nil, fmt.Errorf("failed to list locations: %w", err)
+  }
+  return locations, nil
+}
+
+func (ls *warehouseLocationService) GetLocation(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, locationID uuid.UUID) (*types.WarehouseLocation, error) {
+  ls.log.Info("Starting GetLocation now...", "warehouseID", warehouseID, "locationID", locationID)
+  if _, err := ls.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  return ls.loadLocation(ctx, tx, warehouseID, locationID)
+}
+
+//----------------------------------------------------------------------------------------
+// Create
+//----------------------------------------------------------------------------------------
+
+func (ls *warehouseLocationService) CreateLocations(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, inputs []LocationInput) ([]*types.WarehouseLocation, error) {
+  ls.log.Info("Starting CreateLocations now...", "warehouseID", warehouseID, "inputs", len(inputs))
+  if tx == nil {
+    var out []*types.WarehouseLocation
+    err := ls.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      res, err := ls.createLocationsLogic(ctx, innerTx, warehouseID, inputs)
+      if err != nil {
+        return err
+      }
+      out = res
+      return nil
+    })
+    if err != nil {
+      return nil, err
+    }
+    return out, nil
+  }
+  return ls.createLocationsLogic(ctx, tx, warehouseID, inputs)
+}
+
+// locationBuilder expands LocationInputs into rows. IDs are assigned up front
+// so children can point at parents created in the same call, and rows are
+// collected parent-first so every batch insert satisfies the parent FK.
+type locationBuilder struct {
+  ctx             context.Context
+  tx              *gorm.DB
+  ls              *warehouseLocationService
+  warehouse       *types.Warehouse
+  siblingCodes    map[uuid.UUID][]string
+  fullCodes       map[string]bool
+  pickSequence    int
+  created         []*types.WarehouseLocation
+}
+
+func (ls *warehouseLocationService) createLocationsLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, inputs []LocationInput) ([]*types.WarehouseLocation, error) {
+  if len(inputs) == 0 {
+    return nil, fmt.Errorf("no locations provided")
+  }
+  total := 0
+  for _, in := range inputs {
+    total += expandedLocationCount(in)
+    if total > MaxLocationsPerRequest {
+      return nil, fmt.Errorf("request expands to more than %d locations", MaxLocationsPerRequest)
+    }
+  }
+  warehouse, err := ls.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, err
+  }
+  maxSeq, err := ls.locationRepo.GetMaxPickSequence(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to read pick sequence: %w", err)
+  }
+  b := &locationBuilder{
+    ctx:          ctx,
+    tx:           tx,
+    ls:           ls,
+    warehouse:    warehouse,
+    siblingCodes: map[uuid.UUID][]string{},
+    fullCodes:    map[string]bool{},
+    pickSequence: maxSeq,
+  }
+  for i := range inputs {
+    var parent *types.WarehouseLocation
+    if inputs[i].ParentID != nil {
+      parent, err = ls.loadLocation(ctx, tx, warehouseID, *inputs[i].ParentID)
+      if err != nil {
+        return nil, err
+      }
+    }
+    if err := b.build(parent, inputs[i]); err != nil {
+      return nil, err
+    }
+  }
+
+  codes := make([]string, 0, len(b.fullCodes))
+  for code := range b.fullCodes {
+    codes = append(codes, code)
+  }
+  for start := 0; start < len(codes); start += fullCodeCheckChunk {
+    end := start + fullCodeCheckChunk
+    if end > len(codes) {
+      end = len(codes)
+    }
+    existing, err := ls.locationRepo.GetExistingFullCodes(ctx, tx, warehouseID, codes[start:end])
+    if err != nil {
+      return nil, fmt.Errorf("failed to check location codes: %w", err)
+    }
+    if len(existing) > 0 {
+      return nil, fmt.Errorf("location code %s already exists in this warehouse", existing[0])
+    }
+  }
+
+  created, err := ls.locationRepo.Create(ctx, tx, b.created)
+  if err != nil {
+    ls.log.Warn("Failed to create locations", "error", err)
+    return nil, fmt.Errorf("failed to create locations: %w", err)
+  }
+  ls.log.Info("Locations created", "warehouseID", warehouseID, "count", len(created))
+  if len(created) > 0 {
+    if err := recordCompanyEvent(ctx, tx, created[0].CompanyID, events.LocationsCreated, map[string]interface{}{
+      "warehouseID": warehouseID,
+      "count":       len(created),
+    }); err != nil {
+      return nil, err
+    }
+  }
+  return created, nil
+}
+
+func (b *locationBuilder) build(parent *types.WarehouseLocation, in LocationInput) error {
+  kind := types.LocationKindZone
+  parentFullCode := ""
+  var parentID *uuid.UUID
+  if parent != nil {
+    child, ok := layout.ChildKind(parent.Kind)
+    if !ok {
+      return fmt.Errorf("location %s is a %s and cannot have children", parent.FullCode, parent.Kind)
+    }
+    kind = child
+    parentFullCode = parent.FullCode
+    id := parent.ID
+    parentID = &id
+  }
+  if in.Kind != "" && in.Kind != kind {
+    if parent == nil {
+      return fmt.Errorf("top-level locations must be zones, got %q", in.Kind)
+    }
+    return fmt.Errorf("a %s can only contain %ss, got %q", parent.Kind, kind, in.Kind)
+  }
+  if err := validateLocationAttributes(in.LocationType, in.WidthCm, in.DepthCm, in.HeightCm, in.MaxWeightKg, in.PickSequence); err != nil {
+    return err
+  }
+  count := in.Count
+  if count <= 0 {
+    count = 1
+  }
+  code := ""
+  if in.Code != "" {
+    if count > 1 {
+      return fmt.Errorf("code cannot be set when count is greater than 1")
+    }
+    normalized, err := layout.NormalizeSegment(in.Code)
+    if err != nil {
+      return err
+    }
+    code = normalized
+  }
+  siblings, err := b.siblings(parentID)
+  if err != nil {
+    return err
+  }
+
+  for n := 0; n < count; n++ {
+    segment := code
+    if segment == "" {
+      segment, err = layout.FormatSegment(kind, layout.NextSequence(kind, siblings))
+      if err != nil {
+        return err
+      }
+    }
+    siblings = append(siblings, segment)
+    fullCode := layout.JoinCode(parentFullCode, segment)
+    if b.fullCodes[fullCode] {
+      return fmt.Errorf("location code %s appears more than once in the request", fullCode)
+    }
+    b.fullCodes[fullCode] = true
+
+    loc := &types.WarehouseLocation{
+      ID:           uuid.New(),
+      WarehouseID:  b.warehouse.ID,
+      CompanyID:    b.warehouse.CompanyID,
+      ParentID:     parentID,
+      Kind:         kind,
+      Code:         segment,
+      FullCode:     fullCode,
+      Name:         strings.TrimSpace(in.Name),
+      LocationType: in.LocationType,
+      WidthCm:      in.WidthCm,
+      DepthCm:      in.DepthCm,
+      HeightCm:     in.HeightCm,
+      MaxWeightKg:  in.MaxWeightKg,
+      PickSequence: in.PickSequence,
+    }
+    if kind == types.LocationKindPosition && loc.PickSequence == 0 {
+      b.pickSequence++
+      loc.PickSequence = b.pickSequence
+    }
+    b.created = append(b.created, loc)
+    for _, child := range in.Children {
+      if err := b.build(loc, child); err != nil {
+        return err
+      }
+    }
+  }
+  if parentID != nil {
+    b.siblingCodes[*parentID] = siblings
+  } else {
+    b.siblingCodes[uuid.Nil] = siblings
+  }
+  return nil
+}
+
+// siblings returns the codes already used under parentID, from the database
+// the first time and from what this request has added afterwards.
+func (b *locationBuilder) siblings(parentID *uuid.UUID) ([]string, error) {
+  key := uuid.Nil
+  if parentID != nil {
+    key = *parentID
+  }
+  if codes, ok := b.siblingCodes[key]; ok {
+    return codes, nil
+  }
+  codes, err := b.ls.locationRepo.GetSiblingCodes(b.ctx, b.tx, b.warehouse.ID, parentID)
+  if err != nil {
+    return nil, fmt.Errorf("failed to read sibling location codes: %w", err)
+  }
+  b.siblingCodes[key] = codes
+  return codes, nil
+}
+
+func expandedLocationCount(in LocationInput) int {
+  count := in.Count
+  if count <= 0 {
+    count = 1
+  }
+  if count > MaxLocationsPerRequest {
+    return count
+  }
+  perNode := 1
+  for _, child := range in.Children {
+    perNode += expandedLocationCount(child)
+    if perNode > MaxLocationsPerRequest {
+      return perNode
+    }
+  }
+  return count * perNode
+}
+
+//----------------------------------------------------------------------------------------
+// Update
+//----------------------------------------------------------------------------------------
+
+func (ls *warehouseLocationService) UpdateLocation(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, locationID uuid.UUID, patch LocationPatch) (*types.WarehouseLocation, error) {
+  ls.log.Info("Starting UpdateLocation now...", "warehouseID", warehouseID, "locationID", locationID)
+  if tx == nil {
+    var out *types.WarehouseLocation
+    err := ls.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      res, err := ls.updateLocationLogic(ctx, innerTx, warehouseID, locationID, patch)
+      if err != nil {
+        return err
+      }
+      out = res
+      return nil
+    })
+    if err != nil {
+      return nil, err
+    }
+    return out, nil
+  }
+  return ls.updateLocationLogic(ctx, tx, warehouseID, locationID, patch)
+}
+
+// updateLocationLogic applies patch. Changing the code also rewrites the
+// full code of every location underneath it.
+func (ls *warehouseLocationService) updateLocationLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, locationID uuid.UUID, patch LocationPatch) (*types.WarehouseLocation, error) {
+  if _, err := ls.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  loc, err := ls.loadLocation(ctx, tx, warehouseID, locationID)
+  if err != nil {
+    return nil, err
+  }
+  if patch.Name != nil {
+    loc.Name = strings.TrimSpace(*patch.Name)
+  }
+  if patch.LocationType != nil {
+    loc.LocationType = *patch.LocationType
+  }
+  if patch.WidthCm != nil {
+    loc.WidthCm = *patch.WidthCm
+  }
+  if patch.DepthCm != nil {
+    loc.DepthCm = *patch.DepthCm
+  }
+  if patch.HeightCm != nil {
+    loc.HeightCm = *patch.HeightCm
+  }
+  if patch.MaxWeightKg != nil {
+    loc.MaxWeightKg = *patch.MaxWeightKg
+  }
+  if patch.PickSequence != nil {
+    loc.PickSequence = *patch.PickSequence
+  }
+  if err := validateLocationAttributes(loc.LocationType, loc.WidthCm, loc.DepthCm, loc.HeightCm, loc.MaxWeightKg, loc.PickSequence); err != nil {
+    return nil, err
+  }
+
+  if patch.Code != nil {
+    code, err := layout.NormalizeSegment(*patch.Code)
+    if err != nil {
+      return nil, err
+    }
+    if code != loc.Code {
+      oldFullCode := loc.FullCode
+      newFullCode := strings.TrimSuffix(oldFullCode, loc.Code) + code
+      existing, err := ls.locationRepo.GetExistingFullCodes(ctx, tx, warehouseID, []string{newFullCode})
+      if err != nil {
+        return nil, fmt.Errorf("failed to check location code: %w", err)
+      }
+      if len(existing) > 0 {
+        return nil, fmt.Errorf("location code %s already exists in this warehouse", newFullCode)
+      }
+      moved, err := ls.locationRepo.ReplaceFullCodePrefix(ctx, tx, warehouseID, oldFullCode+layout.CodeSeparator, newFullCode+layout.CodeSeparator)
+      if err != nil {
+        return nil, fmt.Errorf("failed to update child location codes: %w", err)
+      }
+      ls.log.Info("Location code changed", "from", oldFullCode, "to", newFullCode, "descendants", moved)
+      loc.Code = code
+      loc.FullCode = newFullCode
+    }
+  }
+
+  updated, err := ls.locationRepo.Update(ctx, tx, []*types.WarehouseLocation{loc})
+  if err != nil {
+    ls.log.Warn("Failed to update location", "error", err)
+    return nil, fmt.Errorf("failed to update location: %w", err)
+  }
+  if len(updated) == 0 {
+    return nil, fmt.Errorf("no location was updated - unexpected empty result")
+  }
+  if err := recordCompanyEvent(ctx, tx, updated[0].CompanyID, events.LocationUpdated, updated[0]); err != nil {
+    return nil, err
+  }
+  return updated[0], nil
+}
+
+//----------------------------------------------------------------------------------------
+// Delete
+//----------------------------------------------------------------------------------------
+
+func (ls *warehouseLocationService) DeleteLocation(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, locationID uuid.UUID) (*types.WarehouseLocation, error) {
+  ls.log.Info("Starting DeleteLocation now...", "warehouseID", warehouseID, "locationID", locationID)
+  if tx == nil {
+    var out *types.WarehouseLocation
+    err := ls.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      res, err := ls.deleteLocationLogic(ctx, innerTx, warehouseID, locationID)
+      if err != nil {
+        return err
+      }
+      out = res
+      return nil
+    })
+    if err != nil {
+      return nil, err
+    }
+    return out, nil
+  }
+  return ls.deleteLocationLogic(ctx, tx, warehouseID, locationID)
+}
+
+// deleteLocationLogic removes the location; its children go with it through
+// the parent_id ON DELETE CASCADE.
+func (ls *warehouseLocationService) deleteLocationLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, locationID uuid.UUID) (*types.WarehouseLocation, error) {
+  if _, err := ls.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID); err != nil {
+    return nil, err
+  }
+  loc, err := ls.loadLocation(ctx, tx, warehouseID, locationID)
+  if err != nil {
+    return nil, err
+  }
+  if err := ls.locationRepo.FullDeleteByIDs(ctx, tx, []uuid.UUID{loc.ID}); err != nil {
+    ls.log.Warn("Failed to delete location", "error", err)
+    return nil, fmt.Errorf("failed to delete location: %w", err)
+  }
+  ls.log.Info("Location deleted", "warehouseID", warehouseID, "fullCode", loc.FullCode)
+  if err := recordCompanyEvent(ctx, tx, loc.CompanyID, events.LocationDeleted, loc); err != nil {
+    return nil, err
+  }
+  return loc, nil
+}
+
+//----------------------------------------------------------------------------------------
+// Helpers
+//----------------------------------------------------------------------------------------
+
+// loadLocation fetches a location and makes sure it belongs to warehouseID,
+// which the caller has already authorized.
+func (ls *warehouseLocationService) loadLocation(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, locationID uuid.UUID) (*types.WarehouseLocation, error) {
+  locations, err := ls.locationRepo.GetByIDs(ctx, tx, []uuid.UUID{locationID})
+  if err != nil {
+    return nil, fmt.Errorf("failed to load location: %w", err)
+  }
+  if len(locations) == 0 || locations[0].WarehouseID != warehouseID {
+    return nil, fmt.Errorf("location not found")
+  }
+  return locations[0], nil
+}
+
+func validateLocationAttributes(locationType types.LocationType, width, depth, height, maxWeight float64, pickSequence int) error {
+  if locationType != "" && !layout.IsValidLocationType(locationType) {
+    return fmt.Errorf("invalid location type %q", locationType)
+  }
+  if width < 0 || depth < 0 || height < 0 {
+    return fmt.Errorf("location dimensions cannot be negative")
+  }
+  if maxWeight < 0 {
+    return fmt.Errorf("location weight capacity cannot be negative")
+  }
+  if pickSequence < 0 {
+    return fmt.Errorf("pick sequence cannot be negative")
+  }
+  return nil
+}
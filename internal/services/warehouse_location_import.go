@@ -0,0 +1,211 @@
+package services
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "io"
+  "sort"
+  "strings"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/events"
+  "github.com/slotter-org/slotter-backend/internal/layout"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+// MaxLayoutImportBytes is the largest CSV/XLSX accepted by ImportLayout.
+const MaxLayoutImportBytes = 20 << 20
+
+// ErrLayoutImportInvalid is returned together with a report when a real
+// (non dry-run) import is rejected because some rows failed validation.
+var ErrLayoutImportInvalid = errors.New("layout import has invalid rows")
+
+// LayoutImportReport describes what an import did, or would do on a dry
+// run. ImpliedParents counts the zones, aisles and so on that were not rows
+// in the file but had to be created for a row's code to have a parent.
+type LayoutImportReport struct {
+  WarehouseID     uuid.UUID               `json:"warehouseID"`
+  CompanyID       uuid.UUID               `json:"companyID"`
+  DryRun          bool                    `json:"dryRun"`
+  Valid           bool                    `json:"valid"`
+  TotalRows       int                     `json:"totalRows"`
+  Created         int                     `json:"created"`
+  ImpliedParents  int                     `json:"impliedParents"`
+  Errors          []layout.RowError       `json:"errors"`
+}
+
+//----------------------------------------------------------------------------------------
+// Import
+//----------------------------------------------------------------------------------------
+
+// ImportLayout reads the sheet before opening the transaction, then creates
+// every location in one transaction so a failed import leaves nothing behind.
+func (ls *warehouseLocationService) ImportLayout(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, filename string, file io.Reader, dryRun bool) (*LayoutImportReport, error) {
+  ls.log.Info("Starting ImportLayout now...", "warehouseID", warehouseID, "filename", filename, "dryRun", dryRun)
+  records, err := layout.ReadSheet(filename, file, layout.MaxImportRows)
+  if err != nil {
+    return nil, err
+  }
+  rows, rowErrs, err := layout.ParseImport(records)
+  if err != nil {
+    return nil, err
+  }
+  if tx == nil {
+    var out *LayoutImportReport
+    err := ls.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      res, err := ls.importLayoutLogic(ctx, innerTx, warehouseID, rows, rowErrs, dryRun)
+      out = res
+      return err
+    })
+    if err != nil {
+      return out, err
+    }
+    return out, nil
+  }
+  return ls.importLayoutLogic(ctx, tx, warehouseID, rows, rowErrs, dryRun)
+}
+
+func (ls *warehouseLocationService) importLayoutLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, rows []layout.ImportRow, rowErrs []layout.RowError, dryRun bool) (*LayoutImportReport, error) {
+  warehouse, err := ls.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, err
+  }
+  badRows := map[int]bool{}
+  for _, e := range rowErrs {
+    badRows[e.Row] = true
+  }
+  report := &LayoutImportReport{
+    WarehouseID: warehouse.ID,
+    CompanyID:   warehouse.CompanyID,
+    DryRun:      dryRun,
+    TotalRows:   len(rows) + len(badRows),
+    Errors:      append([]layout.RowError{}, rowErrs...),
+  }
+  existing, err := ls.locationRepo.GetByWarehouseID(ctx, tx, warehouseID, repos.LocationFilter{})
+  if err != nil {
+    return nil, fmt.Errorf("failed to load existing locations: %w", err)
+  }
+  existingByCode := make(map[string]*types.WarehouseLocation, len(existing))
+  maxSeq := 0
+  for _, loc := range existing {
+    existingByCode[loc.FullCode] = loc
+    if loc.PickSequence > maxSeq {
+      maxSeq = loc.PickSequence
+    }
+  }
+
+  newByCode := map[string]*types.WarehouseLocation{}
+  for _, row := range rows {
+    if _, ok := existingByCode[row.FullCode]; ok {
+      report.Errors = append(report.Errors, layout.RowError{Row: row.Row, Code: row.FullCode, Field: "code", Message: "location already exists in this warehouse"})
+      continue
+    }
+    newByCode[row.FullCode] = &types.WarehouseLocation{
+      ID:           uuid.New(),
+      WarehouseID:  warehouse.ID,
+      CompanyID:    warehouse.CompanyID,
+      Kind:         row.Kind,
+      Code:         row.Segments[len(row.Segments)-1],
+      FullCode:     row.FullCode,
+      Name:         row.Name,
+      LocationType: row.LocationType,
+      WidthCm:      row.WidthCm,
+      DepthCm:      row.DepthCm,
+      HeightCm:     row.HeightCm,
+      MaxWeightKg:  row.MaxWeightKg,
+      PickSequence: row.PickSequence,
+    }
+  }
+  report.Created = len(newByCode)
+
+  // Link every new location to its parent, creating bare intermediate
+  // locations for prefixes that are neither in the file nor the warehouse.
+  var ensureParent func(segments []string) *uuid.UUID
+  ensureParent = func(segments []string) *uuid.UUID {
+    if len(segments) <= 1 {
+      return nil
+    }
+    parentSegments := segments[:len(segments)-1]
+    parentCode := strings.Join(parentSegments, layout.CodeSeparator)
+    if loc, ok := existingByCode[parentCode]; ok {
+      return &loc.ID
+    }
+    parent, ok := newByCode[parentCode]
+    if !ok {
+      parent = &types.WarehouseLocation{
+        ID:          uuid.New(),
+        WarehouseID: warehouse.ID,
+        CompanyID:   warehouse.CompanyID,
+        Kind:        types.LocationKinds[len(parentSegments)-1],
+        Code:        parentSegments[len(parentSegments)-1],
+        FullCode:    parentCode,
+      }
+      newByCode[parentCode] = parent
+      report.ImpliedParents++
+    }
+    if parent.ParentID == nil {
+      parent.ParentID = ensureParent(parentSegments)
+    }
+    return &parent.ID
+  }
+  for _, row := range rows {
+    if loc, ok := newByCode[row.FullCode]; ok && loc.ParentID == nil {
+      loc.ParentID = ensureParent(row.Segments)
+    }
+  }
+
+  report.Valid = len(report.Errors) == 0
+  sort.SliceStable(report.Errors, func(i, j int) bool { return report.Errors[i].Row < report.Errors[j].Row })
+  if !report.Valid {
+    if dryRun {
+      return report, nil
+    }
+    ls.log.Warn("Rejected layout import", "warehouseID", warehouseID, "errors", len(report.Errors))
+    return report, ErrLayoutImportInvalid
+  }
+
+  // Parents first so every insert batch satisfies the parent_id FK, then
+  // positions without a pick sequence are numbered in code order.
+  created := make([]*types.WarehouseLocation, 0, len(newByCode))
+  for _, loc := range newByCode {
+    created = append(created, loc)
+  }
+  sort.Slice(created, func(i, j int) bool {
+    di, dj := locationDepth(created[i].Kind), locationDepth(created[j].Kind)
+    if di != dj {
+      return di < dj
+    }
+    return created[i].FullCode < created[j].FullCode
+  })
+  for _, loc := range created {
+    if loc.Kind == types.LocationKindPosition && loc.PickSequence == 0 {
+      maxSeq++
+      loc.PickSequence = maxSeq
+    }
+  }
+  if dryRun {
+    return report, nil
+  }
+  if _, err := ls.locationRepo.Create(ctx, tx, created); err != nil {
+    ls.log.Warn("Failed to create imported locations", "error", err)
+    return nil, fmt.Errorf("failed to create imported locations: %w", err)
+  }
+  ls.log.Info("Layout imported", "warehouseID", warehouseID, "created", report.Created, "impliedParents", report.ImpliedParents)
+  if err := recordCompanyEvent(ctx, tx, report.CompanyID, events.LayoutImported, report); err != nil {
+    return nil, err
+  }
+  return report, nil
+}
+
+func locationDepth(kind types.LocationKind) int {
+  for i, k := range types.LocationKinds {
+    if k == kind {
+      return i
+    }
+  }
+  return len(types.LocationKinds)
+}
@@ -0,0 +1,578 @@
+package services
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "math"
+  "sort"
+  "strings"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/events"
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/routing"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+const (
+  MaxRouteStops         = 500
+  maxDockNameLength     = 50
+  routeEndpointDepot    = "depot"
+  routeEndpointDock     = "dock:"
+)
+
+// ErrRouteUnreachable is returned when one-way aisles leave no way to walk
+// a requested route.
+var ErrRouteUnreachable = errors.New("route cannot be walked with the warehouse's one-way aisles")
+
+// NavigationInput replaces a warehouse's navigation. Zero spacing and bay
+// width fall back to the defaults.
+type NavigationInput struct {
+  AisleSpacingM     float64                           `json:"aisleSpacingM"`
+  BayWidthM         float64                           `json:"bayWidthM"`
+  CrossAisles       []float64                         `json:"crossAisles"`
+  OneWayAisles      map[string]types.AisleDirection   `json:"oneWayAisles"`
+  Depot             types.NavigationPoint             `json:"depot"`
+  Docks             []types.NavigationDock            `json:"docks"`
+}
+
+// NavigationAisle is an aisle as the navigation places it.
+type NavigationAisle struct {
+  LocationID        uuid.UUID                         `json:"locationID"`
+  Code              string                            `json:"code"`
+  X                 float64                           `json:"x"`
+  LengthM           float64                           `json:"lengthM"`
+  Direction         types.AisleDirection              `json:"direction,omitempty"`
+}
+
+// NavigationDetail is a warehouse's navigation with the aisles it lays out.
+// Saved is false while the warehouse runs on the defaults.
+type NavigationDetail struct {
+  Navigation        *types.WarehouseNavigation        `json:"navigation"`
+  Saved             bool                              `json:"saved"`
+  LengthM           float64                           `json:"lengthM"`
+  Aisles            []NavigationAisle                 `json:"aisles"`
+}
+
+// RouteEstimateRequest asks for the shortest path between From and To, or,
+// with Locations, for pick routes from From through every location to To.
+// From and To are "depot", "dock:<name>" or, for a shortest path, a
+// location's full code; From defaults to the depot and To to From.
+// Heuristics defaults to all of them.
+type RouteEstimateRequest struct {
+  From              string                            `json:"from,omitempty"`
+  To                string                            `json:"to,omitempty"`
+  Locations         []string                          `json:"locations,omitempty"`
+  Heuristics        []routing.Heuristic               `json:"heuristics,omitempty"`
+}
+
+// RoutePath is a shortest path in metres with the points it walks through.
+type RoutePath struct {
+  DistanceM         float64                           `json:"distanceM"`
+  Points            []types.NavigationPoint           `json:"points"`
+}
+
+// RouteResult is one heuristic's pick route. Sequence is the locations in
+// visiting order.
+type RouteResult struct {
+  Heuristic         routing.Heuristic                 `json:"heuristic"`
+  DistanceM         float64                           `json:"distanceM"`
+  Exact             bool                              `json:"exact"`
+  Sequence          []string                          `json:"sequence"`
+}
+
+type RouteEstimate struct {
+  From              string                            `json:"from"`
+  To                string                            `json:"to"`
+  Path              *RoutePath                        `json:"path,omitempty"`
+  Routes            []RouteResult                     `json:"routes,omitempty"`
+}
+
+type WarehouseNavigationService interface {
+  GetNavigation(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) (*NavigationDetail, error)
+  UpdateNavigation(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, input NavigationInput) (*NavigationDetail, error)
+  updateNavigationLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, input NavigationInput) (*NavigationDetail, error)
+  EstimateRoute(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, req RouteEstimateRequest) (*RouteEstimate, error)
+}
+
+type warehouseNavigationService struct {
+  db                    *gorm.DB
+  log                   *logger.Logger
+  warehouseService      WarehouseService
+  locationRepo          repos.WarehouseLocationRepo
+  navigationRepo        repos.WarehouseNavigationRepo
+}
+
+func NewWarehouseNavigationService(
+  db                    *gorm.DB,
+  log                   *logger.Logger,
+  warehouseService      WarehouseService,
+  locationRepo          repos.WarehouseLocationRepo,
+  navigationRepo        repos.WarehouseNavigationRepo,
+) WarehouseNavigationService {
+  serviceLog := log.With("service", "WarehouseNavigationService")
+  return &warehouseNavigationService{
+    db:               db,
+    log:              serviceLog,
+    warehouseService: warehouseService,
+    locationRepo:     locationRepo,
+    navigationRepo:   navigationRepo,
+  }
+}
+
+//----------------------------------------------------------------------------------------
+// Read
+//----------------------------------------------------------------------------------------
+
+func (ns *warehouseNavigationService) GetNavigation(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID) (*NavigationDetail, error) {
+  ns.log.Info("Starting GetNavigation now...", "warehouseID", warehouseID)
+  warehouse, err := ns.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, err
+  }
+  nav, saved, err := loadNavigation(ctx, tx, warehouse.ID, warehouse.CompanyID, ns.navigationRepo)
+  if err != nil {
+    return nil, err
+  }
+  locations, err := ns.locationRepo.GetByWarehouseID(ctx, tx, warehouseID, repos.LocationFilter{})
+  if err != nil {
+    return nil, fmt.Errorf("failed to load locations: %w", err)
+  }
+  return navigationDetail(nav, saved, locations), nil
+}
+
+// EstimateRoute answers a RouteEstimateRequest in metres on the warehouse's
+// navigation graph.
+func (ns *warehouseNavigationService) EstimateRoute(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, req RouteEstimateRequest) (*RouteEstimate, error) {
+  ns.log.Info("Starting EstimateRoute now...", "warehouseID", warehouseID, "locations", len(req.Locations))
+  warehouse, err := ns.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, err
+  }
+  if len(req.Locations) > MaxRouteStops {
+    return nil, fmt.Errorf("too many locations in one route (max %d)", MaxRouteStops)
+  }
+  heuristics := req.Heuristics
+  if len(heuristics) == 0 {
+    heuristics = routing.Heuristics
+  }
+  for _, h := range heuristics {
+    if h != routing.SShape && h != routing.LargestGap && h != routing.Optimal {
+      return nil, fmt.Errorf("invalid heuristic %q, expected one of s_shape, largest_gap, optimal", h)
+    }
+  }
+  nav, _, err := loadNavigation(ctx, tx, warehouse.ID, warehouse.CompanyID, ns.navigationRepo)
+  if err != nil {
+    return nil, err
+  }
+  locations, err := ns.locationRepo.GetByWarehouseID(ctx, tx, warehouseID, repos.LocationFilter{})
+  if err != nil {
+    return nil, fmt.Errorf("failed to load locations: %w", err)
+  }
+  model, err := buildNavigationModel(nav, locations)
+  if err != nil {
+    return nil, err
+  }
+
+  from := strings.TrimSpace(req.From)
+  if from == "" {
+    from = routeEndpointDepot
+  }
+  to := strings.TrimSpace(req.To)
+  if to == "" {
+    to = from
+  }
+  out := &RouteEstimate{From: from, To: to}
+
+  // Every named location becomes a stop of the graph: the route's stops,
+  // or for a shortest path whichever endpoints are locations.
+  var codes []string
+  if len(req.Locations) > 0 {
+    codes = req.Locations
+  } else {
+    for _, end := range []string{from, to} {
+      if !isRouteAnchor(end) {
+        codes = append(codes, end)
+      }
+    }
+  }
+  stops := make([]routing.Stop, 0, len(codes))
+  names := make([]string, 0, len(codes))
+  for _, code := range codes {
+    stop, name, err := model.stopByCode(code)
+    if err != nil {
+      return nil, err
+    }
+    stops = append(stops, stop)
+    names = append(names, name)
+  }
+  g, err := routing.Build(model.layout, stops)
+  if err != nil {
+    return nil, fmt.Errorf("invalid navigation: %w", err)
+  }
+  next := 0
+  endpoint := func(name string) (routing.NodeID, error) {
+    if isRouteAnchor(name) {
+      return anchorNode(g, name)
+    }
+    if len(req.Locations) > 0 {
+      return 0, fmt.Errorf("a route with locations must start and end at the depot or a dock")
+    }
+    id := g.Stop(next)
+    next++
+    return id, nil
+  }
+  start, err := endpoint(from)
+  if err != nil {
+    return nil, err
+  }
+  end, err := endpoint(to)
+  if err != nil {
+    return nil, err
+  }
+
+  if len(req.Locations) == 0 {
+    path, err := g.ShortestPath(start, end)
+    if err != nil {
+      return nil, routeError(err)
+    }
+    out.Path = &RoutePath{DistanceM: roundMetres(path.Distance)}
+    for _, p := range path.Points {
+      out.Path.Points = append(out.Path.Points, types.NavigationPoint{X: p.X, Y: p.Y})
+    }
+    return out, nil
+  }
+  for _, h := range heuristics {
+    route, err := g.EstimateRoute(h, start, end)
+    if err != nil {
+      return nil, routeError(err)
+    }
+    res := RouteResult{Heuristic: h, DistanceM: roundMetres(route.Distance), Exact: route.Exact}
+    for _, i := range route.Order {
+      res.Sequence = append(res.Sequence, names[i])
+    }
+    out.Routes = append(out.Routes, res)
+  }
+  return out, nil
+}
+
+//----------------------------------------------------------------------------------------
+// Update
+//----------------------------------------------------------------------------------------
+
+// UpdateNavigation replaces the warehouse's navigation after checking it
+// against the current aisles.
+func (ns *warehouseNavigationService) UpdateNavigation(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, input NavigationInput) (*NavigationDetail, error) {
+  ns.log.Info("Starting UpdateNavigation now...", "warehouseID", warehouseID)
+  if tx == nil {
+    var out *NavigationDetail
+    if err := ns.db.WithContext(ctx).Transaction(func(innerTx *gorm.DB) error {
+      var err error
+      out, err = ns.updateNavigationLogic(ctx, innerTx, warehouseID, input)
+      return err
+    }); err != nil {
+      return nil, err
+    }
+    return out, nil
+  }
+  return ns.updateNavigationLogic(ctx, tx, warehouseID, input)
+}
+
+func (ns *warehouseNavigationService) updateNavigationLogic(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, input NavigationInput) (*NavigationDetail, error) {
+  warehouse, err := ns.warehouseService.GetAuthorizedWarehouse(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, err
+  }
+  nav, _, err := loadNavigation(ctx, tx, warehouse.ID, warehouse.CompanyID, ns.navigationRepo)
+  if err != nil {
+    return nil, err
+  }
+  locations, err := ns.locationRepo.GetByWarehouseID(ctx, tx, warehouseID, repos.LocationFilter{})
+  if err != nil {
+    return nil, fmt.Errorf("failed to load locations: %w", err)
+  }
+
+  if input.AisleSpacingM < 0 || input.BayWidthM < 0 {
+    return nil, fmt.Errorf("aisle spacing and bay width cannot be negative")
+  }
+  nav.AisleSpacingM = input.AisleSpacingM
+  if nav.AisleSpacingM == 0 {
+    nav.AisleSpacingM = types.DefaultAisleSpacingM
+  }
+  nav.BayWidthM = input.BayWidthM
+  if nav.BayWidthM == 0 {
+    nav.BayWidthM = types.DefaultBayWidthM
+  }
+  nav.Depot = input.Depot
+
+  aisleCodes := map[string]string{}
+  for _, loc := range locations {
+    if loc.Kind == types.LocationKindAisle {
+      aisleCodes[strings.ToUpper(loc.FullCode)] = loc.FullCode
+    }
+  }
+  nav.OneWayAisles = map[string]types.AisleDirection{}
+  for code, dir := range input.OneWayAisles {
+    fullCode, ok := aisleCodes[strings.ToUpper(strings.TrimSpace(code))]
+    if !ok {
+      return nil, fmt.Errorf("one-way aisle %s is not an aisle of this warehouse", code)
+    }
+    dir = types.AisleDirection(strings.ToLower(strings.TrimSpace(string(dir))))
+    if dir != types.AisleDirectionUp && dir != types.AisleDirectionDown {
+      return nil, fmt.Errorf("aisle %s has invalid direction %q, expected up or down", code, dir)
+    }
+    nav.OneWayAisles[fullCode] = dir
+  }
+  nav.Docks = nil
+  for _, d := range input.Docks {
+    d.Name = strings.TrimSpace(d.Name)
+    if len(d.Name) > maxDockNameLength {
+      return nil, fmt.Errorf("dock name cannot be longer than %d characters", maxDockNameLength)
+    }
+    nav.Docks = append(nav.Docks, d)
+  }
+  nav.CrossAisles = append([]float64{}, input.CrossAisles...)
+  sort.Float64s(nav.CrossAisles)
+
+  // The layout checks positions, cross aisles and docks as the graph will
+  // see them, so a saved navigation always builds.
+  model, err := buildNavigationModel(nav, locations)
+  if err != nil {
+    return nil, err
+  }
+  if len(model.layout.CrossAisles) != len(nav.CrossAisles) {
+    return nil, fmt.Errorf("cross aisles must lie between the front and the back of the aisles (0 to %g m)", model.layout.Length)
+  }
+  if err := model.layout.Validate(nil); err != nil {
+    return nil, err
+  }
+  if rd := requestdata.GetRequestData(ctx); rd != nil && rd.UserID != uuid.Nil {
+    userID := rd.UserID
+    nav.UpdatedByID = &userID
+  }
+  if _, err := ns.navigationRepo.Save(ctx, tx, nav); err != nil {
+    return nil, fmt.Errorf("failed to save warehouse navigation: %w", err)
+  }
+  detail := navigationDetail(nav, true, locations)
+  if err := recordCompanyEvent(ctx, tx, nav.CompanyID, events.WarehouseNavigationUpdated, detail); err != nil {
+    return nil, err
+  }
+  return detail, nil
+}
+
+//----------------------------------------------------------------------------------------
+// Helpers
+//----------------------------------------------------------------------------------------
+
+// loadNavigation is the warehouse's saved navigation or, until one is
+// saved, the defaults. saved tells which.
+func loadNavigation(ctx context.Context, tx *gorm.DB, warehouseID uuid.UUID, companyID uuid.UUID, navigationRepo repos.WarehouseNavigationRepo) (*types.WarehouseNavigation, bool, error) {
+  nav, err := navigationRepo.GetByWarehouseID(ctx, tx, warehouseID)
+  if err != nil {
+    return nil, false, fmt.Errorf("failed to load warehouse navigation: %w", err)
+  }
+  if nav != nil {
+    return nav, true, nil
+  }
+  return &types.WarehouseNavigation{
+    WarehouseID:   warehouseID,
+    CompanyID:     companyID,
+    AisleSpacingM: types.DefaultAisleSpacingM,
+    BayWidthM:     types.DefaultBayWidthM,
+  }, false, nil
+}
+
+// navigationModel is a routing.Layout built from a warehouse's locations,
+// with where each location sits on it and, for floor maps, the span each
+// bay covers along its aisle.
+type navigationModel struct {
+  layout          routing.Layout
+  aisles          []*types.WarehouseLocation
+  lengths         []float64
+  positions       map[uuid.UUID]routing.Stop
+  byCode          map[string]*types.WarehouseLocation
+  aisleIndex      map[uuid.UUID]int
+  baySpans        map[uuid.UUID][2]float64
+}
+
+// buildNavigationModel lays the aisles out side by side in pick-sequence
+// order and their bays along them in the same order, each as wide as its
+// location says. A location sits at the middle of its bay; one in an aisle
+// without bays at the middle of the aisle. Locations outside any aisle are
+// not on the layout. Cross aisles beyond the aisles are dropped.
+func buildNavigationModel(nav *types.WarehouseNavigation, locations []*types.WarehouseLocation) (*navigationModel, error) {
+  m := &navigationModel{
+    positions: make(map[uuid.UUID]routing.Stop, len(locations)),
+    byCode:    make(map[string]*types.WarehouseLocation, len(locations)),
+    baySpans:  map[uuid.UUID][2]float64{},
+  }
+  spacing, bayWidth := nav.AisleSpacingM, nav.BayWidthM
+  if spacing <= 0 {
+    spacing = types.DefaultAisleSpacingM
+  }
+  if bayWidth <= 0 {
+    bayWidth = types.DefaultBayWidthM
+  }
+
+  byID := make(map[uuid.UUID]*types.WarehouseLocation, len(locations))
+  children := map[uuid.UUID][]*types.WarehouseLocation{}
+  for _, loc := range locations {
+    byID[loc.ID] = loc
+    m.byCode[strings.ToUpper(loc.FullCode)] = loc
+    if loc.ParentID != nil {
+      children[*loc.ParentID] = append(children[*loc.ParentID], loc)
+    }
+    if loc.Kind == types.LocationKindAisle {
+      m.aisles = append(m.aisles, loc)
+    }
+  }
+  if len(m.aisles) == 0 {
+    return nil, fmt.Errorf("warehouse has no aisles to route through")
+  }
+  sortBySequence(m.aisles)
+
+  m.aisleIndex = make(map[uuid.UUID]int, len(m.aisles))
+  for i, a := range m.aisles {
+    m.aisleIndex[a.ID] = i
+    var bays []*types.WarehouseLocation
+    for _, c := range children[a.ID] {
+      if c.Kind == types.LocationKindBay {
+        bays = append(bays, c)
+      }
+    }
+    sortBySequence(bays)
+    y := 0.0
+    for _, b := range bays {
+      w := bayWidth
+      if b.WidthCm > 0 {
+        w = b.WidthCm / 100
+      }
+      m.baySpans[b.ID] = [2]float64{y, y + w}
+      y += w
+    }
+    if y == 0 {
+      y = bayWidth
+    }
+    m.lengths = append(m.lengths, y)
+    m.layout.Length = math.Max(m.layout.Length, y)
+    m.layout.Aisles = append(m.layout.Aisles, routing.Aisle{
+      Name:      a.FullCode,
+      X:         float64(i) * spacing,
+      Direction: routing.Direction(nav.OneWayAisles[a.FullCode]),
+    })
+  }
+
+  for _, loc := range locations {
+    var bay *types.WarehouseLocation
+    for at := loc; at != nil; {
+      if at.Kind == types.LocationKindBay {
+        bay = at
+      }
+      if i, ok := m.aisleIndex[at.ID]; ok {
+        if at == loc {
+          break
+        }
+        y := m.lengths[i] / 2
+        if bay != nil && bay.ParentID != nil && *bay.ParentID == at.ID {
+          y = (m.baySpans[bay.ID][0] + m.baySpans[bay.ID][1]) / 2
+        }
+        m.positions[loc.ID] = routing.Stop{Aisle: i, Y: y}
+        break
+      }
+      if at.ParentID == nil {
+        break
+      }
+      at = byID[*at.ParentID]
+    }
+  }
+
+  for _, y := range nav.CrossAisles {
+    if y > 0 && y < m.layout.Length {
+      m.layout.CrossAisles = append(m.layout.CrossAisles, y)
+    }
+  }
+  m.layout.Depot = routing.Point{X: nav.Depot.X, Y: nav.Depot.Y}
+  for _, d := range nav.Docks {
+    m.layout.Docks = append(m.layout.Docks, routing.Dock{Name: d.Name, At: routing.Point{X: d.X, Y: d.Y}})
+  }
+  return m, nil
+}
+
+// stopByCode finds a location by full code, ignoring case.
+func (m *navigationModel) stopByCode(code string) (routing.Stop, string, error) {
+  loc := m.byCode[strings.ToUpper(strings.TrimSpace(code))]
+  if loc == nil {
+    return routing.Stop{}, "", fmt.Errorf("location %s not found in this warehouse", code)
+  }
+  stop, ok := m.positions[loc.ID]
+  if !ok {
+    return routing.Stop{}, "", fmt.Errorf("location %s is not in an aisle", loc.FullCode)
+  }
+  return stop, loc.FullCode, nil
+}
+
+func navigationDetail(nav *types.WarehouseNavigation, saved bool, locations []*types.WarehouseLocation) *NavigationDetail {
+  detail := &NavigationDetail{Navigation: nav, Saved: saved, Aisles: []NavigationAisle{}}
+  model, err := buildNavigationModel(nav, locations)
+  if err != nil {
+    return detail
+  }
+  detail.LengthM = model.layout.Length
+  for i, a := range model.aisles {
+    detail.Aisles = append(detail.Aisles, NavigationAisle{
+      LocationID: a.ID,
+      Code:       a.FullCode,
+      X:          model.layout.Aisles[i].X,
+      LengthM:    model.lengths[i],
+      Direction:  types.AisleDirection(model.layout.Aisles[i].Direction),
+    })
+  }
+  return detail
+}
+
+// sortBySequence orders sequenced locations first by pick sequence, then
+// the rest by code.
+func sortBySequence(locations []*types.WarehouseLocation) {
+  sort.Slice(locations, func(i, j int) bool {
+    a, b := locations[i], locations[j]
+    if (a.PickSequence > 0) != (b.PickSequence > 0) {
+      return a.PickSequence > 0
+    }
+    if a.PickSequence != b.PickSequence {
+      return a.PickSequence < b.PickSequence
+    }
+    return a.FullCode < b.FullCode
+  })
+}
+
+func isRouteAnchor(name string) bool {
+  return strings.EqualFold(name, routeEndpointDepot) || strings.HasPrefix(strings.ToLower(name), routeEndpointDock)
+}
+
+func anchorNode(g *routing.Graph, name string) (routing.NodeID, error) {
+  if strings.EqualFold(name, routeEndpointDepot) {
+    return g.Depot(), nil
+  }
+  dock := strings.TrimSpace(name[len(routeEndpointDock):])
+  id, ok := g.Dock(dock)
+  if !ok {
+    return 0, fmt.Errorf("dock %s not found", dock)
+  }
+  return id, nil
+}
+
+func routeError(err error) error {
+  if errors.Is(err, routing.ErrUnreachable) {
+    return ErrRouteUnreachable
+  }
+  return err
+}
+
+func roundMetres(d float64) float64 {
+  return math.Round(d*100) / 100
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+  "bytes"
+  "context"
+  "crypto/hmac"
+  "crypto/rand"
+  "crypto/sha256"
+  "encoding/hex"
+  "errors"
+  "fmt"
+  "net"
+  "net/http"
+  "strconv"
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/events"
+  "github.com/slotter-org/slotter-backend/internal/logger"
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+var ErrWebhookEndpointNotFound = errors.New("webhook endpoint not found")
+
+// Headers sent with every webhook delivery. The signature is the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the endpoint's secret.
+const (
+  WebhookEventHeader      = "X-Slotter-Event"
+  WebhookDeliveryHeader   = "X-Slotter-Delivery"
+  WebhookTimestampHeader  = "X-Slotter-Timestamp"
+  WebhookSignatureHeader  = "X-Slotter-Signature"
+)
+
+// WebhookService manages the requester's tenant's webhook endpoints.
+type WebhookService interface {
+  List(ctx context.Context, tx *gorm.DB) ([]*types.WebhookEndpoint, error)
+  Create(ctx context.Context, tx *gorm.DB, rawURL string, eventTypes []string) (*types.WebhookEndpoint, error)
+  Delete(ctx context.Context, tx *gorm.DB, endpointID uuid.UUID) error
+}
+
+type webhookService struct {
+  db                *gorm.DB
+  log               *logger.Logger
+  webhookRepo       repos.WebhookEndpointRepo
+  resolver          webhookResolver
+}
+
+func NewWebhookService(db *gorm.DB, log *logger.Logger, webhookRepo repos.WebhookEndpointRepo) WebhookService {
+  return &webhookService{
+    db:           db,
+    log:          log.With("service", "WebhookService"),
+    webhookRepo:  webhookRepo,
+    resolver:     net.DefaultResolver,
+  }
+}
+
+func (ws *webhookService) List(ctx context.Context, tx *gorm.DB) ([]*types.WebhookEndpoint, error) {
+  ws.log.Info("Starting List now...")
+  wmsID, companyID, err := outboxTenantFromRequest(ctx)
+  if err != nil {
+    return nil, err
+  }
+  return ws.webhookRepo.GetByTenant(ctx, tx, wmsID, companyID)
+}
+
+// Create registers rawURL for eventTypes, or for every event when empty.
+// The URL must be https and resolve only to public addresses. The returned
+// endpoint carries the generated signing secret.
+func (ws *webhookService) Create(ctx context.Context, tx *gorm.DB, rawURL string, eventTypes []string) (*types.WebhookEndpoint, error) {
+  ws.log.Info("Starting Create now...")
+  wmsID, companyID, err := outboxTenantFromRequest(ctx)
+  if err != nil {
+    return nil, err
+  }
+  parsed, err := checkWebhookURL(ctx, ws.resolver, rawURL)
+  if err != nil {
+    return nil, err
+  }
+  known := make(map[string]bool)
+  for _, t := range events.Types() {
+    known[string(t)] = true
+  }
+  for _, t := range eventTypes {
+    if !known[t] {
+      return nil, fmt.Errorf("unknown event type: %s", t)
+    }
+  }
+  secret, err := newWebhookSecret()
+  if err != nil {
+    return nil, err
+  }
+  userID := requestdata.GetRequestData(ctx).UserID
+  endpoint := &types.WebhookEndpoint{
+    WmsID:        wmsID,
+    CompanyID:    companyID,
+    CreatedByID:  &userID,
+    URL:          parsed.String(),
+    Secret:       secret,
+    EventTypes:   eventTypes,
+    Active:       true,
+  }
+  if _, err := ws.webhookRepo.Create(ctx, tx, endpoint); err != nil {
+    return nil, fmt.Errorf("failed to create webhook endpoint: %w", err)
+  }
+  return endpoint, nil
+}
+
+func (ws *webhookService) Delete(ctx context.Context, tx *gorm.DB, endpointID uuid.UUID) error {
+  ws.log.Info("Starting Delete now...", "endpointID", endpointID)
+  wmsID, companyID, err := outboxTenantFromRequest(ctx)
+  if err != nil {
+    return err
+  }
+  endpoint, err := ws.webhookRepo.GetByID(ctx, tx, endpointID)
+  if err != nil {
+    return fmt.Errorf("failed to load webhook endpoint: %w", err)
+  }
+  if endpoint == nil || !webhookEndpointOwnedBy(endpoint, wmsID, companyID) {
+    return ErrWebhookEndpointNotFound
+  }
+  return ws.webhookRepo.SoftDeleteByID(ctx, tx, endpointID)
+}
+
+func webhookEndpointOwnedBy(e *types.WebhookEndpoint, wmsID, companyID *uuid.UUID) bool {
+  if wmsID != nil {
+    return e.WmsID != nil && *e.WmsID == *wmsID
+  }
+  return companyID != nil && e.CompanyID != nil && *e.CompanyID == *companyID
+}
+
+func newWebhookSecret() (string, error) {
+  buf := make([]byte, 32)
+  if _, err := rand.Read(buf); err != nil {
+    return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+  }
+  return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// webhookMessages builds one outbox message per endpoint subscribed to ev,
+// so deliveries get the outbox's retries and dead-lettering. body is the
+// encoded event every endpoint receives.
+func webhookMessages(ctx context.Context, tx *gorm.DB, webhookRepo repos.WebhookEndpointRepo, ev events.Event, body string) ([]*types.OutboxMessage, error) {
+  var endpoints []*types.WebhookEndpoint
+  if ev.WmsID != nil {
+    found, err := webhookRepo.GetActiveByTenant(ctx, tx, ev.WmsID, nil)
+    if err != nil {
+      return nil, err
+    }
+    endpoints = append(endpoints, found...)
+  }
+  if ev.CompanyID != nil {
+    found, err := webhookRepo.GetActiveByTenant(ctx, tx, nil, ev.CompanyID)
+    if err != nil {
+      return nil, err
+    }
+    endpoints = append(endpoints, found...)
+  }
+  var msgs []*types.OutboxMessage
+  for _, endpoint := range endpoints {
+    if !endpoint.Wants(string(ev.Type)) {
+      continue
+    }
+    endpointID := endpoint.ID
+    msgs = append(msgs, &types.OutboxMessage{
+      WmsID:        endpoint.WmsID,
+      CompanyID:    endpoint.CompanyID,
+      Channel:      types.OutboxChannelWebhook,
+      MessageType:  string(ev.Type),
+      SourceType:   "webhook_endpoint",
+      SourceID:     &endpointID,
+      Recipient:    endpoint.URL,
+      Body:         body,
+    })
+  }
+  return msgs, nil
+}
+
+type webhookOutboxTarget struct {
+  webhookRepo       repos.WebhookEndpointRepo
+  resolver          webhookResolver
+  client            *http.Client
+}
+
+func NewWebhookOutboxTarget(webhookRepo repos.WebhookEndpointRepo) OutboxTarget {
+  return &webhookOutboxTarget{
+    webhookRepo:  webhookRepo,
+    resolver:     net.DefaultResolver,
+    client:       newWebhookHTTPClient(),
+  }
+}
+
+// Deliver POSTs the queued event to its endpoint. Messages for endpoints
+// that were since deleted or disabled are dropped. The URL is checked again
+// here, since DNS may have changed since Create, and the client refuses to
+// connect to internal addresses whatever the lookup said.
+func (t *webhookOutboxTarget) Deliver(ctx context.Context, msg *types.OutboxMessage) error {
+  if msg.SourceID == nil {
+    return fmt.Errorf("%w: webhook message has no endpoint", ErrPermanentDelivery)
+  }
+  endpoint, err := t.webhookRepo.GetByID(ctx, nil, *msg.SourceID)
+  if err != nil {
+    return fmt.Errorf("failed to load webhook endpoint: %w", err)
+  }
+  if endpoint == nil || !endpoint.Active {
+    return nil
+  }
+  if _, err := checkWebhookURL(ctx, t.resolver, endpoint.URL); err != nil {
+    if errors.Is(err, ErrWebhookURLNotAllowed) {
+      return fmt.Errorf("%w: %w", ErrPermanentDelivery, err)
+    }
+    return err
+  }
+  timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+  mac := hmac.New(sha256.New, []byte(endpoint.Secret))
+  mac.Write([]byte(timestamp + "." + msg.Body))
+
+  req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewBufferString(msg.Body))
+  if err != nil {
+    return fmt.Errorf("%w: %v", ErrPermanentDelivery, err)
+  }
+  req.Header.Set("Content-Type", "application/json")
+  req.Header.Set(WebhookEventHeader, msg.MessageType)
+  req.Header.Set(WebhookDeliveryHeader, msg.ID.String())
+  req.Header.Set(WebhookTimestampHeader, timestamp)
+  req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+  resp, err := t.client.Do(req)
+  if err != nil {
+    if errors.Is(err, ErrWebhookURLNotAllowed) {
+      return fmt.Errorf("%w: %w", ErrPermanentDelivery, err)
+    }
+    return fmt.Errorf("webhook request failed: %w", err)
+  }
+  defer resp.Body.Close()
+  switch {
+  case resp.StatusCode >= 200 && resp.StatusCode < 300:
+    return nil
+  case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+    return fmt.Errorf("webhook endpoint answered %d", resp.StatusCode)
+  default:
+    return fmt.Errorf("%w: webhook endpoint answered %d", ErrPermanentDelivery, resp.StatusCode)
+  }
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+  "context"
+  "errors"
+  "net"
+  "net/http"
+  "net/http/httptest"
+  "net/netip"
+  "sync/atomic"
+  "testing"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+
+  "github.com/slotter-org/slotter-backend/internal/repos"
+  "github.com/slotter-org/slotter-backend/internal/requestdata"
+  "github.com/slotter-org/slotter-backend/internal/types"
+)
+
+type fakeResolver map[string][]netip.Addr
+
+func (f fakeResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+  addrs, ok := f[host]
+  if !ok {
+    return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+  }
+  return addrs, nil
+}
+
+type fakeWebhookEndpointRepo struct {
+  repos.WebhookEndpointRepo
+  endpoints     map[uuid.UUID]*types.WebhookEndpoint
+}
+
+func (f *fakeWebhookEndpointRepo) Create(ctx context.Context, tx *gorm.DB, endpoint *types.WebhookEndpoint) (*types.WebhookEndpoint, error) {
+  endpoint.ID = uuid.New()
+  f.endpoints[endpoint.ID] = endpoint
+  return endpoint, nil
+}
+
+func (f *fakeWebhookEndpointRepo) GetByID(ctx context.Context, tx *gorm.DB, endpointID uuid.UUID) (*types.WebhookEndpoint, error) {
+  return f.endpoints[endpointID], nil
+}
+
+func TestWebhookAddrAllowed(t *testing.T) {
+  tests := []struct {
+    addr          string
+    want          bool
+  }{
+    {addr: "93.184.216.34", want: true},
+    {addr: "2606:4700:4700::1111", want: true},
+    {addr: "127.0.0.1"},
+    {addr: "127.8.9.10"},
+    {addr: "::1"},
+    {addr: "10.1.2.3"},
+    {addr: "172.16.0.1"},
+    {addr: "192.168.1.1"},
+    {addr: "fd00:ec2::254"},
+    {addr: "169.254.169.254"},
+    {addr: "fe80::1"},
+    {addr: "100.100.100.200"},
+    {addr: "0.0.0.0"},
+    {addr: "::"},
+    {addr: "255.255.255.255"},
+    {addr: "224.0.0.1"},
+    {addr: "::ffff:127.0.0.1"},
+    {addr: "::ffff:10.0.0.1"},
+    {addr: "64:ff9b::a9fe:a9fe"},
+  }
+  for _, tt := range tests {
+    t.Run(tt.addr, func(t *testing.T) {
+      if got := webhookAddrAllowed(netip.MustParseAddr(tt.addr)); got != tt.want {
+        t.Fatalf("webhookAddrAllowed(%s) = %v, want %v", tt.addr, got, tt.want)
+      }
+    })
+  }
+}
+
+func TestWebhookServiceCreateRejectsInternalURLs(t *testing.T) {
+  resolver := fakeResolver{
+    "hooks.example.com":  {netip.MustParseAddr("93.184.216.34")},
+    "localhost":          {netip.MustParseAddr("127.0.0.1")},
+    "metadata.internal":  {netip.MustParseAddr("169.254.169.254")},
+    "split.example.com":  {netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("10.0.0.5")},
+  }
+  repo := &fakeWebhookEndpointRepo{endpoints: map[uuid.UUID]*types.WebhookEndpoint{}}
+  ws := &webhookService{log: testLogger(), webhookRepo: repo, resolver: resolver}
+  ctx := requestdata.WithRequestData(context.Background(), &requestdata.RequestData{UserType: "company", UserID: uuid.New(), CompanyID: uuid.New()})
+
+  tests := []struct {
+    name            string
+    url             string
+    wantErr         bool
+    wantNotAllowed  bool
+  }{
+    {name: "public https host", url: "https://hooks.example.com/slotter"},
+    {name: "public https ip", url: "https://93.184.216.34:8443/slotter"},
+    {name: "plain http", url: "http://hooks.example.com/slotter", wantErr: true, wantNotAllowed: true},
+    {name: "other scheme", url: "ftp://hooks.example.com/slotter", wantErr: true, wantNotAllowed: true},
+    {name: "no host", url: "https:///slotter", wantErr: true, wantNotAllowed: true},
+    {name: "loopback ip", url: "https://127.0.0.1/slotter", wantErr: true, wantNotAllowed: true},
+    {name: "ipv6 loopback", url: "https://[::1]/slotter", wantErr: true, wantNotAllowed: true},
+    {name: "private ip", url: "https://192.168.0.10/slotter", wantErr: true, wantNotAllowed: true},
+    {name: "metadata ip", url: "https://169.254.169.254/latest/meta-data", wantErr: true, wantNotAllowed: true},
+    {name: "host resolving to loopback", url: "https://localhost/slotter", wantErr: true, wantNotAllowed: true},
+    {name: "host resolving to metadata", url: "https://metadata.internal/", wantErr: true, wantNotAllowed: true},
+    {name: "host resolving to public and private", url: "https://split.example.com/", wantErr: true, wantNotAllowed: true},
+    {name: "unresolvable host", url: "https://nowhere.example.com/", wantErr: true},
+  }
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      _, err := ws.Create(ctx, nil, tt.url, nil)
+      if (err != nil) != tt.wantErr {
+        t.Fatalf("Create(%s): err = %v, wantErr %v", tt.url, err, tt.wantErr)
+      }
+      if errors.Is(err, ErrWebhookURLNotAllowed) != tt.wantNotAllowed {
+        t.Fatalf("Create(%s): err = %v, want ErrWebhookURLNotAllowed %v", tt.url, err, tt.wantNotAllowed)
+      }
+    })
+  }
+}
+
+// TestWebhookDeliveryRefusesInternalAddresses checks both delivery guards:
+// the URL check before sending, and the dialer refusing an internal address
+// a public host resolves to by the time it is dialled.
+func TestWebhookDeliveryRefusesInternalAddresses(t *testing.T) {
+  var hits atomic.Int32
+  srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    hits.Add(1)
+  }))
+  defer srv.Close()
+
+  stored := &types.WebhookEndpoint{ID: uuid.New(), URL: srv.URL + "/hook", Secret: "s", Active: true}
+  rebinding := &types.WebhookEndpoint{ID: uuid.New(), URL: "https://rebind.example.com/hook", Secret: "s", Active: true}
+  legacyHTTP := &types.WebhookEndpoint{ID: uuid.New(), URL: "http://hooks.example.com/hook", Secret: "s", Active: true}
+  repo := &fakeWebhookEndpointRepo{endpoints: map[uuid.UUID]*types.WebhookEndpoint{
+    stored.ID:     stored,
+    rebinding.ID:  rebinding,
+    legacyHTTP.ID: legacyHTTP,
+  }}
+
+  // The client dials the test server whatever host it was asked for, as if
+  // DNS now answered with a loopback address.
+  client := newWebhookHTTPClient()
+  transport := client.Transport.(*http.Transport)
+  dial := transport.DialContext
+  transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+    return dial(ctx, network, srv.Listener.Addr().String())
+  }
+  target := &webhookOutboxTarget{
+    webhookRepo: repo,
+    resolver:    fakeResolver{"rebind.example.com": {netip.MustParseAddr("93.184.216.34")}},
+    client:      client,
+  }
+
+  for _, endpoint := range []*types.WebhookEndpoint{stored, rebinding, legacyHTTP} {
+    t.Run(endpoint.URL, func(t *testing.T) {
+      msg := &types.OutboxMessage{ID: uuid.New(), SourceID: &endpoint.ID, MessageType: "ItemCreated", Body: `{}`}
+      err := target.Deliver(context.Background(), msg)
+      if !errors.Is(err, ErrPermanentDelivery) || !errors.Is(err, ErrWebhookURLNotAllowed) {
+        t.Fatalf("Deliver: err = %v, want a permanent ErrWebhookURLNotAllowed", err)
+      }
+    })
+  }
+  if n := hits.Load(); n != 0 {
+    t.Fatalf("internal server received %d webhook requests", n)
+  }
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "net"
+  "net/http"
+  "net/netip"
+  "net/url"
+  "strings"
+  "syscall"
+  "time"
+)
+
+// ErrWebhookURLNotAllowed is returned for webhook URLs that are not https or
+// whose host is, or resolves to, an address inside a private network.
+var ErrWebhookURLNotAllowed = errors.New("webhook url not allowed")
+
+// blockedWebhookPrefixes are the special-purpose ranges the net/netip
+// predicates in webhookAddrAllowed do not cover.
+var blockedWebhookPrefixes = []netip.Prefix{
+  netip.MustParsePrefix("0.0.0.0/8"),       // this network
+  netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT, some cloud metadata
+  netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+  netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+  netip.MustParsePrefix("240.0.0.0/4"),     // reserved and broadcast
+  netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, which embeds IPv4
+  netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+  netip.MustParsePrefix("100::/64"),        // discard
+  netip.MustParsePrefix("2002::/16"),       // 6to4, which embeds IPv4
+}
+
+// webhookAddrAllowed reports whether webhooks may be delivered to addr:
+// public unicast only, so no loopback, private, link-local (which holds the
+// cloud metadata address), multicast or unspecified address.
+func webhookAddrAllowed(addr netip.Addr) bool {
+  addr = addr.Unmap()
+  if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+    addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+    return false
+  }
+  for _, p := range blockedWebhookPrefixes {
+    if p.Contains(addr) {
+      return false
+    }
+  }
+  return true
+}
+
+// webhookResolver looks up webhook hosts; net.DefaultResolver in production.
+type webhookResolver interface {
+  LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// checkWebhookURL parses rawURL and requires https and a host whose every
+// address is allowed. A host that does not resolve is an error too, but
+// not ErrWebhookURLNotAllowed, as DNS may just be down.
+func checkWebhookURL(ctx context.Context, resolver webhookResolver, rawURL string) (*url.URL, error) {
+  parsed, err := url.Parse(strings.TrimSpace(rawURL))
+  if err != nil || parsed.Scheme != "https" || parsed.Hostname() == "" {
+    return nil, fmt.Errorf("%w: url must be an absolute https URL", ErrWebhookURLNotAllowed)
+  }
+  host := parsed.Hostname()
+  if addr, err := netip.ParseAddr(host); err == nil {
+    if !webhookAddrAllowed(addr) {
+      return nil, fmt.Errorf("%w: %s is not a public address", ErrWebhookURLNotAllowed, host)
+    }
+    return parsed, nil
+  }
+  addrs, err := resolver.LookupNetIP(ctx, "ip", host)
+  if err != nil {
+    return nil, fmt.Errorf("failed to resolve webhook host %s: %w", host, err)
+  }
+  if len(addrs) == 0 {
+    return nil, fmt.Errorf("webhook host %s has no addresses", host)
+  }
+  for _, addr := range addrs {
+    if !webhookAddrAllowed(addr) {
+      return nil, fmt.Errorf("%w: %s resolves to %s, which is not a public address", ErrWebhookURLNotAllowed, host, addr)
+    }
+  }
+  return parsed, nil
+}
+
+// webhookDialControl refuses connections to addresses webhooks may not
+// reach. It runs on the address actually dialled, so a host that resolved
+// to a public address when checked and to an internal one when dialled is
+// still refused.
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+  addrPort, err := netip.ParseAddrPort(address)
+  if err != nil {
+    return fmt.Errorf("%w: cannot parse dialled address %s", ErrWebhookURLNotAllowed, address)
+  }
+  if !webhookAddrAllowed(addrPort.Addr()) {
+    return fmt.Errorf("%w: %s is not a public address", ErrWebhookURLNotAllowed, addrPort.Addr())
+  }
+  return nil
+}
+
+// newWebhookHTTPClient returns the client webhooks are delivered with. It
+// ignores proxy settings, which would dial in our place, and does not
+// follow redirects, which could point anywhere.
+func newWebhookHTTPClient() *http.Client {
+  dialer := &net.Dialer{Timeout: 5 * time.Second, Control: webhookDialControl}
+  transport := http.DefaultTransport.(*http.Transport).Clone()
+  transport.Proxy = nil
+  transport.DialContext = dialer.DialContext
+  return &http.Client{
+    Timeout:   10 * time.Second,
+    Transport: transport,
+    CheckRedirect: func(*http.Request, []*http.Request) error {
+      return http.ErrUseLastResponse
+    },
+  }
+}
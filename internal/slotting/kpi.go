@@ -0,0 +1,98 @@
+package slotting
+
+import (
+	"math"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// forwardShare is the share of slots, nearest first, that PickDensity
+// counts as the forward pick area.
+const forwardShare = 0.2
+
+// KPIs describe how well an assignment serves picking.
+//
+// TravelDistance is the daily out-and-back travel to every slotted SKU,
+// picks per day times twice the slot's Distance, so it is in the unit of
+// Distance. PickDensity is the share of daily picks served from the nearest
+// fifth of the slots. CubeUtilization is the stock cube over the cube of the
+// occupied slots that have dimensions. MovesRequired counts the SKUs whose
+// slot differs from their current one.
+type KPIs struct {
+	TravelDistance  float64
+	PickDensity     float64
+	CubeUtilization float64
+	MovesRequired   int
+	SlottedSKUs     int
+}
+
+// Measure computes the KPIs of assignment, item ID to slot ID, over p.
+// Items or slots that are not part of p are ignored.
+func Measure(p Problem, assignment map[uuid.UUID]uuid.UUID) KPIs {
+	slots := make(map[uuid.UUID]Slot, len(p.Slots))
+	distances := make([]float64, 0, len(p.Slots))
+	for _, sl := range p.Slots {
+		slots[sl.ID] = sl
+		distances = append(distances, sl.Distance)
+	}
+	sort.Float64s(distances)
+	forward := math.Inf(-1)
+	if len(distances) > 0 {
+		n := int(math.Ceil(float64(len(distances)) * forwardShare))
+		forward = distances[n-1]
+	}
+
+	var k KPIs
+	var picks, forwardPicks, stockCube, slotCube float64
+	for _, sku := range p.SKUs {
+		slotID, ok := assignment[sku.ItemID]
+		if !ok {
+			continue
+		}
+		sl, ok := slots[slotID]
+		if !ok {
+			continue
+		}
+		k.SlottedSKUs++
+		if sku.Current != nil && *sku.Current != slotID {
+			k.MovesRequired++
+		}
+		k.TravelDistance += sku.PicksPerDay * 2 * sl.Distance
+		picks += sku.PicksPerDay
+		if sl.Distance <= forward {
+			forwardPicks += sku.PicksPerDay
+		}
+		if cube := sl.WidthCm * sl.DepthCm * sl.HeightCm; cube > 0 {
+			slotCube += cube
+			stockCube += float64(sku.Quantity) * sku.EachLengthCm * sku.EachWidthCm * sku.EachHeightCm
+		}
+	}
+	if picks > 0 {
+		k.PickDensity = forwardPicks / picks
+	}
+	if slotCube > 0 {
+		k.CubeUtilization = stockCube / slotCube
+	}
+	return k
+}
+
+// CurrentAssignment is the assignment p starts from.
+func CurrentAssignment(p Problem) map[uuid.UUID]uuid.UUID {
+	out := make(map[uuid.UUID]uuid.UUID, len(p.SKUs))
+	for _, sku := range p.SKUs {
+		if sku.Current != nil {
+			out[sku.ItemID] = *sku.Current
+		}
+	}
+	return out
+}
+
+// Assignment is the assignment r ends with.
+func (r *Result) Assignment() map[uuid.UUID]uuid.UUID {
+	out := make(map[uuid.UUID]uuid.UUID, len(r.Placements))
+	for _, pl := range r.Placements {
+		out[pl.ItemID] = pl.SlotID
+	}
+	return out
+}
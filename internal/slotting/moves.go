@@ -0,0 +1,62 @@
+package slotting
+
+import (
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// Move relocates a SKU's stock from one slot to another. Sequence is the
+// 1-based execution order. ViaStaging marks the move that breaks a cycle of
+// SKUs swapping slots: its stock is taken out first and put away last,
+// once the target slot has been emptied.
+type Move struct {
+	Sequence   int
+	ItemID     uuid.UUID
+	FromSlotID uuid.UUID
+	ToSlotID   uuid.UUID
+	ViaStaging bool
+}
+
+// PlanMoves orders the moved placements so that no move targets a slot
+// before the SKU in it has left. Ties go by item ID, so the order is stable.
+func PlanMoves(placements []Placement) []Move {
+	var pending []Move
+	for _, pl := range placements {
+		if pl.Moved && pl.FromSlotID != nil {
+			pending = append(pending, Move{ItemID: pl.ItemID, FromSlotID: *pl.FromSlotID, ToSlotID: pl.SlotID})
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].ItemID.String() < pending[j].ItemID.String() })
+
+	var out []Move
+	emit := func(idx int, staging bool) {
+		m := pending[idx]
+		m.ViaStaging = staging
+		m.Sequence = len(out) + 1
+		out = append(out, m)
+		pending = append(pending[:idx], pending[idx+1:]...)
+	}
+	for len(pending) > 0 {
+		leaving := make(map[uuid.UUID]bool, len(pending))
+		for _, m := range pending {
+			leaving[m.FromSlotID] = true
+		}
+		progressed := false
+		for idx := 0; idx < len(pending); {
+			if leaving[pending[idx].ToSlotID] {
+				idx++
+				continue
+			}
+			delete(leaving, pending[idx].FromSlotID)
+			emit(idx, false)
+			progressed = true
+		}
+		if !progressed {
+			// Every remaining move waits on another: a cycle. Stage the
+			// first one to free its slot and let the others follow.
+			emit(0, true)
+		}
+	}
+	return out
+}
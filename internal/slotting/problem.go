@@ -0,0 +1,130 @@
+package slotting
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Defaults for a Params field left at zero.
+const (
+	DefaultIterations       = 20000
+	DefaultGoldenLevelMin   = 1
+	DefaultGoldenLevelMax   = 2
+	DefaultHeavyThresholdKg = 15
+	DefaultFillFactor       = 0.85
+	DefaultMovePenalty      = 1
+	DefaultGoldenPenalty    = 25
+)
+
+// MaxIterations bounds the local search of a single solve.
+const MaxIterations = 1000000
+
+// Slot is a location that can hold one SKU. Level is the 0-based rack level
+// (floor is 0) or -1 when the slot has no level. Distance is the travel
+// proxy from the pick path start; only its order matters to the solver.
+type Slot struct {
+	ID          uuid.UUID
+	Code        string
+	Zone        string
+	Bay         string
+	Level       int
+	Distance    float64
+	WidthCm     float64
+	DepthCm     float64
+	HeightCm    float64
+	MaxWeightKg float64
+}
+
+// SKU is an item to slot. Quantity is the number of eaches that has to fit
+// the slot; Current is the slot it occupies today, if any.
+type SKU struct {
+	ItemID       uuid.UUID
+	SKU          string
+	PicksPerDay  float64
+	ABCClass     string
+	Quantity     int
+	EachLengthCm float64
+	EachWidthCm  float64
+	EachHeightCm float64
+	EachWeightKg float64
+	HazmatClass  string
+	Current      *uuid.UUID
+}
+
+// Params are the constraints and weights of a solve.
+//
+// A-items outside levels GoldenLevelMin..GoldenLevelMax cost GoldenPenalty
+// per pick; items of HeavyThresholdKg or more per each may not sit above
+// HeavyMaxLevel. With HazmatZones set, hazmat items may only go to those
+// zones and other items may not; either way two different hazmat classes
+// never share a bay. FillFactor is the usable share of a slot's cube.
+// MovePenalty is charged per SKU that leaves its current slot, so a higher
+// value trades travel for fewer moves; MaxMoves (0 = unlimited) caps them.
+type Params struct {
+	Seed             int64
+	Iterations       int
+	GoldenLevelMin   int
+	GoldenLevelMax   int
+	GoldenPenalty    float64
+	HeavyThresholdKg float64
+	HeavyMaxLevel    int
+	HazmatZones      []string
+	FillFactor       float64
+	MovePenalty      float64
+	MaxMoves         int
+}
+
+// Problem is everything a solve needs. It is self-contained, so a problem
+// built from a live warehouse and one from Synthetic are solved alike.
+type Problem struct {
+	Slots  []Slot
+	SKUs   []SKU
+	Params Params
+}
+
+// WithDefaults returns p with every zero field set to its default.
+func (p Params) WithDefaults() Params {
+	if p.Iterations <= 0 {
+		p.Iterations = DefaultIterations
+	}
+	if p.Iterations > MaxIterations {
+		p.Iterations = MaxIterations
+	}
+	if p.GoldenLevelMin <= 0 && p.GoldenLevelMax <= 0 {
+		p.GoldenLevelMin = DefaultGoldenLevelMin
+		p.GoldenLevelMax = DefaultGoldenLevelMax
+	}
+	if p.GoldenPenalty <= 0 {
+		p.GoldenPenalty = DefaultGoldenPenalty
+	}
+	if p.HeavyThresholdKg <= 0 {
+		p.HeavyThresholdKg = DefaultHeavyThresholdKg
+	}
+	if p.FillFactor <= 0 || p.FillFactor > 1 {
+		p.FillFactor = DefaultFillFactor
+	}
+	if p.MovePenalty <= 0 {
+		p.MovePenalty = DefaultMovePenalty
+	}
+	return p
+}
+
+// HazmatGroup is the class a hazmat code segregates on: "2.1" and "2.3" are
+// both class 2. Non-hazmat items return "".
+func HazmatGroup(code string) string {
+	code = strings.TrimSpace(code)
+	if i := strings.IndexByte(code, '.'); i >= 0 {
+		return code[:i]
+	}
+	return code
+}
+
+// sortedDims returns a, b, c largest first, so an item fits a slot in any
+// orientation when each of its sorted dimensions fits the slot's.
+func sortedDims(a, b, c float64) [3]float64 {
+	d := []float64{a, b, c}
+	sort.Sort(sort.Reverse(sort.Float64Slice(d)))
+	return [3]float64{d[0], d[1], d[2]}
+}
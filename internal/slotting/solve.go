@@ -0,0 +1,413 @@
+package slotting
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+const (
+	epsilon         = 1e-9
+	cancelCheckStep = 256
+)
+
+// Placement is where a SKU ends up. FromSlotID is its current slot, if any;
+// Moved reports whether the two differ.
+type Placement struct {
+	ItemID     uuid.UUID
+	SlotID     uuid.UUID
+	FromSlotID *uuid.UUID
+	Moved      bool
+}
+
+// Unplaced is a SKU no slot could take, with the reason.
+type Unplaced struct {
+	ItemID uuid.UUID
+	Reason string
+}
+
+// Result is the outcome of Solve. Costs are picks per day times distance
+// plus the golden zone penalty; CostBefore only covers SKUs that have a
+// current slot, CostAfter every placed SKU.
+type Result struct {
+	Placements   []Placement
+	Unplaced     []Unplaced
+	CostBefore   float64
+	CostAfter    float64
+	Moves        int
+	Iterations   int
+	Improvements int
+}
+
+// ProgressFunc receives the share of the solve that is done, 0 to 1.
+type ProgressFunc func(fraction float64, message string)
+
+type solver struct {
+	p         Params
+	slots     []Slot
+	skus      []SKU
+	groups    []string
+	hazZones  map[string]bool
+	current   []int
+	assign    []int
+	occupant  []int
+	bayHazmat map[string]map[string]int
+	moves     int
+}
+
+// Solve assigns every SKU to at most one slot. It starts from the current
+// assignment wherever that is still allowed, places the rest greedily by
+// pick frequency, then improves with relocations and a local search of
+// swaps driven by Params.Seed. Equal problems and seeds give equal results.
+// The solve stops with ctx.Err() when ctx is canceled.
+func Solve(ctx context.Context, problem Problem, progress ProgressFunc) (*Result, error) {
+	if progress == nil {
+		progress = func(float64, string) {}
+	}
+	s := newSolver(problem)
+	progress(0, "placing current assignment")
+
+	result := &Result{}
+	for i := range s.skus {
+		if c := s.current[i]; c >= 0 {
+			result.CostBefore += s.cost(i, c)
+			if s.occupant[c] < 0 && s.fits(i, c) && s.segregated(i, c) {
+				s.place(i, c)
+			}
+		}
+	}
+	progress(0.05, "placing remaining SKUs")
+	for i := range s.skus {
+		if s.assign[i] >= 0 {
+			continue
+		}
+		if best := s.bestFreeSlot(i, true); best >= 0 {
+			s.place(i, best)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	progress(0.1, "relocating SKUs")
+	for i := range s.skus {
+		from := s.assign[i]
+		if from < 0 {
+			continue
+		}
+		best := s.bestFreeSlot(i, false)
+		if best < 0 || !s.withinMoveCap(s.moves-s.moved(i, from)+s.moved(i, best)) {
+			continue
+		}
+		if s.total(i, best) < s.total(i, from)-epsilon {
+			s.unplace(i)
+			s.place(i, best)
+			result.Improvements++
+		}
+	}
+
+	rng := rand.New(rand.NewSource(problem.Params.Seed))
+	iterations := s.p.Iterations
+	report := iterations / 50
+	if report < 1 {
+		report = 1
+	}
+	var placed []int
+	for i := range s.skus {
+		if s.assign[i] >= 0 {
+			placed = append(placed, i)
+		}
+	}
+	for it := 0; it < iterations && len(placed) > 0; it++ {
+		if it%cancelCheckStep == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		if it%report == 0 {
+			progress(0.1+0.85*float64(it)/float64(iterations), fmt.Sprintf("local search %d/%d", it, iterations))
+		}
+		i := placed[rng.Intn(len(placed))]
+		if rng.Intn(3) == 0 {
+			if s.tryRelocate(i, rng.Intn(len(s.slots))) {
+				result.Improvements++
+			}
+			continue
+		}
+		if s.trySwap(i, placed[rng.Intn(len(placed))]) {
+			result.Improvements++
+		}
+	}
+	result.Iterations = iterations
+
+	for i, sku := range s.skus {
+		a := s.assign[i]
+		if a < 0 {
+			result.Unplaced = append(result.Unplaced, Unplaced{ItemID: sku.ItemID, Reason: s.unplacedReason(i)})
+			continue
+		}
+		pl := Placement{ItemID: sku.ItemID, SlotID: s.slots[a].ID}
+		if c := s.current[i]; c >= 0 {
+			from := s.slots[c].ID
+			pl.FromSlotID = &from
+			pl.Moved = c != a
+		}
+		result.Placements = append(result.Placements, pl)
+		result.CostAfter += s.cost(i, a)
+	}
+	result.Moves = s.moves
+	progress(1, "done")
+	return result, nil
+}
+
+func newSolver(problem Problem) *solver {
+	s := &solver{
+		p:         problem.Params.WithDefaults(),
+		slots:     append([]Slot{}, problem.Slots...),
+		skus:      append([]SKU{}, problem.SKUs...),
+		hazZones:  map[string]bool{},
+		bayHazmat: map[string]map[string]int{},
+	}
+	for _, z := range s.p.HazmatZones {
+		s.hazZones[z] = true
+	}
+	sort.SliceStable(s.slots, func(i, j int) bool {
+		if s.slots[i].Distance != s.slots[j].Distance {
+			return s.slots[i].Distance < s.slots[j].Distance
+		}
+		return s.slots[i].ID.String() < s.slots[j].ID.String()
+	})
+	sort.SliceStable(s.skus, func(i, j int) bool {
+		if s.skus[i].PicksPerDay != s.skus[j].PicksPerDay {
+			return s.skus[i].PicksPerDay > s.skus[j].PicksPerDay
+		}
+		return s.skus[i].ItemID.String() < s.skus[j].ItemID.String()
+	})
+	index := make(map[uuid.UUID]int, len(s.slots))
+	for i, sl := range s.slots {
+		index[sl.ID] = i
+	}
+	s.occupant = make([]int, len(s.slots))
+	for i := range s.occupant {
+		s.occupant[i] = -1
+	}
+	s.groups = make([]string, len(s.skus))
+	s.current = make([]int, len(s.skus))
+	s.assign = make([]int, len(s.skus))
+	for i, sku := range s.skus {
+		s.groups[i] = HazmatGroup(sku.HazmatClass)
+		s.current[i] = -1
+		s.assign[i] = -1
+		if sku.Current != nil {
+			if c, ok := index[*sku.Current]; ok {
+				s.current[i] = c
+			}
+		}
+	}
+	return s
+}
+
+// cost is the travel and golden zone cost of SKU i in slot sl.
+func (s *solver) cost(i, sl int) float64 {
+	sku, slot := s.skus[i], s.slots[sl]
+	c := sku.PicksPerDay * slot.Distance
+	if sku.ABCClass == "A" && (slot.Level < s.p.GoldenLevelMin || slot.Level > s.p.GoldenLevelMax) {
+		c += s.p.GoldenPenalty * sku.PicksPerDay
+	}
+	return c
+}
+
+func (s *solver) moved(i, sl int) int {
+	if sl >= 0 && s.current[i] >= 0 && s.current[i] != sl {
+		return 1
+	}
+	return 0
+}
+
+// total is cost plus the move penalty when sl is not SKU i's current slot.
+func (s *solver) total(i, sl int) float64 {
+	return s.cost(i, sl) + float64(s.moved(i, sl))*s.p.MovePenalty
+}
+
+func (s *solver) withinMoveCap(moves int) bool {
+	return s.p.MaxMoves <= 0 || moves <= s.p.MaxMoves || moves <= s.moves
+}
+
+// fits checks the static rules: size, weight, heavy items low and hazmat
+// zones. Size and weight are not checked for the SKU's current slot, which
+// demonstrably holds it.
+func (s *solver) fits(i, sl int) bool {
+	sku, slot := s.skus[i], s.slots[sl]
+	if len(s.hazZones) > 0 && (s.groups[i] != "") != s.hazZones[slot.Zone] {
+		return false
+	}
+	if sku.EachWeightKg >= s.p.HeavyThresholdKg && slot.Level > s.p.HeavyMaxLevel {
+		return false
+	}
+	if sl == s.current[i] {
+		return true
+	}
+	qty := float64(sku.Quantity)
+	if qty < 1 {
+		qty = 1
+	}
+	if slot.MaxWeightKg > 0 && qty*sku.EachWeightKg > slot.MaxWeightKg {
+		return false
+	}
+	if slot.WidthCm > 0 && slot.DepthCm > 0 && slot.HeightCm > 0 {
+		item := sortedDims(sku.EachLengthCm, sku.EachWidthCm, sku.EachHeightCm)
+		room := sortedDims(slot.WidthCm, slot.DepthCm, slot.HeightCm)
+		for d := 0; d < 3; d++ {
+			if item[d] > room[d] {
+				return false
+			}
+		}
+		if qty*item[0]*item[1]*item[2] > room[0]*room[1]*room[2]*s.p.FillFactor {
+			return false
+		}
+	}
+	return true
+}
+
+// segregated reports whether SKU i may join slot sl's bay without sharing
+// it with another hazmat class.
+func (s *solver) segregated(i, sl int) bool {
+	g := s.groups[i]
+	if g == "" {
+		return true
+	}
+	for other, n := range s.bayHazmat[s.slots[sl].Bay] {
+		if n > 0 && other != g {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *solver) place(i, sl int) {
+	s.assign[i] = sl
+	s.occupant[sl] = i
+	s.moves += s.moved(i, sl)
+	if g := s.groups[i]; g != "" {
+		bay := s.slots[sl].Bay
+		if s.bayHazmat[bay] == nil {
+			s.bayHazmat[bay] = map[string]int{}
+		}
+		s.bayHazmat[bay][g]++
+	}
+}
+
+func (s *solver) unplace(i int) {
+	sl := s.assign[i]
+	if sl < 0 {
+		return
+	}
+	s.moves -= s.moved(i, sl)
+	if g := s.groups[i]; g != "" {
+		s.bayHazmat[s.slots[sl].Bay][g]--
+	}
+	s.occupant[sl] = -1
+	s.assign[i] = -1
+}
+
+// bestFreeSlot returns the free slot with the lowest total cost for SKU i,
+// or -1. Slots are sorted by distance and the golden penalty is never
+// negative, so the scan stops once distance alone exceeds the best total
+// (at once for a SKU that is never picked, where every slot costs the same).
+// With forced set the move cap is ignored: the SKU has no slot at all yet.
+func (s *solver) bestFreeSlot(i int, forced bool) int {
+	best, bestTotal := -1, 0.0
+	base := s.moves
+	if s.assign[i] >= 0 {
+		base -= s.moved(i, s.assign[i])
+	}
+	for sl := range s.slots {
+		if best >= 0 && (s.skus[i].PicksPerDay == 0 || s.skus[i].PicksPerDay*s.slots[sl].Distance > bestTotal) {
+			break
+		}
+		if s.occupant[sl] >= 0 || !s.fits(i, sl) || !s.segregated(i, sl) {
+			continue
+		}
+		if !forced && !s.withinMoveCap(base+s.moved(i, sl)) {
+			continue
+		}
+		if t := s.total(i, sl); best < 0 || t < bestTotal-epsilon {
+			best, bestTotal = sl, t
+		}
+	}
+	return best
+}
+
+func (s *solver) tryRelocate(i, sl int) bool {
+	from := s.assign[i]
+	if s.occupant[sl] >= 0 || sl == from || !s.fits(i, sl) {
+		return false
+	}
+	if s.total(i, sl) >= s.total(i, from)-epsilon {
+		return false
+	}
+	if !s.withinMoveCap(s.moves - s.moved(i, from) + s.moved(i, sl)) {
+		return false
+	}
+	s.unplace(i)
+	if !s.segregated(i, sl) {
+		s.place(i, from)
+		return false
+	}
+	s.place(i, sl)
+	return true
+}
+
+func (s *solver) trySwap(i, j int) bool {
+	if i == j {
+		return false
+	}
+	si, sj := s.assign[i], s.assign[j]
+	if !s.fits(i, sj) || !s.fits(j, si) {
+		return false
+	}
+	before := s.total(i, si) + s.total(j, sj)
+	after := s.total(i, sj) + s.total(j, si)
+	if after >= before-epsilon {
+		return false
+	}
+	moves := s.moves - s.moved(i, si) - s.moved(j, sj) + s.moved(i, sj) + s.moved(j, si)
+	if !s.withinMoveCap(moves) {
+		return false
+	}
+	s.unplace(i)
+	s.unplace(j)
+	if s.segregated(i, sj) {
+		s.place(i, sj)
+		if s.segregated(j, si) {
+			s.place(j, si)
+			return true
+		}
+		s.unplace(i)
+	}
+	s.place(i, si)
+	s.place(j, sj)
+	return false
+}
+
+func (s *solver) unplacedReason(i int) string {
+	fitting := 0
+	for sl := range s.slots {
+		if s.fits(i, sl) {
+			fitting++
+			if s.occupant[sl] < 0 {
+				return "hazmat segregation leaves no free slot"
+			}
+		}
+	}
+	if fitting == 0 {
+		return "no slot fits its size, weight, level or zone rules"
+	}
+	if s.p.MaxMoves > 0 && s.current[i] >= 0 {
+		return "no free slot within the move limit"
+	}
+	return "every slot that fits is taken"
+}
@@ -0,0 +1,290 @@
+package slotting
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func solveSynthetic(t *testing.T, spec SyntheticSpec, params func(*Params)) (Problem, *Result) {
+	t.Helper()
+	p := Synthetic(spec)
+	p.Params.Iterations = 3000
+	if params != nil {
+		params(&p.Params)
+	}
+	res, err := Solve(context.Background(), p, nil)
+	if err != nil {
+		t.Fatalf("Solve: %v", err)
+	}
+	return p, res
+}
+
+// checkHardConstraints fails t for any placement in res that breaks a rule
+// Solve must never break, whatever the seed.
+func checkHardConstraints(t *testing.T, p Problem, res *Result) {
+	t.Helper()
+	params := p.Params.WithDefaults()
+	slots := make(map[uuid.UUID]Slot, len(p.Slots))
+	for _, sl := range p.Slots {
+		slots[sl.ID] = sl
+	}
+	skus := make(map[uuid.UUID]SKU, len(p.SKUs))
+	for _, sku := range p.SKUs {
+		skus[sku.ItemID] = sku
+	}
+	hazZones := map[string]bool{}
+	for _, z := range params.HazmatZones {
+		hazZones[z] = true
+	}
+	if len(res.Placements)+len(res.Unplaced) != len(p.SKUs) {
+		t.Fatalf("%d placed + %d unplaced, want %d SKUs", len(res.Placements), len(res.Unplaced), len(p.SKUs))
+	}
+	occupied := map[uuid.UUID]uuid.UUID{}
+	bayGroups := map[string]map[string]bool{}
+	for _, pl := range res.Placements {
+		sku, slot := skus[pl.ItemID], slots[pl.SlotID]
+		if other, ok := occupied[pl.SlotID]; ok {
+			t.Errorf("slot %s holds both %s and %s", slot.Code, skus[other].SKU, sku.SKU)
+		}
+		occupied[pl.SlotID] = pl.ItemID
+		if sku.EachWeightKg >= params.HeavyThresholdKg && slot.Level > params.HeavyMaxLevel {
+			t.Errorf("heavy %s (%.1fkg) on level %d above %d", sku.SKU, sku.EachWeightKg, slot.Level, params.HeavyMaxLevel)
+		}
+		group := HazmatGroup(sku.HazmatClass)
+		if len(hazZones) > 0 && (group != "") != hazZones[slot.Zone] {
+			t.Errorf("%s (hazmat %q) in zone %s", sku.SKU, sku.HazmatClass, slot.Zone)
+		}
+		if group != "" {
+			if bayGroups[slot.Bay] == nil {
+				bayGroups[slot.Bay] = map[string]bool{}
+			}
+			bayGroups[slot.Bay][group] = true
+		}
+		if sku.Current != nil && *sku.Current == pl.SlotID {
+			continue
+		}
+		qty := float64(sku.Quantity)
+		if qty < 1 {
+			qty = 1
+		}
+		if slot.MaxWeightKg > 0 && qty*sku.EachWeightKg > slot.MaxWeightKg {
+			t.Errorf("%s weighs %.1fkg in %s rated %.1fkg", sku.SKU, qty*sku.EachWeightKg, slot.Code, slot.MaxWeightKg)
+		}
+		item := sortedDims(sku.EachLengthCm, sku.EachWidthCm, sku.EachHeightCm)
+		room := sortedDims(slot.WidthCm, slot.DepthCm, slot.HeightCm)
+		for d := 0; d < 3; d++ {
+			if item[d] > room[d] {
+				t.Errorf("%s does not fit %s: %v > %v", sku.SKU, slot.Code, item, room)
+				break
+			}
+		}
+		if cube := qty * item[0] * item[1] * item[2]; cube > room[0]*room[1]*room[2]*params.FillFactor {
+			t.Errorf("%s needs %.0fcm3 in %s", sku.SKU, cube, slot.Code)
+		}
+	}
+	for bay, groups := range bayGroups {
+		if len(groups) > 1 {
+			t.Errorf("bay %s mixes hazmat classes %v", bay, groups)
+		}
+	}
+}
+
+func TestSyntheticIsDeterministic(t *testing.T) {
+	spec := SyntheticSpec{Seed: 7, Aisles: 3, Bays: 4, Levels: 4, Positions: 2, SKUs: 60, HazmatShare: 0.2, HeavyShare: 0.2, Fill: 0.7}
+	if a, b := Synthetic(spec), Synthetic(spec); !reflect.DeepEqual(a, b) {
+		t.Fatal("Synthetic built different problems from the same spec")
+	}
+	other := spec
+	other.Seed = 8
+	if a, b := Synthetic(spec), Synthetic(other); reflect.DeepEqual(a, b) {
+		t.Fatal("Synthetic built the same problem from different seeds")
+	}
+}
+
+func TestSolveIsDeterministic(t *testing.T) {
+	tests := []struct {
+		name string
+		spec SyntheticSpec
+	}{
+		{name: "small", spec: SyntheticSpec{Seed: 1, Aisles: 2, Bays: 3, Levels: 3, Positions: 2, SKUs: 30, Fill: 0.5}},
+		{name: "hazmat and heavy", spec: SyntheticSpec{Seed: 42, Aisles: 3, Bays: 4, Levels: 4, Positions: 2, SKUs: 80, HazmatShare: 0.25, HeavyShare: 0.25, Fill: 0.8}},
+		{name: "more skus than slots", spec: SyntheticSpec{Seed: 99, Aisles: 1, Bays: 3, Levels: 2, Positions: 2, SKUs: 20, HazmatShare: 0.1, Fill: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, first := solveSynthetic(t, tt.spec, nil)
+			_, second := solveSynthetic(t, tt.spec, nil)
+			if !reflect.DeepEqual(first, second) {
+				t.Fatal("two solves of the same problem and seed differ")
+			}
+			if !reflect.DeepEqual(PlanMoves(first.Placements), PlanMoves(second.Placements)) {
+				t.Fatal("two solves of the same problem and seed plan different moves")
+			}
+		})
+	}
+}
+
+func TestSolveKeepsHardConstraints(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   SyntheticSpec
+		params func(*Params)
+	}{
+		{
+			name: "defaults",
+			spec: SyntheticSpec{Seed: 3, Aisles: 3, Bays: 4, Levels: 4, Positions: 2, SKUs: 70, HazmatShare: 0.2, HeavyShare: 0.2, Fill: 0.6},
+		},
+		{
+			name:   "heavy only on the floor",
+			spec:   SyntheticSpec{Seed: 5, Aisles: 2, Bays: 4, Levels: 4, Positions: 2, SKUs: 50, HeavyShare: 0.4, Fill: 0.9},
+			params: func(p *Params) { p.HeavyMaxLevel = 0 },
+		},
+		{
+			name:   "tight fill factor",
+			spec:   SyntheticSpec{Seed: 11, Aisles: 2, Bays: 4, Levels: 3, Positions: 2, SKUs: 40, Fill: 0.3},
+			params: func(p *Params) { p.FillFactor = 0.05 },
+		},
+		{
+			name:   "move cap",
+			spec:   SyntheticSpec{Seed: 13, Aisles: 2, Bays: 4, Levels: 3, Positions: 2, SKUs: 40, HazmatShare: 0.3, Fill: 1},
+			params: func(p *Params) { p.MaxMoves = 5 },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, res := solveSynthetic(t, tt.spec, tt.params)
+			checkHardConstraints(t, p, res)
+			if p.Params.MaxMoves > 0 && res.Moves > p.Params.MaxMoves {
+				t.Errorf("%d moves, cap is %d", res.Moves, p.Params.MaxMoves)
+			}
+		})
+	}
+}
+
+func TestSolveKeepsHazmatInItsZones(t *testing.T) {
+	spec := SyntheticSpec{Seed: 21, Aisles: 3, Bays: 3, Levels: 3, Positions: 2, SKUs: 60, HazmatShare: 0.3, Fill: 0.5}
+	p := Synthetic(spec)
+	for i := range p.Slots {
+		if p.Slots[i].Bay[:4] == "A-01" {
+			p.Slots[i].Zone = "HAZ"
+		}
+	}
+	p.Params.HazmatZones = []string{"HAZ"}
+	p.Params.Iterations = 3000
+	res, err := Solve(context.Background(), p, nil)
+	if err != nil {
+		t.Fatalf("Solve: %v", err)
+	}
+	checkHardConstraints(t, p, res)
+}
+
+func TestSolveSmallProblems(t *testing.T) {
+	floor := Slot{ID: uuid.New(), Code: "FLOOR", Zone: "A", Bay: "B1", Level: 0, Distance: 5, WidthCm: 100, DepthCm: 100, HeightCm: 100, MaxWeightKg: 1000}
+	top := Slot{ID: uuid.New(), Code: "TOP", Zone: "A", Bay: "B1", Level: 3, Distance: 1, WidthCm: 100, DepthCm: 100, HeightCm: 100, MaxWeightKg: 1000}
+	small := Slot{ID: uuid.New(), Code: "SMALL", Zone: "A", Bay: "B2", Level: 1, Distance: 1, WidthCm: 10, DepthCm: 10, HeightCm: 10, MaxWeightKg: 5}
+	hazB1 := Slot{ID: uuid.New(), Code: "HAZ1", Zone: "A", Bay: "B3", Level: 1, Distance: 2, WidthCm: 100, DepthCm: 100, HeightCm: 100, MaxWeightKg: 1000}
+	hazB2 := Slot{ID: uuid.New(), Code: "HAZ2", Zone: "A", Bay: "B3", Level: 1, Distance: 3, WidthCm: 100, DepthCm: 100, HeightCm: 100, MaxWeightKg: 1000}
+	sku := func(name string, kg, cm float64, hazmat string) SKU {
+		return SKU{ItemID: uuid.New(), SKU: name, PicksPerDay: 10, Quantity: 1, EachLengthCm: cm, EachWidthCm: cm, EachHeightCm: cm, EachWeightKg: kg, HazmatClass: hazmat}
+	}
+
+	tests := []struct {
+		name         string
+		slots        []Slot
+		skus         []SKU
+		wantSlot     map[string]string
+		wantUnplaced []string
+	}{
+		{
+			name:     "heavy goes low even though the top slot is nearer",
+			slots:    []Slot{floor, top},
+			skus:     []SKU{sku("HEAVY", 40, 20, "")},
+			wantSlot: map[string]string{"HEAVY": "FLOOR"},
+		},
+		{
+			name:         "heavy with only a high slot stays unplaced",
+			slots:        []Slot{top},
+			skus:         []SKU{sku("HEAVY", 40, 20, "")},
+			wantUnplaced: []string{"HEAVY"},
+		},
+		{
+			name:     "too big for the small slot",
+			slots:    []Slot{small, floor},
+			skus:     []SKU{sku("BULKY", 1, 30, "")},
+			wantSlot: map[string]string{"BULKY": "FLOOR"},
+		},
+		{
+			name:         "over the slot's weight rating",
+			slots:        []Slot{small},
+			skus:         []SKU{sku("DENSE", 6, 5, "")},
+			wantUnplaced: []string{"DENSE"},
+		},
+		{
+			name:         "two hazmat classes never share a bay",
+			slots:        []Slot{hazB1, hazB2},
+			skus:         []SKU{sku("FLAMMABLE", 1, 20, "3"), sku("CORROSIVE", 1, 20, "8")},
+			wantUnplaced: []string{"CORROSIVE"},
+		},
+		{
+			name:     "divisions of one class may share a bay",
+			slots:    []Slot{hazB1, hazB2},
+			skus:     []SKU{sku("GAS-2.1", 1, 20, "2.1"), sku("GAS-2.3", 1, 20, "2.3")},
+			wantSlot: map[string]string{"GAS-2.1": "HAZ1", "GAS-2.3": "HAZ2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Equal picks are ordered by item ID, so give the first SKU the
+			// most picks to make the expected outcome independent of IDs.
+			for i := range tt.skus {
+				tt.skus[i].PicksPerDay = float64(10 - i)
+			}
+			p := Problem{Slots: tt.slots, SKUs: tt.skus, Params: Params{Seed: 1, Iterations: 200}}
+			res, err := Solve(context.Background(), p, nil)
+			if err != nil {
+				t.Fatalf("Solve: %v", err)
+			}
+			checkHardConstraints(t, p, res)
+			names := map[uuid.UUID]string{}
+			for _, s := range tt.skus {
+				names[s.ItemID] = s.SKU
+			}
+			codes := map[uuid.UUID]string{}
+			for _, sl := range tt.slots {
+				codes[sl.ID] = sl.Code
+			}
+			got := map[string]string{}
+			for _, pl := range res.Placements {
+				got[names[pl.ItemID]] = codes[pl.SlotID]
+			}
+			for name, code := range tt.wantSlot {
+				if got[name] != code {
+					t.Errorf("%s in %q, want %q", name, got[name], code)
+				}
+			}
+			var unplaced []string
+			for _, u := range res.Unplaced {
+				if u.Reason == "" {
+					t.Errorf("%s unplaced without a reason", names[u.ItemID])
+				}
+				unplaced = append(unplaced, names[u.ItemID])
+			}
+			if !reflect.DeepEqual(unplaced, tt.wantUnplaced) {
+				t.Errorf("unplaced = %v, want %v", unplaced, tt.wantUnplaced)
+			}
+		})
+	}
+}
+
+func TestSolveStopsWhenCanceled(t *testing.T) {
+	p := Synthetic(SyntheticSpec{Seed: 1, Aisles: 2, Bays: 2, Levels: 2, Positions: 2, SKUs: 10, Fill: 0.5})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Solve(ctx, p, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+}
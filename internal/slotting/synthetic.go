@@ -0,0 +1,108 @@
+package slotting
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/google/uuid"
+)
+
+// SyntheticSpec describes a generated warehouse: Aisles x Bays x Levels x
+// Positions slots and SKUs items, of which HazmatShare carry a hazmat class
+// and HeavyShare weigh more than the default heavy threshold. Fill is the
+// share of SKUs that already have a slot.
+type SyntheticSpec struct {
+	Seed        int64
+	Aisles      int
+	Bays        int
+	Levels      int
+	Positions   int
+	SKUs        int
+	HazmatShare float64
+	HeavyShare  float64
+	Fill        float64
+}
+
+// Synthetic builds a reproducible Problem from spec, for exercising the
+// solver without a database. Pick frequencies follow a Zipf-like curve so
+// roughly a fifth of the SKUs carry most picks, as in real order profiles,
+// and the current assignment is random, so there is something to improve.
+func Synthetic(spec SyntheticSpec) Problem {
+	rng := rand.New(rand.NewSource(spec.Seed))
+	id := func() uuid.UUID {
+		var b [16]byte
+		rng.Read(b[:])
+		u, _ := uuid.FromBytes(b[:])
+		u[6] = (u[6] & 0x0f) | 0x40
+		u[8] = (u[8] & 0x3f) | 0x80
+		return u
+	}
+
+	var slots []Slot
+	for a := 1; a <= spec.Aisles; a++ {
+		for b := 1; b <= spec.Bays; b++ {
+			bay := fmt.Sprintf("A-%02d-%02d", a, b)
+			for l := 0; l < spec.Levels; l++ {
+				for p := 1; p <= spec.Positions; p++ {
+					slots = append(slots, Slot{
+						ID:          id(),
+						Code:        fmt.Sprintf("%s-%c-%02d", bay, 'A'+l, p),
+						Zone:        "A",
+						Bay:         bay,
+						Level:       l,
+						Distance:    float64((a-1)*spec.Bays*2 + b*2 + p),
+						WidthCm:     100,
+						DepthCm:     80,
+						HeightCm:    60,
+						MaxWeightKg: 500,
+					})
+				}
+			}
+		}
+	}
+
+	skus := make([]SKU, spec.SKUs)
+	hazmatClasses := []string{"2.1", "3", "8"}
+	for i := range skus {
+		picks := 50 / math.Pow(float64(i+1), 0.9)
+		abc := "C"
+		switch {
+		case i < spec.SKUs/5:
+			abc = "A"
+		case i < spec.SKUs/2:
+			abc = "B"
+		}
+		weight := 0.2 + rng.Float64()*3
+		if rng.Float64() < spec.HeavyShare {
+			weight = DefaultHeavyThresholdKg + rng.Float64()*10
+		}
+		sku := SKU{
+			ItemID:       id(),
+			SKU:          fmt.Sprintf("SKU-%05d", i+1),
+			PicksPerDay:  picks,
+			ABCClass:     abc,
+			Quantity:     1 + rng.Intn(12),
+			EachLengthCm: 5 + rng.Float64()*25,
+			EachWidthCm:  5 + rng.Float64()*20,
+			EachHeightCm: 2 + rng.Float64()*15,
+			EachWeightKg: weight,
+		}
+		if rng.Float64() < spec.HazmatShare {
+			sku.HazmatClass = hazmatClasses[rng.Intn(len(hazmatClasses))]
+		}
+		skus[i] = sku
+	}
+
+	order := rng.Perm(len(slots))
+	next := 0
+	for i := range skus {
+		if next >= len(order) || rng.Float64() >= spec.Fill {
+			continue
+		}
+		current := slots[order[next]].ID
+		skus[i].Current = &current
+		next++
+	}
+	return Problem{Slots: slots, SKUs: skus, Params: Params{Seed: spec.Seed}}
+}
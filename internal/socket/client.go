@@ -45,9 +45,12 @@ func NewClient(conn *websocket.Conn, hub *Hub, log *logger.Logger) *Client {
 	}
 }
 
+// Run first tells the client its connection ID, which it passes back when
+// setting what it is viewing, then serves it until either side closes.
 func (c *Client) Run(ctx context.Context) {
 	ctx, cancel := context.WithCancel(ctx)
 	c.cancelFn = cancel
+	c.reply(Message{Data: map[string]string{"action": "connected", "connectionID": c.ID.String()}})
 	go c.writeLoop(ctx)
 	c.readLoop(ctx)
 }
@@ -76,6 +79,11 @@ func (c *Client) readLoop(ctx context.Context) {
 		switch inbound.Action {
 		case "subscribe":
 			if inbound.Channel != "" {
+				if err := c.Hub.Authorize(ctx, inbound.Channel); err != nil {
+					c.logger.Debug("Client subscribe refused", "channel", inbound.Channel, "client", c.ID, "error", err)
+					c.reply(Message{Channel: inbound.Channel, Data: map[string]string{"action": "subscribe", "error": err.Error()}})
+					continue
+				}
 				c.Hub.Subscribe(c, []string{inbound.Channel})
 				c.logger.Debug("Client requested subscribe", "channel", inbound.Channel, "client", c.ID)
 			}
@@ -119,6 +127,16 @@ func (c *Client) writeLoop(ctx context.Context) {
 	}
 }
 
+// reply queues a message for this client only, dropping it when the
+// outbound buffer is full.
+func (c *Client) reply(msg Message) {
+	select {
+	case c.Outbound <- msg:
+	default:
+		c.logger.Warn("Dropping reply to client; outbound buffer full", "client", c.ID)
+	}
+}
+
 func (c *Client) writeJSON(v interface{}) error {
 	payload, err := json.Marshal(v)
 	if err != nil {
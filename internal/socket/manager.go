@@ -2,10 +2,13 @@ package socket
 
 import (
     "context"
+    "encoding/json"
+    "errors"
     "sync"
 
     "github.com/google/uuid"
     "github.com/slotter-org/slotter-backend/internal/logger"
+    "github.com/slotter-org/slotter-backend/internal/pubsub"
 )
 
 // Message is unchanged
@@ -14,26 +17,74 @@ type Message struct {
     Data    interface{} `json:"data"`
 }
 
+// ChannelAuthorizer decides whether the requester in ctx may subscribe to
+// channel.
+type ChannelAuthorizer interface {
+    Authorize(ctx context.Context, channel string) error
+}
+
 type Hub struct {
     log       *logger.Logger
     mu        sync.RWMutex
     channels  map[string]map[uuid.UUID]*Client
 
-    // Add a pointer to the RedisPubSub, optional
-    redisPubSub *RedisPubSub
+    // origin marks what this hub publishes, so its own messages coming back
+    // from the pub/sub are not delivered twice.
+    origin    string
+    ps        pubsub.PubSub
+    topic     string
+
+    authorizer ChannelAuthorizer
 }
 
-// Modify NewHub to accept an optional redisPubSub:
 func NewHub(logger *logger.Logger) *Hub {
     return &Hub{
         log:       logger,
         channels:  make(map[string]map[uuid.UUID]*Client),
+        origin:    uuid.NewString(),
+    }
+}
+
+// SetAuthorizer sets the check run before every client subscription.
+func (h *Hub) SetAuthorizer(a ChannelAuthorizer) {
+    h.authorizer = a
+}
+
+// Authorize checks a subscription of the requester in ctx. Without an
+// authorizer every subscription is refused.
+func (h *Hub) Authorize(ctx context.Context, channel string) error {
+    if h.authorizer == nil {
+        return errors.New("channel subscriptions are not configured")
     }
+    return h.authorizer.Authorize(ctx, channel)
 }
 
-// If you want to store it later:
-func (h *Hub) SetRedisPubSub(rp *RedisPubSub) {
-    h.redisPubSub = rp
+// SetPubSub fans broadcasts out to the hubs of other instances through
+// topic and delivers theirs here.
+func (h *Hub) SetPubSub(ctx context.Context, ps pubsub.PubSub, topic string) error {
+    if _, err := ps.Subscribe(ctx, topic, h.receive); err != nil {
+        return err
+    }
+    h.ps = ps
+    h.topic = topic
+    return nil
+}
+
+func (h *Hub) receive(payload []byte) {
+    env, err := pubsub.Decode(payload)
+    if err != nil {
+        h.log.Warn("Failed to decode pubsub message", "error", err)
+        return
+    }
+    if env.Origin == h.origin {
+        return
+    }
+    var msg Message
+    if err := json.Unmarshal(env.Data, &msg); err != nil {
+        h.log.Warn("Failed to decode pubsub message", "error", err)
+        return
+    }
+    h.localBroadcast(msg)
 }
 
 // Subscribe is unchanged
@@ -100,10 +151,15 @@ func (h *Hub) BroadcastGlobal(ctx context.Context, msg Message) {
     // 1) always do local broadcast
     h.localBroadcast(msg)
 
-    // 2) if we have Redis, also publish so other nodes can broadcast
-    if h.redisPubSub != nil {
-        if err := h.redisPubSub.Publish(msg); err != nil {
-            h.log.Warn("Failed to publish to Redis", "error", err)
+    // 2) if we have a pub/sub, also publish so other nodes can broadcast
+    if h.ps != nil {
+        payload, err := pubsub.Encode(h.origin, msg)
+        if err != nil {
+            h.log.Warn("Failed to encode message for pubsub", "error", err)
+            return
+        }
+        if err := h.ps.Publish(ctx, h.topic, payload); err != nil {
+            h.log.Warn("Failed to publish to pubsub", "error", err)
         }
     }
 }
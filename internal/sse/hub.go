@@ -1,7 +1,9 @@
 package sse
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -9,36 +11,68 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/slotter-org/slotter-backend/internal/events"
 	"github.com/slotter-org/slotter-backend/internal/logger"
+	"github.com/slotter-org/slotter-backend/internal/pubsub"
 )
 
+// SSEEvent is the name browsers listen for. Domain events are sent under
+// their events.Type; the hub itself only adds Connected and ResyncRequired.
 type SSEEvent string
 
+// The names clients listen for, kept as aliases of the event types they
+// are sent under.
 const (
-	SSEEventUserJoined         SSEEvent = "UserJoined"
-	SSEEventUserLeft           SSEEvent = "UserLeft"
-	SSEEventUserAvatarUpdated  SSEEvent = "UserAvatarUpdated"
-	SSEEventUserNameChanged    SSEEvent = "UserNameChanged"
-	SSEEventWarehouseCreated   SSEEvent = "WarehouseCreated"
-	SSEEventWarehouseDeleted   SSEEvent = "WarehouseDeleted"
-	SSEEventCompanyCreated     SSEEvent = "CompanyCreated"
-	SSEEventCompanyDeleted     SSEEvent = "CompanyDeleted"
-	SSEEventRoleCreated				 SSEEvent = "RoleCreated"
-	SSEEventRoleDeleted				 SSEEvent = "RoleDeleted"
-	SSEEventRoleUpdated				 SSEEvent = "RoleUpdated"
-	SSEEventInvitationCreated	 SSEEvent = "InvitationCreated"
-	SSEEventInvitationAccepted SSEEvent = "InvitationAccepted"
-	SSEEventInvitationCanceled SSEEvent = "InvitationCanceled"
-	SSEEventInvitationResent	 SSEEvent = "InvitationResent"
-	SSEEventInvitationDeleted  SSEEvent = "InvitationDeleted"
-	SSEEventInvitationExpired	 SSEEvent = "InvitationExpired"
-	SSEEventInvitationUpdated	 SSEEvent = "InvitationUpdated"
+	SSEEventUserJoined           = SSEEvent(events.UserJoined)
+	SSEEventUserLeft             = SSEEvent(events.UserLeft)
+	SSEEventUserAvatarUpdated    = SSEEvent(events.UserAvatarUpdated)
+	SSEEventAvatarUpdated        = SSEEvent(events.AvatarUpdated)
+	SSEEventUserNameChanged      = SSEEvent(events.UserNameChanged)
+	SSEEventWarehouseCreated     = SSEEvent(events.WarehouseCreated)
+	SSEEventWarehouseDeleted     = SSEEvent(events.WarehouseDeleted)
+	SSEEventCompanyCreated       = SSEEvent(events.CompanyCreated)
+	SSEEventCompanyDeleted       = SSEEvent(events.CompanyDeleted)
+	SSEEventRoleCreated          = SSEEvent(events.RoleCreated)
+	SSEEventRoleDeleted          = SSEEvent(events.RoleDeleted)
+	SSEEventRoleUpdated          = SSEEvent(events.RoleUpdated)
+	SSEEventInvitationCreated    = SSEEvent(events.InvitationCreated)
+	SSEEventInvitationAccepted   = SSEEvent(events.InvitationAccepted)
+	SSEEventInvitationCanceled   = SSEEvent(events.InvitationCanceled)
+	SSEEventInvitationResent     = SSEEvent(events.InvitationResent)
+	SSEEventInvitationDeleted    = SSEEvent(events.InvitationDeleted)
+	SSEEventInvitationExpired    = SSEEvent(events.InvitationExpired)
+	SSEEventInvitationUpdated    = SSEEvent(events.InvitationUpdated)
+	SSEEventSlottingJobProgress  = SSEEvent(events.SlottingJobProgress)
+	SSEEventSlottingJobCompleted = SSEEvent(events.SlottingJobCompleted)
+	SSEEventSlottingJobFailed    = SSEEvent(events.SlottingJobFailed)
+	SSEEventSlottingJobCanceled  = SSEEvent(events.SlottingJobCanceled)
+)
+
+const (
+	// SSEEventConnected is the first message of a stream and carries the
+	// connectionID the client passes back when setting what it is viewing.
+	SSEEventConnected SSEEvent = "Connected"
+	// SSEEventResyncRequired tells a client that events of Channel after
+	// its Last-Event-ID can no longer be replayed, so it should refetch.
+	SSEEventResyncRequired SSEEvent = "ResyncRequired"
+)
+
+const (
+	// outboundBuffer is how many events a client may fall behind before it
+	// is disconnected to catch up through replay.
+	outboundBuffer = 256
+	// replayLimit caps the events replayed per channel on subscribe; a
+	// larger gap gets a resync event instead.
+	replayLimit = 200
+	// retryMillis is the reconnect delay suggested to browsers.
+	retryMillis = 3000
 )
 
 type SSEMessage struct {
-	Channel string			`json:"channel"`
-	Event   SSEEvent		`json:"event"`
-	Data		any					`json:"data,omitempty"`
+	ID      uint64   `json:"id,omitempty"`
+	Channel string   `json:"channel"`
+	Event   SSEEvent `json:"event"`
+	Data    any      `json:"data,omitempty"`
 }
 
 type SSEClient struct {
@@ -46,21 +80,106 @@ type SSEClient struct {
 	UserID   uuid.UUID
 	Channels map[string]bool
 	Outbound chan SSEMessage
-	done     chan struct{}
-	Logger   *logger.Logger
+	// LastEventID is the Last-Event-ID the client reconnected with; events
+	// after it are replayed as it subscribes to channels.
+	LastEventID uint64
+	done        chan struct{}
+	// closed is set under hub.mu once Outbound is closed, so a subscribe
+	// racing a reconnect cannot register or send to the dead client.
+	closed  bool
+	lagged  chan struct{}
+	lagOnce sync.Once
+	Logger  *logger.Logger
 }
 
 type SSEHub struct {
-	mu           sync.RWMutex
-	logger       *logger.Logger
+	mu sync.RWMutex
+	// publishMu orders appends to the store with delivery, so a subscribing
+	// client's replay and the live events after it neither overlap nor gap.
+	publishMu     sync.Mutex
+	logger        *logger.Logger
+	store         EventStore
 	subscriptions map[string]map[*SSEClient]bool
+
+	// origin marks what this hub publishes, so its own messages coming back
+	// from the pub/sub are not delivered twice.
+	origin string
+	ps     pubsub.PubSub
+	topic  string
 }
 
 func NewSSEHub(log *logger.Logger) *SSEHub {
 	return &SSEHub{
-		logger: log.With("component", "SSEHub"),
+		logger:        log.With("component", "SSEHub"),
+		store:         NewMemoryEventStore(DefaultReplaySize),
 		subscriptions: make(map[string]map[*SSEClient]bool),
+		origin:        uuid.NewString(),
+	}
+}
+
+// ErrLocalEventStore is returned when a pub/sub shared with other
+// instances is paired with a store only this instance numbers events in:
+// each instance would give the same event a different ID, so a
+// Last-Event-ID from one would replay the wrong events on another.
+var ErrLocalEventStore = errors.New("a shared pub/sub needs a shared SSE event store")
+
+// SetEventStore replaces the in-memory replay buffer, e.g. with a
+// RedisEventStore shared by all instances.
+func (hub *SSEHub) SetEventStore(store EventStore) error {
+	hub.publishMu.Lock()
+	defer hub.publishMu.Unlock()
+	if hub.ps != nil && hub.ps.Shared() && !store.Shared() {
+		return ErrLocalEventStore
+	}
+	hub.store = store
+	return nil
+}
+
+// SetPubSub fans broadcasts out to the SSE hubs of other instances through
+// topic and delivers theirs here. A shared pub/sub needs a shared store.
+func (hub *SSEHub) SetPubSub(ctx context.Context, ps pubsub.PubSub, topic string) error {
+	hub.publishMu.Lock()
+	shared := hub.store.Shared()
+	hub.publishMu.Unlock()
+	if ps.Shared() && !shared {
+		return ErrLocalEventStore
+	}
+	if _, err := ps.Subscribe(ctx, topic, hub.receive); err != nil {
+		return err
+	}
+	hub.publishMu.Lock()
+	defer hub.publishMu.Unlock()
+	hub.ps = ps
+	hub.topic = topic
+	return nil
+}
+
+// receive delivers a message broadcast by another hub. With a shared store
+// it already has its ID; a local store, which only an in-process pub/sub
+// may be paired with, numbers it here.
+func (hub *SSEHub) receive(payload []byte) {
+	env, err := pubsub.Decode(payload)
+	if err != nil {
+		hub.logger.Warn("Failed to decode SSE pubsub message", "error", err)
+		return
+	}
+	if env.Origin == hub.origin {
+		return
 	}
+	var msg SSEMessage
+	if err := json.Unmarshal(env.Data, &msg); err != nil {
+		hub.logger.Warn("Failed to decode SSE pubsub message", "error", err)
+		return
+	}
+	if msg.Channel == "" {
+		return
+	}
+	hub.publishMu.Lock()
+	defer hub.publishMu.Unlock()
+	if !hub.store.Shared() || msg.ID == 0 {
+		msg = hub.append(msg)
+	}
+	hub.deliver(msg)
 }
 
 func (hub *SSEHub) NewSSEClient(userID uuid.UUID) *SSEClient {
@@ -68,21 +187,31 @@ func (hub *SSEHub) NewSSEClient(userID uuid.UUID) *SSEClient {
 		ID:       uuid.New(),
 		UserID:   userID,
 		Channels: make(map[string]bool),
-		Outbound: make(chan SSEMessage, 10),
+		Outbound: make(chan SSEMessage, outboundBuffer),
 		done:     make(chan struct{}),
+		lagged:   make(chan struct{}),
 		Logger:   hub.logger.With("clientID", nil),
 	}
 }
 
 func (hub *SSEHub) AddChannel(client *SSEClient, channel string) {
-	hub.mu.Lock()
-	defer hub.mu.Unlock()
-
 	channel = strings.TrimSpace(channel)
 	if channel == "" {
 		return
 	}
 
+	hub.publishMu.Lock()
+	defer hub.publishMu.Unlock()
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if client.closed {
+		hub.logger.Debug("SSE client closed before subscribing", "clientID", client.ID, "channel", channel)
+		return
+	}
+	if client.LastEventID > 0 && !client.Channels[channel] {
+		hub.replay(client, channel)
+	}
 	client.Channels[channel] = true
 
 	clients, exists := hub.subscriptions[channel]
@@ -117,7 +246,11 @@ func (hub *SSEHub) RemoveChannel(client *SSEClient, channel string) {
 func (hub *SSEHub) RemoveClient(client *SSEClient) {
 	hub.mu.Lock()
 	defer hub.mu.Unlock()
+	hub.removeClient(client)
+}
 
+// removeClient drops client from all its channels. Callers hold mu.
+func (hub *SSEHub) removeClient(client *SSEClient) {
 	for ch := range client.Channels {
 		if subMap, ok := hub.subscriptions[ch]; ok {
 			delete(subMap, client)
@@ -130,23 +263,98 @@ func (hub *SSEHub) RemoveClient(client *SSEClient) {
 	hub.logger.Debug("SSE client unsubscribed from all channels", "clientID", client.ID)
 }
 
-func (hub *SSEHub) Broadcast(msg SSEMessage) {
-	hub.mu.RLock()
-	defer hub.mu.RUnlock()
+// replay queues the stored events of channel after client.LastEventID, or
+// a resync event when they are not all available. Callers hold publishMu.
+func (hub *SSEHub) replay(client *SSEClient, channel string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	events, complete, err := hub.store.Since(ctx, channel, client.LastEventID, replayLimit)
+	if err != nil {
+		hub.logger.Warn("Failed to load SSE replay", "clientID", client.ID, "channel", channel, "error", err)
+		complete = false
+	}
+	if !complete {
+		hub.logger.Debug("SSE replay incomplete; asking client to resync", "clientID", client.ID, "channel", channel, "lastEventID", client.LastEventID)
+		hub.send(client, SSEMessage{
+			Channel: channel,
+			Event:   SSEEventResyncRequired,
+			Data:    map[string]any{"lastEventID": client.LastEventID},
+		})
+		return
+	}
+	for _, msg := range events {
+		hub.send(client, msg)
+	}
+	hub.logger.Debug("SSE events replayed", "clientID", client.ID, "channel", channel, "count", len(events))
+}
 
+// Broadcast numbers msg, keeps it for replay and sends it to the channel's
+// subscribers here and, through the pub/sub, on other instances.
+func (hub *SSEHub) Broadcast(msg SSEMessage) {
 	if msg.Channel == "" {
 		return
 	}
-	clientsMap, ok := hub.subscriptions[msg.Channel]
-	if !ok {
+	hub.publishMu.Lock()
+	msg = hub.append(msg)
+	hub.deliver(msg)
+	ps, topic := hub.ps, hub.topic
+	hub.publishMu.Unlock()
+
+	// Published outside publishMu: this hub's own subscription may be
+	// waiting for the lock to deliver another instance's message.
+	if ps == nil {
 		return
 	}
-	for c := range clientsMap {
-		select {
-		case c.Outbound <- msg:
-		default:
-			hub.logger.Warn("Dropping SSE message; outbound buffer full", "clientID", c.ID)
-		}
+	payload, err := pubsub.Encode(hub.origin, msg)
+	if err != nil {
+		hub.logger.Warn("Failed to encode SSE message for pubsub", "error", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := ps.Publish(ctx, topic, payload); err != nil {
+		hub.logger.Warn("Failed to publish SSE message", "channel", msg.Channel, "error", err)
+	}
+}
+
+// append stores msg for replay and returns it with its ID. Callers hold
+// publishMu.
+func (hub *SSEHub) append(msg SSEMessage) SSEMessage {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	stored, err := hub.store.Append(ctx, msg)
+	if err != nil {
+		hub.logger.Warn("Failed to store SSE message; sending without an ID", "channel", msg.Channel, "error", err)
+		msg.ID = 0
+		return msg
+	}
+	return stored
+}
+
+// deliver sends msg to this instance's subscribers of its channel. Callers
+// hold publishMu.
+func (hub *SSEHub) deliver(msg SSEMessage) {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	for c := range hub.subscriptions[msg.Channel] {
+		hub.send(c, msg)
+	}
+}
+
+// send queues msg without blocking. A client whose buffer is full is
+// disconnected rather than silently losing events; it reconnects with its
+// Last-Event-ID and catches up from the replay buffer. Callers hold mu.
+func (hub *SSEHub) send(client *SSEClient, msg SSEMessage) {
+	if client.closed {
+		return
+	}
+	select {
+	case client.Outbound <- msg:
+	default:
+		client.lagOnce.Do(func() {
+			hub.logger.Warn("SSE client outbound buffer full; disconnecting", "clientID", client.ID)
+			close(client.lagged)
+		})
 	}
 }
 
@@ -171,6 +379,10 @@ func (hub *SSEHub) ServeHTTP(w http.ResponseWriter, r *http.Request, client *SSE
 	heartbeat := time.NewTicker(15 * time.Second)
 	defer heartbeat.Stop()
 
+	_, _ = fmt.Fprintf(w, "retry: %d\n\n", retryMillis)
+	hub.write(w, SSEMessage{Event: SSEEventConnected, Data: map[string]any{"connectionID": client.ID}})
+	flusher.Flush()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -180,33 +392,54 @@ func (hub *SSEHub) ServeHTTP(w http.ResponseWriter, r *http.Request, client *SSE
 		case <-client.done:
 			return
 
+		case <-client.lagged:
+			return
+
 		case <-heartbeat.C:
 			// ":" = comment line in SSE; browsers ignore, LBs see traffic
-		  const pingChunkSize = 8*1024 - len(": ping \n\n") 
+			const pingChunkSize = 8*1024 - len(": ping \n\n")
 			fmt.Fprint(w, ": ping "+strings.Repeat("#", pingChunkSize)+"\n\n")
 			flusher.Flush()
 
-		case msg := <-client.Outbound:
-			// All events use "event: message" so a single onmessage captures them
-			_, _ = fmt.Fprintf(w, "event: message\n")
-
-			// Marshal SSEMessage as JSON
-			jsonBytes, err := json.Marshal(msg)
-			if err != nil {
-				hub.logger.Warn("Failed to marshal SSE message", "error", err)
-				continue
+		case msg, ok := <-client.Outbound:
+			if !ok {
+				return
 			}
-
-			// e.g. data: {"event":"UserJoined","channel":"SomeChannel"}
-			_, _ = fmt.Fprintf(w, "data: %s\n\n", string(jsonBytes))
+			hub.write(w, msg)
 			flusher.Flush()
 		}
 	}
 }
 
+// write sends msg as one SSE event.
+func (hub *SSEHub) write(w http.ResponseWriter, msg SSEMessage) {
+	// Marshal SSEMessage as JSON
+	jsonBytes, err := json.Marshal(msg)
+	if err != nil {
+		hub.logger.Warn("Failed to marshal SSE message", "error", err)
+		return
+	}
+
+	// The id line lets the browser resume with Last-Event-ID
+	if msg.ID > 0 {
+		_, _ = fmt.Fprintf(w, "id: %d\n", msg.ID)
+	}
+	// All events use "event: message" so a single onmessage captures them
+	_, _ = fmt.Fprintf(w, "event: message\n")
+
+	// e.g. data: {"event":"UserJoined","channel":"SomeChannel"}
+	_, _ = fmt.Fprintf(w, "data: %s\n\n", string(jsonBytes))
+}
+
+// CloseClient ends client's stream. It is safe to call more than once.
 func (hub *SSEHub) CloseClient(client *SSEClient) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if client.closed {
+		return
+	}
+	client.closed = true
 	close(client.done)
-	hub.RemoveClient(client)
+	hub.removeClient(client)
 	close(client.Outbound)
 }
-
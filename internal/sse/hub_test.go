@@ -0,0 +1,87 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/slotter-org/slotter-backend/internal/logger"
+	"github.com/slotter-org/slotter-backend/internal/pubsub"
+)
+
+// sharedPubSub stands in for a pub/sub reaching other instances.
+type sharedPubSub struct {
+	*pubsub.Memory
+}
+
+func (sharedPubSub) Shared() bool { return true }
+
+// sharedStore stands in for a store every instance numbers events in.
+type sharedStore struct {
+	*MemoryEventStore
+}
+
+func (sharedStore) Shared() bool { return true }
+
+func TestSharedPubSubNeedsSharedStore(t *testing.T) {
+	log := &logger.Logger{SugaredLogger: zap.NewNop().Sugar()}
+	tests := []struct {
+		name    string
+		ps      pubsub.PubSub
+		store   EventStore
+		wantErr error
+	}{
+		{name: "in-process pub/sub with memory store", ps: pubsub.NewMemory(), store: NewMemoryEventStore(0)},
+		{name: "shared pub/sub with memory store", ps: sharedPubSub{pubsub.NewMemory()}, store: NewMemoryEventStore(0), wantErr: ErrLocalEventStore},
+		{name: "shared pub/sub with shared store", ps: sharedPubSub{pubsub.NewMemory()}, store: sharedStore{NewMemoryEventStore(0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewSSEHub(log)
+			if err := hub.SetEventStore(tt.store); err != nil {
+				t.Fatalf("SetEventStore: %v", err)
+			}
+			if err := hub.SetPubSub(context.Background(), tt.ps, "sse"); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SetPubSub: err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	hub := NewSSEHub(log)
+	if err := hub.SetEventStore(sharedStore{NewMemoryEventStore(0)}); err != nil {
+		t.Fatalf("SetEventStore: %v", err)
+	}
+	if err := hub.SetPubSub(context.Background(), sharedPubSub{pubsub.NewMemory()}, "sse"); err != nil {
+		t.Fatalf("SetPubSub: %v", err)
+	}
+	if err := hub.SetEventStore(NewMemoryEventStore(0)); !errors.Is(err, ErrLocalEventStore) {
+		t.Fatalf("SetEventStore after a shared pub/sub: err = %v, want %v", err, ErrLocalEventStore)
+	}
+}
+
+// TestSubscribeAfterClose subscribes a client its reconnect has already
+// closed, as a subscribe racing the reconnect does, and checks neither the
+// replay nor later broadcasts send on its closed channel.
+func TestSubscribeAfterClose(t *testing.T) {
+	log := &logger.Logger{SugaredLogger: zap.NewNop().Sugar()}
+	hub := NewSSEHub(log)
+	hub.Broadcast(SSEMessage{Channel: "company:acme", Event: SSEEventWarehouseCreated})
+	hub.Broadcast(SSEMessage{Channel: "company:acme", Event: SSEEventWarehouseDeleted})
+
+	client := hub.NewSSEClient(uuid.New())
+	client.LastEventID = 1
+	hub.CloseClient(client)
+	hub.AddChannel(client, "company:acme")
+	hub.Broadcast(SSEMessage{Channel: "company:acme", Event: SSEEventWarehouseCreated})
+	hub.CloseClient(client)
+
+	if client.Channels["company:acme"] {
+		t.Fatal("closed client was subscribed")
+	}
+	if n := len(hub.subscriptions["company:acme"]); n != 0 {
+		t.Fatalf("channel has %d subscribers, want 0", n)
+	}
+}
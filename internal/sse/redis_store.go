@@ -0,0 +1,137 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/slotter-org/slotter-backend/internal/logger"
+)
+
+// appendScript numbers an event and adds it to its channel's stream in one
+// step, so stream IDs follow the global sequence even with several
+// instances. Before the stream is trimmed it records the newest ID that
+// falls out.
+var appendScript = redis.NewScript(`
+local id = redis.call('INCR', KEYS[1])
+local size = tonumber(ARGV[1])
+local len = redis.call('XLEN', KEYS[2])
+if len >= size then
+	local old = redis.call('XRANGE', KEYS[2], '-', '+', 'COUNT', len - size + 1)
+	redis.call('SET', KEYS[3], old[#old][1])
+end
+redis.call('XADD', KEYS[2], 'MAXLEN', size, id .. '-0', 'data', ARGV[2])
+return id
+`)
+
+// RedisEventStore keeps every channel's events in a Redis stream, shared by
+// all instances and kept across restarts.
+type RedisEventStore struct {
+	log    *logger.Logger
+	client *redis.Client
+	prefix string
+	size   int
+}
+
+func NewRedisEventStore(log *logger.Logger, address, password, prefix string, size int) (*RedisEventStore, error) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     address,
+		Password: password,
+		DB:       0,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("redis ping failed: %w", err)
+	}
+	if size <= 0 {
+		size = DefaultReplaySize
+	}
+	return &RedisEventStore{
+		log:    log.With("component", "RedisEventStore"),
+		client: rdb,
+		prefix: prefix,
+		size:   size,
+	}, nil
+}
+
+func (s *RedisEventStore) Append(ctx context.Context, msg SSEMessage) (SSEMessage, error) {
+	msg.ID = 0
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return msg, fmt.Errorf("failed to encode sse event: %w", err)
+	}
+	id, err := appendScript.Run(ctx, s.client, []string{s.seqKey(), s.streamKey(msg.Channel), s.evictedKey(msg.Channel)}, s.size, payload).Uint64()
+	if err != nil {
+		return msg, fmt.Errorf("failed to store sse event: %w", err)
+	}
+	msg.ID = id
+	return msg, nil
+}
+
+func (s *RedisEventStore) Shared() bool {
+	return true
+}
+
+func (s *RedisEventStore) Since(ctx context.Context, channel string, after uint64, limit int) ([]SSEMessage, bool, error) {
+	seq, err := s.client.Get(ctx, s.seqKey()).Uint64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, false, err
+	}
+	if after > seq {
+		return nil, false, nil
+	}
+	evicted, err := s.client.Get(ctx, s.evictedKey(channel)).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, false, err
+	}
+	if evicted != "" && after < streamSeq(evicted) {
+		return nil, false, nil
+	}
+	entries, err := s.client.XRangeN(ctx, s.streamKey(channel), strconv.FormatUint(after+1, 10)+"-0", "+", int64(limit)+1).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(entries) > limit {
+		return nil, false, nil
+	}
+	events := make([]SSEMessage, 0, len(entries))
+	for _, entry := range entries {
+		raw, _ := entry.Values["data"].(string)
+		var msg SSEMessage
+		if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+			s.log.Warn("Skipping undecodable sse event", "channel", channel, "entryID", entry.ID, "error", err)
+			continue
+		}
+		msg.ID = streamSeq(entry.ID)
+		events = append(events, msg)
+	}
+	return events, true, nil
+}
+
+func (s *RedisEventStore) Close() error {
+	return s.client.Close()
+}
+
+func (s *RedisEventStore) seqKey() string {
+	return s.prefix + ":seq"
+}
+
+func (s *RedisEventStore) streamKey(channel string) string {
+	return s.prefix + ":stream:" + channel
+}
+
+func (s *RedisEventStore) evictedKey(channel string) string {
+	return s.prefix + ":evicted:" + channel
+}
+
+// streamSeq is the sequence number of a stream entry ID such as "42-0".
+func streamSeq(id string) uint64 {
+	n, _ := strconv.ParseUint(strings.SplitN(id, "-", 2)[0], 10, 64)
+	return n
+}
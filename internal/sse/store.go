@@ -0,0 +1,98 @@
+package sse
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultReplaySize is how many events each channel keeps for replay.
+const DefaultReplaySize = 500
+
+// EventStore numbers events and keeps the latest ones of every channel so a
+// reconnecting client can be sent what it missed. IDs increase across all
+// channels, so a client's Last-Event-ID means the same thing on each.
+type EventStore interface {
+	// Append assigns msg the next ID and stores it.
+	Append(ctx context.Context, msg SSEMessage) (SSEMessage, error)
+	// Since returns up to limit events of channel with IDs above after,
+	// oldest first. complete is false when some of them are gone: evicted,
+	// lost with a restarted store, or more than limit.
+	Since(ctx context.Context, channel string, after uint64, limit int) (events []SSEMessage, complete bool, err error)
+	// Shared reports whether every instance numbers events in this same
+	// store. Hubs on a local store renumber events from other instances.
+	Shared() bool
+}
+
+// MemoryEventStore keeps a ring buffer per channel in this process. IDs
+// restart with the process; clients that come back with a later ID are
+// told to resync.
+type MemoryEventStore struct {
+	mu       sync.Mutex
+	size     int
+	seq      uint64
+	channels map[string]*eventRing
+}
+
+type eventRing struct {
+	events  []SSEMessage
+	start   int
+	evicted uint64
+}
+
+func NewMemoryEventStore(size int) *MemoryEventStore {
+	if size <= 0 {
+		size = DefaultReplaySize
+	}
+	return &MemoryEventStore{size: size, channels: make(map[string]*eventRing)}
+}
+
+func (s *MemoryEventStore) Append(_ context.Context, msg SSEMessage) (SSEMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	msg.ID = s.seq
+	ring, ok := s.channels[msg.Channel]
+	if !ok {
+		ring = &eventRing{}
+		s.channels[msg.Channel] = ring
+	}
+	if len(ring.events) < s.size {
+		ring.events = append(ring.events, msg)
+		return msg, nil
+	}
+	ring.evicted = ring.events[ring.start].ID
+	ring.events[ring.start] = msg
+	ring.start = (ring.start + 1) % len(ring.events)
+	return msg, nil
+}
+
+func (s *MemoryEventStore) Shared() bool {
+	return false
+}
+
+func (s *MemoryEventStore) Since(_ context.Context, channel string, after uint64, limit int) ([]SSEMessage, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if after > s.seq {
+		return nil, false, nil
+	}
+	ring, ok := s.channels[channel]
+	if !ok {
+		return nil, true, nil
+	}
+	if after < ring.evicted {
+		return nil, false, nil
+	}
+	var events []SSEMessage
+	for i := range ring.events {
+		msg := ring.events[(ring.start+i)%len(ring.events)]
+		if msg.ID <= after {
+			continue
+		}
+		if len(events) == limit {
+			return nil, false, nil
+		}
+		events = append(events, msg)
+	}
+	return events, true, nil
+}
@@ -0,0 +1,139 @@
+package templates
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	DefaultLocale						= "en"
+	DefaultPrimaryColor			= "#333333"
+	DefaultAccentColor			= "#333333"
+	DefaultTenantName				= "Slotter"
+)
+
+// Branding is resolved per tenant. Tenant* fields come from the owning Wms
+// (or the Company when it has no Wms) so client companies share a look.
+type Branding struct {
+	Logo						string		`json:"logo"`
+	TenantName			string		`json:"tenantName"`
+	TenantLogoURL		string		`json:"tenantLogoURL"`
+	PrimaryColor		string		`json:"primaryColor"`
+	AccentColor			string		`json:"accentColor"`
+}
+
+func (b Branding) withDefaults() Branding {
+	if strings.TrimSpace(b.TenantName) == "" {
+		b.TenantName = DefaultTenantName
+	}
+	if strings.TrimSpace(b.PrimaryColor) == "" {
+		b.PrimaryColor = DefaultPrimaryColor
+	}
+	if strings.TrimSpace(b.AccentColor) == "" {
+		b.AccentColor = DefaultAccentColor
+	}
+	return b
+}
+
+type InvitationDetails struct {
+	InvitationType		InvitationType
+	WmsName						string
+	CompanyName				string
+	AvatarURL					string
+	Message						string
+}
+
+type LockoutDetails struct {
+	FailedAttempts		int
+	LockedUntil				time.Time
+}
+
+type DigestItem struct {
+	Label			string
+	Count			int
+}
+
+type DigestDetails struct {
+	PeriodLabel			string
+	Items						[]DigestItem
+}
+
+// MessageData is the single payload every template renders against; only the
+// details block matching the message type needs to be filled.
+type MessageData struct {
+	Brand						Branding
+	RecipientName		string
+	ActionLink			string
+	ExpiresInHours	int
+	Year						int
+	Invitation			InvitationDetails
+	Lockout					LockoutDetails
+	Digest					DigestDetails
+}
+
+// SampleData returns representative data used by the admin preview endpoint.
+func SampleData(msgType MessageType, brand Branding) MessageData {
+	data := MessageData{
+		Brand:          brand,
+		RecipientName:  "Alex Example",
+		ActionLink:     "https://www.slotter.ai/example",
+		ExpiresInHours: 48,
+		Year:           time.Now().Year(),
+	}
+	switch msgType {
+	case MessageTypeInvitation:
+		data.Invitation = InvitationDetails{
+			InvitationType: InvitationTypeJoinCompany,
+			WmsName:        brand.TenantName,
+			CompanyName:    "Example Logistics",
+			AvatarURL:      brand.TenantLogoURL,
+			Message:        "Looking forward to working with you!",
+		}
+	case MessageTypePasswordReset:
+		data.ExpiresInHours = 1
+	case MessageTypeLockout:
+		data.Lockout = LockoutDetails{
+			FailedAttempts: 5,
+			LockedUntil:    time.Now().Add(30 * time.Minute),
+		}
+	case MessageTypeDigest:
+		data.Digest = DigestDetails{
+			PeriodLabel: "this week",
+			Items: []DigestItem{
+				{Label: "New users", Count: 3},
+				{Label: "Pending invitations", Count: 2},
+				{Label: "Warehouses created", Count: 1},
+			},
+		}
+	}
+	return data
+}
+
+// ResolveLocale returns the first non-empty candidate, normalized, or the
+// default locale. Callers pass the most specific preference first
+// (user, then company, then wms).
+func ResolveLocale(candidates ...string) string {
+	for _, c := range candidates {
+		if n := normalizeLocale(c); n != "" {
+			return n
+		}
+	}
+	return DefaultLocale
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(locale)), "_", "-")
+}
+
+// localeFallbacks turns "es-MX" into ["es-mx", "es"].
+func localeFallbacks(locale string) []string {
+	n := normalizeLocale(locale)
+	if n == "" {
+		return nil
+	}
+	out := []string{n}
+	if idx := strings.Index(n, "-"); idx > 0 {
+		out = append(out, n[:idx])
+	}
+	return out
+}
@@ -0,0 +1,74 @@
+package templates
+
+var digestTemplates = []MessageTemplate{
+	{
+		Type:    MessageTypeDigest,
+		Locale:  "en",
+		Subject: `Your {{.Brand.TenantName}} activity digest`,
+		HTML: `
+{{define "title"}}Activity Digest{{end}}
+{{define "body"}}
+  {{if .RecipientName}}
+    <p>Hi <span class="highlight">{{.RecipientName}}</span>,</p>
+  {{else}}
+    <p>Hello,</p>
+  {{end}}
+
+  <p>Here's what happened in <span class="highlight">{{.Brand.TenantName}}</span>
+     {{if .Digest.PeriodLabel}}{{.Digest.PeriodLabel}}{{else}}recently{{end}}:</p>
+  {{if .Digest.Items}}
+  <ul>
+    {{range .Digest.Items}}<li>{{.Label}}: <span class="highlight">{{.Count}}</span></li>{{end}}
+  </ul>
+  {{else}}
+  <p>No new activity.</p>
+  {{end}}
+
+  {{if .ActionLink}}
+  <div class="button-container">
+    <a class="cta-button" href="{{.ActionLink}}">Open Slotter</a>
+  </div>
+  {{end}}
+{{end}}
+{{define "footer"}}<p>&copy; {{.Year}} Slotter Inc. All rights reserved.</p>{{end}}
+`,
+		Text: `{{.Brand.TenantName}} activity {{if .Digest.PeriodLabel}}{{.Digest.PeriodLabel}}{{else}}recently{{end}}:{{range .Digest.Items}}
+- {{.Label}}: {{.Count}}{{end}}`,
+		SMS:  `Slotter digest for {{.Brand.TenantName}}:{{range .Digest.Items}} {{.Label}} {{.Count}};{{end}}`,
+	},
+	{
+		Type:    MessageTypeDigest,
+		Locale:  "es",
+		Subject: `Tu resumen de actividad de {{.Brand.TenantName}}`,
+		HTML: `
+{{define "title"}}Resumen de actividad{{end}}
+{{define "body"}}
+  {{if .RecipientName}}
+    <p>Hola <span class="highlight">{{.RecipientName}}</span>,</p>
+  {{else}}
+    <p>Hola,</p>
+  {{end}}
+
+  <p>Esto es lo que pasó en <span class="highlight">{{.Brand.TenantName}}</span>
+     {{if .Digest.PeriodLabel}}{{.Digest.PeriodLabel}}{{else}}recientemente{{end}}:</p>
+  {{if .Digest.Items}}
+  <ul>
+    {{range .Digest.Items}}<li>{{.Label}}: <span class="highlight">{{.Count}}</span></li>{{end}}
+  </ul>
+  {{else}}
+  <p>No hay actividad nueva.</p>
+  {{end}}
+
+  {{if .ActionLink}}
+  <div class="button-container">
+    <a class="cta-button" href="{{.ActionLink}}">Abrir Slotter</a>
+  </div>
+  {{end}}
+{{end}}
+{{define "footer"}}<p>&copy; {{.Year}} Slotter Inc. Todos los derechos reservados.</p>{{end}}
+`,
+		Text: `Actividad de {{.Brand.TenantName}} {{if .Digest.PeriodLabel}}{{.Digest.PeriodLabel}}{{else}}recientemente{{end}}:{{range .Digest.Items}}
+- {{.Label}}: {{.Count}}{{end}}`,
+		SMS:  `Resumen de Slotter para {{.Brand.TenantName}}:{{range .Digest.Items}} {{.Label}} {{.Count}};{{end}}`,
+	},
+}
@@ -0,0 +1,105 @@
+package templates
+
+// layoutHTML is shared by every message type. Each locale variant supplies the
+// "title", "body" and "footer" blocks; colors and logos come from .Brand.
+const layoutHTML = `{{define "layout"}}
+<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="UTF-8"/>
+  <title>{{template "title" .}}</title>
+  <style>
+    body {
+      margin: 0;
+      padding: 0;
+      font-family: Arial, sans-serif;
+      background-color: #f5f5f5;
+      color: #333;
+    }
+    .email-container {
+      width: 100%;
+      max-width: 600px;
+      margin: 0 auto;
+      background-color: #ffffff;
+      border-radius: 6px;
+      overflow: hidden;
+      box-shadow: 0 2px 5px rgba(0,0,0,0.1);
+    }
+    .header {
+      background-color: {{.Brand.PrimaryColor}};
+      padding: 20px;
+      text-align: center;
+      color: #fff;
+    }
+    .header img {
+      width: 120px;
+      height: auto;
+      margin-bottom: 10px;
+    }
+    .header h1 {
+      margin: 10px 0 0;
+      font-size: 24px;
+    }
+    .content {
+      padding: 20px;
+      text-align: left;
+    }
+    .avatar-container {
+      text-align: center;
+      margin: 10px 0 20px;
+    }
+    .avatar-container img {
+      width: 60px;
+      height: 60px;
+      border-radius: 50%;
+    }
+    .button-container {
+      text-align: center;
+      margin: 20px 0;
+    }
+    .cta-button {
+      display: inline-block;
+      padding: 12px 24px;
+      background-color: {{.Brand.AccentColor}};
+      color: #ffffff;
+      text-decoration: none;
+      border-radius: 4px;
+      font-weight: bold;
+    }
+    .footer {
+      font-size: 12px;
+      color: #999;
+      text-align: center;
+      padding: 10px 20px;
+    }
+    .highlight {
+      font-weight: bold;
+      color: #333;
+    }
+  </style>
+</head>
+<body>
+  <table class="email-container" role="presentation" cellspacing="0" cellpadding="0">
+    <tr>
+      <td>
+        <div class="header">
+          {{if .Brand.Logo}}<img src="{{logoURL .Brand.Logo}}" alt="Slotter Brand Logo" />{{end}}
+          <h1>{{template "title" .}}</h1>
+        </div>
+        <div class="content">
+          {{if .Brand.TenantLogoURL}}
+          <div class="avatar-container">
+            <img src="{{.Brand.TenantLogoURL}}" alt="{{.Brand.TenantName}}" />
+          </div>
+          {{end}}
+          {{template "body" .}}
+        </div>
+        <div class="footer">
+          {{template "footer" .}}
+        </div>
+      </td>
+    </tr>
+  </table>
+</body>
+</html>
+{{end}}`
@@ -0,0 +1,66 @@
+package templates
+
+var lockoutTemplates = []MessageTemplate{
+	{
+		Type:    MessageTypeLockout,
+		Locale:  "en",
+		Subject: `Your Slotter account has been locked`,
+		HTML: `
+{{define "title"}}Account Locked{{end}}
+{{define "body"}}
+  {{if .RecipientName}}
+    <p>Hi <span class="highlight">{{.RecipientName}}</span>,</p>
+  {{else}}
+    <p>Hello,</p>
+  {{end}}
+
+  <p>Your <span class="highlight">{{.Brand.TenantName}}</span> account was locked after
+     {{.Lockout.FailedAttempts}} failed sign-in attempts.</p>
+  {{if not .Lockout.LockedUntil.IsZero}}
+    <p>You can try again after {{.Lockout.LockedUntil.Format "Jan 2, 2006 15:04 MST"}}.</p>
+  {{end}}
+
+  {{if .ActionLink}}
+  <p>If this wasn't you, reset your password now.</p>
+  <div class="button-container">
+    <a class="cta-button" href="{{.ActionLink}}">Reset Password</a>
+  </div>
+  {{end}}
+{{end}}
+{{define "footer"}}<p>&copy; {{.Year}} Slotter Inc. All rights reserved.</p>{{end}}
+`,
+		Text: `Your Slotter account for {{.Brand.TenantName}} was locked after {{.Lockout.FailedAttempts}} failed sign-in attempts.{{if .ActionLink}} If this wasn't you, reset your password: {{.ActionLink}}{{end}}`,
+		SMS:  `Slotter: your account was locked after {{.Lockout.FailedAttempts}} failed sign-in attempts.`,
+	},
+	{
+		Type:    MessageTypeLockout,
+		Locale:  "es",
+		Subject: `Tu cuenta de Slotter ha sido bloqueada`,
+		HTML: `
+{{define "title"}}Cuenta bloqueada{{end}}
+{{define "body"}}
+  {{if .RecipientName}}
+    <p>Hola <span class="highlight">{{.RecipientName}}</span>,</p>
+  {{else}}
+    <p>Hola,</p>
+  {{end}}
+
+  <p>Tu cuenta de <span class="highlight">{{.Brand.TenantName}}</span> fue bloqueada después de
+     {{.Lockout.FailedAttempts}} intentos fallidos de inicio de sesión.</p>
+  {{if not .Lockout.LockedUntil.IsZero}}
+    <p>Podrás intentarlo de nuevo después de {{.Lockout.LockedUntil.Format "02/01/2006 15:04 MST"}}.</p>
+  {{end}}
+
+  {{if .ActionLink}}
+  <p>Si no fuiste tú, restablece tu contraseña ahora.</p>
+  <div class="button-container">
+    <a class="cta-button" href="{{.ActionLink}}">Restablecer contraseña</a>
+  </div>
+  {{end}}
+{{end}}
+{{define "footer"}}<p>&copy; {{.Year}} Slotter Inc. Todos los derechos reservados.</p>{{end}}
+`,
+		Text: `Tu cuenta de Slotter para {{.Brand.TenantName}} fue bloqueada después de {{.Lockout.FailedAttempts}} intentos fallidos.{{if .ActionLink}} Si no fuiste tú, restablece tu contraseña: {{.ActionLink}}{{end}}`,
+		SMS:  `Slotter: tu cuenta fue bloqueada después de {{.Lockout.FailedAttempts}} intentos fallidos.`,
+	},
+}
@@ -0,0 +1,58 @@
+package templates
+
+var passwordResetTemplates = []MessageTemplate{
+	{
+		Type:    MessageTypePasswordReset,
+		Locale:  "en",
+		Subject: `Reset your Slotter password`,
+		HTML: `
+{{define "title"}}Password Reset{{end}}
+{{define "body"}}
+  {{if .RecipientName}}
+    <p>Hi <span class="highlight">{{.RecipientName}}</span>,</p>
+  {{else}}
+    <p>Hello,</p>
+  {{end}}
+
+  <p>We received a request to reset the password for your
+     <span class="highlight">{{.Brand.TenantName}}</span> account.</p>
+
+  <div class="button-container">
+    <a class="cta-button" href="{{.ActionLink}}">Reset Password</a>
+  </div>
+  {{if .ExpiresInHours}}<p>This link expires in {{.ExpiresInHours}} hour(s).</p>{{end}}
+  <p>If you didn't request this, you can safely ignore this email.</p>
+{{end}}
+{{define "footer"}}<p>&copy; {{.Year}} Slotter Inc. All rights reserved.</p>{{end}}
+`,
+		Text: `Reset your Slotter password for {{.Brand.TenantName}}: {{.ActionLink}}{{if .ExpiresInHours}} (expires in {{.ExpiresInHours}} hour(s)){{end}}`,
+		SMS:  `Slotter password reset: {{.ActionLink}}`,
+	},
+	{
+		Type:    MessageTypePasswordReset,
+		Locale:  "es",
+		Subject: `Restablece tu contraseña de Slotter`,
+		HTML: `
+{{define "title"}}Restablecer contraseña{{end}}
+{{define "body"}}
+  {{if .RecipientName}}
+    <p>Hola <span class="highlight">{{.RecipientName}}</span>,</p>
+  {{else}}
+    <p>Hola,</p>
+  {{end}}
+
+  <p>Recibimos una solicitud para restablecer la contraseña de tu cuenta de
+     <span class="highlight">{{.Brand.TenantName}}</span>.</p>
+
+  <div class="button-container">
+    <a class="cta-button" href="{{.ActionLink}}">Restablecer contraseña</a>
+  </div>
+  {{if .ExpiresInHours}}<p>Este enlace vence en {{.ExpiresInHours}} hora(s).</p>{{end}}
+  <p>Si no solicitaste esto, puedes ignorar este correo.</p>
+{{end}}
+{{define "footer"}}<p>&copy; {{.Year}} Slotter Inc. Todos los derechos reservados.</p>{{end}}
+`,
+		Text: `Restablece tu contraseña de Slotter para {{.Brand.TenantName}}: {{.ActionLink}}{{if .ExpiresInHours}} (vence en {{.ExpiresInHours}} hora(s)){{end}}`,
+		SMS:  `Restablecer contraseña de Slotter: {{.ActionLink}}`,
+	},
+}
@@ -0,0 +1,197 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"sort"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+type MessageType string
+
+const (
+	MessageTypeInvitation			MessageType = "invitation"
+	MessageTypePasswordReset	MessageType = "password_reset"
+	MessageTypeLockout				MessageType = "lockout"
+	MessageTypeDigest					MessageType = "digest"
+)
+
+// MessageTemplate is a single (type, locale) variant. HTML only needs to define
+// the "title" and "body" blocks; the shared branded layout wraps it.
+type MessageTemplate struct {
+	Type			MessageType
+	Locale		string
+	Subject		string
+	HTML			string
+	Text			string
+	SMS				string
+}
+
+type RenderedMessage struct {
+	Type			MessageType		`json:"type"`
+	Locale		string				`json:"locale"`
+	Subject		string				`json:"subject"`
+	HTML			string				`json:"html"`
+	Text			string				`json:"text"`
+	SMS				string				`json:"sms"`
+}
+
+type compiledTemplate struct {
+	subject		*texttemplate.Template
+	html			*htmltemplate.Template
+	text			*texttemplate.Template
+	sms				*texttemplate.Template
+}
+
+type Registry struct {
+	mu							sync.RWMutex
+	defaultLocale		string
+	templates				map[MessageType]map[string]*compiledTemplate
+}
+
+// NewRegistry returns a registry pre-loaded with every built-in message type
+// and locale variant.
+func NewRegistry() (*Registry, error) {
+	r := &Registry{
+		defaultLocale: DefaultLocale,
+		templates:     make(map[MessageType]map[string]*compiledTemplate),
+	}
+	var builtIns []MessageTemplate
+	builtIns = append(builtIns, invitationTemplates...)
+	builtIns = append(builtIns, passwordResetTemplates...)
+	builtIns = append(builtIns, lockoutTemplates...)
+	builtIns = append(builtIns, digestTemplates...)
+	for _, t := range builtIns {
+		if err := r.Register(t); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *Registry) Register(t MessageTemplate) error {
+	if t.Type == "" {
+		return fmt.Errorf("template type is required")
+	}
+	locale := normalizeLocale(t.Locale)
+	if locale == "" {
+		return fmt.Errorf("template locale is required for %s", t.Type)
+	}
+	name := string(t.Type) + "." + locale
+	subject, err := texttemplate.New(name + ".subject").Parse(t.Subject)
+	if err != nil {
+		return fmt.Errorf("failed to parse subject for %s: %w", name, err)
+	}
+	html, err := htmltemplate.New(name + ".html").Funcs(htmlFuncs).Parse(layoutHTML)
+	if err != nil {
+		return fmt.Errorf("failed to parse layout for %s: %w", name, err)
+	}
+	if _, err := html.Parse(t.HTML); err != nil {
+		return fmt.Errorf("failed to parse html for %s: %w", name, err)
+	}
+	text, err := texttemplate.New(name + ".text").Parse(t.Text)
+	if err != nil {
+		return fmt.Errorf("failed to parse text for %s: %w", name, err)
+	}
+	sms, err := texttemplate.New(name + ".sms").Parse(t.SMS)
+	if err != nil {
+		return fmt.Errorf("failed to parse sms for %s: %w", name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.templates[t.Type] == nil {
+		r.templates[t.Type] = make(map[string]*compiledTemplate)
+	}
+	r.templates[t.Type][locale] = &compiledTemplate{subject: subject, html: html, text: text, sms: sms}
+	return nil
+}
+
+// Render picks the best locale variant for msgType (falling back to the
+// registry default) and executes every part of it against data.
+func (r *Registry) Render(msgType MessageType, locale string, data MessageData) (*RenderedMessage, error) {
+	r.mu.RLock()
+	variants, ok := r.templates[msgType]
+	r.mu.RUnlock()
+	if !ok || len(variants) == 0 {
+		return nil, fmt.Errorf("no templates registered for message type %q", msgType)
+	}
+	resolved := r.resolveLocale(variants, locale)
+	tpl := variants[resolved]
+	data.Brand = data.Brand.withDefaults()
+
+	var subject, html, text, sms bytes.Buffer
+	if err := tpl.subject.Execute(&subject, data); err != nil {
+		return nil, fmt.Errorf("failed to render subject: %w", err)
+	}
+	if err := tpl.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return nil, fmt.Errorf("failed to render html: %w", err)
+	}
+	if err := tpl.text.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("failed to render text: %w", err)
+	}
+	if err := tpl.sms.Execute(&sms, data); err != nil {
+		return nil, fmt.Errorf("failed to render sms: %w", err)
+	}
+	return &RenderedMessage{
+		Type:    msgType,
+		Locale:  resolved,
+		Subject: strings.TrimSpace(subject.String()),
+		HTML:    html.String(),
+		Text:    strings.TrimSpace(text.String()),
+		SMS:     strings.TrimSpace(sms.String()),
+	}, nil
+}
+
+func (r *Registry) Types() []MessageType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]MessageType, 0, len(r.templates))
+	for t := range r.templates {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+func (r *Registry) Locales(msgType MessageType) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]string, 0, len(r.templates[msgType]))
+	for l := range r.templates[msgType] {
+		out = append(out, l)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (r *Registry) resolveLocale(variants map[string]*compiledTemplate, locale string) string {
+	for _, candidate := range localeFallbacks(locale) {
+		if _, ok := variants[candidate]; ok {
+			return candidate
+		}
+	}
+	if _, ok := variants[r.defaultLocale]; ok {
+		return r.defaultLocale
+	}
+	keys := make([]string, 0, len(variants))
+	for k := range variants {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys[0]
+}
+
+var htmlFuncs = htmltemplate.FuncMap{
+	// logoURL trusts only an inline base64 image, which html/template would
+	// otherwise replace; any other URL is sanitized as usual.
+	"logoURL": func(s string) any {
+		if strings.HasPrefix(s, "data:image/") && strings.Contains(s, ";base64,") {
+			return htmltemplate.URL(s)
+		}
+		return s
+	},
+}
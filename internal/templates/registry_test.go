@@ -0,0 +1,196 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+)
+
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+	r, err := NewRegistry()
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	return r
+}
+
+// TestRenderEveryTemplate renders each built-in type and locale with the
+// preview's sample data.
+func TestRenderEveryTemplate(t *testing.T) {
+	r := newTestRegistry(t)
+	brand := Branding{TenantName: "Acme Fulfillment", TenantLogoURL: "https://cdn.example.com/acme.png", PrimaryColor: "#112233"}
+	for _, msgType := range r.Types() {
+		for _, locale := range r.Locales(msgType) {
+			t.Run(string(msgType)+"."+locale, func(t *testing.T) {
+				msg, err := r.Render(msgType, locale, SampleData(msgType, brand))
+				if err != nil {
+					t.Fatalf("Render: %v", err)
+				}
+				if msg.Locale != locale {
+					t.Errorf("locale = %q, want %q", msg.Locale, locale)
+				}
+				if msg.Subject == "" || msg.Text == "" || msg.SMS == "" {
+					t.Errorf("empty part: subject %q, text %q, sms %q", msg.Subject, msg.Text, msg.SMS)
+				}
+				for _, want := range []string{brand.TenantName, brand.TenantLogoURL, brand.PrimaryColor, "<h1>"} {
+					if !strings.Contains(msg.HTML, want) {
+						t.Errorf("html does not contain %q", want)
+					}
+				}
+				if strings.Contains(msg.HTML, "<no value>") || strings.Contains(msg.Text, "<no value>") {
+					t.Error("rendered a missing field")
+				}
+			})
+		}
+	}
+}
+
+// TestLocaleFallback resolves regional and unknown locales to the nearest
+// registered variant.
+func TestLocaleFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		register string
+		locale   string
+		want     string
+	}{
+		{name: "exact", locale: "es", want: "es"},
+		{name: "region falls back to language", locale: "es-MX", want: "es"},
+		{name: "underscore region", locale: "es_mx", want: "es"},
+		{name: "registered region wins", register: "es-MX", locale: "es-mx", want: "es-mx"},
+		{name: "other region of a registered region", register: "es-MX", locale: "es-AR", want: "es"},
+		{name: "unknown language falls back to default", locale: "fr-CA", want: "en"},
+		{name: "empty falls back to default", locale: "", want: "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRegistry(t)
+			if tt.register != "" {
+				err := r.Register(MessageTemplate{
+					Type:    MessageTypeInvitation,
+					Locale:  tt.register,
+					Subject: `Invitación de {{.Brand.TenantName}}`,
+					HTML:    `{{define "title"}}Hola{{end}}{{define "body"}}<p>Hola</p>{{end}}{{define "footer"}}{{end}}`,
+					Text:    `Hola`,
+					SMS:     `Hola`,
+				})
+				if err != nil {
+					t.Fatalf("Register: %v", err)
+				}
+			}
+			msg, err := r.Render(MessageTypeInvitation, tt.locale, SampleData(MessageTypeInvitation, Branding{}))
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if msg.Locale != tt.want {
+				t.Errorf("locale = %q, want %q", msg.Locale, tt.want)
+			}
+		})
+	}
+}
+
+// TestRenderBranding renders a Wms's branding, which carries its colors, and
+// a standalone company's, which takes the defaults.
+func TestRenderBranding(t *testing.T) {
+	tests := []struct {
+		name       string
+		brand      Branding
+		wantName   string
+		wantColors []string
+	}{
+		{
+			name:       "wms",
+			brand:      Branding{TenantName: "Acme WMS", TenantLogoURL: "https://cdn.example.com/wms.png", PrimaryColor: "#0a0b0c", AccentColor: "#d0e0f0"},
+			wantName:   "Acme WMS",
+			wantColors: []string{"#0a0b0c", "#d0e0f0"},
+		},
+		{
+			name:       "company",
+			brand:      Branding{TenantName: "Initech", TenantLogoURL: "https://cdn.example.com/initech.png"},
+			wantName:   "Initech",
+			wantColors: []string{DefaultPrimaryColor, DefaultAccentColor},
+		},
+		{
+			name:       "no tenant",
+			wantName:   DefaultTenantName,
+			wantColors: []string{DefaultPrimaryColor},
+		},
+	}
+	r := newTestRegistry(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := r.Render(MessageTypePasswordReset, "en", SampleData(MessageTypePasswordReset, tt.brand))
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			for _, want := range append([]string{tt.wantName}, tt.wantColors...) {
+				if !strings.Contains(msg.HTML, want) {
+					t.Errorf("html does not contain %q", want)
+				}
+			}
+		})
+	}
+}
+
+// TestLogoURLEscaping checks only the inline brand logo bypasses URL
+// sanitizing; tenant-controlled URLs with unsafe schemes are replaced.
+func TestLogoURLEscaping(t *testing.T) {
+	const dataLogo = "data:image/png;base64,iVBORw0KGgo="
+	tests := []struct {
+		name      string
+		brand     Branding
+		avatarURL string
+		want      []string
+		wantNot   []string
+	}{
+		{
+			name:  "inline brand logo kept",
+			brand: Branding{Logo: dataLogo},
+			want:  []string{`src="` + dataLogo + `"`},
+		},
+		{
+			name:  "https urls kept",
+			brand: Branding{Logo: "https://slotter.ai/slotter-logo.png", TenantLogoURL: "https://cdn.example.com/acme.png"},
+			want:  []string{"https://slotter.ai/slotter-logo.png", "https://cdn.example.com/acme.png"},
+		},
+		{
+			name:    "javascript tenant logo replaced",
+			brand:   Branding{TenantLogoURL: "javascript:alert(1)"},
+			want:    []string{"#ZgotmplZ"},
+			wantNot: []string{"javascript:"},
+		},
+		{
+			name:      "javascript invitation avatar replaced",
+			avatarURL: "javascript:alert(1)",
+			want:      []string{"#ZgotmplZ"},
+			wantNot:   []string{"javascript:"},
+		},
+		{
+			name:    "non-image data url as brand logo replaced",
+			brand:   Branding{Logo: "data:text/html;base64,PHNjcmlwdD4="},
+			want:    []string{"#ZgotmplZ"},
+			wantNot: []string{"data:text/html"},
+		},
+	}
+	r := newTestRegistry(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := SampleData(MessageTypeInvitation, tt.brand)
+			data.Invitation.AvatarURL = tt.avatarURL
+			msg, err := r.Render(MessageTypeInvitation, "en", data)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(msg.HTML, want) {
+					t.Errorf("html does not contain %q", want)
+				}
+			}
+			for _, not := range tt.wantNot {
+				if strings.Contains(msg.HTML, not) {
+					t.Errorf("html contains %q", not)
+				}
+			}
+		})
+	}
+}
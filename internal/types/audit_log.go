@@ -0,0 +1,32 @@
+package types
+
+import (
+  "time"
+
+  "gorm.io/gorm"
+  "github.com/google/uuid"
+)
+
+// AuditLogEntry is one domain event as it was published, kept so a tenant
+// can see who changed what. EventID is the event envelope's id, which makes
+// recording an event twice a no-op.
+type AuditLogEntry struct {
+  gorm.Model
+  ID                  uuid.UUID             `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  EventID             uuid.UUID             `gorm:"type:uuid;column:event_id;not null;uniqueIndex" json:"eventID"`
+  WmsID               *uuid.UUID            `gorm:"type:uuid;index" json:"wmsID,omitempty"`
+  CompanyID           *uuid.UUID            `gorm:"type:uuid;index" json:"companyID,omitempty"`
+  ActorID             *uuid.UUID            `gorm:"type:uuid;index" json:"actorID,omitempty"`
+
+  EventType           string                `gorm:"column:event_type;type:varchar(64);not null;index" json:"eventType"`
+  Version             int                   `gorm:"column:version;not null;default:1" json:"version"`
+  Data                any                   `gorm:"column:data;type:jsonb;serializer:json" json:"data,omitempty"`
+  OccurredAt          time.Time             `gorm:"column:occurred_at;not null;index" json:"occurredAt"`
+
+  CreatedAt           time.Time             `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time             `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (AuditLogEntry) TableName() string {
+  return "audit_log_entry"
+}
@@ -0,0 +1,30 @@
+package types
+
+import (
+  "time"
+
+  "gorm.io/gorm"
+  "github.com/google/uuid"
+)
+
+// ChatRetentionPolicy is how long a company's or WMS's chat sessions are
+// kept after their last message. Exactly one of CompanyID and WmsID is set.
+// RetentionDays 0 keeps sessions forever.
+type ChatRetentionPolicy struct {
+  gorm.Model
+  ID                  uuid.UUID             `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  CompanyID           *uuid.UUID            `gorm:"type:uuid;uniqueIndex" json:"companyID,omitempty"`
+  WmsID               *uuid.UUID            `gorm:"type:uuid;uniqueIndex" json:"wmsID,omitempty"`
+  UpdatedByID         *uuid.UUID            `gorm:"type:uuid" json:"updatedByID,omitempty"`
+
+  RetentionDays       int                   `gorm:"column:retention_days;not null;default:0" json:"retentionDays"`
+  LastPurgedAt        *time.Time            `gorm:"column:last_purged_at" json:"lastPurgedAt,omitempty"`
+  LastPurgedCount     int                   `gorm:"column:last_purged_count;not null;default:0" json:"lastPurgedCount"`
+
+  CreatedAt           time.Time             `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time             `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (ChatRetentionPolicy) TableName() string {
+  return "chat_retention_policy"
+}
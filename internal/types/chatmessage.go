@@ -7,16 +7,38 @@ import (
   "github.com/google/uuid"
 )
 
+const (
+  ChatRoleUser        = "user"
+  ChatRoleAssistant   = "assistant"
+  ChatRoleTool        = "tool"
+)
+
+// ChatToolCall is a read-only tool the assistant asked to run. Arguments is
+// the JSON object it was called with.
+type ChatToolCall struct {
+  ID          string            `json:"id"`
+  Name        string            `json:"name"`
+  Arguments   string            `json:"arguments"`
+}
+
+// ChatMessage is one turn of a ChatSession, in Sequence order. UserID is set on the user's own
+// messages. An assistant turn may carry ToolCalls instead of, or besides,
+// Content; each is answered by a tool message with the matching ToolCallID
+// and the JSON result (or error) as Content.
 type ChatMessage struct {
   gorm.Model
 
-  ID          uuid.UUID       `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
-  SessionID   uuid.UUID       `gorm:"index"`
-  UserID      *uuid.UUID      `gorm:"index;null"`
-  Role        string          `gorm:"column:role"`
-  Content     string          `gorm:"column:content"`
-  CreatedAt   time.Time       `gorm:"not null;default:now()"`
-  UpdatedAt   time.Time       `gorm:"not null;default:now()"`
+  ID          uuid.UUID       `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  SessionID   uuid.UUID       `gorm:"index;index:idx_chat_message_session_sequence" json:"sessionID"`
+  Sequence    int             `gorm:"column:sequence;index:idx_chat_message_session_sequence" json:"sequence"`
+  UserID      *uuid.UUID      `gorm:"index;null" json:"userID,omitempty"`
+  Role        string          `gorm:"column:role" json:"role"`
+  Content     string          `gorm:"column:content" json:"content"`
+  ToolCalls   []ChatToolCall  `gorm:"column:tool_calls;type:jsonb;serializer:json" json:"toolCalls,omitempty"`
+  ToolCallID  string          `gorm:"column:tool_call_id" json:"toolCallID,omitempty"`
+  IsError     bool            `gorm:"column:is_error" json:"isError,omitempty"`
+  CreatedAt   time.Time       `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt   time.Time       `gorm:"not null;default:now()" json:"updatedAt"`
 }
 
 func (ChatMessage) TableName() string {
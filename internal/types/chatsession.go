@@ -7,16 +7,64 @@ import (
   "github.com/google/uuid"
 )
 
+// ChatVisibility decides who besides the owner may read a ChatSession:
+// nobody (private), every user of the owner's company or WMS (company), or
+// the users it is shared with (users).
+type ChatVisibility string
+
+const (
+  ChatVisibilityPrivate   ChatVisibility = "private"
+  ChatVisibilityCompany   ChatVisibility = "company"
+  ChatVisibilityUsers     ChatVisibility = "users"
+)
+
+// ChatSession is one conversation of a user with the slotting assistant.
+// TitleAuto is set while the title is generated rather than chosen by the
+// owner. Pinned is per viewer and filled in when sessions are listed.
 type ChatSession struct {
   gorm.Model
 
-  ID          uuid.UUID         `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
-  UserID      uuid.UUID         `gorm:"index"`
-  Title       string            `gorm:"column:title"`
-  CreatedAt   time.Time         `gorm:"not null;default:now()"`
-  UpdatedAt   time.Time         `gorm:"not null;default:now()"`
+  ID          uuid.UUID         `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  UserID      uuid.UUID         `gorm:"index" json:"userID"`
+  Title       string            `gorm:"column:title" json:"title"`
+  TitleAuto   bool              `gorm:"column:title_auto;not null;default:true" json:"titleAuto"`
+  Visibility  ChatVisibility    `gorm:"column:visibility;not null;default:'private';index" json:"visibility"`
+  Pinned      bool              `gorm:"-" json:"pinned"`
+  CreatedAt   time.Time         `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt   time.Time         `gorm:"not null;default:now()" json:"updatedAt"`
 }
 
 func (ChatSession) TableName() string {
   return "chat_session"
 }
+
+// ChatSessionShare gives one user read access to a session with
+// ChatVisibilityUsers.
+type ChatSessionShare struct {
+  gorm.Model
+
+  ID          uuid.UUID         `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  SessionID   uuid.UUID         `gorm:"type:uuid;not null;uniqueIndex:idx_chat_session_share_user" json:"sessionID"`
+  UserID      uuid.UUID         `gorm:"type:uuid;not null;index;uniqueIndex:idx_chat_session_share_user" json:"userID"`
+  CreatedAt   time.Time         `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt   time.Time         `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (ChatSessionShare) TableName() string {
+  return "chat_session_share"
+}
+
+// ChatSessionPin marks a session a user keeps at the top of their list.
+type ChatSessionPin struct {
+  gorm.Model
+
+  ID          uuid.UUID         `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  SessionID   uuid.UUID         `gorm:"type:uuid;not null;uniqueIndex:idx_chat_session_pin_user" json:"sessionID"`
+  UserID      uuid.UUID         `gorm:"type:uuid;not null;index;uniqueIndex:idx_chat_session_pin_user" json:"userID"`
+  CreatedAt   time.Time         `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt   time.Time         `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (ChatSessionPin) TableName() string {
+  return "chat_session_pin"
+}
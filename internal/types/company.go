@@ -19,6 +19,7 @@ type Company struct {
   Name                string                    `gorm:"column:name" json:"name"`
   AvatarBucketKey     string                    `gorm:"column:avatar_bucket_key" json:"avatarBucketKey"`
   AvatarURL           string                    `gorm:"column:avatar_url" json:"avatarURL"`
+  Locale              string                    `gorm:"column:locale" json:"locale,omitempty"`
 
   CreatedAt           time.Time                 `gorm:"not null;default:now()" json:"createdAt"`
   UpdatedAt           time.Time                 `gorm:"not null;default:now()" json:"updatedAt"`
@@ -0,0 +1,53 @@
+package types
+
+import (
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+)
+
+type FloorMapFormat string
+
+const (
+  FloorMapSVG         FloorMapFormat = "svg"
+  FloorMapPNG         FloorMapFormat = "png"
+)
+
+// FloorMapHeatmap is what a floor map colours its bays by. Scenario heatmaps
+// show pick velocity before or after a scenario's run.
+type FloorMapHeatmap string
+
+const (
+  FloorMapHeatmapNone       FloorMapHeatmap = "none"
+  FloorMapHeatmapVelocity   FloorMapHeatmap = "velocity"
+  FloorMapHeatmapCube       FloorMapHeatmap = "cube"
+  FloorMapHeatmapScenario   FloorMapHeatmap = "scenario"
+)
+
+// FloorMapRender is a rendered floor map stored in the bucket. Fingerprint
+// hashes everything drawn, so a render is reused until the layout or the
+// data behind its heatmap changes. Variant names what was asked for (format,
+// heatmap, scenario and side); only the newest render of a variant is kept.
+type FloorMapRender struct {
+  gorm.Model
+  ID                  uuid.UUID                 `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  WarehouseID         uuid.UUID                 `gorm:"type:uuid;not null;index;uniqueIndex:idx_floor_map_render_fingerprint" json:"warehouseID"`
+  CompanyID           uuid.UUID                 `gorm:"type:uuid;not null;index" json:"companyID"`
+  Fingerprint         string                    `gorm:"column:fingerprint;not null;uniqueIndex:idx_floor_map_render_fingerprint" json:"fingerprint"`
+  Variant             string                    `gorm:"column:variant;not null;index" json:"variant"`
+
+  Format              FloorMapFormat            `gorm:"column:format;not null" json:"format"`
+  Heatmap             FloorMapHeatmap           `gorm:"column:heatmap;not null" json:"heatmap"`
+  ScenarioID          *uuid.UUID                `gorm:"type:uuid" json:"scenarioID,omitempty"`
+  Side                string                    `gorm:"column:side" json:"side,omitempty"`
+  StorageKey          string                    `gorm:"column:storage_key;not null" json:"storageKey"`
+  SizeBytes           int                       `gorm:"column:size_bytes;not null" json:"sizeBytes"`
+
+  CreatedAt           time.Time                 `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time                 `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (FloorMapRender) TableName() string {
+  return "floor_map_render"
+}
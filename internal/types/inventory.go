@@ -0,0 +1,70 @@
+package types
+
+import (
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+)
+
+type InventoryTransactionType string
+
+const (
+  InventoryReceipt      InventoryTransactionType = "receipt"
+  InventoryMove         InventoryTransactionType = "move"
+  InventoryAdjustment   InventoryTransactionType = "adjustment"
+  InventoryPick         InventoryTransactionType = "pick"
+)
+
+// InventoryLedgerEntry is one append-only change of stock at a location, in
+// eaches. A move writes two entries (out of the source, into the target)
+// that share a GroupID.
+type InventoryLedgerEntry struct {
+  gorm.Model
+  ID                  uuid.UUID                   `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  GroupID             uuid.UUID                   `gorm:"type:uuid;not null;index" json:"groupID"`
+  WarehouseID         uuid.UUID                   `gorm:"type:uuid;not null;index:idx_inventory_ledger_warehouse_created" json:"warehouseID"`
+  CompanyID           uuid.UUID                   `gorm:"type:uuid;not null;index" json:"companyID"`
+  LocationID          uuid.UUID                   `gorm:"type:uuid;not null;index" json:"locationID"`
+  ItemID              uuid.UUID                   `gorm:"type:uuid;not null;index" json:"itemID"`
+  UserID              *uuid.UUID                  `gorm:"type:uuid" json:"userID,omitempty"`
+
+  Type                InventoryTransactionType    `gorm:"column:type;not null;index" json:"type"`
+  Lot                 string                      `gorm:"column:lot;not null;default:''" json:"lot,omitempty"`
+  ExpiresAt           *time.Time                  `gorm:"column:expires_at" json:"expiresAt,omitempty"`
+  QuantityDelta       int                         `gorm:"column:quantity_delta;not null" json:"quantityDelta"`
+  QuantityAfter       int                         `gorm:"column:quantity_after;not null" json:"quantityAfter"`
+  Reference           string                      `gorm:"column:reference" json:"reference,omitempty"`
+  Reason              string                      `gorm:"column:reason" json:"reason,omitempty"`
+
+  CreatedAt           time.Time                   `gorm:"not null;default:now();index:idx_inventory_ledger_warehouse_created" json:"createdAt"`
+  UpdatedAt           time.Time                   `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (InventoryLedgerEntry) TableName() string {
+  return "inventory_ledger"
+}
+
+// InventoryOnHand is the running total of the ledger per location, item and
+// lot. Rows are removed when they reach zero and can never go negative.
+type InventoryOnHand struct {
+  gorm.Model
+  ID                  uuid.UUID                   `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  WarehouseID         uuid.UUID                   `gorm:"type:uuid;not null;index" json:"warehouseID"`
+  CompanyID           uuid.UUID                   `gorm:"type:uuid;not null;index" json:"companyID"`
+  LocationID          uuid.UUID                   `gorm:"type:uuid;not null;uniqueIndex:idx_inventory_on_hand_bin" json:"locationID"`
+  Location            *WarehouseLocation          `gorm:"foreignKey:LocationID;references:ID" json:"location,omitempty"`
+  ItemID              uuid.UUID                   `gorm:"type:uuid;not null;index;uniqueIndex:idx_inventory_on_hand_bin" json:"itemID"`
+  Item                *Item                       `gorm:"foreignKey:ItemID;references:ID" json:"item,omitempty"`
+
+  Lot                 string                      `gorm:"column:lot;not null;default:'';uniqueIndex:idx_inventory_on_hand_bin" json:"lot,omitempty"`
+  ExpiresAt           *time.Time                  `gorm:"column:expires_at;index" json:"expiresAt,omitempty"`
+  Quantity            int                         `gorm:"column:quantity;not null;check:chk_inventory_on_hand_quantity,quantity >= 0" json:"quantity"`
+
+  CreatedAt           time.Time                   `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time                   `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (InventoryOnHand) TableName() string {
+  return "inventory_on_hand"
+}
@@ -44,6 +44,7 @@ type Invitation struct {
   Message             *string                   `gorm:"column:message" json:"message,omitempty"`
   Email               *string                   `gorm:"column:email" json:"email,omitempty"`
   PhoneNumber         *string                   `gorm:"column:phone_number" json:"phone_number,omitempty"`
+  Locale              *string                   `gorm:"column:locale" json:"locale,omitempty"`
   ExpiresAt           time.Time                 `gorm:"column:expires_at" json:"expires_at"`
   AvatarBucketKey     string                    `gorm:"column:avatar_bucket_key" json:"avatarBucketKey"`
   AvatarURL           string                    `gorm:"column:avatar_url" json:"avatarURL"`
@@ -0,0 +1,79 @@
+package types
+
+import (
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+)
+
+// UOM is a packaging level of an item, smallest first.
+type UOM string
+
+const (
+  UOMEach     UOM = "each"
+  UOMInner    UOM = "inner"
+  UOMCase     UOM = "case"
+  UOMPallet   UOM = "pallet"
+)
+
+// UOMs lists every unit of measure from smallest to largest.
+var UOMs = []UOM{UOMEach, UOMInner, UOMCase, UOMPallet}
+
+type TemperatureClass string
+
+const (
+  TemperatureAmbient    TemperatureClass = "ambient"
+  TemperatureChilled    TemperatureClass = "chilled"
+  TemperatureFrozen     TemperatureClass = "frozen"
+)
+
+// Item is an entry of a company's item master. SKU is unique per company.
+// HazmatClass holds the UN hazard class ("3", "8", "2.1"), empty when the
+// item is not dangerous goods.
+type Item struct {
+  gorm.Model
+  ID                  uuid.UUID                 `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  CompanyID           uuid.UUID                 `gorm:"type:uuid;not null;index;uniqueIndex:idx_item_company_sku" json:"companyID"`
+  Company             *Company                  `gorm:"constraint:OnDelete:CASCADE;foreignKey:CompanyID;references:ID" json:"company,omitempty"`
+
+  SKU                 string                    `gorm:"column:sku;not null;uniqueIndex:idx_item_company_sku" json:"sku"`
+  Description         string                    `gorm:"column:description" json:"description"`
+  HazmatClass         string                    `gorm:"column:hazmat_class" json:"hazmatClass,omitempty"`
+  TemperatureClass    TemperatureClass          `gorm:"column:temperature_class;not null" json:"temperatureClass"`
+  Stackable           bool                      `gorm:"column:stackable;not null" json:"stackable"`
+  MaxStackHeight      int                       `gorm:"column:max_stack_height" json:"maxStackHeight,omitempty"`
+  UOMs                []*ItemUOM                `gorm:"foreignKey:ItemID" json:"uoms"`
+
+  CreatedAt           time.Time                 `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time                 `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (Item) TableName() string {
+  return "item"
+}
+
+// ItemUOM is one packaging level of an item. Quantity is how many eaches it
+// holds (always 1 for each). GTIN is stored as a 14 digit GTIN so UPC-A,
+// EAN-13 and GTIN-14 scans of the same barcode all match.
+type ItemUOM struct {
+  gorm.Model
+  ID                  uuid.UUID                 `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  ItemID              uuid.UUID                 `gorm:"type:uuid;not null;index;uniqueIndex:idx_item_uom_item_uom" json:"itemID"`
+  CompanyID           uuid.UUID                 `gorm:"type:uuid;not null;index:idx_item_uom_company_gtin" json:"companyID"`
+
+  UOM                 UOM                       `gorm:"column:uom;not null;uniqueIndex:idx_item_uom_item_uom" json:"uom"`
+  Quantity            int                       `gorm:"column:quantity;not null;default:1" json:"quantity"`
+  GTIN                string                    `gorm:"column:gtin;index:idx_item_uom_company_gtin" json:"gtin,omitempty"`
+  LengthCm            float64                   `gorm:"column:length_cm" json:"lengthCm"`
+  WidthCm             float64                   `gorm:"column:width_cm" json:"widthCm"`
+  HeightCm            float64                   `gorm:"column:height_cm" json:"heightCm"`
+  WeightKg            float64                   `gorm:"column:weight_kg" json:"weightKg"`
+
+  CreatedAt           time.Time                 `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time                 `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (ItemUOM) TableName() string {
+  return "item_uom"
+}
@@ -0,0 +1,76 @@
+package types
+
+import (
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+)
+
+// MoveTaskStatus is where a move task is in its workflow:
+// open -> assigned -> claimed -> in_progress -> completed, with exception
+// reachable from any active state and canceled set by a supervisor.
+type MoveTaskStatus string
+
+const (
+  MoveTaskOpen          MoveTaskStatus = "open"
+  MoveTaskAssigned      MoveTaskStatus = "assigned"
+  MoveTaskClaimed       MoveTaskStatus = "claimed"
+  MoveTaskInProgress    MoveTaskStatus = "in_progress"
+  MoveTaskCompleted     MoveTaskStatus = "completed"
+  MoveTaskException     MoveTaskStatus = "exception"
+  MoveTaskCanceled      MoveTaskStatus = "canceled"
+)
+
+type MoveTaskExceptionReason string
+
+const (
+  MoveTaskExceptionShortPick        MoveTaskExceptionReason = "short_pick"
+  MoveTaskExceptionItemMissing      MoveTaskExceptionReason = "item_missing"
+  MoveTaskExceptionLocationBlocked  MoveTaskExceptionReason = "location_blocked"
+  MoveTaskExceptionDamaged          MoveTaskExceptionReason = "damaged"
+  MoveTaskExceptionOther            MoveTaskExceptionReason = "other"
+)
+
+// MoveTask is one move of an approved slotting plan for floor staff to carry
+// out: Quantity eaches of a lot from FromLocationID to ToLocationID. Higher
+// Priority goes first; within a priority, lower Sequence.
+type MoveTask struct {
+  gorm.Model
+  ID                  uuid.UUID                 `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  PlanID              uuid.UUID                 `gorm:"type:uuid;not null;index" json:"planID"`
+  WarehouseID         uuid.UUID                 `gorm:"type:uuid;not null;index" json:"warehouseID"`
+  CompanyID           uuid.UUID                 `gorm:"type:uuid;not null;index" json:"companyID"`
+  MoveID              uuid.UUID                 `gorm:"type:uuid;not null;uniqueIndex" json:"moveID"`
+
+  Sequence            int                       `gorm:"column:sequence;not null" json:"sequence"`
+  Priority            int                       `gorm:"column:priority;not null;index" json:"priority"`
+  ItemID              uuid.UUID                 `gorm:"type:uuid;not null;index" json:"itemID"`
+  Item                *Item                     `gorm:"foreignKey:ItemID;references:ID" json:"item,omitempty"`
+  FromLocationID      uuid.UUID                 `gorm:"type:uuid;not null" json:"fromLocationID"`
+  FromLocation        *WarehouseLocation        `gorm:"foreignKey:FromLocationID;references:ID" json:"fromLocation,omitempty"`
+  ToLocationID        uuid.UUID                 `gorm:"type:uuid;not null" json:"toLocationID"`
+  ToLocation          *WarehouseLocation        `gorm:"foreignKey:ToLocationID;references:ID" json:"toLocation,omitempty"`
+  Lot                 string                    `gorm:"column:lot;not null" json:"lot"`
+  Quantity            int                       `gorm:"column:quantity;not null" json:"quantity"`
+  ViaStaging          bool                      `gorm:"column:via_staging;not null" json:"viaStaging"`
+
+  Status              MoveTaskStatus            `gorm:"column:status;not null;index" json:"status"`
+  AssignedToID        *uuid.UUID                `gorm:"type:uuid;index" json:"assignedToID,omitempty"`
+  AssignedAt          *time.Time                `gorm:"column:assigned_at" json:"assignedAt,omitempty"`
+  ClaimedAt           *time.Time                `gorm:"column:claimed_at" json:"claimedAt,omitempty"`
+  StartedAt           *time.Time                `gorm:"column:started_at" json:"startedAt,omitempty"`
+  CompletedAt         *time.Time                `gorm:"column:completed_at" json:"completedAt,omitempty"`
+  CompletedByID       *uuid.UUID                `gorm:"type:uuid" json:"completedByID,omitempty"`
+  ConfirmedQuantity   int                       `gorm:"column:confirmed_quantity;not null" json:"confirmedQuantity"`
+  LedgerGroupID       *uuid.UUID                `gorm:"type:uuid" json:"ledgerGroupID,omitempty"`
+  ExceptionReason     MoveTaskExceptionReason   `gorm:"column:exception_reason" json:"exceptionReason,omitempty"`
+  ExceptionNote       string                    `gorm:"column:exception_note" json:"exceptionNote,omitempty"`
+
+  CreatedAt           time.Time                 `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time                 `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (MoveTask) TableName() string {
+  return "move_task"
+}
@@ -0,0 +1,30 @@
+package types
+
+import (
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+)
+
+// OrderLine is one line of historical order data for a warehouse, in eaches.
+// Lines are keyed on order and item, so re-importing the same history
+// replaces quantities instead of doubling them.
+type OrderLine struct {
+  gorm.Model
+  ID                  uuid.UUID             `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  WarehouseID         uuid.UUID             `gorm:"type:uuid;not null;uniqueIndex:idx_order_line_order_item;index:idx_order_line_warehouse_ordered" json:"warehouseID"`
+  CompanyID           uuid.UUID             `gorm:"type:uuid;not null;index" json:"companyID"`
+  OrderID             string                `gorm:"column:order_id;not null;uniqueIndex:idx_order_line_order_item" json:"orderID"`
+  ItemID              uuid.UUID             `gorm:"type:uuid;not null;index;uniqueIndex:idx_order_line_order_item" json:"itemID"`
+  SKU                 string                `gorm:"column:sku;not null" json:"sku"`
+  Quantity            int                   `gorm:"column:quantity;not null" json:"quantity"`
+  OrderedAt           time.Time             `gorm:"column:ordered_at;not null;index:idx_order_line_warehouse_ordered" json:"orderedAt"`
+
+  CreatedAt           time.Time             `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time             `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (OrderLine) TableName() string {
+  return "order_line"
+}
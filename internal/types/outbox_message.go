@@ -0,0 +1,63 @@
+package types
+
+import (
+  "time"
+
+  "gorm.io/gorm"
+  "github.com/google/uuid"
+)
+
+type OutboxChannel string
+
+const (
+  OutboxChannelEmail    OutboxChannel = "email"
+  OutboxChannelSMS      OutboxChannel = "sms"
+  OutboxChannelWebhook  OutboxChannel = "webhook"
+  // OutboxChannelEvent carries a domain event to the audit log and on to
+  // the webhook endpoints subscribed to it.
+  OutboxChannelEvent    OutboxChannel = "event"
+)
+
+type OutboxStatus string
+
+const (
+  OutboxStatusPending     OutboxStatus = "pending"
+  OutboxStatusProcessing  OutboxStatus = "processing"
+  OutboxStatusSent        OutboxStatus = "sent"
+  OutboxStatusFailed      OutboxStatus = "failed"
+  OutboxStatusDead        OutboxStatus = "dead"
+)
+
+type OutboxMessage struct {
+  gorm.Model
+  ID                  uuid.UUID                 `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  WmsID               *uuid.UUID                `gorm:"index" json:"wmsID,omitempty"`
+  CompanyID           *uuid.UUID                `gorm:"index" json:"companyID,omitempty"`
+
+  Channel             OutboxChannel             `gorm:"type:varchar(20);not null" json:"channel"`
+  MessageType         string                    `gorm:"type:varchar(50);column:message_type" json:"messageType"`
+  SourceType          string                    `gorm:"type:varchar(50);column:source_type" json:"sourceType,omitempty"`
+  SourceID            *uuid.UUID                `gorm:"type:uuid;column:source_id;index" json:"sourceID,omitempty"`
+  Recipient           string                    `gorm:"not null;column:recipient" json:"recipient"`
+  Subject             string                    `gorm:"column:subject" json:"subject,omitempty"`
+  // Body and HTML carry live invitation links and event payloads, so they
+  // are never serialized into API responses.
+  Body                string                    `gorm:"type:text;column:body" json:"-"`
+  HTML                string                    `gorm:"type:text;column:html" json:"-"`
+
+  Status              OutboxStatus              `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+  Attempts            int                       `gorm:"not null;default:0" json:"attempts"`
+  MaxAttempts         int                       `gorm:"not null;default:8" json:"maxAttempts"`
+  NextAttemptAt       time.Time                 `gorm:"not null;default:now();index" json:"nextAttemptAt"`
+  LockedUntil         *time.Time                `gorm:"column:locked_until" json:"lockedUntil,omitempty"`
+  LastError           string                    `gorm:"type:text;column:last_error" json:"lastError,omitempty"`
+  SentAt              *time.Time                `gorm:"column:sent_at" json:"sentAt,omitempty"`
+  DeadAt              *time.Time                `gorm:"column:dead_at" json:"deadAt,omitempty"`
+
+  CreatedAt           time.Time                 `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time                 `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (OutboxMessage) TableName() string {
+  return "outbox_message"
+}
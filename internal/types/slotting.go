@@ -0,0 +1,157 @@
+package types
+
+import (
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+)
+
+type SlottingJobStatus string
+
+const (
+  SlottingJobPending      SlottingJobStatus = "pending"
+  SlottingJobRunning      SlottingJobStatus = "running"
+  SlottingJobCompleted    SlottingJobStatus = "completed"
+  SlottingJobFailed       SlottingJobStatus = "failed"
+  SlottingJobCanceled     SlottingJobStatus = "canceled"
+)
+
+// SlottingParams are the knobs of one optimizer run. Zero values take the
+// defaults of the slotting package. SlotTypes selects which location types
+// are candidate slots (pick_face when empty).
+type SlottingParams struct {
+  Seed                int64                     `json:"seed"`
+  Iterations          int                       `json:"iterations,omitempty"`
+  VelocityRunID       *uuid.UUID                `json:"velocityRunID,omitempty"`
+  SlotTypes           []LocationType            `json:"slotTypes,omitempty"`
+  GoldenLevelMin      int                       `json:"goldenLevelMin,omitempty"`
+  GoldenLevelMax      int                       `json:"goldenLevelMax,omitempty"`
+  GoldenPenalty       float64                   `json:"goldenPenalty,omitempty"`
+  HeavyThresholdKg    float64                   `json:"heavyThresholdKg,omitempty"`
+  HeavyMaxLevel       int                       `json:"heavyMaxLevel,omitempty"`
+  HazmatZones         []string                  `json:"hazmatZones,omitempty"`
+  FillFactor          float64                   `json:"fillFactor,omitempty"`
+  MovePenalty         float64                   `json:"movePenalty,omitempty"`
+  MaxMoves            int                       `json:"maxMoves,omitempty"`
+}
+
+// SlottingUnplaced is an item the optimizer could not give a slot, and why.
+type SlottingUnplaced struct {
+  ItemID              uuid.UUID                 `json:"itemID"`
+  SKU                 string                    `json:"sku"`
+  Reason              string                    `json:"reason"`
+}
+
+// SlottingKPIs mirror slotting.KPIs. TravelDistance is in metres walked when
+// the warehouse's navigation places every slot and in slot-sequence steps
+// otherwise; PickDensity and CubeUtilization are shares between 0 and 1.
+type SlottingKPIs struct {
+  TravelDistance      float64                   `json:"travelDistance"`
+  PickDensity         float64                   `json:"pickDensity"`
+  CubeUtilization     float64                   `json:"cubeUtilization"`
+  MovesRequired       int                       `json:"movesRequired"`
+  SlottedSKUs         int                       `json:"slottedSKUs"`
+}
+
+// SlottingSnapshot is the warehouse state an optimizer run reads: the layout
+// and its navigation, the on-hand positions, the item master and the
+// velocity of one run. It is
+// plain data, so it can be stored and solved again later with other params.
+type SlottingSnapshot struct {
+  Locations           []*WarehouseLocation      `json:"locations"`
+  OnHand              []*InventoryOnHand        `json:"onHand"`
+  Items               []*Item                   `json:"items"`
+  Velocity            []*SKUVelocity            `json:"velocity"`
+  VelocityRunID       *uuid.UUID                `json:"velocityRunID,omitempty"`
+  Navigation          *WarehouseNavigation      `json:"navigation,omitempty"`
+}
+
+// SlottingJob is one background run of the slotting optimizer for a
+// warehouse, on its live state or, with ScenarioID set, on a scenario's
+// snapshot. Costs are in the optimizer's own unit (pick-weighted travel plus
+// penalties), so they only compare between jobs of the same warehouse.
+type SlottingJob struct {
+  gorm.Model
+  ID                  uuid.UUID                 `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  WarehouseID         uuid.UUID                 `gorm:"type:uuid;not null;index" json:"warehouseID"`
+  CompanyID           uuid.UUID                 `gorm:"type:uuid;not null;index" json:"companyID"`
+  RequestedByID       *uuid.UUID                `gorm:"type:uuid" json:"requestedByID,omitempty"`
+  VelocityRunID       *uuid.UUID                `gorm:"type:uuid" json:"velocityRunID,omitempty"`
+  ScenarioID          *uuid.UUID                `gorm:"type:uuid;index" json:"scenarioID,omitempty"`
+  Status              SlottingJobStatus         `gorm:"column:status;not null;index" json:"status"`
+  Params              SlottingParams            `gorm:"column:params;type:jsonb;serializer:json" json:"params"`
+  Progress            float64                   `gorm:"column:progress;not null" json:"progress"`
+  Message             string                    `gorm:"column:message" json:"message,omitempty"`
+  Error               string                    `gorm:"column:error" json:"error,omitempty"`
+
+  SKUCount            int                       `gorm:"column:sku_count;not null" json:"skuCount"`
+  SlotCount           int                       `gorm:"column:slot_count;not null" json:"slotCount"`
+  CostBefore          float64                   `gorm:"column:cost_before;not null" json:"costBefore"`
+  CostAfter           float64                   `gorm:"column:cost_after;not null" json:"costAfter"`
+  MovesRequired       int                       `gorm:"column:moves_required;not null" json:"movesRequired"`
+  Unplaced            []SlottingUnplaced        `gorm:"column:unplaced;type:jsonb;serializer:json" json:"unplaced"`
+  KPIsBefore          SlottingKPIs              `gorm:"column:kpis_before;type:jsonb;serializer:json" json:"kpisBefore"`
+  KPIsAfter           SlottingKPIs              `gorm:"column:kpis_after;type:jsonb;serializer:json" json:"kpisAfter"`
+  StartedAt           *time.Time                `gorm:"column:started_at" json:"startedAt,omitempty"`
+  CompletedAt         *time.Time                `gorm:"column:completed_at" json:"completedAt,omitempty"`
+
+  CreatedAt           time.Time                 `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time                 `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (SlottingJob) TableName() string {
+  return "slotting_job"
+}
+
+// SlottingAssignment is the slot a job gives an item. FromLocationID is the
+// slot the item occupied when the job ran, if any.
+type SlottingAssignment struct {
+  gorm.Model
+  ID                  uuid.UUID                 `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  JobID               uuid.UUID                 `gorm:"type:uuid;not null;uniqueIndex:idx_slotting_assignment_job_item" json:"jobID"`
+  WarehouseID         uuid.UUID                 `gorm:"type:uuid;not null;index" json:"warehouseID"`
+  ItemID              uuid.UUID                 `gorm:"type:uuid;not null;uniqueIndex:idx_slotting_assignment_job_item" json:"itemID"`
+  Item                *Item                     `gorm:"foreignKey:ItemID;references:ID" json:"item,omitempty"`
+  LocationID          uuid.UUID                 `gorm:"type:uuid;not null;index" json:"locationID"`
+  Location            *WarehouseLocation        `gorm:"foreignKey:LocationID;references:ID" json:"location,omitempty"`
+  FromLocationID      *uuid.UUID                `gorm:"type:uuid" json:"fromLocationID,omitempty"`
+  Moved               bool                      `gorm:"column:moved;not null" json:"moved"`
+  PicksPerDay         float64                   `gorm:"column:picks_per_day;not null" json:"picksPerDay"`
+  ABCClass            string                    `gorm:"column:abc_class;not null" json:"abcClass"`
+
+  CreatedAt           time.Time                 `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time                 `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (SlottingAssignment) TableName() string {
+  return "slotting_assignment"
+}
+
+// SlottingMove is one step of a job's move list: take Quantity eaches of a
+// lot from FromLocationID to ToLocationID. Moves run in Sequence order; a
+// move with ViaStaging breaks a cycle of swaps and must be parked in staging
+// until its destination has been emptied.
+type SlottingMove struct {
+  gorm.Model
+  ID                  uuid.UUID                 `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  JobID               uuid.UUID                 `gorm:"type:uuid;not null;index:idx_slotting_move_job_sequence" json:"jobID"`
+  WarehouseID         uuid.UUID                 `gorm:"type:uuid;not null;index" json:"warehouseID"`
+  Sequence            int                       `gorm:"column:sequence;not null;index:idx_slotting_move_job_sequence" json:"sequence"`
+  ItemID              uuid.UUID                 `gorm:"type:uuid;not null;index" json:"itemID"`
+  Item                *Item                     `gorm:"foreignKey:ItemID;references:ID" json:"item,omitempty"`
+  FromLocationID      uuid.UUID                 `gorm:"type:uuid;not null" json:"fromLocationID"`
+  FromLocation        *WarehouseLocation        `gorm:"foreignKey:FromLocationID;references:ID" json:"fromLocation,omitempty"`
+  ToLocationID        uuid.UUID                 `gorm:"type:uuid;not null" json:"toLocationID"`
+  ToLocation          *WarehouseLocation        `gorm:"foreignKey:ToLocationID;references:ID" json:"toLocation,omitempty"`
+  Lot                 string                    `gorm:"column:lot;not null" json:"lot"`
+  Quantity            int                       `gorm:"column:quantity;not null" json:"quantity"`
+  ViaStaging          bool                      `gorm:"column:via_staging;not null" json:"viaStaging"`
+
+  CreatedAt           time.Time                 `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time                 `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (SlottingMove) TableName() string {
+  return "slotting_move"
+}
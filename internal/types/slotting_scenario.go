@@ -0,0 +1,71 @@
+package types
+
+import (
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+)
+
+// SlottingScenario is a named what-if of a warehouse: a frozen snapshot of
+// its layout, inventory and velocity, and the optimizer params to try on it.
+// JobID is the scenario's latest run.
+type SlottingScenario struct {
+  gorm.Model
+  ID                  uuid.UUID                 `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  WarehouseID         uuid.UUID                 `gorm:"type:uuid;not null;index;uniqueIndex:idx_slotting_scenario_name" json:"warehouseID"`
+  CompanyID           uuid.UUID                 `gorm:"type:uuid;not null;index" json:"companyID"`
+  CreatedByID         *uuid.UUID                `gorm:"type:uuid" json:"createdByID,omitempty"`
+
+  Name                string                    `gorm:"column:name;not null;uniqueIndex:idx_slotting_scenario_name" json:"name"`
+  Description         string                    `gorm:"column:description" json:"description,omitempty"`
+  Params              SlottingParams            `gorm:"column:params;type:jsonb;serializer:json" json:"params"`
+  Snapshot            SlottingSnapshot          `gorm:"column:snapshot;type:jsonb;serializer:json" json:"-"`
+  SnapshotAt          time.Time                 `gorm:"column:snapshot_at;not null" json:"snapshotAt"`
+  SnapshotLocations   int                       `gorm:"column:snapshot_locations;not null" json:"snapshotLocations"`
+  SnapshotItems       int                       `gorm:"column:snapshot_items;not null" json:"snapshotItems"`
+  SnapshotPositions   int                       `gorm:"column:snapshot_positions;not null" json:"snapshotPositions"`
+
+  JobID               *uuid.UUID                `gorm:"type:uuid" json:"jobID,omitempty"`
+  Job                 *SlottingJob              `gorm:"foreignKey:JobID;references:ID" json:"job,omitempty"`
+  PromotedPlanID      *uuid.UUID                `gorm:"type:uuid" json:"promotedPlanID,omitempty"`
+
+  CreatedAt           time.Time                 `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time                 `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (SlottingScenario) TableName() string {
+  return "slotting_scenario"
+}
+
+type SlottingPlanStatus string
+
+const (
+  SlottingPlanApproved    SlottingPlanStatus = "approved"
+  SlottingPlanInProgress  SlottingPlanStatus = "in_progress"
+  SlottingPlanCompleted   SlottingPlanStatus = "completed"
+  SlottingPlanCanceled    SlottingPlanStatus = "canceled"
+)
+
+// SlottingPlan is a scenario's move list approved for execution. Its moves
+// are those of JobID.
+type SlottingPlan struct {
+  gorm.Model
+  ID                  uuid.UUID                 `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  WarehouseID         uuid.UUID                 `gorm:"type:uuid;not null;index" json:"warehouseID"`
+  CompanyID           uuid.UUID                 `gorm:"type:uuid;not null;index" json:"companyID"`
+  ScenarioID          uuid.UUID                 `gorm:"type:uuid;not null;index" json:"scenarioID"`
+  JobID               uuid.UUID                 `gorm:"type:uuid;not null;index" json:"jobID"`
+  Status              SlottingPlanStatus        `gorm:"column:status;not null;index" json:"status"`
+  MoveCount           int                       `gorm:"column:move_count;not null" json:"moveCount"`
+  ApprovedByID        *uuid.UUID                `gorm:"type:uuid" json:"approvedByID,omitempty"`
+  ApprovedAt          time.Time                 `gorm:"column:approved_at;not null" json:"approvedAt"`
+  CompletedAt         *time.Time                `gorm:"column:completed_at" json:"completedAt,omitempty"`
+
+  CreatedAt           time.Time                 `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time                 `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (SlottingPlan) TableName() string {
+  return "slotting_plan"
+}
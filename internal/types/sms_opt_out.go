@@ -0,0 +1,28 @@
+package types
+
+import (
+  "time"
+
+  "gorm.io/gorm"
+  "github.com/google/uuid"
+)
+
+// SmsOptOut records the latest STOP/START keyword received from a number.
+// Numbers are stored in E.164 form.
+type SmsOptOut struct {
+  gorm.Model
+  ID                  uuid.UUID                 `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  PhoneNumber         string                    `gorm:"uniqueIndex;not null;column:phone_number" json:"phoneNumber"`
+  OptedOut            bool                      `gorm:"not null;default:true;column:opted_out" json:"optedOut"`
+  Keyword             string                    `gorm:"column:keyword" json:"keyword,omitempty"`
+  Provider            string                    `gorm:"column:provider" json:"provider,omitempty"`
+  OptedOutAt          *time.Time                `gorm:"column:opted_out_at" json:"optedOutAt,omitempty"`
+  OptedInAt           *time.Time                `gorm:"column:opted_in_at" json:"optedInAt,omitempty"`
+
+  CreatedAt           time.Time                 `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time                 `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (SmsOptOut) TableName() string {
+  return "sms_opt_out"
+}
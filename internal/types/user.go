@@ -25,6 +25,7 @@ type User struct {
   LastName            string                    `gorm:"not null;column:last_name" json:"lastName"`
   AvatarBucketKey     string                    `gorm:"column:avatar_bucket_key" json:"avatarBucketKey"`
   AvatarURL           string                    `gorm:"column:avatar_url" json:"avatarURL"`
+  Locale              string                    `gorm:"column:locale" json:"locale,omitempty"`
 
   CreatedAt           time.Time                 `gorm:"not null;default:now()" json:"createdAt"`
   UpdatedAt           time.Time                 `gorm:"not null;default:now()" json:"updatedAt"`
@@ -0,0 +1,102 @@
+package types
+
+import (
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+)
+
+type VelocityRunStatus string
+
+const (
+  VelocityRunPending      VelocityRunStatus = "pending"
+  VelocityRunRunning      VelocityRunStatus = "running"
+  VelocityRunCompleted    VelocityRunStatus = "completed"
+  VelocityRunFailed       VelocityRunStatus = "failed"
+)
+
+// VelocityRun is one background computation of SKU velocity over a window of
+// order history, with the thresholds it was run with.
+type VelocityRun struct {
+  gorm.Model
+  ID                  uuid.UUID             `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  WarehouseID         uuid.UUID             `gorm:"type:uuid;not null;index" json:"warehouseID"`
+  CompanyID           uuid.UUID             `gorm:"type:uuid;not null;index" json:"companyID"`
+  RequestedByID       *uuid.UUID            `gorm:"type:uuid" json:"requestedByID,omitempty"`
+  Status              VelocityRunStatus     `gorm:"column:status;not null;index" json:"status"`
+  Error               string                `gorm:"column:error" json:"error,omitempty"`
+
+  WindowStart         time.Time             `gorm:"column:window_start;not null" json:"windowStart"`
+  WindowEnd           time.Time             `gorm:"column:window_end;not null" json:"windowEnd"`
+  PeriodDays          int                   `gorm:"column:period_days;not null" json:"periodDays"`
+  ABCThresholdA       float64               `gorm:"column:abc_threshold_a;not null" json:"abcThresholdA"`
+  ABCThresholdB       float64               `gorm:"column:abc_threshold_b;not null" json:"abcThresholdB"`
+  XYZThresholdX       float64               `gorm:"column:xyz_threshold_x;not null" json:"xyzThresholdX"`
+  XYZThresholdY       float64               `gorm:"column:xyz_threshold_y;not null" json:"xyzThresholdY"`
+
+  OrderCount          int                   `gorm:"column:order_count;not null" json:"orderCount"`
+  LineCount           int                   `gorm:"column:line_count;not null" json:"lineCount"`
+  ItemCount           int                   `gorm:"column:item_count;not null" json:"itemCount"`
+  StartedAt           *time.Time            `gorm:"column:started_at" json:"startedAt,omitempty"`
+  CompletedAt         *time.Time            `gorm:"column:completed_at" json:"completedAt,omitempty"`
+
+  CreatedAt           time.Time             `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time             `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (VelocityRun) TableName() string {
+  return "velocity_run"
+}
+
+// SKUVelocity is the movement of one item in a VelocityRun.
+type SKUVelocity struct {
+  gorm.Model
+  ID                  uuid.UUID             `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  RunID               uuid.UUID             `gorm:"type:uuid;not null;uniqueIndex:idx_sku_velocity_run_item" json:"runID"`
+  WarehouseID         uuid.UUID             `gorm:"type:uuid;not null;index" json:"warehouseID"`
+  CompanyID           uuid.UUID             `gorm:"type:uuid;not null;index" json:"companyID"`
+  ItemID              uuid.UUID             `gorm:"type:uuid;not null;index;uniqueIndex:idx_sku_velocity_run_item" json:"itemID"`
+  Item                *Item                 `gorm:"foreignKey:ItemID;references:ID" json:"item,omitempty"`
+
+  Rank                int                   `gorm:"column:rank;not null" json:"rank"`
+  PickLines           int                   `gorm:"column:pick_lines;not null" json:"pickLines"`
+  OrderCount          int                   `gorm:"column:order_count;not null" json:"orderCount"`
+  Quantity            int                   `gorm:"column:quantity;not null" json:"quantity"`
+  PicksPerDay         float64               `gorm:"column:picks_per_day;not null" json:"picksPerDay"`
+  CubeCm3             float64               `gorm:"column:cube_cm3;not null" json:"cubeCm3"`
+  CubePerDay          float64               `gorm:"column:cube_per_day;not null" json:"cubePerDay"`
+  DemandCV            float64               `gorm:"column:demand_cv;not null" json:"demandCV"`
+  ABCClass            string                `gorm:"column:abc_class;not null;index" json:"abcClass"`
+  XYZClass            string                `gorm:"column:xyz_class;not null;index" json:"xyzClass"`
+
+  CreatedAt           time.Time             `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time             `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (SKUVelocity) TableName() string {
+  return "sku_velocity"
+}
+
+// SKUAffinity says how often an item is ordered together with another one in
+// a VelocityRun. Each pair is stored once per direction.
+type SKUAffinity struct {
+  gorm.Model
+  ID                  uuid.UUID             `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  RunID               uuid.UUID             `gorm:"type:uuid;not null;index:idx_sku_affinity_run_item" json:"runID"`
+  WarehouseID         uuid.UUID             `gorm:"type:uuid;not null;index" json:"warehouseID"`
+  ItemID              uuid.UUID             `gorm:"type:uuid;not null;index:idx_sku_affinity_run_item" json:"itemID"`
+  OtherItemID         uuid.UUID             `gorm:"type:uuid;not null" json:"otherItemID"`
+
+  CoOccurrences       int                   `gorm:"column:co_occurrences;not null" json:"coOccurrences"`
+  Support             float64               `gorm:"column:support;not null" json:"support"`
+  Confidence          float64               `gorm:"column:confidence;not null" json:"confidence"`
+  Lift                float64               `gorm:"column:lift;not null" json:"lift"`
+
+  CreatedAt           time.Time             `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time             `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (SKUAffinity) TableName() string {
+  return "sku_affinity"
+}
@@ -0,0 +1,70 @@
+package types
+
+import (
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+)
+
+// LocationKind is a level of the physical hierarchy, outermost first:
+// zone -> aisle -> bay -> level -> position.
+type LocationKind string
+
+const (
+  LocationKindZone      LocationKind = "zone"
+  LocationKindAisle     LocationKind = "aisle"
+  LocationKindBay       LocationKind = "bay"
+  LocationKindLevel     LocationKind = "level"
+  LocationKindPosition  LocationKind = "position"
+)
+
+// LocationKinds lists every kind in hierarchy order.
+var LocationKinds = []LocationKind{
+  LocationKindZone,
+  LocationKindAisle,
+  LocationKindBay,
+  LocationKindLevel,
+  LocationKindPosition,
+}
+
+type LocationType string
+
+const (
+  LocationTypePickFace  LocationType = "pick_face"
+  LocationTypeReserve   LocationType = "reserve"
+  LocationTypeFloor     LocationType = "floor"
+  LocationTypeBulk      LocationType = "bulk"
+)
+
+// WarehouseLocation is one node of a warehouse's zone/aisle/bay/level/position
+// tree. Code is this node's own segment ("03"); FullCode joins the segments
+// from the zone down ("A-03-02-B") and is unique within the warehouse.
+type WarehouseLocation struct {
+  gorm.Model
+  ID                  uuid.UUID                 `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  WarehouseID         uuid.UUID                 `gorm:"type:uuid;not null;index;uniqueIndex:idx_warehouse_location_full_code" json:"warehouseID"`
+  Warehouse           *Warehouse                `gorm:"constraint:OnDelete:CASCADE;foreignKey:WarehouseID;references:ID" json:"warehouse,omitempty"`
+  CompanyID           uuid.UUID                 `gorm:"type:uuid;not null;index" json:"companyID"`
+  ParentID            *uuid.UUID                `gorm:"type:uuid;index" json:"parentID,omitempty"`
+  Parent              *WarehouseLocation        `gorm:"constraint:OnDelete:CASCADE;foreignKey:ParentID;references:ID" json:"parent,omitempty"`
+
+  Kind                LocationKind              `gorm:"column:kind;not null;index" json:"kind"`
+  Code                string                    `gorm:"column:code;not null" json:"code"`
+  FullCode            string                    `gorm:"column:full_code;not null;uniqueIndex:idx_warehouse_location_full_code" json:"fullCode"`
+  Name                string                    `gorm:"column:name" json:"name,omitempty"`
+  LocationType        LocationType              `gorm:"column:location_type;index" json:"locationType,omitempty"`
+
+  WidthCm             float64                   `gorm:"column:width_cm" json:"widthCm"`
+  DepthCm             float64                   `gorm:"column:depth_cm" json:"depthCm"`
+  HeightCm            float64                   `gorm:"column:height_cm" json:"heightCm"`
+  MaxWeightKg         float64                   `gorm:"column:max_weight_kg" json:"maxWeightKg"`
+  PickSequence        int                       `gorm:"column:pick_sequence;index" json:"pickSequence"`
+
+  CreatedAt           time.Time                 `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time                 `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (WarehouseLocation) TableName() string {
+  return "warehouse_location"
+}
@@ -0,0 +1,63 @@
+package types
+
+import (
+  "time"
+
+  "github.com/google/uuid"
+  "gorm.io/gorm"
+)
+
+const (
+  DefaultAisleSpacingM  = 3.0
+  DefaultBayWidthM      = 1.0
+)
+
+// AisleDirection restricts travel in a one-way aisle: up walks away from the
+// front cross aisle, down towards it.
+type AisleDirection string
+
+const (
+  AisleDirectionUp    AisleDirection = "up"
+  AisleDirectionDown  AisleDirection = "down"
+)
+
+// NavigationPoint is a floor position in metres. X runs across the aisles,
+// with the first aisle at 0; Y runs along them, with the front cross aisle
+// at 0.
+type NavigationPoint struct {
+  X                   float64                   `json:"x"`
+  Y                   float64                   `json:"y"`
+}
+
+type NavigationDock struct {
+  Name                string                    `json:"name"`
+  X                   float64                   `json:"x"`
+  Y                   float64                   `json:"y"`
+}
+
+// WarehouseNavigation is how a warehouse's aisles are walked. Aisles stand
+// side by side in pick-sequence order, AisleSpacingM apart, and are as long
+// as their bays; BayWidthM stands in for bays without a width. Cross aisles
+// run along the front and back, and at every CrossAisles distance from the
+// front. OneWayAisles maps aisle full codes to their direction.
+type WarehouseNavigation struct {
+  gorm.Model
+  ID                  uuid.UUID                 `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  WarehouseID         uuid.UUID                 `gorm:"type:uuid;not null;uniqueIndex" json:"warehouseID"`
+  CompanyID           uuid.UUID                 `gorm:"type:uuid;not null;index" json:"companyID"`
+
+  AisleSpacingM       float64                   `gorm:"column:aisle_spacing_m;not null" json:"aisleSpacingM"`
+  BayWidthM           float64                   `gorm:"column:bay_width_m;not null" json:"bayWidthM"`
+  CrossAisles         []float64                 `gorm:"column:cross_aisles;type:jsonb;serializer:json" json:"crossAisles"`
+  OneWayAisles        map[string]AisleDirection `gorm:"column:one_way_aisles;type:jsonb;serializer:json" json:"oneWayAisles"`
+  Depot               NavigationPoint           `gorm:"column:depot;type:jsonb;serializer:json" json:"depot"`
+  Docks               []NavigationDock          `gorm:"column:docks;type:jsonb;serializer:json" json:"docks"`
+  UpdatedByID         *uuid.UUID                `gorm:"type:uuid" json:"updatedByID,omitempty"`
+
+  CreatedAt           time.Time                 `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time                 `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (WarehouseNavigation) TableName() string {
+  return "warehouse_navigation"
+}
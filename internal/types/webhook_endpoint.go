@@ -0,0 +1,44 @@
+package types
+
+import (
+  "time"
+
+  "gorm.io/gorm"
+  "github.com/google/uuid"
+)
+
+// WebhookEndpoint is a URL a company or WMS wants domain events POSTed to.
+// Exactly one of WmsID and CompanyID is set. An empty EventTypes receives
+// every event; Secret signs each delivery and is only shown on create.
+type WebhookEndpoint struct {
+  gorm.Model
+  ID                  uuid.UUID             `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+  WmsID               *uuid.UUID            `gorm:"type:uuid;index" json:"wmsID,omitempty"`
+  CompanyID           *uuid.UUID            `gorm:"type:uuid;index" json:"companyID,omitempty"`
+  CreatedByID         *uuid.UUID            `gorm:"type:uuid" json:"createdByID,omitempty"`
+
+  URL                 string                `gorm:"column:url;not null" json:"url"`
+  Secret              string                `gorm:"column:secret;not null" json:"-"`
+  EventTypes          []string              `gorm:"column:event_types;type:jsonb;serializer:json" json:"eventTypes"`
+  Active              bool                  `gorm:"column:active;not null;default:true" json:"active"`
+
+  CreatedAt           time.Time             `gorm:"not null;default:now()" json:"createdAt"`
+  UpdatedAt           time.Time             `gorm:"not null;default:now()" json:"updatedAt"`
+}
+
+func (WebhookEndpoint) TableName() string {
+  return "webhook_endpoint"
+}
+
+// Wants reports whether the endpoint subscribed to eventType.
+func (w *WebhookEndpoint) Wants(eventType string) bool {
+  if len(w.EventTypes) == 0 {
+    return true
+  }
+  for _, t := range w.EventTypes {
+    if t == eventType {
+      return true
+    }
+  }
+  return false
+}
@@ -12,13 +12,19 @@ type Wms struct {
   ID                  uuid.UUID                 `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
   DefaultRoleID       *uuid.UUID                `gorm:"index" json:"defaultRoleID,omitempty"`
   DefaultRole         *Role                     `gorm:"constraint:OnDelete:SET NULL;foreignKey:DefaultRoleID;references:ID" json:"defaultRole,omitempty"`
-  Companies           []*Company                `gorm:"foreignKey:WmsID", json:"companies,omitempty"`
+  Companies           []*Company                `gorm:"foreignKey:WmsID" json:"companies,omitempty"`
   Users               []*User                   `gorm:"foreignKey:WmsID" json:"users,omitempty"`
 
 
   Name                string                    `gorm:"column:name" json:"name"`
   AvatarBucketKey     string                    `gorm:"column:avatar_bucket_key" json:"avatarBucketKey,omitempty"`
   AvatarURL           string                    `gorm:"column:avatar_url" json:"avatarURL,omitempty"`
+  Locale              string                    `gorm:"column:locale" json:"locale,omitempty"`
+  BrandPrimaryColor   string                    `gorm:"column:brand_primary_color" json:"brandPrimaryColor,omitempty"`
+  BrandAccentColor    string                    `gorm:"column:brand_accent_color" json:"brandAccentColor,omitempty"`
+  // Comma separated #RRGGBB colors used for the generated avatars of this wms
+  // and its companies, users and warehouses instead of the default palette.
+  AvatarPalette       string                    `gorm:"column:avatar_palette" json:"avatarPalette,omitempty"`
 
   CreatedAt           time.Time                 `gorm:"not null;default:now()" json:"createdAt"`
   UpdatedAt           time.Time                 `gorm:"not null;default:now()" json:"updatedAt"`
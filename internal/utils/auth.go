@@ -56,17 +56,25 @@ func handleRegisterInputValidation(ctx context.Context, userRepo repos.UserRepo,
     return fmt.Errorf("email is already in use.")
   }
 
-  //3) Check Phone Number
-  if *user.PhoneNumber != "" {
+  //3) Check Phone Number (stored as E.164)
+  if user.PhoneNumber != nil && *user.PhoneNumber != "" {
+    normalizedPhone, err := normalization.ParsePhoneNumber(*user.PhoneNumber)
+    if err != nil {
+      log.Warn("Phone number is invalid, cannot proceed further. Returning error", "phoneNumber", *user.PhoneNumber, "error", err)
+      return fmt.Errorf("phone number is invalid: %w", err)
+    }
+    user.PhoneNumber = &normalizedPhone
     phoneExists, err := userRepo.PhoneNumberExists(ctx, nil, *user.PhoneNumber)
     if err != nil {
       log.Warn("Failed to check if user phone number exists, error from UserRepo. Returning an error.", "error", err)
-      return fmt.Errorf("Failed checking user phone number '%s' existence: %w", user.PhoneNumber, err)
+      return fmt.Errorf("Failed checking user phone number '%s' existence: %w", *user.PhoneNumber, err)
     }
     if phoneExists {
       log.Warn("Phone Number is already in use, cannot continue. Returning an error.", "phoneExists", phoneExists)
       return fmt.Errorf("phone number is already in use.")
     }
+  } else {
+    user.PhoneNumber = nil
   }
 
   //4) Check Password
@@ -0,0 +1,298 @@
+package velocity
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Defaults for a Config field left at zero.
+const (
+	DefaultWindowDays         = 90
+	DefaultPeriodDays         = 7
+	DefaultABCThresholdA      = 0.80
+	DefaultABCThresholdB      = 0.95
+	DefaultXYZThresholdX      = 0.5
+	DefaultXYZThresholdY      = 1.0
+	DefaultMaxAffinityPerItem = 20
+	DefaultMinCoOccurrences   = 2
+)
+
+// maxAffinityOrderSize skips orders with more distinct SKUs than this when
+// counting pairs: a handful of huge replenishment orders would otherwise
+// dominate both the runtime and the result.
+const maxAffinityOrderSize = 100
+
+// Line is one historical order line, in eaches.
+type Line struct {
+	OrderID   string
+	ItemID    uuid.UUID
+	Quantity  int
+	OrderedAt time.Time
+}
+
+// Config is the window and the thresholds of one computation. ABC classes
+// are cut on the cumulative share of pick lines, XYZ classes on the
+// coefficient of variation of the per-period demand.
+type Config struct {
+	WindowStart        time.Time
+	WindowEnd          time.Time
+	PeriodDays         int
+	ABCThresholdA      float64
+	ABCThresholdB      float64
+	XYZThresholdX      float64
+	XYZThresholdY      float64
+	MaxAffinityPerItem int
+	MinCoOccurrences   int
+}
+
+// SKUStats is the movement of one SKU over the window. Lines is the pick
+// frequency (one pick per order line); CubeCm3 is Quantity times the volume
+// of one each.
+type SKUStats struct {
+	ItemID      uuid.UUID
+	Lines       int
+	Orders      int
+	Quantity    int
+	PicksPerDay float64
+	CubeCm3     float64
+	CubePerDay  float64
+	DemandCV    float64
+	ABCClass    string
+	XYZClass    string
+	Rank        int
+}
+
+// Affinity says how often ItemID is ordered together with OtherItemID. Both
+// directions are returned, since Confidence is relative to ItemID's orders.
+type Affinity struct {
+	ItemID        uuid.UUID
+	OtherItemID   uuid.UUID
+	CoOccurrences int
+	Support       float64
+	Confidence    float64
+	Lift          float64
+}
+
+// Summary describes the lines that went into a computation.
+type Summary struct {
+	Orders int
+	Lines  int
+	Items  int
+	Days   float64
+}
+
+// WithDefaults returns cfg with every zero field set to its default, and the
+// window ending now when it is not set.
+func (cfg Config) WithDefaults(now time.Time) Config {
+	if cfg.WindowEnd.IsZero() {
+		cfg.WindowEnd = now
+	}
+	if cfg.WindowStart.IsZero() {
+		cfg.WindowStart = cfg.WindowEnd.AddDate(0, 0, -DefaultWindowDays)
+	}
+	if cfg.PeriodDays <= 0 {
+		cfg.PeriodDays = DefaultPeriodDays
+	}
+	if cfg.ABCThresholdA <= 0 {
+		cfg.ABCThresholdA = DefaultABCThresholdA
+	}
+	if cfg.ABCThresholdB <= 0 {
+		cfg.ABCThresholdB = DefaultABCThresholdB
+	}
+	if cfg.XYZThresholdX <= 0 {
+		cfg.XYZThresholdX = DefaultXYZThresholdX
+	}
+	if cfg.XYZThresholdY <= 0 {
+		cfg.XYZThresholdY = DefaultXYZThresholdY
+	}
+	if cfg.MaxAffinityPerItem <= 0 {
+		cfg.MaxAffinityPerItem = DefaultMaxAffinityPerItem
+	}
+	if cfg.MinCoOccurrences <= 0 {
+		cfg.MinCoOccurrences = DefaultMinCoOccurrences
+	}
+	return cfg
+}
+
+// Compute turns order lines into per-SKU stats and affinities. Lines outside
+// the window are ignored. eachCubeCm3 holds the volume of one each per item;
+// items missing from it move zero cube. The result only depends on its
+// input: ties are broken on item ID, so the same history always gives the
+// same classes and ranks.
+func Compute(lines []Line, eachCubeCm3 map[uuid.UUID]float64, cfg Config) ([]SKUStats, []Affinity, Summary) {
+	days := cfg.WindowEnd.Sub(cfg.WindowStart).Hours() / 24
+	if days < 1 {
+		days = 1
+	}
+	period := time.Duration(cfg.PeriodDays) * 24 * time.Hour
+	periods := int(math.Ceil(cfg.WindowEnd.Sub(cfg.WindowStart).Hours() / period.Hours()))
+	if periods < 1 {
+		periods = 1
+	}
+
+	byItem := map[uuid.UUID]*SKUStats{}
+	demand := map[uuid.UUID][]float64{}
+	orderItems := map[string]map[uuid.UUID]bool{}
+	for _, l := range lines {
+		if l.OrderedAt.Before(cfg.WindowStart) || !l.OrderedAt.Before(cfg.WindowEnd) {
+			continue
+		}
+		s := byItem[l.ItemID]
+		if s == nil {
+			s = &SKUStats{ItemID: l.ItemID}
+			byItem[l.ItemID] = s
+			demand[l.ItemID] = make([]float64, periods)
+		}
+		s.Lines++
+		s.Quantity += l.Quantity
+		p := int(l.OrderedAt.Sub(cfg.WindowStart) / period)
+		if p >= periods {
+			p = periods - 1
+		}
+		demand[l.ItemID][p] += float64(l.Quantity)
+		items := orderItems[l.OrderID]
+		if items == nil {
+			items = map[uuid.UUID]bool{}
+			orderItems[l.OrderID] = items
+		}
+		if !items[l.ItemID] {
+			items[l.ItemID] = true
+			s.Orders++
+		}
+	}
+
+	summary := Summary{Orders: len(orderItems), Items: len(byItem), Days: days}
+	stats := make([]SKUStats, 0, len(byItem))
+	totalLines := 0
+	for id, s := range byItem {
+		summary.Lines += s.Lines
+		totalLines += s.Lines
+		s.PicksPerDay = float64(s.Lines) / days
+		s.CubeCm3 = float64(s.Quantity) * eachCubeCm3[id]
+		s.CubePerDay = s.CubeCm3 / days
+		s.DemandCV = coefficientOfVariation(demand[id])
+		s.XYZClass = xyzClass(s.DemandCV, cfg)
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Lines != stats[j].Lines {
+			return stats[i].Lines > stats[j].Lines
+		}
+		if stats[i].Quantity != stats[j].Quantity {
+			return stats[i].Quantity > stats[j].Quantity
+		}
+		return stats[i].ItemID.String() < stats[j].ItemID.String()
+	})
+	cumulative := 0
+	for i := range stats {
+		share := 0.0
+		if totalLines > 0 {
+			share = float64(cumulative) / float64(totalLines)
+		}
+		switch {
+		case share < cfg.ABCThresholdA:
+			stats[i].ABCClass = "A"
+		case share < cfg.ABCThresholdB:
+			stats[i].ABCClass = "B"
+		default:
+			stats[i].ABCClass = "C"
+		}
+		stats[i].Rank = i + 1
+		cumulative += stats[i].Lines
+	}
+
+	return stats, computeAffinities(orderItems, byItem, cfg), summary
+}
+
+func computeAffinities(orderItems map[string]map[uuid.UUID]bool, byItem map[uuid.UUID]*SKUStats, cfg Config) []Affinity {
+	type pair struct{ a, b uuid.UUID }
+	counts := map[pair]int{}
+	for _, items := range orderItems {
+		if len(items) < 2 || len(items) > maxAffinityOrderSize {
+			continue
+		}
+		ids := make([]uuid.UUID, 0, len(items))
+		for id := range items {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+		for i := 0; i < len(ids); i++ {
+			for j := i + 1; j < len(ids); j++ {
+				counts[pair{ids[i], ids[j]}]++
+			}
+		}
+	}
+
+	orders := float64(len(orderItems))
+	perItem := map[uuid.UUID][]Affinity{}
+	for p, n := range counts {
+		if n < cfg.MinCoOccurrences {
+			continue
+		}
+		oa, ob := float64(byItem[p.a].Orders), float64(byItem[p.b].Orders)
+		support := float64(n) / orders
+		lift := float64(n) * orders / (oa * ob)
+		perItem[p.a] = append(perItem[p.a], Affinity{ItemID: p.a, OtherItemID: p.b, CoOccurrences: n, Support: support, Confidence: float64(n) / oa, Lift: lift})
+		perItem[p.b] = append(perItem[p.b], Affinity{ItemID: p.b, OtherItemID: p.a, CoOccurrences: n, Support: support, Confidence: float64(n) / ob, Lift: lift})
+	}
+
+	ids := make([]uuid.UUID, 0, len(perItem))
+	for id := range perItem {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+	var out []Affinity
+	for _, id := range ids {
+		list := perItem[id]
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].CoOccurrences != list[j].CoOccurrences {
+				return list[i].CoOccurrences > list[j].CoOccurrences
+			}
+			if list[i].Lift != list[j].Lift {
+				return list[i].Lift > list[j].Lift
+			}
+			return list[i].OtherItemID.String() < list[j].OtherItemID.String()
+		})
+		if len(list) > cfg.MaxAffinityPerItem {
+			list = list[:cfg.MaxAffinityPerItem]
+		}
+		out = append(out, list...)
+	}
+	return out
+}
+
+// coefficientOfVariation is the standard deviation of demand over its mean.
+// Every item in the stats has at least one line, so the mean is positive;
+// the zero guard only keeps the result finite for JSON and Postgres.
+func coefficientOfVariation(demand []float64) float64 {
+	if len(demand) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, d := range demand {
+		sum += d
+	}
+	mean := sum / float64(len(demand))
+	if mean == 0 {
+		return 0
+	}
+	variance := 0.0
+	for _, d := range demand {
+		variance += (d - mean) * (d - mean)
+	}
+	variance /= float64(len(demand))
+	return math.Sqrt(variance) / mean
+}
+
+func xyzClass(cv float64, cfg Config) string {
+	switch {
+	case cv <= cfg.XYZThresholdX:
+		return "X"
+	case cv <= cfg.XYZThresholdY:
+		return "Y"
+	}
+	return "Z"
+}
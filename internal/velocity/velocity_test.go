@@ -0,0 +1,232 @@
+package velocity
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var windowStart = time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+
+// testConfig is a four-week window of weekly periods with the default
+// thresholds.
+func testConfig() Config {
+	return Config{WindowStart: windowStart, WindowEnd: windowStart.AddDate(0, 0, 28)}.WithDefaults(windowStart)
+}
+
+func testItem(n int) uuid.UUID {
+	return uuid.MustParse(fmt.Sprintf("00000000-0000-0000-0000-%012d", n))
+}
+
+func statsByItem(stats []SKUStats) map[uuid.UUID]SKUStats {
+	out := map[uuid.UUID]SKUStats{}
+	for _, s := range stats {
+		out[s.ItemID] = s
+	}
+	return out
+}
+
+// TestComputeABC cuts classes on the share of lines ranked above an item,
+// so the item that crosses a threshold keeps the better class.
+func TestComputeABC(t *testing.T) {
+	tests := []struct {
+		name      string
+		lines     []int
+		quantity  []int
+		wantClass string
+		wantOrder []int
+	}{
+		{name: "cumulative share", lines: []int{5, 50, 15, 30}, wantClass: "CABA", wantOrder: []int{2, 4, 3, 1}},
+		{name: "exactly at the thresholds", lines: []int{80, 15, 5}, wantClass: "ABC", wantOrder: []int{1, 2, 3}},
+		{name: "ties on quantity then id", lines: []int{10, 10, 10}, quantity: []int{1, 2, 1}, wantClass: "AAA", wantOrder: []int{2, 1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []Line
+			for i, n := range tt.lines {
+				qty := 1
+				if tt.quantity != nil {
+					qty = tt.quantity[i]
+				}
+				for j := 0; j < n; j++ {
+					lines = append(lines, Line{OrderID: fmt.Sprintf("o%d-%d", i, j), ItemID: testItem(i + 1), Quantity: qty, OrderedAt: windowStart})
+				}
+			}
+			stats, _, _ := Compute(lines, nil, testConfig())
+			class := ""
+			for i, s := range stats {
+				if s.ItemID != testItem(tt.wantOrder[i]) || s.Rank != i+1 {
+					t.Errorf("rank %d = item %s (rank %d), want item %d", i+1, s.ItemID, s.Rank, tt.wantOrder[i])
+				}
+			}
+			byItem := statsByItem(stats)
+			for i := range tt.lines {
+				class += byItem[testItem(i+1)].ABCClass
+			}
+			if class != tt.wantClass {
+				t.Errorf("classes = %s, want %s", class, tt.wantClass)
+			}
+		})
+	}
+}
+
+func TestComputeXYZ(t *testing.T) {
+	tests := []struct {
+		name      string
+		perPeriod []int
+		wantCV    float64
+		wantClass string
+	}{
+		{name: "steady", perPeriod: []int{10, 10, 10, 10}, wantCV: 0, wantClass: "X"},
+		{name: "at the x threshold", perPeriod: []int{15, 5, 15, 5}, wantCV: 0.5, wantClass: "X"},
+		{name: "between thresholds", perPeriod: []int{16, 4, 16, 4}, wantCV: 0.6, wantClass: "Y"},
+		{name: "at the y threshold", perPeriod: []int{20, 0, 20, 0}, wantCV: 1, wantClass: "Y"},
+		{name: "lumpy", perPeriod: []int{40, 0, 0, 0}, wantCV: math.Sqrt(3), wantClass: "Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []Line
+			for p, qty := range tt.perPeriod {
+				if qty > 0 {
+					lines = append(lines, Line{OrderID: fmt.Sprint(p), ItemID: testItem(1), Quantity: qty, OrderedAt: windowStart.AddDate(0, 0, 7*p+3)})
+				}
+			}
+			stats, _, _ := Compute(lines, nil, testConfig())
+			if len(stats) != 1 {
+				t.Fatalf("got %d stats, want 1", len(stats))
+			}
+			if math.Abs(stats[0].DemandCV-tt.wantCV) > 1e-9 || stats[0].XYZClass != tt.wantClass {
+				t.Errorf("cv = %g class %s, want %g class %s", stats[0].DemandCV, stats[0].XYZClass, tt.wantCV, tt.wantClass)
+			}
+		})
+	}
+}
+
+// TestComputeWindowAndPeriods checks the window includes its start and
+// excludes its end, and lines are bucketed by the period they fall in.
+func TestComputeWindowAndPeriods(t *testing.T) {
+	end := windowStart.AddDate(0, 0, 28)
+	week := 7 * 24 * time.Hour
+	tests := []struct {
+		name      string
+		at        []time.Time
+		wantLines int
+		wantCV    float64
+	}{
+		{name: "start is inclusive", at: []time.Time{windowStart}, wantLines: 1, wantCV: math.Sqrt(3)},
+		{name: "end is exclusive", at: []time.Time{end}, wantLines: 0},
+		{name: "before the start", at: []time.Time{windowStart.Add(-time.Nanosecond)}, wantLines: 0},
+		{name: "last instant", at: []time.Time{end.Add(-time.Nanosecond)}, wantLines: 1, wantCV: math.Sqrt(3)},
+		{name: "same period", at: []time.Time{windowStart, windowStart.Add(week - time.Nanosecond)}, wantLines: 2, wantCV: math.Sqrt(3)},
+		{name: "period boundary", at: []time.Time{windowStart.Add(week - time.Nanosecond), windowStart.Add(week)}, wantLines: 2, wantCV: 1},
+		{name: "one line a period", at: []time.Time{windowStart, windowStart.Add(week), windowStart.Add(2 * week), end.Add(-time.Nanosecond)}, wantLines: 4, wantCV: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []Line
+			for i, at := range tt.at {
+				lines = append(lines, Line{OrderID: fmt.Sprint(i), ItemID: testItem(1), Quantity: 1, OrderedAt: at})
+			}
+			stats, _, summary := Compute(lines, map[uuid.UUID]float64{testItem(1): 500}, testConfig())
+			if summary.Lines != tt.wantLines || summary.Days != 28 {
+				t.Fatalf("summary = %+v, want %d lines over 28 days", summary, tt.wantLines)
+			}
+			if tt.wantLines == 0 {
+				if len(stats) != 0 {
+					t.Errorf("got stats %+v, want none", stats)
+				}
+				return
+			}
+			s := stats[0]
+			if math.Abs(s.DemandCV-tt.wantCV) > 1e-9 {
+				t.Errorf("cv = %g, want %g", s.DemandCV, tt.wantCV)
+			}
+			if s.PicksPerDay != float64(tt.wantLines)/28 || s.CubeCm3 != 500*float64(tt.wantLines) || s.CubePerDay != s.CubeCm3/28 {
+				t.Errorf("picks/day %g, cube %g, cube/day %g for %d lines", s.PicksPerDay, s.CubeCm3, s.CubePerDay, tt.wantLines)
+			}
+		})
+	}
+}
+
+func TestComputeShortWindow(t *testing.T) {
+	cfg := Config{WindowStart: windowStart, WindowEnd: windowStart.Add(6 * time.Hour)}.WithDefaults(windowStart)
+	stats, _, summary := Compute([]Line{{OrderID: "o", ItemID: testItem(1), Quantity: 3, OrderedAt: windowStart.Add(time.Hour)}}, nil, cfg)
+	if summary.Days != 1 || stats[0].PicksPerDay != 1 || stats[0].DemandCV != 0 {
+		t.Errorf("summary %+v, stats %+v: want one day and a single period", summary, stats[0])
+	}
+}
+
+func TestComputeAffinities(t *testing.T) {
+	a, b, c := testItem(1), testItem(2), testItem(3)
+	orders := map[string][]uuid.UUID{
+		"o1": {a, b, a},
+		"o2": {a, b},
+		"o3": {a, b, c},
+		"o4": {a},
+		"o5": {c},
+		"o6": {b},
+		"o7": {b},
+	}
+	var lines []Line
+	for id, items := range orders {
+		for _, item := range items {
+			lines = append(lines, Line{OrderID: id, ItemID: item, Quantity: 1, OrderedAt: windowStart})
+		}
+	}
+	stats, affinities, summary := Compute(lines, nil, testConfig())
+	if summary.Orders != 7 || summary.Lines != 12 || summary.Items != 3 {
+		t.Errorf("summary = %+v, want 7 orders, 12 lines and 3 items", summary)
+	}
+	if s := statsByItem(stats)[a]; s.Lines != 5 || s.Orders != 4 {
+		t.Errorf("item a has %d lines in %d orders, want 5 lines in 4 orders", s.Lines, s.Orders)
+	}
+
+	// Only a and b are ordered together at least twice: 3 of 7 orders,
+	// out of a's 4 and b's 5.
+	want := []Affinity{
+		{ItemID: a, OtherItemID: b, CoOccurrences: 3, Support: 3.0 / 7, Confidence: 3.0 / 4, Lift: 3.0 * 7 / (4 * 5)},
+		{ItemID: b, OtherItemID: a, CoOccurrences: 3, Support: 3.0 / 7, Confidence: 3.0 / 5, Lift: 3.0 * 7 / (4 * 5)},
+	}
+	if len(affinities) != len(want) {
+		t.Fatalf("affinities = %+v, want %+v", affinities, want)
+	}
+	for i, w := range want {
+		got := affinities[i]
+		if got.ItemID != w.ItemID || got.OtherItemID != w.OtherItemID || got.CoOccurrences != w.CoOccurrences ||
+			math.Abs(got.Support-w.Support) > 1e-9 || math.Abs(got.Confidence-w.Confidence) > 1e-9 || math.Abs(got.Lift-w.Lift) > 1e-9 {
+			t.Errorf("affinity %d = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+// TestComputeAffinitiesSkipsLargeOrders counts pairs in orders of up to
+// maxAffinityOrderSize distinct SKUs and skips larger ones.
+func TestComputeAffinitiesSkipsLargeOrders(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		want int
+	}{
+		{name: "at the limit", size: maxAffinityOrderSize, want: maxAffinityOrderSize * 3},
+		{name: "over the limit", size: maxAffinityOrderSize + 1, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []Line
+			for o := 0; o < DefaultMinCoOccurrences; o++ {
+				for i := 0; i < tt.size; i++ {
+					lines = append(lines, Line{OrderID: fmt.Sprint(o), ItemID: testItem(i + 1), Quantity: 1, OrderedAt: windowStart})
+				}
+			}
+			cfg := testConfig()
+			cfg.MaxAffinityPerItem = 3
+			_, affinities, _ := Compute(lines, nil, cfg)
+			if len(affinities) != tt.want {
+				t.Errorf("got %d affinities, want %d", len(affinities), tt.want)
+			}
+		})
+	}
+}